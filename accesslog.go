@@ -0,0 +1,168 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var accessLogOutput io.Writer = os.Stdout
+
+// AccessLogEntry is the structured representation of a request, written by
+// LoggingMiddleware when LOG_FORMAT is set to json.
+type AccessLogEntry struct {
+	RequestID    string                 `json:"request_id,omitempty"`
+	Time         string                 `json:"time"`
+	RemoteAddr   string                 `json:"remote_addr"`
+	User         string                 `json:"user,omitempty"`
+	Method       string                 `json:"method"`
+	URI          string                 `json:"uri"`
+	Proto        string                 `json:"proto"`
+	Status       int                    `json:"status"`
+	Bytes        int64                  `json:"bytes"`
+	PayloadBytes int64                  `json:"payload_bytes"`
+	Duration     float64                `json:"duration_ms"`
+	Referer      string                 `json:"referer,omitempty"`
+	UserAgent    string                 `json:"user_agent,omitempty"`
+	Resources    map[string]int64       `json:"resources,omitempty"`
+	Fingerprint  *ClientFingerprint     `json:"fingerprint,omitempty"`
+	Fields       map[string]interface{} `json:"fields,omitempty"`
+}
+
+// AccessLogEnricher returns custom fields to add to the access log entry of
+// a request, e.g. the tenant, plan or shard, pulled from its context. It is
+// called once the response is complete, and may return nil to add nothing.
+type AccessLogEnricher func(r *http.Request, s *ResponseStats) map[string]interface{}
+
+// accessLogEnrichers holds the AccessLogEnrichers registered with an API,
+// shared by every copy of the API.
+type accessLogEnrichers struct {
+	sync.RWMutex
+	enrichers []AccessLogEnricher
+}
+
+func (ae *accessLogEnrichers) add(e AccessLogEnricher) {
+	ae.Lock()
+	defer ae.Unlock()
+	ae.enrichers = append(ae.enrichers, e)
+}
+
+// fields returns the fields added by every enricher, in the order they were
+// registered, so later enrichers override earlier ones.
+func (ae *accessLogEnrichers) fields(r *http.Request, s *ResponseStats) map[string]interface{} {
+	ae.RLock()
+	defer ae.RUnlock()
+	var fields map[string]interface{}
+	for _, e := range ae.enrichers {
+		for k, v := range e(r, s) {
+			if fields == nil {
+				fields = map[string]interface{}{}
+			}
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// AddAccessLogEnricher registers an AccessLogEnricher, whose fields
+// LoggingMiddleware adds to every access log entry: under "fields" when
+// LOG_FORMAT is json, or as key="value" pairs after the Combined Log Format.
+func (api *API) AddAccessLogEnricher(e AccessLogEnricher) {
+	api.accessLogEnrichers.add(e)
+}
+
+// NewAccessLogEntry creates an instance of AccessLogEntry from the given
+// request and its ResponseStats. When CLIENT_FINGERPRINTS is true, the
+// entry includes the request's ClientFingerprint.
+func NewAccessLogEntry(r *http.Request, s *ResponseStats) AccessLogEntry {
+	entry := AccessLogEntry{
+		RequestID:    s.RequestID,
+		Time:         s.Start.Format(time.RFC3339),
+		RemoteAddr:   remoteHost(r),
+		User:         remoteUser(r),
+		Method:       r.Method,
+		URI:          requestURI(r),
+		Proto:        r.Proto,
+		Status:       s.Status,
+		Bytes:        s.WireBytes,
+		PayloadBytes: s.PayloadBytes,
+		Duration:     float64(s.Duration()) / float64(time.Millisecond),
+		Referer:      r.Referer(),
+		UserAgent:    r.UserAgent(),
+		Resources:    s.Ledger.Counts(),
+	}
+	if conf.ClientFingerprints {
+		fp := GetClientFingerprint(r)
+		entry.Fingerprint = &fp
+	}
+	return entry
+}
+
+// writeAccessLogEntry writes the given access log entry, in the configured
+// LOG_FORMAT: combined (Apache Combined Log Format) or json.
+func writeAccessLogEntry(w io.Writer, entry AccessLogEntry, start time.Time) {
+	if conf.LogFormat == "json" {
+		json.NewEncoder(w).Encode(entry)
+		return
+	}
+	user := entry.User
+	if user == "" {
+		user = "-"
+	}
+	fmt.Fprintf(w, "%s - %s [%s] \"%s %s %s\" %d %d %q %q%s\n",
+		entry.RemoteAddr,
+		user,
+		start.Format("02/Jan/2006:15:04:05 -0700"),
+		entry.Method,
+		entry.URI,
+		entry.Proto,
+		entry.Status,
+		entry.Bytes,
+		entry.Referer,
+		entry.UserAgent,
+		formatAccessLogFields(entry.Fields),
+	)
+}
+
+// formatAccessLogFields formats custom fields as key="value" pairs, sorted
+// by key, for the Combined Log Format.
+func formatAccessLogFields(fields map[string]interface{}) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var out string
+	for _, k := range keys {
+		out += fmt.Sprintf(" %s=%q", k, fmt.Sprint(fields[k]))
+	}
+	return out
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func remoteUser(r *http.Request) string {
+	if r.URL.User != nil {
+		return r.URL.User.Username()
+	}
+	return ""
+}
+
+func requestURI(r *http.Request) string {
+	if r.RequestURI != "" {
+		return r.RequestURI
+	}
+	return r.URL.RequestURI()
+}
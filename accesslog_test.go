@@ -0,0 +1,113 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestNewAccessLogEntry() {
+	s := &ResponseStats{Start: time.Now(), Status: 200, WireBytes: 10, PayloadBytes: 20}
+	entry := NewAccessLogEntry(suite.TestGetRequest, s)
+	suite.Equal("GET", entry.Method, "expects the method to be set")
+	suite.Equal("/test/2?id=1&a=b", entry.URI, "expects the uri to be set")
+	suite.Equal(int64(10), entry.Bytes, "expects bytes to be wire bytes")
+	suite.Equal(int64(20), entry.PayloadBytes, "expects payload bytes to be set")
+}
+
+func (suite *HyperdriveTestSuite) TestWriteAccessLogCombined() {
+	var buf bytes.Buffer
+	s := &ResponseStats{Start: time.Now(), Status: 200, WireBytes: 10}
+	writeAccessLogEntry(&buf, NewAccessLogEntry(suite.TestGetRequest, s), s.Start)
+	suite.Contains(buf.String(), `"GET /test/2?id=1&a=b HTTP/1.1" 200 10`, "expects combined log format")
+}
+
+func (suite *HyperdriveTestSuite) TestWriteAccessLogJSON() {
+	var (
+		buf   bytes.Buffer
+		entry AccessLogEntry
+	)
+	conf.LogFormat = "json"
+	defer func() { conf.LogFormat = "combined" }()
+	s := &ResponseStats{Start: time.Now(), Status: 200, WireBytes: 10, PayloadBytes: 20, Ledger: &Ledger{}}
+	s.Ledger.Add(LedgerDBQueries, 3)
+	writeAccessLogEntry(&buf, NewAccessLogEntry(suite.TestGetRequest, s), s.Start)
+	suite.Nil(json.Unmarshal(buf.Bytes(), &entry), "expects valid json")
+	suite.Equal(int64(20), entry.PayloadBytes, "expects payload bytes to be logged")
+	suite.Equal(int64(3), entry.Resources[LedgerDBQueries], "expects the resources counted by the Ledger to be logged")
+}
+
+func (suite *HyperdriveTestSuite) TestWriteAccessLogJSONFingerprint() {
+	var (
+		buf   bytes.Buffer
+		entry AccessLogEntry
+	)
+	defer func(c Config) { conf = c }(conf)
+	conf.LogFormat = "json"
+	conf.ClientFingerprints = true
+	s := &ResponseStats{Start: time.Now(), Status: 200}
+	writeAccessLogEntry(&buf, NewAccessLogEntry(suite.TestGetRequest, s), s.Start)
+	suite.Nil(json.Unmarshal(buf.Bytes(), &entry), "expects valid json")
+	suite.Equal(GetClientFingerprint(suite.TestGetRequest), *entry.Fingerprint, "expects the client fingerprint to be logged")
+}
+
+func (suite *HyperdriveTestSuite) TestLoggingMiddlewareCompressed() {
+	var (
+		buf   bytes.Buffer
+		entry AccessLogEntry
+	)
+	accessLogOutput = &buf
+	conf.LogFormat = "json"
+	defer func() {
+		accessLogOutput = os.Stdout
+		conf.LogFormat = "combined"
+	}()
+	h := NewMiddlewareChain(suite.TestAPI.LoggingMiddleware, suite.TestAPI.CompressionMiddleware).Then(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(strings.Repeat("a", 1000)))
+	}))
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	suite.Nil(json.Unmarshal(buf.Bytes(), &entry), "expects valid json")
+	suite.Equal(int64(1000), entry.PayloadBytes, "expects uncompressed size")
+	suite.True(entry.Bytes < entry.PayloadBytes, "expects compressed size")
+}
+
+func (suite *HyperdriveTestSuite) TestLoggingMiddlewareEnrichers() {
+	var (
+		buf   bytes.Buffer
+		entry AccessLogEntry
+	)
+	accessLogOutput = &buf
+	conf.LogFormat = "json"
+	defer func() {
+		accessLogOutput = os.Stdout
+		conf.LogFormat = "combined"
+	}()
+	api := NewAPI("API", "Test API Desc")
+	api.AddAccessLogEnricher(func(r *http.Request, s *ResponseStats) map[string]interface{} {
+		return map[string]interface{}{"tenant": r.Header.Get("X-Tenant"), "plan": "free"}
+	})
+	api.AddAccessLogEnricher(func(r *http.Request, s *ResponseStats) map[string]interface{} {
+		return map[string]interface{}{"plan": "pro"}
+	})
+	h := NewMiddlewareChain(api.LoggingMiddleware).Then(suite.TestHandler)
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("X-Tenant", "acme")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	suite.Nil(json.Unmarshal(buf.Bytes(), &entry), "expects valid json")
+	suite.Equal("acme", entry.Fields["tenant"], "expects the fields added by enrichers to be logged")
+	suite.Equal("pro", entry.Fields["plan"], "expects later enrichers to override earlier ones")
+}
+
+func (suite *HyperdriveTestSuite) TestWriteAccessLogCombinedFields() {
+	var buf bytes.Buffer
+	entry := NewAccessLogEntry(suite.TestGetRequest, &ResponseStats{Start: time.Now(), Status: 200})
+	entry.Fields = map[string]interface{}{"tenant": "acme", "shard": 3}
+	writeAccessLogEntry(&buf, entry, time.Now())
+	suite.Contains(buf.String(), ` shard="3" tenant="acme"`, "expects the fields to be appended, sorted by key")
+}
@@ -0,0 +1,221 @@
+package hyperdrive
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const recentErrorsSize = 50
+
+//go:embed admin
+var adminAssets embed.FS
+
+// RecentError is a request which failed with a server error (5xx), as shown
+// by the admin dashboard. Panic is set if the error was caused by a panic,
+// recovered by RecoveryMiddleware.
+type RecentError struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	URI    string    `json:"uri"`
+	Status int       `json:"status"`
+	Panic  string    `json:"panic,omitempty"`
+}
+
+// recentErrors holds the last few server errors logged by LoggingMiddleware,
+// shared by every copy of an API.
+type recentErrors struct {
+	sync.Mutex
+	errors []RecentError
+}
+
+func (re *recentErrors) add(e RecentError) {
+	re.Lock()
+	defer re.Unlock()
+	re.errors = append(re.errors, e)
+	if len(re.errors) > recentErrorsSize {
+		re.errors = re.errors[len(re.errors)-recentErrorsSize:]
+	}
+}
+
+func (re *recentErrors) list() []RecentError {
+	re.Lock()
+	defer re.Unlock()
+	list := make([]RecentError, len(re.errors))
+	for i, e := range re.errors {
+		list[len(re.errors)-1-i] = e
+	}
+	return list
+}
+
+// record adds the request to the recent errors, if it failed with a server
+// error.
+func (re *recentErrors) record(r *http.Request, s *ResponseStats) {
+	if s.Status < 500 {
+		return
+	}
+	e := RecentError{Time: s.Start, Method: r.Method, URI: requestURI(r), Status: s.Status}
+	if s.Recovered != nil {
+		e.Panic = fmt.Sprint(s.Recovered)
+	}
+	re.add(e)
+}
+
+// RecentErrors returns the last 50 server errors (5xx) logged by
+// LoggingMiddleware, newest first.
+func (api *API) RecentErrors() []RecentError {
+	return api.recentErrors.list()
+}
+
+// RouteMetrics summarises the metrics recorded by MetricsMiddleware for a
+// route and method, as shown by the admin dashboard.
+type RouteMetrics struct {
+	Route       string  `json:"route"`
+	Method      string  `json:"method"`
+	Requests    uint64  `json:"requests"`
+	Errors      uint64  `json:"errors"`
+	AvgDuration float64 `json:"avg_duration_ms"`
+}
+
+// summary returns the RouteMetrics for every route and method which has
+// served a request, sorted by route and method.
+func (m *metrics) summary() []RouteMetrics {
+	type key struct{ route, method string }
+	var (
+		routes = map[key]*RouteMetrics{}
+		keys   []key
+	)
+	families, _ := m.registry.Gather()
+	for _, family := range families {
+		if family.GetName() != metricsNamespace+"_"+metricsSubsystem+"_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := metricLabels(metric)
+			k := key{labels["route"], labels["method"]}
+			rm, ok := routes[k]
+			if !ok {
+				rm = &RouteMetrics{Route: k.route, Method: k.method}
+				routes[k] = rm
+				keys = append(keys, k)
+			}
+			h := metric.GetHistogram()
+			total := float64(rm.Requests)*rm.AvgDuration + h.GetSampleSum()*1000
+			rm.Requests += h.GetSampleCount()
+			if status, _ := strconv.Atoi(labels["status"]); status >= 500 {
+				rm.Errors += h.GetSampleCount()
+			}
+			if rm.Requests > 0 {
+				rm.AvgDuration = total / float64(rm.Requests)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route == keys[j].route {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].route < keys[j].route
+	})
+	summary := make([]RouteMetrics, 0, len(keys))
+	for _, k := range keys {
+		summary = append(summary, *routes[k])
+	}
+	return summary
+}
+
+func metricLabels(metric *dto.Metric) map[string]string {
+	labels := map[string]string{}
+	for _, l := range metric.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+// redactedConfig returns the configuration of the API, by environment
+// variable, with the values of the variables which may contain secrets
+// (e.g. BASIC_AUTH_USERS) redacted.
+func redactedConfig(c Config) map[string]string {
+	config := map[string]string{}
+	v, t := reflect.ValueOf(c), reflect.TypeOf(c)
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		value := fmt.Sprint(v.Field(i).Interface())
+		if value != "" && isSecretConfig(name) {
+			value = "[redacted]"
+		}
+		config[name] = value
+	}
+	return config
+}
+
+func isSecretConfig(name string) bool {
+	for _, s := range []string{"USERS", "SECRET", "PASSWORD", "TOKEN", "KEY", "DSN"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminStatus is the json representation of the API served to the admin
+// dashboard.
+type AdminStatus struct {
+	Name        string             `json:"name"`
+	Env         string             `json:"env"`
+	Uptime      string             `json:"uptime"`
+	Maintenance bool               `json:"maintenance"`
+	Routes      []EndpointResource `json:"routes"`
+	Metrics     []RouteMetrics     `json:"metrics"`
+	Health      []HealthStatus     `json:"health"`
+	Errors      []RecentError      `json:"errors"`
+	Config      map[string]string  `json:"config"`
+}
+
+// AdminHandler returns an http.Handler which serves the admin dashboard under
+// /admin: a web page showing the API's endpoints, metrics, health checks,
+// recent errors, and config, refreshed every few seconds from the json
+// served at /admin/api/status.
+//
+// The dashboard is mounted automatically when ADMIN_ENABLED is true, and
+// protected with Basic Auth for the users set by ADMIN_USERS (see
+// NewStaticCredentials).
+func (api *API) AdminHandler() http.Handler {
+	static, _ := fs.Sub(adminAssets, "admin")
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", http.StripPrefix("/admin/", http.FileServer(http.FS(static))))
+	mux.HandleFunc("/admin/api/status", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(AdminStatus{
+			Name:        api.Name,
+			Env:         conf.Env,
+			Uptime:      time.Since(api.started).Truncate(time.Second).String(),
+			Maintenance: api.InMaintenance(),
+			Routes:      api.Root.Endpoints,
+			Metrics:     api.metrics.summary(),
+			Health:      api.CheckHealth(),
+			Errors:      api.RecentErrors(),
+			Config:      redactedConfig(conf),
+		})
+	})
+	return mux
+}
+
+// mountAdmin mounts the admin dashboard on the API's Router, behind Basic
+// Auth and the API's MiddlewareChain.
+func (api *API) mountAdmin() {
+	auth := api.BasicAuthMiddleware(NewStaticCredentials(conf.AdminUsers))
+	api.Router.PathPrefix("/admin").Handler(api.DefaultMiddlewareChain(auth(api.AdminHandler())))
+}
@@ -0,0 +1,71 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestAdminHandlerIndex() {
+	rw := httptest.NewRecorder()
+	suite.TestAPI.AdminHandler().ServeHTTP(rw, httptest.NewRequest("GET", "/admin/", nil))
+	suite.Equal(http.StatusOK, rw.Code, "expects the dashboard to be served")
+	suite.Contains(rw.Body.String(), "<title>hyperdrive admin</title>", "expects the embedded dashboard page")
+}
+
+func (suite *HyperdriveTestSuite) TestAdminHandlerStatus() {
+	var status AdminStatus
+	suite.TestAPI.AddEndpoint(suite.TestEndpoint)
+	suite.TestAPI.AddHealthCheck("db", func() error { return nil })
+	rw := httptest.NewRecorder()
+	suite.TestAPI.AdminHandler().ServeHTTP(rw, httptest.NewRequest("GET", "/admin/api/status", nil))
+	suite.Nil(json.Unmarshal(rw.Body.Bytes(), &status), "expects valid json")
+	suite.Equal("API", status.Name, "expects the name of the API")
+	suite.Equal(1, len(status.Routes), "expects the endpoints of the API")
+	suite.Equal(1, len(status.Health), "expects the health checks of the API")
+	suite.Equal("5000", status.Config["PORT"], "expects the config of the API")
+}
+
+func (suite *HyperdriveTestSuite) TestAdminMounted() {
+	conf.AdminEnabled, conf.AdminUsers = true, "ops:s3cret"
+	defer func() { conf.AdminEnabled, conf.AdminUsers = false, "" }()
+	api := NewAPI("API", "Test API Desc")
+	rw := httptest.NewRecorder()
+	api.Router.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/", nil))
+	suite.Equal(http.StatusUnauthorized, rw.Code, "expects the dashboard to require authentication")
+	rw, r := httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/", nil)
+	r.SetBasicAuth("ops", "s3cret")
+	api.Router.ServeHTTP(rw, r)
+	suite.Equal(http.StatusOK, rw.Code, "expects the dashboard to be served to the configured users")
+}
+
+func (suite *HyperdriveTestSuite) TestRecentErrors() {
+	h := suite.TestAPI.LoggingMiddleware(suite.TestAPI.RecoveryMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+	accessLogOutput = ioutil.Discard
+	defer func() { accessLogOutput = os.Stdout }()
+	h.ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	errs := suite.TestAPI.RecentErrors()
+	suite.Equal(1, len(errs), "expects server errors to be recorded")
+	suite.Equal("boom", errs[0].Panic, "expects the recovered panic to be recorded")
+}
+
+func (suite *HyperdriveTestSuite) TestRecentErrorsLimit() {
+	re := &recentErrors{}
+	for i := 0; i < recentErrorsSize+10; i++ {
+		re.add(RecentError{Time: time.Unix(int64(i), 0), Status: 500})
+	}
+	list := re.list()
+	suite.Equal(recentErrorsSize, len(list), "expects only the most recent errors to be kept")
+	suite.Equal(time.Unix(int64(recentErrorsSize+9), 0), list[0].Time, "expects the newest error first")
+}
+
+func (suite *HyperdriveTestSuite) TestRedactedConfig() {
+	config := redactedConfig(Config{BasicAuthUsers: "alice:s3cret", Port: 5000})
+	suite.Equal("[redacted]", config["BASIC_AUTH_USERS"], "expects secrets to be redacted")
+	suite.Equal("5000", config["PORT"], "expects other config to be shown")
+}
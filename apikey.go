@@ -0,0 +1,182 @@
+package hyperdrive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+type apiKeyContextKey struct{}
+
+// APIKey holds the metadata of an API key: the owner it was issued to, the
+// scopes and roles it grants, and the number of requests it may make per
+// RATE_LIMIT_WINDOW, overriding RATE_LIMIT (e.g. for free and paid tiers),
+// when RateLimitMiddleware limits requests by principal. Quota likewise
+// overrides QUOTA_LIMIT, for QuotaMiddleware. A limit of 0 uses the
+// configured default, and a negative limit is unlimited.
+type APIKey struct {
+	Key       string   `json:"key"`
+	Owner     string   `json:"owner"`
+	Scopes    []string `json:"scopes"`
+	Roles     []string `json:"roles,omitempty"`
+	RateLimit int      `json:"rate_limit,omitempty"`
+	Quota     int      `json:"quota,omitempty"`
+}
+
+// KeyStore interface is satisfied by anything that can look up the API keys
+// presented to APIKeyMiddleware, e.g. a database. LookupKey returns nil,
+// without an error, when the key does not exist. Implementations must be safe
+// for concurrent use. See MemoryKeyStore and FileKeyStore.
+type KeyStore interface {
+	LookupKey(key string) (*APIKey, error)
+}
+
+// MemoryKeyStore is an in-memory implementation of KeyStore. Keys are held by
+// their sha256 digest, so looking them up does not compare the keys
+// themselves.
+type MemoryKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]*APIKey
+}
+
+// NewMemoryKeyStore creates an instance of MemoryKeyStore, holding the given
+// keys.
+func NewMemoryKeyStore(keys ...APIKey) *MemoryKeyStore {
+	s := &MemoryKeyStore{keys: map[string]*APIKey{}}
+	s.SetKeys(keys)
+	return s
+}
+
+func sha256Digest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// AddKey adds the given key to the store, replacing any key with the same
+// value.
+func (s *MemoryKeyStore) AddKey(key APIKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := key
+	s.keys[sha256Digest(key.Key)] = &k
+}
+
+// RemoveKey removes the given key from the store.
+func (s *MemoryKeyStore) RemoveKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, sha256Digest(key))
+}
+
+// SetKeys replaces every key in the store with the given keys.
+func (s *MemoryKeyStore) SetKeys(keys []APIKey) {
+	table := make(map[string]*APIKey, len(keys))
+	for _, key := range keys {
+		k := key
+		table[sha256Digest(key.Key)] = &k
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = table
+}
+
+// LookupKey returns the metadata of the given key, or nil if it is not in
+// the store.
+func (s *MemoryKeyStore) LookupKey(key string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys[sha256Digest(key)], nil
+}
+
+// FileKeyStore is a KeyStore holding the keys listed in a json file, e.g.
+//
+//	[{"key": "abc123", "owner": "billing-service", "scopes": ["invoices:read"]}]
+//
+// Call Reload to pick up changes to the file.
+type FileKeyStore struct {
+	*MemoryKeyStore
+	path string
+}
+
+// NewFileKeyStore creates an instance of FileKeyStore, loading the keys in
+// the file at the given path.
+func NewFileKeyStore(path string) (*FileKeyStore, error) {
+	s := &FileKeyStore{MemoryKeyStore: NewMemoryKeyStore(), path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload replaces the keys in the store with those in the file. If the file
+// can not be read, the keys are left unchanged.
+func (s *FileKeyStore) Reload() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var keys []APIKey
+	if err := json.NewDecoder(f).Decode(&keys); err != nil {
+		return fmt.Errorf("could not parse API keys file %s: %v", s.path, err)
+	}
+	for _, key := range keys {
+		if key.Key == "" {
+			return fmt.Errorf("API key is missing for %q in %s", key.Owner, s.path)
+		}
+	}
+	s.SetKeys(keys)
+	return nil
+}
+
+// CurrentAPIKey returns the APIKey authenticated by APIKeyMiddleware, and
+// false if the request has not been authenticated.
+func CurrentAPIKey(r *http.Request) (*APIKey, bool) {
+	key, ok := r.Context().Value(apiKeyContextKey{}).(*APIKey)
+	return key, ok
+}
+
+// requestAPIKey returns the key presented by the request, in the header set
+// by API_KEY_HEADER (default: X-API-Key), or the query param set by
+// API_KEY_QUERY_PARAM, if it is set.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(conf.APIKeyHeader); key != "" {
+		return key
+	}
+	if conf.APIKeyQueryParam != "" {
+		return r.URL.Query().Get(conf.APIKeyQueryParam)
+	}
+	return ""
+}
+
+// APIKeyMiddleware returns a Middleware which requires requests to present a
+// valid API key, responding to those without one with a 401 Unauthorized.
+// Keys are read from the header set by API_KEY_HEADER (default: X-API-Key),
+// or, if API_KEY_QUERY_PARAM is set, from that query param. Keys are looked
+// up in the given KeyStore, and the metadata of the key can be retrieved with
+// CurrentAPIKey.
+func (api *API) APIKeyMiddleware(store KeyStore) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			var key *APIKey
+			if k := requestAPIKey(r); k != "" {
+				var err error
+				if key, err = store.LookupKey(k); err != nil {
+					http.Error(rw, GetErrorText(http.StatusInternalServerError, err), http.StatusInternalServerError)
+					return
+				}
+			}
+			if key == nil {
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key))
+			h.ServeHTTP(rw, WithPrincipal(r, &Principal{ID: key.Owner, Method: AuthMethodAPIKey, Scopes: key.Scopes, Roles: key.Roles, RateLimit: key.RateLimit, Quota: key.Quota}))
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package hyperdrive
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+)
+
+type errorKeyStore struct{}
+
+func (errorKeyStore) LookupKey(key string) (*APIKey, error) {
+	return nil, errors.New("unavailable")
+}
+
+func (suite *HyperdriveTestSuite) TestMemoryKeyStore() {
+	s := NewMemoryKeyStore(APIKey{Key: "abc123", Owner: "billing", Scopes: []string{"invoices:read"}})
+	key, err := s.LookupKey("abc123")
+	suite.Nil(err, "expects no error")
+	suite.Equal("billing", key.Owner, "expects the key's metadata")
+	s.RemoveKey("abc123")
+	key, _ = s.LookupKey("abc123")
+	suite.Nil(key, "expects nil for keys which are not in the store")
+	s.AddKey(APIKey{Key: "def456", Owner: "reports"})
+	key, _ = s.LookupKey("def456")
+	suite.Equal("reports", key.Owner, "expects added keys to be found")
+}
+
+func (suite *HyperdriveTestSuite) TestFileKeyStore() {
+	f, _ := ioutil.TempFile("", "keys")
+	defer os.Remove(f.Name())
+	f.WriteString(`[{"key": "abc123", "owner": "billing", "scopes": ["invoices:read"]}]`)
+	f.Close()
+	s, err := NewFileKeyStore(f.Name())
+	suite.Nil(err, "expects no error")
+	key, _ := s.LookupKey("abc123")
+	suite.Equal([]string{"invoices:read"}, key.Scopes, "expects the keys in the file to be loaded")
+	ioutil.WriteFile(f.Name(), []byte(`[{"owner": "billing"}]`), 0644)
+	suite.Error(s.Reload(), "expects an error for entries without a key")
+	key, _ = s.LookupKey("abc123")
+	suite.NotNil(key, "expects the keys to be left unchanged when the file is invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestFileKeyStoreError() {
+	_, err := NewFileKeyStore("does-not-exist.json")
+	suite.Error(err, "expects an error if the file is missing")
+}
+
+func (suite *HyperdriveTestSuite) TestAPIKeyMiddleware() {
+	var (
+		key       *APIKey
+		principal *Principal
+	)
+	h := suite.TestAPI.APIKeyMiddleware(NewMemoryKeyStore(APIKey{Key: "abc123", Owner: "billing", Scopes: []string{"invoices:read"}}))(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		key, _ = CurrentAPIKey(r)
+		principal, _ = CurrentPrincipal(r)
+	}))
+	rw, r := httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("X-API-Key", "abc123")
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusOK, rw.Code, "expects valid keys to be accepted")
+	suite.Equal("billing", key.Owner, "expects the key to be added to the request context")
+	suite.Equal(&Principal{ID: "billing", Method: AuthMethodAPIKey, Scopes: []string{"invoices:read"}}, principal, "expects the owner of the key to be the principal")
+}
+
+func (suite *HyperdriveTestSuite) TestAPIKeyMiddlewareUnauthorized() {
+	h := suite.TestAPI.APIKeyMiddleware(NewMemoryKeyStore(APIKey{Key: "abc123"}))(suite.TestHandler)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/test", nil))
+	suite.Equal(http.StatusUnauthorized, rw.Code, "expects requests without a key to be rejected")
+	rw, r := httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("X-API-Key", "wrong")
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusUnauthorized, rw.Code, "expects unknown keys to be rejected")
+}
+
+func (suite *HyperdriveTestSuite) TestAPIKeyMiddlewareQueryParam() {
+	h := suite.TestAPI.APIKeyMiddleware(NewMemoryKeyStore(APIKey{Key: "abc123"}))(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/test?api_key=abc123", nil))
+	suite.Equal(http.StatusUnauthorized, rw.Code, "expects query params to be ignored by default")
+	conf.APIKeyQueryParam = "api_key"
+	defer func() { conf.APIKeyQueryParam = "" }()
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/test?api_key=abc123", nil))
+	suite.Equal(http.StatusOK, rw.Code, "expects keys in the configured query param to be accepted")
+}
+
+func (suite *HyperdriveTestSuite) TestAPIKeyMiddlewareError() {
+	rw, r := httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("X-API-Key", "abc123")
+	suite.TestAPI.APIKeyMiddleware(errorKeyStore{})(suite.TestHandler).ServeHTTP(rw, r)
+	suite.Equal(http.StatusInternalServerError, rw.Code, "expects a 500 if the key could not be looked up")
+}
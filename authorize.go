@@ -0,0 +1,84 @@
+package hyperdrive
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ScopesRequirer interface is satisfied if the endpoint has implemented a
+// method called RequiredScopes(), returning the scopes a client must have
+// been granted, every one of them, to make requests with the given method.
+type ScopesRequirer interface {
+	RequiredScopes(method string) []string
+}
+
+// RolesRequirer interface is satisfied if the endpoint has implemented a
+// method called RequiredRoles(), returning the roles a client must have, at
+// least one of them, to make requests with the given method.
+type RolesRequirer interface {
+	RequiredRoles(method string) []string
+}
+
+// AuthorizeMiddleware returns a Middleware which compares the scopes and roles
+// required by the endpoint (see ScopesRequirer and RolesRequirer) with those
+// granted to the authenticated Principal (see CurrentPrincipal).
+// Unauthenticated requests are rejected with a 401 error, and requests
+// missing a scope or role with a 403 error, both with an
+// application/problem+json body. It must run after the auth middleware, and
+// is added to endpoints which require scopes or roles automatically, by
+// AddEndpoint.
+func (api *API) AuthorizeMiddleware(e Endpointer) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			var required, requiredRoles []string
+			if s, ok := e.(ScopesRequirer); ok {
+				required = s.RequiredScopes(r.Method)
+			}
+			if s, ok := e.(RolesRequirer); ok {
+				requiredRoles = s.RequiredRoles(r.Method)
+			}
+			if len(required) == 0 && len(requiredRoles) == 0 {
+				h.ServeHTTP(rw, r)
+				return
+			}
+			p, ok := CurrentPrincipal(r)
+			if !ok {
+				writeProblem(rw, http.StatusUnauthorized, "Authentication is required.")
+				return
+			}
+			var missing []string
+			for _, s := range required {
+				if !contains(p.Scopes, s) {
+					missing = append(missing, s)
+				}
+			}
+			if len(missing) > 0 {
+				writeProblem(rw, http.StatusForbidden, fmt.Sprintf("Missing required scopes: %s.", strings.Join(missing, ", ")))
+				return
+			}
+			if len(requiredRoles) > 0 && !p.HasRole(requiredRoles...) {
+				writeProblem(rw, http.StatusForbidden, fmt.Sprintf("One of these roles is required: %s.", strings.Join(requiredRoles, ", ")))
+				return
+			}
+			h.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// requiresAuthorization returns true if the endpoint requires scopes or
+// roles.
+func requiresAuthorization(e Endpointer) bool {
+	_, scopes := e.(ScopesRequirer)
+	_, roles := e.(RolesRequirer)
+	return scopes || roles
+}
+
+func containsAny(s []string, values []string) bool {
+	for _, v := range values {
+		if contains(s, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+)
+
+type ScopedEndpoint struct {
+	Endpoint
+}
+
+func (e *ScopedEndpoint) Get(rw http.ResponseWriter, r *http.Request) {}
+
+func (e *ScopedEndpoint) Delete(rw http.ResponseWriter, r *http.Request) {}
+
+func (e *ScopedEndpoint) RequiredScopes(method string) []string {
+	if method == "GET" {
+		return []string{"widgets:read"}
+	}
+	return []string{"widgets:read", "widgets:write"}
+}
+
+func (e *ScopedEndpoint) RequiredRoles(method string) []string {
+	if method == "DELETE" {
+		return []string{"admin", "owner"}
+	}
+	return nil
+}
+
+func (suite *HyperdriveTestSuite) authorizeRequest(method string, key *APIKey) *httptest.ResponseRecorder {
+	e := &ScopedEndpoint{Endpoint: *NewEndpoint("Widgets", "Widgets", "/widgets", "1")}
+	h := suite.TestAPI.AuthorizeMiddleware(e)(NewMethodHandler(e))
+	if key != nil {
+		h = suite.TestAPI.APIKeyMiddleware(NewMemoryKeyStore(*key))(h)
+	}
+	rw, r := httptest.NewRecorder(), httptest.NewRequest(method, "/widgets", nil)
+	r.Header.Set("X-API-Key", "abc123")
+	h.ServeHTTP(rw, r)
+	return rw
+}
+
+func (suite *HyperdriveTestSuite) TestAuthorizeMiddleware() {
+	rw := suite.authorizeRequest("GET", &APIKey{Key: "abc123", Scopes: []string{"widgets:read"}})
+	suite.Equal(http.StatusOK, rw.Code, "expects requests with the required scopes to be allowed")
+}
+
+func (suite *HyperdriveTestSuite) TestAuthorizeMiddlewareUnauthenticated() {
+	rw := suite.authorizeRequest("GET", nil)
+	suite.Equal(http.StatusUnauthorized, rw.Code, "expects unauthenticated requests to be rejected")
+	suite.Equal("application/problem+json", rw.Header().Get("Content-Type"), "expects a problem+json body")
+}
+
+func (suite *HyperdriveTestSuite) TestAuthorizeMiddlewareMissingScope() {
+	rw := suite.authorizeRequest("DELETE", &APIKey{Key: "abc123", Scopes: []string{"widgets:read"}, Roles: []string{"admin"}})
+	suite.Equal(http.StatusForbidden, rw.Code, "expects requests missing a scope to be rejected")
+	suite.Equal("application/problem+json", rw.Header().Get("Content-Type"), "expects a problem+json body")
+	var p Problem
+	json.NewDecoder(rw.Body).Decode(&p)
+	suite.Equal(Problem{Type: "about:blank", Title: "Forbidden", Status: 403, Detail: "Missing required scopes: widgets:write."}, p, "expects the missing scopes to be described")
+}
+
+func (suite *HyperdriveTestSuite) TestAuthorizeMiddlewareRoles() {
+	rw := suite.authorizeRequest("DELETE", &APIKey{Key: "abc123", Scopes: []string{"widgets:read", "widgets:write"}})
+	suite.Equal(http.StatusForbidden, rw.Code, "expects requests without a required role to be rejected")
+	rw = suite.authorizeRequest("DELETE", &APIKey{Key: "abc123", Scopes: []string{"widgets:read", "widgets:write"}, Roles: []string{"owner"}})
+	suite.Equal(http.StatusOK, rw.Code, "expects requests with one of the required roles to be allowed")
+}
+
+func (suite *HyperdriveTestSuite) TestAuthorizeMiddlewareNotRequired() {
+	h := suite.TestAPI.AuthorizeMiddleware(suite.TestEndpoint)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/test", nil))
+	suite.Equal(http.StatusOK, rw.Code, "expects endpoints without requirements to be allowed")
+}
+
+func (suite *HyperdriveTestSuite) TestAddEndpointAuthorize() {
+	e := &ScopedEndpoint{Endpoint: *NewEndpoint("Widgets", "Widgets", "/widgets", "1")}
+	suite.TestAPI.AddEndpoint(e)
+	rw, r := httptest.NewRecorder(), httptest.NewRequest("GET", "/widgets", nil)
+	r.Header.Set("Accept", GetContentTypeJSON(suite.TestAPI, e))
+	suite.TestAPI.Router.ServeHTTP(rw, r)
+	suite.Equal(http.StatusUnauthorized, rw.Code, "expects endpoints which require scopes to be authorized")
+}
@@ -0,0 +1,107 @@
+package hyperdrive
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type basicAuthContextKey struct{}
+
+// CredentialValidator interface is satisfied by anything that can check the
+// username and password given to BasicAuthMiddleware, e.g. a database of
+// users. StaticCredentials is a simple implementation, configured from the
+// environment. An error should only be returned if the credentials could not
+// be checked, e.g. the database is unavailable.
+type CredentialValidator interface {
+	ValidateCredentials(username string, password string) (bool, error)
+}
+
+// CredentialValidatorFunc is an adapter to allow the use of ordinary
+// functions as a CredentialValidator.
+type CredentialValidatorFunc func(username string, password string) (bool, error)
+
+// ValidateCredentials calls f(username, password).
+func (f CredentialValidatorFunc) ValidateCredentials(username string, password string) (bool, error) {
+	return f(username, password)
+}
+
+// StaticCredentials is a CredentialValidator for a fixed set of users, keyed
+// by username, with their passwords as values.
+type StaticCredentials map[string]string
+
+// NewStaticCredentials creates an instance of StaticCredentials from a comma
+// separated list of username:password pairs, e.g. "alice:s3cret,bob:hunter2",
+// as set by the BASIC_AUTH_USERS environment variable.
+func NewStaticCredentials(users string) StaticCredentials {
+	creds := StaticCredentials{}
+	for _, user := range splitList(users) {
+		if i := strings.Index(user, ":"); i > 0 {
+			creds[user[:i]] = user[i+1:]
+		}
+	}
+	return creds
+}
+
+// ValidateCredentials returns true if the password is correct for the given
+// username. Passwords are compared in constant time, and unknown users take
+// as long to check as known ones, so valid usernames and passwords can not be
+// discovered by timing the response.
+func (creds StaticCredentials) ValidateCredentials(username string, password string) (bool, error) {
+	expected, ok := creds[username]
+	if !secureCompare(password, expected) {
+		return false, nil
+	}
+	return ok, nil
+}
+
+// secureCompare compares two strings in constant time. Both are hashed first,
+// so the time taken does not depend on their lengths either.
+func secureCompare(given string, expected string) bool {
+	g, e := sha256.Sum256([]byte(given)), sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(g[:], e[:]) == 1
+}
+
+// BasicAuthUser returns the username authenticated by BasicAuthMiddleware,
+// and false if the request has not been authenticated.
+func BasicAuthUser(r *http.Request) (string, bool) {
+	user, ok := r.Context().Value(basicAuthContextKey{}).(string)
+	return user, ok
+}
+
+// BasicAuthMiddleware returns a Middleware which requires requests to be
+// authenticated with HTTP Basic Auth, responding to those without valid
+// credentials with a 401 Unauthorized, and a WWW-Authenticate header for the
+// realm set by BASIC_AUTH_REALM (default: hyperdrive). Credentials are checked
+// with the given CredentialValidator, or, if it is nil, against the users set
+// by BASIC_AUTH_USERS (see NewStaticCredentials). The authenticated username
+// can be retrieved with BasicAuthUser.
+func (api *API) BasicAuthMiddleware(v CredentialValidator) Middleware {
+	if v == nil {
+		v = NewStaticCredentials(conf.BasicAuthUsers)
+	}
+	challenge := fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, conf.BasicAuthRealm)
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			if ok {
+				valid, err := v.ValidateCredentials(user, pass)
+				if err != nil {
+					http.Error(rw, GetErrorText(http.StatusInternalServerError, err), http.StatusInternalServerError)
+					return
+				}
+				ok = valid
+			}
+			if !ok {
+				rw.Header().Set("WWW-Authenticate", challenge)
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), basicAuthContextKey{}, user))
+			h.ServeHTTP(rw, WithPrincipal(r, &Principal{ID: user, Method: AuthMethodBasic}))
+		})
+	}
+}
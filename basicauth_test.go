@@ -0,0 +1,75 @@
+package hyperdrive
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestNewStaticCredentials() {
+	creds := NewStaticCredentials("alice:s3cret, bob:pass:word,invalid")
+	suite.Equal(StaticCredentials{"alice": "s3cret", "bob": "pass:word"}, creds, "expects username:password pairs to be parsed")
+}
+
+func (suite *HyperdriveTestSuite) TestStaticCredentialsValidate() {
+	creds := StaticCredentials{"alice": "s3cret"}
+	ok, _ := creds.ValidateCredentials("alice", "s3cret")
+	suite.True(ok, "expects valid credentials to be accepted")
+	ok, _ = creds.ValidateCredentials("alice", "wrong")
+	suite.False(ok, "expects an invalid password to be rejected")
+	ok, _ = creds.ValidateCredentials("mallory", "")
+	suite.False(ok, "expects unknown users to be rejected")
+}
+
+func (suite *HyperdriveTestSuite) TestBasicAuthMiddleware() {
+	var (
+		user      string
+		principal *Principal
+	)
+	h := suite.TestAPI.BasicAuthMiddleware(StaticCredentials{"alice": "s3cret"})(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		user, _ = BasicAuthUser(r)
+		principal, _ = CurrentPrincipal(r)
+	}))
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.SetBasicAuth("alice", "s3cret")
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusOK, rw.Code, "expects valid credentials to be accepted")
+	suite.Equal("alice", user, "expects the username to be added to the request context")
+	suite.Equal(&Principal{ID: "alice", Method: AuthMethodBasic}, principal, "expects the user to be the principal")
+}
+
+func (suite *HyperdriveTestSuite) TestBasicAuthMiddlewareUnauthorized() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.SetBasicAuth("alice", "wrong")
+	suite.TestAPI.BasicAuthMiddleware(StaticCredentials{"alice": "s3cret"})(suite.TestHandler).ServeHTTP(rw, r)
+	suite.Equal(http.StatusUnauthorized, rw.Code, "expects invalid credentials to be rejected")
+	suite.Equal(`Basic realm="hyperdrive", charset="UTF-8"`, rw.Header().Get("WWW-Authenticate"), "expects a challenge for the configured realm")
+}
+
+func (suite *HyperdriveTestSuite) TestBasicAuthMiddlewareFromConfig() {
+	conf.BasicAuthUsers = "bob:hunter2"
+	defer func() { conf.BasicAuthUsers = "" }()
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.SetBasicAuth("bob", "hunter2")
+	suite.TestAPI.BasicAuthMiddleware(nil)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})).ServeHTTP(rw, r)
+	suite.Equal(http.StatusOK, rw.Code, "expects the users set by BASIC_AUTH_USERS to be accepted")
+}
+
+func (suite *HyperdriveTestSuite) TestBasicAuthMiddlewareError() {
+	v := CredentialValidatorFunc(func(username string, password string) (bool, error) {
+		return false, errors.New("unavailable")
+	})
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.SetBasicAuth("alice", "s3cret")
+	suite.TestAPI.BasicAuthMiddleware(v)(suite.TestHandler).ServeHTTP(rw, r)
+	suite.Equal(http.StatusInternalServerError, rw.Code, "expects a 500 if the credentials could not be checked")
+}
+
+func (suite *HyperdriveTestSuite) TestBasicAuthUserMissing() {
+	_, ok := BasicAuthUser(suite.TestGetRequest)
+	suite.False(ok, "expects false for requests which have not been authenticated")
+}
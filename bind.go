@@ -0,0 +1,400 @@
+package hyperdrive
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The sources of the values decoded by Bind, as given in a source struct tag.
+const (
+	SourcePath  = "path"
+	SourceQuery = "query"
+	SourceBody  = "body"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// Validatable interface is satisfied by structs given to Bind which check
+// their own values once they have been decoded, e.g. ranges, formats, or
+// combinations of fields. Validate adds a FieldError to verr for each invalid
+// value, e.g.
+//
+//	func (u *UpdateUser) Validate(verr *hyperdrive.ValidationError) {
+//		if len(u.Name) > 100 {
+//			verr.AddBodyError(hyperdrive.JSONPointer("name"), hyperdrive.ValidationInvalid, "name must be at most 100 characters")
+//		}
+//	}
+type Validatable interface {
+	Validate(verr *ValidationError)
+}
+
+// bindField is a field of the struct given to Bind.
+type bindField struct {
+	value    reflect.Value
+	key      string
+	source   string
+	required bool
+	def      string
+}
+
+// Bind decodes the path, query, and body params of the request into the
+// struct pointed to by v, so handlers do not have to pluck values from
+// url.Values. Only fields with a param tag are decoded, by the key given in
+// the tag, using the same syntax as endpoint params, so "r" marks the
+// methods the param is required for, e.g.
+//
+//	type UpdateUser struct {
+//		ID      int               `param:"id" source:"path"`
+//		Notify  bool              `param:"notify" source:"query"`
+//		Name    string            `param:"name;r=PUT"`
+//		Tags    []string          `param:"tags"`
+//		Address map[string]string `param:"address" source:"body"`
+//	}
+//
+// The d option gives the value used when the param is missing, e.g.
+// `param:"per_page;d=25"`, which is converted like a query value. The source
+// tag restricts where the value is read from: path, query, or body. Without
+// it, the sources are tried in the order of precedence of Params (by
+// default, the path, then the body, then the query). JSON bodies (see
+// BodyJSON) are decoded as JSON, so fields may be nested structs, maps, or
+// slices, and so are MessagePack and YAML bodies (see BodyMsgPack and
+// BodyYAML). XML bodies (see BodyXML) are decoded into the same shape, so may
+// fill nested fields too, but their values are strings, which are converted
+// like form values. Bodies decoded by a registered Codec (see RegisterCodec)
+// are bound like JSON bodies. Form bodies, query, and path values are
+// converted to the field's type: strings, booleans, numbers, time.Duration,
+// time.Time (RFC 3339), types implementing encoding.TextUnmarshaler, and
+// slices and pointers of these.
+//
+// If any value is missing or can not be converted, Bind returns a
+// *ValidationError, with a FieldError for each of them, which can be written
+// with WriteValidationError (or use BindOrReject). Otherwise, if v is
+// Validatable, the errors added by its Validate method are returned. Any
+// other error means v is not a pointer to a struct.
+func Bind(r *http.Request, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("hyperdrive: Bind requires a pointer to a struct")
+	}
+	var (
+		verr        = &ValidationError{}
+		path        = mux.Vars(r)
+		query       = r.URL.Query()
+		form        url.Values
+		body        map[string]interface{}
+		codecBody   = r.Method != "GET" && hasCodec(r.Header.Get("Content-Type"))
+		jsonBody    = r.Method != "GET" && isJSONContentType(r.Header.Get("Content-Type"))
+		xmlBody     = r.Method != "GET" && !codecBody && isXMLContentType(r.Header.Get("Content-Type"))
+		msgPackBody = r.Method != "GET" && isMsgPackContentType(r.Header.Get("Content-Type"))
+		yamlBody    = r.Method != "GET" && isYAMLContentType(r.Header.Get("Content-Type"))
+		bodyError   error
+	)
+	if codecBody {
+		body, bodyError = BodyCodec(r)
+		if bodyError != nil {
+			verr.AddBodyError("", ValidationInvalid, "The request body could not be decoded as "+r.Header.Get("Content-Type")+".")
+		}
+	} else if jsonBody {
+		body, bodyError = BodyJSON(r)
+		if bodyError != nil {
+			verr.AddBodyError("", ValidationInvalid, "The request body must be a JSON object.")
+		}
+	} else if xmlBody {
+		body, bodyError = BodyXML(r)
+		if bodyError != nil {
+			verr.AddBodyError("", ValidationInvalid, "The request body must be a well-formed XML document.")
+		}
+	} else if msgPackBody {
+		body, bodyError = BodyMsgPack(r)
+		if bodyError != nil {
+			verr.AddBodyError("", ValidationInvalid, "The request body must be a MessagePack map.")
+		}
+	} else if yamlBody {
+		body, bodyError = BodyYAML(r)
+		if bodyError != nil {
+			verr.AddBodyError("", ValidationInvalid, "The request body must be a YAML mapping.")
+		}
+	} else {
+		form = BodyParams(r)
+	}
+	bindFrom := func(f bindField, source string) bool {
+		switch source {
+		case SourcePath:
+			if s, ok := path[f.key]; ok {
+				bindStrings(verr, f, InPath, []string{s})
+				return true
+			}
+		case SourceBody:
+			if raw, ok := body[f.key]; ok && raw != nil {
+				if values, ok := xmlStrings(raw); ok && xmlBody {
+					bindStrings(verr, f, InBody, values)
+				} else {
+					bindJSON(verr, f, raw)
+				}
+				return true
+			}
+			if values, ok := form[f.key]; ok {
+				bindStrings(verr, f, InBody, values)
+				return true
+			}
+		case SourceQuery:
+			if values, ok := query[f.key]; ok {
+				bindStrings(verr, f, InQuery, values)
+				return true
+			}
+		}
+		return false
+	}
+	precedence := paramPrecedence(nil)
+	for _, f := range bindFields(rv.Elem(), r.Method) {
+		bound := false
+		for _, source := range precedence {
+			if (f.source == "" || f.source == source) && bindFrom(f, source) {
+				bound = true
+				break
+			}
+		}
+		if bound {
+			continue
+		}
+		if f.def != "" {
+			bindStrings(verr, f, bindLocation(f.source, r.Method), []string{f.def})
+			continue
+		}
+		if f.required {
+			addBindError(verr, f, bindLocation(f.source, r.Method), ValidationRequired, fmt.Sprintf("Missing required parameter: %s", f.key))
+		}
+	}
+	if val, ok := v.(Validatable); ok && verr.Empty() {
+		val.Validate(verr)
+	}
+	if !verr.Empty() {
+		return verr
+	}
+	return nil
+}
+
+// BindOrReject calls Bind, and if the request is invalid, responds with a 400
+// Bad Request listing every FieldError (see WriteValidationError), or with a
+// 500 Internal Server Error if v can not be bound to. It returns true if the
+// handler should continue, e.g.
+//
+//	var params UpdateUser
+//	if !hyperdrive.BindOrReject(rw, r, &params) {
+//		return
+//	}
+func BindOrReject(rw http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := Bind(r, v); err != nil {
+		WriteError(rw, err)
+		return false
+	}
+	return true
+}
+
+// bindFields returns the fields of the struct with a param tag.
+func bindFields(v reflect.Value, method string) []bindField {
+	var fields []bindField
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if _, ok := field.Tag.Lookup(tagName); !ok || field.PkgPath != "" {
+			continue
+		}
+		parsed := parseField(field)
+		fields = append(fields, bindField{
+			value:    v.Field(i),
+			key:      parsed.Key,
+			source:   field.Tag.Get("source"),
+			required: parsed.IsRequired(method),
+			def:      parsed.Default,
+		})
+	}
+	return fields
+}
+
+// bindLocation returns where a missing value was expected, for its
+// FieldError: as GetParams, in the query for GET requests, and otherwise in
+// the body, unless the field has a source.
+func bindLocation(source string, method string) string {
+	switch {
+	case source == SourcePath:
+		return InPath
+	case source == SourceQuery || (source == "" && method == "GET"):
+		return InQuery
+	}
+	return InBody
+}
+
+func addBindError(verr *ValidationError, f bindField, in string, code string, message string) {
+	if in == InBody {
+		verr.AddBodyError(JSONPointer(f.key), code, message)
+		return
+	}
+	verr.AddParamError(in, f.key, code, message)
+}
+
+// bindJSON decodes a value of a JSON body into the field. Strings are
+// converted as params are, so types JSON has no literal for (e.g.
+// time.Duration) can be bound from their text, e.g. "1m". Slices are left to
+// encoding/json, which decodes []byte from base64.
+func bindJSON(verr *ValidationError, f bindField, raw interface{}) {
+	if s, ok := raw.(string); ok && f.value.Kind() != reflect.String && f.value.Kind() != reflect.Slice {
+		target := reflect.New(f.value.Type()).Elem()
+		if err := convertString(target, s); err != nil {
+			verr.AddTypeError(InBody, f.key, describeType(f.value.Type()), raw)
+			return
+		}
+		f.value.Set(target)
+		return
+	}
+	b, _ := json.Marshal(raw)
+	target := reflect.New(f.value.Type())
+	if err := json.Unmarshal(b, target.Interface()); err != nil {
+		verr.AddTypeError(InBody, f.key, describeType(f.value.Type()), raw)
+		return
+	}
+	f.value.Set(target.Elem())
+}
+
+// xmlStrings returns the value of an XML body as strings, and false if it is
+// a nested element.
+func xmlStrings(raw interface{}) ([]string, bool) {
+	switch v := raw.(type) {
+	case string:
+		return []string{v}, true
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, false
+			}
+			values[i] = s
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+// bindStrings converts the values to the type of the field.
+func bindStrings(verr *ValidationError, f bindField, in string, values []string) {
+	t := f.value.Type()
+	target := reflect.New(t).Elem()
+	var (
+		err   error
+		value string
+	)
+	if len(values) > 0 {
+		value = values[len(values)-1]
+	}
+	if t.Kind() == reflect.Slice && !reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		target = reflect.MakeSlice(t, len(values), len(values))
+		for i, s := range values {
+			if err = convertString(target.Index(i), s); err != nil {
+				value = s
+				break
+			}
+		}
+	} else {
+		err = convertString(target, value)
+	}
+	if err != nil {
+		verr.AddTypeError(in, f.key, describeType(t), value)
+		return
+	}
+	f.value.Set(target)
+}
+
+// convertString sets v to the value of s, converted to the type of v.
+func convertString(v reflect.Value, s string) error {
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		v.SetInt(int64(d))
+		return err
+	case v.Type() == timeType:
+		t, err := time.Parse(time.RFC3339, s)
+		v.Set(reflect.ValueOf(t))
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := convertString(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// describeType describes the values a field accepts, for the message of its
+// FieldError, e.g. "an integer".
+func describeType(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "a duration, e.g. 1m30s"
+	case t == timeType:
+		return "an RFC 3339 timestamp"
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return describeType(t.Elem())
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "a list, where each item is " + describeType(t.Elem())
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	}
+	return "a string"
+}
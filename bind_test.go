@@ -0,0 +1,128 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+type bindTarget struct {
+	ID      int               `param:"id" source:"path"`
+	Notify  bool              `param:"notify" source:"query"`
+	Name    string            `param:"name;r=PUT"`
+	Tags    []string          `param:"tags"`
+	Limit   *int              `param:"limit"`
+	Timeout time.Duration     `param:"timeout"`
+	PerPage int               `param:"per_page;d=25"`
+	Address map[string]string `param:"address" source:"body"`
+	Ignored string
+}
+
+// bindRequest routes the request to a handler which binds it to a bindTarget.
+func (suite *HyperdriveTestSuite) bindRequest(r *http.Request) (bindTarget, error) {
+	var (
+		target bindTarget
+		err    error
+	)
+	suite.TestAPI.Router.Handle("/users/{id}", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		err = Bind(r, &target)
+	}))
+	suite.TestAPI.Router.ServeHTTP(httptest.NewRecorder(), r)
+	return target, err
+}
+
+func (suite *HyperdriveTestSuite) TestBindJSON() {
+	r := httptest.NewRequest("PUT", "/users/42?notify=true&limit=10", strings.NewReader(`{"name":"test","tags":["a","b"],"timeout":"1m","address":{"city":"Austin"}}`))
+	r.Header.Set("Content-Type", "application/json")
+	target, err := suite.bindRequest(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal(42, target.ID, "expects the path param to be converted")
+	suite.True(target.Notify, "expects the query param to be converted")
+	suite.Equal("test", target.Name, "expects the body field to be decoded")
+	suite.Equal([]string{"a", "b"}, target.Tags, "expects lists to be decoded")
+	suite.Equal(10, *target.Limit, "expects pointers to be set")
+	suite.Equal(map[string]string{"city": "Austin"}, target.Address, "expects nested objects to be decoded")
+}
+
+func (suite *HyperdriveTestSuite) TestBindForm() {
+	r := httptest.NewRequest("POST", "/users/42", strings.NewReader("name=test&tags=a&tags=b&timeout=30s"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	target, err := suite.bindRequest(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal("test", target.Name, "expects the form value to be decoded")
+	suite.Equal([]string{"a", "b"}, target.Tags, "expects every value to be decoded")
+	suite.Equal(30*time.Second, target.Timeout, "expects durations to be converted")
+	suite.Nil(target.Limit, "expects missing params to be left unset")
+	suite.Equal(25, target.PerPage, "expects missing params with a default to be set to it")
+}
+
+func (suite *HyperdriveTestSuite) TestBindXML() {
+	r := httptest.NewRequest("PUT", "/users/42", strings.NewReader(`<user limit="10"><name>test</name><tags>a</tags><tags>b</tags><timeout>1m</timeout><address><city>Austin</city></address></user>`))
+	r.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	target, err := suite.bindRequest(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal("test", target.Name, "expects the element to be decoded")
+	suite.Equal([]string{"a", "b"}, target.Tags, "expects repeated elements to be decoded")
+	suite.Equal(10, *target.Limit, "expects attributes to be converted")
+	suite.Equal(time.Minute, target.Timeout, "expects durations to be converted")
+	suite.Equal(map[string]string{"city": "Austin"}, target.Address, "expects nested elements to be decoded")
+}
+
+func (suite *HyperdriveTestSuite) TestBindXMLMalformed() {
+	r := httptest.NewRequest("POST", "/users/42", strings.NewReader(`<user><name>test</user>`))
+	r.Header.Set("Content-Type", "application/xml")
+	_, err := suite.bindRequest(r)
+	verr, ok := err.(*ValidationError)
+	suite.Require().True(ok, "expects a *ValidationError")
+	suite.Equal("The request body must be a well-formed XML document.", verr.Errors[0].Message, "expects the malformed body to be reported")
+}
+
+func (suite *HyperdriveTestSuite) TestBindValidationError() {
+	r := httptest.NewRequest("PUT", "/users/42?notify=maybe", strings.NewReader(`{"tags":"a"}`))
+	r.Header.Set("Content-Type", "application/json")
+	_, err := suite.bindRequest(r)
+	suite.IsType(&ValidationError{}, err, "expects a ValidationError")
+	suite.Equal([]FieldError{
+		{In: InQuery, Parameter: "notify", Code: ValidationInvalidType, Message: "notify must be a boolean", Expected: "a boolean", Value: "maybe"},
+		{In: InBody, Pointer: "/name", Code: ValidationRequired, Message: "Missing required parameter: name"},
+		{In: InBody, Pointer: "/tags", Code: ValidationInvalidType, Message: "tags must be a list, where each item is a string", Expected: "a list, where each item is a string", Value: "a"},
+	}, err.(*ValidationError).Errors, "expects every invalid param to be reported")
+}
+
+func (suite *HyperdriveTestSuite) TestBindNotStruct() {
+	var id int
+	err := Bind(httptest.NewRequest("GET", "/", nil), &id)
+	_, ok := err.(*ValidationError)
+	suite.Error(err, "expects an error")
+	suite.False(ok, "expects an error which is not a ValidationError")
+}
+
+type validatableTarget struct {
+	Limit int `param:"limit" source:"query"`
+}
+
+func (t *validatableTarget) Validate(verr *ValidationError) {
+	if t.Limit < 1 || t.Limit > 100 {
+		verr.AddParamError(InQuery, "limit", ValidationInvalid, "limit must be between 1 and 100")
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestBindValidatable() {
+	var target validatableTarget
+	suite.Nil(Bind(httptest.NewRequest("GET", "/test?limit=10", nil), &target), "expects valid values to pass")
+	err := Bind(httptest.NewRequest("GET", "/test?limit=1000", nil), &target)
+	suite.IsType(&ValidationError{}, err, "expects a ValidationError")
+	suite.Equal([]FieldError{{In: InQuery, Parameter: "limit", Code: ValidationInvalid, Message: "limit must be between 1 and 100"}}, err.(*ValidationError).Errors, "expects the errors added by Validate")
+	err = Bind(httptest.NewRequest("GET", "/test?limit=many", nil), &target)
+	suite.Len(err.(*ValidationError).Errors, 1, "expects Validate not to be called when values can not be converted")
+}
+
+func (suite *HyperdriveTestSuite) TestBindOrReject() {
+	var target validatableTarget
+	rw := httptest.NewRecorder()
+	suite.False(BindOrReject(rw, httptest.NewRequest("GET", "/test?limit=0", nil), &target), "expects the handler to stop")
+	suite.Equal(http.StatusBadRequest, rw.Code, "expects a 400 Bad Request")
+	suite.Contains(rw.Body.String(), `"parameter":"limit"`, "expects the field errors")
+	suite.True(BindOrReject(httptest.NewRecorder(), httptest.NewRequest("GET", "/test?limit=5", nil), &target), "expects the handler to continue")
+}
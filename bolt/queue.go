@@ -0,0 +1,286 @@
+// Package bolt provides a persistent hyperdrive.QueueAdapter, backed by an
+// embedded bbolt database file, so queued messages survive restarts of
+// single node deployments, without running a message broker.
+//
+//	q, err := bolt.Open("/var/lib/widgets/queue.db", "emails")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer q.Close()
+//	api.AddConsumer("emails", q, handler)
+//
+//	// in a handler
+//	q.Publish(body, nil)
+package bolt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/hyperdriven/hyperdrive"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// ErrNotFound is returned when a message being acked or nacked is no longer
+// in the queue, or no longer leased by the receive it came from, e.g.
+// because its visibility timeout expired, and it was received again.
+var ErrNotFound = errors.New("message not found in queue")
+
+// record is a message, as it is stored in the database. Lease is the token
+// of the receive it is hidden for, if any.
+type record struct {
+	Body       []byte            `json:"body"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Attempts   int               `json:"attempts"`
+	VisibleAt  time.Time         `json:"visible_at"`
+	Lease      string            `json:"lease,omitempty"`
+}
+
+// lease is the Raw value of a received message: its key, and the token of
+// the lease, so only the receive it was leased to can ack or nack it.
+type lease struct {
+	key   []byte
+	token string
+}
+
+// Queue is an implementation of hyperdrive.QueueAdapter, which stores
+// messages in a bucket of a bbolt database, in the order they were
+// published. Received messages stay in the queue, hidden for
+// VisibilityTimeout, and are delivered again if they are not acked by then
+// (e.g. because the process crashed). Nacked messages are visible again
+// immediately. Receive returns up to MaxMessages at a time, checking for
+// messages every PollInterval while the queue is empty.
+//
+// Every receive scans the queue for visible messages, so Queue is intended for
+// modest volumes (thousands of pending messages, not millions).
+type Queue struct {
+	DB                *bbolt.DB
+	Name              string
+	MaxMessages       int
+	VisibilityTimeout time.Duration
+	PollInterval      time.Duration
+	published         chan struct{}
+	owned             bool
+}
+
+// NewQueue creates an instance of Queue, storing messages in the bucket with
+// the given name, which is created if it does not exist. Several queues can
+// share a database.
+func NewQueue(db *bbolt.DB, name string) (*Queue, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(name))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{
+		DB:                db,
+		Name:              name,
+		MaxMessages:       10,
+		VisibilityTimeout: 5 * time.Minute,
+		PollInterval:      time.Second,
+		published:         make(chan struct{}, 1),
+	}, nil
+}
+
+// Open opens (or creates) the database file at path, and creates an instance
+// of Queue using it. Close closes the database.
+func Open(path string, name string) (*Queue, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	q, err := NewQueue(db, name)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	q.owned = true
+	return q, nil
+}
+
+// Close closes the database, if it was opened by Open.
+func (q *Queue) Close() error {
+	if q.owned {
+		return q.DB.Close()
+	}
+	return nil
+}
+
+// Publish adds a message to the end of the queue, returning its ID.
+func (q *Queue) Publish(body []byte, attributes map[string]string) (string, error) {
+	b, err := json.Marshal(record{Body: body, Attributes: attributes, VisibleAt: time.Now()})
+	if err != nil {
+		return "", err
+	}
+	var seq uint64
+	err = q.DB.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(q.Name))
+		seq, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key(seq), b)
+	})
+	if err != nil {
+		return "", err
+	}
+	select {
+	case q.published <- struct{}{}:
+	default:
+	}
+	return strconv.FormatUint(seq, 10), nil
+}
+
+// Receive returns the visible messages at the front of the queue, hiding them
+// for VisibilityTimeout, and waits until one is published (or the context is
+// done) if there are none.
+func (q *Queue) Receive(ctx context.Context) ([]*hyperdrive.Message, error) {
+	for {
+		messages, err := q.lease(time.Now())
+		if err != nil || len(messages) > 0 {
+			return messages, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.published:
+		case <-time.After(q.PollInterval):
+		}
+	}
+}
+
+// lease returns up to MaxMessages visible messages, and hides them.
+func (q *Queue) lease(now time.Time) ([]*hyperdrive.Message, error) {
+	var messages []*hyperdrive.Message
+	err := q.DB.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(q.Name))
+		var (
+			keys    [][]byte
+			records []record
+		)
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil && len(keys) < q.MaxMessages; k, v = c.Next() {
+			var rec record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if rec.VisibleAt.After(now) {
+				continue
+			}
+			keys = append(keys, append([]byte(nil), k...))
+			records = append(records, rec)
+		}
+		// Buckets must not be modified while a cursor iterates over them.
+		for i, rec := range records {
+			token, err := leaseToken()
+			if err != nil {
+				return err
+			}
+			rec.Attempts++
+			rec.VisibleAt = now.Add(q.VisibilityTimeout)
+			rec.Lease = token
+			b, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(keys[i], b); err != nil {
+				return err
+			}
+			messages = append(messages, &hyperdrive.Message{
+				ID:         strconv.FormatUint(binary.BigEndian.Uint64(keys[i]), 10),
+				Queue:      q.Name,
+				Body:       rec.Body,
+				Attributes: rec.Attributes,
+				Attempts:   rec.Attempts,
+				Raw:        lease{key: keys[i], token: token},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// leased returns the stored record of the message, and ErrNotFound if it is
+// no longer in the queue, or has been leased again since it was received.
+func leased(bucket *bbolt.Bucket, m *hyperdrive.Message) (lease, record, error) {
+	l := m.Raw.(lease)
+	var rec record
+	v := bucket.Get(l.key)
+	if v == nil {
+		return l, rec, ErrNotFound
+	}
+	if err := json.Unmarshal(v, &rec); err != nil {
+		return l, rec, err
+	}
+	if rec.Lease != l.token {
+		return l, rec, ErrNotFound
+	}
+	return l, rec, nil
+}
+
+// Ack removes the message from the queue.
+func (q *Queue) Ack(ctx context.Context, m *hyperdrive.Message) error {
+	return q.DB.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(q.Name))
+		l, _, err := leased(bucket, m)
+		if err != nil {
+			return err
+		}
+		return bucket.Delete(l.key)
+	})
+}
+
+// Nack makes the message visible again, so it is delivered again.
+func (q *Queue) Nack(ctx context.Context, m *hyperdrive.Message) error {
+	return q.DB.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(q.Name))
+		l, rec, err := leased(bucket, m)
+		if err != nil {
+			return err
+		}
+		rec.VisibleAt = time.Now()
+		rec.Lease = ""
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(l.key, b)
+	})
+}
+
+// Len returns the number of messages in the queue, including those which
+// have been received, but not yet acked.
+func (q *Queue) Len() (int, error) {
+	var n int
+	err := q.DB.View(func(tx *bbolt.Tx) error {
+		n = tx.Bucket([]byte(q.Name)).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+// leaseToken returns a random token for a lease.
+func leaseToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func key(seq uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, seq)
+	return k
+}
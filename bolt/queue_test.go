@@ -0,0 +1,133 @@
+package bolt
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperdriven/hyperdrive"
+	"github.com/stretchr/testify/suite"
+)
+
+type BoltTestSuite struct {
+	suite.Suite
+	Dir   string
+	Queue *Queue
+}
+
+func (suite *BoltTestSuite) SetupTest() {
+	suite.Dir, _ = ioutil.TempDir("", "bolt")
+	q, err := Open(filepath.Join(suite.Dir, "queue.db"), "emails")
+	suite.Require().Nil(err, "expects no error")
+	q.PollInterval = 10 * time.Millisecond
+	suite.Queue = q
+}
+
+func (suite *BoltTestSuite) TearDownTest() {
+	suite.Queue.Close()
+	os.RemoveAll(suite.Dir)
+}
+
+func (suite *BoltTestSuite) TestQueueAdapter() {
+	suite.Implements((*hyperdrive.QueueAdapter)(nil), suite.Queue, "expects an implementation of hyperdrive.QueueAdapter")
+}
+
+func (suite *BoltTestSuite) TestPublishReceiveAck() {
+	id, err := suite.Queue.Publish([]byte("hello"), map[string]string{"to": "ada@example.com"})
+	suite.Nil(err, "expects no error")
+	suite.Queue.Publish([]byte("world"), nil)
+	messages, err := suite.Queue.Receive(context.Background())
+	suite.Nil(err, "expects no error")
+	suite.Equal(2, len(messages), "expects every visible message")
+	suite.Equal(id, messages[0].ID, "expects messages in the order they were published")
+	suite.Equal("hello", string(messages[0].Body), "expects the body of the message")
+	suite.Equal("ada@example.com", messages[0].Attributes["to"], "expects the attributes of the message")
+	suite.Equal(1, messages[0].Attempts, "expects the first attempt")
+	suite.Nil(suite.Queue.Ack(context.Background(), messages[0]), "expects no error")
+	n, _ := suite.Queue.Len()
+	suite.Equal(1, n, "expects acked messages to be removed")
+	suite.Equal(ErrNotFound, suite.Queue.Ack(context.Background(), messages[0]), "expects an error when the message is no longer queued")
+}
+
+func (suite *BoltTestSuite) TestReceiveHidesMessages() {
+	suite.Queue.Publish([]byte("hello"), nil)
+	suite.Queue.Receive(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err := suite.Queue.Receive(ctx)
+	suite.Equal(context.DeadlineExceeded, err, "expects received messages to be hidden")
+}
+
+func (suite *BoltTestSuite) TestVisibilityTimeout() {
+	suite.Queue.VisibilityTimeout = 20 * time.Millisecond
+	suite.Queue.Publish([]byte("hello"), nil)
+	suite.Queue.Receive(context.Background())
+	messages, err := suite.Queue.Receive(context.Background())
+	suite.Nil(err, "expects no error")
+	suite.Equal(2, messages[0].Attempts, "expects unacked messages to be delivered again after the visibility timeout")
+}
+
+func (suite *BoltTestSuite) TestAckExpiredLease() {
+	suite.Queue.VisibilityTimeout = 20 * time.Millisecond
+	suite.Queue.Publish([]byte("hello"), nil)
+	first, _ := suite.Queue.Receive(context.Background())
+	second, _ := suite.Queue.Receive(context.Background())
+	suite.Equal(ErrNotFound, suite.Queue.Ack(context.Background(), first[0]), "expects an error when the message has been received again")
+	suite.Equal(ErrNotFound, suite.Queue.Nack(context.Background(), first[0]), "expects an error when the message has been received again")
+	suite.Nil(suite.Queue.Ack(context.Background(), second[0]), "expects the latest receive to ack the message")
+	n, _ := suite.Queue.Len()
+	suite.Equal(0, n, "expects acked messages to be removed")
+}
+
+func (suite *BoltTestSuite) TestReceiveMany() {
+	suite.Queue.MaxMessages = 100
+	for i := 0; i < 50; i++ {
+		suite.Queue.Publish([]byte("hello"), nil)
+	}
+	messages, err := suite.Queue.Receive(context.Background())
+	suite.Nil(err, "expects no error")
+	suite.Equal(50, len(messages), "expects every message to be leased once")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err = suite.Queue.Receive(ctx)
+	suite.Equal(context.DeadlineExceeded, err, "expects every received message to be hidden")
+}
+
+func (suite *BoltTestSuite) TestNack() {
+	suite.Queue.Publish([]byte("hello"), nil)
+	messages, _ := suite.Queue.Receive(context.Background())
+	suite.Nil(suite.Queue.Nack(context.Background(), messages[0]), "expects no error")
+	messages, _ = suite.Queue.Receive(context.Background())
+	suite.Equal(1, len(messages), "expects nacked messages to be visible again")
+	suite.Equal(2, messages[0].Attempts, "expects the second attempt")
+}
+
+func (suite *BoltTestSuite) TestReceiveWaitsForPublish() {
+	suite.Queue.PollInterval = time.Hour
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		suite.Queue.Publish([]byte("hello"), nil)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	messages, err := suite.Queue.Receive(ctx)
+	suite.Nil(err, "expects no error")
+	suite.Equal(1, len(messages), "expects published messages to be received without waiting to poll")
+}
+
+func (suite *BoltTestSuite) TestPersistence() {
+	suite.Queue.Publish([]byte("hello"), nil)
+	suite.Queue.Close()
+	q, err := Open(filepath.Join(suite.Dir, "queue.db"), "emails")
+	suite.Require().Nil(err, "expects no error")
+	suite.Queue = q
+	messages, _ := q.Receive(context.Background())
+	suite.Equal("hello", string(messages[0].Body), "expects messages to survive reopening the database")
+}
+
+func TestBoltTestSuite(t *testing.T) {
+	suite.Run(t, new(BoltTestSuite))
+}
@@ -0,0 +1,140 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// CacheStatusHeader is set on every response served by CacheMiddleware, to
+// HIT when it was served from the cache, and MISS otherwise.
+const CacheStatusHeader = "X-Cache"
+
+// CacheStore interface is satisfied by anything that can store the responses
+// cached by CacheMiddleware. Get returns nil, without an error, when there is
+// no response stored for the key. Implementations must be safe for
+// concurrent use. MemoryIdempotencyStore, and the IdempotencyStore of the
+// hyperdrive/redis package, satisfy it.
+type CacheStore interface {
+	Get(key string) (*StoredResponse, error)
+	Set(key string, resp *StoredResponse, ttl time.Duration) error
+}
+
+// CacheOptions configures which responses CacheMiddleware caches, and who
+// they are shared with. TTL overrides CACHE_TTL (default: 1m).
+//
+// Responses to authenticated requests (with a Principal, or credentials no
+// auth middleware has checked yet, e.g. when CacheMiddleware is outside of
+// it: an Authorization header, API key, signature, or cookie) are
+// personalized, so they are only cached when VaryByPrincipal is set, and each
+// principal gets their own copy, or when AllowAuthenticated is set, and one
+// copy is shared by every client, e.g. for endpoints which require a key, but
+// respond the same to everyone. Vary returns any other value the response
+// depends on (e.g. the tenant), to add to the key.
+//
+// Responses which set a cookie are never cached, unless AllowSetCookie is
+// set, since the cookie would be replayed to other clients.
+type CacheOptions struct {
+	TTL                time.Duration
+	VaryByPrincipal    bool
+	Vary               func(r *http.Request) string
+	AllowAuthenticated bool
+	AllowSetCookie     bool
+}
+
+// key returns the key the response to the request is cached under, and false
+// if it must not be cached.
+func (o CacheOptions) key(r *http.Request) (string, bool) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return "", false
+	}
+	key := r.Method + " " + r.URL.RequestURI() + " accept=" + r.Header.Get("Accept")
+	p, authenticated := CurrentPrincipal(r)
+	switch {
+	case o.VaryByPrincipal && authenticated:
+		key += " principal=" + p.Method + ":" + p.ID
+	case o.VaryByPrincipal && hasCredentials(r):
+		// The request carries credentials no auth middleware has checked, so
+		// the principal the response is personalized for is unknown.
+		return "", false
+	case (authenticated || hasCredentials(r)) && !o.AllowAuthenticated:
+		return "", false
+	}
+	if o.Vary != nil {
+		key += " vary=" + o.Vary(r)
+	}
+	return key, true
+}
+
+// cacheable returns true if the recorded response may be stored.
+func (o CacheOptions) cacheable(status int, h http.Header) bool {
+	if status != http.StatusOK {
+		return false
+	}
+	if h.Get("Set-Cookie") != "" && !o.AllowSetCookie {
+		return false
+	}
+	if strings.Contains(h.Get("Vary"), "*") {
+		return false
+	}
+	cc := strings.ToLower(h.Get("Cache-Control"))
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "no-cache")
+}
+
+// CacheMiddleware returns a Middleware which caches successful (200 OK)
+// responses to GET and HEAD requests in the given CacheStore, and serves them
+// for identical requests (by method, URI, and Accept header) until they
+// expire, with the X-Cache header set to HIT. Responses marked no-store or
+// no-cache by the handler are not cached. Only the headers set by the handler
+// (and middleware inside of CacheMiddleware) are cached, so those of each
+// request, e.g. X-Request-Id, are not replayed. See CacheOptions for how
+// responses to authenticated requests are cached safely, e.g.
+//
+//	api.CacheMiddleware(hyperdrive.NewMemoryIdempotencyStore(), hyperdrive.CacheOptions{
+//		VaryByPrincipal: true,
+//		Vary:            func(r *http.Request) string { return r.Header.Get("X-Tenant") },
+//	})
+func (api *API) CacheMiddleware(store CacheStore, o CacheOptions) Middleware {
+	if o.TTL == 0 {
+		o.TTL = conf.CacheTTL
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			key, ok := o.key(r)
+			if !ok {
+				h.ServeHTTP(rw, r)
+				return
+			}
+			if resp, err := store.Get(key); err == nil && resp != nil {
+				for k, v := range resp.Header {
+					rw.Header()[k] = v
+				}
+				rw.Header().Set(CacheStatusHeader, "HIT")
+				rw.WriteHeader(resp.Status)
+				rw.Write(resp.Body)
+				return
+			}
+			rw.Header().Set(CacheStatusHeader, "MISS")
+			before := cloneHeader(rw.Header())
+			rec := &recordingWriter{ResponseWriter: rw}
+			h.ServeHTTP(rec, r)
+			if status := rec.statusCode(); o.cacheable(status, rw.Header()) {
+				store.Set(key, &StoredResponse{Status: status, Header: handlerHeader(before, rw.Header()), Body: rec.body.Bytes()}, o.TTL)
+			}
+		})
+	}
+}
+
+// handlerHeader returns the headers which were set or changed after the
+// before copy was taken, except the ID of the request.
+func handlerHeader(before, after http.Header) http.Header {
+	h := make(http.Header)
+	for k, v := range after {
+		if k == RequestIDHeader || reflect.DeepEqual(before[k], v) {
+			continue
+		}
+		h[k] = append([]string(nil), v...)
+	}
+	return h
+}
@@ -0,0 +1,101 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// countingHandler responds with the ID of the request's principal, and counts
+// how many times it is called.
+func countingHandler(calls *int) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		*calls++
+		if p, ok := CurrentPrincipal(r); ok {
+			rw.Write([]byte(p.ID))
+		}
+	})
+}
+
+func (suite *HyperdriveTestSuite) TestCacheMiddleware() {
+	var calls int
+	h := suite.TestAPI.CacheMiddleware(NewMemoryIdempotencyStore(), CacheOptions{})(countingHandler(&calls))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/test", nil))
+	suite.Equal("MISS", rw.Header().Get(CacheStatusHeader), "expects the first request to miss")
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/test", nil))
+	suite.Equal("HIT", rw.Header().Get(CacheStatusHeader), "expects the second request to hit")
+	suite.Equal(1, calls, "expects the handler to be called once")
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/test", nil))
+	suite.Equal(2, calls, "expects POST requests not to be cached")
+}
+
+func (suite *HyperdriveTestSuite) TestCacheMiddlewareVaryByPrincipal() {
+	var calls int
+	h := suite.TestAPI.CacheMiddleware(NewMemoryIdempotencyStore(), CacheOptions{VaryByPrincipal: true})(countingHandler(&calls))
+	for _, id := range []string{"alice", "bob", "alice"} {
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, WithPrincipal(httptest.NewRequest("GET", "/me", nil), &Principal{ID: id, Method: AuthMethodAPIKey}))
+		suite.Equal(id, rw.Body.String(), "expects each principal to get their own response")
+	}
+	suite.Equal(2, calls, "expects one response to be cached per principal")
+	r := httptest.NewRequest("GET", "/me", nil)
+	r.Header.Set("Authorization", "Bearer abc")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	suite.Equal(4, calls, "expects unauthenticated credentials not to be cached")
+}
+
+func (suite *HyperdriveTestSuite) TestCacheMiddlewarePrivacyGuards() {
+	var calls int
+	h := suite.TestAPI.CacheMiddleware(NewMemoryIdempotencyStore(), CacheOptions{})(countingHandler(&calls))
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), WithPrincipal(httptest.NewRequest("GET", "/me", nil), &Principal{ID: "alice"}))
+	}
+	suite.Equal(2, calls, "expects authenticated requests not to be cached by default")
+	calls = 0
+	h = suite.TestAPI.CacheMiddleware(NewMemoryIdempotencyStore(), CacheOptions{})(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		http.SetCookie(rw, &http.Cookie{Name: "session", Value: "abc"})
+	}))
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+	}
+	suite.Equal(2, calls, "expects responses which set a cookie not to be cached")
+}
+
+func (suite *HyperdriveTestSuite) TestCacheMiddlewareUncheckedCredentials() {
+	var calls int
+	h := suite.TestAPI.CacheMiddleware(NewMemoryIdempotencyStore(), CacheOptions{})(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.Write([]byte(r.Header.Get("X-API-Key")))
+	}))
+	r := httptest.NewRequest("GET", "/me", nil)
+	r.Header.Set("X-API-Key", "secret")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/me", nil))
+	suite.Equal(2, calls, "expects requests with an API key not to be cached")
+	suite.Equal("", rw.Body.String(), "expects anonymous requests not to be served the response to an API key")
+
+	for _, header := range []string{"Cookie", "X-Signature"} {
+		r := httptest.NewRequest("GET", "/other", nil)
+		r.Header.Set(header, "secret")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	suite.Equal(6, calls, "expects requests with a cookie or signature not to be cached")
+}
+
+func (suite *HyperdriveTestSuite) TestCacheMiddlewareRequestHeaders() {
+	h := suite.TestAPI.CacheMiddleware(NewMemoryIdempotencyStore(), CacheOptions{})(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("X-Widget", "1")
+	}))
+	for _, id := range []string{"first", "second"} {
+		rw := httptest.NewRecorder()
+		rw.Header().Set(RequestIDHeader, id)
+		h.ServeHTTP(rw, httptest.NewRequest("GET", "/test", nil))
+		suite.Equal(id, rw.Header().Get(RequestIDHeader), "expects the ID of each request, not a cached one")
+		suite.Equal("1", rw.Header().Get("X-Widget"), "expects the headers of the handler")
+	}
+}
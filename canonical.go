@@ -0,0 +1,135 @@
+package hyperdrive
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CanonicalURLMiddleware redirects GET and HEAD requests to the canonical URL
+// of the requested resource, with a 301 Moved Permanently, so the same
+// content is only ever cached (or indexed) under one URL. The canonical URL
+// has:
+//
+// - the host set by CANONICAL_HOST (string), if it is set
+// - a lowercase path, if CANONICAL_LOWERCASE (bool) is true
+// - the query params sorted by name, without any params missing from
+// CANONICAL_QUERY_PARAMS (string, comma separated), if it is set
+//
+// The scheme of a redirect to the canonical host is taken from the
+// X-Forwarded-Proto header only when the request comes from one of
+// TRUSTED_PROXIES (string, comma separated IPs or CIDRs), and from the
+// connection otherwise.
+//
+// Other methods are passed through unchanged, since clients may not repeat
+// the request body after a 301.
+func (api *API) CanonicalURLMiddleware(h http.Handler) http.Handler {
+	allowed := splitList(conf.CanonicalQueryParams)
+	proxies, _ := parseTrustedProxies(conf.TrustedProxies)
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		if u, ok := canonicalURL(r, conf.CanonicalHost, conf.CanonicalLowercase, allowed, proxies); !ok {
+			http.Redirect(rw, r, u, http.StatusMovedPermanently)
+			return
+		}
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// canonicalURL returns the canonical URL for the request, and true if the
+// request URL is already canonical. The URL is only absolute when the host
+// has to change.
+func canonicalURL(r *http.Request, host string, lowercase bool, allowed []string, proxies []*net.IPNet) (string, bool) {
+	canonical := true
+	u := url.URL{Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+	if host != "" && !strings.EqualFold(r.Host, host) {
+		canonical = false
+		u.Scheme, u.Host = requestScheme(r, proxies), host
+	}
+	if lowercase && strings.ToLower(u.Path) != u.Path {
+		canonical = false
+		u.Path = strings.ToLower(u.Path)
+	}
+	query := r.URL.Query()
+	if len(allowed) > 0 {
+		for k := range query {
+			if !contains(allowed, k) {
+				query.Del(k)
+			}
+		}
+	}
+	if q := query.Encode(); q != r.URL.RawQuery {
+		canonical = false
+		u.RawQuery = q
+	}
+	return u.String(), canonical
+}
+
+// requestScheme returns the scheme the client used, trusting the
+// X-Forwarded-Proto header only if the request comes from one of the proxies.
+func requestScheme(r *http.Request, proxies []*net.IPNet) string {
+	if trustedProxy(r, proxies) {
+		if proto := strings.ToLower(r.Header.Get("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// trustedProxy returns true if the request was made by one of the proxies.
+func trustedProxy(r *http.Request, proxies []*net.IPNet) bool {
+	ip := net.ParseIP(remoteHost(r))
+	if ip == nil {
+		return false
+	}
+	for _, p := range proxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses a comma separated list of IPs and CIDRs, e.g.
+// TRUSTED_PROXIES.
+func parseTrustedProxies(list string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, p := range splitList(list) {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", p)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			p = fmt.Sprintf("%s/%d", p, bits)
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", p, err)
+		}
+		proxies = append(proxies, n)
+	}
+	return proxies, nil
+}
+
+// splitList splits a comma separated config value, ignoring empty entries.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
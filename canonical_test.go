@@ -0,0 +1,64 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+var canonicalTestHandler = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})
+
+func (suite *HyperdriveTestSuite) TestCanonicalURLMiddleware() {
+	defer func(c Config) { conf = c }(conf)
+	conf.CanonicalLowercase = true
+	conf.CanonicalQueryParams = "page,sort"
+	r := httptest.NewRequest("GET", "/Widgets?sort=name&utm_source=x&page=2", nil)
+	rw := httptest.NewRecorder()
+	suite.TestAPI.CanonicalURLMiddleware(canonicalTestHandler).ServeHTTP(rw, r)
+	suite.Equal(http.StatusMovedPermanently, rw.Code, "expects a 301 to the canonical URL")
+	suite.Equal("/widgets?page=2&sort=name", rw.Header().Get("Location"), "expects a lowercase path and sorted, allowed query params")
+}
+
+func (suite *HyperdriveTestSuite) TestCanonicalURLMiddlewareCanonical() {
+	r := httptest.NewRequest("GET", "/widgets?page=2&sort=name", nil)
+	rw := httptest.NewRecorder()
+	suite.TestAPI.CanonicalURLMiddleware(canonicalTestHandler).ServeHTTP(rw, r)
+	suite.Equal(http.StatusOK, rw.Code, "expects canonical URLs to be served")
+}
+
+func (suite *HyperdriveTestSuite) TestCanonicalURLMiddlewareHost() {
+	defer func(c Config) { conf = c }(conf)
+	conf.CanonicalHost = "api.example.com"
+	conf.TrustedProxies = "10.0.0.0/8"
+	r := httptest.NewRequest("GET", "http://www.example.com/widgets", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-Proto", "https")
+	rw := httptest.NewRecorder()
+	suite.TestAPI.CanonicalURLMiddleware(canonicalTestHandler).ServeHTTP(rw, r)
+	suite.Equal("https://api.example.com/widgets", rw.Header().Get("Location"), "expects a redirect to the canonical host, with the scheme forwarded by a trusted proxy")
+}
+
+func (suite *HyperdriveTestSuite) TestCanonicalURLMiddlewareUntrustedProxy() {
+	defer func(c Config) { conf = c }(conf)
+	conf.CanonicalHost = "api.example.com"
+	conf.TrustedProxies = "10.0.0.0/8"
+	r := httptest.NewRequest("GET", "http://www.example.com/widgets", nil)
+	r.RemoteAddr = "203.0.113.1:1234"
+	r.Header.Set("X-Forwarded-Proto", "https")
+	rw := httptest.NewRecorder()
+	suite.TestAPI.CanonicalURLMiddleware(canonicalTestHandler).ServeHTTP(rw, r)
+	suite.Equal("http://api.example.com/widgets", rw.Header().Get("Location"), "expects X-Forwarded-Proto to be ignored when the request does not come from a trusted proxy")
+}
+
+func (suite *HyperdriveTestSuite) TestCanonicalURLMiddlewareLowercase() {
+	r := httptest.NewRequest("GET", "/Widgets", nil)
+	rw := httptest.NewRecorder()
+	suite.TestAPI.CanonicalURLMiddleware(canonicalTestHandler).ServeHTTP(rw, r)
+	suite.Equal(http.StatusOK, rw.Code, "expects the path not to be lowercased by default")
+}
+
+func (suite *HyperdriveTestSuite) TestCanonicalURLMiddlewarePost() {
+	r := httptest.NewRequest("POST", "/Widgets?b=1&a=2", nil)
+	rw := httptest.NewRecorder()
+	suite.TestAPI.CanonicalURLMiddleware(canonicalTestHandler).ServeHTTP(rw, r)
+	suite.Equal(http.StatusOK, rw.Code, "expects non-GET requests not to be redirected")
+}
@@ -0,0 +1,167 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type canonicalJSONContextKey struct{}
+
+// CanonicalJSONer interface is satisfied by endpoints which always respond
+// with canonical JSON (see CanonicalJSON), e.g. because their responses are
+// signed. Their method handlers are wrapped in CanonicalJSONMiddleware by
+// AddEndpoint.
+type CanonicalJSONer interface {
+	CanonicalJSON() bool
+}
+
+// CanonicalJSONEncoder is an implementation of ContentEncoder which writes
+// canonical JSON (see CanonicalJSON), followed by a newline, as JSONEncoder
+// does.
+type CanonicalJSONEncoder struct {
+	Writer io.Writer
+}
+
+// Encode encodes input as canonical json text or returns an error.
+func (enc CanonicalJSONEncoder) Encode(v interface{}) error {
+	b, err := CanonicalJSON(v)
+	if err != nil {
+		return err
+	}
+	_, err = enc.Writer.Write(append(b, '\n'))
+	return err
+}
+
+// CanonicalJSON returns the deterministic JSON encoding of v, so it can be
+// signed, hashed (e.g. for an ETag), or compared byte for byte (e.g. in
+// golden tests): object keys, including struct fields, are sorted, there is
+// no insignificant whitespace, HTML characters are not escaped, integers are
+// written in full, and other numbers in their shortest form, e.g. 1.5e+21
+// becomes 1.5e21, and 1.0 becomes 1.
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeCanonicalJSON(&buf, decoded); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			if err := writeCanonicalJSON(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case string:
+		writeCanonicalString(buf, v)
+	case json.Number:
+		buf.WriteString(canonicalNumber(v))
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case nil:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	buf.Truncate(buf.Len() - 1) // Encode adds a newline.
+}
+
+// canonicalNumber returns the shortest representation of the number, as
+// JavaScript (and RFC 8785) formats it. Integers are kept as they are, so
+// they do not lose precision.
+func canonicalNumber(n json.Number) string {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		return s
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) {
+		return s
+	}
+	if abs := math.Abs(f); abs == 0 || (abs >= 1e-6 && abs < 1e21) {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	mantissa, exp, _ := strings.Cut(strconv.FormatFloat(f, 'e', -1, 64), "e")
+	sign := exp[:1]
+	if sign == "+" {
+		sign = ""
+	}
+	return mantissa + "e" + sign + strings.TrimLeft(exp[1:], "0")
+}
+
+// WithCanonicalJSON returns a shallow copy of r, for which Respond writes
+// canonical JSON.
+func WithCanonicalJSON(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), canonicalJSONContextKey{}, true))
+}
+
+// UsesCanonicalJSON returns true if Respond writes canonical JSON for the
+// request: when JSON_CANONICAL is true, or the request has been marked with
+// WithCanonicalJSON (e.g. by CanonicalJSONMiddleware).
+func UsesCanonicalJSON(r *http.Request) bool {
+	canonical, _ := r.Context().Value(canonicalJSONContextKey{}).(bool)
+	return canonical || conf.JSONCanonical
+}
+
+// CanonicalJSONMiddleware wraps the given http.Handler, so Respond writes
+// canonical JSON (see CanonicalJSON) for every request it serves. Set
+// JSON_CANONICAL to true to write canonical JSON for every endpoint instead.
+func (api *API) CanonicalJSONMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(rw, WithCanonicalJSON(r))
+	})
+}
+
+// requiresCanonicalJSON returns true if the endpoint always responds with
+// canonical JSON.
+func requiresCanonicalJSON(e Endpointer) bool {
+	c, ok := e.(CanonicalJSONer)
+	return ok && c.CanonicalJSON()
+}
@@ -0,0 +1,46 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+type canonicalEndpoint struct {
+	*Endpoint
+}
+
+func (e *canonicalEndpoint) CanonicalJSON() bool {
+	return true
+}
+
+func (e *canonicalEndpoint) Get(rw http.ResponseWriter, r *http.Request) {
+	Respond(rw, r, http.StatusOK, map[string]interface{}{"b": 1.0, "a": "<x>"})
+}
+
+func (suite *HyperdriveTestSuite) TestCanonicalJSON() {
+	b, err := CanonicalJSON(struct {
+		Zebra  string                 `json:"zebra"`
+		Apple  []float64              `json:"apple"`
+		Nested map[string]interface{} `json:"nested"`
+	}{"<&>", []float64{1.0, 0.5, 1e21, 1e-7}, map[string]interface{}{"y": nil, "x": true, "id": int64(9007199254740993)}})
+	suite.Nil(err, "expects no error")
+	suite.Equal(`{"apple":[1,0.5,1e21,1e-7],"nested":{"id":9007199254740993,"x":true,"y":null},"zebra":"<&>"}`, string(b), "expects sorted keys and stable numbers")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondCanonicalJSON() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Accept", "application/json")
+	Respond(rw, WithCanonicalJSON(r), http.StatusOK, map[string]interface{}{"b": 1.5, "a": "<x>"})
+	suite.Equal(`{"a":"<x>","b":1.5}`+"\n", rw.Body.String(), "expects canonical json")
+}
+
+func (suite *HyperdriveTestSuite) TestAddEndpointCanonicalJSON() {
+	e := &canonicalEndpoint{NewEndpoint("Canonical", "Canonical Endpoint", "/canonical", "1")}
+	suite.TestAPI.AddEndpoint(e)
+	r := httptest.NewRequest("GET", "/canonical", nil)
+	r.Header.Set("Accept", GetContentTypeJSON(suite.TestAPI, e))
+	rw := httptest.NewRecorder()
+	suite.TestAPI.Router.ServeHTTP(rw, r)
+	suite.Equal(`{"a":"<x>","b":1}`+"\n", rw.Body.String(), "expects endpoints implementing CanonicalJSONer to respond with canonical json")
+}
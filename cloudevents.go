@@ -0,0 +1,382 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// CloudEventsSpecVersion is the version of the CloudEvents specification
+	// implemented by CloudEvent.
+	CloudEventsSpecVersion = "1.0"
+
+	// CloudEventsContentType is the media type of events encoded in
+	// structured mode.
+	CloudEventsContentType = "application/cloudevents+json"
+
+	cloudEventHeaderPrefix = "Ce-"
+)
+
+// CloudEventMode is the way a CloudEvent is encoded in an HTTP request or
+// response. In binary mode, the data is the body, and the attributes are
+// headers (e.g. Ce-Type). In structured mode, the event is encoded as a
+// single JSON document.
+type CloudEventMode int
+
+const (
+	// CloudEventBinary encodes the data as the body, and the attributes as
+	// headers.
+	CloudEventBinary CloudEventMode = iota
+
+	// CloudEventStructured encodes the event as an
+	// application/cloudevents+json body.
+	CloudEventStructured
+)
+
+// CloudEvent is an event, as described by the CloudEvents specification:
+// https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/spec.md
+// Extension attributes are held as strings, as they are in binary mode.
+type CloudEvent struct {
+	ID              string
+	Source          string
+	SpecVersion     string
+	Type            string
+	DataContentType string
+	DataSchema      string
+	Subject         string
+	Time            time.Time
+	Extensions      map[string]string
+	Data            []byte
+}
+
+// NewCloudEvent creates an instance of CloudEvent, with a new ID (see NewID)
+// and the current time, and the given data encoded as JSON.
+func NewCloudEvent(source string, eventType string, data interface{}) (*CloudEvent, error) {
+	e := &CloudEvent{
+		ID:          NewID(),
+		Source:      source,
+		SpecVersion: CloudEventsSpecVersion,
+		Type:        eventType,
+		Time:        time.Now().UTC(),
+	}
+	if data != nil {
+		d, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		e.DataContentType = "application/json"
+		e.Data = d
+	}
+	return e, nil
+}
+
+// Validate returns an error if a required attribute is missing, or the spec
+// version is not supported.
+func (e *CloudEvent) Validate() error {
+	switch {
+	case e.SpecVersion != CloudEventsSpecVersion:
+		return fmt.Errorf("unsupported CloudEvents specversion: %q", e.SpecVersion)
+	case e.ID == "":
+		return errors.New("CloudEvent is missing the required id attribute")
+	case e.Source == "":
+		return errors.New("CloudEvent is missing the required source attribute")
+	case e.Type == "":
+		return errors.New("CloudEvent is missing the required type attribute")
+	}
+	return nil
+}
+
+// DecodeData decodes the data of a JSON event into v.
+func (e *CloudEvent) DecodeData(v interface{}) error {
+	if !isJSONMediaType(e.DataContentType) {
+		return fmt.Errorf("CloudEvent data is not JSON: %q", e.DataContentType)
+	}
+	return json.Unmarshal(e.Data, v)
+}
+
+// attributes returns the attributes of the event which are set, by their
+// CloudEvents names, excluding data.
+func (e *CloudEvent) attributes() map[string]string {
+	attrs := map[string]string{}
+	for k, v := range e.Extensions {
+		attrs[k] = v
+	}
+	for k, v := range map[string]string{
+		"id":              e.ID,
+		"source":          e.Source,
+		"specversion":     e.SpecVersion,
+		"type":            e.Type,
+		"datacontenttype": e.DataContentType,
+		"dataschema":      e.DataSchema,
+		"subject":         e.Subject,
+	} {
+		if v != "" {
+			attrs[k] = v
+		}
+	}
+	if !e.Time.IsZero() {
+		attrs["time"] = e.Time.Format(time.RFC3339Nano)
+	}
+	return attrs
+}
+
+// setAttribute sets the attribute of the event with the given CloudEvents
+// name.
+func (e *CloudEvent) setAttribute(name string, value string) error {
+	switch name {
+	case "id":
+		e.ID = value
+	case "source":
+		e.Source = value
+	case "specversion":
+		e.SpecVersion = value
+	case "type":
+		e.Type = value
+	case "datacontenttype":
+		e.DataContentType = value
+	case "dataschema":
+		e.DataSchema = value
+	case "subject":
+		e.Subject = value
+	case "time":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("invalid CloudEvent time attribute: %v", err)
+		}
+		e.Time = t
+	default:
+		if e.Extensions == nil {
+			e.Extensions = map[string]string{}
+		}
+		e.Extensions[name] = value
+	}
+	return nil
+}
+
+// MarshalJSON encodes the event in structured mode. JSON data is embedded as
+// is, and any other data is base64 encoded, as data_base64.
+func (e *CloudEvent) MarshalJSON() ([]byte, error) {
+	doc := map[string]interface{}{}
+	for k, v := range e.attributes() {
+		doc[k] = v
+	}
+	switch {
+	case len(e.Data) == 0:
+	case isJSONMediaType(e.DataContentType) && json.Valid(e.Data):
+		doc["data"] = json.RawMessage(e.Data)
+	default:
+		doc["data_base64"] = base64.StdEncoding.EncodeToString(e.Data)
+	}
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON decodes an event encoded in structured mode. Extension
+// attributes which are not strings are kept as their JSON text.
+func (e *CloudEvent) UnmarshalJSON(b []byte) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	*e = CloudEvent{}
+	for k, v := range doc {
+		switch k {
+		case "data":
+			var s string
+			if !isJSONMediaType(structuredDataContentType(doc)) && json.Unmarshal(v, &s) == nil {
+				e.Data = []byte(s)
+			} else {
+				e.Data = []byte(v)
+			}
+		case "data_base64":
+			var s string
+			if err := json.Unmarshal(v, &s); err != nil {
+				return err
+			}
+			d, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return fmt.Errorf("invalid CloudEvent data_base64: %v", err)
+			}
+			e.Data = d
+		default:
+			var s string
+			if json.Unmarshal(v, &s) != nil {
+				s = string(v)
+			}
+			if err := e.setAttribute(k, s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// structuredDataContentType returns the datacontenttype of a structured
+// event, which defaults to JSON.
+func structuredDataContentType(doc map[string]json.RawMessage) string {
+	var ct string
+	if v, ok := doc["datacontenttype"]; ok && json.Unmarshal(v, &ct) == nil {
+		return ct
+	}
+	return "application/json"
+}
+
+// isJSONMediaType returns true for JSON media types, including those with
+// a +json suffix. An empty media type is treated as JSON.
+func isJSONMediaType(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || mt == "text/json" || strings.HasSuffix(mt, "+json")
+}
+
+// ReadCloudEvent decodes the CloudEvent sent in the request body, in either
+// structured mode (Content-Type: application/cloudevents+json) or binary mode
+// (Ce-* headers). Batched events are not supported. An error is returned if
+// the event is invalid.
+func ReadCloudEvent(r *http.Request) (*CloudEvent, error) {
+	body, err := RawBody(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeCloudEvent(r.Header, body)
+}
+
+func decodeCloudEvent(h http.Header, body []byte) (*CloudEvent, error) {
+	e := &CloudEvent{}
+	mt, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if mt == CloudEventsContentType {
+		if err := json.Unmarshal(body, e); err != nil {
+			return nil, fmt.Errorf("invalid structured CloudEvent: %v", err)
+		}
+		return validCloudEvent(e)
+	}
+	for k, values := range h {
+		if !strings.HasPrefix(k, cloudEventHeaderPrefix) || len(values) == 0 {
+			continue
+		}
+		v, err := url.PathUnescape(values[0])
+		if err != nil {
+			v = values[0]
+		}
+		if err := e.setAttribute(strings.ToLower(strings.TrimPrefix(k, cloudEventHeaderPrefix)), v); err != nil {
+			return nil, err
+		}
+	}
+	e.DataContentType = h.Get("Content-Type")
+	e.Data = body
+	return validCloudEvent(e)
+}
+
+func validCloudEvent(e *CloudEvent) (*CloudEvent, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// EncodeCloudEvent sets the headers for the event in h, and returns the body,
+// encoded with the given mode.
+func EncodeCloudEvent(h http.Header, e *CloudEvent, mode CloudEventMode) ([]byte, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	if mode == CloudEventStructured {
+		h.Set("Content-Type", CloudEventsContentType+"; charset=utf-8")
+		return json.Marshal(e)
+	}
+	for k, v := range e.attributes() {
+		if k == "datacontenttype" {
+			h.Set("Content-Type", v)
+			continue
+		}
+		h.Set(cloudEventHeaderPrefix+k, cloudEventHeaderEscape(v))
+	}
+	return e.Data, nil
+}
+
+// cloudEventHeaderEscape percent encodes a binary mode header value, as
+// required by the HTTP protocol binding: spaces, double quotes, percent
+// signs, and anything outside of printable ASCII.
+func cloudEventHeaderEscape(v string) string {
+	var buf strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c <= 0x20 || c >= 0x7f || c == '"' || c == '%' {
+			fmt.Fprintf(&buf, "%%%02X", c)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// NewCloudEventRequest creates a POST request, delivering the event to the
+// given URL, e.g. a Knative broker or webhook subscriber.
+func NewCloudEventRequest(ctx context.Context, target string, e *CloudEvent, mode CloudEventMode) (*http.Request, error) {
+	h := http.Header{}
+	body, err := EncodeCloudEvent(h, e, mode)
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header = h
+	return r.WithContext(ctx), nil
+}
+
+// CloudEventHandler returns an http.Handler which decodes a CloudEvent from
+// each request, and passes it to the given function. It responds with 202
+// ACCEPTED if the function returns nil, 400 BAD REQUEST if the event is
+// invalid, and 500 INTERNAL SERVER ERROR otherwise.
+func CloudEventHandler(f func(context.Context, *CloudEvent) error) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		e, err := ReadCloudEvent(r)
+		if err != nil {
+			http.Error(rw, GetErrorText(http.StatusBadRequest, err), http.StatusBadRequest)
+			return
+		}
+		if err := f(r.Context(), e); err != nil {
+			http.Error(rw, GetErrorText(http.StatusInternalServerError, err), http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// MessageCloudEvent decodes the CloudEvent carried by a consumer Message. If
+// the attributes of the message hold the event attributes, prefixed with
+// "ce-" or "ce_" (as with the Kafka binding) the body is the data (binary
+// mode), otherwise the body must be a structured event.
+func MessageCloudEvent(m *Message) (*CloudEvent, error) {
+	h := http.Header{}
+	binary := false
+	for k, v := range m.Attributes {
+		lk := strings.ToLower(k)
+		switch {
+		case strings.HasPrefix(lk, "ce-"), strings.HasPrefix(lk, "ce_"):
+			h.Set(cloudEventHeaderPrefix+lk[3:], v)
+			binary = true
+		case lk == "content-type", lk == "content_type", lk == "contenttype":
+			h.Set("Content-Type", v)
+		}
+	}
+	if !binary {
+		h.Set("Content-Type", CloudEventsContentType)
+	}
+	return decodeCloudEvent(h, m.Body)
+}
@@ -0,0 +1,141 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+func testCloudEvent() *CloudEvent {
+	return &CloudEvent{
+		ID:              "1",
+		Source:          "/widgets",
+		SpecVersion:     CloudEventsSpecVersion,
+		Type:            "com.example.widget.created",
+		DataContentType: "application/json",
+		Time:            time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC),
+		Extensions:      map[string]string{"tenant": "acme inc"},
+		Data:            []byte(`{"name":"sprocket"}`),
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestNewCloudEvent() {
+	e, err := NewCloudEvent("/widgets", "com.example.widget.created", map[string]string{"name": "sprocket"})
+	suite.Nil(err, "expects no error")
+	suite.Nil(e.Validate(), "expects a valid event")
+	suite.Equal("application/json", e.DataContentType, "expects the data to be encoded as json")
+	var data map[string]string
+	suite.Nil(e.DecodeData(&data), "expects no error")
+	suite.Equal("sprocket", data["name"], "expects the data to be decoded")
+}
+
+func (suite *HyperdriveTestSuite) TestCloudEventValidate() {
+	e := testCloudEvent()
+	e.Source = ""
+	suite.Error(e.Validate(), "expects an error for a missing source")
+	e = testCloudEvent()
+	e.SpecVersion = "0.3"
+	suite.Error(e.Validate(), "expects an error for an unsupported specversion")
+}
+
+func (suite *HyperdriveTestSuite) TestCloudEventBinary() {
+	h := http.Header{}
+	body, err := EncodeCloudEvent(h, testCloudEvent(), CloudEventBinary)
+	suite.Nil(err, "expects no error")
+	suite.Equal("com.example.widget.created", h.Get("Ce-Type"), "expects attributes as headers")
+	suite.Equal("acme%20inc", h.Get("Ce-Tenant"), "expects header values to be percent encoded")
+	suite.Equal("application/json", h.Get("Content-Type"), "expects the datacontenttype as the Content-Type")
+	r := httptest.NewRequest("POST", "/events", bytes.NewReader(body))
+	r.Header = h
+	e, err := ReadCloudEvent(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal(testCloudEvent(), e, "expects the event to be decoded")
+}
+
+func (suite *HyperdriveTestSuite) TestCloudEventStructured() {
+	h := http.Header{}
+	body, err := EncodeCloudEvent(h, testCloudEvent(), CloudEventStructured)
+	suite.Nil(err, "expects no error")
+	suite.True(strings.HasPrefix(h.Get("Content-Type"), CloudEventsContentType), "expects the structured media type")
+	var doc map[string]interface{}
+	json.Unmarshal(body, &doc)
+	suite.Equal(map[string]interface{}{"name": "sprocket"}, doc["data"], "expects json data to be embedded")
+	r := httptest.NewRequest("POST", "/events", bytes.NewReader(body))
+	r.Header = h
+	e, err := ReadCloudEvent(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal(testCloudEvent(), e, "expects the event to be decoded")
+}
+
+func (suite *HyperdriveTestSuite) TestCloudEventStructuredBase64() {
+	e := testCloudEvent()
+	e.DataContentType = "application/octet-stream"
+	e.Data = []byte{0, 1, 2}
+	b, _ := json.Marshal(e)
+	suite.Contains(string(b), `"data_base64":"AAEC"`, "expects binary data to be base64 encoded")
+	decoded := &CloudEvent{}
+	suite.Nil(json.Unmarshal(b, decoded), "expects no error")
+	suite.Equal([]byte{0, 1, 2}, decoded.Data, "expects binary data to be decoded")
+}
+
+func (suite *HyperdriveTestSuite) TestReadCloudEventInvalid() {
+	r := httptest.NewRequest("POST", "/events", strings.NewReader("{}"))
+	r.Header.Set("Content-Type", "application/json")
+	_, err := ReadCloudEvent(r)
+	suite.Error(err, "expects an error when there are no event attributes")
+}
+
+func (suite *HyperdriveTestSuite) TestNewCloudEventRequest() {
+	r, err := NewCloudEventRequest(context.Background(), "http://broker.example.com/", testCloudEvent(), CloudEventBinary)
+	suite.Nil(err, "expects no error")
+	suite.Equal("POST", r.Method, "expects a POST request")
+	suite.Equal("1", r.Header.Get("Ce-Id"), "expects the event attributes as headers")
+	body, _ := ioutil.ReadAll(r.Body)
+	suite.Equal(`{"name":"sprocket"}`, string(body), "expects the data as the body")
+}
+
+func (suite *HyperdriveTestSuite) TestCloudEventHandler() {
+	var received *CloudEvent
+	h := CloudEventHandler(func(ctx context.Context, e *CloudEvent) error {
+		received = e
+		return nil
+	})
+	r, _ := NewCloudEventRequest(context.Background(), "/events", testCloudEvent(), CloudEventStructured)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusAccepted, rw.Code, "expects a 202 response")
+	suite.Equal("1", received.ID, "expects the event to be handled")
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("POST", "/events", strings.NewReader("{}")))
+	suite.Equal(http.StatusBadRequest, rw.Code, "expects a 400 response for an invalid event")
+}
+
+func (suite *HyperdriveTestSuite) TestCloudEventHandlerError() {
+	h := CloudEventHandler(func(ctx context.Context, e *CloudEvent) error {
+		return errors.New("failed")
+	})
+	r, _ := NewCloudEventRequest(context.Background(), "/events", testCloudEvent(), CloudEventBinary)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusInternalServerError, rw.Code, "expects a 500 response")
+}
+
+func (suite *HyperdriveTestSuite) TestMessageCloudEvent() {
+	m := &Message{
+		Body:       []byte(`{"name":"sprocket"}`),
+		Attributes: map[string]string{"ce_id": "1", "ce_source": "/widgets", "ce_specversion": "1.0", "ce_type": "com.example.widget.created", "content-type": "application/json"},
+	}
+	e, err := MessageCloudEvent(m)
+	suite.Nil(err, "expects no error")
+	suite.Equal("com.example.widget.created", e.Type, "expects a binary event to be decoded from the attributes")
+	b, _ := json.Marshal(testCloudEvent())
+	e, err = MessageCloudEvent(&Message{Body: b})
+	suite.Nil(err, "expects no error")
+	suite.Equal(testCloudEvent(), e, "expects a structured event to be decoded from the body")
+}
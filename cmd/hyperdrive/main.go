@@ -0,0 +1,46 @@
+// Command hyperdrive generates hyperdrive projects.
+//
+// Usage:
+//
+//	hyperdrive example [-name name] [-module path] [dir]
+//
+// The example command writes a complete, runnable example service (a CRUD
+// resource, with API key auth, metrics, and tests) to dir, which defaults to
+// the name of the API.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hyperdriven/hyperdrive"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: hyperdrive example [-name name] [-module path] [dir]")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "example" {
+		usage()
+	}
+	flags := flag.NewFlagSet("example", flag.ExitOnError)
+	flags.Usage = usage
+	name := flags.String("name", "example", "the name of the API")
+	module := flags.String("module", "", "the module path (default: example.com/<name>)")
+	flags.Parse(os.Args[2:])
+	dir := flags.Arg(0)
+	if dir == "" {
+		dir = *name
+	}
+	if *module == "" {
+		*module = "example.com/" + *name
+	}
+	if err := hyperdrive.WriteExample(dir, *name, *module); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote the %s example API to %s\n", *name, dir)
+}
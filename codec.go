@@ -0,0 +1,134 @@
+package hyperdrive
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Codec encodes response bodies, and decodes request bodies, of a custom
+// media type, e.g. CBOR. Decode must return the fields of the body in the
+// same shape as BodyJSON, so the body can be used by BodyParams, NestedParams
+// and Bind.
+type Codec interface {
+	Encode(w io.Writer, v interface{}) error
+	Decode(body []byte) (map[string]interface{}, error)
+}
+
+// CodecEncoder is an implementation of ContentEncoder which writes the
+// response body with a Codec registered with RegisterCodec.
+type CodecEncoder struct {
+	Codec  Codec
+	Writer io.Writer
+}
+
+// Encode encodes v with the Codec.
+func (enc CodecEncoder) Encode(v interface{}) error {
+	return enc.Codec.Encode(enc.Writer, v)
+}
+
+// codecs holds the Codecs registered with RegisterCodec, by media type.
+type codecs struct {
+	mu     sync.RWMutex
+	byType map[string]Codec
+}
+
+// RegisterCodec registers the Codec for the given media type, e.g.
+// "application/vnd.foo+cbor", so request bodies with that Content-Type are
+// decoded by it (see BodyParams and Bind), and responses to requests which
+// Accept it are encoded by it (see Respond), without forking the params and
+// render code. A Codec is also used for other media types with the same
+// structured suffix or extension, e.g. application/vnd.api.widgets.v1.cbor,
+// so endpoints respond with their vendor media type in that format. Codecs
+// take precedence over the built in JSON and XML support.
+func (api *API) RegisterCodec(mediaType string, c Codec) {
+	api.codecs.mu.Lock()
+	defer api.codecs.mu.Unlock()
+	if api.codecs.byType == nil {
+		api.codecs.byType = map[string]Codec{}
+	}
+	api.codecs.byType[strings.ToLower(mediaType)] = c
+}
+
+// mediaTypeSuffix returns the structured suffix (after the last +) of a
+// media type, or its extension (after the last .), e.g. "cbor" for both
+// application/vnd.foo+cbor and application/vnd.api.widgets.v1.cbor.
+func mediaTypeSuffix(mediaType string) string {
+	subtype := mediaType[strings.Index(mediaType, "/")+1:]
+	if i := strings.LastIndexAny(subtype, "+."); i >= 0 {
+		return subtype[i+1:]
+	}
+	return subtype
+}
+
+// lookup returns the Codec registered for the given media type, or for one
+// with the same suffix.
+func (c *codecs) lookup(mediaType string) (Codec, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	mediaType = strings.ToLower(mediaType)
+	if codec, ok := c.byType[mediaType]; ok {
+		return codec, true
+	}
+	suffix := mediaTypeSuffix(mediaType)
+	for _, t := range c.sortedTypes() {
+		if mediaTypeSuffix(t) == suffix {
+			return c.byType[t], true
+		}
+	}
+	return nil, false
+}
+
+// sortedTypes returns the registered media types, sorted, so lookups by
+// suffix are deterministic.
+func (c *codecs) sortedTypes() []string {
+	types := make([]string, 0, len(c.byType))
+	for t := range c.byType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// contentTypeCodec returns the Codec for the given Content-Type, if one is
+// registered with the API.
+func contentTypeCodec(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	return hAPI.codecs.lookup(mediaType)
+}
+
+// hasCodec returns true if a Codec is registered with the API for the given
+// Content-Type.
+func hasCodec(contentType string) bool {
+	_, ok := contentTypeCodec(contentType)
+	return ok
+}
+
+// BodyCodec decodes the request body with the Codec registered for its
+// Content-Type (see RegisterCodec), into the same shape as BodyJSON. It
+// returns ErrUnsupportedMediaType if no Codec is registered for it. The body
+// is left unread, so BodyCodec (and BodyParams) can be called more than once.
+func BodyCodec(r *http.Request) (map[string]interface{}, error) {
+	c, ok := contentTypeCodec(r.Header.Get("Content-Type"))
+	if !ok {
+		return map[string]interface{}{}, ErrUnsupportedMediaType
+	}
+	b, err := RawBody(r)
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+	body, err := c.Decode(b)
+	if err != nil || body == nil {
+		return map[string]interface{}{}, err
+	}
+	return body, nil
+}
@@ -0,0 +1,99 @@
+package hyperdrive
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"sort"
+	"strings"
+)
+
+// lineCodec encodes and decodes bodies of key=value lines.
+type lineCodec struct{}
+
+func (c lineCodec) Encode(w io.Writer, v interface{}) error {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return errors.New("lineCodec: only maps can be encoded")
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s=%v\n", k, m[k])
+	}
+	return nil
+}
+
+func (c lineCodec) Decode(b []byte) (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		kv := strings.SplitN(s.Text(), "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New("lineCodec: expected key=value")
+		}
+		body[kv[0]] = kv[1]
+	}
+	return body, s.Err()
+}
+
+func (suite *HyperdriveTestSuite) TestBodyCodec() {
+	suite.TestAPI.RegisterCodec("application/vnd.test+lines", lineCodec{})
+	r := httptest.NewRequest("POST", "/test", strings.NewReader("name=test\nlimit=10\n"))
+	r.Header.Set("Content-Type", "application/vnd.test+lines; charset=utf-8")
+	body, err := BodyCodec(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal(map[string]interface{}{"name": "test", "limit": "10"}, body, "expects the body to be decoded by the codec")
+	suite.Equal("test", BodyParams(r).Get("name"), "expects the body to be flattened by BodyParams")
+	suite.Equal("10", NestedParams(r)["limit"], "expects the body to be used by NestedParams")
+}
+
+func (suite *HyperdriveTestSuite) TestBodyCodecBySuffix() {
+	suite.TestAPI.RegisterCodec("application/vnd.test+lines", lineCodec{})
+	r := httptest.NewRequest("POST", "/test", strings.NewReader("name=test\n"))
+	r.Header.Set("Content-Type", "application/vnd.api.test.v1.lines")
+	suite.Equal("test", BodyParams(r).Get("name"), "expects the codec to be used for media types with the same extension")
+}
+
+func (suite *HyperdriveTestSuite) TestBodyCodecUnregistered() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader("name=test\n"))
+	r.Header.Set("Content-Type", "application/vnd.test+lines")
+	_, err := BodyCodec(r)
+	suite.Equal(ErrUnsupportedMediaType, err, "expects an error when no codec is registered")
+}
+
+func (suite *HyperdriveTestSuite) TestBindCodec() {
+	suite.TestAPI.RegisterCodec("application/vnd.test+lines", lineCodec{})
+	r := httptest.NewRequest("PUT", "/users/42", strings.NewReader("name=test\n"))
+	r.Header.Set("Content-Type", "application/vnd.test+lines")
+	target, err := suite.bindRequest(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal("test", target.Name, "expects the field to be decoded")
+	suite.Equal(42, target.ID, "expects path values to be bound")
+}
+
+func (suite *HyperdriveTestSuite) TestBindCodecInvalid() {
+	suite.TestAPI.RegisterCodec("application/vnd.test+lines", lineCodec{})
+	r := httptest.NewRequest("PUT", "/users/42", strings.NewReader("name\n"))
+	r.Header.Set("Content-Type", "application/vnd.test+lines")
+	_, err := suite.bindRequest(r)
+	verr, ok := err.(*ValidationError)
+	suite.True(ok, "expects a ValidationError")
+	suite.Equal("The request body could not be decoded as application/vnd.test+lines.", verr.Errors[0].Message, "expects the body to be reported")
+}
+
+func (suite *HyperdriveTestSuite) TestGetEncoderCodec() {
+	suite.TestAPI.RegisterCodec("application/vnd.test+lines", lineCodec{})
+	rw := httptest.NewRecorder()
+	enc, _ := GetEncoder(rw, "text/plain, application/vnd.test+lines;q=0.9")
+	suite.IsType(CodecEncoder{}, enc, "expects the codec to encode the response")
+	suite.Nil(enc.Encode(map[string]interface{}{"name": "test"}), "expects no error")
+	suite.Equal("application/vnd.test+lines", rw.Header().Get("Content-Type"), "expects the Content-Type of the codec")
+	suite.Equal("name=test\n", rw.Body.String(), "expects the body to be encoded by the codec")
+}
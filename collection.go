@@ -0,0 +1,89 @@
+package hyperdrive
+
+import "net/http"
+
+// Collection is a neutral representation of a page of a list, which the
+// hypermedia renderers turn into their own format: HAL (see HAL), Siren
+// (see Siren), and Collection+JSON (see CollectionJSON), e.g.
+//
+//	q, err := hyperdrive.ListParams(e, r)
+//	...
+//	c := hyperdrive.NewCollection(r, "users", q, total)
+//	for _, u := range users {
+//		c.AddItem("/users/"+u.ID, u)
+//	}
+//	hyperdrive.Respond(rw, r, http.StatusOK, c)
+//
+// Responses negotiated as HAL, Siren, or Collection+JSON are written in that
+// format.
+type Collection struct {
+	Name  string            `json:"name"`
+	Href  string            `json:"href"`
+	Links map[string]string `json:"links,omitempty"`
+	Total int               `json:"total"`
+	Items []CollectionItem  `json:"items"`
+}
+
+// CollectionItem is an item of a Collection: its URL, and its Data (a
+// struct or map, encoded as a JSON object).
+type CollectionItem struct {
+	Href string      `json:"href"`
+	Data interface{} `json:"data"`
+}
+
+// NewCollection returns an empty Collection named after the items in it
+// (e.g. users), for the list requested, with links to its pages (see
+// PageLinks) for the page and per_page query params parsed by ListParams,
+// and the total number of items in the list.
+func NewCollection(r *http.Request, name string, q ListQuery, total int) *Collection {
+	return &Collection{Name: name, Href: r.URL.RequestURI(), Links: PageLinks(r, q, total), Total: total, Items: []CollectionItem{}}
+}
+
+// AddItem adds an item at the given URL to the collection.
+func (c *Collection) AddItem(href string, data interface{}) {
+	c.Items = append(c.Items, CollectionItem{Href: href, Data: data})
+}
+
+// pageRels are the rels of the links of a Collection, in the order they are
+// rendered.
+var pageRels = []string{"first", "prev", "next", "last"}
+
+// HAL returns the collection as a HALResource, with its total as a property,
+// self and pagination links, and its items embedded as a list with the name
+// of the collection, each with a self link.
+func (c *Collection) HAL() *HALResource {
+	res := NewHALResource(map[string]int{"total": c.Total})
+	res.AddLink("self", HALLink{Href: c.Href})
+	for _, rel := range pageRels {
+		if href, ok := c.Links[rel]; ok {
+			res.AddLink(rel, HALLink{Href: href})
+		}
+	}
+	items := make([]*HALResource, len(c.Items))
+	for i, item := range c.Items {
+		items[i] = NewHALResource(item.Data)
+		items[i].AddLink("self", HALLink{Href: item.Href})
+	}
+	res.EmbedList(c.Name, items)
+	return res
+}
+
+// Siren returns the collection as a SirenEntity, of the classes collection
+// and the name of the collection, with its total as a property, self and
+// pagination links, and its items as sub-entities with the rel item, each
+// with a self link.
+func (c *Collection) Siren() *SirenEntity {
+	ent := NewSirenEntity(map[string]int{"total": c.Total}, "collection", c.Name)
+	for _, item := range c.Items {
+		sub := NewSirenEntity(item.Data, c.Name)
+		sub.AddLink(item.Href, "self")
+		ent.AddEntity(sub, "item")
+	}
+	ent.AddLink(c.Href, "self")
+	for _, rel := range pageRels {
+		if href, ok := c.Links[rel]; ok {
+			ent.AddLink(href, rel)
+		}
+	}
+	return ent
+}
@@ -0,0 +1,63 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) testCollection() *Collection {
+	r := httptest.NewRequest("GET", "/users?page=2&per_page=1", nil)
+	c := NewCollection(r, "users", ListQuery{Page: 2, PerPage: 1}, 3)
+	c.AddItem("/users/2", halUser{ID: 2, Name: "Ada"})
+	return c
+}
+
+func (suite *HyperdriveTestSuite) TestCollectionHAL() {
+	b, err := json.Marshal(suite.testCollection().HAL())
+	suite.Nil(err, "expects no error")
+	suite.JSONEq(`{
+		"total": 3,
+		"_links": {
+			"self": {"href": "/users?page=2&per_page=1"},
+			"first": {"href": "/users?page=1&per_page=1"},
+			"prev": {"href": "/users?page=1&per_page=1"},
+			"next": {"href": "/users?page=3&per_page=1"},
+			"last": {"href": "/users?page=3&per_page=1"}
+		},
+		"_embedded": {"users": [{"id": 2, "name": "Ada", "_links": {"self": {"href": "/users/2"}}}]}
+	}`, string(b), "expects a HAL document")
+}
+
+func (suite *HyperdriveTestSuite) TestCollectionSiren() {
+	b, err := json.Marshal(suite.testCollection().Siren())
+	suite.Nil(err, "expects no error")
+	suite.JSONEq(`{
+		"class": ["collection", "users"],
+		"properties": {"total": 3},
+		"entities": [{"class": ["users"], "rel": ["item"], "properties": {"id": 2, "name": "Ada"}, "links": [{"rel": ["self"], "href": "/users/2"}]}],
+		"links": [
+			{"rel": ["self"], "href": "/users?page=2&per_page=1"},
+			{"rel": ["first"], "href": "/users?page=1&per_page=1"},
+			{"rel": ["prev"], "href": "/users?page=1&per_page=1"},
+			{"rel": ["next"], "href": "/users?page=3&per_page=1"},
+			{"rel": ["last"], "href": "/users?page=3&per_page=1"}
+		]
+	}`, string(b), "expects a Siren entity")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondCollection() {
+	for accept, contentType := range map[string]string{
+		"application/json":        "application/json",
+		HALContentType:            HALContentType,
+		SirenContentType:          SirenContentType,
+		CollectionJSONContentType: CollectionJSONContentType,
+	} {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/users", nil)
+		r.Header.Set("Accept", accept)
+		Respond(rw, r, http.StatusOK, suite.testCollection())
+		suite.Equal(contentType, rw.Header().Get("Content-Type"), "expects the negotiated Content-Type")
+		suite.True(json.Valid(rw.Body.Bytes()), "expects a JSON body for "+accept)
+	}
+}
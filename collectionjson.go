@@ -0,0 +1,166 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// CollectionJSONContentType is the media type of Collection+JSON documents.
+const CollectionJSONContentType = "application/vnd.collection+json"
+
+// CollectionJSON is a Collection+JSON (application/vnd.collection+json)
+// document.
+type CollectionJSON struct {
+	Collection CollectionJSONCollection `json:"collection"`
+}
+
+// CollectionJSONCollection is the collection object of a Collection+JSON
+// document. Template describes the data clients send to add an item (see
+// NewCollectionJSONTemplate).
+type CollectionJSONCollection struct {
+	Version  string                  `json:"version"`
+	Href     string                  `json:"href"`
+	Links    []CollectionJSONLink    `json:"links,omitempty"`
+	Items    []CollectionJSONItem    `json:"items"`
+	Queries  []CollectionJSONQuery   `json:"queries,omitempty"`
+	Template *CollectionJSONTemplate `json:"template,omitempty"`
+	Error    *CollectionJSONError    `json:"error,omitempty"`
+}
+
+// CollectionJSONLink is a link of a Collection+JSON collection or item.
+type CollectionJSONLink struct {
+	Rel    string `json:"rel"`
+	Href   string `json:"href"`
+	Name   string `json:"name,omitempty"`
+	Render string `json:"render,omitempty"`
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// CollectionJSONItem is an item of a Collection+JSON collection, with its
+// fields as Data.
+type CollectionJSONItem struct {
+	Href  string               `json:"href"`
+	Data  []CollectionJSONData `json:"data"`
+	Links []CollectionJSONLink `json:"links,omitempty"`
+}
+
+// CollectionJSONData is a field of an item, query, or template.
+type CollectionJSONData struct {
+	Name   string      `json:"name"`
+	Value  interface{} `json:"value"`
+	Prompt string      `json:"prompt,omitempty"`
+}
+
+// CollectionJSONQuery is a query clients can make on a collection.
+type CollectionJSONQuery struct {
+	Rel    string               `json:"rel"`
+	Href   string               `json:"href"`
+	Prompt string               `json:"prompt,omitempty"`
+	Data   []CollectionJSONData `json:"data,omitempty"`
+}
+
+// CollectionJSONTemplate describes the fields of an item.
+type CollectionJSONTemplate struct {
+	Data []CollectionJSONData `json:"data"`
+}
+
+// CollectionJSONError describes an error in a Collection+JSON document.
+type CollectionJSONError struct {
+	Title   string `json:"title,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// CollectionJSON returns the collection as a Collection+JSON document, with
+// self and pagination links, and an item for each of its items, whose data
+// are the fields of its Data, ordered by name. It returns an error if the
+// Data of an item is not encoded as a JSON object.
+func (c *Collection) CollectionJSON() (*CollectionJSON, error) {
+	doc := &CollectionJSON{Collection: CollectionJSONCollection{
+		Version: "1.0",
+		Href:    c.Href,
+		Links:   []CollectionJSONLink{},
+		Items:   []CollectionJSONItem{},
+	}}
+	for _, rel := range pageRels {
+		if href, ok := c.Links[rel]; ok {
+			doc.Collection.Links = append(doc.Collection.Links, CollectionJSONLink{Rel: rel, Href: href})
+		}
+	}
+	for _, item := range c.Items {
+		data, err := collectionJSONData(item.Data)
+		if err != nil {
+			return nil, err
+		}
+		doc.Collection.Items = append(doc.Collection.Items, CollectionJSONItem{Href: item.Href, Data: data})
+	}
+	return doc, nil
+}
+
+// collectionJSONData returns the fields of v, as it is encoded as JSON, as
+// Collection+JSON data.
+func collectionJSONData(v interface{}) ([]CollectionJSONData, error) {
+	var fields map[string]interface{}
+	if err := decodeAsJSON(v, &fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, errors.New("hyperdrive: Collection+JSON items must be encoded as a JSON object")
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	data := make([]CollectionJSONData, len(names))
+	for i, name := range names {
+		data[i] = CollectionJSONData{Name: name, Value: fields[name]}
+	}
+	return data, nil
+}
+
+// NewCollectionJSONTemplate returns the template of the items of an
+// endpoint's collection: a field for each param allowed for the method
+// (e.g. POST), ordered by name, with its default as its value, and its
+// description, if it has one, as its prompt.
+func NewCollectionJSONTemplate(e Endpointer, method string) *CollectionJSONTemplate {
+	params := parseEndpoint(e)
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	t := &CollectionJSONTemplate{Data: []CollectionJSONData{}}
+	for _, k := range keys {
+		p := params[k]
+		if !p.IsAllowed(method) {
+			continue
+		}
+		d := CollectionJSONData{Name: p.Key, Value: p.Default}
+		if p.Desc != "..." {
+			d.Prompt = p.Desc
+		}
+		t.Data = append(t.Data, d)
+	}
+	return t
+}
+
+// CollectionJSONEncoder is an implementation of ContentEncoder which writes
+// Collection+JSON documents. A *Collection is written as returned by its
+// CollectionJSON method, and other values as they are.
+type CollectionJSONEncoder struct {
+	Encoder *json.Encoder
+}
+
+// Encode encodes input as a Collection+JSON document or returns an error.
+func (enc CollectionJSONEncoder) Encode(v interface{}) error {
+	if c, ok := v.(*Collection); ok {
+		doc, err := c.CollectionJSON()
+		if err != nil {
+			return err
+		}
+		return enc.Encoder.Encode(doc)
+	}
+	return enc.Encoder.Encode(v)
+}
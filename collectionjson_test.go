@@ -0,0 +1,37 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+)
+
+func (suite *HyperdriveTestSuite) TestCollectionJSON() {
+	doc, err := suite.testCollection().CollectionJSON()
+	suite.Nil(err, "expects no error")
+	b, _ := json.Marshal(doc)
+	suite.JSONEq(`{"collection": {
+		"version": "1.0",
+		"href": "/users?page=2&per_page=1",
+		"links": [
+			{"rel": "first", "href": "/users?page=1&per_page=1"},
+			{"rel": "prev", "href": "/users?page=1&per_page=1"},
+			{"rel": "next", "href": "/users?page=3&per_page=1"},
+			{"rel": "last", "href": "/users?page=3&per_page=1"}
+		],
+		"items": [{"href": "/users/2", "data": [{"name": "id", "value": 2}, {"name": "name", "value": "Ada"}]}]
+	}}`, string(b), "expects a Collection+JSON document")
+}
+
+func (suite *HyperdriveTestSuite) TestCollectionJSONInvalidItem() {
+	c := suite.testCollection()
+	c.AddItem("/users/3", "Grace")
+	_, err := c.CollectionJSON()
+	suite.Error(err, "expects an error for items which are not an object")
+}
+
+func (suite *HyperdriveTestSuite) TestNewCollectionJSONTemplate() {
+	e := &SirenEndpoint{Endpoint: NewEndpoint("Orders", "Manage orders", "/orders", "1")}
+	suite.Equal(&CollectionJSONTemplate{Data: []CollectionJSONData{
+		{Name: "amount", Value: ""},
+		{Name: "name", Value: ""},
+	}}, NewCollectionJSONTemplate(e, "POST"), "expects a field for each param allowed for the method")
+}
@@ -0,0 +1,308 @@
+package hyperdrive
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+)
+
+// dczHeader is the magic number which starts every dictionary-compressed
+// zstd (dcz) response body, followed by the SHA-256 hash of the dictionary.
+var dczHeader = []byte{0x5e, 0x2a, 0x4d, 0x18, 0x20, 0x00, 0x00, 0x00}
+
+// maxDictionarySample is the most of a response body kept as a sample for
+// training a dictionary.
+const maxDictionarySample = 64 << 10
+
+// CompressionDictionary is a raw dictionary used to compress responses with
+// zstd, for clients which have already downloaded it. Similar payloads (e.g.
+// JSON with the same keys) compress far better with a dictionary, since the
+// repeated parts are sent once, in the dictionary, rather than with every
+// response.
+type CompressionDictionary struct {
+	Data     []byte
+	Hash     [sha256.Size]byte
+	encoders sync.Pool
+}
+
+// NewCompressionDictionary returns a CompressionDictionary with the given
+// contents.
+func NewCompressionDictionary(data []byte) *CompressionDictionary {
+	return &CompressionDictionary{Data: data, Hash: sha256.Sum256(data)}
+}
+
+// TrainCompressionDictionary returns a CompressionDictionary of at most size
+// bytes, built from the substrings most common to the given samples.
+func TrainCompressionDictionary(samples [][]byte, size int) (*CompressionDictionary, error) {
+	if len(samples) == 0 {
+		return nil, errors.New("a compression dictionary can not be trained without samples")
+	}
+	data, err := dict.BuildRawDict(samples, dict.Options{MaxDictSize: size, HashBytes: 6})
+	if err != nil {
+		return nil, err
+	}
+	return NewCompressionDictionary(data), nil
+}
+
+// ID returns the hash of the dictionary, in the form sent by clients in the
+// Available-Dictionary header, e.g. ":pZGm1Av0IEBKARczz7exkNYsZb8LzaMrV7J32a2fFG4=:".
+func (d *CompressionDictionary) ID() string {
+	return ":" + base64.StdEncoding.EncodeToString(d.Hash[:]) + ":"
+}
+
+func (d *CompressionDictionary) encoder() (*zstd.Encoder, error) {
+	if enc, ok := d.encoders.Get().(*zstd.Encoder); ok {
+		return enc, nil
+	}
+	return zstd.NewWriter(nil, zstd.WithEncoderDictRaw(0, d.Data), zstd.WithWindowSize(8<<20), zstd.WithEncoderConcurrency(1))
+}
+
+// compressionDictionaries holds the current CompressionDictionary, and the
+// responses sampled to train the next one, shared by every copy of the API.
+type compressionDictionaries struct {
+	mu       sync.RWMutex
+	current  *CompressionDictionary
+	samples  [][]byte
+	training bool
+}
+
+func (c *compressionDictionaries) get() *CompressionDictionary {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.current
+}
+
+func (c *compressionDictionaries) set(d *CompressionDictionary) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = d
+}
+
+func (c *compressionDictionaries) sampling() bool {
+	if conf.CompressionDictionarySampleRate <= 0 {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !c.training && len(c.samples) < conf.CompressionDictionarySamples && rand.Float64() < conf.CompressionDictionarySampleRate
+}
+
+// addSample keeps the given response body, and returns true once there are
+// enough samples to train a dictionary.
+func (c *compressionDictionaries) addSample(b []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.training || len(c.samples) >= conf.CompressionDictionarySamples {
+		return false
+	}
+	c.samples = append(c.samples, b)
+	if len(c.samples) < conf.CompressionDictionarySamples {
+		return false
+	}
+	c.training = true
+	return true
+}
+
+// SetCompressionDictionary replaces the CompressionDictionary used by
+// CompressionMiddleware, e.g. with one trained ahead of time and
+// shipped with your API. A nil dictionary disables dictionary compression.
+func (api *API) SetCompressionDictionary(d *CompressionDictionary) {
+	api.dictionaries.set(d)
+}
+
+// CompressionDictionary returns the current CompressionDictionary, or nil if
+// there is none yet.
+func (api *API) CompressionDictionary() *CompressionDictionary {
+	return api.dictionaries.get()
+}
+
+// TrainCompressionDictionary trains a CompressionDictionary of at most
+// COMPRESSION_DICTIONARY_SIZE bytes (default: 64KB) from the responses
+// sampled by CompressionMiddleware, and makes it the current
+// dictionary. Samples are discarded, so the next dictionary is trained from
+// new responses. It is called automatically once COMPRESSION_DICTIONARY_SAMPLES
+// responses have been sampled.
+func (api *API) TrainCompressionDictionary() error {
+	c := api.dictionaries
+	c.mu.Lock()
+	samples := c.samples
+	c.samples = nil
+	c.training = true
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.training = false
+		c.mu.Unlock()
+	}()
+	d, err := TrainCompressionDictionary(samples, conf.CompressionDictionarySize)
+	if err != nil {
+		return err
+	}
+	api.SetCompressionDictionary(d)
+	return nil
+}
+
+// CompressionDictionaryHandler returns an http.Handler which serves the
+// current CompressionDictionary, with a Use-As-Dictionary header telling
+// clients to use it for every request to the API, or a 404 Not Found if there
+// is none yet. It is mounted at COMPRESSION_DICTIONARY_PATH, when set.
+func (api *API) CompressionDictionaryHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		d := api.CompressionDictionary()
+		if d == nil {
+			writeProblem(rw, http.StatusNotFound, "No compression dictionary is available yet.")
+			return
+		}
+		rw.Header().Set("Use-As-Dictionary", `match="/*", id="`+d.ID()+`"`)
+		rw.Header().Set("Content-Type", "application/octet-stream")
+		rw.Header().Set("Cache-Control", "public, max-age=86400")
+		rw.Header().Set("ETag", strconv.Quote(d.ID()))
+		if r.Header.Get("If-None-Match") == strconv.Quote(d.ID()) {
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+		rw.Write(d.Data)
+	})
+}
+
+// acceptsDictionary returns true if the request's Accept-Encoding header
+// includes dcz, and its Available-Dictionary header matches the dictionary.
+func acceptsDictionary(r *http.Request, d *CompressionDictionary) bool {
+	if d == nil || r.Header.Get("Available-Dictionary") != d.ID() {
+		return false
+	}
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(strings.SplitN(enc, ";", 2)[0]) == "dcz" {
+			return true
+		}
+	}
+	return false
+}
+
+// dictionaryCompressed wraps the given http.Handler, and compresses its
+// responses with the given CompressionDictionary, using the
+// dictionary-compressed zstd (dcz) Content-Encoding.
+func dictionaryCompressed(h http.Handler, d *CompressionDictionary) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Add("Vary", "Accept-Encoding, Available-Dictionary")
+		w := &dictionaryWriter{ResponseWriter: rw, dict: d}
+		defer func() {
+			if err := w.Close(); err != nil {
+				log.Printf("Dictionary compressed response could not be completed: %v", err)
+			}
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// dictionaryWriter wraps an http.ResponseWriter, and compresses the body
+// with a CompressionDictionary.
+type dictionaryWriter struct {
+	http.ResponseWriter
+	dict        *CompressionDictionary
+	enc         *zstd.Encoder
+	wroteHeader bool
+}
+
+func (w *dictionaryWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	header := w.Header()
+	if status != http.StatusNoContent && status != http.StatusNotModified && header.Get("Content-Encoding") == "" {
+		enc, err := w.dict.encoder()
+		if err == nil {
+			header.Del("Content-Length")
+			header.Set("Content-Encoding", "dcz")
+			w.enc = enc
+		} else {
+			log.Printf("Dictionary compression could not be started: %v", err)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+	if w.enc != nil {
+		w.ResponseWriter.Write(dczHeader)
+		w.ResponseWriter.Write(w.dict.Hash[:])
+		w.enc.Reset(w.ResponseWriter)
+	}
+}
+
+func (w *dictionaryWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the compressed data written so far to the client.
+func (w *dictionaryWriter) Flush() {
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close completes the compressed response, and returns the encoder to the
+// dictionary's pool.
+func (w *dictionaryWriter) Close() error {
+	if w.enc == nil {
+		return nil
+	}
+	err := w.enc.Close()
+	w.enc.Reset(nil)
+	w.dict.encoders.Put(w.enc)
+	w.enc = nil
+	return err
+}
+
+// dictionarySampler wraps the given http.Handler, and keeps a
+// COMPRESSION_DICTIONARY_SAMPLE_RATE fraction of its successful JSON
+// responses, before they are compressed, to train the next
+// CompressionDictionary. As the dictionary is served to anyone, responses
+// which may be personalized are never sampled: those to requests with a
+// Principal, or credentials (an Authorization header, API key, signature, or
+// cookie), and those which set a cookie or are marked private or no-store.
+func (api *API) dictionarySampler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if _, authenticated := CurrentPrincipal(r); authenticated || hasCredentials(r) || !api.dictionaries.sampling() {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		w := &recordingWriter{ResponseWriter: rw}
+		h.ServeHTTP(w, r)
+		ct, _, _ := mime.ParseMediaType(rw.Header().Get("Content-Type"))
+		if w.status != http.StatusOK || !strings.HasSuffix(ct, "json") || w.body.Len() == 0 || w.body.Len() > maxDictionarySample {
+			return
+		}
+		cc := strings.ToLower(rw.Header().Get("Cache-Control"))
+		if rw.Header().Get("Set-Cookie") != "" || strings.Contains(cc, "private") || strings.Contains(cc, "no-store") {
+			return
+		}
+		if api.dictionaries.addSample(w.body.Bytes()) {
+			go func() {
+				if err := api.TrainCompressionDictionary(); err != nil {
+					log.Printf("Compression dictionary could not be trained: %v", err)
+				}
+			}()
+		}
+	})
+}
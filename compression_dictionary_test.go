@@ -0,0 +1,125 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func dictionarySamples() [][]byte {
+	var samples [][]byte
+	for i := 0; i < 20; i++ {
+		samples = append(samples, []byte(fmt.Sprintf(`{"id":%d,"name":"widget %d","description":"a widget for testing compression dictionaries","tags":["alpha","beta"]}`, i, i)))
+	}
+	return samples
+}
+
+func jsonHandler(body []byte) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(body)
+	})
+}
+
+func (suite *HyperdriveTestSuite) TestTrainCompressionDictionary() {
+	d, err := TrainCompressionDictionary(dictionarySamples(), 1024)
+	suite.Nil(err, "expects no error")
+	suite.NotEmpty(d.Data, "expects a dictionary")
+	suite.True(len(d.Data) <= 1024, "expects the dictionary to be no larger than the given size")
+	_, err = TrainCompressionDictionary(nil, 1024)
+	suite.Error(err, "expects an error without samples")
+}
+
+func (suite *HyperdriveTestSuite) TestCompressionMiddlewareDictionary() {
+	d, _ := TrainCompressionDictionary(dictionarySamples(), 1024)
+	suite.TestAPI.SetCompressionDictionary(d)
+	defer suite.TestAPI.SetCompressionDictionary(nil)
+	body := dictionarySamples()[3]
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Accept-Encoding", "gzip, br, dcz")
+	r.Header.Set("Available-Dictionary", d.ID())
+	rw := httptest.NewRecorder()
+	suite.TestAPI.CompressionMiddleware(jsonHandler(body)).ServeHTTP(rw, r)
+	suite.Equal("dcz", rw.Header().Get("Content-Encoding"), "expects a dictionary compressed response")
+	suite.Equal("Accept-Encoding, Available-Dictionary", rw.Header().Get("Vary"), "expects the response to vary by dictionary")
+	out := rw.Body.Bytes()
+	suite.Equal(dczHeader, out[:8], "expects the dcz header")
+	suite.Equal(d.Hash[:], out[8:40], "expects the dictionary hash")
+	dec, _ := zstd.NewReader(bytes.NewReader(out[40:]), zstd.WithDecoderDictRaw(0, d.Data))
+	defer dec.Close()
+	decoded, err := io.ReadAll(dec)
+	suite.Nil(err, "expects the body to be decompressed with the dictionary")
+	suite.Equal(body, decoded, "expects the original body")
+}
+
+func (suite *HyperdriveTestSuite) TestCompressionMiddlewareUnknownDictionary() {
+	d, _ := TrainCompressionDictionary(dictionarySamples(), 1024)
+	suite.TestAPI.SetCompressionDictionary(d)
+	defer suite.TestAPI.SetCompressionDictionary(nil)
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Accept-Encoding", "gzip, dcz")
+	r.Header.Set("Available-Dictionary", ":c29tZSBvdGhlciBkaWN0aW9uYXJ5:")
+	rw := httptest.NewRecorder()
+	suite.TestAPI.CompressionMiddleware(jsonHandler(dictionarySamples()[0])).ServeHTTP(rw, r)
+	suite.Equal("gzip", rw.Header().Get("Content-Encoding"), "expects gzip when the client has another dictionary")
+}
+
+func (suite *HyperdriveTestSuite) TestCompressionDictionaryHandler() {
+	rw := httptest.NewRecorder()
+	suite.TestAPI.CompressionDictionaryHandler().ServeHTTP(rw, suite.TestGetRequest)
+	suite.Equal(http.StatusNotFound, rw.Code, "expects a 404 without a dictionary")
+	d := NewCompressionDictionary([]byte(`{"id":"name":"description"}`))
+	suite.TestAPI.SetCompressionDictionary(d)
+	defer suite.TestAPI.SetCompressionDictionary(nil)
+	rw = httptest.NewRecorder()
+	suite.TestAPI.CompressionDictionaryHandler().ServeHTTP(rw, suite.TestGetRequest)
+	suite.Equal(http.StatusOK, rw.Code, "expects a 200 OK")
+	suite.Equal(`match="/*", id="`+d.ID()+`"`, rw.Header().Get("Use-As-Dictionary"), "expects clients to be told to use the dictionary")
+	suite.Equal(d.Data, rw.Body.Bytes(), "expects the dictionary")
+}
+
+func (suite *HyperdriveTestSuite) TestCompressionDictionarySampling() {
+	defer func(c Config) { conf = c }(conf)
+	conf.CompressionDictionarySampleRate = 1
+	conf.CompressionDictionarySamples = 20
+	conf.CompressionDictionarySize = 1024
+	defer suite.TestAPI.SetCompressionDictionary(nil)
+	for _, body := range dictionarySamples() {
+		r := httptest.NewRequest("GET", "/test", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		suite.TestAPI.CompressionMiddleware(jsonHandler(body)).ServeHTTP(httptest.NewRecorder(), r)
+	}
+	suite.Eventually(func() bool { return suite.TestAPI.CompressionDictionary() != nil }, time.Second, 10*time.Millisecond, "expects a dictionary to be trained from sampled responses")
+}
+
+func (suite *HyperdriveTestSuite) TestCompressionDictionarySamplingPersonalized() {
+	defer func(c Config) { conf = c }(conf)
+	conf.CompressionDictionarySampleRate = 1
+	conf.CompressionDictionarySamples = 20
+	defer func() { suite.TestAPI.dictionaries.samples = nil }()
+	body := dictionarySamples()[0]
+	requests := map[string]*http.Request{}
+	for _, h := range []string{"Authorization", "Cookie", conf.APIKeyHeader} {
+		r := httptest.NewRequest("GET", "/test", nil)
+		r.Header.Set(h, "secret")
+		requests[h] = r
+	}
+	requests["principal"] = WithPrincipal(httptest.NewRequest("GET", "/test", nil), &Principal{ID: "1", Method: "test"})
+	for name, r := range requests {
+		suite.TestAPI.CompressionMiddleware(jsonHandler(body)).ServeHTTP(httptest.NewRecorder(), r)
+		suite.Empty(suite.TestAPI.dictionaries.samples, "expects responses to requests with "+name+" not to be sampled")
+	}
+	private := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Cache-Control", "private")
+		jsonHandler(body).ServeHTTP(rw, r)
+	})
+	suite.TestAPI.CompressionMiddleware(private).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+	suite.Empty(suite.TestAPI.dictionaries.samples, "expects private responses not to be sampled")
+	suite.TestAPI.CompressionMiddleware(jsonHandler(body)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+	suite.Len(suite.TestAPI.dictionaries.samples, 1, "expects anonymous responses to be sampled")
+}
@@ -0,0 +1,120 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// limiter caps the number of requests in flight, with a bounded queue of
+// requests waiting for a slot.
+type limiter struct {
+	slots   chan struct{}
+	mu      sync.Mutex
+	waiting int
+}
+
+func newLimiter(n int) *limiter {
+	return &limiter{slots: make(chan struct{}, n)}
+}
+
+// acquire takes a slot, waiting up to timeout for one if the queue is not
+// full, and returns false if it could not. Call release once the request is
+// complete.
+func (l *limiter) acquire(r *http.Request, queue int, timeout time.Duration) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	l.mu.Lock()
+	if l.waiting >= queue {
+		l.mu.Unlock()
+		return false
+	}
+	l.waiting++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+	}()
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-t.C:
+	case <-r.Context().Done():
+	}
+	return false
+}
+
+func (l *limiter) release() {
+	<-l.slots
+}
+
+// concurrencyLimits holds the limiters used by ConcurrencyLimitMiddleware,
+// shared by every copy of the API, and every endpoint.
+type concurrencyLimits struct {
+	mu     sync.Mutex
+	global *limiter
+	routes map[string]*limiter
+}
+
+func (c *concurrencyLimits) limiters(route string) []*limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var limiters []*limiter
+	if conf.ConcurrencyLimit > 0 {
+		if c.global == nil {
+			c.global = newLimiter(conf.ConcurrencyLimit)
+		}
+		limiters = append(limiters, c.global)
+	}
+	if conf.ConcurrencyRouteLimit > 0 {
+		if c.routes == nil {
+			c.routes = map[string]*limiter{}
+		}
+		l, ok := c.routes[route]
+		if !ok {
+			l = newLimiter(conf.ConcurrencyRouteLimit)
+			c.routes[route] = l
+		}
+		// The route is limited before the API, so requests queued for a busy
+		// route do not hold slots other routes could use.
+		limiters = append([]*limiter{l}, limiters...)
+	}
+	return limiters
+}
+
+// ConcurrencyLimitMiddleware wraps the given http.Handler, and caps the
+// number of requests in flight: CONCURRENCY_LIMIT for the whole API, and
+// CONCURRENCY_ROUTE_LIMIT for each route (e.g. /users/{id}). Unlike a rate
+// limit, this bounds the work in progress, which protects downstream
+// databases when requests slow down. Requests over a limit wait, in a queue
+// of at most CONCURRENCY_QUEUE (default: 100) requests, for up to
+// CONCURRENCY_QUEUE_TIMEOUT (default: 1s); when the queue is full, or the
+// wait times out, they are rejected with a 503 Service Unavailable. Limits of
+// 0 (the default) are not enforced.
+func (api *API) ConcurrencyLimitMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		limiters := api.concurrency.limiters(routeLabel(r))
+		for i, l := range limiters {
+			if !l.acquire(r, conf.ConcurrencyQueue, conf.ConcurrencyQueueTimeout) {
+				for _, acquired := range limiters[:i] {
+					acquired.release()
+				}
+				rw.Header().Set("Retry-After", "1")
+				writeProblem(rw, http.StatusServiceUnavailable, "Too many requests are in progress, try again later.")
+				return
+			}
+		}
+		defer func() {
+			for _, l := range limiters {
+				l.release()
+			}
+		}()
+		h.ServeHTTP(rw, r)
+	})
+}
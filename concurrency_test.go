@@ -0,0 +1,89 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// blockingHandler returns a handler which blocks until release is closed,
+// signalling started as each request begins.
+func blockingHandler(started chan struct{}, release chan struct{}) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+}
+
+func (suite *HyperdriveTestSuite) TestConcurrencyLimitMiddleware() {
+	defer func(c Config) { conf = c }(conf)
+	conf.ConcurrencyLimit = 1
+	conf.ConcurrencyQueue = 0
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	h := suite.TestAPI.ConcurrencyLimitMiddleware(blockingHandler(started, release))
+	go h.ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	<-started
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/test", nil))
+	suite.Equal(http.StatusServiceUnavailable, rw.Code, "expects a 503 when every slot is in use, and the queue is full")
+	suite.Equal("1", rw.Header().Get("Retry-After"), "expects a Retry-After header")
+	close(release)
+}
+
+func (suite *HyperdriveTestSuite) TestConcurrencyLimitMiddlewareQueue() {
+	defer func(c Config) { conf = c }(conf)
+	conf.ConcurrencyLimit = 1
+	conf.ConcurrencyQueue = 1
+	conf.ConcurrencyQueueTimeout = time.Second
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	h := suite.TestAPI.ConcurrencyLimitMiddleware(blockingHandler(started, release))
+	go h.ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	<-started
+	queued := httptest.NewRecorder()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(queued, httptest.NewRequest("GET", "/test", nil))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	suite.Equal(http.StatusOK, queued.Code, "expects queued requests to be served once a slot is free")
+}
+
+func (suite *HyperdriveTestSuite) TestConcurrencyLimitMiddlewareQueueTimeout() {
+	defer func(c Config) { conf = c }(conf)
+	conf.ConcurrencyLimit = 1
+	conf.ConcurrencyQueue = 1
+	conf.ConcurrencyQueueTimeout = 10 * time.Millisecond
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+	h := suite.TestAPI.ConcurrencyLimitMiddleware(blockingHandler(started, release))
+	go h.ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	<-started
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/test", nil))
+	suite.Equal(http.StatusServiceUnavailable, rw.Code, "expects a 503 when the wait times out")
+}
+
+func (suite *HyperdriveTestSuite) TestConcurrencyLimitMiddlewareRoutes() {
+	defer func(c Config) { conf = c }(conf)
+	conf.ConcurrencyRouteLimit = 1
+	conf.ConcurrencyQueue = 0
+	suite.Equal(1, len(suite.TestAPI.concurrency.limiters("/a")), "expects a limiter for the route")
+	a, b := suite.TestAPI.concurrency.limiters("/a")[0], suite.TestAPI.concurrency.limiters("/b")[0]
+	suite.True(a.acquire(suite.TestGetRequest, 0, 0), "expects a slot for the first route")
+	suite.True(b.acquire(suite.TestGetRequest, 0, 0), "expects routes to be limited separately")
+	suite.False(a.acquire(suite.TestGetRequest, 0, 0), "expects the route to be limited")
+	a.release()
+	suite.True(a.acquire(suite.TestGetRequest, 0, 0), "expects released slots to be reused")
+}
+
+func (suite *HyperdriveTestSuite) TestConcurrencyLimitMiddlewareDisabled() {
+	suite.Empty(suite.TestAPI.concurrency.limiters("/a"), "expects no limiters by default")
+	rw := httptest.NewRecorder()
+	suite.TestAPI.ConcurrencyLimitMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})).ServeHTTP(rw, suite.TestGetRequest)
+	suite.Equal(http.StatusOK, rw.Code, "expects requests to be served")
+}
@@ -0,0 +1,70 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"time"
+)
+
+// LastModifiedHandler interface is satisfied if the endpoint has implemented
+// a method called LastModified(), returning the time the resource requested
+// was last modified. If this is implemented, GET and HEAD requests will have
+// the Last-Modified header set, and will be responded to with a
+// `304 Not Modified` if the resource has not been modified since the time
+// given in the If-Modified-Since header. Return the zero time.Time to skip
+// these checks for a given request.
+type LastModifiedHandler interface {
+	LastModified(*http.Request) time.Time
+}
+
+// NotModified is a helper function to make it easy for an Endpointer's method
+// handler (e.g. GetHandler) to support conditional GET requests. It sets the
+// Last-Modified header to the given time, and returns true, after responding
+// with a `304 Not Modified`, if the request's If-Modified-Since header shows
+// the client already has the current representation, e.g.:
+//
+//	if hyperdrive.NotModified(rw, r, user.UpdatedAt) {
+//		return
+//	}
+func NotModified(rw http.ResponseWriter, r *http.Request, modified time.Time) bool {
+	if modified.IsZero() || modified.Equal(time.Unix(0, 0)) {
+		return false
+	}
+	rw.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	if !isNotModified(r, modified) {
+		return false
+	}
+	h := rw.Header()
+	delete(h, "Content-Type")
+	delete(h, "Content-Length")
+	rw.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// isNotModified evaluates the If-Modified-Since precondition, as described
+// in RFC 7232. It is ignored if the request also has an If-None-Match header.
+func isNotModified(r *http.Request, modified time.Time) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// Last-Modified has a resolution of one second.
+	return !modified.Truncate(time.Second).After(t)
+}
+
+// lastModifiedHandlerFunc wraps the given http.Handler, checking the
+// LastModified() time of the given LastModifiedHandler before calling it.
+func lastModifiedHandlerFunc(e LastModifiedHandler, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if (r.Method == "GET" || r.Method == "HEAD") && NotModified(rw, r, e.LastModified(r)) {
+			return
+		}
+		h.ServeHTTP(rw, r)
+	})
+}
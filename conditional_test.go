@@ -0,0 +1,70 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+type LastModifiedEndpoint struct {
+	Endpoint
+	Modified time.Time
+}
+
+func (e *LastModifiedEndpoint) Get(rw http.ResponseWriter, r *http.Request) {
+	rw.Write([]byte("ok"))
+}
+
+func (e *LastModifiedEndpoint) LastModified(r *http.Request) time.Time {
+	return e.Modified
+}
+
+func (suite *HyperdriveTestSuite) TestNotModifiedZero() {
+	rw := httptest.NewRecorder()
+	suite.False(NotModified(rw, suite.TestGetRequest, time.Time{}), "expects false when there is no modified time")
+	suite.Equal("", rw.Header().Get("Last-Modified"), "expects Last-Modified not to be set")
+}
+
+func (suite *HyperdriveTestSuite) TestNotModifiedSetsHeader() {
+	rw := httptest.NewRecorder()
+	modified := time.Date(2017, 3, 27, 12, 0, 0, 0, time.UTC)
+	suite.False(NotModified(rw, suite.TestGetRequest, modified), "expects false without If-Modified-Since")
+	suite.Equal("Mon, 27 Mar 2017 12:00:00 GMT", rw.Header().Get("Last-Modified"), "expects Last-Modified to be set")
+}
+
+func (suite *HyperdriveTestSuite) TestNotModified() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("If-Modified-Since", "Mon, 27 Mar 2017 12:00:00 GMT")
+	suite.True(NotModified(rw, r, time.Date(2017, 3, 27, 12, 0, 0, 500, time.UTC)), "expects true when not modified since")
+	suite.Equal(http.StatusNotModified, rw.Code, "expects a 304")
+}
+
+func (suite *HyperdriveTestSuite) TestNotModifiedModified() {
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("If-Modified-Since", "Mon, 27 Mar 2017 12:00:00 GMT")
+	suite.False(NotModified(httptest.NewRecorder(), r, time.Date(2017, 3, 27, 12, 0, 1, 0, time.UTC)), "expects false when modified since")
+}
+
+func (suite *HyperdriveTestSuite) TestNotModifiedIfNoneMatch() {
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("If-Modified-Since", "Mon, 27 Mar 2017 12:00:00 GMT")
+	r.Header.Set("If-None-Match", `"abc"`)
+	suite.False(NotModified(httptest.NewRecorder(), r, time.Date(2017, 3, 27, 12, 0, 0, 0, time.UTC)), "expects If-Modified-Since to be ignored")
+}
+
+func (suite *HyperdriveTestSuite) TestNotModifiedPost() {
+	r := httptest.NewRequest("POST", "/test", nil)
+	r.Header.Set("If-Modified-Since", "Mon, 27 Mar 2017 12:00:00 GMT")
+	suite.False(NotModified(httptest.NewRecorder(), r, time.Date(2017, 3, 27, 12, 0, 0, 0, time.UTC)), "expects If-Modified-Since to be ignored")
+}
+
+func (suite *HyperdriveTestSuite) TestLastModifiedHandler() {
+	e := &LastModifiedEndpoint{Endpoint: *NewEndpoint("Test", "Test Endpoint", "/test", "1"), Modified: time.Date(2017, 3, 27, 12, 0, 0, 0, time.UTC)}
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("If-Modified-Since", "Mon, 27 Mar 2017 12:00:00 GMT")
+	NewMethodHandler(e).ServeHTTP(rw, r)
+	suite.Equal(http.StatusNotModified, rw.Code, "expects a 304")
+	suite.Equal("", rw.Body.String(), "expects an empty body")
+}
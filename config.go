@@ -1,8 +1,15 @@
 package hyperdrive
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env"
 )
@@ -21,13 +28,130 @@ func init() {
 // (where possible). Required configuration will throw a Fatal error if they
 // are missing.
 type Config struct {
-	Port            int    `env:"PORT" envDefault:"5000"`
-	Env             string `env:"HYPERDRIVE_ENV" envDefault:"development"`
-	GzipLevel       int    `env:"GZIP_LEVEL" envDefault:"-1"`
-	CorsEnabled     bool   `env:"CORS_ENABLED" envDefault:"true"`
-	CorsOrigins     string `env:"CORS_ORIGINS" envDefault:"*"`
-	CorsHeaders     string `env:"CORS_HEADERS" envDefault:""`
-	CorsCredentials bool   `env:"CORS_CREDENTIALS" envDefault:"true"`
+	Port                            int           `env:"PORT" envDefault:"5000"`
+	Host                            string        `env:"HOST" envDefault:""`
+	ListenAddrs                     string        `env:"LISTEN_ADDRS" envDefault:""`
+	Env                             string        `env:"HYPERDRIVE_ENV" envDefault:"development"`
+	GzipLevel                       int           `env:"GZIP_LEVEL" envDefault:"-1"`
+	CompressionDictionaryPath       string        `env:"COMPRESSION_DICTIONARY_PATH" envDefault:""`
+	CompressionDictionarySampleRate float64       `env:"COMPRESSION_DICTIONARY_SAMPLE_RATE" envDefault:"0"`
+	CompressionDictionarySamples    int           `env:"COMPRESSION_DICTIONARY_SAMPLES" envDefault:"500"`
+	CompressionDictionarySize       int           `env:"COMPRESSION_DICTIONARY_SIZE" envDefault:"65536"`
+	CorsEnabled                     bool          `env:"CORS_ENABLED" envDefault:"true"`
+	CorsOrigins                     string        `env:"CORS_ORIGINS" envDefault:"*"`
+	CorsHeaders                     string        `env:"CORS_HEADERS" envDefault:""`
+	CorsCredentials                 bool          `env:"CORS_CREDENTIALS" envDefault:"true"`
+	CorsDebug                       bool          `env:"CORS_DEBUG" envDefault:"false"`
+	LogFormat                       string        `env:"LOG_FORMAT" envDefault:"combined"`
+	JSONCanonical                   bool          `env:"JSON_CANONICAL" envDefault:"false"`
+	JSONPretty                      bool          `env:"JSON_PRETTY" envDefault:"false"`
+	RenderETags                     bool          `env:"RENDER_ETAGS" envDefault:"false"`
+	CSVEscapeFormulas               bool          `env:"CSV_ESCAPE_FORMULAS" envDefault:"true"`
+	MetricsBucketPaths              bool          `env:"METRICS_BUCKET_PATHS" envDefault:"false"`
+	MetricsRouteAllow               string        `env:"METRICS_ROUTE_ALLOW" envDefault:""`
+	MetricsRouteDeny                string        `env:"METRICS_ROUTE_DENY" envDefault:""`
+	MetricsMaxRoutes                int           `env:"METRICS_MAX_ROUTES" envDefault:"500"`
+	ClientFingerprints              bool          `env:"CLIENT_FINGERPRINTS" envDefault:"false"`
+	MaintenanceMode                 bool          `env:"MAINTENANCE_MODE" envDefault:"false"`
+	MaintenanceRetryAfter           int           `env:"MAINTENANCE_RETRY_AFTER" envDefault:"300"`
+	MaintenanceBody                 string        `env:"MAINTENANCE_BODY" envDefault:"{\"error\":\"Service Unavailable\",\"status\":503}"`
+	MaintenanceAllow                string        `env:"MAINTENANCE_ALLOW" envDefault:"/health"`
+	UserAgentBlock                  string        `env:"USER_AGENT_BLOCK" envDefault:""`
+	UserAgentAllow                  string        `env:"USER_AGENT_ALLOW" envDefault:"Googlebot,bingbot,DuckDuckBot,Applebot,YandexBot,Baiduspider"`
+	UserAgentAction                 string        `env:"USER_AGENT_ACTION" envDefault:"block"`
+	ProxyProtocol                   bool          `env:"PROXY_PROTOCOL" envDefault:"false"`
+	ProxyProtocolTimeout            time.Duration `env:"PROXY_PROTOCOL_TIMEOUT" envDefault:"5s"`
+	IdempotencyTTL                  time.Duration `env:"IDEMPOTENCY_TTL" envDefault:"24h"`
+	CacheTTL                        time.Duration `env:"CACHE_TTL" envDefault:"1m"`
+	RequestDecompressionMaxBytes    int           `env:"REQUEST_DECOMPRESSION_MAX_BYTES" envDefault:"10485760"`
+	RawBodyMaxBytes                 int           `env:"RAW_BODY_MAX_BYTES" envDefault:"10485760"`
+	MultipartMaxMemory              int           `env:"MULTIPART_MAX_MEMORY" envDefault:"33554432"`
+	MultipartMaxFileSize            int           `env:"MULTIPART_MAX_FILE_SIZE" envDefault:"0"`
+	MaxUploadBytes                  int           `env:"MAX_UPLOAD_BYTES" envDefault:"0"`
+	TLSCertFile                     string        `env:"TLS_CERT_FILE" envDefault:""`
+	TLSKeyFile                      string        `env:"TLS_KEY_FILE" envDefault:""`
+	TLSMinVersion                   string        `env:"TLS_MIN_VERSION" envDefault:"1.2"`
+	TLSCipherSuites                 string        `env:"TLS_CIPHER_SUITES" envDefault:""`
+	HTTPMinVersion                  string        `env:"HTTP_MIN_VERSION" envDefault:"1.0"`
+	HTTP3Enabled                    bool          `env:"HTTP3_ENABLED" envDefault:"false"`
+	HTTP3Addr                       string        `env:"HTTP3_ADDR" envDefault:""`
+	RedirectsFile                   string        `env:"REDIRECTS_FILE" envDefault:""`
+	MiddlewareChain                 string        `env:"MIDDLEWARE_CHAIN" envDefault:""`
+	CanonicalHost                   string        `env:"CANONICAL_HOST" envDefault:""`
+	CanonicalLowercase              bool          `env:"CANONICAL_LOWERCASE" envDefault:"false"`
+	CanonicalQueryParams            string        `env:"CANONICAL_QUERY_PARAMS" envDefault:""`
+	TrustedProxies                  string        `env:"TRUSTED_PROXIES" envDefault:""`
+	BasicAuthUsers                  string        `env:"BASIC_AUTH_USERS" envDefault:""`
+	BasicAuthRealm                  string        `env:"BASIC_AUTH_REALM" envDefault:"hyperdrive"`
+	AdminEnabled                    bool          `env:"ADMIN_ENABLED" envDefault:"false"`
+	AdminUsers                      string        `env:"ADMIN_USERS" envDefault:""`
+	APIKeyHeader                    string        `env:"API_KEY_HEADER" envDefault:"X-API-Key"`
+	APIKeyQueryParam                string        `env:"API_KEY_QUERY_PARAM" envDefault:""`
+	SQLComments                     bool          `env:"SQL_COMMENTS" envDefault:"true"`
+	OAuth2IntrospectionURL          string        `env:"OAUTH2_INTROSPECTION_URL" envDefault:""`
+	OAuth2ClientID                  string        `env:"OAUTH2_CLIENT_ID" envDefault:""`
+	OAuth2ClientSecret              string        `env:"OAUTH2_CLIENT_SECRET" envDefault:""`
+	OAuth2IntrospectionCacheTTL     time.Duration `env:"OAUTH2_INTROSPECTION_CACHE_TTL" envDefault:"1m"`
+	SignatureHeader                 string        `env:"SIGNATURE_HEADER" envDefault:"X-Signature"`
+	SignatureClientHeader           string        `env:"SIGNATURE_CLIENT_HEADER" envDefault:"X-Client-Id"`
+	SignatureTolerance              time.Duration `env:"SIGNATURE_TOLERANCE" envDefault:"5m"`
+	ConsumerConcurrency             int           `env:"CONSUMER_CONCURRENCY" envDefault:"1"`
+	ConsumerMaxAttempts             int           `env:"CONSUMER_MAX_ATTEMPTS" envDefault:"3"`
+	ConsumerRetryBackoff            time.Duration `env:"CONSUMER_RETRY_BACKOFF" envDefault:"1s"`
+	SessionSecret                   string        `env:"SESSION_SECRET" envDefault:""`
+	SessionEncryptionKey            string        `env:"SESSION_ENCRYPTION_KEY" envDefault:""`
+	SessionCookieName               string        `env:"SESSION_COOKIE_NAME" envDefault:"hyperdrive_session"`
+	SessionCookieSecure             bool          `env:"SESSION_COOKIE_SECURE" envDefault:"true"`
+	SessionMaxAge                   time.Duration `env:"SESSION_MAX_AGE" envDefault:"24h"`
+	CursorSecret                    string        `env:"CURSOR_SECRET" envDefault:""`
+	CursorEncryptionKey             string        `env:"CURSOR_ENCRYPTION_KEY" envDefault:""`
+	CursorTTL                       time.Duration `env:"CURSOR_TTL" envDefault:"24h"`
+	ListMaxItems                    int           `env:"LIST_MAX_ITEMS" envDefault:"0"`
+	ListLimitAction                 string        `env:"LIST_LIMIT_ACTION" envDefault:"paginate"`
+	DeployMode                      string        `env:"DEPLOY_MODE" envDefault:"auto"`
+	ShutdownTimeout                 time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"15s"`
+	StreamDrainTimeout              time.Duration `env:"STREAM_DRAIN_TIMEOUT" envDefault:"5s"`
+	SSEKeepAlive                    time.Duration `env:"SSE_KEEP_ALIVE" envDefault:"15s"`
+	WebSocketPingInterval           time.Duration `env:"WEBSOCKET_PING_INTERVAL" envDefault:"30s"`
+	WebSocketMaxMessageBytes        int           `env:"WEBSOCKET_MAX_MESSAGE_BYTES" envDefault:"1048576"`
+	DrainOverlap                    time.Duration `env:"DRAIN_OVERLAP" envDefault:"0s"`
+	ConfigSnapshotFile              string        `env:"CONFIG_SNAPSHOT_FILE" envDefault:""`
+	IDFormat                        string        `env:"ID_FORMAT" envDefault:"hex"`
+	SnowflakeNode                   int           `env:"SNOWFLAKE_NODE" envDefault:"0"`
+	ParamListSeparators             string        `env:"PARAM_LIST_SEPARATORS" envDefault:","`
+	ParamPrecedence                 string        `env:"PARAM_PRECEDENCE" envDefault:"path,body,query"`
+	ListDefaultPerPage              int           `env:"LIST_DEFAULT_PER_PAGE" envDefault:"25"`
+	ListMaxPerPage                  int           `env:"LIST_MAX_PER_PAGE" envDefault:"100"`
+	ReadHeaderTimeout               time.Duration `env:"READ_HEADER_TIMEOUT" envDefault:"5s"`
+	ReadTimeout                     time.Duration `env:"READ_TIMEOUT" envDefault:"15s"`
+	WriteTimeout                    time.Duration `env:"WRITE_TIMEOUT" envDefault:"15s"`
+	IdleTimeout                     time.Duration `env:"IDLE_TIMEOUT" envDefault:"60s"`
+	ReadyPath                       string        `env:"READY_PATH" envDefault:"/ready"`
+	SmokeTarget                     string        `env:"SMOKE_TARGET" envDefault:""`
+	SmokeTimeout                    time.Duration `env:"SMOKE_TIMEOUT" envDefault:"10s"`
+	MirrorURL                       string        `env:"MIRROR_URL" envDefault:""`
+	MirrorPercent                   float64       `env:"MIRROR_PERCENT" envDefault:"0"`
+	MirrorTimeout                   time.Duration `env:"MIRROR_TIMEOUT" envDefault:"5s"`
+	MirrorMaxBody                   int           `env:"MIRROR_MAX_BODY" envDefault:"1048576"`
+	MirrorCredentials               bool          `env:"MIRROR_CREDENTIALS" envDefault:"false"`
+	FaultInjection                  bool          `env:"FAULT_INJECTION" envDefault:"false"`
+	FaultPercent                    float64       `env:"FAULT_PERCENT" envDefault:"0"`
+	FaultDelay                      time.Duration `env:"FAULT_DELAY" envDefault:"0s"`
+	FaultStatus                     int           `env:"FAULT_STATUS" envDefault:"503"`
+	FaultPaths                      string        `env:"FAULT_PATHS" envDefault:""`
+	FaultHeader                     string        `env:"FAULT_HEADER" envDefault:""`
+	RateLimit                       int           `env:"RATE_LIMIT" envDefault:"0"`
+	RateLimitWindow                 time.Duration `env:"RATE_LIMIT_WINDOW" envDefault:"1m"`
+	RateLimitBy                     string        `env:"RATE_LIMIT_BY" envDefault:"ip"`
+	RateLimitHeaders                string        `env:"RATE_LIMIT_HEADERS" envDefault:"x-ratelimit,ratelimit"`
+	ConcurrencyLimit                int           `env:"CONCURRENCY_LIMIT" envDefault:"0"`
+	ConcurrencyRouteLimit           int           `env:"CONCURRENCY_ROUTE_LIMIT" envDefault:"0"`
+	ConcurrencyQueue                int           `env:"CONCURRENCY_QUEUE" envDefault:"100"`
+	ConcurrencyQueueTimeout         time.Duration `env:"CONCURRENCY_QUEUE_TIMEOUT" envDefault:"1s"`
+	QuotaLimit                      int           `env:"QUOTA_LIMIT" envDefault:"0"`
+	QuotaPeriod                     string        `env:"QUOTA_PERIOD" envDefault:"month"`
+	PaginationStyle                 string        `env:"PAGINATION_STYLE" envDefault:"headers"`
+	ResponseEnvelope                bool          `env:"RESPONSE_ENVELOPE" envDefault:"false"`
 }
 
 // GetPort returns the formatted value of config.Port, for use by the
@@ -36,9 +160,233 @@ func (c *Config) GetPort() string {
 	return fmt.Sprintf(":%d", c.Port)
 }
 
+// GetAddr returns the first address the hyperdrive server will listen on,
+// e.g. ":5000", or the result of GetPort if the configured addresses are
+// invalid.
+func (c *Config) GetAddr() string {
+	addrs, err := c.GetListenAddrs()
+	if err != nil || len(addrs) == 0 {
+		return c.GetPort()
+	}
+	return addrs[0]
+}
+
+// TLSEnabled returns true if both config.TLSCertFile and config.TLSKeyFile
+// are set, in which case the hyperdrive server will only accept TLS
+// connections.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// GetHTTP3Addr returns the UDP address the experimental HTTP/3 server will
+// listen on: config.HTTP3Addr if it is set, otherwise the first TCP listen
+// address, e.g. ":5000".
+func (c *Config) GetHTTP3Addr() string {
+	if c.HTTP3Addr != "" {
+		return c.HTTP3Addr
+	}
+	return c.GetAddr()
+}
+
+// Serverless returns true if the API is deployed to a scale-to-zero platform
+// (e.g. Cloud Run, or AWS Lambda), where the CPU may be throttled outside of
+// requests, and instances are stopped with little notice. DEPLOY_MODE may be
+// "server", "serverless", or "auto" (the default), which detects Cloud Run
+// (K_SERVICE) and AWS Lambda (AWS_LAMBDA_FUNCTION_NAME) from the environment.
+//
+// Serverless APIs do not run consumers, run Background work before requests
+// complete, and wait at most 8s (or SHUTDOWN_TIMEOUT, if shorter) for
+// requests to finish when shutting down.
+func (c *Config) Serverless() bool {
+	switch c.DeployMode {
+	case "serverless":
+		return true
+	case "auto":
+		return os.Getenv("K_SERVICE") != "" || os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != ""
+	}
+	return false
+}
+
+// GetListenAddrs returns the validated addresses the hyperdrive server will
+// listen on. If config.ListenAddrs is set, it is treated as a comma separated
+// list of host:port pairs, e.g. "127.0.0.1:5000,[::1]:5000". Otherwise a single
+// address is built from config.Host and config.Port. An empty host listens on
+// all interfaces, on both IPv4 and IPv6 where supported (dual-stack); IPv6
+// hosts may be given with or without brackets, e.g. HOST=::1.
+func (c *Config) GetListenAddrs() ([]string, error) {
+	var addrs []string
+	if strings.TrimSpace(c.ListenAddrs) == "" {
+		addrs = []string{net.JoinHostPort(strings.Trim(c.Host, "[]"), strconv.Itoa(c.Port))}
+	} else {
+		for _, addr := range strings.Split(c.ListenAddrs, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	for _, addr := range addrs {
+		if err := validateListenAddr(addr); err != nil {
+			return addrs, fmt.Errorf("invalid listen address %q: %v", addr, err)
+		}
+	}
+	return addrs, nil
+}
+
+func validateListenAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 0 || p > 65535 {
+		return fmt.Errorf("port must be a number between 0 and 65535, got %q", port)
+	}
+	if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return fmt.Errorf("host %q is not a valid IPv6 address", host)
+	}
+	return nil
+}
+
 // NewConfig returns an instance of config, with values loaded from ENV vars.
 func NewConfig() (Config, error) {
 	c := Config{}
 	err := env.Parse(&c)
+	if err == nil {
+		err = c.validate()
+	}
 	return c, err
 }
+
+func (c *Config) validate() error {
+	if _, err := c.GetListenAddrs(); err != nil {
+		return err
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.HTTP3Enabled && !c.TLSEnabled() {
+		return errors.New("HTTP3_ENABLED requires TLS_CERT_FILE and TLS_KEY_FILE to be set")
+	}
+	if _, err := parseTLSVersion(c.TLSMinVersion); err != nil {
+		return fmt.Errorf("TLS_MIN_VERSION is invalid: %v", err)
+	}
+	if _, err := parseCipherSuites(splitList(c.TLSCipherSuites)); err != nil {
+		return fmt.Errorf("TLS_CIPHER_SUITES is invalid: %v", err)
+	}
+	if err := validateHTTPVersion(c.HTTPMinVersion); err != nil {
+		return fmt.Errorf("HTTP_MIN_VERSION is invalid: %v", err)
+	}
+	if c.HTTPMinVersion == "2" && !c.TLSEnabled() {
+		return errors.New("HTTP_MIN_VERSION 2 requires TLS_CERT_FILE and TLS_KEY_FILE to be set, as HTTP/2 is only served over TLS")
+	}
+	for name, d := range map[string]time.Duration{
+		"READ_HEADER_TIMEOUT":     c.ReadHeaderTimeout,
+		"READ_TIMEOUT":            c.ReadTimeout,
+		"WRITE_TIMEOUT":           c.WriteTimeout,
+		"IDLE_TIMEOUT":            c.IdleTimeout,
+		"STREAM_DRAIN_TIMEOUT":    c.StreamDrainTimeout,
+		"SSE_KEEP_ALIVE":          c.SSEKeepAlive,
+		"WEBSOCKET_PING_INTERVAL": c.WebSocketPingInterval,
+		"DRAIN_OVERLAP":           c.DrainOverlap,
+	} {
+		if d < 0 {
+			return fmt.Errorf("%s must not be negative, got %v", name, d)
+		}
+	}
+	if c.WebSocketMaxMessageBytes <= 0 {
+		return fmt.Errorf("WEBSOCKET_MAX_MESSAGE_BYTES must be greater than 0, got %d", c.WebSocketMaxMessageBytes)
+	}
+	if c.DrainOverlap > 0 && c.DrainOverlap >= c.ShutdownTimeout {
+		return fmt.Errorf("DRAIN_OVERLAP must be shorter than SHUTDOWN_TIMEOUT, got %v", c.DrainOverlap)
+	}
+	for _, list := range []string{c.UserAgentBlock, c.UserAgentAllow} {
+		if _, err := userAgentPatterns(list); err != nil {
+			return err
+		}
+	}
+	if c.MirrorURL != "" {
+		if u, err := url.Parse(c.MirrorURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("MIRROR_URL must be an absolute http or https URL, got %q", c.MirrorURL)
+		}
+	}
+	if _, err := parseTrustedProxies(c.TrustedProxies); err != nil {
+		return fmt.Errorf("TRUSTED_PROXIES is invalid: %v", err)
+	}
+	if c.MirrorPercent < 0 || c.MirrorPercent > 100 {
+		return fmt.Errorf("MIRROR_PERCENT must be between 0 and 100, got %v", c.MirrorPercent)
+	}
+	if c.MetricsMaxRoutes < 0 {
+		return fmt.Errorf("METRICS_MAX_ROUTES must not be negative, got %d", c.MetricsMaxRoutes)
+	}
+	if c.RequestDecompressionMaxBytes <= 0 {
+		return fmt.Errorf("REQUEST_DECOMPRESSION_MAX_BYTES must be greater than 0, got %d", c.RequestDecompressionMaxBytes)
+	}
+	if c.RawBodyMaxBytes <= 0 {
+		return fmt.Errorf("RAW_BODY_MAX_BYTES must be greater than 0, got %d", c.RawBodyMaxBytes)
+	}
+	if c.MultipartMaxMemory <= 0 {
+		return fmt.Errorf("MULTIPART_MAX_MEMORY must be greater than 0, got %d", c.MultipartMaxMemory)
+	}
+	if c.MultipartMaxFileSize < 0 {
+		return fmt.Errorf("MULTIPART_MAX_FILE_SIZE must not be negative, got %d", c.MultipartMaxFileSize)
+	}
+	if c.MaxUploadBytes < 0 {
+		return fmt.Errorf("MAX_UPLOAD_BYTES must not be negative, got %d", c.MaxUploadBytes)
+	}
+	if c.FaultPercent < 0 || c.FaultPercent > 100 {
+		return fmt.Errorf("FAULT_PERCENT must be between 0 and 100, got %v", c.FaultPercent)
+	}
+	if c.FaultStatus != 0 && (c.FaultStatus < 400 || c.FaultStatus > 599) {
+		return fmt.Errorf("FAULT_STATUS must be 0, or an error status between 400 and 599, got %d", c.FaultStatus)
+	}
+	if !contains([]string{"paginate", "log"}, c.ListLimitAction) {
+		return fmt.Errorf("LIST_LIMIT_ACTION must be paginate or log, got %q", c.ListLimitAction)
+	}
+	if !contains([]string{"block", "tag"}, c.UserAgentAction) {
+		return fmt.Errorf("USER_AGENT_ACTION must be block or tag, got %q", c.UserAgentAction)
+	}
+	if !contains([]string{"headers", "envelope", "both"}, c.PaginationStyle) {
+		return fmt.Errorf("PAGINATION_STYLE must be headers, envelope, or both, got %q", c.PaginationStyle)
+	}
+	if !contains([]string{"hex", "uuidv4", "uuidv7", "ulid", "snowflake"}, c.IDFormat) {
+		return fmt.Errorf("ID_FORMAT must be hex, uuidv4, uuidv7, ulid, or snowflake, got %q", c.IDFormat)
+	}
+	if c.ParamListSeparators == "" {
+		return errors.New("PARAM_LIST_SEPARATORS must not be empty")
+	}
+	if _, err := parseParamPrecedence(c.ParamPrecedence); err != nil {
+		return fmt.Errorf("PARAM_PRECEDENCE %v", err)
+	}
+	if c.ListDefaultPerPage <= 0 {
+		return fmt.Errorf("LIST_DEFAULT_PER_PAGE must be greater than 0, got %d", c.ListDefaultPerPage)
+	}
+	if c.ListMaxPerPage < c.ListDefaultPerPage {
+		return fmt.Errorf("LIST_MAX_PER_PAGE must not be less than LIST_DEFAULT_PER_PAGE (%d), got %d", c.ListDefaultPerPage, c.ListMaxPerPage)
+	}
+	if c.SnowflakeNode < 0 || c.SnowflakeNode > 1023 {
+		return fmt.Errorf("SNOWFLAKE_NODE must be between 0 and 1023, got %d", c.SnowflakeNode)
+	}
+	if !contains([]string{"auto", "server", "serverless"}, c.DeployMode) {
+		return fmt.Errorf("DEPLOY_MODE must be auto, server, or serverless, got %q", c.DeployMode)
+	}
+	if !contains([]string{"ip", "principal"}, c.RateLimitBy) {
+		return fmt.Errorf("RATE_LIMIT_BY must be ip or principal, got %q", c.RateLimitBy)
+	}
+	for _, h := range strings.Split(c.RateLimitHeaders, ",") {
+		if h = strings.TrimSpace(h); h != "" && !contains([]string{"x-ratelimit", "ratelimit"}, h) {
+			return fmt.Errorf("RATE_LIMIT_HEADERS must be a list of x-ratelimit and ratelimit, got %q", c.RateLimitHeaders)
+		}
+	}
+	if c.CompressionDictionarySampleRate < 0 || c.CompressionDictionarySampleRate > 1 {
+		return fmt.Errorf("COMPRESSION_DICTIONARY_SAMPLE_RATE must be between 0 and 1, got %v", c.CompressionDictionarySampleRate)
+	}
+	if c.CompressionDictionarySize <= 0 {
+		return fmt.Errorf("COMPRESSION_DICTIONARY_SIZE must be greater than 0, got %d", c.CompressionDictionarySize)
+	}
+	if !contains([]string{"day", "month"}, c.QuotaPeriod) {
+		return fmt.Errorf("QUOTA_PERIOD must be day or month, got %q", c.QuotaPeriod)
+	}
+	if c.AdminEnabled && len(NewStaticCredentials(c.AdminUsers)) == 0 {
+		return errors.New("ADMIN_ENABLED requires ADMIN_USERS to be set")
+	}
+	return nil
+}
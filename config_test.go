@@ -1,6 +1,9 @@
 package hyperdrive
 
-import "os"
+import (
+	"os"
+	"time"
+)
 
 func (suite *HyperdriveTestSuite) TestNewConfig() {
 	c, _ := NewConfig()
@@ -102,3 +105,1051 @@ func (suite *HyperdriveTestSuite) TestCorsCredentialsConfigFromEnv() {
 	c, _ := NewConfig()
 	suite.Equal(false, c.CorsCredentials, "CorsCredentials should be equal to CORS_CREDENTIALS value set via ENV var")
 }
+
+func (suite *HyperdriveTestSuite) TestCorsDebugConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(false, c.CorsDebug, "CorsDebug should be false by default")
+}
+
+func (suite *HyperdriveTestSuite) TestCorsDebugConfigFromEnv() {
+	os.Setenv("CORS_DEBUG", "true")
+	defer os.Unsetenv("CORS_DEBUG")
+	c, _ := NewConfig()
+	suite.Equal(true, c.CorsDebug, "CorsDebug should be equal to CORS_DEBUG value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestLogFormatConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("combined", c.LogFormat, "LogFormat should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestLogFormatConfigFromEnv() {
+	os.Setenv("LOG_FORMAT", "json")
+	defer os.Unsetenv("LOG_FORMAT")
+	c, _ := NewConfig()
+	suite.Equal("json", c.LogFormat, "LogFormat should be equal to LOG_FORMAT value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestHostConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("", c.Host, "Host should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestHostConfigFromEnv() {
+	os.Setenv("HOST", "::1")
+	defer os.Unsetenv("HOST")
+	c, _ := NewConfig()
+	suite.Equal("::1", c.Host, "Host should be equal to HOST value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestListenAddrsConfigFromEnv() {
+	os.Setenv("LISTEN_ADDRS", "127.0.0.1:5000,[::1]:5000")
+	defer os.Unsetenv("LISTEN_ADDRS")
+	c, _ := NewConfig()
+	suite.Equal("127.0.0.1:5000,[::1]:5000", c.ListenAddrs, "ListenAddrs should be equal to LISTEN_ADDRS value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestListenAddrsConfigError() {
+	os.Setenv("LISTEN_ADDRS", "127.0.0.1")
+	defer os.Unsetenv("LISTEN_ADDRS")
+	_, err := NewConfig()
+	suite.Error(err, "will throw an error if a listen address is invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestGetListenAddrsDefault() {
+	c, _ := NewConfig()
+	addrs, err := c.GetListenAddrs()
+	suite.Nil(err, "expects no error")
+	suite.Equal([]string{":5000"}, addrs, "expects to listen on all interfaces")
+}
+
+func (suite *HyperdriveTestSuite) TestGetListenAddrsIPv6Host() {
+	c := Config{Host: "::1", Port: 5000}
+	addrs, _ := c.GetListenAddrs()
+	suite.Equal([]string{"[::1]:5000"}, addrs, "expects IPv6 hosts to be bracketed")
+}
+
+func (suite *HyperdriveTestSuite) TestGetListenAddrsList() {
+	c := Config{ListenAddrs: "127.0.0.1:5000, [::1]:5001", Port: 5000}
+	addrs, _ := c.GetListenAddrs()
+	suite.Equal([]string{"127.0.0.1:5000", "[::1]:5001"}, addrs, "expects each address in the list")
+}
+
+func (suite *HyperdriveTestSuite) TestGetListenAddrsInvalidPort() {
+	c := Config{ListenAddrs: "127.0.0.1:http"}
+	_, err := c.GetListenAddrs()
+	suite.Error(err, "expects an error when the port is not a number")
+}
+
+func (suite *HyperdriveTestSuite) TestGetListenAddrsInvalidIPv6() {
+	c := Config{ListenAddrs: "[::zz]:5000"}
+	_, err := c.GetListenAddrs()
+	suite.Error(err, "expects an error when the IPv6 address is invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestGetAddr() {
+	c, _ := NewConfig()
+	suite.Equal(":5000", c.GetAddr(), "expects the first listen address")
+}
+
+func (suite *HyperdriveTestSuite) TestMaintenanceModeConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(false, c.MaintenanceMode, "MaintenanceMode should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestMaintenanceModeConfigFromEnv() {
+	os.Setenv("MAINTENANCE_MODE", "true")
+	defer os.Unsetenv("MAINTENANCE_MODE")
+	c, _ := NewConfig()
+	suite.Equal(true, c.MaintenanceMode, "MaintenanceMode should be equal to MAINTENANCE_MODE value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestMaintenanceRetryAfterConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(300, c.MaintenanceRetryAfter, "MaintenanceRetryAfter should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestMaintenanceBodyConfigFromEnv() {
+	os.Setenv("MAINTENANCE_BODY", `{"message":"back soon"}`)
+	defer os.Unsetenv("MAINTENANCE_BODY")
+	c, _ := NewConfig()
+	suite.Equal(`{"message":"back soon"}`, c.MaintenanceBody, "MaintenanceBody should be equal to MAINTENANCE_BODY value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestMaintenanceAllowConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("/health", c.MaintenanceAllow, "MaintenanceAllow should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestProxyProtocolConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(false, c.ProxyProtocol, "ProxyProtocol should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestProxyProtocolConfigFromEnv() {
+	os.Setenv("PROXY_PROTOCOL", "true")
+	defer os.Unsetenv("PROXY_PROTOCOL")
+	c, _ := NewConfig()
+	suite.Equal(true, c.ProxyProtocol, "ProxyProtocol should be equal to PROXY_PROTOCOL value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestProxyProtocolTimeoutConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(5*time.Second, c.ProxyProtocolTimeout, "ProxyProtocolTimeout should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestIdempotencyTTLConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(24*time.Hour, c.IdempotencyTTL, "IdempotencyTTL should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestIdempotencyTTLConfigFromEnv() {
+	os.Setenv("IDEMPOTENCY_TTL", "1h")
+	defer os.Unsetenv("IDEMPOTENCY_TTL")
+	c, _ := NewConfig()
+	suite.Equal(time.Hour, c.IdempotencyTTL, "IdempotencyTTL should be equal to IDEMPOTENCY_TTL value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestCacheTTLConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(time.Minute, c.CacheTTL, "CacheTTL should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestCacheTTLConfigFromEnv() {
+	os.Setenv("CACHE_TTL", "10s")
+	defer os.Unsetenv("CACHE_TTL")
+	c, _ := NewConfig()
+	suite.Equal(10*time.Second, c.CacheTTL, "CacheTTL should be equal to CACHE_TTL value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestRequestDecompressionMaxBytesConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(10485760, c.RequestDecompressionMaxBytes, "RequestDecompressionMaxBytes should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidRequestDecompressionMaxBytes() {
+	os.Setenv("REQUEST_DECOMPRESSION_MAX_BYTES", "0")
+	defer os.Unsetenv("REQUEST_DECOMPRESSION_MAX_BYTES")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when REQUEST_DECOMPRESSION_MAX_BYTES is not greater than 0")
+}
+
+func (suite *HyperdriveTestSuite) TestRawBodyMaxBytesConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(10485760, c.RawBodyMaxBytes, "RawBodyMaxBytes should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestRawBodyMaxBytesConfigFromEnv() {
+	os.Setenv("RAW_BODY_MAX_BYTES", "1024")
+	defer os.Unsetenv("RAW_BODY_MAX_BYTES")
+	c, _ := NewConfig()
+	suite.Equal(1024, c.RawBodyMaxBytes, "RawBodyMaxBytes should be equal to RAW_BODY_MAX_BYTES value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidRawBodyMaxBytes() {
+	os.Setenv("RAW_BODY_MAX_BYTES", "0")
+	defer os.Unsetenv("RAW_BODY_MAX_BYTES")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when RAW_BODY_MAX_BYTES is not positive")
+}
+
+func (suite *HyperdriveTestSuite) TestMultipartConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(33554432, c.MultipartMaxMemory, "MultipartMaxMemory should be equal to default value")
+	suite.Equal(0, c.MultipartMaxFileSize, "MultipartMaxFileSize should be equal to default value")
+	suite.Equal(0, c.MaxUploadBytes, "MaxUploadBytes should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestMaxUploadBytesConfigFromEnv() {
+	os.Setenv("MAX_UPLOAD_BYTES", "1048576")
+	defer os.Unsetenv("MAX_UPLOAD_BYTES")
+	c, _ := NewConfig()
+	suite.Equal(1048576, c.MaxUploadBytes, "MaxUploadBytes should be equal to env value")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidMaxUploadBytes() {
+	os.Setenv("MAX_UPLOAD_BYTES", "-1")
+	defer os.Unsetenv("MAX_UPLOAD_BYTES")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when MAX_UPLOAD_BYTES is negative")
+}
+
+func (suite *HyperdriveTestSuite) TestMultipartMaxFileSizeConfigFromEnv() {
+	os.Setenv("MULTIPART_MAX_FILE_SIZE", "1024")
+	defer os.Unsetenv("MULTIPART_MAX_FILE_SIZE")
+	c, _ := NewConfig()
+	suite.Equal(1024, c.MultipartMaxFileSize, "MultipartMaxFileSize should be equal to env value")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidMultipartMaxMemory() {
+	os.Setenv("MULTIPART_MAX_MEMORY", "0")
+	defer os.Unsetenv("MULTIPART_MAX_MEMORY")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when MULTIPART_MAX_MEMORY is not greater than 0")
+}
+
+func (suite *HyperdriveTestSuite) TestProtocolConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("1.2", c.TLSMinVersion, "TLSMinVersion should be equal to default value")
+	suite.Equal("", c.TLSCipherSuites, "TLSCipherSuites should be equal to default value")
+	suite.Equal("1.0", c.HTTPMinVersion, "HTTPMinVersion should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidTLSMinVersion() {
+	os.Setenv("TLS_MIN_VERSION", "1.4")
+	defer os.Unsetenv("TLS_MIN_VERSION")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when TLS_MIN_VERSION is unknown")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidTLSCipherSuites() {
+	os.Setenv("TLS_CIPHER_SUITES", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_RSA_WITH_RC4_128_SHA")
+	defer os.Unsetenv("TLS_CIPHER_SUITES")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when TLS_CIPHER_SUITES contains an insecure cipher suite")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidHTTPMinVersion() {
+	os.Setenv("HTTP_MIN_VERSION", "3")
+	defer os.Unsetenv("HTTP_MIN_VERSION")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when HTTP_MIN_VERSION is unknown")
+}
+
+func (suite *HyperdriveTestSuite) TestHTTPMinVersion2RequiresTLS() {
+	os.Setenv("HTTP_MIN_VERSION", "2")
+	defer os.Unsetenv("HTTP_MIN_VERSION")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when HTTP_MIN_VERSION is 2 without TLS")
+}
+
+func (suite *HyperdriveTestSuite) TestStreamDrainTimeoutConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(5*time.Second, c.StreamDrainTimeout, "StreamDrainTimeout should be 5s by default")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidStreamDrainTimeout() {
+	os.Setenv("STREAM_DRAIN_TIMEOUT", "-1s")
+	defer os.Unsetenv("STREAM_DRAIN_TIMEOUT")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when STREAM_DRAIN_TIMEOUT is negative")
+}
+
+func (suite *HyperdriveTestSuite) TestDrainOverlapConfigFromEnv() {
+	os.Setenv("DRAIN_OVERLAP", "10s")
+	defer os.Unsetenv("DRAIN_OVERLAP")
+	c, err := NewConfig()
+	suite.Nil(err, "expects no error")
+	suite.Equal(10*time.Second, c.DrainOverlap, "DrainOverlap should be equal to DRAIN_OVERLAP value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidDrainOverlap() {
+	os.Setenv("DRAIN_OVERLAP", "15s")
+	defer os.Unsetenv("DRAIN_OVERLAP")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when DRAIN_OVERLAP is not shorter than SHUTDOWN_TIMEOUT")
+}
+
+func (suite *HyperdriveTestSuite) TestIDFormatConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("hex", c.IDFormat, "IDFormat should be equal to default value")
+	suite.Equal(0, c.SnowflakeNode, "SnowflakeNode should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidIDFormat() {
+	os.Setenv("ID_FORMAT", "uuidv1")
+	defer os.Unsetenv("ID_FORMAT")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when ID_FORMAT is unknown")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidSnowflakeNode() {
+	os.Setenv("SNOWFLAKE_NODE", "1024")
+	defer os.Unsetenv("SNOWFLAKE_NODE")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when SNOWFLAKE_NODE is out of range")
+}
+
+func (suite *HyperdriveTestSuite) TestParamListSeparatorsConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(",", c.ParamListSeparators, "ParamListSeparators should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestParamListSeparatorsConfigFromEnv() {
+	os.Setenv("PARAM_LIST_SEPARATORS", ",|")
+	defer os.Unsetenv("PARAM_LIST_SEPARATORS")
+	c, _ := NewConfig()
+	suite.Equal(",|", c.ParamListSeparators, "ParamListSeparators should be equal to PARAM_LIST_SEPARATORS value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestParamPrecedenceConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("path,body,query", c.ParamPrecedence, "ParamPrecedence should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestParamPrecedenceConfigFromEnv() {
+	os.Setenv("PARAM_PRECEDENCE", "query,path,body")
+	defer os.Unsetenv("PARAM_PRECEDENCE")
+	c, _ := NewConfig()
+	suite.Equal("query,path,body", c.ParamPrecedence, "ParamPrecedence should be equal to PARAM_PRECEDENCE value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidParamPrecedence() {
+	os.Setenv("PARAM_PRECEDENCE", "path,query")
+	defer os.Unsetenv("PARAM_PRECEDENCE")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when PARAM_PRECEDENCE does not list every source")
+}
+
+func (suite *HyperdriveTestSuite) TestListPerPageConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(25, c.ListDefaultPerPage, "ListDefaultPerPage should be equal to default value")
+	suite.Equal(100, c.ListMaxPerPage, "ListMaxPerPage should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestListPerPageConfigFromEnv() {
+	os.Setenv("LIST_DEFAULT_PER_PAGE", "50")
+	defer os.Unsetenv("LIST_DEFAULT_PER_PAGE")
+	os.Setenv("LIST_MAX_PER_PAGE", "500")
+	defer os.Unsetenv("LIST_MAX_PER_PAGE")
+	c, _ := NewConfig()
+	suite.Equal(50, c.ListDefaultPerPage, "ListDefaultPerPage should be equal to LIST_DEFAULT_PER_PAGE value set via ENV var")
+	suite.Equal(500, c.ListMaxPerPage, "ListMaxPerPage should be equal to LIST_MAX_PER_PAGE value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidListMaxPerPage() {
+	os.Setenv("LIST_MAX_PER_PAGE", "10")
+	defer os.Unsetenv("LIST_MAX_PER_PAGE")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when LIST_MAX_PER_PAGE is less than LIST_DEFAULT_PER_PAGE")
+}
+
+func (suite *HyperdriveTestSuite) TestTLSEnabled() {
+	c := Config{TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}
+	suite.True(c.TLSEnabled(), "expects TLS to be enabled when both files are set")
+}
+
+func (suite *HyperdriveTestSuite) TestTLSEnabledFromDefault() {
+	c, _ := NewConfig()
+	suite.False(c.TLSEnabled(), "expects TLS to be disabled by default")
+}
+
+func (suite *HyperdriveTestSuite) TestTLSConfigError() {
+	os.Setenv("TLS_CERT_FILE", "cert.pem")
+	defer os.Unsetenv("TLS_CERT_FILE")
+	_, err := NewConfig()
+	suite.Error(err, "will throw an error if TLS_KEY_FILE is missing")
+}
+
+func (suite *HyperdriveTestSuite) TestHTTP3EnabledConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(false, c.HTTP3Enabled, "HTTP3Enabled should be equal to default value")
+}
+
+func (suite *HyperdriveTestSuite) TestHTTP3EnabledConfigError() {
+	os.Setenv("HTTP3_ENABLED", "true")
+	defer os.Unsetenv("HTTP3_ENABLED")
+	_, err := NewConfig()
+	suite.Error(err, "will throw an error if HTTP3_ENABLED is set without TLS")
+}
+
+func (suite *HyperdriveTestSuite) TestGetHTTP3Addr() {
+	c := Config{HTTP3Addr: ":443"}
+	suite.Equal(":443", c.GetHTTP3Addr(), "expects HTTP3Addr when set")
+}
+
+func (suite *HyperdriveTestSuite) TestGetHTTP3AddrDefault() {
+	c, _ := NewConfig()
+	suite.Equal(":5000", c.GetHTTP3Addr(), "expects the first listen address by default")
+}
+
+func (suite *HyperdriveTestSuite) TestRedirectsFileConfigFromEnv() {
+	os.Setenv("REDIRECTS_FILE", "redirects.json")
+	defer os.Unsetenv("REDIRECTS_FILE")
+	c, _ := NewConfig()
+	suite.Equal("redirects.json", c.RedirectsFile, "RedirectsFile should be equal to REDIRECTS_FILE value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestMiddlewareChainConfigFromEnv() {
+	os.Setenv("MIDDLEWARE_CHAIN", "recovery,logging")
+	defer os.Unsetenv("MIDDLEWARE_CHAIN")
+	c, _ := NewConfig()
+	suite.Equal("recovery,logging", c.MiddlewareChain, "MiddlewareChain should be equal to MIDDLEWARE_CHAIN value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestCanonicalConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("", c.CanonicalHost, "CanonicalHost should be empty by default")
+	suite.Equal(false, c.CanonicalLowercase, "CanonicalLowercase should be false by default")
+	suite.Equal("", c.CanonicalQueryParams, "CanonicalQueryParams should be empty by default")
+	suite.Equal("", c.TrustedProxies, "TrustedProxies should be empty by default")
+}
+
+func (suite *HyperdriveTestSuite) TestCanonicalConfigFromEnv() {
+	os.Setenv("CANONICAL_HOST", "api.example.com")
+	os.Setenv("CANONICAL_LOWERCASE", "true")
+	os.Setenv("CANONICAL_QUERY_PARAMS", "page")
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.0/8,192.168.1.1")
+	defer os.Unsetenv("CANONICAL_HOST")
+	defer os.Unsetenv("CANONICAL_LOWERCASE")
+	defer os.Unsetenv("CANONICAL_QUERY_PARAMS")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+	c, _ := NewConfig()
+	suite.Equal("api.example.com", c.CanonicalHost, "CanonicalHost should be equal to CANONICAL_HOST value set via ENV var")
+	suite.Equal(true, c.CanonicalLowercase, "CanonicalLowercase should be equal to CANONICAL_LOWERCASE value set via ENV var")
+	suite.Equal("page", c.CanonicalQueryParams, "CanonicalQueryParams should be equal to CANONICAL_QUERY_PARAMS value set via ENV var")
+	suite.Equal("10.0.0.0/8,192.168.1.1", c.TrustedProxies, "TrustedProxies should be equal to TRUSTED_PROXIES value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidTrustedProxies() {
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.0/33")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when TRUSTED_PROXIES is not a list of IPs and CIDRs")
+}
+
+func (suite *HyperdriveTestSuite) TestBasicAuthConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("", c.BasicAuthUsers, "BasicAuthUsers should be empty by default")
+	suite.Equal("hyperdrive", c.BasicAuthRealm, "BasicAuthRealm should be hyperdrive by default")
+}
+
+func (suite *HyperdriveTestSuite) TestBasicAuthConfigFromEnv() {
+	os.Setenv("BASIC_AUTH_USERS", "alice:s3cret")
+	os.Setenv("BASIC_AUTH_REALM", "admin")
+	defer os.Unsetenv("BASIC_AUTH_USERS")
+	defer os.Unsetenv("BASIC_AUTH_REALM")
+	c, _ := NewConfig()
+	suite.Equal("alice:s3cret", c.BasicAuthUsers, "BasicAuthUsers should be equal to BASIC_AUTH_USERS value set via ENV var")
+	suite.Equal("admin", c.BasicAuthRealm, "BasicAuthRealm should be equal to BASIC_AUTH_REALM value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestAdminConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(false, c.AdminEnabled, "AdminEnabled should be false by default")
+	suite.Equal("", c.AdminUsers, "AdminUsers should be empty by default")
+}
+
+func (suite *HyperdriveTestSuite) TestAdminConfigFromEnv() {
+	os.Setenv("ADMIN_ENABLED", "true")
+	os.Setenv("ADMIN_USERS", "ops:s3cret")
+	defer os.Unsetenv("ADMIN_ENABLED")
+	defer os.Unsetenv("ADMIN_USERS")
+	c, _ := NewConfig()
+	suite.Equal(true, c.AdminEnabled, "AdminEnabled should be equal to ADMIN_ENABLED value set via ENV var")
+	suite.Equal("ops:s3cret", c.AdminUsers, "AdminUsers should be equal to ADMIN_USERS value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestAdminEnabledConfigError() {
+	os.Setenv("ADMIN_ENABLED", "true")
+	defer os.Unsetenv("ADMIN_ENABLED")
+	_, err := NewConfig()
+	suite.Error(err, "will throw an error if ADMIN_ENABLED is set without ADMIN_USERS")
+}
+
+func (suite *HyperdriveTestSuite) TestAPIKeyConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("X-API-Key", c.APIKeyHeader, "APIKeyHeader should be X-API-Key by default")
+	suite.Equal("", c.APIKeyQueryParam, "APIKeyQueryParam should be empty by default")
+}
+
+func (suite *HyperdriveTestSuite) TestAPIKeyConfigFromEnv() {
+	os.Setenv("API_KEY_HEADER", "X-Token")
+	os.Setenv("API_KEY_QUERY_PARAM", "api_key")
+	defer os.Unsetenv("API_KEY_HEADER")
+	defer os.Unsetenv("API_KEY_QUERY_PARAM")
+	c, _ := NewConfig()
+	suite.Equal("X-Token", c.APIKeyHeader, "APIKeyHeader should be equal to API_KEY_HEADER value set via ENV var")
+	suite.Equal("api_key", c.APIKeyQueryParam, "APIKeyQueryParam should be equal to API_KEY_QUERY_PARAM value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestSQLCommentsConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(true, c.SQLComments, "SQLComments should be true by default")
+}
+
+func (suite *HyperdriveTestSuite) TestSQLCommentsConfigFromEnv() {
+	os.Setenv("SQL_COMMENTS", "false")
+	defer os.Unsetenv("SQL_COMMENTS")
+	c, _ := NewConfig()
+	suite.Equal(false, c.SQLComments, "SQLComments should be equal to SQL_COMMENTS value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestOAuth2ConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("", c.OAuth2IntrospectionURL, "OAuth2IntrospectionURL should be empty by default")
+	suite.Equal(time.Minute, c.OAuth2IntrospectionCacheTTL, "OAuth2IntrospectionCacheTTL should be 1m by default")
+}
+
+func (suite *HyperdriveTestSuite) TestOAuth2ConfigFromEnv() {
+	os.Setenv("OAUTH2_INTROSPECTION_URL", "https://auth.example.com/introspect")
+	os.Setenv("OAUTH2_CLIENT_ID", "api")
+	os.Setenv("OAUTH2_CLIENT_SECRET", "s3cret")
+	os.Setenv("OAUTH2_INTROSPECTION_CACHE_TTL", "30s")
+	defer os.Unsetenv("OAUTH2_INTROSPECTION_URL")
+	defer os.Unsetenv("OAUTH2_CLIENT_ID")
+	defer os.Unsetenv("OAUTH2_CLIENT_SECRET")
+	defer os.Unsetenv("OAUTH2_INTROSPECTION_CACHE_TTL")
+	c, _ := NewConfig()
+	suite.Equal("https://auth.example.com/introspect", c.OAuth2IntrospectionURL, "OAuth2IntrospectionURL should be equal to OAUTH2_INTROSPECTION_URL value set via ENV var")
+	suite.Equal("api", c.OAuth2ClientID, "OAuth2ClientID should be equal to OAUTH2_CLIENT_ID value set via ENV var")
+	suite.Equal("s3cret", c.OAuth2ClientSecret, "OAuth2ClientSecret should be equal to OAUTH2_CLIENT_SECRET value set via ENV var")
+	suite.Equal(30*time.Second, c.OAuth2IntrospectionCacheTTL, "OAuth2IntrospectionCacheTTL should be equal to OAUTH2_INTROSPECTION_CACHE_TTL value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestSignatureConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("X-Signature", c.SignatureHeader, "SignatureHeader should be X-Signature by default")
+	suite.Equal("X-Client-Id", c.SignatureClientHeader, "SignatureClientHeader should be X-Client-Id by default")
+	suite.Equal(5*time.Minute, c.SignatureTolerance, "SignatureTolerance should be 5m by default")
+}
+
+func (suite *HyperdriveTestSuite) TestSignatureConfigFromEnv() {
+	os.Setenv("SIGNATURE_HEADER", "X-Hub-Signature")
+	os.Setenv("SIGNATURE_CLIENT_HEADER", "X-Hub-Client")
+	os.Setenv("SIGNATURE_TOLERANCE", "1m")
+	defer os.Unsetenv("SIGNATURE_HEADER")
+	defer os.Unsetenv("SIGNATURE_CLIENT_HEADER")
+	defer os.Unsetenv("SIGNATURE_TOLERANCE")
+	c, _ := NewConfig()
+	suite.Equal("X-Hub-Signature", c.SignatureHeader, "SignatureHeader should be equal to SIGNATURE_HEADER value set via ENV var")
+	suite.Equal("X-Hub-Client", c.SignatureClientHeader, "SignatureClientHeader should be equal to SIGNATURE_CLIENT_HEADER value set via ENV var")
+	suite.Equal(time.Minute, c.SignatureTolerance, "SignatureTolerance should be equal to SIGNATURE_TOLERANCE value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestConsumerConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(1, c.ConsumerConcurrency, "ConsumerConcurrency should be 1 by default")
+	suite.Equal(3, c.ConsumerMaxAttempts, "ConsumerMaxAttempts should be 3 by default")
+	suite.Equal(time.Second, c.ConsumerRetryBackoff, "ConsumerRetryBackoff should be 1s by default")
+}
+
+func (suite *HyperdriveTestSuite) TestConsumerConfigFromEnv() {
+	os.Setenv("CONSUMER_CONCURRENCY", "8")
+	os.Setenv("CONSUMER_MAX_ATTEMPTS", "5")
+	os.Setenv("CONSUMER_RETRY_BACKOFF", "250ms")
+	defer os.Unsetenv("CONSUMER_CONCURRENCY")
+	defer os.Unsetenv("CONSUMER_MAX_ATTEMPTS")
+	defer os.Unsetenv("CONSUMER_RETRY_BACKOFF")
+	c, _ := NewConfig()
+	suite.Equal(8, c.ConsumerConcurrency, "ConsumerConcurrency should be equal to CONSUMER_CONCURRENCY value set via ENV var")
+	suite.Equal(5, c.ConsumerMaxAttempts, "ConsumerMaxAttempts should be equal to CONSUMER_MAX_ATTEMPTS value set via ENV var")
+	suite.Equal(250*time.Millisecond, c.ConsumerRetryBackoff, "ConsumerRetryBackoff should be equal to CONSUMER_RETRY_BACKOFF value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestSessionConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("", c.SessionSecret, "SessionSecret should be empty by default")
+	suite.Equal("", c.SessionEncryptionKey, "SessionEncryptionKey should be empty by default")
+	suite.Equal("hyperdrive_session", c.SessionCookieName, "SessionCookieName should be hyperdrive_session by default")
+	suite.Equal(true, c.SessionCookieSecure, "SessionCookieSecure should be true by default")
+	suite.Equal(24*time.Hour, c.SessionMaxAge, "SessionMaxAge should be 24h by default")
+}
+
+func (suite *HyperdriveTestSuite) TestSessionConfigFromEnv() {
+	os.Setenv("SESSION_SECRET", "s3cret")
+	os.Setenv("SESSION_ENCRYPTION_KEY", "k3y")
+	os.Setenv("SESSION_COOKIE_NAME", "sid")
+	os.Setenv("SESSION_COOKIE_SECURE", "false")
+	os.Setenv("SESSION_MAX_AGE", "1h")
+	defer os.Unsetenv("SESSION_SECRET")
+	defer os.Unsetenv("SESSION_ENCRYPTION_KEY")
+	defer os.Unsetenv("SESSION_COOKIE_NAME")
+	defer os.Unsetenv("SESSION_COOKIE_SECURE")
+	defer os.Unsetenv("SESSION_MAX_AGE")
+	c, _ := NewConfig()
+	suite.Equal("s3cret", c.SessionSecret, "SessionSecret should be equal to SESSION_SECRET value set via ENV var")
+	suite.Equal("k3y", c.SessionEncryptionKey, "SessionEncryptionKey should be equal to SESSION_ENCRYPTION_KEY value set via ENV var")
+	suite.Equal("sid", c.SessionCookieName, "SessionCookieName should be equal to SESSION_COOKIE_NAME value set via ENV var")
+	suite.Equal(false, c.SessionCookieSecure, "SessionCookieSecure should be equal to SESSION_COOKIE_SECURE value set via ENV var")
+	suite.Equal(time.Hour, c.SessionMaxAge, "SessionMaxAge should be equal to SESSION_MAX_AGE value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestLifecycleConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("auto", c.DeployMode, "DeployMode should be auto by default")
+	suite.Equal(15*time.Second, c.ShutdownTimeout, "ShutdownTimeout should be 15s by default")
+	suite.Equal("/ready", c.ReadyPath, "ReadyPath should be /ready by default")
+	suite.Equal(false, c.Serverless(), "Serverless should be false by default")
+}
+
+func (suite *HyperdriveTestSuite) TestLifecycleConfigFromEnv() {
+	os.Setenv("DEPLOY_MODE", "serverless")
+	os.Setenv("SHUTDOWN_TIMEOUT", "5s")
+	os.Setenv("READY_PATH", "/readyz")
+	defer os.Unsetenv("DEPLOY_MODE")
+	defer os.Unsetenv("SHUTDOWN_TIMEOUT")
+	defer os.Unsetenv("READY_PATH")
+	c, _ := NewConfig()
+	suite.Equal("serverless", c.DeployMode, "DeployMode should be equal to DEPLOY_MODE value set via ENV var")
+	suite.Equal(5*time.Second, c.ShutdownTimeout, "ShutdownTimeout should be equal to SHUTDOWN_TIMEOUT value set via ENV var")
+	suite.Equal("/readyz", c.ReadyPath, "ReadyPath should be equal to READY_PATH value set via ENV var")
+	suite.Equal(true, c.Serverless(), "Serverless should be true when DEPLOY_MODE is serverless")
+}
+
+func (suite *HyperdriveTestSuite) TestServerlessDetected() {
+	os.Setenv("K_SERVICE", "widgets")
+	defer os.Unsetenv("K_SERVICE")
+	c, _ := NewConfig()
+	suite.Equal(true, c.Serverless(), "Serverless should be true on Cloud Run")
+	c.DeployMode = "server"
+	suite.Equal(false, c.Serverless(), "Serverless should be false when DEPLOY_MODE is server")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidDeployMode() {
+	os.Setenv("DEPLOY_MODE", "lambda")
+	defer os.Unsetenv("DEPLOY_MODE")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when DEPLOY_MODE is invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestRateLimitConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(0, c.RateLimit, "RateLimit should be 0 by default")
+	suite.Equal(time.Minute, c.RateLimitWindow, "RateLimitWindow should be 1m by default")
+	suite.Equal("ip", c.RateLimitBy, "RateLimitBy should be ip by default")
+	suite.Equal("x-ratelimit,ratelimit", c.RateLimitHeaders, "RateLimitHeaders should be x-ratelimit,ratelimit by default")
+}
+
+func (suite *HyperdriveTestSuite) TestRateLimitConfigFromEnv() {
+	os.Setenv("RATE_LIMIT", "100")
+	os.Setenv("RATE_LIMIT_WINDOW", "1h")
+	os.Setenv("RATE_LIMIT_BY", "principal")
+	os.Setenv("RATE_LIMIT_HEADERS", "ratelimit")
+	defer os.Unsetenv("RATE_LIMIT")
+	defer os.Unsetenv("RATE_LIMIT_HEADERS")
+	defer os.Unsetenv("RATE_LIMIT_WINDOW")
+	defer os.Unsetenv("RATE_LIMIT_BY")
+	c, _ := NewConfig()
+	suite.Equal(100, c.RateLimit, "RateLimit should be equal to RATE_LIMIT value set via ENV var")
+	suite.Equal(time.Hour, c.RateLimitWindow, "RateLimitWindow should be equal to RATE_LIMIT_WINDOW value set via ENV var")
+	suite.Equal("principal", c.RateLimitBy, "RateLimitBy should be equal to RATE_LIMIT_BY value set via ENV var")
+	suite.Equal("ratelimit", c.RateLimitHeaders, "RateLimitHeaders should be equal to RATE_LIMIT_HEADERS value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidRateLimitHeaders() {
+	os.Setenv("RATE_LIMIT_HEADERS", "x-ratelimit,github")
+	defer os.Unsetenv("RATE_LIMIT_HEADERS")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when RATE_LIMIT_HEADERS is invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidRateLimitBy() {
+	os.Setenv("RATE_LIMIT_BY", "user")
+	defer os.Unsetenv("RATE_LIMIT_BY")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when RATE_LIMIT_BY is invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestQuotaConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(0, c.QuotaLimit, "QuotaLimit should be 0 by default")
+	suite.Equal("month", c.QuotaPeriod, "QuotaPeriod should be month by default")
+}
+
+func (suite *HyperdriveTestSuite) TestQuotaConfigFromEnv() {
+	os.Setenv("QUOTA_LIMIT", "10000")
+	os.Setenv("QUOTA_PERIOD", "day")
+	defer os.Unsetenv("QUOTA_LIMIT")
+	defer os.Unsetenv("QUOTA_PERIOD")
+	c, _ := NewConfig()
+	suite.Equal(10000, c.QuotaLimit, "QuotaLimit should be equal to QUOTA_LIMIT value set via ENV var")
+	suite.Equal("day", c.QuotaPeriod, "QuotaPeriod should be equal to QUOTA_PERIOD value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidQuotaPeriod() {
+	os.Setenv("QUOTA_PERIOD", "week")
+	defer os.Unsetenv("QUOTA_PERIOD")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when QUOTA_PERIOD is invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestConcurrencyConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(0, c.ConcurrencyLimit, "ConcurrencyLimit should be 0 by default")
+	suite.Equal(0, c.ConcurrencyRouteLimit, "ConcurrencyRouteLimit should be 0 by default")
+	suite.Equal(100, c.ConcurrencyQueue, "ConcurrencyQueue should be 100 by default")
+	suite.Equal(time.Second, c.ConcurrencyQueueTimeout, "ConcurrencyQueueTimeout should be 1s by default")
+}
+
+func (suite *HyperdriveTestSuite) TestConcurrencyConfigFromEnv() {
+	os.Setenv("CONCURRENCY_LIMIT", "50")
+	os.Setenv("CONCURRENCY_ROUTE_LIMIT", "10")
+	os.Setenv("CONCURRENCY_QUEUE", "20")
+	os.Setenv("CONCURRENCY_QUEUE_TIMEOUT", "250ms")
+	defer os.Unsetenv("CONCURRENCY_LIMIT")
+	defer os.Unsetenv("CONCURRENCY_ROUTE_LIMIT")
+	defer os.Unsetenv("CONCURRENCY_QUEUE")
+	defer os.Unsetenv("CONCURRENCY_QUEUE_TIMEOUT")
+	c, _ := NewConfig()
+	suite.Equal(50, c.ConcurrencyLimit, "ConcurrencyLimit should be equal to CONCURRENCY_LIMIT value set via ENV var")
+	suite.Equal(10, c.ConcurrencyRouteLimit, "ConcurrencyRouteLimit should be equal to CONCURRENCY_ROUTE_LIMIT value set via ENV var")
+	suite.Equal(20, c.ConcurrencyQueue, "ConcurrencyQueue should be equal to CONCURRENCY_QUEUE value set via ENV var")
+	suite.Equal(250*time.Millisecond, c.ConcurrencyQueueTimeout, "ConcurrencyQueueTimeout should be equal to CONCURRENCY_QUEUE_TIMEOUT value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestCompressionDictionaryConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("", c.CompressionDictionaryPath, "CompressionDictionaryPath should be empty by default")
+	suite.Equal(0.0, c.CompressionDictionarySampleRate, "CompressionDictionarySampleRate should be 0 by default")
+	suite.Equal(500, c.CompressionDictionarySamples, "CompressionDictionarySamples should be 500 by default")
+	suite.Equal(65536, c.CompressionDictionarySize, "CompressionDictionarySize should be 65536 by default")
+}
+
+func (suite *HyperdriveTestSuite) TestCompressionDictionaryConfigFromEnv() {
+	os.Setenv("COMPRESSION_DICTIONARY_PATH", "/dictionary")
+	os.Setenv("COMPRESSION_DICTIONARY_SAMPLE_RATE", "0.01")
+	os.Setenv("COMPRESSION_DICTIONARY_SAMPLES", "1000")
+	os.Setenv("COMPRESSION_DICTIONARY_SIZE", "32768")
+	defer os.Unsetenv("COMPRESSION_DICTIONARY_PATH")
+	defer os.Unsetenv("COMPRESSION_DICTIONARY_SAMPLE_RATE")
+	defer os.Unsetenv("COMPRESSION_DICTIONARY_SAMPLES")
+	defer os.Unsetenv("COMPRESSION_DICTIONARY_SIZE")
+	c, _ := NewConfig()
+	suite.Equal("/dictionary", c.CompressionDictionaryPath, "CompressionDictionaryPath should be equal to COMPRESSION_DICTIONARY_PATH value set via ENV var")
+	suite.Equal(0.01, c.CompressionDictionarySampleRate, "CompressionDictionarySampleRate should be equal to COMPRESSION_DICTIONARY_SAMPLE_RATE value set via ENV var")
+	suite.Equal(1000, c.CompressionDictionarySamples, "CompressionDictionarySamples should be equal to COMPRESSION_DICTIONARY_SAMPLES value set via ENV var")
+	suite.Equal(32768, c.CompressionDictionarySize, "CompressionDictionarySize should be equal to COMPRESSION_DICTIONARY_SIZE value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidCompressionDictionarySampleRate() {
+	os.Setenv("COMPRESSION_DICTIONARY_SAMPLE_RATE", "2")
+	defer os.Unsetenv("COMPRESSION_DICTIONARY_SAMPLE_RATE")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when COMPRESSION_DICTIONARY_SAMPLE_RATE is not between 0 and 1")
+}
+
+func (suite *HyperdriveTestSuite) TestClientFingerprintsConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(false, c.ClientFingerprints, "ClientFingerprints should be false by default")
+}
+
+func (suite *HyperdriveTestSuite) TestClientFingerprintsConfigFromEnv() {
+	os.Setenv("CLIENT_FINGERPRINTS", "true")
+	defer os.Unsetenv("CLIENT_FINGERPRINTS")
+	c, _ := NewConfig()
+	suite.Equal(true, c.ClientFingerprints, "ClientFingerprints should be equal to CLIENT_FINGERPRINTS value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestServerTimeoutsConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(5*time.Second, c.ReadHeaderTimeout, "ReadHeaderTimeout should be 5s by default")
+	suite.Equal(15*time.Second, c.ReadTimeout, "ReadTimeout should be 15s by default")
+	suite.Equal(15*time.Second, c.WriteTimeout, "WriteTimeout should be 15s by default")
+	suite.Equal(time.Minute, c.IdleTimeout, "IdleTimeout should be 60s by default")
+}
+
+func (suite *HyperdriveTestSuite) TestServerTimeoutsConfigFromEnv() {
+	os.Setenv("READ_HEADER_TIMEOUT", "2s")
+	os.Setenv("READ_TIMEOUT", "10s")
+	os.Setenv("WRITE_TIMEOUT", "30s")
+	os.Setenv("IDLE_TIMEOUT", "2m")
+	defer os.Unsetenv("READ_HEADER_TIMEOUT")
+	defer os.Unsetenv("READ_TIMEOUT")
+	defer os.Unsetenv("WRITE_TIMEOUT")
+	defer os.Unsetenv("IDLE_TIMEOUT")
+	c, _ := NewConfig()
+	suite.Equal(2*time.Second, c.ReadHeaderTimeout, "ReadHeaderTimeout should be equal to READ_HEADER_TIMEOUT value set via ENV var")
+	suite.Equal(10*time.Second, c.ReadTimeout, "ReadTimeout should be equal to READ_TIMEOUT value set via ENV var")
+	suite.Equal(30*time.Second, c.WriteTimeout, "WriteTimeout should be equal to WRITE_TIMEOUT value set via ENV var")
+	suite.Equal(2*time.Minute, c.IdleTimeout, "IdleTimeout should be equal to IDLE_TIMEOUT value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidServerTimeout() {
+	os.Setenv("READ_HEADER_TIMEOUT", "-1s")
+	defer os.Unsetenv("READ_HEADER_TIMEOUT")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when READ_HEADER_TIMEOUT is negative")
+}
+
+func (suite *HyperdriveTestSuite) TestUserAgentConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("", c.UserAgentBlock, "UserAgentBlock should be empty by default")
+	suite.Equal("Googlebot,bingbot,DuckDuckBot,Applebot,YandexBot,Baiduspider", c.UserAgentAllow, "UserAgentAllow should be the major search engine crawlers by default")
+	suite.Equal("block", c.UserAgentAction, "UserAgentAction should be block by default")
+}
+
+func (suite *HyperdriveTestSuite) TestUserAgentConfigFromEnv() {
+	os.Setenv("USER_AGENT_BLOCK", "curl,^$")
+	os.Setenv("USER_AGENT_ALLOW", "Googlebot")
+	os.Setenv("USER_AGENT_ACTION", "tag")
+	defer os.Unsetenv("USER_AGENT_BLOCK")
+	defer os.Unsetenv("USER_AGENT_ALLOW")
+	defer os.Unsetenv("USER_AGENT_ACTION")
+	c, _ := NewConfig()
+	suite.Equal("curl,^$", c.UserAgentBlock, "UserAgentBlock should be equal to USER_AGENT_BLOCK value set via ENV var")
+	suite.Equal("Googlebot", c.UserAgentAllow, "UserAgentAllow should be equal to USER_AGENT_ALLOW value set via ENV var")
+	suite.Equal("tag", c.UserAgentAction, "UserAgentAction should be equal to USER_AGENT_ACTION value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidUserAgentBlock() {
+	os.Setenv("USER_AGENT_BLOCK", "curl(")
+	defer os.Unsetenv("USER_AGENT_BLOCK")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when USER_AGENT_BLOCK has an invalid pattern")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidUserAgentAction() {
+	os.Setenv("USER_AGENT_ACTION", "ignore")
+	defer os.Unsetenv("USER_AGENT_ACTION")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when USER_AGENT_ACTION is invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestMirrorConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("", c.MirrorURL, "MirrorURL should be empty by default")
+	suite.Equal(0.0, c.MirrorPercent, "MirrorPercent should be 0 by default")
+	suite.Equal(5*time.Second, c.MirrorTimeout, "MirrorTimeout should be 5s by default")
+	suite.Equal(1048576, c.MirrorMaxBody, "MirrorMaxBody should be 1MB by default")
+	suite.False(c.MirrorCredentials, "MirrorCredentials should be false by default")
+}
+
+func (suite *HyperdriveTestSuite) TestMirrorConfigFromEnv() {
+	os.Setenv("MIRROR_URL", "http://shadow.internal:8080")
+	os.Setenv("MIRROR_PERCENT", "2.5")
+	os.Setenv("MIRROR_TIMEOUT", "1s")
+	os.Setenv("MIRROR_MAX_BODY", "1024")
+	defer os.Unsetenv("MIRROR_URL")
+	defer os.Unsetenv("MIRROR_PERCENT")
+	defer os.Unsetenv("MIRROR_TIMEOUT")
+	defer os.Unsetenv("MIRROR_MAX_BODY")
+	c, _ := NewConfig()
+	suite.Equal("http://shadow.internal:8080", c.MirrorURL, "MirrorURL should be equal to MIRROR_URL value set via ENV var")
+	suite.Equal(2.5, c.MirrorPercent, "MirrorPercent should be equal to MIRROR_PERCENT value set via ENV var")
+	suite.Equal(time.Second, c.MirrorTimeout, "MirrorTimeout should be equal to MIRROR_TIMEOUT value set via ENV var")
+	suite.Equal(1024, c.MirrorMaxBody, "MirrorMaxBody should be equal to MIRROR_MAX_BODY value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidMirrorURL() {
+	os.Setenv("MIRROR_URL", "shadow.internal")
+	defer os.Unsetenv("MIRROR_URL")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when MIRROR_URL is not an absolute URL")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidMirrorPercent() {
+	os.Setenv("MIRROR_PERCENT", "150")
+	defer os.Unsetenv("MIRROR_PERCENT")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when MIRROR_PERCENT is not between 0 and 100")
+}
+
+func (suite *HyperdriveTestSuite) TestCursorConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("", c.CursorSecret, "CursorSecret should be empty by default")
+	suite.Equal("", c.CursorEncryptionKey, "CursorEncryptionKey should be empty by default")
+	suite.Equal(24*time.Hour, c.CursorTTL, "CursorTTL should be 24h by default")
+}
+
+func (suite *HyperdriveTestSuite) TestCursorConfigFromEnv() {
+	os.Setenv("CURSOR_SECRET", "secret")
+	os.Setenv("CURSOR_ENCRYPTION_KEY", "key")
+	os.Setenv("CURSOR_TTL", "1h")
+	defer os.Unsetenv("CURSOR_SECRET")
+	defer os.Unsetenv("CURSOR_ENCRYPTION_KEY")
+	defer os.Unsetenv("CURSOR_TTL")
+	c, _ := NewConfig()
+	suite.Equal("secret", c.CursorSecret, "CursorSecret should be equal to CURSOR_SECRET value set via ENV var")
+	suite.Equal("key", c.CursorEncryptionKey, "CursorEncryptionKey should be equal to CURSOR_ENCRYPTION_KEY value set via ENV var")
+	suite.Equal(time.Hour, c.CursorTTL, "CursorTTL should be equal to CURSOR_TTL value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestFaultConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(false, c.FaultInjection, "FaultInjection should be false by default")
+	suite.Equal(0.0, c.FaultPercent, "FaultPercent should be 0 by default")
+	suite.Equal(time.Duration(0), c.FaultDelay, "FaultDelay should be 0 by default")
+	suite.Equal(503, c.FaultStatus, "FaultStatus should be 503 by default")
+}
+
+func (suite *HyperdriveTestSuite) TestFaultConfigFromEnv() {
+	os.Setenv("FAULT_INJECTION", "true")
+	os.Setenv("FAULT_PERCENT", "10")
+	os.Setenv("FAULT_DELAY", "250ms")
+	os.Setenv("FAULT_STATUS", "500")
+	os.Setenv("FAULT_PATHS", "/users,/orders")
+	os.Setenv("FAULT_HEADER", "X-Chaos")
+	defer os.Unsetenv("FAULT_INJECTION")
+	defer os.Unsetenv("FAULT_PERCENT")
+	defer os.Unsetenv("FAULT_DELAY")
+	defer os.Unsetenv("FAULT_STATUS")
+	defer os.Unsetenv("FAULT_PATHS")
+	defer os.Unsetenv("FAULT_HEADER")
+	c, _ := NewConfig()
+	suite.Equal(true, c.FaultInjection, "FaultInjection should be equal to FAULT_INJECTION value set via ENV var")
+	suite.Equal(10.0, c.FaultPercent, "FaultPercent should be equal to FAULT_PERCENT value set via ENV var")
+	suite.Equal(250*time.Millisecond, c.FaultDelay, "FaultDelay should be equal to FAULT_DELAY value set via ENV var")
+	suite.Equal(500, c.FaultStatus, "FaultStatus should be equal to FAULT_STATUS value set via ENV var")
+	suite.Equal("/users,/orders", c.FaultPaths, "FaultPaths should be equal to FAULT_PATHS value set via ENV var")
+	suite.Equal("X-Chaos", c.FaultHeader, "FaultHeader should be equal to FAULT_HEADER value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidFaultPercent() {
+	os.Setenv("FAULT_PERCENT", "-1")
+	defer os.Unsetenv("FAULT_PERCENT")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when FAULT_PERCENT is not between 0 and 100")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidFaultStatus() {
+	os.Setenv("FAULT_STATUS", "200")
+	defer os.Unsetenv("FAULT_STATUS")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when FAULT_STATUS is not an error status")
+}
+
+func (suite *HyperdriveTestSuite) TestMetricsConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(false, c.MetricsBucketPaths, "MetricsBucketPaths should be false by default")
+	suite.Equal("", c.MetricsRouteAllow, "MetricsRouteAllow should be empty by default")
+	suite.Equal("", c.MetricsRouteDeny, "MetricsRouteDeny should be empty by default")
+	suite.Equal(500, c.MetricsMaxRoutes, "MetricsMaxRoutes should be 500 by default")
+}
+
+func (suite *HyperdriveTestSuite) TestMetricsConfigFromEnv() {
+	os.Setenv("METRICS_BUCKET_PATHS", "true")
+	os.Setenv("METRICS_ROUTE_ALLOW", "/users/*")
+	os.Setenv("METRICS_ROUTE_DENY", "/admin/*")
+	os.Setenv("METRICS_MAX_ROUTES", "100")
+	defer os.Unsetenv("METRICS_BUCKET_PATHS")
+	defer os.Unsetenv("METRICS_ROUTE_ALLOW")
+	defer os.Unsetenv("METRICS_ROUTE_DENY")
+	defer os.Unsetenv("METRICS_MAX_ROUTES")
+	c, _ := NewConfig()
+	suite.Equal(true, c.MetricsBucketPaths, "MetricsBucketPaths should be equal to METRICS_BUCKET_PATHS value set via ENV var")
+	suite.Equal("/users/*", c.MetricsRouteAllow, "MetricsRouteAllow should be equal to METRICS_ROUTE_ALLOW value set via ENV var")
+	suite.Equal("/admin/*", c.MetricsRouteDeny, "MetricsRouteDeny should be equal to METRICS_ROUTE_DENY value set via ENV var")
+	suite.Equal(100, c.MetricsMaxRoutes, "MetricsMaxRoutes should be equal to METRICS_MAX_ROUTES value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestSmokeConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("", c.SmokeTarget, "SmokeTarget should be empty by default")
+	suite.Equal(10*time.Second, c.SmokeTimeout, "SmokeTimeout should be 10s by default")
+}
+
+func (suite *HyperdriveTestSuite) TestSmokeConfigFromEnv() {
+	os.Setenv("SMOKE_TARGET", "https://api.example.com")
+	os.Setenv("SMOKE_TIMEOUT", "2s")
+	defer os.Unsetenv("SMOKE_TARGET")
+	defer os.Unsetenv("SMOKE_TIMEOUT")
+	c, _ := NewConfig()
+	suite.Equal("https://api.example.com", c.SmokeTarget, "SmokeTarget should be equal to SMOKE_TARGET value set via ENV var")
+	suite.Equal(2*time.Second, c.SmokeTimeout, "SmokeTimeout should be equal to SMOKE_TIMEOUT value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestJSONCanonicalConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(false, c.JSONCanonical, "JSONCanonical should be false by default")
+}
+
+func (suite *HyperdriveTestSuite) TestJSONCanonicalConfigFromEnv() {
+	os.Setenv("JSON_CANONICAL", "true")
+	defer os.Unsetenv("JSON_CANONICAL")
+	c, _ := NewConfig()
+	suite.Equal(true, c.JSONCanonical, "JSONCanonical should be equal to JSON_CANONICAL value set via ENV var")
+}
+
+func (suite *HyperdriveTestSuite) TestListLimitConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(0, c.ListMaxItems, "ListMaxItems should be 0 by default")
+	suite.Equal("paginate", c.ListLimitAction, "ListLimitAction should be paginate by default")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidListLimitAction() {
+	os.Setenv("LIST_LIMIT_ACTION", "truncate")
+	defer os.Unsetenv("LIST_LIMIT_ACTION")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when LIST_LIMIT_ACTION is invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestPaginationStyleFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal("headers", c.PaginationStyle, "PaginationStyle should be headers by default")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidPaginationStyle() {
+	os.Setenv("PAGINATION_STYLE", "body")
+	defer os.Unsetenv("PAGINATION_STYLE")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when PAGINATION_STYLE is invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestJSONPrettyFromDefault() {
+	c, _ := NewConfig()
+	suite.False(c.JSONPretty, "JSONPretty should be false by default")
+}
+
+func (suite *HyperdriveTestSuite) TestResponseEnvelopeFromDefault() {
+	c, _ := NewConfig()
+	suite.False(c.ResponseEnvelope, "ResponseEnvelope should be false by default")
+}
+
+func (suite *HyperdriveTestSuite) TestRenderETagsFromDefault() {
+	c, _ := NewConfig()
+	suite.False(c.RenderETags, "RenderETags should be false by default")
+}
+
+func (suite *HyperdriveTestSuite) TestCSVEscapeFormulasFromDefault() {
+	c, _ := NewConfig()
+	suite.True(c.CSVEscapeFormulas, "CSVEscapeFormulas should be true by default")
+}
+
+func (suite *HyperdriveTestSuite) TestSSEKeepAliveFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(15*time.Second, c.SSEKeepAlive, "SSEKeepAlive should be 15s by default")
+}
+
+func (suite *HyperdriveTestSuite) TestWebSocketConfigFromDefault() {
+	c, _ := NewConfig()
+	suite.Equal(30*time.Second, c.WebSocketPingInterval, "WebSocketPingInterval should be 30s by default")
+	suite.Equal(1048576, c.WebSocketMaxMessageBytes, "WebSocketMaxMessageBytes should be 1MiB by default")
+}
+
+func (suite *HyperdriveTestSuite) TestInvalidWebSocketMaxMessageBytes() {
+	os.Setenv("WEBSOCKET_MAX_MESSAGE_BYTES", "0")
+	defer os.Unsetenv("WEBSOCKET_MAX_MESSAGE_BYTES")
+	_, err := NewConfig()
+	suite.Error(err, "expects an error when WEBSOCKET_MAX_MESSAGE_BYTES is not positive")
+}
@@ -0,0 +1,130 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ConfigChange is a setting, by environment variable, whose value differs
+// from the previous snapshot. Old is empty for settings which are new, and
+// New for settings which were removed.
+type ConfigChange struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// DiffConfig returns the settings whose values differ between the old and
+// new snapshots, sorted by name.
+func DiffConfig(old map[string]string, new map[string]string) []ConfigChange {
+	var changes []ConfigChange
+	for name, value := range new {
+		if previous, ok := old[name]; !ok || previous != value {
+			changes = append(changes, ConfigChange{Name: name, Old: previous, New: value})
+		}
+	}
+	for name, value := range old {
+		if _, ok := new[name]; !ok {
+			changes = append(changes, ConfigChange{Name: name, Old: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// ConfigSnapshotStore interface is satisfied by anything that can persist a
+// snapshot of the configuration between deploys, e.g. a file or a database.
+// LoadConfigSnapshot returns nil, without an error, when no snapshot has been
+// saved yet. See FileConfigSnapshotStore.
+type ConfigSnapshotStore interface {
+	LoadConfigSnapshot() (map[string]string, error)
+	SaveConfigSnapshot(snapshot map[string]string) error
+}
+
+// FileConfigSnapshotStore is a ConfigSnapshotStore holding the snapshot in a
+// json file, which is used when CONFIG_SNAPSHOT_FILE is set.
+type FileConfigSnapshotStore struct {
+	Path string
+}
+
+// LoadConfigSnapshot reads the snapshot from the file, returning nil if it
+// does not exist.
+func (s FileConfigSnapshotStore) LoadConfigSnapshot() (map[string]string, error) {
+	b, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshot map[string]string
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		return nil, fmt.Errorf("could not parse config snapshot file %s: %v", s.Path, err)
+	}
+	return snapshot, nil
+}
+
+// SaveConfigSnapshot replaces the file with the given snapshot.
+func (s FileConfigSnapshotStore) SaveConfigSnapshot(snapshot map[string]string) error {
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path, b, 0600)
+}
+
+// configSnapshots holds the ConfigSnapshotStore of an API, shared by every
+// copy of the API.
+type configSnapshots struct {
+	sync.RWMutex
+	store ConfigSnapshotStore
+}
+
+func (c *configSnapshots) get() ConfigSnapshotStore {
+	c.RLock()
+	defer c.RUnlock()
+	if c.store == nil && conf.ConfigSnapshotFile != "" {
+		return FileConfigSnapshotStore{Path: conf.ConfigSnapshotFile}
+	}
+	return c.store
+}
+
+// SetConfigSnapshotStore sets the ConfigSnapshotStore used by Start to log
+// the settings which changed since the previous deploy. It overrides
+// CONFIG_SNAPSHOT_FILE.
+func (api *API) SetConfigSnapshotStore(store ConfigSnapshotStore) {
+	api.configSnapshots.Lock()
+	defer api.configSnapshots.Unlock()
+	api.configSnapshots.store = store
+}
+
+// logConfigChanges compares the configuration with the snapshot saved by the
+// previous deploy, logs the settings which changed, as json, so behaviour
+// changes can be correlated with config changes, and saves a new snapshot.
+// Secrets (see redactedConfig) are only compared as set or unset, so they
+// are never persisted. It does nothing unless a ConfigSnapshotStore is set.
+func (api *API) logConfigChanges() {
+	store := api.configSnapshots.get()
+	if store == nil {
+		return
+	}
+	snapshot := redactedConfig(conf)
+	previous, err := store.LoadConfigSnapshot()
+	if err != nil {
+		log.Printf("Could not load config snapshot: %v", err)
+	} else if previous != nil {
+		if changes := DiffConfig(previous, snapshot); len(changes) > 0 {
+			b, _ := json.Marshal(changes)
+			log.Printf("Config changed since the previous snapshot: %s", b)
+		} else {
+			log.Printf("Config unchanged since the previous snapshot")
+		}
+	}
+	if err := store.SaveConfigSnapshot(snapshot); err != nil {
+		log.Printf("Could not save config snapshot: %v", err)
+	}
+}
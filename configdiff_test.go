@@ -0,0 +1,66 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+type memoryConfigSnapshotStore struct {
+	snapshot map[string]string
+}
+
+func (s *memoryConfigSnapshotStore) LoadConfigSnapshot() (map[string]string, error) {
+	return s.snapshot, nil
+}
+
+func (s *memoryConfigSnapshotStore) SaveConfigSnapshot(snapshot map[string]string) error {
+	s.snapshot = snapshot
+	return nil
+}
+
+func (suite *HyperdriveTestSuite) TestDiffConfig() {
+	changes := DiffConfig(
+		map[string]string{"PORT": "5000", "ENV": "development", "OLD_SETTING": "true"},
+		map[string]string{"PORT": "8080", "ENV": "development", "NEW_SETTING": "1s"},
+	)
+	suite.Equal([]ConfigChange{
+		{Name: "NEW_SETTING", New: "1s"},
+		{Name: "OLD_SETTING", Old: "true"},
+		{Name: "PORT", Old: "5000", New: "8080"},
+	}, changes, "expects the changed, added, and removed settings, sorted by name")
+}
+
+func (suite *HyperdriveTestSuite) TestFileConfigSnapshotStore() {
+	f, _ := ioutil.TempFile("", "config")
+	f.Close()
+	os.Remove(f.Name())
+	defer os.Remove(f.Name())
+	store := FileConfigSnapshotStore{Path: f.Name()}
+	snapshot, err := store.LoadConfigSnapshot()
+	suite.Nil(err, "expects no error when the file does not exist")
+	suite.Nil(snapshot, "expects no snapshot when the file does not exist")
+	suite.Nil(store.SaveConfigSnapshot(map[string]string{"PORT": "5000"}), "expects the snapshot to be saved")
+	snapshot, err = store.LoadConfigSnapshot()
+	suite.Nil(err, "expects no error")
+	suite.Equal(map[string]string{"PORT": "5000"}, snapshot, "expects the saved snapshot")
+}
+
+func (suite *HyperdriveTestSuite) TestLogConfigChanges() {
+	defer func(c Config) { conf = c }(conf)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	store := &memoryConfigSnapshotStore{}
+	suite.TestAPI.SetConfigSnapshotStore(store)
+	suite.TestAPI.logConfigChanges()
+	suite.Equal(redactedConfig(conf), store.snapshot, "expects a snapshot to be saved")
+	suite.Empty(buf.String(), "expects nothing to be logged without a previous snapshot")
+	conf.Port = 8080
+	conf.BasicAuthUsers = "admin:secret"
+	suite.TestAPI.logConfigChanges()
+	suite.Contains(buf.String(), `{"name":"PORT","old":"5000","new":"8080"}`, "expects the changed setting to be logged")
+	suite.Contains(buf.String(), `{"name":"BASIC_AUTH_USERS","old":"","new":"[redacted]"}`, "expects secrets to be redacted")
+	suite.NotContains(buf.String(), "secret", "expects secrets not to be logged")
+}
@@ -0,0 +1,375 @@
+package hyperdrive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Message is a message received from a queue, by a QueueAdapter. Attempts is
+// the number of times the message has been delivered, if the queue reports
+// it. Raw holds the adapter's own representation of the message, which it
+// needs to Ack or Nack it.
+type Message struct {
+	ID         string
+	Queue      string
+	Body       []byte
+	Attributes map[string]string
+	Attempts   int
+	Raw        interface{}
+}
+
+// QueueAdapter interface is satisfied by anything that can receive messages
+// from a queue, e.g. SQS, NATS, or Kafka. Receive blocks until at least one
+// message is available, or the context is done. Ack is called once a message
+// has been handled, and Nack if it could not be, so the queue can deliver it
+// again. See MemoryQueue, and the hyperdrive/sqs, hyperdrive/nats, and
+// hyperdrive/kafka packages for implementations.
+type QueueAdapter interface {
+	Receive(ctx context.Context) ([]*Message, error)
+	Ack(ctx context.Context, m *Message) error
+	Nack(ctx context.Context, m *Message) error
+}
+
+// MessageHandler interface is satisfied by anything that can handle messages
+// received from a queue. Returning an error causes the message to be
+// delivered again.
+type MessageHandler interface {
+	HandleMessage(ctx context.Context, m *Message) error
+}
+
+// MessageHandlerFunc is an adapter to allow the use of ordinary functions as
+// a MessageHandler.
+type MessageHandlerFunc func(ctx context.Context, m *Message) error
+
+// HandleMessage calls f(ctx, m).
+func (f MessageHandlerFunc) HandleMessage(ctx context.Context, m *Message) error {
+	return f(ctx, m)
+}
+
+// MessageMiddleware wraps a MessageHandler, in the same way Middleware wraps
+// an http.Handler.
+type MessageMiddleware func(MessageHandler) MessageHandler
+
+// DefaultMessageMiddleware returns the MessageMiddleware used by AddConsumer,
+// outermost first: MessageMetricsMiddleware, MessageLoggingMiddleware,
+// MessageRetryMiddleware, and MessageRecoveryMiddleware.
+func (api *API) DefaultMessageMiddleware() []MessageMiddleware {
+	return []MessageMiddleware{
+		api.MessageMetricsMiddleware,
+		api.MessageLoggingMiddleware,
+		api.MessageRetryMiddleware,
+		api.MessageRecoveryMiddleware,
+	}
+}
+
+type consumer struct {
+	queue       string
+	adapter     QueueAdapter
+	handler     MessageHandler
+	concurrency int
+}
+
+type consumers struct {
+	sync.Mutex
+	consumers []*consumer
+}
+
+// AddConsumer registers a MessageHandler for the messages received by the
+// given QueueAdapter, wrapped in the given MessageMiddleware, or the
+// DefaultMessageMiddleware if there are none. Consumers are run by
+// RunConsumers, which Start calls for you, handling up to
+// CONSUMER_CONCURRENCY (default: 1) messages from each queue at a time.
+func (api *API) AddConsumer(queue string, adapter QueueAdapter, h MessageHandler, mw ...MessageMiddleware) {
+	if len(mw) == 0 {
+		mw = api.DefaultMessageMiddleware()
+	}
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	concurrency := conf.ConsumerConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	api.consumers.Lock()
+	defer api.consumers.Unlock()
+	api.consumers.consumers = append(api.consumers.consumers, &consumer{queue: queue, adapter: adapter, handler: h, concurrency: concurrency})
+	log.Printf("Added hyperdriven Consumer: %s", queue)
+}
+
+// HasConsumers returns true if any consumers have been added with
+// AddConsumer.
+func (api *API) HasConsumers() bool {
+	api.consumers.Lock()
+	defer api.consumers.Unlock()
+	return len(api.consumers.consumers) > 0
+}
+
+// RunConsumers runs every consumer added with AddConsumer, until the context
+// is done, and every message being handled has been acked or nacked. Errors
+// receiving messages are logged, and retried after a second.
+func (api *API) RunConsumers(ctx context.Context) {
+	api.runConsumers(ctx, ctx)
+}
+
+// runConsumers runs every consumer, receiving messages until the receive
+// context is done, and handling them with the handle context.
+func (api *API) runConsumers(receive, handle context.Context) {
+	api.consumers.Lock()
+	list := append([]*consumer(nil), api.consumers.consumers...)
+	api.consumers.Unlock()
+	var wg sync.WaitGroup
+	for _, c := range list {
+		wg.Add(1)
+		go func(c *consumer) {
+			defer wg.Done()
+			c.run(receive, handle)
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (c *consumer) run(ctx, handle context.Context) {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, c.concurrency)
+	)
+	defer wg.Wait()
+	for ctx.Err() == nil {
+		messages, err := c.adapter.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Consumer %s could not receive messages: %v", c.queue, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for _, m := range messages {
+			if m.Queue == "" {
+				m.Queue = c.queue
+			}
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(m *Message) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				c.handle(handle, m)
+			}(m)
+		}
+	}
+}
+
+// handle handles a single message, acking or nacking it with a context which
+// is not cancelled, so the outcome is not lost during shutdown.
+func (c *consumer) handle(ctx context.Context, m *Message) {
+	if err := c.handler.HandleMessage(ctx, m); err != nil {
+		if err := c.adapter.Nack(context.Background(), m); err != nil {
+			log.Printf("Consumer %s could not nack message %s: %v", c.queue, m.ID, err)
+		}
+		return
+	}
+	if err := c.adapter.Ack(context.Background(), m); err != nil {
+		log.Printf("Consumer %s could not ack message %s: %v", c.queue, m.ID, err)
+	}
+}
+
+// MessageRecoveryMiddleware recovers from panics in the wrapped
+// MessageHandler, returning them as errors, so the message is delivered
+// again. Panics are logged, and forwarded to every PanicReporter registered
+// with AddPanicReporter, without a Request.
+func (api *API) MessageRecoveryMiddleware(h MessageHandler) MessageHandler {
+	return MessageHandlerFunc(func(ctx context.Context, m *Message) (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				p := Panic{Value: v, Stack: debug.Stack()}
+				log.Println(v)
+				if conf.Env != "production" {
+					log.Printf("%s", p.Stack)
+				}
+				api.panicReporters.report(p)
+				err = fmt.Errorf("panic: %v", v)
+			}
+		}()
+		return h.HandleMessage(ctx, m)
+	})
+}
+
+// MessageRetryMiddleware retries messages that fail, up to
+// CONSUMER_MAX_ATTEMPTS (default: 3) times in all, waiting
+// CONSUMER_RETRY_BACKOFF (default: 1s) before the first retry, and twice as
+// long before each one after that. If every attempt fails, the last error is
+// returned, so the message is nacked.
+func (api *API) MessageRetryMiddleware(h MessageHandler) MessageHandler {
+	return MessageHandlerFunc(func(ctx context.Context, m *Message) error {
+		backoff := conf.ConsumerRetryBackoff
+		err := h.HandleMessage(ctx, m)
+		for attempt := 1; err != nil && attempt < conf.ConsumerMaxAttempts; attempt++ {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			err = h.HandleMessage(ctx, m)
+		}
+		return err
+	})
+}
+
+// MessageLogEntry is the structured representation of a handled message,
+// written by MessageLoggingMiddleware when LOG_FORMAT is set to json.
+type MessageLogEntry struct {
+	Time     string  `json:"time"`
+	Queue    string  `json:"queue"`
+	ID       string  `json:"id"`
+	Attempts int     `json:"attempts,omitempty"`
+	Bytes    int     `json:"bytes"`
+	Duration float64 `json:"duration_ms"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// MessageLoggingMiddleware logs every message once it has been handled, to
+// the same output as LoggingMiddleware, as json when LOG_FORMAT is set to
+// json.
+func (api *API) MessageLoggingMiddleware(h MessageHandler) MessageHandler {
+	return MessageHandlerFunc(func(ctx context.Context, m *Message) error {
+		start := time.Now()
+		err := h.HandleMessage(ctx, m)
+		entry := MessageLogEntry{
+			Time:     start.Format(time.RFC3339),
+			Queue:    m.Queue,
+			ID:       m.ID,
+			Attempts: m.Attempts,
+			Bytes:    len(m.Body),
+			Duration: float64(time.Since(start)) / float64(time.Millisecond),
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		if conf.LogFormat == "json" {
+			json.NewEncoder(accessLogOutput).Encode(entry)
+			return err
+		}
+		if entry.Error == "" {
+			entry.Error = "-"
+		}
+		fmt.Fprintf(accessLogOutput, "[%s] queue=%s id=%s bytes=%d duration_ms=%.3f error=%q\n",
+			start.Format("02/Jan/2006:15:04:05 -0700"), entry.Queue, entry.ID, entry.Bytes, entry.Duration, entry.Error)
+		return err
+	})
+}
+
+// MessageMetricsMiddleware records prometheus metrics for each message: a
+// count of messages handled, by queue and outcome (ok or error), and a
+// histogram of the time taken to handle them, by queue.
+func (api *API) MessageMetricsMiddleware(h MessageHandler) MessageHandler {
+	m := api.metrics
+	return MessageHandlerFunc(func(ctx context.Context, msg *Message) error {
+		start := time.Now()
+		err := h.HandleMessage(ctx, msg)
+		outcome := "ok"
+		if err != nil {
+			outcome = "error"
+		}
+		m.messages.WithLabelValues(msg.Queue, outcome).Inc()
+		m.messageDuration.WithLabelValues(msg.Queue).Observe(time.Since(start).Seconds())
+		return err
+	})
+}
+
+// MemoryQueue is an in-memory implementation of QueueAdapter, for tests and
+// development. Nacked messages are put back on the end of the queue, or, if
+// it is full, held until it has been emptied, so Nack never blocks.
+type MemoryQueue struct {
+	messages chan *Message
+	mu       sync.Mutex
+	overflow []*Message
+	acked    int
+	nacked   int
+	nextID   int
+}
+
+// NewMemoryQueue creates an instance of MemoryQueue, holding up to size
+// messages.
+func NewMemoryQueue(size int) *MemoryQueue {
+	return &MemoryQueue{messages: make(chan *Message, size)}
+}
+
+// Publish adds a message with the given body to the queue.
+func (q *MemoryQueue) Publish(body []byte) {
+	q.mu.Lock()
+	q.nextID++
+	id := q.nextID
+	q.mu.Unlock()
+	q.messages <- &Message{ID: fmt.Sprintf("%d", id), Body: body}
+}
+
+// Receive returns the next message in the queue.
+func (q *MemoryQueue) Receive(ctx context.Context) ([]*Message, error) {
+	m, ok := q.next()
+	if !ok {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case m = <-q.messages:
+		}
+	}
+	m.Attempts++
+	return []*Message{m}, nil
+}
+
+// next returns the next message in the queue, or the first of those nacked
+// while it was full, once it is empty, without waiting.
+func (q *MemoryQueue) next() (*Message, bool) {
+	select {
+	case m := <-q.messages:
+		return m, true
+	default:
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.overflow) == 0 {
+		return nil, false
+	}
+	m := q.overflow[0]
+	q.overflow = q.overflow[1:]
+	return m, true
+}
+
+// Ack removes the message from the queue.
+func (q *MemoryQueue) Ack(ctx context.Context, m *Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.acked++
+	return nil
+}
+
+// Nack puts the message back on the end of the queue.
+func (q *MemoryQueue) Nack(ctx context.Context, m *Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nacked++
+	select {
+	case q.messages <- m:
+	default:
+		q.overflow = append(q.overflow, m)
+	}
+	return nil
+}
+
+// Stats returns the number of messages acked and nacked so far.
+func (q *MemoryQueue) Stats() (acked int, nacked int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.acked, q.nacked
+}
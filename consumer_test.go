@@ -0,0 +1,131 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestRunConsumers() {
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	accessLogOutput = ioutil.Discard
+	defer func() { accessLogOutput = os.Stdout }()
+	q := NewMemoryQueue(10)
+	q.Publish([]byte("one"))
+	q.Publish([]byte("two"))
+	ctx, cancel := context.WithCancel(context.Background())
+	suite.TestAPI.AddConsumer("widgets", q, MessageHandlerFunc(func(ctx context.Context, m *Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, string(m.Body))
+		if len(received) == 2 {
+			cancel()
+		}
+		return nil
+	}))
+	suite.True(suite.TestAPI.HasConsumers(), "expects the consumer to be added")
+	suite.TestAPI.RunConsumers(ctx)
+	suite.Equal([]string{"one", "two"}, received, "expects every message to be handled")
+	acked, _ := q.Stats()
+	suite.Equal(2, acked, "expects handled messages to be acked")
+}
+
+func (suite *HyperdriveTestSuite) TestRunConsumersNack() {
+	q := NewMemoryQueue(10)
+	q.Publish([]byte("fail"))
+	ctx, cancel := context.WithCancel(context.Background())
+	suite.TestAPI.AddConsumer("widgets", q, MessageHandlerFunc(func(ctx context.Context, m *Message) error {
+		if m.Attempts == 2 {
+			cancel()
+			return nil
+		}
+		return errors.New("failed")
+	}), suite.TestAPI.MessageRecoveryMiddleware)
+	suite.TestAPI.RunConsumers(ctx)
+	acked, nacked := q.Stats()
+	suite.Equal(1, nacked, "expects failed messages to be nacked")
+	suite.Equal(1, acked, "expects redelivered messages to be handled")
+}
+
+func (suite *HyperdriveTestSuite) TestMemoryQueueNackFull() {
+	q := NewMemoryQueue(1)
+	q.Publish([]byte("one"))
+	messages, _ := q.Receive(context.Background())
+	q.Publish([]byte("two"))
+	done := make(chan error)
+	go func() { done <- q.Nack(context.Background(), messages[0]) }()
+	select {
+	case err := <-done:
+		suite.Nil(err, "expects no error")
+	case <-time.After(time.Second):
+		suite.FailNow("expects Nack not to block when the queue is full")
+	}
+	var received []string
+	for i := 0; i < 2; i++ {
+		messages, _ := q.Receive(context.Background())
+		received = append(received, string(messages[0].Body))
+	}
+	suite.Equal([]string{"two", "one"}, received, "expects the nacked message to be put back on the end of the queue")
+}
+
+func (suite *HyperdriveTestSuite) TestMessageRecoveryMiddleware() {
+	var reported bool
+	suite.TestAPI.AddPanicReporter(PanicReporterFunc(func(p Panic) { reported = true }))
+	h := suite.TestAPI.MessageRecoveryMiddleware(MessageHandlerFunc(func(ctx context.Context, m *Message) error {
+		panic("boom")
+	}))
+	err := h.HandleMessage(context.Background(), &Message{ID: "1"})
+	suite.EqualError(err, "panic: boom", "expects the panic to be returned as an error")
+	suite.True(reported, "expects the panic to be reported")
+}
+
+func (suite *HyperdriveTestSuite) TestMessageRetryMiddleware() {
+	var attempts int
+	conf.ConsumerRetryBackoff = time.Millisecond
+	defer func() { conf.ConsumerRetryBackoff = time.Second }()
+	h := suite.TestAPI.MessageRetryMiddleware(MessageHandlerFunc(func(ctx context.Context, m *Message) error {
+		attempts++
+		return errors.New("failed")
+	}))
+	suite.Error(h.HandleMessage(context.Background(), &Message{}), "expects the last error to be returned")
+	suite.Equal(3, attempts, "expects CONSUMER_MAX_ATTEMPTS attempts")
+}
+
+func (suite *HyperdriveTestSuite) TestMessageLoggingMiddleware() {
+	var (
+		buf   bytes.Buffer
+		entry MessageLogEntry
+	)
+	accessLogOutput = &buf
+	conf.LogFormat = "json"
+	defer func() {
+		accessLogOutput = os.Stdout
+		conf.LogFormat = "combined"
+	}()
+	h := suite.TestAPI.MessageLoggingMiddleware(MessageHandlerFunc(func(ctx context.Context, m *Message) error {
+		return errors.New("failed")
+	}))
+	h.HandleMessage(context.Background(), &Message{ID: "1", Queue: "widgets", Body: []byte("body")})
+	suite.Nil(json.Unmarshal(buf.Bytes(), &entry), "expects valid json")
+	suite.Equal("widgets", entry.Queue, "expects the queue to be logged")
+	suite.Equal("failed", entry.Error, "expects the error to be logged")
+}
+
+func (suite *HyperdriveTestSuite) TestMessageMetricsMiddleware() {
+	h := suite.TestAPI.MessageMetricsMiddleware(MessageHandlerFunc(func(ctx context.Context, m *Message) error {
+		return nil
+	}))
+	h.HandleMessage(context.Background(), &Message{Queue: "widgets"})
+	rw := httptest.NewRecorder()
+	suite.TestAPI.MetricsHandler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	suite.Contains(rw.Body.String(), `hyperdrive_consumer_messages_total{outcome="ok",queue="widgets"} 1`, "expects messages to be counted by queue and outcome")
+}
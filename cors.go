@@ -0,0 +1,186 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/handlers"
+)
+
+// corsSafeHeaders are the request headers CorsMiddleware always allows.
+var corsSafeHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Origin"}
+
+// corsMethods are the methods CorsMiddleware allows cross-origin requests to
+// use.
+var corsMethods = []string{"GET", "HEAD", "POST"}
+
+// corsOrigins returns the origins set by CORS_ORIGINS.
+func corsOrigins() []string {
+	return strings.Split(conf.CorsOrigins, ",")
+}
+
+// corsHeaders returns the request headers allowed by CORS_HEADERS, along with
+// Content-Type and X-Content-Type-Options.
+func corsHeaders() []string {
+	return append([]string{"Content-Type", "X-Content-Type-Options"}, strings.Split(conf.CorsHeaders, ",")...)
+}
+
+// corsAllowedHeaders returns every request header CorsMiddleware allows,
+// canonicalized.
+func corsAllowedHeaders() []string {
+	var allowed []string
+	for _, h := range append(append([]string{}, corsSafeHeaders...), corsHeaders()...) {
+		if h = http.CanonicalHeaderKey(strings.TrimSpace(h)); h != "" && !contains(allowed, h) {
+			allowed = append(allowed, h)
+		}
+	}
+	return allowed
+}
+
+// corsCredentials returns true if credentialed requests are allowed: when
+// CORS_CREDENTIALS is true, and CORS_ORIGINS does not allow every origin.
+func corsCredentials() bool {
+	return conf.CorsCredentials && !contains(corsOrigins(), "*")
+}
+
+// CorsMiddleware allows cross-origin HTTP requests to your API. The middleware is enabled
+// by default, and can be configured via the following environment variables:
+//
+// - CORS_ENABLED (bool)
+// - CORS_ORIGINS (string)
+// - CORS_HEADERS (string)
+// - CORS_CREDENTIALS (bool, ignored when CORS_ORIGINS is *)
+// - CORS_DEBUG (bool, logs why cross-origin requests were rejected)
+func (api *API) CorsMiddleware(h http.Handler) http.Handler {
+	if conf.CorsEnabled != true {
+		return h
+	}
+	opts := []handlers.CORSOption{handlers.AllowedHeaders(corsHeaders()), handlers.AllowedOrigins(corsOrigins())}
+	if corsCredentials() {
+		opts = append(opts, handlers.AllowCredentials())
+	}
+	cors := handlers.CORS(opts...)(h)
+	if !conf.CorsDebug {
+		return cors
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" {
+			if d := ExplainCors(r); !d.Allowed {
+				log.Printf("CORS rejected %s %s from %s: %s", r.Method, r.URL.Path, d.Origin, strings.Join(d.Reasons, " "))
+			}
+		}
+		cors.ServeHTTP(rw, r)
+	})
+}
+
+// CorsDiagnosis explains whether CorsMiddleware allows a cross-origin
+// request, and if not, the Reasons why not.
+type CorsDiagnosis struct {
+	Origin    string   `json:"origin"`
+	Method    string   `json:"method"`
+	Headers   []string `json:"headers,omitempty"`
+	Preflight bool     `json:"preflight"`
+	Allowed   bool     `json:"allowed"`
+	Reasons   []string `json:"reasons,omitempty"`
+}
+
+func (d *CorsDiagnosis) reject(format string, a ...interface{}) {
+	d.Allowed = false
+	d.Reasons = append(d.Reasons, fmt.Sprintf(format, a...))
+}
+
+// ExplainCors returns a CorsDiagnosis of the given request, as CorsMiddleware
+// would handle it with the current configuration. OPTIONS requests with an
+// Access-Control-Request-Method header are treated as preflights, for the
+// method and headers they request.
+func ExplainCors(r *http.Request) CorsDiagnosis {
+	d := CorsDiagnosis{Origin: r.Header.Get("Origin"), Method: r.Method}
+	if _, ok := r.Header["Access-Control-Request-Method"]; ok && r.Method == "OPTIONS" {
+		d.Preflight = true
+		d.Method = r.Header.Get("Access-Control-Request-Method")
+		for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			if h = http.CanonicalHeaderKey(strings.TrimSpace(h)); h != "" {
+				d.Headers = append(d.Headers, h)
+			}
+		}
+	}
+	d.explain(r.Header.Get("Cookie") != "" || r.Header.Get("Authorization") != "" || contains(d.Headers, "Authorization"))
+	return d
+}
+
+func (d *CorsDiagnosis) explain(credentialed bool) {
+	d.Allowed = true
+	if !conf.CorsEnabled {
+		d.reject("CORS is disabled (CORS_ENABLED is false), so no Access-Control-Allow-Origin header is sent.")
+		return
+	}
+	if d.Origin == "" {
+		d.reject("The request has no Origin header, so it is not a cross-origin request.")
+		return
+	}
+	origins := corsOrigins()
+	if !contains(origins, "*") && !contains(origins, d.Origin) {
+		d.reject("Origin %q is not in CORS_ORIGINS (%s).", d.Origin, conf.CorsOrigins)
+		for _, o := range origins {
+			if o != d.Origin && strings.TrimRight(strings.TrimSpace(o), "/") == d.Origin {
+				d.Reasons = append(d.Reasons, fmt.Sprintf("CORS_ORIGINS has %q, origins must match exactly, without spaces or a trailing slash.", o))
+			}
+		}
+	}
+	if d.Preflight && d.Method == "" {
+		d.reject("The preflight has an empty Access-Control-Request-Method header.")
+	} else if d.Preflight && !contains(corsMethods, d.Method) {
+		d.reject("Method %s is not allowed for cross-origin requests (allowed: %s).", d.Method, strings.Join(corsMethods, ", "))
+	}
+	allowed := corsAllowedHeaders()
+	for _, h := range d.Headers {
+		if !contains(allowed, h) {
+			d.reject("Header %s is not in CORS_HEADERS (%s).", h, conf.CorsHeaders)
+		}
+	}
+	if credentialed && !corsCredentials() {
+		if conf.CorsCredentials {
+			d.reject("The request has credentials, which are not allowed when CORS_ORIGINS is *, list the allowed origins instead.")
+		} else {
+			d.reject("The request has credentials, which are not allowed (CORS_CREDENTIALS is false).")
+		}
+	}
+}
+
+// CorsDebugHandler returns an http.Handler which responds with the
+// CorsDiagnosis of the cross-origin request described by its query params,
+// e.g. /debug/cors?origin=https://app.example.com&method=PUT&headers=X-Token,
+// along with the CORS configuration, to explain why browsers reject it. If
+// method is set, the request is diagnosed as a preflight; set credentials to
+// true for requests with cookies or an Authorization header. It is mounted
+// on /debug/cors when CORS_DEBUG is true, outside of production.
+func (api *API) CorsDebugHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		d := CorsDiagnosis{Origin: q.Get("origin"), Method: "GET"}
+		if m := q.Get("method"); m != "" {
+			d.Preflight = true
+			d.Method = strings.ToUpper(m)
+		}
+		for _, h := range strings.Split(q.Get("headers"), ",") {
+			if h = http.CanonicalHeaderKey(strings.TrimSpace(h)); h != "" {
+				d.Headers = append(d.Headers, h)
+			}
+		}
+		d.explain(q.Get("credentials") == "true")
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]interface{}{
+			"request": d,
+			"config": map[string]interface{}{
+				"enabled":     conf.CorsEnabled,
+				"origins":     corsOrigins(),
+				"methods":     corsMethods,
+				"headers":     corsAllowedHeaders(),
+				"credentials": corsCredentials(),
+			},
+		})
+	})
+}
@@ -0,0 +1,105 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+)
+
+func corsPreflight(origin string, method string, headers string) *http.Request {
+	r := httptest.NewRequest("OPTIONS", "/test", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		r.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return r
+}
+
+func (suite *HyperdriveTestSuite) TestCorsMiddlewareCredentials() {
+	defer func(c Config) { conf = c }(conf)
+	conf.CorsOrigins = "https://app.example.com"
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	rw := httptest.NewRecorder()
+	suite.TestAPI.CorsMiddleware(suite.TestHandler).ServeHTTP(rw, r)
+	suite.Equal("true", rw.Header().Get("Access-Control-Allow-Credentials"), "expects credentials to be allowed for the listed origins")
+	conf.CorsOrigins = "*"
+	rw = httptest.NewRecorder()
+	suite.TestAPI.CorsMiddleware(suite.TestHandler).ServeHTTP(rw, r)
+	suite.Equal("", rw.Header().Get("Access-Control-Allow-Credentials"), "expects credentials not to be allowed for every origin")
+}
+
+func (suite *HyperdriveTestSuite) TestExplainCorsAllowed() {
+	d := ExplainCors(corsPreflight("https://app.example.com", "POST", "content-type"))
+	suite.True(d.Allowed, "expects the preflight to be allowed")
+	suite.True(d.Preflight, "expects a preflight")
+	suite.Equal("POST", d.Method, "expects the requested method")
+	suite.Equal([]string{"Content-Type"}, d.Headers, "expects the requested headers")
+	suite.Empty(d.Reasons, "expects no reasons")
+}
+
+func (suite *HyperdriveTestSuite) TestExplainCorsOrigin() {
+	defer func(c Config) { conf = c }(conf)
+	conf.CorsOrigins = "https://admin.example.com,https://app.example.com/"
+	d := ExplainCors(corsPreflight("https://app.example.com", "GET", ""))
+	suite.False(d.Allowed, "expects the preflight to be rejected")
+	suite.Equal([]string{
+		`Origin "https://app.example.com" is not in CORS_ORIGINS (https://admin.example.com,https://app.example.com/).`,
+		`CORS_ORIGINS has "https://app.example.com/", origins must match exactly, without spaces or a trailing slash.`,
+	}, d.Reasons, "expects the origin mismatch to be explained")
+}
+
+func (suite *HyperdriveTestSuite) TestExplainCorsMethodAndHeaders() {
+	d := ExplainCors(corsPreflight("https://app.example.com", "DELETE", "X-Token"))
+	suite.False(d.Allowed, "expects the preflight to be rejected")
+	suite.Equal([]string{
+		"Method DELETE is not allowed for cross-origin requests (allowed: GET, HEAD, POST).",
+		"Header X-Token is not in CORS_HEADERS ().",
+	}, d.Reasons, "expects the method and missing header to be explained")
+}
+
+func (suite *HyperdriveTestSuite) TestExplainCorsCredentials() {
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Cookie", "session=abc")
+	d := ExplainCors(r)
+	suite.False(d.Allowed, "expects the credentialed request to be rejected")
+	suite.Contains(d.Reasons[0], "not allowed when CORS_ORIGINS is *", "expects the credential conflict to be explained")
+}
+
+func (suite *HyperdriveTestSuite) TestExplainCorsNotCrossOrigin() {
+	d := ExplainCors(httptest.NewRequest("GET", "/test", nil))
+	suite.False(d.Allowed, "expects requests without an origin not to be allowed")
+	suite.Contains(d.Reasons[0], "no Origin header", "expects the missing origin to be explained")
+}
+
+func (suite *HyperdriveTestSuite) TestCorsDebugHandler() {
+	defer func(c Config) { conf = c }(conf)
+	conf.CorsHeaders = "X-Token"
+	rw := httptest.NewRecorder()
+	suite.TestAPI.CorsDebugHandler().ServeHTTP(rw, httptest.NewRequest("GET", "/debug/cors?origin=https://app.example.com&method=put&headers=x-token", nil))
+	var body struct {
+		Request CorsDiagnosis          `json:"request"`
+		Config  map[string]interface{} `json:"config"`
+	}
+	json.Unmarshal(rw.Body.Bytes(), &body)
+	suite.Equal("PUT", body.Request.Method, "expects the method to be diagnosed")
+	suite.Equal([]string{"X-Token"}, body.Request.Headers, "expects the headers to be diagnosed")
+	suite.Equal([]string{"Method PUT is not allowed for cross-origin requests (allowed: GET, HEAD, POST)."}, body.Request.Reasons, "expects the reasons the preflight is rejected")
+	suite.Contains(body.Config["headers"], "X-Token", "expects the allowed headers")
+}
+
+func (suite *HyperdriveTestSuite) TestCorsDebugRoute() {
+	defer func(c Config) { conf = c }(conf)
+	conf.CorsDebug = true
+	api := NewAPI("API", "An example API.")
+	rw := httptest.NewRecorder()
+	api.Router.ServeHTTP(rw, httptest.NewRequest("GET", "/debug/cors?origin=https://app.example.com", nil))
+	suite.Equal(http.StatusOK, rw.Code, "expects the debug endpoint to be mounted when CORS_DEBUG is true")
+	conf.Env = "production"
+	api = NewAPI("API", "An example API.")
+	rw = httptest.NewRecorder()
+	api.Router.ServeHTTP(rw, httptest.NewRequest("GET", "/debug/cors", nil))
+	suite.Equal(http.StatusNotFound, rw.Code, "expects the debug endpoint not to be mounted in production")
+}
@@ -0,0 +1,283 @@
+package hyperdrive
+
+import (
+	"context"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// CSVContentType is the media type of comma-separated values, as defined by
+// RFC 4180.
+const CSVContentType = "text/csv"
+
+// FormatParam is the query param which asks Respond for CSV, whatever the
+// Accept header, e.g. ?format=csv, so exports can be downloaded from a link.
+const FormatParam = "format"
+
+// CSVEncoder is an implementation of ContentEncoder which writes CSV, for
+// exporting raw data. A slice (or array) of structs, or of pointers to them,
+// is written as a row for each, and a channel of them as a row for each value
+// received until it is closed, or the Context (set by Respond to the
+// request's) is done, each flushed as it is written, so large exports are
+// streamed (producers should stop sending once the request's context is
+// done). A single struct is written as one row, and a PaginationEnvelope as
+// its Data.
+//
+// The header row names the exported fields by their csv struct tag (e.g.
+// `csv:"created_at"`), or json tag, or name. Fields tagged `csv:"-"` are
+// skipped, and those of untagged embedded structs are flattened. Values
+// implementing encoding.TextMarshaler (e.g. time.Time) are written as their
+// text, nil pointers, maps and slices as empty cells, other maps, slices and
+// structs as JSON, and anything else as by fmt.Sprint.
+//
+// Unless CSV_ESCAPE_FORMULAS (bool) is false, text cells (strings, and values
+// written as their text or String method) starting with =, +, -, @, a tab, or
+// a carriage return are prefixed with a single quote, so spreadsheets opening
+// the export do not run them as formulas (e.g. =HYPERLINK(...) in a name
+// entered by a user). Numbers, e.g. -5, are written as is.
+type CSVEncoder struct {
+	Writer  io.Writer
+	Context context.Context
+}
+
+// csvColumn is a column of a CSV, and the index of its field.
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+// Encode encodes input as CSV or returns an error.
+func (enc CSVEncoder) Encode(v interface{}) error {
+	if p, ok := v.(PaginationEnvelope); ok {
+		v = p.Data
+	}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() == reflect.Struct {
+		rv = reflect.Append(reflect.MakeSlice(reflect.SliceOf(rv.Type()), 0, 1), rv)
+	}
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array && rv.Kind() != reflect.Chan) || (rv.Kind() == reflect.Chan && rv.Type().ChanDir()&reflect.RecvDir == 0) {
+		return fmt.Errorf("csv: %T can not be encoded, only structs, and slices and channels of them", v)
+	}
+	columns, err := csvColumns(rv.Type().Elem())
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(enc.Writer)
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.name
+	}
+	w.Write(header)
+	if rv.Kind() != reflect.Chan {
+		for i := 0; i < rv.Len(); i++ {
+			if err := writeCSVRow(w, columns, rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+	}
+	if err := enc.flush(w); err != nil {
+		return err
+	}
+	cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: rv}}
+	if enc.Context != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(enc.Context.Done())})
+	}
+	for {
+		chosen, row, ok := reflect.Select(cases)
+		if chosen != 0 || !ok {
+			return nil
+		}
+		if err := writeCSVRow(w, columns, row); err != nil {
+			return err
+		}
+		if err := enc.flush(w); err != nil {
+			return err
+		}
+	}
+}
+
+// flush writes the buffered rows, and flushes the response, if the writer is
+// one.
+func (enc CSVEncoder) flush(w *csv.Writer) error {
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	if f, ok := enc.Writer.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// csvColumns returns the columns of the rows of a CSV of values of the type.
+func csvColumns(t reflect.Type) ([]csvColumn, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("csv: rows of %s can not be encoded, only structs", t)
+	}
+	return appendCSVColumns(nil, t, nil), nil
+}
+
+// appendCSVColumns appends the columns of the fields of the struct type,
+// whose index is prefixed with the given index.
+func appendCSVColumns(columns []csvColumn, t reflect.Type, index []int) []csvColumn {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := csvFieldName(f)
+		if name == "-" {
+			continue
+		}
+		fieldIndex := append(append([]int(nil), index...), i)
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		// As with encoding/json, the exported fields of embedded structs are
+		// promoted, even if the struct type itself is unexported.
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct && (f.PkgPath == "" || f.Type.Kind() == reflect.Struct) {
+			columns = appendCSVColumns(columns, ft, fieldIndex)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		columns = append(columns, csvColumn{name: name, index: fieldIndex})
+	}
+	return columns
+}
+
+// csvFieldName returns the name given to the field by its csv tag, or its
+// json tag, or "" if neither names it.
+func csvFieldName(f reflect.StructField) string {
+	for _, key := range []string{"csv", "json"} {
+		if name := strings.Split(f.Tag.Get(key), ",")[0]; name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// writeCSVRow writes the columns of the struct, or pointer to one, as a row.
+// A nil pointer is written as a row of empty cells.
+func writeCSVRow(w *csv.Writer, columns []csvColumn, v reflect.Value) error {
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		f, ok := csvField(v, c.index)
+		if !ok {
+			continue
+		}
+		cell, err := csvCell(f)
+		if err != nil {
+			return err
+		}
+		row[i] = cell
+	}
+	return w.Write(row)
+}
+
+// csvField returns the field of the struct with the given index, following
+// pointers, and false if one of them is nil.
+func csvField(v reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// csvCell returns the text of a value, as described by CSVEncoder, escaped if
+// it is text a spreadsheet would run as a formula. Numbers are never escaped,
+// so negative ones stay numbers.
+func csvCell(v reflect.Value) (string, error) {
+	cell, err := csvText(v)
+	if conf.CSVEscapeFormulas && cell != "" && strings.IndexByte("=+-@\t\r", cell[0]) >= 0 && isCSVText(v) {
+		cell = "'" + cell
+	}
+	return cell, err
+}
+
+// isCSVText returns true if the value is a string, or is written as the text
+// it marshals to, or as its String method.
+func isCSVText(v reflect.Value) bool {
+	for {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return false
+		}
+		switch v.Interface().(type) {
+		case encoding.TextMarshaler, fmt.Stringer:
+			return true
+		}
+		if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface {
+			return v.Kind() == reflect.String
+		}
+		v = v.Elem()
+	}
+}
+
+// csvText returns the text of a value, as described by CSVEncoder.
+func csvText(v reflect.Value) (string, error) {
+	for {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return "", nil
+		}
+		if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+			b, err := m.MarshalText()
+			return string(b), err
+		}
+		if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface {
+			break
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return "", nil
+		}
+		b, err := json.Marshal(v.Interface())
+		return string(b), err
+	case reflect.Array, reflect.Struct:
+		b, err := json.Marshal(v.Interface())
+		return string(b), err
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// isChannel returns true if the body is a channel, whose values are streamed,
+// and so can not be buffered.
+func isChannel(body interface{}) bool {
+	return reflect.ValueOf(body).Kind() == reflect.Chan
+}
+
+// acceptHeader returns the Accept header of the request, or text/csv, when
+// the format query param asks for CSV.
+func acceptHeader(r *http.Request) string {
+	if asksForCSV(r) {
+		return CSVContentType
+	}
+	return r.Header.Get("Accept")
+}
+
+// asksForCSV returns true if the format query param asks for CSV, e.g. from
+// a download link.
+func asksForCSV(r *http.Request) bool {
+	return r.URL.Query().Get(FormatParam) == "csv"
+}
@@ -0,0 +1,133 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+type csvBase struct {
+	ID int `json:"id"`
+}
+
+type csvRow struct {
+	csvBase
+	Name    string    `csv:"name"`
+	Email   string    `json:"email,omitempty"`
+	Secret  string    `csv:"-"`
+	Created time.Time `csv:"created"`
+	Count   *int      `csv:"count"`
+	Tags    []string  `csv:"tags"`
+	Note    string
+}
+
+func (suite *HyperdriveTestSuite) TestCSVEncoder() {
+	suite.Implements((*ContentEncoder)(nil), CSVEncoder{}, "return an implementation of ContentEncoder")
+}
+
+func (suite *HyperdriveTestSuite) TestCSVEncoderEncode() {
+	count := 2
+	rows := []*csvRow{
+		{csvBase: csvBase{1}, Name: "Smith, Jo", Email: "jo@example.com", Secret: "x", Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Count: &count, Tags: []string{"a"}, Note: "two\nlines"},
+		nil,
+	}
+	var buf bytes.Buffer
+	suite.Nil(CSVEncoder{Writer: &buf}.Encode(rows), "expects no error")
+	csv := "id,name,email,created,count,tags,Note\n" +
+		"1,\"Smith, Jo\",jo@example.com,2020-01-02T03:04:05Z,2,\"[\"\"a\"\"]\",\"two\nlines\"\n" +
+		",,,,,,\n"
+	suite.Equal(csv, buf.String(), "expects a header, and a row for each struct")
+}
+
+func (suite *HyperdriveTestSuite) TestCSVEncoderEncodeStruct() {
+	var buf bytes.Buffer
+	suite.Nil(CSVEncoder{Writer: &buf}.Encode(PaginationEnvelope{Data: []csvBase{{7}}}), "expects no error")
+	suite.Equal("id\n7\n", buf.String(), "expects the data of a PaginationEnvelope")
+	buf.Reset()
+	suite.Nil(CSVEncoder{Writer: &buf}.Encode(&csvBase{8}), "expects no error")
+	suite.Equal("id\n8\n", buf.String(), "expects a single row for a struct")
+	buf.Reset()
+	suite.Nil(CSVEncoder{Writer: &buf}.Encode([]csvBase{}), "expects no error")
+	suite.Equal("id\n", buf.String(), "expects only the header for an empty slice")
+}
+
+func (suite *HyperdriveTestSuite) TestCSVEncoderEncodeChannel() {
+	rows := make(chan csvBase)
+	go func() {
+		for i := 1; i <= 3; i++ {
+			rows <- csvBase{i}
+		}
+		close(rows)
+	}()
+	rw := httptest.NewRecorder()
+	suite.Nil(CSVEncoder{Writer: rw}.Encode(rows), "expects no error")
+	suite.Equal("id\n1\n2\n3\n", rw.Body.String(), "expects a row for each value received")
+	suite.True(rw.Flushed, "expects the rows to be flushed")
+}
+
+func (suite *HyperdriveTestSuite) TestCSVEncoderEncodeInvalid() {
+	var buf bytes.Buffer
+	suite.Error(CSVEncoder{Writer: &buf}.Encode(map[string]int{"a": 1}), "expects an error for maps")
+	suite.Error(CSVEncoder{Writer: &buf}.Encode([]int{1}), "expects an error for slices of other types")
+	suite.Error(CSVEncoder{Writer: &buf}.Encode(make(chan<- csvBase)), "expects an error for send-only channels")
+	suite.Equal("", buf.String(), "expects nothing to be written")
+}
+
+func (suite *HyperdriveTestSuite) TestGetEncoderCSV() {
+	enc, _ := GetEncoder(httptest.NewRecorder(), "text/csv")
+	suite.IsType(CSVEncoder{}, enc, "return a CSVEncoder")
+	enc, _ = GetEncoder(httptest.NewRecorder(), "text/*")
+	suite.IsType(HTMLEncoder{}, enc, "expects CSV only when it is asked for")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondCSV() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test?format=csv", nil)
+	r.Header.Set("Accept", "application/json")
+	Respond(rw, r, http.StatusOK, []csvBase{{1}, {2}})
+	suite.Equal(CSVContentType, rw.Header().Get("Content-Type"), "expects the format query param to override the Accept header")
+	suite.Equal("attachment", rw.Header().Get("Content-Disposition"), "expects CSV asked for with the format query param to be downloaded")
+	suite.Equal("id\n1\n2\n", rw.Body.String(), "expects the rows")
+	rw = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Accept", CSVContentType)
+	Respond(rw, r, http.StatusOK, []csvBase{{1}})
+	suite.Empty(rw.Header().Get("Content-Disposition"), "expects negotiated CSV not to be an attachment")
+}
+
+func (suite *HyperdriveTestSuite) TestCSVEncoderEncodeFormulas() {
+	defer func(c Config) { conf = c }(conf)
+	rows := []csvRow{{Name: "=HYPERLINK(\"http://example.com\")", Email: "@SUM(A1)", Note: "-1+1"}}
+	var buf bytes.Buffer
+	suite.Nil(CSVEncoder{Writer: &buf}.Encode(rows), "expects no error")
+	suite.Equal("id,name,email,created,count,tags,Note\n"+
+		"0,\"'=HYPERLINK(\"\"http://example.com\"\")\",'@SUM(A1),0001-01-01T00:00:00Z,,,'-1+1\n", buf.String(), "expects cells which would be run as formulas to be escaped")
+	buf.Reset()
+	suite.Nil(CSVEncoder{Writer: &buf}.Encode([]csvBase{{-1}}), "expects no error")
+	suite.Equal("id\n-1\n", buf.String(), "expects negative numbers not to be escaped")
+	conf.CSVEscapeFormulas = false
+	buf.Reset()
+	suite.Nil(CSVEncoder{Writer: &buf}.Encode([]csvRow{{Name: "=1+1"}}), "expects no error")
+	suite.Contains(buf.String(), ",=1+1,", "expects cells not to be escaped when CSV_ESCAPE_FORMULAS is false")
+}
+
+func (suite *HyperdriveTestSuite) TestCSVEncoderEncodeChannelContext() {
+	rows := make(chan csvBase)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		rows <- csvBase{1}
+		cancel()
+	}()
+	done := make(chan error)
+	rw := httptest.NewRecorder()
+	go func() { done <- CSVEncoder{Writer: rw, Context: ctx}.Encode(rows) }()
+	select {
+	case err := <-done:
+		suite.Nil(err, "expects no error")
+		suite.Equal("id\n1\n", rw.Body.String(), "expects the rows received before the context was done")
+	case <-time.After(time.Second):
+		suite.Fail("expects the channel to stop being read once the context is done")
+	}
+}
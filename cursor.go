@@ -0,0 +1,138 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// CursorParam is the query param holding the cursor of the page requested.
+const CursorParam = "cursor"
+
+var (
+	// ErrInvalidCursor is returned when a cursor has been tampered with, was
+	// not created by a CursorCodec with the same keys, or can not be decoded.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrExpiredCursor is returned when a cursor is older than its TTL.
+	ErrExpiredCursor = errors.New("expired cursor")
+)
+
+// Cursor is a general purpose pagination state, for use with CursorCodec:
+// the sort key values of the last item of the previous page (for keyset
+// pagination), or an Offset, along with the Filters the first page was
+// requested with, so they can not be changed between pages. Any other type
+// which can be encoded as JSON can be used instead.
+type Cursor struct {
+	After   []interface{}     `json:"after,omitempty"`
+	Offset  int               `json:"offset,omitempty"`
+	Filters map[string]string `json:"filters,omitempty"`
+}
+
+// CursorCodec encodes pagination state into opaque tokens, which clients
+// pass back unchanged to request the next page. Tokens are signed with
+// Secret, so clients can not tamper with them, or construct their own, and
+// expire after TTL. When EncryptionKey is set, they are also encrypted (with
+// AES-256-GCM), so clients can not read them either.
+type CursorCodec struct {
+	Secret        string
+	EncryptionKey string
+	TTL           time.Duration
+}
+
+// NewCursorCodec creates an instance of CursorCodec, configured by the
+// CURSOR_SECRET, CURSOR_ENCRYPTION_KEY, and CURSOR_TTL (default: 24h)
+// environment variables.
+func NewCursorCodec() CursorCodec {
+	return CursorCodec{Secret: conf.CursorSecret, EncryptionKey: conf.CursorEncryptionKey, TTL: conf.CursorTTL}
+}
+
+type cursorPayload struct {
+	State   json.RawMessage `json:"s"`
+	Expires int64           `json:"e,omitempty"`
+}
+
+// Encode returns the token for the given state, which must be encodable as
+// JSON.
+func (c CursorCodec) Encode(state interface{}) (string, error) {
+	if c.Secret == "" {
+		return "", errors.New("cursors can not be signed: CURSOR_SECRET is not set")
+	}
+	s, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	payload := cursorPayload{State: s}
+	if c.TTL > 0 {
+		payload.Expires = time.Now().Add(c.TTL).Unix()
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return c.sealer().seal(b)
+}
+
+// Decode verifies, decrypts, and decodes the token into state. It returns
+// ErrInvalidCursor or ErrExpiredCursor if the token can not be used.
+func (c CursorCodec) Decode(token string, state interface{}) error {
+	b, ok := c.sealer().open(token)
+	if !ok {
+		return ErrInvalidCursor
+	}
+	payload := cursorPayload{}
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return ErrInvalidCursor
+	}
+	if payload.Expires != 0 && time.Now().After(time.Unix(payload.Expires, 0)) {
+		return ErrExpiredCursor
+	}
+	if err := json.Unmarshal(payload.State, state); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// sealer returns the sealer of tokens, keyed by the Secret and
+// EncryptionKey.
+func (c CursorCodec) sealer() sealer {
+	return sealer{name: "cursor", secret: c.Secret, encryptionKey: c.EncryptionKey}
+}
+
+// GetCursor decodes the cursor query param of the request into state, using
+// the CursorCodec returned by NewCursorCodec. It returns false if the request
+// has no cursor, i.e. the first page is requested, and a *ValidationError,
+// which can be written with WriteValidationError, if the cursor is invalid or
+// expired.
+func GetCursor(r *http.Request, state interface{}) (bool, error) {
+	token := r.URL.Query().Get(CursorParam)
+	if token == "" {
+		return false, nil
+	}
+	if err := NewCursorCodec().Decode(token, state); err != nil {
+		verr := &ValidationError{}
+		msg := "The cursor is invalid."
+		if err == ErrExpiredCursor {
+			msg = "The cursor has expired, request the first page again."
+		}
+		verr.AddParamError(InQuery, CursorParam, ValidationInvalid, msg)
+		return true, verr
+	}
+	return true, nil
+}
+
+// NextPageURL returns the URL of the page after the one requested, relative
+// to the API's host, for use in a Link header or the response body: the path
+// and query of the request, with its cursor query param set to the token for
+// the given state, encoded by the CursorCodec returned by NewCursorCodec.
+func NextPageURL(r *http.Request, state interface{}) (string, error) {
+	token, err := NewCursorCodec().Encode(state)
+	if err != nil {
+		return "", err
+	}
+	u := *r.URL
+	q := u.Query()
+	q.Set(CursorParam, token)
+	u.RawQuery = q.Encode()
+	return u.RequestURI(), nil
+}
@@ -0,0 +1,62 @@
+package hyperdrive
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestCursorCodec() {
+	c := CursorCodec{Secret: "secret", TTL: time.Hour}
+	token, err := c.Encode(Cursor{After: []interface{}{"2017-01-01", float64(42)}, Filters: map[string]string{"status": "active"}})
+	suite.Nil(err, "expects no error")
+	var cursor Cursor
+	suite.Nil(c.Decode(token, &cursor), "expects the cursor to be decoded")
+	suite.Equal([]interface{}{"2017-01-01", float64(42)}, cursor.After, "expects the sort keys to be decoded")
+	suite.Equal("active", cursor.Filters["status"], "expects the filters to be decoded")
+	suite.Equal(ErrInvalidCursor, CursorCodec{Secret: "other"}.Decode(token, &cursor), "expects cursors signed with another secret to be invalid")
+	tampered := base64.RawURLEncoding.EncodeToString([]byte(`{"s":{"offset":1000}}`)) + token[strings.Index(token, "."):]
+	suite.Equal(ErrInvalidCursor, c.Decode(tampered, &cursor), "expects tampered cursors to be invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestCursorCodecEncryption() {
+	c := CursorCodec{Secret: "secret", EncryptionKey: "key"}
+	token, err := c.Encode(Cursor{Offset: 20})
+	suite.Nil(err, "expects no error")
+	b, _ := base64.RawURLEncoding.DecodeString(strings.SplitN(token, ".", 2)[0])
+	suite.NotContains(string(b), "offset", "expects the cursor to be encrypted")
+	var cursor Cursor
+	suite.Nil(c.Decode(token, &cursor), "expects the cursor to be decrypted")
+	suite.Equal(20, cursor.Offset, "expects the offset to be decoded")
+	suite.Equal(ErrInvalidCursor, CursorCodec{Secret: "secret", EncryptionKey: "other"}.Decode(token, &cursor), "expects cursors encrypted with another key to be invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestCursorCodecExpired() {
+	c := CursorCodec{Secret: "secret", TTL: time.Nanosecond}
+	token, _ := c.Encode(Cursor{Offset: 20})
+	suite.Equal(ErrExpiredCursor, c.Decode(token, &Cursor{}), "expects expired cursors to be rejected")
+	_, err := CursorCodec{}.Encode(Cursor{})
+	suite.Error(err, "expects an error without a secret")
+}
+
+func (suite *HyperdriveTestSuite) TestGetCursor() {
+	defer func(c Config) { conf = c }(conf)
+	conf.CursorSecret = "secret"
+	var cursor Cursor
+	ok, err := GetCursor(httptest.NewRequest("GET", "/test?limit=10", nil), &cursor)
+	suite.False(ok, "expects no cursor for the first page")
+	suite.Nil(err, "expects no error")
+	next, err := NextPageURL(httptest.NewRequest("GET", "/test?limit=10", nil), Cursor{Offset: 10})
+	suite.Nil(err, "expects no error")
+	u, _ := url.Parse(next)
+	suite.Equal("10", u.Query().Get("limit"), "expects the other query params to be kept")
+	ok, err = GetCursor(httptest.NewRequest("GET", next, nil), &cursor)
+	suite.True(ok, "expects a cursor")
+	suite.Nil(err, "expects no error")
+	suite.Equal(10, cursor.Offset, "expects the state of the next page")
+	_, err = GetCursor(httptest.NewRequest("GET", "/test?cursor=forged", nil), &cursor)
+	suite.IsType(&ValidationError{}, err, "expects a ValidationError for an invalid cursor")
+	suite.Equal(CursorParam, err.(*ValidationError).Errors[0].Parameter, "expects the cursor param to be reported")
+}
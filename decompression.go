@@ -0,0 +1,80 @@
+package hyperdrive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+
+// decompressor returns a reader of the decompressed body, for the given
+// Content-Encoding.
+func decompressor(encoding string, body io.Reader) (io.Reader, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		// Deflate should be zlib wrapped (RFC 9110), but some clients send
+		// raw deflate data.
+		br := bufio.NewReader(body)
+		if header, err := br.Peek(2); err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	}
+	return nil, errUnsupportedEncoding
+}
+
+// DecompressionMiddleware wraps the given http.Handler, and transparently
+// decompresses request bodies sent with a Content-Encoding of gzip or
+// deflate, so handlers (and BodyParams) read the decoded body. The
+// Content-Encoding header is removed, and Content-Length set to the size of
+// the decompressed body.
+//
+// Bodies are decompressed before the handler is called, up to
+// REQUEST_DECOMPRESSION_MAX_BYTES (default: 10MB), so small bodies can not
+// expand into large ones (decompression bombs): larger bodies are rejected
+// with a 413 Request Entity Too Large, corrupt bodies with a 400 Bad Request,
+// and other encodings with a 415 Unsupported Media Type.
+func (api *API) DecompressionMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		dr, err := decompressor(encoding, r.Body)
+		if err == errUnsupportedEncoding {
+			rw.Header().Set("Accept-Encoding", "gzip, deflate")
+			writeProblem(rw, http.StatusUnsupportedMediaType, fmt.Sprintf("The Content-Encoding %q is not supported.", encoding))
+			return
+		}
+		var b []byte
+		if err == nil {
+			b, err = io.ReadAll(io.LimitReader(dr, int64(conf.RequestDecompressionMaxBytes)+1))
+		}
+		if err != nil {
+			writeProblem(rw, http.StatusBadRequest, "The request body could not be decompressed: "+err.Error())
+			return
+		}
+		if len(b) > conf.RequestDecompressionMaxBytes {
+			writeProblem(rw, http.StatusRequestEntityTooLarge, fmt.Sprintf("The decompressed request body must not be larger than %d bytes.", conf.RequestDecompressionMaxBytes))
+			return
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		r.ContentLength = int64(len(b))
+		r.Header.Del("Content-Encoding")
+		r.Header.Set("Content-Length", strconv.Itoa(len(b)))
+		h.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,65 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// echoBodyHandler responds with the body of the request.
+var echoBodyHandler = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+	io.Copy(rw, r.Body)
+})
+
+func gzipped(s string) *bytes.Buffer {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return &buf
+}
+
+func (suite *HyperdriveTestSuite) TestDecompressionMiddlewareGzip() {
+	r := httptest.NewRequest("POST", "/test", gzipped("name=test"))
+	r.Header.Set("Content-Encoding", "gzip")
+	rw := httptest.NewRecorder()
+	suite.TestAPI.DecompressionMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		suite.Empty(r.Header.Get("Content-Encoding"), "expects the Content-Encoding to be removed")
+		suite.Equal(int64(9), r.ContentLength, "expects the Content-Length of the decompressed body")
+		echoBodyHandler(rw, r)
+	})).ServeHTTP(rw, r)
+	suite.Equal("name=test", rw.Body.String(), "expects the body to be decompressed")
+}
+
+func (suite *HyperdriveTestSuite) TestDecompressionMiddlewareDeflate() {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte("name=test"))
+	w.Close()
+	r := httptest.NewRequest("POST", "/test", &buf)
+	r.Header.Set("Content-Encoding", "deflate")
+	rw := httptest.NewRecorder()
+	suite.TestAPI.DecompressionMiddleware(echoBodyHandler).ServeHTTP(rw, r)
+	suite.Equal("name=test", rw.Body.String(), "expects the body to be decompressed")
+}
+
+func (suite *HyperdriveTestSuite) TestDecompressionMiddlewareErrors() {
+	defer func(c Config) { conf = c }(conf)
+	conf.RequestDecompressionMaxBytes = 4
+	for encoding, expected := range map[string]int{"gzip": http.StatusRequestEntityTooLarge, "br": http.StatusUnsupportedMediaType} {
+		r := httptest.NewRequest("POST", "/test", gzipped("name=test"))
+		r.Header.Set("Content-Encoding", encoding)
+		rw := httptest.NewRecorder()
+		suite.TestAPI.DecompressionMiddleware(echoBodyHandler).ServeHTTP(rw, r)
+		suite.Equal(expected, rw.Code, "expects an error for "+encoding)
+	}
+	r := httptest.NewRequest("POST", "/test", strings.NewReader("name=test"))
+	r.Header.Set("Content-Encoding", "gzip")
+	rw := httptest.NewRecorder()
+	suite.TestAPI.DecompressionMiddleware(echoBodyHandler).ServeHTTP(rw, r)
+	suite.Equal(http.StatusBadRequest, rw.Code, "expects a 400 for corrupt bodies")
+}
@@ -43,6 +43,7 @@ type EndpointResourceParam struct {
 	Allowed      []string `json:"allowed" xml:"-"`
 	RequiredList string   `json:"-" xml:"required,attr"`
 	Required     []string `json:"required" xml:"-"`
+	Default      string   `json:"default,omitempty" xml:"default,attr,omitempty"`
 }
 
 // NewRootResource creates an instance of RootResource from the given API.
@@ -74,6 +75,7 @@ func NewEndpointResourceParam(p parsedParam) EndpointResourceParam {
 		AllowedList:  p.AllowedList(),
 		Required:     p.Required,
 		RequiredList: p.RequiredList(),
+		Default:      p.Default,
 	}
 }
 
@@ -1,11 +1,13 @@
 package hyperdrive
 
 import (
+	"bytes"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
+	"html/template"
+	"io"
 	"net/http"
-	"strings"
 )
 
 // ContentEncoder interface wraps the details of encoding response bodies to
@@ -46,18 +48,96 @@ func (enc XMLEncoder) Encode(v interface{}) error {
 	return enc.Encoder.Encode(v)
 }
 
+// MsgPackEncoder is an implementation of ContentEncoder which writes
+// MessagePack. Values are encoded as they would be as JSON (so json struct
+// tags apply), then converted.
+type MsgPackEncoder struct {
+	Writer io.Writer
+}
+
+// Encode encodes input as MessagePack or returns an error.
+func (enc MsgPackEncoder) Encode(v interface{}) error {
+	var doc interface{}
+	if err := decodeAsJSON(v, &doc); err != nil {
+		return err
+	}
+	b, err := encodeMsgPack(nil, doc)
+	if err != nil {
+		return err
+	}
+	_, err = enc.Writer.Write(b)
+	return err
+}
+
+// htmlTemplate renders a value as an HTML page, for browsing an API.
+var htmlTemplate = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body><pre>{{.Body}}</pre></body>
+</html>
+`))
+
+// HTMLEncoder is an implementation of ContentEncoder which writes an HTML
+// page showing the value as indented JSON, so an API can be browsed.
+type HTMLEncoder struct {
+	Writer io.Writer
+}
+
+// Encode encodes input as an HTML page or returns an error.
+func (enc HTMLEncoder) Encode(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return htmlTemplate.Execute(enc.Writer, struct{ Title, Body string }{hAPI.Name, string(b)})
+}
+
+// decodeAsJSON encodes v as JSON, and decodes it into doc, with numbers as
+// json.Number.
+func decodeAsJSON(v interface{}, doc interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(doc)
+}
+
 // GetEncoder returns the correct ContentEncoder, determined by the Accept
-// header, to support automatic Content Negotiation.
+// header (see Negotiate), to support automatic Content Negotiation, and sets
+// the Content-Type to the chosen media type. Media types with a Codec
+// registered with RegisterCodec are encoded by it, and others as JSON, XML,
+// MessagePack, HTML, CSV, HAL, Siren, or Collection+JSON (see CSVEncoder,
+// HALEncoder, SirenEncoder, and CollectionJSONEncoder). If no media type is
+// acceptable, a NullEncoder is returned.
 func GetEncoder(rw http.ResponseWriter, accept string) (ContentEncoder, http.ResponseWriter) {
-	if strings.HasSuffix(accept, "json") {
-		rw.Header().Set("Content-Type", accept)
-		return JSONEncoder{json.NewEncoder(rw)}, rw
+	rw.Header().Add("Vary", "Accept")
+	mediaType, ok := Negotiate(accept)
+	if !ok {
+		return NullEncoder{}, rw
 	}
-
-	if strings.HasSuffix(accept, "xml") {
-		rw.Header().Set("Content-Type", accept)
+	rw.Header().Set("Content-Type", mediaType)
+	if c, ok := hAPI.codecs.lookup(mediaType); ok {
+		return CodecEncoder{c, rw}, rw
+	}
+	switch mediaType {
+	case HALContentType:
+		return HALEncoder{json.NewEncoder(rw)}, rw
+	case SirenContentType:
+		return SirenEncoder{json.NewEncoder(rw)}, rw
+	case CollectionJSONContentType:
+		return CollectionJSONEncoder{json.NewEncoder(rw)}, rw
+	}
+	switch renderFormat(mediaType) {
+	case "json":
+		return JSONEncoder{json.NewEncoder(rw)}, rw
+	case "xml":
 		return XMLEncoder{xml.NewEncoder(rw)}, rw
+	case "msgpack":
+		return MsgPackEncoder{rw}, rw
+	case "csv":
+		return CSVEncoder{Writer: rw}, rw
 	}
-
-	return NullEncoder{}, rw
+	return HTMLEncoder{rw}, rw
 }
@@ -85,3 +85,32 @@ func (suite *HyperdriveTestSuite) TestGetEncoderNULL() {
 	enc, _ := GetEncoder(httptest.NewRecorder(), "text/plain")
 	suite.IsType(NullEncoder{}, enc, "return a NullEncoder")
 }
+
+func (suite *HyperdriveTestSuite) TestMsgPackEncoderEncode() {
+	rw := httptest.NewRecorder()
+	enc := MsgPackEncoder{Writer: rw}
+	suite.Nil(enc.Encode(struct {
+		ID   int      `json:"id"`
+		Tags []string `json:"tags"`
+	}{300, []string{"a"}}), "returns nil")
+	suite.Equal([]byte{0x82, 0xa2, 'i', 'd', 0xd1, 0x01, 0x2c, 0xa4, 't', 'a', 'g', 's', 0x91, 0xa1, 'a'}, rw.Body.Bytes(), "encodes as MessagePack")
+}
+
+func (suite *HyperdriveTestSuite) TestHTMLEncoderEncode() {
+	rw := httptest.NewRecorder()
+	enc := HTMLEncoder{Writer: rw}
+	suite.Nil(enc.Encode(map[string]string{"name": "<b>"}), "returns nil")
+	suite.Contains(rw.Body.String(), "<pre>{\n  &#34;name&#34;: &#34;\\u003cb\\u003e&#34;\n}</pre>", "renders the value as escaped JSON")
+}
+
+func (suite *HyperdriveTestSuite) TestGetEncoderMsgPack() {
+	enc, _ := GetEncoder(httptest.NewRecorder(), "application/vnd.api.test.v1+msgpack")
+	suite.IsType(MsgPackEncoder{}, enc, "return a MsgPackEncoder")
+}
+
+func (suite *HyperdriveTestSuite) TestGetEncoderHTML() {
+	rw := httptest.NewRecorder()
+	enc, _ := GetEncoder(rw, "text/html")
+	suite.IsType(HTMLEncoder{}, enc, "return an HTMLEncoder")
+	suite.Equal("text/html", rw.Header().Get("Content-Type"), "sets the Content-Type")
+}
@@ -58,6 +58,46 @@ type OptionsHandler interface {
 	Options(http.ResponseWriter, *http.Request)
 }
 
+// GetErrorHandler interface is satisfied if the endpoint has implemented
+// a method called Get() which returns an error, as an alternative to
+// GetHandler. A returned error is responded to with WriteError, e.g.
+//
+//	func (e *WidgetEndpoint) Get(rw http.ResponseWriter, r *http.Request) error {
+//		w, ok := e.store.Find(mux.Vars(r)["id"])
+//		if !ok {
+//			return hyperdrive.NotFound("No widget has this id.")
+//		}
+//		hyperdrive.Respond(rw, r, http.StatusOK, w)
+//		return nil
+//	}
+type GetErrorHandler interface {
+	Get(http.ResponseWriter, *http.Request) error
+}
+
+// PostErrorHandler interface is satisfied if the endpoint has implemented
+// a method called Post() which returns an error (see GetErrorHandler).
+type PostErrorHandler interface {
+	Post(http.ResponseWriter, *http.Request) error
+}
+
+// PutErrorHandler interface is satisfied if the endpoint has implemented
+// a method called Put() which returns an error (see GetErrorHandler).
+type PutErrorHandler interface {
+	Put(http.ResponseWriter, *http.Request) error
+}
+
+// PatchErrorHandler interface is satisfied if the endpoint has implemented
+// a method called Patch() which returns an error (see GetErrorHandler).
+type PatchErrorHandler interface {
+	Patch(http.ResponseWriter, *http.Request) error
+}
+
+// DeleteErrorHandler interface is satisfied if the endpoint has implemented
+// a method called Delete() which returns an error (see GetErrorHandler).
+type DeleteErrorHandler interface {
+	Delete(http.ResponseWriter, *http.Request) error
+}
+
 // Endpointer interface provides flexibility in how endpoints are created
 // allowing for expressiveness in how developers make use of the hyperdrive
 // package.
@@ -132,28 +172,15 @@ func NewEndpoint(name string, desc string, path string, version string) *Endpoin
 
 // GetMethods returns a slice of the methods an Endpoint supports.
 func GetMethods(e Endpointer) []string {
-	var methods = []string{"OPTIONS"}
-
-	if _, ok := interface{}(e).(GetHandler); ok {
-		methods = append(methods, "GET")
-	}
-
-	if _, ok := interface{}(e).(PostHandler); ok {
-		methods = append(methods, "POST")
-	}
-
-	if _, ok := interface{}(e).(PutHandler); ok {
-		methods = append(methods, "PUT")
-	}
-
-	if _, ok := interface{}(e).(PatchHandler); ok {
-		methods = append(methods, "PATCH")
-	}
-
-	if _, ok := interface{}(e).(DeleteHandler); ok {
-		methods = append(methods, "DELETE")
+	var (
+		methods = []string{"OPTIONS"}
+		handled = methodHandlers(e)
+	)
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		if _, ok := handled[method]; ok {
+			methods = append(methods, method)
+		}
 	}
-
 	return methods
 }
 
@@ -164,27 +191,50 @@ func GetMethodsList(e Endpointer) string {
 
 // NewMethodHandler sets the correct http.Handler for each method, depending on
 // the interfaces the Endpointer supports. It returns an http.Handler, ready
-// to be served directly, wrapped in other middleware, etc.
+// to be served directly, wrapped in other middleware, etc. Other methods are
+// responded to with a 405 Method Not Allowed problem (see RespondProblem).
+// Errors returned by method handlers which return one (e.g. GetErrorHandler)
+// are responded to with WriteError, so an *Error renders as its status.
 func NewMethodHandler(e Endpointer) http.Handler {
+	handler := methodHandlers(e)
+	if h, ok := interface{}(e).(LastModifiedHandler); ok {
+		return lastModifiedHandlerFunc(h, problemMethodHandler(handler))
+	}
+	return problemMethodHandler(handler)
+}
+
+// methodHandlers returns the http.Handler for each method the Endpointer
+// supports.
+func methodHandlers(e Endpointer) handlers.MethodHandler {
 	handler := make(handlers.MethodHandler)
 	if h, ok := interface{}(e).(GetHandler); ok {
 		handler["GET"] = http.HandlerFunc(h.Get)
+	} else if h, ok := interface{}(e).(GetErrorHandler); ok {
+		handler["GET"] = errorHandlerFunc(h.Get)
 	}
 
 	if h, ok := interface{}(e).(PostHandler); ok {
 		handler["POST"] = http.HandlerFunc(h.Post)
+	} else if h, ok := interface{}(e).(PostErrorHandler); ok {
+		handler["POST"] = errorHandlerFunc(h.Post)
 	}
 
 	if h, ok := interface{}(e).(PutHandler); ok {
 		handler["PUT"] = http.HandlerFunc(h.Put)
+	} else if h, ok := interface{}(e).(PutErrorHandler); ok {
+		handler["PUT"] = errorHandlerFunc(h.Put)
 	}
 
 	if h, ok := interface{}(e).(PatchHandler); ok {
 		handler["PATCH"] = http.HandlerFunc(h.Patch)
+	} else if h, ok := interface{}(e).(PatchErrorHandler); ok {
+		handler["PATCH"] = errorHandlerFunc(h.Patch)
 	}
 
 	if h, ok := interface{}(e).(DeleteHandler); ok {
 		handler["DELETE"] = http.HandlerFunc(h.Delete)
+	} else if h, ok := interface{}(e).(DeleteErrorHandler); ok {
+		handler["DELETE"] = errorHandlerFunc(h.Delete)
 	}
 
 	if h, ok := interface{}(e).(OptionsHandler); ok {
@@ -193,17 +243,95 @@ func NewMethodHandler(e Endpointer) http.Handler {
 	return handler
 }
 
+// errorHandlerFunc returns an http.Handler which calls the method handler,
+// and responds to the error it returns, if any, with WriteError.
+func errorHandlerFunc(f func(http.ResponseWriter, *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if err := f(rw, r); err != nil {
+			WriteError(rw, err)
+		}
+	})
+}
+
 // Respond is a helper function to make it easy for an Endpointer's method
-// handler (e.g. GetHandler) to respond with the appropriate Content-Type.
+// handler (e.g. GetHandler) to respond with the appropriate Content-Type:
+// handlers pass a neutral value, and its representation is negotiated from
+// the Accept header (see Negotiate). If none is acceptable, Respond responds
+// with a 406 Not Acceptable.
+// JSON is written in canonical form (see CanonicalJSON) when
+// UsesCanonicalJSON is true for the request, and otherwise indented when
+// UsesPrettyJSON is. The bodies of enveloped endpoints (see Enveloper) are
+// written in an Envelope. When the fields query param asks for a sparse
+// fieldset, e.g. ?fields=id,owner.email, only those fields of the body are
+// written (see FilterFields), unless it is negotiated as XML, CSV, or by a
+// Codec.
+// When UsesETag is true for the request, 200 OK responses have an ETag of
+// the body, and are responded to with a 304 Not Modified when it matches
+// If-None-Match (see NotModifiedBody).
+//
+// Slices and channels of structs are written as CSV (see CSVEncoder) when
+// text/csv is negotiated, or asked for with ?format=csv, whatever the Accept
+// header (as an attachment, so browsers download it), streaming the rows of
+// a channel as they are received, until the request's context is done (so
+// they have no ETag).
+//
+// When LIST_MAX_ITEMS is set, lists with more items are counted as
+// oversized_lists in the request's Ledger, and logged, or, when
+// LIST_LIMIT_ACTION is paginate (the default) and CURSOR_SECRET is set,
+// paginated: only LIST_MAX_ITEMS items are written, from the offset of the
+// Cursor in the cursor query param, with a Link header to the next page.
 func Respond(rw http.ResponseWriter, r *http.Request, status int, body interface{}, headers ...http.Header) (http.ResponseWriter, *http.Request) {
-	var enc ContentEncoder
-	enc, rw = GetEncoder(rw, r.Header.Get("Accept"))
+	var (
+		enc ContentEncoder
+		ok  bool
+	)
+	if body, ok = limitList(rw, r, body); !ok {
+		return rw, r
+	}
+	w := rw
+	if status == http.StatusOK && UsesETag(r) && !isChannel(body) {
+		w = &etagWriter{ResponseWriter: rw}
+	}
+	enc, w = GetEncoder(w, acceptHeader(r))
+	if _, ok := enc.(NullEncoder); ok {
+		writeProblem(rw, http.StatusNotAcceptable, "None of the media types in the Accept header can be rendered.")
+		return rw, r
+	}
+	if c, ok := enc.(CSVEncoder); ok {
+		c.Context = r.Context()
+		enc = c
+		if asksForCSV(r) {
+			rw.Header().Set("Content-Disposition", "attachment")
+		}
+	}
+	if fields := SparseFields(r); fields != nil && supportsSparseFields(enc) {
+		sparse, err := sparseBody(body, fields)
+		if err != nil {
+			writeProblem(rw, http.StatusInternalServerError, GetErrorText(http.StatusInternalServerError, err))
+			return rw, r
+		}
+		body = sparse
+	}
+	if isEnveloped(rw) && !hasOwnEnvelope(enc) {
+		body = envelopeBody(body)
+	}
+	if _, ok := enc.(JSONEncoder); ok && UsesCanonicalJSON(r) {
+		enc = CanonicalJSONEncoder{Writer: w}
+	} else if UsesPrettyJSON(r) {
+		indentJSON(enc)
+	}
 	err := enc.Encode(body)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		writeProblem(rw, http.StatusInternalServerError, GetErrorText(http.StatusInternalServerError, err))
 		// TODO: Add LOGGING
 		return rw, r
 	}
+	if ew, ok := w.(*etagWriter); ok {
+		if NotModifiedBody(rw, r, ew.body.Bytes()) {
+			return rw, r
+		}
+		rw.Write(ew.body.Bytes())
+	}
 	rw.WriteHeader(status)
 	for _, header := range headers {
 		header.Write(rw)
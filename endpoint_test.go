@@ -1,6 +1,9 @@
 package hyperdrive
 
-import "net/http"
+import (
+	"net/http"
+	"net/http/httptest"
+)
 
 func (suite *HyperdriveTestSuite) TestNewEndpoint() {
 	suite.IsType(&Endpoint{}, suite.TestEndpoint, "expects an instance of hyperdrive.Endpoint")
@@ -42,3 +45,31 @@ func (suite *HyperdriveTestSuite) TestGetMethodsList() {
 func (suite *HyperdriveTestSuite) TestNewMethodHandler() {
 	suite.Implements((*http.Handler)(nil), NewMethodHandler(suite.TestEndpoint), "return an implementation of http.Handler")
 }
+
+type errorEndpoint struct {
+	*Endpoint
+}
+
+func (e *errorEndpoint) Get(rw http.ResponseWriter, r *http.Request) error {
+	return NotFound("No widget has this id.")
+}
+
+func (e *errorEndpoint) Delete(rw http.ResponseWriter, r *http.Request) error {
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (suite *HyperdriveTestSuite) TestNewMethodHandlerWithErrorHandlers() {
+	e := &errorEndpoint{NewEndpoint("Widget", "A widget", "/widgets/{id}", "1")}
+	suite.Equal([]string{"OPTIONS", "GET", "DELETE"}, GetMethods(e), "expects methods returning an error to be supported")
+
+	rw := httptest.NewRecorder()
+	NewMethodHandler(e).ServeHTTP(rw, httptest.NewRequest("GET", "/widgets/1", nil))
+	suite.Equal(http.StatusNotFound, rw.Code, "expects the status of the returned error")
+	suite.Equal(problemContentType, rw.Header().Get("Content-Type"), "expects a problem+json body")
+	suite.Contains(rw.Body.String(), `"code":"not_found"`, "expects the code of the returned error")
+
+	rw = httptest.NewRecorder()
+	NewMethodHandler(e).ServeHTTP(rw, httptest.NewRequest("DELETE", "/widgets/1", nil))
+	suite.Equal(http.StatusNoContent, rw.Code, "expects the handler's own response when no error is returned")
+}
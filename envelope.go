@@ -0,0 +1,114 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Envelope is the body of the responses of enveloped endpoints (see
+// Enveloper): what Respond is given, as Data, anything about it, e.g. its
+// pagination (see Paginate), as Meta, and the problems of error responses,
+// as Errors, e.g.
+//
+//	{"data": {"id": "42", "name": "sprocket"}}
+//	{"data": [...], "meta": {"pagination": {"page": 2, ...}}}
+//	{"data": null, "errors": [{"type": "about:blank", "title": "Not Found", ...}]}
+//
+// Handlers can respond with an Envelope, e.g. to set Meta, and it is written
+// as it is.
+type Envelope struct {
+	Data   interface{} `json:"data" xml:"data"`
+	Meta   interface{} `json:"meta,omitempty" xml:"meta,omitempty"`
+	Errors []Problem   `json:"errors,omitempty" xml:"errors>error,omitempty"`
+}
+
+// Enveloper interface is satisfied by endpoints which implement a method
+// called Envelope(), returning whether their responses are enveloped (see
+// Envelope). It overrides RESPONSE_ENVELOPE, which envelopes the responses
+// of every endpoint when true (default: false), so endpoints can opt in or
+// out of it. AddEndpoint wraps the method handlers of enveloped endpoints in
+// EnvelopeMiddleware.
+type Enveloper interface {
+	Envelope() bool
+}
+
+// EnvelopeMiddleware wraps the given http.Handler, so the bodies written by
+// Respond, RespondJSON, and RespondXML, and the problems written by
+// RespondProblem (e.g. by WriteError), are enveloped (see Envelope). HAL,
+// Siren, and Collection+JSON documents, which have a structure of their
+// own, are not.
+func (api *API) EnvelopeMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(&envelopeWriter{rw}, r)
+	})
+}
+
+// envelopeWriter marks the http.ResponseWriter of an enveloped response.
+type envelopeWriter struct {
+	http.ResponseWriter
+}
+
+func (w *envelopeWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for
+// http.ResponseController.
+func (w *envelopeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// usesEnvelope returns true if the endpoint's responses are enveloped.
+func usesEnvelope(e Endpointer) bool {
+	if env, ok := e.(Enveloper); ok {
+		return env.Envelope()
+	}
+	return conf.ResponseEnvelope
+}
+
+// isEnveloped returns true if the response is enveloped, because it is
+// written by a handler wrapped in EnvelopeMiddleware.
+func isEnveloped(rw http.ResponseWriter) bool {
+	_, ok := rw.(*envelopeWriter)
+	return ok
+}
+
+// paginationMeta is the Meta of an Envelope of a PaginationEnvelope.
+type paginationMeta struct {
+	Pagination PaginationInfo `json:"pagination" xml:"pagination"`
+}
+
+// envelopeBody returns the body of a response in an Envelope, unless it is
+// one already, or a hypermedia document. The items of a PaginationEnvelope
+// are its Data, and its pagination its Meta.
+func envelopeBody(body interface{}) interface{} {
+	switch b := body.(type) {
+	case Envelope, *Envelope, *HALResource, *SirenEntity, *CollectionJSON:
+		return body
+	case PaginationEnvelope:
+		return Envelope{Data: b.Data, Meta: paginationMeta{b.Pagination}}
+	}
+	return Envelope{Data: body}
+}
+
+// hasOwnEnvelope returns true if the encoder writes a hypermedia format,
+// with a structure of its own, or CSV, which has no room for one, and so is
+// not enveloped.
+func hasOwnEnvelope(enc ContentEncoder) bool {
+	switch enc.(type) {
+	case HALEncoder, SirenEncoder, CollectionJSONEncoder, CSVEncoder:
+		return true
+	}
+	return false
+}
+
+// writeEnvelopedProblem responds with the problem, as the Errors of an
+// Envelope, with its status.
+func writeEnvelopedProblem(rw http.ResponseWriter, p Problem) {
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(p.Status)
+	json.NewEncoder(rw).Encode(Envelope{Errors: []Problem{p}})
+}
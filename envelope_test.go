@@ -0,0 +1,74 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+)
+
+type envelopeEndpoint struct {
+	*Endpoint
+	enveloped bool
+}
+
+func (e *envelopeEndpoint) Envelope() bool {
+	return e.enveloped
+}
+
+func (e *envelopeEndpoint) Get(rw http.ResponseWriter, r *http.Request) error {
+	if r.URL.Query().Get("id") == "" {
+		return NotFound("No widget has this id.")
+	}
+	Respond(rw, r, http.StatusOK, map[string]string{"id": r.URL.Query().Get("id")})
+	return nil
+}
+
+func (suite *HyperdriveTestSuite) TestEnvelopeEndpoint() {
+	e := &envelopeEndpoint{NewEndpoint("Widget", "A widget", "/widget", "1"), true}
+	suite.TestAPI.AddEndpoint(e)
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/widget?id=42", nil)
+	r.Header.Set("Accept", GetContentTypeJSON(suite.TestAPI, e))
+	suite.TestAPI.Router.ServeHTTP(rw, r)
+	suite.Equal(http.StatusOK, rw.Code, "expects a 200")
+	suite.JSONEq(`{"data": {"id": "42"}}`, rw.Body.String(), "expects the body in an envelope")
+
+	rw = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/widget", nil)
+	r.Header.Set("Accept", GetContentTypeJSON(suite.TestAPI, e))
+	suite.TestAPI.Router.ServeHTTP(rw, r)
+	suite.Equal(http.StatusNotFound, rw.Code, "expects the status of the error")
+	suite.Equal("application/json; charset=utf-8", rw.Header().Get("Content-Type"), "expects a json body")
+	suite.JSONEq(`{
+		"data": null,
+		"errors": [{"type": "about:blank", "title": "Not Found", "status": 404, "detail": "No widget has this id.", "code": "not_found"}]
+	}`, rw.Body.String(), "expects the problem in the errors of an envelope")
+}
+
+func (suite *HyperdriveTestSuite) TestEnvelopeOptOut() {
+	defer func(c Config) { conf = c }(conf)
+	conf.ResponseEnvelope = true
+	suite.True(usesEnvelope(suite.TestEndpoint), "expects RESPONSE_ENVELOPE to envelope every endpoint")
+	suite.False(usesEnvelope(&envelopeEndpoint{NewEndpoint("Widget", "A widget", "/widget", "1"), false}), "expects endpoints to opt out")
+}
+
+func (suite *HyperdriveTestSuite) TestEnvelopeBody() {
+	suite.Equal(Envelope{Data: []int{1}}, envelopeBody([]int{1}), "expects the body as data")
+	env := Envelope{Data: 1, Meta: map[string]int{"version": 2}}
+	suite.Equal(env, envelopeBody(env), "expects envelopes as they are")
+	res := NewHALResource(nil)
+	suite.Equal(res, envelopeBody(res), "expects hypermedia documents as they are")
+
+	b, err := json.Marshal(envelopeBody(PaginationEnvelope{Data: []int{1}, Pagination: PaginationInfo{Page: 1, PerPage: 25, Total: 1, TotalPages: 1, Links: map[string]string{}}}))
+	suite.Nil(err, "expects no error")
+	suite.JSONEq(`{"data": [1], "meta": {"pagination": {"page": 1, "per_page": 25, "total": 1, "total_pages": 1, "links": {}}}}`, string(b), "expects the pagination as meta")
+}
+
+func (suite *HyperdriveTestSuite) TestEnvelopeMiddlewareRespondJSON() {
+	rw := httptest.NewRecorder()
+	suite.TestAPI.EnvelopeMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		RespondJSON(rw, http.StatusCreated, map[string]int{"a": 1})
+	})).ServeHTTP(rw, httptest.NewRequest("POST", "/test", nil))
+	suite.Equal(http.StatusCreated, rw.Code, "expects the status")
+	suite.JSONEq(`{"data": {"a": 1}}`, rw.Body.String(), "expects the body in an envelope")
+}
@@ -1,6 +1,9 @@
 package hyperdrive
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+)
 
 // GetErrorText helps ensure implementation details are not leaked in production
 // environments. If this is production, it returns the http.StatusText for the
@@ -12,3 +15,126 @@ func GetErrorText(status int, err error) string {
 	}
 	return http.StatusText(status)
 }
+
+// Error is an error with the HTTP Status it is responded to with, a stable
+// Code for clients to switch on, and a Message safe to show them. Cause is
+// the underlying error, if any, which is shown in place of a missing message
+// outside of production (see GetErrorText). WriteError, RespondError, and
+// method handlers which return an error (e.g. GetErrorHandler) respond to it
+// with a problem (see RespondProblem) of its status, with its message as the
+// detail, and its code as the code extension member, e.g.
+//
+//	if w == nil {
+//		return hyperdrive.NotFound("No widget has this id.")
+//	}
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Cause   error
+}
+
+// NewError returns an Error with the given status, code, and message.
+func NewError(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Error returns the message of the error, followed by that of its cause.
+func (e *Error) Error() string {
+	message := e.Message
+	if message == "" {
+		message = http.StatusText(e.Status)
+	}
+	if e.Cause != nil {
+		return message + ": " + e.Cause.Error()
+	}
+	return message
+}
+
+// Unwrap returns the cause of the error, for errors.Is and errors.As.
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// WithCause returns a copy of the error, with the given cause.
+func (e *Error) WithCause(cause error) *Error {
+	c := *e
+	c.Cause = cause
+	return &c
+}
+
+// problem returns the problem the error is responded to with.
+func (e *Error) problem() Problem {
+	detail := e.Message
+	if detail == "" && e.Cause != nil {
+		detail = GetErrorText(e.Status, e.Cause)
+	}
+	p := NewProblem(e.Status, detail)
+	if e.Code != "" {
+		p.Extensions = map[string]interface{}{"code": e.Code}
+	}
+	return p
+}
+
+// errorCode returns the code of the errors returned by the helpers for the
+// given status, e.g. not_found for a 404.
+func errorCode(status int) string {
+	return strings.ToLower(strings.Replace(http.StatusText(status), " ", "_", -1))
+}
+
+// BadRequest returns an Error with a 400 Bad Request status.
+func BadRequest(message string) *Error {
+	return NewError(http.StatusBadRequest, errorCode(http.StatusBadRequest), message)
+}
+
+// Unauthorized returns an Error with a 401 Unauthorized status.
+func Unauthorized(message string) *Error {
+	return NewError(http.StatusUnauthorized, errorCode(http.StatusUnauthorized), message)
+}
+
+// Forbidden returns an Error with a 403 Forbidden status.
+func Forbidden(message string) *Error {
+	return NewError(http.StatusForbidden, errorCode(http.StatusForbidden), message)
+}
+
+// NotFound returns an Error with a 404 Not Found status.
+func NotFound(message string) *Error {
+	return NewError(http.StatusNotFound, errorCode(http.StatusNotFound), message)
+}
+
+// Conflict returns an Error with a 409 Conflict status.
+func Conflict(message string) *Error {
+	return NewError(http.StatusConflict, errorCode(http.StatusConflict), message)
+}
+
+// Gone returns an Error with a 410 Gone status.
+func Gone(message string) *Error {
+	return NewError(http.StatusGone, errorCode(http.StatusGone), message)
+}
+
+// PreconditionFailed returns an Error with a 412 Precondition Failed status.
+func PreconditionFailed(message string) *Error {
+	return NewError(http.StatusPreconditionFailed, errorCode(http.StatusPreconditionFailed), message)
+}
+
+// UnprocessableEntity returns an Error with a 422 Unprocessable Entity
+// status.
+func UnprocessableEntity(message string) *Error {
+	return NewError(http.StatusUnprocessableEntity, errorCode(http.StatusUnprocessableEntity), message)
+}
+
+// TooManyRequests returns an Error with a 429 Too Many Requests status.
+func TooManyRequests(message string) *Error {
+	return NewError(http.StatusTooManyRequests, errorCode(http.StatusTooManyRequests), message)
+}
+
+// Internal returns an Error with a 500 Internal Server Error status, caused
+// by the given error.
+func Internal(cause error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: errorCode(http.StatusInternalServerError), Cause: cause}
+}
+
+// Unavailable returns an Error with a 503 Service Unavailable status.
+func Unavailable(message string) *Error {
+	return NewError(http.StatusServiceUnavailable, errorCode(http.StatusServiceUnavailable), message)
+}
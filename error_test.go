@@ -2,7 +2,9 @@ package hyperdrive
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 )
 
 func (suite *HyperdriveTestSuite) TestGetErrorTextProduction() {
@@ -13,3 +15,60 @@ func (suite *HyperdriveTestSuite) TestGetErrorTextProduction() {
 func (suite *HyperdriveTestSuite) TestGetErrorText() {
 	suite.Equal("Test Error", GetErrorText(406, errors.New("Test Error")), "returns Error Text")
 }
+
+func (suite *HyperdriveTestSuite) TestError() {
+	err := NotFound("No widget has this id.")
+	suite.Equal(http.StatusNotFound, err.Status, "expects a 404")
+	suite.Equal("not_found", err.Code, "expects the code of the status")
+	suite.Equal("No widget has this id.", err.Error(), "expects the message")
+
+	cause := errors.New("connection refused")
+	err = Internal(cause)
+	suite.Equal(http.StatusInternalServerError, err.Status, "expects a 500")
+	suite.Equal("internal_server_error", err.Code, "expects the code of the status")
+	suite.Equal("Internal Server Error: connection refused", err.Error(), "expects the status text and the cause")
+	suite.True(errors.Is(err, cause), "expects the cause to be unwrapped")
+
+	wrapped := Conflict("Already exists.").WithCause(cause)
+	suite.Equal("Already exists.: connection refused", wrapped.Error(), "expects the message and the cause")
+}
+
+func (suite *HyperdriveTestSuite) TestErrorHelpers() {
+	for status, err := range map[int]*Error{
+		http.StatusBadRequest:          BadRequest(""),
+		http.StatusUnauthorized:        Unauthorized(""),
+		http.StatusForbidden:           Forbidden(""),
+		http.StatusNotFound:            NotFound(""),
+		http.StatusConflict:            Conflict(""),
+		http.StatusGone:                Gone(""),
+		http.StatusPreconditionFailed:  PreconditionFailed(""),
+		http.StatusUnprocessableEntity: UnprocessableEntity(""),
+		http.StatusTooManyRequests:     TooManyRequests(""),
+		http.StatusServiceUnavailable:  Unavailable(""),
+	} {
+		suite.Equal(status, err.Status, "expects the status of the helper")
+		suite.NotEmpty(err.Code, "expects a code")
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestWriteErrorWithError() {
+	rw := httptest.NewRecorder()
+	WriteError(rw, fmt.Errorf("finding widget: %w", Conflict("Already exists.")))
+	suite.Equal(http.StatusConflict, rw.Code, "expects the status of the wrapped error")
+	suite.JSONEq(`{"type": "about:blank", "title": "Conflict", "status": 409, "detail": "Already exists.", "code": "conflict"}`, rw.Body.String(), "expects a problem with the code")
+}
+
+func (suite *HyperdriveTestSuite) TestWriteErrorWithErrorCauseProduction() {
+	defer func(c Config) { conf = c }(conf)
+	conf.Env = "production"
+	rw := httptest.NewRecorder()
+	WriteError(rw, Internal(errors.New("connection refused")))
+	suite.Equal(http.StatusInternalServerError, rw.Code, "expects a 500")
+	suite.NotContains(rw.Body.String(), "connection refused", "expects the cause not to be leaked")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondErrorWithError() {
+	rw := httptest.NewRecorder()
+	RespondError(rw, http.StatusInternalServerError, Forbidden("Not yours."))
+	suite.Equal(http.StatusForbidden, rw.Code, "expects the status of the error")
+}
@@ -0,0 +1,99 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+type etagContextKey struct{}
+
+// ETag returns a strong entity tag for the given body, e.g.
+// "3b5d5c3712955042212316173ccf37be": the quoted, hex encoded first 16
+// bytes of its SHA-256 hash, so identical bodies have the same ETag.
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// NotModifiedBody is a helper function to make it easy for an Endpointer's
+// method handler (e.g. GetHandler) to support conditional GET requests with
+// entity tags, as NotModified does with modification times. It sets the
+// ETag header to the ETag of the body about to be written, and returns
+// true, after responding with a `304 Not Modified`, if the request's
+// If-None-Match header shows the client already has it, e.g.:
+//
+//	b, _ := json.Marshal(user)
+//	if hyperdrive.NotModifiedBody(rw, r, b) {
+//		return
+//	}
+//	rw.Write(b)
+//
+// Respond, RespondJSON, and RespondXML do this for every 200 OK response
+// when UsesETag is true for the request.
+func NotModifiedBody(rw http.ResponseWriter, r *http.Request, body []byte) bool {
+	etag := ETag(body)
+	rw.Header().Set("ETag", etag)
+	if (r.Method != "GET" && r.Method != "HEAD") || !noneMatch(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	h := rw.Header()
+	delete(h, "Content-Type")
+	delete(h, "Content-Length")
+	rw.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// noneMatch returns true if the If-None-Match header matches the entity
+// tag, with the weak comparison of RFC 7232.
+func noneMatch(header string, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// WithETag returns a shallow copy of r, for which Respond sets an ETag.
+func WithETag(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), etagContextKey{}, true))
+}
+
+// UsesETag returns true if the render helpers (Respond, and RespondJSON and
+// RespondXML, when given the request in their RenderOptions) set an ETag,
+// computed from the rendered body, on 200 OK responses to the request, and
+// respond with a 304 Not Modified when it matches If-None-Match: when
+// RENDER_ETAGS is true, or the request has been marked with WithETag (e.g.
+// by ETagMiddleware).
+func UsesETag(r *http.Request) bool {
+	etag, _ := r.Context().Value(etagContextKey{}).(bool)
+	return etag || conf.RenderETags
+}
+
+// ETagMiddleware wraps the given http.Handler, so the render helpers set an
+// ETag on the responses to every request it serves (see UsesETag). Set
+// RENDER_ETAGS to true to set them for every endpoint instead.
+func (api *API) ETagMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(rw, WithETag(r))
+	})
+}
+
+// etagWriter holds the body written by Respond, so its ETag can be computed
+// before it is written.
+type etagWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
@@ -0,0 +1,63 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestETag() {
+	suite.Equal(ETag([]byte("a")), ETag([]byte("a")), "expects identical bodies to have the same ETag")
+	suite.NotEqual(ETag([]byte("a")), ETag([]byte("b")), "expects different bodies to have different ETags")
+	suite.Len(ETag([]byte("a")), 34, "expects a quoted hex encoded hash")
+}
+
+func (suite *HyperdriveTestSuite) TestNotModifiedBody() {
+	body := []byte(`{"a":1}`)
+	rw := httptest.NewRecorder()
+	suite.False(NotModifiedBody(rw, httptest.NewRequest("GET", "/test", nil), body), "expects requests without If-None-Match to be modified")
+	suite.Equal(ETag(body), rw.Header().Get("ETag"), "expects the ETag of the body")
+
+	for _, inm := range []string{ETag(body), `"other", W/` + ETag(body), "*"} {
+		rw = httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/test", nil)
+		r.Header.Set("If-None-Match", inm)
+		suite.True(NotModifiedBody(rw, r, body), "expects a matching If-None-Match to be not modified")
+		suite.Equal(http.StatusNotModified, rw.Code, "expects a 304")
+	}
+
+	rw = httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/test", nil)
+	r.Header.Set("If-None-Match", ETag(body))
+	suite.False(NotModifiedBody(rw, r, body), "expects only GET and HEAD requests to be not modified")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondETag() {
+	body := map[string]int{"a": 1}
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Accept", "application/json")
+	Respond(rw, WithETag(r), http.StatusOK, body)
+	etag := rw.Header().Get("ETag")
+	suite.Equal(ETag([]byte(`{"a":1}`+"\n")), etag, "expects the ETag of the rendered body")
+	suite.Equal(`{"a":1}`+"\n", rw.Body.String(), "expects the body")
+
+	rw = httptest.NewRecorder()
+	r.Header.Set("If-None-Match", etag)
+	Respond(rw, WithETag(r), http.StatusOK, body)
+	suite.Equal(http.StatusNotModified, rw.Code, "expects a 304 when the ETag matches")
+	suite.Empty(rw.Body.String(), "expects no body")
+
+	rw = httptest.NewRecorder()
+	Respond(rw, r, http.StatusOK, body)
+	suite.Empty(rw.Header().Get("ETag"), "expects no ETag unless asked for")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondJSONETag() {
+	defer func(c Config) { conf = c }(conf)
+	conf.RenderETags = true
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("If-None-Match", ETag([]byte(`{"a":1}`+"\n")))
+	rw := httptest.NewRecorder()
+	suite.Nil(RespondJSON(rw, http.StatusOK, map[string]int{"a": 1}, RenderOptions{Request: r}), "expects no error")
+	suite.Equal(http.StatusNotModified, rw.Code, "expects a 304 when RENDER_ETAGS is true and the ETag matches")
+}
@@ -0,0 +1,47 @@
+package hyperdrive
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed example
+var exampleFiles embed.FS
+
+// WriteExample writes a complete, runnable example service to dir, as a Go
+// module with the given module path, serving an API with the given name: a
+// CRUD resource, authenticated with API keys, with metrics, and tests. It is
+// kept in step with the package's APIs, so it serves as living documentation;
+// run it with "hyperdrive example" (see cmd/hyperdrive). Existing files are
+// never overwritten.
+func WriteExample(dir string, name string, module string) error {
+	data := struct {
+		Name   string
+		Slug   string
+		Module string
+	}{name, slug(name), module}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return fs.WalkDir(exampleFiles, "example", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		t, err := template.ParseFS(exampleFiles, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), ".tmpl"))
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return fmt.Errorf("could not write example file: %v", err)
+		}
+		defer f.Close()
+		return t.Execute(f, data)
+	})
+}
@@ -0,0 +1,59 @@
+package hyperdrive
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// exampleImporter imports the packages of the generated example from
+// source: hyperdrive from this package's directory, so the example is checked
+// against the code under test, and everything else as the go tool would.
+type exampleImporter struct {
+	source types.ImporterFrom
+}
+
+func (i exampleImporter) Import(path string) (*types.Package, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if path == "github.com/hyperdriven/hyperdrive" {
+		path = "."
+	}
+	return i.source.ImportFrom(path, dir, 0)
+}
+
+func (suite *HyperdriveTestSuite) TestWriteExample() {
+	dir, _ := ioutil.TempDir("", "example")
+	defer os.RemoveAll(dir)
+	suite.Require().Nil(WriteExample(dir, "widgets", "example.com/widgets"), "expects no error")
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range []string{"main.go", "widgets.go", "widgets_test.go"} {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.AllErrors)
+		suite.Require().Nil(err, "expects %s to be valid Go", name)
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: exampleImporter{importer.ForCompiler(fset, "source", nil).(types.ImporterFrom)}}
+	_, err := conf.Check("example.com/widgets", fset, files, nil)
+	suite.Nil(err, "expects the example to compile against the package")
+	mod, _ := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	suite.Contains(string(mod), "module example.com/widgets", "expects the module path")
+	test, _ := ioutil.ReadFile(filepath.Join(dir, "widgets_test.go"))
+	suite.Contains(string(test), "application/vnd.widgets.widgets.v1.json", "expects the media type of the API")
+}
+
+func (suite *HyperdriveTestSuite) TestWriteExampleExisting() {
+	dir, _ := ioutil.TempDir("", "example")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644)
+	suite.Error(WriteExample(dir, "example", "example.com/example"), "expects an error rather than overwriting files")
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "main.go"))
+	suite.Equal("package main\n", string(b), "expects existing files to be left unchanged")
+}
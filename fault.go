@@ -0,0 +1,130 @@
+package hyperdrive
+
+import (
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault configures the faults FaultInjectionMiddleware injects into
+// requests. Percent (0 to 100) of the requests matching Paths (path
+// prefixes, or every path when empty) and Header (when set, only requests
+// which have the header) are delayed by Delay, and then, when Status is set,
+// answered with an error of that status instead of being served.
+type Fault struct {
+	Percent float64
+	Delay   time.Duration
+	Status  int
+	Paths   []string
+	Header  string
+}
+
+// matches returns true if the request is eligible for the fault.
+func (f Fault) matches(r *http.Request) bool {
+	if f.Header != "" && r.Header.Get(f.Header) == "" {
+		return false
+	}
+	if len(f.Paths) == 0 {
+		return true
+	}
+	for _, p := range f.Paths {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// faults holds the Fault injected at runtime, shared by every copy of an
+// API.
+type faults struct {
+	sync.RWMutex
+	fault   Fault
+	enabled bool
+}
+
+func (fs *faults) set(f Fault, enabled bool) {
+	fs.Lock()
+	defer fs.Unlock()
+	fs.fault, fs.enabled = f, enabled
+}
+
+func (fs *faults) get() (Fault, bool) {
+	fs.RLock()
+	defer fs.RUnlock()
+	return fs.fault, fs.enabled
+}
+
+// newFaults returns the faults configured by the FAULT_* environment
+// variables.
+func newFaults() *faults {
+	fs := &faults{}
+	fs.set(Fault{
+		Percent: conf.FaultPercent,
+		Delay:   conf.FaultDelay,
+		Status:  conf.FaultStatus,
+		Paths:   splitList(conf.FaultPaths),
+		Header:  conf.FaultHeader,
+	}, conf.FaultInjection)
+	return fs
+}
+
+// InjectFaults starts injecting the given Fault at runtime, e.g. at the start
+// of a game day, replacing the Fault configured by the environment.
+func (api *API) InjectFaults(f Fault) {
+	api.faults.set(f, true)
+}
+
+// StopFaults stops injecting faults.
+func (api *API) StopFaults() {
+	f, _ := api.faults.get()
+	api.faults.set(f, false)
+}
+
+// InjectingFaults returns the Fault being injected, and true, if faults are
+// being injected.
+func (api *API) InjectingFaults() (Fault, bool) {
+	return api.faults.get()
+}
+
+// FaultInjectionMiddleware wraps the given http.Handler, and injects latency
+// and error responses into a percentage of requests, so the resilience of the
+// API's clients (timeouts, retries, fallbacks) can be tested, e.g. on a game
+// day. It is opt-in: add it to the MiddlewareChain (as "fault-injection" in
+// MIDDLEWARE_CHAIN), and faults are only injected while enabled, either by
+// FAULT_INJECTION, or at runtime with InjectFaults and StopFaults. The Fault
+// is configured via the following environment variables:
+//
+// - FAULT_PERCENT (float, 0 to 100)
+// - FAULT_DELAY (duration, e.g. 500ms)
+// - FAULT_STATUS (int, default: 503, or 0 to only inject latency)
+// - FAULT_PATHS (string, comma separated path prefixes)
+// - FAULT_HEADER (string, e.g. X-Chaos)
+//
+// Injected faults are counted, by kind (delay or error), in the
+// hyperdrive_http_injected_faults_total metric.
+func (api *API) FaultInjectionMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		f, enabled := api.faults.get()
+		if !enabled || !f.matches(r) || rand.Float64()*100 >= f.Percent {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		if f.Delay > 0 {
+			api.metrics.faults.WithLabelValues("delay").Inc()
+			select {
+			case <-time.After(f.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if f.Status == 0 {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		api.metrics.faults.WithLabelValues("error").Inc()
+		writeProblem(rw, f.Status, "This error was injected by FaultInjectionMiddleware.")
+	})
+}
@@ -0,0 +1,46 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestFaultInjectionMiddleware() {
+	defer suite.TestAPI.StopFaults()
+	served := false
+	h := suite.TestAPI.FaultInjectionMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		served = true
+	}))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/users", nil))
+	suite.True(served, "expects no faults to be injected until enabled")
+	suite.TestAPI.InjectFaults(Fault{Percent: 100, Status: http.StatusBadGateway, Paths: []string{"/users"}})
+	served = false
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/users/1", nil))
+	suite.False(served, "expects the handler not to be called")
+	suite.Equal(http.StatusBadGateway, rw.Code, "expects the injected error status")
+	suite.Equal(problemContentType, rw.Header().Get("Content-Type"), "expects a problem response")
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders", nil))
+	suite.True(served, "expects requests to other paths to be served")
+	suite.TestAPI.StopFaults()
+	_, enabled := suite.TestAPI.InjectingFaults()
+	suite.False(enabled, "expects faults to be stopped")
+}
+
+func (suite *HyperdriveTestSuite) TestFaultInjectionMiddlewareDelay() {
+	defer suite.TestAPI.StopFaults()
+	suite.TestAPI.InjectFaults(Fault{Percent: 100, Delay: 20 * time.Millisecond, Header: "X-Chaos"})
+	h := suite.TestAPI.FaultInjectionMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest("GET", "/test", nil)
+	start := time.Now()
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	suite.True(time.Since(start) < 20*time.Millisecond, "expects requests without the header not to be delayed")
+	r.Header.Set("X-Chaos", "1")
+	rw := httptest.NewRecorder()
+	start = time.Now()
+	h.ServeHTTP(rw, r)
+	suite.True(time.Since(start) >= 20*time.Millisecond, "expects the request to be delayed")
+	suite.Equal(http.StatusOK, rw.Code, "expects the request to be served after the delay")
+}
@@ -0,0 +1,198 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"strings"
+)
+
+// FieldsParam is the query param which asks Respond for a sparse fieldset:
+// only the given fields of the body, e.g. ?fields=id,name,owner.email.
+const FieldsParam = "fields"
+
+// fieldTree holds the fields of a sparse fieldset, by name, with the fields
+// of nested objects to keep. A field with no nested fields is kept whole.
+type fieldTree map[string]fieldTree
+
+// SparseFields returns the fields requested in the fields query param, a
+// list split as ParamList, e.g. [id name owner.email], or nil if there is
+// none.
+func SparseFields(r *http.Request) []string {
+	fields := splitParamValues(r.URL.Query()[FieldsParam])
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// newFieldTree returns the tree of the given fields, in which the fields of
+// nested objects are separated by dots, e.g. owner.email. A field given
+// whole, e.g. owner, is kept whole, even if some of its fields are given too.
+func newFieldTree(fields []string) fieldTree {
+	tree := fieldTree{}
+	for _, field := range fields {
+		node := tree
+		names := strings.Split(field, ".")
+		for i, name := range names {
+			if name == "" {
+				break
+			}
+			sub, ok := node[name]
+			if i == len(names)-1 {
+				node[name] = nil
+				break
+			}
+			if ok && sub == nil {
+				break
+			}
+			if !ok {
+				sub = fieldTree{}
+				node[name] = sub
+			}
+			node = sub
+		}
+	}
+	return tree
+}
+
+// FilterFields returns v, as it is encoded as JSON, with only the given
+// fields (see SparseFields) of each object, or of each object in a list.
+// Fields of nested objects are separated by dots, e.g. owner.email keeps
+// the email of the owner, and nothing else of it. Fields which v does not
+// have are ignored.
+func FilterFields(v interface{}, fields []string) (interface{}, error) {
+	var doc interface{}
+	if err := decodeAsJSON(v, &doc); err != nil {
+		return nil, err
+	}
+	return filterFieldTree(doc, newFieldTree(fields)), nil
+}
+
+// filterFieldTree returns the decoded JSON value with only the fields in
+// the tree.
+func filterFieldTree(v interface{}, tree fieldTree) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		filtered := make(map[string]interface{}, len(tree))
+		for name, sub := range tree {
+			value, ok := v[name]
+			if !ok {
+				continue
+			}
+			if sub == nil {
+				filtered[name] = value
+			} else {
+				filtered[name] = filterFieldTree(value, sub)
+			}
+		}
+		return filtered
+	case []interface{}:
+		filtered := make([]interface{}, len(v))
+		for i, item := range v {
+			filtered[i] = filterFieldTree(item, tree)
+		}
+		return filtered
+	}
+	return v
+}
+
+// sparseBody returns the body given to Respond with only the given fields.
+// The structure of envelopes and hypermedia documents is kept, and only the
+// fields of what they hold are filtered: the data of an Envelope or
+// PaginationEnvelope, the items of a Collection, and the properties of a
+// HALResource or SirenEntity, and of those embedded in it.
+func sparseBody(body interface{}, fields []string) (interface{}, error) {
+	switch b := body.(type) {
+	case Envelope:
+		data, err := FilterFields(b.Data, fields)
+		b.Data = data
+		return b, err
+	case PaginationEnvelope:
+		data, err := FilterFields(b.Data, fields)
+		b.Data = data
+		return b, err
+	case *Collection:
+		c := *b
+		c.Items = make([]CollectionItem, len(b.Items))
+		for i, item := range b.Items {
+			data, err := FilterFields(item.Data, fields)
+			if err != nil {
+				return nil, err
+			}
+			c.Items[i] = CollectionItem{Href: item.Href, Data: data}
+		}
+		return &c, nil
+	case *HALResource:
+		return sparseHALResource(b, fields)
+	case *SirenEntity:
+		return sparseSirenEntity(b, fields)
+	}
+	return FilterFields(body, fields)
+}
+
+// sparseHALResource returns a copy of the resource, and those embedded in
+// it, with only the given fields of their properties.
+func sparseHALResource(res *HALResource, fields []string) (*HALResource, error) {
+	sparse := &HALResource{Links: res.Links, Embedded: make(map[string]interface{}, len(res.Embedded))}
+	if res.Properties != nil {
+		properties, err := FilterFields(res.Properties, fields)
+		if err != nil {
+			return nil, err
+		}
+		sparse.Properties = properties
+	}
+	for rel, embedded := range res.Embedded {
+		switch e := embedded.(type) {
+		case *HALResource:
+			sub, err := sparseHALResource(e, fields)
+			if err != nil {
+				return nil, err
+			}
+			sparse.Embedded[rel] = sub
+		case []*HALResource:
+			list := make([]*HALResource, len(e))
+			for i, item := range e {
+				sub, err := sparseHALResource(item, fields)
+				if err != nil {
+					return nil, err
+				}
+				list[i] = sub
+			}
+			sparse.Embedded[rel] = list
+		default:
+			sparse.Embedded[rel] = embedded
+		}
+	}
+	return sparse, nil
+}
+
+// sparseSirenEntity returns a copy of the entity, and its sub-entities,
+// with only the given fields of their properties.
+func sparseSirenEntity(ent *SirenEntity, fields []string) (*SirenEntity, error) {
+	sparse := *ent
+	if ent.Properties != nil {
+		properties, err := FilterFields(ent.Properties, fields)
+		if err != nil {
+			return nil, err
+		}
+		sparse.Properties = properties
+	}
+	sparse.Entities = make([]*SirenEntity, len(ent.Entities))
+	for i, sub := range ent.Entities {
+		s, err := sparseSirenEntity(sub, fields)
+		if err != nil {
+			return nil, err
+		}
+		sparse.Entities[i] = s
+	}
+	return &sparse, nil
+}
+
+// supportsSparseFields returns true if the encoder writes JSON, or a format
+// based on it, whose output can be filtered by sparseBody.
+func supportsSparseFields(enc ContentEncoder) bool {
+	switch enc.(type) {
+	case XMLEncoder, CodecEncoder, CSVEncoder:
+		return false
+	}
+	return true
+}
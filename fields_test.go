@@ -0,0 +1,90 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+type fieldsOwner struct {
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+type fieldsWidget struct {
+	ID     string      `json:"id"`
+	Name   string      `json:"name"`
+	Secret string      `json:"secret"`
+	Owner  fieldsOwner `json:"owner"`
+}
+
+func (suite *HyperdriveTestSuite) testFieldsWidget() fieldsWidget {
+	return fieldsWidget{ID: "42", Name: "sprocket", Secret: "s3cr3t", Owner: fieldsOwner{Email: "a@example.com", Phone: "555"}}
+}
+
+func (suite *HyperdriveTestSuite) TestSparseFields() {
+	suite.Nil(SparseFields(httptest.NewRequest("GET", "/widgets", nil)), "expects no fields when none are requested")
+	suite.Equal([]string{"id", "name", "owner.email"}, SparseFields(httptest.NewRequest("GET", "/widgets?fields=id,name,owner.email", nil)), "expects the fields requested")
+}
+
+func (suite *HyperdriveTestSuite) TestFilterFields() {
+	v, err := FilterFields([]fieldsWidget{suite.testFieldsWidget()}, []string{"id", "owner.email", "missing"})
+	suite.Nil(err, "expects no error")
+	suite.Equal([]interface{}{map[string]interface{}{
+		"id":    "42",
+		"owner": map[string]interface{}{"email": "a@example.com"},
+	}}, v, "expects only the fields requested, of each item")
+
+	v, err = FilterFields(suite.testFieldsWidget(), []string{"owner.email", "owner"})
+	suite.Nil(err, "expects no error")
+	suite.Equal(map[string]interface{}{
+		"owner": map[string]interface{}{"email": "a@example.com", "phone": "555"},
+	}, v, "expects fields requested whole to be kept whole")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondSparseFields() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/widgets/42?fields=id,owner.email", nil)
+	r.Header.Set("Accept", "application/json")
+	Respond(rw, r, http.StatusOK, suite.testFieldsWidget())
+	suite.JSONEq(`{"id": "42", "owner": {"email": "a@example.com"}}`, rw.Body.String(), "expects only the fields requested")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondSparseFieldsHAL() {
+	res := NewHALResource(suite.testFieldsWidget())
+	res.AddLink("self", HALLink{Href: "/widgets/42"})
+	res.EmbedList("parts", []*HALResource{NewHALResource(map[string]string{"id": "7", "name": "cog"})})
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/widgets/42?fields=id", nil)
+	r.Header.Set("Accept", HALContentType)
+	Respond(rw, r, http.StatusOK, res)
+	suite.JSONEq(`{
+		"id": "42",
+		"_links": {"self": {"href": "/widgets/42"}},
+		"_embedded": {"parts": [{"id": "7"}]}
+	}`, rw.Body.String(), "expects the fields of the properties to be filtered, and the links to be kept")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondSparseFieldsSiren() {
+	ent := NewSirenEntity(suite.testFieldsWidget(), "widget")
+	ent.AddEntity(NewSirenEntity(map[string]string{"id": "7", "name": "cog"}, "part"), "item")
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/widgets/42?fields=name", nil)
+	r.Header.Set("Accept", SirenContentType)
+	Respond(rw, r, http.StatusOK, ent)
+	suite.JSONEq(`{
+		"class": ["widget"],
+		"properties": {"name": "sprocket"},
+		"entities": [{"class": ["part"], "rel": ["item"], "properties": {"name": "cog"}}]
+	}`, rw.Body.String(), "expects the fields of the properties to be filtered")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondSparseFieldsCollection() {
+	c := &Collection{Name: "widgets", Href: "/widgets", Total: 1}
+	c.AddItem("/widgets/42", suite.testFieldsWidget())
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/widgets?fields=id", nil)
+	r.Header.Set("Accept", "application/json")
+	Respond(rw, r, http.StatusOK, c)
+	suite.JSONEq(`{"name": "widgets", "href": "/widgets", "total": 1, "items": [{"href": "/widgets/42", "data": {"id": "42"}}]}`, rw.Body.String(), "expects the fields of the items to be filtered")
+	suite.Len(c.Items[0].Data.(fieldsWidget).Secret, 6, "expects the collection not to be changed")
+}
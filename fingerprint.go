@@ -0,0 +1,241 @@
+package hyperdrive
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The TLS extensions left out of the JA4 extensions hash.
+const (
+	extensionServerName = 0x0000
+	extensionALPN       = 0x0010
+)
+
+// ClientFingerprint identifies the software a client is running, rather than
+// the client itself, for abuse detection and analytics: requests from the
+// same library or browser share a fingerprint, even as their addresses and
+// User-Agent headers change.
+//
+// JA3 (the MD5 hash of the JA3 string) and JA4 fingerprint the TLS
+// ClientHello, and are only set when the API terminates TLS, and
+// CLIENT_FINGERPRINTS is true. Header is a JA4H style fingerprint of the
+// request headers: since Go does not preserve the order headers were sent in,
+// the hash is of the sorted header names.
+type ClientFingerprint struct {
+	JA3    string `json:"ja3,omitempty"`
+	JA4    string `json:"ja4,omitempty"`
+	Header string `json:"header"`
+}
+
+// GetClientFingerprint returns the ClientFingerprint of the given request.
+func GetClientFingerprint(r *http.Request) ClientFingerprint {
+	fp := ClientFingerprint{Header: headerFingerprint(r)}
+	if t, ok := r.Context().Value(tlsFingerprintContextKey{}).(*tlsFingerprint); ok {
+		fp.JA3, fp.JA4 = t.ja3, t.ja4
+	}
+	return fp
+}
+
+type tlsFingerprintContextKey struct{}
+
+// tlsFingerprint holds the fingerprints of a connection's ClientHello. It is
+// created when the connection is accepted, and set during the handshake,
+// before any request on the connection is handled.
+type tlsFingerprint struct {
+	ja3 string
+	ja4 string
+}
+
+// fingerprintClientHellos configures the given http.Server to fingerprint the
+// TLS ClientHello of every connection, for GetClientFingerprint.
+func fingerprintClientHellos(s *http.Server) {
+	var conns sync.Map
+	s.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		tc, ok := c.(*tls.Conn)
+		if !ok {
+			return ctx
+		}
+		t := &tlsFingerprint{}
+		conns.Store(tc.NetConn(), t)
+		return context.WithValue(ctx, tlsFingerprintContextKey{}, t)
+	}
+	s.ConnState = func(c net.Conn, state http.ConnState) {
+		if tc, ok := c.(*tls.Conn); ok && (state == http.StateClosed || state == http.StateHijacked) {
+			conns.Delete(tc.NetConn())
+		}
+	}
+	if s.TLSConfig == nil {
+		s.TLSConfig = &tls.Config{}
+	}
+	s.TLSConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if t, ok := conns.LoadAndDelete(hello.Conn); ok {
+			t.(*tlsFingerprint).ja3 = ja3(hello)
+			t.(*tlsFingerprint).ja4 = ja4(hello)
+		}
+		return nil, nil
+	}
+}
+
+// isGREASE returns true for the reserved GREASE values (RFC 8701), which
+// clients send at random, and are ignored by fingerprints.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func withoutGREASE(values []uint16) []uint16 {
+	var filtered []uint16
+	for _, v := range values {
+		if !isGREASE(v) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
+
+// joinUint16 formats each value as a decimal, or as 4 hex digits, and joins
+// them with sep.
+func joinUint16(values []uint16, hexadecimal bool, sep string) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		if hexadecimal {
+			s[i] = fmt.Sprintf("%04x", v)
+		} else {
+			s[i] = strconv.Itoa(int(v))
+		}
+	}
+	return strings.Join(s, sep)
+}
+
+// helloVersion returns the version in the ClientHello's legacy_version field,
+// which is TLS 1.2 for clients supporting TLS 1.3, or the highest version
+// offered.
+func helloVersion(hello *tls.ClientHelloInfo, highest bool) uint16 {
+	versions := withoutGREASE(hello.SupportedVersions)
+	var max uint16
+	for _, v := range versions {
+		if v > max {
+			max = v
+		}
+	}
+	if !highest && max > tls.VersionTLS12 {
+		return tls.VersionTLS12
+	}
+	return max
+}
+
+// ja3 returns the MD5 hash of the JA3 string of the ClientHello: its version,
+// cipher suites, extensions, curves, and point formats.
+func ja3(hello *tls.ClientHelloInfo) string {
+	points := make([]uint16, len(hello.SupportedPoints))
+	for i, p := range hello.SupportedPoints {
+		points[i] = uint16(p)
+	}
+	curves := make([]uint16, len(hello.SupportedCurves))
+	for i, c := range hello.SupportedCurves {
+		curves[i] = uint16(c)
+	}
+	s := strings.Join([]string{
+		strconv.Itoa(int(helloVersion(hello, false))),
+		joinUint16(withoutGREASE(hello.CipherSuites), false, "-"),
+		joinUint16(withoutGREASE(hello.Extensions), false, "-"),
+		joinUint16(withoutGREASE(curves), false, "-"),
+		joinUint16(points, false, "-"),
+	}, ",")
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// ja4 returns the JA4 fingerprint of the ClientHello, e.g.
+// t13d1516h2_8daaf6152771_e5627efa2ab1.
+func ja4(hello *tls.ClientHelloInfo) string {
+	version := map[uint16]string{
+		tls.VersionTLS13: "13",
+		tls.VersionTLS12: "12",
+		tls.VersionTLS11: "11",
+		tls.VersionTLS10: "10",
+		tls.VersionSSL30: "s3",
+	}[helloVersion(hello, true)]
+	if version == "" {
+		version = "00"
+	}
+	sni := "i"
+	if hello.ServerName != "" {
+		sni = "d"
+	}
+	alpn := "00"
+	if len(hello.SupportedProtos) > 0 && hello.SupportedProtos[0] != "" {
+		p := hello.SupportedProtos[0]
+		alpn = p[:1] + p[len(p)-1:]
+	}
+	ciphers := withoutGREASE(hello.CipherSuites)
+	extensions := withoutGREASE(hello.Extensions)
+	a := fmt.Sprintf("t%s%s%02d%02d%s", version, sni, min(len(ciphers), 99), min(len(extensions), 99), alpn)
+
+	sorted := append([]uint16(nil), ciphers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	b := truncatedHash(joinUint16(sorted, true, ","), len(sorted) == 0)
+
+	sorted = nil
+	for _, e := range extensions {
+		if e != extensionServerName && e != extensionALPN {
+			sorted = append(sorted, e)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	c := joinUint16(sorted, true, ",")
+	if len(hello.SignatureSchemes) > 0 {
+		schemes := make([]uint16, len(hello.SignatureSchemes))
+		for i, s := range hello.SignatureSchemes {
+			schemes[i] = uint16(s)
+		}
+		c += "_" + joinUint16(schemes, true, ",")
+	}
+	return a + "_" + b + "_" + truncatedHash(c, len(sorted) == 0)
+}
+
+// truncatedHash returns the first 12 hex characters of the SHA-256 hash of
+// s, or zeros if there was nothing to hash.
+func truncatedHash(s string, empty bool) string {
+	if empty {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// headerFingerprint returns a JA4H style fingerprint of the request: its
+// method, HTTP version, whether it has cookies and a referer, the number of
+// headers, and first Accept-Language, followed by a hash of the header names.
+func headerFingerprint(r *http.Request) string {
+	method := strings.ToLower(r.Method)
+	if len(method) > 2 {
+		method = method[:2]
+	}
+	cookie, referer := "n", "n"
+	var names []string
+	for name := range r.Header {
+		switch name {
+		case "Cookie":
+			cookie = "c"
+		case "Referer":
+			referer = "r"
+		default:
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	sort.Strings(names)
+	lang := strings.ToLower(strings.Replace(strings.SplitN(strings.SplitN(r.Header.Get("Accept-Language"), ",", 2)[0], ";", 2)[0], "-", "", -1))
+	lang = (strings.TrimSpace(lang) + "0000")[:4]
+	a := fmt.Sprintf("%s%d%d%s%s%02d%s", method, r.ProtoMajor, r.ProtoMinor, cookie, referer, min(len(names), 99), lang)
+	return a + "_" + truncatedHash(strings.Join(names, ","), len(names) == 0)
+}
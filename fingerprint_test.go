@@ -0,0 +1,56 @@
+package hyperdrive
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+)
+
+func (suite *HyperdriveTestSuite) TestGetClientFingerprintHeader() {
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	r.Header.Set("User-Agent", "test")
+	r.Header.Set("Cookie", "a=b")
+	fp := GetClientFingerprint(r)
+	suite.Equal("ge11cn02enus_"+truncatedHash("accept-language,user-agent", false), fp.Header, "expects a fingerprint of the headers")
+	suite.Empty(fp.JA3, "expects no TLS fingerprint without TLS")
+	r.Header.Set("X-Extra", "1")
+	suite.NotEqual(fp.Header, GetClientFingerprint(r).Header, "expects the fingerprint to change with the headers sent")
+	r.Header.Set("X-Extra", "2")
+	suite.Equal(GetClientFingerprint(r).Header[:13], "ge11cn03enus_", "expects the header count")
+}
+
+func (suite *HyperdriveTestSuite) TestGetClientFingerprintTLS() {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(rw).Encode(GetClientFingerprint(r))
+	}))
+	fingerprintClientHellos(ts.Config)
+	ts.TLS = ts.Config.TLSConfig
+	ts.StartTLS()
+	defer ts.Close()
+	client := ts.Client()
+	client.Transport.(*http.Transport).TLSClientConfig.NextProtos = []string{"http/1.1"}
+	var fp ClientFingerprint
+	for i := 0; i < 2; i++ {
+		res, err := client.Get(ts.URL)
+		suite.Nil(err, "expects no error")
+		json.NewDecoder(res.Body).Decode(&fp)
+		res.Body.Close()
+		suite.Regexp(regexp.MustCompile(`^[0-9a-f]{32}$`), fp.JA3, "expects a JA3 hash")
+		suite.Regexp(regexp.MustCompile(`^t13i\d{4}h1_[0-9a-f]{12}_[0-9a-f]{12}$`), fp.JA4, "expects a JA4 fingerprint of a TLS 1.3 client, without SNI, offering HTTP/1.1")
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestJA4() {
+	hello := &tls.ClientHelloInfo{
+		CipherSuites:      []uint16{0x0a0a, tls.TLS_AES_128_GCM_SHA256, tls.TLS_AES_256_GCM_SHA384},
+		ServerName:        "example.com",
+		SupportedVersions: []uint16{0x1a1a, tls.VersionTLS13, tls.VersionTLS12},
+		SupportedProtos:   []string{"h2", "http/1.1"},
+		Extensions:        []uint16{0x0000, 0x0010, 0x002b, 0x000d},
+		SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+	}
+	suite.Equal("t13d0204h2_"+truncatedHash("1301,1302", false)+"_"+truncatedHash("000d,002b_0403", false), ja4(hello), "expects GREASE values, SNI and ALPN to be left out of the hashes")
+}
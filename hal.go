@@ -0,0 +1,179 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// HALContentType is the media type of HAL documents.
+const HALContentType = "application/hal+json"
+
+// HALLink is a link of a HALResource. Templated links have an Href which is
+// a URI Template, to be expanded by clients (see ExpandLinkTemplate).
+type HALLink struct {
+	Href      string `json:"href"`
+	Templated bool   `json:"templated,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+// HALResource is a HAL (application/hal+json) representation of a resource:
+// its Properties (a struct or map, encoded as a JSON object), with its links
+// in _links, and the resources embedded in it in _embedded, e.g.
+//
+//	res := hyperdrive.NewHALResource(user)
+//	res.AddSelfLink(r)
+//	res.AddLink("orders", hyperdrive.HALLink{Href: "/users/42/orders{?page}", Templated: true})
+//	res.Embed("address", hyperdrive.NewHALResource(address))
+//	hyperdrive.RespondHAL(rw, http.StatusOK, res)
+//
+// A rel with one link is written as a link object, and one with several as
+// an array of them.
+type HALResource struct {
+	Properties interface{}
+	Links      map[string][]HALLink
+	Embedded   map[string]interface{}
+}
+
+// NewHALResource returns a HALResource with the given properties.
+func NewHALResource(properties interface{}) *HALResource {
+	return &HALResource{Properties: properties, Links: map[string][]HALLink{}, Embedded: map[string]interface{}{}}
+}
+
+// AddLink adds a link with the given rel.
+func (res *HALResource) AddLink(rel string, link HALLink) {
+	if res.Links == nil {
+		res.Links = map[string][]HALLink{}
+	}
+	res.Links[rel] = append(res.Links[rel], link)
+}
+
+// AddSelfLink adds a self link to the URL of the request, relative to the
+// API's host.
+func (res *HALResource) AddSelfLink(r *http.Request) {
+	res.AddLink("self", HALLink{Href: r.URL.RequestURI()})
+}
+
+// AddCollectionLink adds a collection link, to the list the resource belongs
+// to.
+func (res *HALResource) AddCollectionLink(href string) {
+	res.AddLink("collection", HALLink{Href: href})
+}
+
+// AddPaginationLinks adds first, prev, next and last links, to the pages of
+// the list requested, as returned by PageLinks.
+func (res *HALResource) AddPaginationLinks(r *http.Request, q ListQuery, total int) {
+	links := PageLinks(r, q, total)
+	for _, rel := range pageRels {
+		if href, ok := links[rel]; ok {
+			res.AddLink(rel, HALLink{Href: href})
+		}
+	}
+}
+
+// Embed embeds a resource with the given rel.
+func (res *HALResource) Embed(rel string, embedded *HALResource) {
+	if res.Embedded == nil {
+		res.Embedded = map[string]interface{}{}
+	}
+	res.Embedded[rel] = embedded
+}
+
+// EmbedList embeds a list of resources with the given rel, which is written
+// as an array even if it has a single resource, e.g. the items of a page.
+func (res *HALResource) EmbedList(rel string, embedded []*HALResource) {
+	if res.Embedded == nil {
+		res.Embedded = map[string]interface{}{}
+	}
+	if embedded == nil {
+		embedded = []*HALResource{}
+	}
+	res.Embedded[rel] = embedded
+}
+
+// MarshalJSON encodes the resource as a HAL document.
+func (res *HALResource) MarshalJSON() ([]byte, error) {
+	doc := map[string]interface{}{}
+	if res.Properties != nil {
+		if err := decodeAsJSON(res.Properties, &doc); err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			return nil, errors.New("hyperdrive: HAL properties must be encoded as a JSON object")
+		}
+	}
+	if len(res.Links) > 0 {
+		links := make(map[string]interface{}, len(res.Links))
+		for rel, l := range res.Links {
+			if len(l) == 1 {
+				links[rel] = l[0]
+			} else {
+				links[rel] = l
+			}
+		}
+		doc["_links"] = links
+	}
+	if len(res.Embedded) > 0 {
+		doc["_embedded"] = res.Embedded
+	}
+	return json.Marshal(doc)
+}
+
+// RespondHAL responds with the given status, and the resource as an
+// application/hal+json document, as RespondJSON does.
+func RespondHAL(rw http.ResponseWriter, status int, res *HALResource) error {
+	return RespondJSON(rw, status, res, RenderOptions{ContentType: HALContentType})
+}
+
+// HALEncoder is an implementation of ContentEncoder which writes HAL
+// documents. A *Collection is written as returned by its HAL method, and
+// other values as they are.
+type HALEncoder struct {
+	Encoder *json.Encoder
+}
+
+// Encode encodes input as a HAL document or returns an error.
+func (enc HALEncoder) Encode(v interface{}) error {
+	if c, ok := v.(*Collection); ok {
+		v = c.HAL()
+	}
+	return enc.Encoder.Encode(v)
+}
+
+// linkTemplateExpression matches the expressions of a URI Template.
+var linkTemplateExpression = regexp.MustCompile(`\{([?&]?)([^}]*)\}`)
+
+// ExpandLinkTemplate expands the href of a templated HALLink with the given
+// variables, supporting simple expressions ({id}), and form-style query
+// expressions ({?page,per_page} and {&sort}) of RFC 6570 URI Templates.
+// Variables which are not given are left out.
+func ExpandLinkTemplate(template string, vars map[string]string) string {
+	return linkTemplateExpression.ReplaceAllStringFunc(template, func(expr string) string {
+		m := linkTemplateExpression.FindStringSubmatch(expr)
+		op, names := m[1], strings.Split(m[2], ",")
+		if op == "" {
+			var values []string
+			for _, name := range names {
+				if v, ok := vars[name]; ok {
+					values = append(values, url.PathEscape(v))
+				}
+			}
+			return strings.Join(values, ",")
+		}
+		var pairs []string
+		for _, name := range names {
+			if v, ok := vars[name]; ok {
+				pairs = append(pairs, url.QueryEscape(name)+"="+url.QueryEscape(v))
+			}
+		}
+		if len(pairs) == 0 {
+			return ""
+		}
+		return op + strings.Join(pairs, "&")
+	})
+}
@@ -0,0 +1,83 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+)
+
+type halUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func (suite *HyperdriveTestSuite) TestHALResourceMarshalJSON() {
+	res := NewHALResource(halUser{ID: 42, Name: "test"})
+	res.AddSelfLink(httptest.NewRequest("GET", "/users/42?fields=name", nil))
+	res.AddCollectionLink("/users")
+	res.AddLink("orders", HALLink{Href: "/users/42/orders{?page}", Templated: true})
+	res.AddLink("friend", HALLink{Href: "/users/1"})
+	res.AddLink("friend", HALLink{Href: "/users/2"})
+	res.Embed("manager", NewHALResource(halUser{ID: 7, Name: "boss"}))
+	res.EmbedList("teams", nil)
+	b, err := json.Marshal(res)
+	suite.Nil(err, "expects no error")
+	suite.JSONEq(`{
+		"id": 42,
+		"name": "test",
+		"_links": {
+			"self": {"href": "/users/42?fields=name"},
+			"collection": {"href": "/users"},
+			"orders": {"href": "/users/42/orders{?page}", "templated": true},
+			"friend": [{"href": "/users/1"}, {"href": "/users/2"}]
+		},
+		"_embedded": {
+			"manager": {"id": 7, "name": "boss"},
+			"teams": []
+		}
+	}`, string(b), "expects a HAL document")
+}
+
+func (suite *HyperdriveTestSuite) TestHALResourceInvalidProperties() {
+	_, err := json.Marshal(NewHALResource([]int{1}))
+	suite.Error(err, "expects an error for properties which are not an object")
+}
+
+func (suite *HyperdriveTestSuite) TestHALResourcePaginationLinks() {
+	r := httptest.NewRequest("GET", "/users?page=2&per_page=10&sort=name", nil)
+	res := NewHALResource(nil)
+	res.AddPaginationLinks(r, ListQuery{Page: 2, PerPage: 10}, 35)
+	suite.Equal("/users?page=1&per_page=10&sort=name", res.Links["first"][0].Href, "expects a link to the first page")
+	suite.Equal("/users?page=1&per_page=10&sort=name", res.Links["prev"][0].Href, "expects a link to the previous page")
+	suite.Equal("/users?page=3&per_page=10&sort=name", res.Links["next"][0].Href, "expects a link to the next page")
+	suite.Equal("/users?page=4&per_page=10&sort=name", res.Links["last"][0].Href, "expects a link to the last page")
+
+	res = NewHALResource(nil)
+	res.AddPaginationLinks(r, ListQuery{Page: 1, PerPage: 10}, 0)
+	suite.NotContains(res.Links, "prev", "expects no previous page")
+	suite.NotContains(res.Links, "next", "expects no next page")
+	suite.Equal("/users?page=1&per_page=10&sort=name", res.Links["last"][0].Href, "expects the first page to be the last")
+}
+
+func (suite *HyperdriveTestSuite) TestExpandLinkTemplate() {
+	suite.Equal("/users/4%2F2/orders?page=2&per_page=10", ExpandLinkTemplate("/users/{id}/orders{?page,per_page}", map[string]string{"id": "4/2", "page": "2", "per_page": "10"}), "expects the variables to be expanded")
+	suite.Equal("/orders?status=open", ExpandLinkTemplate("/orders?status=open{&page}", map[string]string{}), "expects missing variables to be left out")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondHAL() {
+	rw := httptest.NewRecorder()
+	suite.Nil(RespondHAL(rw, http.StatusOK, NewHALResource(halUser{ID: 1})), "expects no error")
+	suite.Equal(HALContentType, rw.Header().Get("Content-Type"), "expects a HAL Content-Type")
+	suite.JSONEq(`{"id": 1, "name": ""}`, rw.Body.String(), "expects the resource to be written")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondNegotiatedHAL() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/users/1", nil)
+	r.Header.Set("Accept", HALContentType)
+	res := NewHALResource(halUser{ID: 1})
+	res.AddSelfLink(r)
+	Respond(rw, r, http.StatusOK, res)
+	suite.Equal(HALContentType, rw.Header().Get("Content-Type"), "expects the negotiated HAL Content-Type")
+	suite.JSONEq(`{"id": 1, "name": "", "_links": {"self": {"href": "/users/1"}}}`, rw.Body.String(), "expects the resource to be written")
+}
@@ -0,0 +1,62 @@
+package hyperdrive
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HealthCheck is a function which checks a dependency of the API, e.g. a
+// database connection, returning an error if it is unhealthy.
+type HealthCheck func() error
+
+// HealthStatus is the result of running a HealthCheck.
+type HealthStatus struct {
+	Name     string  `json:"name"`
+	Healthy  bool    `json:"healthy"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration_ms"`
+}
+
+type healthChecks struct {
+	sync.RWMutex
+	checks map[string]HealthCheck
+}
+
+func (hc *healthChecks) add(name string, check HealthCheck) {
+	hc.Lock()
+	defer hc.Unlock()
+	if hc.checks == nil {
+		hc.checks = map[string]HealthCheck{}
+	}
+	hc.checks[name] = check
+}
+
+func (hc *healthChecks) run() []HealthStatus {
+	hc.RLock()
+	defer hc.RUnlock()
+	statuses := make([]HealthStatus, 0, len(hc.checks))
+	for name, check := range hc.checks {
+		start := time.Now()
+		err := check()
+		status := HealthStatus{Name: name, Healthy: err == nil, Duration: float64(time.Since(start)) / float64(time.Millisecond)}
+		if err != nil {
+			status.Error = err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// AddHealthCheck registers a HealthCheck with the given name, replacing any
+// check already registered with that name.
+func (api *API) AddHealthCheck(name string, check HealthCheck) {
+	api.healthChecks.add(name, check)
+}
+
+// CheckHealth runs every registered HealthCheck, returning their results,
+// sorted by name.
+func (api *API) CheckHealth() []HealthStatus {
+	return api.healthChecks.run()
+}
@@ -0,0 +1,14 @@
+package hyperdrive
+
+import "errors"
+
+func (suite *HyperdriveTestSuite) TestCheckHealth() {
+	suite.TestAPI.AddHealthCheck("redis", func() error { return errors.New("connection refused") })
+	suite.TestAPI.AddHealthCheck("db", func() error { return nil })
+	statuses := suite.TestAPI.CheckHealth()
+	suite.Equal(2, len(statuses), "expects every check to be run")
+	suite.Equal("db", statuses[0].Name, "expects results to be sorted by name")
+	suite.True(statuses[0].Healthy, "expects passing checks to be healthy")
+	suite.False(statuses[1].Healthy, "expects failing checks to be unhealthy")
+	suite.Equal("connection refused", statuses[1].Error, "expects the error to be reported")
+}
@@ -0,0 +1,164 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Hook is a point in the lifecycle of a resource, at which RunHook calls the
+// hooks implemented by the endpoint, and those added with AddHook.
+type Hook string
+
+// The lifecycle hooks run by RunHook.
+const (
+	HookBeforeCreate Hook = "before_create"
+	HookAfterCreate  Hook = "after_create"
+	HookBeforeUpdate Hook = "before_update"
+	HookAfterUpdate  Hook = "after_update"
+	HookBeforeDelete Hook = "before_delete"
+	HookAfterDelete  Hook = "after_delete"
+)
+
+// BeforeCreateHook interface is satisfied if the endpoint has implemented a
+// method called BeforeCreate(), which RunHook calls with the entity about to
+// be created. Returning an error aborts the create.
+type BeforeCreateHook interface {
+	BeforeCreate(r *http.Request, entity interface{}) error
+}
+
+// AfterCreateHook interface is satisfied if the endpoint has implemented a
+// method called AfterCreate(), which RunHook calls with the entity once it
+// has been created.
+type AfterCreateHook interface {
+	AfterCreate(r *http.Request, entity interface{}) error
+}
+
+// BeforeUpdateHook interface is satisfied if the endpoint has implemented a
+// method called BeforeUpdate(), which RunHook calls with the entity about to
+// be updated. Returning an error aborts the update.
+type BeforeUpdateHook interface {
+	BeforeUpdate(r *http.Request, entity interface{}) error
+}
+
+// AfterUpdateHook interface is satisfied if the endpoint has implemented a
+// method called AfterUpdate(), which RunHook calls with the entity once it
+// has been updated.
+type AfterUpdateHook interface {
+	AfterUpdate(r *http.Request, entity interface{}) error
+}
+
+// BeforeDeleteHook interface is satisfied if the endpoint has implemented a
+// method called BeforeDelete(), which RunHook calls with the entity about to
+// be deleted. Returning an error aborts the delete.
+type BeforeDeleteHook interface {
+	BeforeDelete(r *http.Request, entity interface{}) error
+}
+
+// AfterDeleteHook interface is satisfied if the endpoint has implemented a
+// method called AfterDelete(), which RunHook calls with the entity once it
+// has been deleted.
+type AfterDeleteHook interface {
+	AfterDelete(r *http.Request, entity interface{}) error
+}
+
+// HookEvent is passed to the hooks added with AddHook: the Hook being run,
+// the endpoint serving the resource, the request, and the entity bound from
+// it (or loaded, for deletes).
+type HookEvent struct {
+	Hook     Hook
+	Endpoint Endpointer
+	Request  *http.Request
+	Entity   interface{}
+}
+
+// HookFunc is a hook added with AddHook.
+type HookFunc func(HookEvent) error
+
+// hooks holds the hooks added with AddHook, shared by every copy of the API.
+type hooks struct {
+	sync.RWMutex
+	funcs map[Hook][]HookFunc
+}
+
+func (h *hooks) add(hook Hook, f HookFunc) {
+	h.Lock()
+	defer h.Unlock()
+	if h.funcs == nil {
+		h.funcs = map[Hook][]HookFunc{}
+	}
+	h.funcs[hook] = append(h.funcs[hook], f)
+}
+
+func (h *hooks) get(hook Hook) []HookFunc {
+	h.RLock()
+	defer h.RUnlock()
+	return h.funcs[hook]
+}
+
+// AddHook adds a hook, which RunHook calls for every endpoint, after the
+// endpoint's own hook, e.g. to invalidate caches, or emit webhooks, for
+// every resource, without changing their endpoints.
+func (api *API) AddHook(hook Hook, f HookFunc) {
+	api.hooks.add(hook, f)
+}
+
+// endpointHook returns the endpoint's own implementation of the hook, if it
+// has one.
+func endpointHook(e Endpointer, hook Hook) func(*http.Request, interface{}) error {
+	switch hook {
+	case HookBeforeCreate:
+		if h, ok := e.(BeforeCreateHook); ok {
+			return h.BeforeCreate
+		}
+	case HookAfterCreate:
+		if h, ok := e.(AfterCreateHook); ok {
+			return h.AfterCreate
+		}
+	case HookBeforeUpdate:
+		if h, ok := e.(BeforeUpdateHook); ok {
+			return h.BeforeUpdate
+		}
+	case HookAfterUpdate:
+		if h, ok := e.(AfterUpdateHook); ok {
+			return h.AfterUpdate
+		}
+	case HookBeforeDelete:
+		if h, ok := e.(BeforeDeleteHook); ok {
+			return h.BeforeDelete
+		}
+	case HookAfterDelete:
+		if h, ok := e.(AfterDeleteHook); ok {
+			return h.AfterDelete
+		}
+	}
+	return nil
+}
+
+// RunHook runs the given lifecycle hook for the entity: first the endpoint's
+// own hook (e.g. BeforeCreate, if it implements BeforeCreateHook), then every
+// hook added with AddHook, in the order they were added. It stops at, and
+// returns, the first error, so resource handlers can call it around their
+// writes, e.g.
+//
+//	func (e *WidgetEndpoint) Post(rw http.ResponseWriter, r *http.Request) {
+//		w := &Widget{...}
+//		if err := api.RunHook(r, hyperdrive.HookBeforeCreate, e, w); err != nil {
+//			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+//			return
+//		}
+//		db.Create(w)
+//		api.RunHook(r, hyperdrive.HookAfterCreate, e, w)
+//	}
+func (api *API) RunHook(r *http.Request, hook Hook, e Endpointer, entity interface{}) error {
+	if f := endpointHook(e, hook); f != nil {
+		if err := f(r, entity); err != nil {
+			return err
+		}
+	}
+	for _, f := range api.hooks.get(hook) {
+		if err := f(HookEvent{Hook: hook, Endpoint: e, Request: r, Entity: entity}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
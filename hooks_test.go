@@ -0,0 +1,58 @@
+package hyperdrive
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+)
+
+type HookedEndpoint struct {
+	Endpoint
+	calls []string
+}
+
+func (e *HookedEndpoint) BeforeCreate(r *http.Request, entity interface{}) error {
+	e.calls = append(e.calls, "endpoint:before_create")
+	if entity == "invalid" {
+		return errors.New("invalid widget")
+	}
+	return nil
+}
+
+func (e *HookedEndpoint) AfterDelete(r *http.Request, entity interface{}) error {
+	e.calls = append(e.calls, "endpoint:after_delete")
+	return nil
+}
+
+func (suite *HyperdriveTestSuite) TestRunHook() {
+	e := &HookedEndpoint{}
+	var event HookEvent
+	suite.TestAPI.AddHook(HookBeforeCreate, func(ev HookEvent) error {
+		event = ev
+		e.calls = append(e.calls, "api:before_create")
+		return nil
+	})
+	r := httptest.NewRequest("POST", "/test", nil)
+	suite.Nil(suite.TestAPI.RunHook(r, HookBeforeCreate, e, "widget"), "expects no error")
+	suite.Equal([]string{"endpoint:before_create", "api:before_create"}, e.calls, "expects the endpoint's hook to run before the API's hooks")
+	suite.Equal(HookEvent{Hook: HookBeforeCreate, Endpoint: e, Request: r, Entity: "widget"}, event, "expects the hook event")
+}
+
+func (suite *HyperdriveTestSuite) TestRunHookError() {
+	e := &HookedEndpoint{}
+	suite.TestAPI.AddHook(HookBeforeCreate, func(ev HookEvent) error {
+		e.calls = append(e.calls, "api:before_create")
+		return nil
+	})
+	err := suite.TestAPI.RunHook(httptest.NewRequest("POST", "/test", nil), HookBeforeCreate, e, "invalid")
+	suite.EqualError(err, "invalid widget", "expects the hook's error")
+	suite.Equal([]string{"endpoint:before_create"}, e.calls, "expects hooks to stop at the first error")
+}
+
+func (suite *HyperdriveTestSuite) TestRunHookUnimplemented() {
+	e := &HookedEndpoint{}
+	suite.Nil(suite.TestAPI.RunHook(httptest.NewRequest("PUT", "/test", nil), HookAfterUpdate, e, "widget"), "expects no error")
+	suite.Empty(e.calls, "expects no hooks to run when none are implemented")
+	suite.TestAPI.RunHook(httptest.NewRequest("DELETE", "/test", nil), HookAfterDelete, e, "widget")
+	suite.Equal([]string{"endpoint:after_delete"}, e.calls, "expects only the hook being run")
+}
@@ -0,0 +1,30 @@
+package hyperdrive
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3Server creates an http3.Server, which serves the API's Router over
+// QUIC, on the configured HTTP3_ADDR.
+func (api *API) newHTTP3Server() *http3.Server {
+	return &http3.Server{
+		Addr:    conf.GetHTTP3Addr(),
+		Handler: api.Router,
+	}
+}
+
+// altSvcHandler wraps the given http.Handler, so every response served over
+// TCP advertises the HTTP/3 server listening on addr with an Alt-Svc header,
+// allowing capable clients to switch to QUIC for subsequent requests.
+func altSvcHandler(h http.Handler, addr string) http.Handler {
+	_, port, _ := net.SplitHostPort(addr)
+	altSvc := fmt.Sprintf(`%s=":%s"; ma=2592000`, http3.NextProtoH3, port)
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Add("Alt-Svc", altSvc)
+		h.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,16 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestNewHTTP3Server() {
+	suite.Equal(":5000", suite.TestAPI.newHTTP3Server().Addr, "expects the HTTP/3 server to listen on the first listen address")
+}
+
+func (suite *HyperdriveTestSuite) TestAltSvcHandler() {
+	rw := httptest.NewRecorder()
+	altSvcHandler(http.NotFoundHandler(), ":5000").ServeHTTP(rw, suite.TestGetRequest)
+	suite.Equal(`h3=":5000"; ma=2592000`, rw.Header().Get("Alt-Svc"), "expects the HTTP/3 server to be advertised")
+}
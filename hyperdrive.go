@@ -3,6 +3,7 @@ package hyperdrive
 import (
 	"log"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -17,28 +18,97 @@ var (
 // API is a logical collection of one or more endpoints, connecting requests
 // to the response handlers using a gorlla mux Router.
 type API struct {
-	Name      string
-	Desc      string
-	Router    *mux.Router
-	Server    *http.Server
-	Root      *RootResource
-	endpoints []Endpoint
+	Name               string
+	Desc               string
+	Router             *mux.Router
+	Server             *http.Server
+	Root               *RootResource
+	endpoints          []Endpoint
+	middleware         MiddlewareChain
+	rootRoute          *mux.Route
+	metrics            *metrics
+	panicReporters     *panicReporters
+	maintenance        *maintenance
+	redirects          *redirectTable
+	registry           *middlewareRegistry
+	healthChecks       *healthChecks
+	recentErrors       *recentErrors
+	consumers          *consumers
+	lifecycle          *lifecycle
+	hooks              *hooks
+	quotas             *quotas
+	rateLimits         *rateLimits
+	concurrency        *concurrencyLimits
+	dictionaries       *compressionDictionaries
+	mirror             *mirror
+	faults             *faults
+	accessLogEnrichers *accessLogEnrichers
+	smokeChecks        *smokeChecks
+	streams            *streams
+	configSnapshots    *configSnapshots
+	codecs             *codecs
+	started            time.Time
 }
 
 // NewAPI creates an instance of API, with an initialized Router, Config, Server, and RootResource.
 func NewAPI(name string, desc string) API {
 	api := API{
-		Name:   name,
-		Desc:   desc,
-		Router: mux.NewRouter(),
+		Name:               name,
+		Desc:               desc,
+		Router:             mux.NewRouter(),
+		metrics:            newMetrics(),
+		panicReporters:     &panicReporters{},
+		maintenance:        &maintenance{},
+		redirects:          &redirectTable{},
+		registry:           &middlewareRegistry{},
+		healthChecks:       &healthChecks{},
+		recentErrors:       &recentErrors{},
+		consumers:          &consumers{},
+		lifecycle:          newLifecycle(),
+		hooks:              &hooks{},
+		quotas:             &quotas{store: NewMemoryQuotaStore()},
+		rateLimits:         &rateLimits{store: NewMemoryRateLimitStore()},
+		concurrency:        &concurrencyLimits{},
+		dictionaries:       &compressionDictionaries{},
+		mirror:             newMirror(),
+		faults:             newFaults(),
+		accessLogEnrichers: &accessLogEnrichers{},
+		smokeChecks:        &smokeChecks{},
+		streams:            &streams{},
+		configSnapshots:    &configSnapshots{},
+		codecs:             &codecs{},
+		started:            time.Now(),
 	}
+	api.maintenance.set(conf.MaintenanceMode)
+	if err := api.ReloadRedirects(); err != nil {
+		log.Fatalf("Redirects could not be loaded: %v", err)
+	}
+	api.Router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
 	api.Root = NewRootResource(api)
-	api.Router.Handle("/", api.DefaultMiddlewareChain(api.Root)).Methods("GET")
+	api.rootRoute = api.Router.Handle("/", api.DefaultMiddlewareChain(api.Root)).Methods("GET")
+	if conf.ReadyPath != "" {
+		api.Router.Handle(conf.ReadyPath, api.ReadyHandler()).Methods("GET", "HEAD")
+	}
+	if conf.CompressionDictionaryPath != "" {
+		api.Router.Handle(conf.CompressionDictionaryPath, api.CompressionDictionaryHandler()).Methods("GET")
+	}
+	if conf.CorsDebug && conf.Env != "production" {
+		api.Router.Handle("/debug/cors", api.CorsDebugHandler()).Methods("GET")
+	}
+	if conf.AdminEnabled {
+		api.mountAdmin()
+	}
 	api.Server = &http.Server{
-		Handler:      api.Router,
-		Addr:         conf.GetPort(),
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
+		Handler:           api.RedirectMiddleware(api.Router),
+		Addr:              conf.GetAddr(),
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		ReadTimeout:       conf.ReadTimeout,
+		WriteTimeout:      conf.WriteTimeout,
+		IdleTimeout:       conf.IdleTimeout,
+		TLSConfig:         newTLSConfig(),
+	}
+	if conf.ClientFingerprints {
+		fingerprintClientHellos(api.Server)
 	}
 	hAPI = api
 	return api
@@ -46,20 +116,149 @@ func NewAPI(name string, desc string) API {
 
 // AddEndpoint registers endpoints, ensuring that endpoints automatically
 // respond with a 405 error if the endpoint does not support a particular
-// HTTP method.
-func (api *API) AddEndpoint(e Endpointer) {
+// HTTP method. Matchers (e.g. MatchHeader) can be given to only route
+// matching requests to the endpoint. Endpoints which require scopes or roles
+// are wrapped in AuthorizeMiddleware, those which implement CanonicalJSONer
+// in CanonicalJSONMiddleware, and every endpoint in ProtocolPolicyMiddleware,
+// unless its ProtocolPolicy (see ProtocolPolicer) accepts any protocol.
+// Endpoints whose responses are enveloped (see Enveloper) are wrapped in
+// EnvelopeMiddleware. WebSocket upgrade requests for the path of endpoints
+// which implement WebSocketHandler are routed to it, whatever their Accept
+// header.
+// Requests are routed to the endpoint when their Accept header names its
+// media type, in any format, or none of the API's media types (e.g. text/csv,
+// application/hal+json, or */*), in which case the first endpoint added for
+// the path is chosen. The representation is then negotiated by Respond, which
+// responds with a 406 Not Acceptable if it can not be rendered.
+func (api *API) AddEndpoint(e Endpointer, matchers ...Matcher) {
 	api.Root.AddEndpoint(e)
-	api.Router.Handle(e.GetPath(), api.DefaultMiddlewareChain(NewMethodHandler(e))).HeadersRegexp("Accept", GetMediaType(*api, e)+"(json|xml)")
+	if wh, ok := e.(WebSocketHandler); ok {
+		api.addWebSocketRoute(e, wh, matchers)
+	}
+	h := NewMethodHandler(e)
+	if requiresCanonicalJSON(e) {
+		h = api.CanonicalJSONMiddleware(h)
+	}
+	if requiresAuthorization(e) {
+		h = api.AuthorizeMiddleware(e)(h)
+	}
+	if p := protocolPolicy(e); !p.acceptsAny() {
+		h = api.ProtocolPolicyMiddleware(p)(h)
+	}
+	if usesEnvelope(e) {
+		h = api.EnvelopeMiddleware(h)
+	}
+	if requiresNoCache(e) {
+		h = api.NoCacheMiddleware(h)
+	}
+	route := api.Router.Handle(e.GetPath(), api.DefaultMiddlewareChain(h)).MatcherFunc(api.acceptsEndpoint(e))
+	for _, m := range matchers {
+		route = m(route)
+	}
 	log.Printf("Added hyperdriven Endpoint: %s http://0.0.0.0:%d%s", e.GetName(), conf.Port, e.GetPath())
 	log.Printf("    Methods: %s", GetMethodsList(e))
 	log.Printf("    Media Types: %s", GetContentTypesList(*api, e))
 }
 
+// acceptsEndpoint returns a mux.MatcherFunc which matches requests whose
+// Accept header names the endpoint's media type, with any suffix or
+// extension (e.g. application/vnd.api.users.v1+msgpack), or none of the API's
+// media types.
+func (api *API) acceptsEndpoint(e Endpointer) mux.MatcherFunc {
+	var (
+		mediaType = GetMediaType(*api, e)
+		vendor    = "application/vnd." + slug(api.Name) + "."
+	)
+	return func(r *http.Request, _ *mux.RouteMatch) bool {
+		namesVendor := false
+		for _, a := range parseAccept(r.Header.Get("Accept")) {
+			if !strings.HasPrefix(a.mediaType, mediaType) {
+				namesVendor = namesVendor || strings.HasPrefix(a.mediaType, vendor)
+				continue
+			}
+			// The media type itself, or with a suffix, but not another
+			// version (e.g. v1.2 for v1).
+			if format := a.mediaType[len(mediaType):]; format == "" || (strings.ContainsAny(format[:1], ".+") && !strings.ContainsAny(format[1:], ".+")) {
+				return true
+			}
+			namesVendor = true
+		}
+		return !namesVendor
+	}
+}
+
+// addWebSocketRoute routes WebSocket upgrade requests for the endpoint's
+// path to its WebSocketHandler, authorized, and restricted to its protocols,
+// as its other methods are.
+func (api *API) addWebSocketRoute(e Endpointer, wh WebSocketHandler, matchers []Matcher) {
+	h := api.webSocketHandler(wh)
+	if requiresAuthorization(e) {
+		h = api.AuthorizeMiddleware(e)(h)
+	}
+	if p := protocolPolicy(e); !p.acceptsAny() {
+		h = api.ProtocolPolicyMiddleware(p)(h)
+	}
+	route := api.Router.Handle(e.GetPath(), api.DefaultMiddlewareChain(h)).MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+		return isWebSocketUpgrade(r)
+	})
+	for _, m := range matchers {
+		route = m(route)
+	}
+}
+
 // Start starts the configured http server, listening on the configured Port
-// (default: 5000). Set the PORT environment variable to change this.
+// (default: 5000). Set the PORT environment variable to change this. Set the
+// HOST environment variable to listen on a single interface, or LISTEN_ADDRS
+// to listen on several addresses at once (e.g. "0.0.0.0:5000,[::1]:5000").
+// Consumers added with AddConsumer are run alongside the server, unless the
+// API is serverless (see Config.Serverless). When the process receives
+// SIGTERM or SIGINT, the API is shut down gracefully, waiting up to
+// SHUTDOWN_TIMEOUT (default: 15s) for requests to finish, and Start returns.
+// When CONFIG_SNAPSHOT_FILE is set (or SetConfigSnapshotStore is used), Start
+// logs the settings which changed since the API was last started.
+//
+// The server protects itself from slow clients (e.g. slowloris attacks) with
+// a READ_HEADER_TIMEOUT (default: 5s) for the request headers, a READ_TIMEOUT
+// (default: 15s) for the whole request, a WRITE_TIMEOUT (default: 15s) for
+// the response, and an IDLE_TIMEOUT (default: 60s) for keep-alive
+// connections waiting for their next request. A timeout of 0 disables it.
+//
+// When the program is run with the smoke command, e.g. "myapi smoke
+// https://api.example.com", Start runs Smoke against the given target (or
+// SMOKE_TARGET) instead of serving, prints the report, and exits with a
+// non-zero status if any SmokeCheck failed.
 func (api *API) Start() {
-	log.Printf("Starting hyperdriven API (%s): %s http://0.0.0.0:%d", conf.Env, api.Name, conf.Port)
-	log.Fatal(api.Server.ListenAndServe())
+	if len(os.Args) > 1 && os.Args[1] == "smoke" {
+		os.Exit(api.runSmokeCommand(os.Args[2:]))
+	}
+	if missing := api.unregisteredMiddleware(); len(missing) > 0 {
+		log.Fatalf("Middleware chain could not be initialized, custom middleware not registered: %s", strings.Join(missing, ", "))
+	}
+	listeners, err := api.Listen()
+	if err != nil {
+		log.Fatal(err)
+	}
+	api.logConfigChanges()
+	for _, l := range listeners {
+		log.Printf("Starting hyperdriven API (%s): %s http://%s", conf.Env, api.Name, l.Addr())
+	}
+	if conf.RedirectsFile != "" {
+		go api.reloadRedirectsOnHangup()
+	}
+	if api.HasConsumers() {
+		if conf.Serverless() {
+			log.Printf("Consumers are not run by serverless APIs, as the CPU may be throttled outside of requests")
+		} else {
+			api.goConsumers()
+		}
+	}
+	done := api.shutdownOnSignal()
+	api.SetReady(true)
+	if err := api.Serve(listeners...); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
+	log.Printf("Stopped hyperdriven API: %s", api.Name)
 }
 
 func slug(s string) string {
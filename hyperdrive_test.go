@@ -91,11 +91,11 @@ func (suite *HyperdriveTestSuite) SetupTest() {
 	suite.TestGetRequest = httptest.NewRequest("GET", "/test/2?id=1&a=b", nil)
 	suite.TestGetRequestNoParams = httptest.NewRequest("GET", "/test", nil)
 	suite.TestPostRequest = httptest.NewRequest("POST", "/test/2?id=1&a=b", strings.NewReader(`{"id":3}`))
-	suite.TestParsedParam = parsedParam{"TestParam", "...", "TestParam", "string", "test_param", []string{"GET", "PUT"}, []string{"PUT"}}
-	suite.TestParsedParamDefault = parsedParam{"TestParamDefault", "...", "TestParamDefault", "string", "test_param_default", []string{"GET", "PATCH", "POST", "PUT"}, []string{}}
-	suite.TestParsedParamEmpty = parsedParam{"TestParamEmpty", "...", "TestParamEmpty", "string", "TestParamEmpty", []string{"GET", "PATCH", "POST", "PUT"}, []string{}}
-	suite.TestParsedParamRequired = parsedParam{"TestParamRequired", "...", "TestParamRequired", "string", "test_param_required", []string{"GET", "PUT"}, []string{"PUT"}}
-	suite.TestParsedParamCustom = parsedParam{"ID", "The unique identifer for this resource.", "ID", "ID", "id", []string{"GET", "PATCH", "POST", "PUT"}, []string{"GET"}}
+	suite.TestParsedParam = parsedParam{"TestParam", "...", "TestParam", "string", "test_param", []string{"GET", "PUT"}, []string{"PUT"}, ""}
+	suite.TestParsedParamDefault = parsedParam{"TestParamDefault", "...", "TestParamDefault", "string", "test_param_default", []string{"GET", "PATCH", "POST", "PUT"}, []string{}, ""}
+	suite.TestParsedParamEmpty = parsedParam{"TestParamEmpty", "...", "TestParamEmpty", "string", "TestParamEmpty", []string{"GET", "PATCH", "POST", "PUT"}, []string{}, ""}
+	suite.TestParsedParamRequired = parsedParam{"TestParamRequired", "...", "TestParamRequired", "string", "test_param_required", []string{"GET", "PUT"}, []string{"PUT"}, ""}
+	suite.TestParsedParamCustom = parsedParam{"ID", "The unique identifer for this resource.", "ID", "ID", "id", []string{"GET", "PATCH", "POST", "PUT"}, []string{"GET"}, ""}
 	suite.TestParsedParamMap = parsedParams{"test_param": suite.TestParsedParam, "test_param_default": suite.TestParsedParamDefault, "TestParamEmpty": suite.TestParsedParamEmpty, "test_param_required": suite.TestParsedParamRequired}
 	suite.TestTaggedStruct = &TaggedStruct{Endpoint: *NewEndpoint("Test", "Test Endpoint", "/test", "1.0.1-beta")}
 	suite.TestTaggedEndpoint = &TaggedEndpoint{Endpoint: *NewEndpoint("Test", "Test Endpoint", "/test", "1.0.1-beta")}
@@ -110,6 +110,59 @@ func (suite *HyperdriveTestSuite) TestAPIServer() {
 	suite.IsType(&http.Server{}, suite.TestAPI.Server, "expects an instance of *http.Server")
 }
 
+func (suite *HyperdriveTestSuite) TestAPIServerTimeouts() {
+	suite.Equal(conf.ReadHeaderTimeout, suite.TestAPI.Server.ReadHeaderTimeout, "expects the READ_HEADER_TIMEOUT to be used")
+	suite.Equal(conf.ReadTimeout, suite.TestAPI.Server.ReadTimeout, "expects the READ_TIMEOUT to be used")
+	suite.Equal(conf.WriteTimeout, suite.TestAPI.Server.WriteTimeout, "expects the WRITE_TIMEOUT to be used")
+	suite.Equal(conf.IdleTimeout, suite.TestAPI.Server.IdleTimeout, "expects the IDLE_TIMEOUT to be used")
+	suite.NotZero(suite.TestAPI.Server.ReadHeaderTimeout, "expects slow headers to time out by default")
+}
+
 func TestHyperdriveTestSuite(t *testing.T) {
 	suite.Run(t, new(HyperdriveTestSuite))
 }
+
+type routedWidget struct {
+	ID int `json:"id"`
+}
+
+type routedEndpoint struct {
+	*Endpoint
+}
+
+func (e *routedEndpoint) Get(rw http.ResponseWriter, r *http.Request) {
+	Respond(rw, r, http.StatusOK, []routedWidget{{1}})
+}
+
+func (suite *HyperdriveTestSuite) TestAddEndpointRouting() {
+	e := &routedEndpoint{NewEndpoint("Widgets", "Widgets", "/widgets", "1")}
+	suite.TestAPI.AddEndpoint(e)
+	mediaType := GetMediaType(suite.TestAPI, e)
+	for accept, contentType := range map[string]string{
+		mediaType + ".json":    mediaType + ".json",
+		mediaType + "+msgpack": mediaType + "+msgpack",
+		"text/html":            "text/html",
+		"text/csv":             CSVContentType,
+		HALContentType:         HALContentType,
+		"":                     "application/json",
+	} {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/widgets", nil)
+		r.Header.Set("Accept", accept)
+		suite.TestAPI.Router.ServeHTTP(rw, r)
+		suite.Equal(http.StatusOK, rw.Code, "expects the endpoint to respond to "+accept)
+		suite.Equal(contentType, rw.Header().Get("Content-Type"), "expects the negotiated media type for "+accept)
+	}
+	for accept, status := range map[string]int{
+		"image/png":                         http.StatusNotAcceptable,
+		mediaType + "+yaml":                 http.StatusNotAcceptable,
+		mediaType + ".2.json":               http.StatusNotFound,
+		"application/vnd.api.other.v1.json": http.StatusNotFound,
+	} {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/widgets", nil)
+		r.Header.Set("Accept", accept)
+		suite.TestAPI.Router.ServeHTTP(rw, r)
+		suite.Equal(status, rw.Code, "expects the status for "+accept)
+	}
+}
@@ -0,0 +1,195 @@
+package hyperdrive
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IDGenerator interface is satisfied by anything that can generate unique
+// IDs, in the format required by downstream systems. Implementations must be
+// safe for concurrent use. hyperdrive generates request IDs and CloudEvent
+// IDs with the IDGenerator set with SetIDGenerator, or selected by
+// ID_FORMAT.
+type IDGenerator interface {
+	NewID() string
+}
+
+// HexIDGenerator generates 128 bit random IDs, encoded as 32 hex digits. It
+// is the default IDGenerator (ID_FORMAT hex).
+type HexIDGenerator struct{}
+
+// NewID returns a new random ID.
+func (HexIDGenerator) NewID() string {
+	return hex.EncodeToString(randomBytes(16))
+}
+
+// UUIDv4Generator generates random (version 4) UUIDs (ID_FORMAT uuidv4).
+type UUIDv4Generator struct{}
+
+// NewID returns a new version 4 UUID.
+func (UUIDv4Generator) NewID() string {
+	b := randomBytes(16)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+// UUIDv7Generator generates time-ordered (version 7) UUIDs, whose first 48
+// bits are the Unix time in milliseconds, so they sort by creation time and
+// index well in databases (ID_FORMAT uuidv7).
+type UUIDv7Generator struct{}
+
+// NewID returns a new version 7 UUID.
+func (UUIDv7Generator) NewID() string {
+	b := randomBytes(16)
+	putMillis(b, time.Now())
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+// ULIDGenerator generates ULIDs: 48 bits of Unix time in milliseconds,
+// followed by 80 random bits, encoded as 26 characters of Crockford's base32,
+// so they sort by creation time (ID_FORMAT ulid).
+type ULIDGenerator struct{}
+
+// crockford is the alphabet of Crockford's base32.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewID returns a new ULID.
+func (ULIDGenerator) NewID() string {
+	b := randomBytes(16)
+	putMillis(b, time.Now())
+	// The 128 bits are encoded 5 at a time, after 2 leading zero bits.
+	id := make([]byte, 26)
+	for i := range id {
+		var v byte
+		for bit := i*5 - 2; bit < i*5+3; bit++ {
+			v <<= 1
+			if bit >= 0 && b[bit/8]&(0x80>>uint(bit%8)) != 0 {
+				v |= 1
+			}
+		}
+		id[i] = crockford[v]
+	}
+	return string(id)
+}
+
+// snowflakeEpoch is the epoch of Snowflake IDs, in Unix milliseconds
+// (2010-11-04T01:42:54.657Z, as used by Twitter).
+const snowflakeEpoch = 1288834974657
+
+// SnowflakeGenerator generates Snowflake IDs: 64 bit integers made of 41
+// bits of milliseconds since the Snowflake epoch, a 10 bit node ID, and a 12
+// bit sequence, formatted as decimals (ID_FORMAT snowflake). IDs are unique
+// as long as every instance of the API has its own node ID (SNOWFLAKE_NODE).
+type SnowflakeGenerator struct {
+	mu       sync.Mutex
+	node     int64
+	millis   int64
+	sequence int64
+}
+
+// NewSnowflakeGenerator creates an instance of SnowflakeGenerator, with the
+// given node ID, which must be between 0 and 1023.
+func NewSnowflakeGenerator(node int64) (*SnowflakeGenerator, error) {
+	if node < 0 || node > 1023 {
+		return nil, fmt.Errorf("snowflake node must be between 0 and 1023, got %d", node)
+	}
+	return &SnowflakeGenerator{node: node}, nil
+}
+
+// NewID returns a new Snowflake ID. If more than 4096 IDs are generated in
+// the same millisecond, it waits for the next one.
+func (g *SnowflakeGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	if now < g.millis {
+		// The clock went backwards, keep counting from the last millisecond.
+		now = g.millis
+	}
+	if now == g.millis {
+		g.sequence = (g.sequence + 1) & 0xfff
+		if g.sequence == 0 {
+			for now <= g.millis {
+				time.Sleep(time.Millisecond / 10)
+				now = time.Now().UnixNano() / int64(time.Millisecond)
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.millis = now
+	return strconv.FormatInt((now-snowflakeEpoch)<<22|g.node<<12|g.sequence, 10)
+}
+
+// ids holds the IDGenerator set with SetIDGenerator, and the
+// SnowflakeGenerator used for ID_FORMAT snowflake.
+var ids struct {
+	sync.Mutex
+	generator IDGenerator
+	snowflake *SnowflakeGenerator
+}
+
+// SetIDGenerator sets the IDGenerator used throughout hyperdrive, overriding
+// ID_FORMAT. Pass nil to go back to ID_FORMAT.
+func SetIDGenerator(g IDGenerator) {
+	ids.Lock()
+	defer ids.Unlock()
+	ids.generator = g
+}
+
+// NewID returns a new ID from the IDGenerator set with SetIDGenerator, or
+// else the one selected by ID_FORMAT: hex (the default), uuidv4, uuidv7,
+// ulid, or snowflake (with SNOWFLAKE_NODE as its node ID).
+func NewID() string {
+	return idGenerator().NewID()
+}
+
+func idGenerator() IDGenerator {
+	ids.Lock()
+	defer ids.Unlock()
+	if ids.generator != nil {
+		return ids.generator
+	}
+	switch conf.IDFormat {
+	case "uuidv4":
+		return UUIDv4Generator{}
+	case "uuidv7":
+		return UUIDv7Generator{}
+	case "ulid":
+		return ULIDGenerator{}
+	case "snowflake":
+		if ids.snowflake == nil || ids.snowflake.node != int64(conf.SnowflakeNode) {
+			ids.snowflake, _ = NewSnowflakeGenerator(int64(conf.SnowflakeNode))
+		}
+		if ids.snowflake != nil {
+			return ids.snowflake
+		}
+	}
+	return HexIDGenerator{}
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+// putMillis writes the Unix time in milliseconds to the first 48 bits of b.
+func putMillis(b []byte, t time.Time) {
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(t.UnixNano()/int64(time.Millisecond)))
+	copy(b[:6], ms[2:])
+}
+
+func formatUUID(b []byte) string {
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
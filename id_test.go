@@ -0,0 +1,66 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+)
+
+type sequentialIDGenerator struct {
+	n int
+}
+
+func (g *sequentialIDGenerator) NewID() string {
+	g.n++
+	return strconv.Itoa(g.n)
+}
+
+func (suite *HyperdriveTestSuite) TestIDGenerators() {
+	suite.Regexp(`^[0-9a-f]{32}$`, HexIDGenerator{}.NewID(), "expects 32 hex digits")
+	suite.Regexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, UUIDv4Generator{}.NewID(), "expects a version 4 UUID")
+	suite.Regexp(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, UUIDv7Generator{}.NewID(), "expects a version 7 UUID")
+	suite.Regexp(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`, ULIDGenerator{}.NewID(), "expects a ULID")
+}
+
+func (suite *HyperdriveTestSuite) TestTimeOrderedIDs() {
+	for _, g := range []IDGenerator{UUIDv7Generator{}, ULIDGenerator{}} {
+		first := g.NewID()
+		suite.True(first[:8] <= g.NewID()[:8], "expects IDs to sort by creation time")
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestSnowflakeGenerator() {
+	g, err := NewSnowflakeGenerator(5)
+	suite.Require().Nil(err, "expects no error")
+	seen := map[string]bool{}
+	for i := 0; i < 5000; i++ {
+		id := g.NewID()
+		suite.Require().False(seen[id], "expects unique IDs")
+		seen[id] = true
+	}
+	id, _ := strconv.ParseInt(g.NewID(), 10, 64)
+	suite.Equal(int64(5), id>>12&0x3ff, "expects the node ID")
+	_, err = NewSnowflakeGenerator(1024)
+	suite.Error(err, "expects an error when the node ID is out of range")
+}
+
+func (suite *HyperdriveTestSuite) TestNewIDFormat() {
+	defer func(c Config) { conf = c }(conf)
+	conf.IDFormat = "uuidv4"
+	suite.Regexp(`^[0-9a-f-]{36}$`, NewID(), "expects the ID_FORMAT to be used")
+	conf.IDFormat = "snowflake"
+	suite.Regexp(`^[0-9]+$`, NewID(), "expects the ID_FORMAT to be used")
+}
+
+func (suite *HyperdriveTestSuite) TestSetIDGenerator() {
+	SetIDGenerator(&sequentialIDGenerator{})
+	defer SetIDGenerator(nil)
+	var id string
+	h := instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id = RequestID(r)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+	suite.Equal("1", id, "expects request IDs to be generated by the IDGenerator")
+	e, _ := NewCloudEvent("/test", "test.created", nil)
+	suite.Equal("2", e.ID, "expects CloudEvent IDs to be generated by the IDGenerator")
+}
@@ -0,0 +1,266 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header clients use to make a POST
+// request safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencySweepInterval is how often MemoryIdempotencyStore removes
+// expired responses and locks.
+const idempotencySweepInterval = time.Minute
+
+// StoredResponse is a response recorded by IdempotencyMiddleware, so that it
+// can be replayed for retries of the same request. Fingerprint is the hash
+// of the body of the request it was the response to.
+type StoredResponse struct {
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+	Fingerprint string      `json:"fingerprint,omitempty"`
+}
+
+// IdempotencyStore interface is satisfied by anything that can store the
+// responses recorded by IdempotencyMiddleware. Get returns nil, without an
+// error, when there is no response stored for the key. Lock must return
+// false if the key is already locked, so that concurrent requests with the
+// same key are not processed more than once. Implementations must be safe
+// for concurrent use. See MemoryIdempotencyStore, and the hyperdrive/redis
+// package for implementations.
+type IdempotencyStore interface {
+	Get(key string) (*StoredResponse, error)
+	Set(key string, resp *StoredResponse, ttl time.Duration) error
+	Lock(key string, ttl time.Duration) (bool, error)
+	Unlock(key string) error
+}
+
+type memoryIdempotencyEntry struct {
+	resp    *StoredResponse
+	expires time.Time
+}
+
+// MemoryIdempotencyStore is an in-memory implementation of IdempotencyStore.
+// It is suitable for development, and APIs running as a single instance.
+// Expired responses and locks are removed once a minute, as responses are
+// stored and keys locked, so the store does not grow with keys which are
+// never retried.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]memoryIdempotencyEntry
+	locks     map[string]time.Time
+	swept     time.Time
+}
+
+// NewMemoryIdempotencyStore creates an instance of MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		responses: map[string]memoryIdempotencyEntry{},
+		locks:     map[string]time.Time{},
+		swept:     time.Now(),
+	}
+}
+
+// sweep removes expired responses and locks, at most once per
+// idempotencySweepInterval. s.mu must be held.
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < idempotencySweepInterval {
+		return
+	}
+	for k, entry := range s.responses {
+		if now.After(entry.expires) {
+			delete(s.responses, k)
+		}
+	}
+	for k, expires := range s.locks {
+		if !now.Before(expires) {
+			delete(s.locks, k)
+		}
+	}
+	s.swept = now
+}
+
+// Get returns the response stored for the given key, if it has not expired.
+func (s *MemoryIdempotencyStore) Get(key string) (*StoredResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.responses[key]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(s.responses, key)
+		return nil, nil
+	}
+	return entry.resp, nil
+}
+
+// Set stores the response for the given key, until the ttl expires.
+func (s *MemoryIdempotencyStore) Set(key string, resp *StoredResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	s.responses[key] = memoryIdempotencyEntry{resp: resp, expires: now.Add(ttl)}
+	return nil
+}
+
+// Lock locks the given key until Unlock is called, or the ttl expires. It
+// returns false if the key is already locked.
+func (s *MemoryIdempotencyStore) Lock(key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	if expires, ok := s.locks[key]; ok && now.Before(expires) {
+		return false, nil
+	}
+	s.locks[key] = now.Add(ttl)
+	return true, nil
+}
+
+// Unlock unlocks the given key.
+func (s *MemoryIdempotencyStore) Unlock(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.locks, key)
+	return nil
+}
+
+// recordingWriter wraps an http.ResponseWriter, and keeps a copy of the
+// status and body written to it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// statusCode returns the status of the recorded response, which is 200 OK if
+// the handler wrote neither a header nor a body.
+func (w *recordingWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// idempotencyKey returns the key the response to the request is stored
+// under: the key sent by the client, scoped to the method, path, and
+// Principal, so clients can not replay each other's responses.
+func idempotencyKey(r *http.Request, key string) string {
+	scoped := r.Method + " " + r.URL.Path + " " + key
+	if p, ok := CurrentPrincipal(r); ok {
+		scoped += " principal=" + p.Method + ":" + p.ID
+	}
+	return scoped
+}
+
+// idempotencyFingerprint returns the hash of the request body, so retries
+// with a different body can be told apart.
+func idempotencyFingerprint(r *http.Request) (string, error) {
+	b, err := RawBody(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// IdempotencyMiddleware returns a Middleware which makes POST requests with
+// an Idempotency-Key header safe to retry. The first response for each key
+// (and path, and Principal, so it must be inside the auth middleware) is
+// saved in the given IdempotencyStore, and replayed for every retry within
+// the IDEMPOTENCY_TTL (default: 24h), with the Idempotent-Replayed header set
+// to true. A retry that arrives while the first request is still being
+// processed receives a 409 Conflict, and one with a different body than the
+// first (i.e. a key reused for another request) a 422 Unprocessable Entity.
+// Server errors (5xx) are not saved, so the request can be retried.
+func (api *API) IdempotencyMiddleware(store IdempotencyStore) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get(IdempotencyKeyHeader)
+			if r.Method != "POST" || header == "" {
+				h.ServeHTTP(rw, r)
+				return
+			}
+			fingerprint, err := idempotencyFingerprint(r)
+			if err != nil {
+				WriteError(rw, err)
+				return
+			}
+			key := idempotencyKey(r, header)
+			if replayStoredResponse(rw, r, store, key, fingerprint) {
+				return
+			}
+			locked, err := store.Lock(key, conf.IdempotencyTTL)
+			if err != nil {
+				http.Error(rw, GetErrorText(http.StatusInternalServerError, err), http.StatusInternalServerError)
+				return
+			}
+			if !locked {
+				http.Error(rw, "A request with this Idempotency-Key is already being processed.", http.StatusConflict)
+				return
+			}
+			defer store.Unlock(key)
+			// The first request may have completed since the response was looked up.
+			if replayStoredResponse(rw, r, store, key, fingerprint) {
+				return
+			}
+			rec := &recordingWriter{ResponseWriter: rw}
+			h.ServeHTTP(rec, r)
+			if status := rec.statusCode(); status < 500 {
+				store.Set(key, &StoredResponse{Status: status, Header: cloneHeader(rw.Header()), Body: rec.body.Bytes(), Fingerprint: fingerprint}, conf.IdempotencyTTL)
+			}
+		})
+	}
+}
+
+// replayStoredResponse writes the response stored for the key, or a 422
+// Unprocessable Entity if it was the response to a request with another
+// body, and returns false if there is none.
+func replayStoredResponse(rw http.ResponseWriter, r *http.Request, store IdempotencyStore, key string, fingerprint string) bool {
+	resp, err := store.Get(key)
+	if err != nil || resp == nil {
+		return false
+	}
+	if resp.Fingerprint != "" && resp.Fingerprint != fingerprint {
+		writeProblem(rw, http.StatusUnprocessableEntity, "This Idempotency-Key was already used for a request with a different body.")
+		return true
+	}
+	for k, v := range resp.Header {
+		rw.Header()[k] = v
+	}
+	rw.Header().Set("Idempotent-Replayed", "true")
+	rw.WriteHeader(resp.Status)
+	rw.Write(resp.Body)
+	return true
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
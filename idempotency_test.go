@@ -0,0 +1,161 @@
+package hyperdrive
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestMemoryIdempotencyStore() {
+	suite.Implements((*IdempotencyStore)(nil), NewMemoryIdempotencyStore(), "expects an implementation of IdempotencyStore")
+}
+
+func (suite *HyperdriveTestSuite) TestMemoryIdempotencyStoreExpires() {
+	store := NewMemoryIdempotencyStore()
+	store.Set("key", &StoredResponse{Status: 201}, -time.Second)
+	resp, _ := store.Get("key")
+	suite.Nil(resp, "expects expired responses to be removed")
+}
+
+func (suite *HyperdriveTestSuite) TestMemoryIdempotencyStoreSweep() {
+	store := NewMemoryIdempotencyStore()
+	store.Set("expired", &StoredResponse{Status: 201}, -time.Second)
+	store.Lock("abandoned", -time.Second)
+	store.swept = time.Now().Add(-2 * idempotencySweepInterval)
+	store.Set("key", &StoredResponse{Status: 201}, time.Minute)
+	suite.Len(store.responses, 1, "expects expired responses to be swept")
+	suite.Empty(store.locks, "expects expired locks to be swept")
+}
+
+func (suite *HyperdriveTestSuite) TestMemoryIdempotencyStoreLock() {
+	store := NewMemoryIdempotencyStore()
+	locked, _ := store.Lock("key", time.Minute)
+	suite.True(locked, "expects the lock to be acquired")
+	locked, _ = store.Lock("key", time.Minute)
+	suite.False(locked, "expects the lock to be held")
+	store.Unlock("key")
+	locked, _ = store.Lock("key", time.Minute)
+	suite.True(locked, "expects the lock to be released")
+}
+
+func (suite *HyperdriveTestSuite) TestIdempotencyMiddlewareReplay() {
+	var calls int
+	h := suite.TestAPI.IdempotencyMiddleware(NewMemoryIdempotencyStore())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte("created"))
+	}))
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("POST", "/test", nil)
+		r.Header.Set(IdempotencyKeyHeader, "abc")
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, r)
+		suite.Equal(http.StatusCreated, rw.Code, "expects the original status")
+		suite.Equal("created", rw.Body.String(), "expects the original body")
+	}
+	suite.Equal(1, calls, "expects the handler to be called once")
+}
+
+func (suite *HyperdriveTestSuite) TestIdempotencyMiddlewareReplayedHeader() {
+	store := NewMemoryIdempotencyStore()
+	store.Set(idempotencyKey(httptest.NewRequest("POST", "/test", nil), "abc"), &StoredResponse{Status: 201}, time.Minute)
+	r := httptest.NewRequest("POST", "/test", nil)
+	r.Header.Set(IdempotencyKeyHeader, "abc")
+	rw := httptest.NewRecorder()
+	suite.TestAPI.IdempotencyMiddleware(store)(suite.TestHandler).ServeHTTP(rw, r)
+	suite.Equal("true", rw.Header().Get("Idempotent-Replayed"), "expects replayed responses to be marked")
+}
+
+func (suite *HyperdriveTestSuite) TestIdempotencyMiddlewareConflict() {
+	store := NewMemoryIdempotencyStore()
+	r := httptest.NewRequest("POST", "/test", nil)
+	r.Header.Set(IdempotencyKeyHeader, "abc")
+	store.Lock(idempotencyKey(r, "abc"), time.Minute)
+	rw := httptest.NewRecorder()
+	suite.TestAPI.IdempotencyMiddleware(store)(suite.TestHandler).ServeHTTP(rw, r)
+	suite.Equal(http.StatusConflict, rw.Code, "expects a 409 while the first request is in progress")
+}
+
+func (suite *HyperdriveTestSuite) TestIdempotencyMiddlewareServerError() {
+	var calls int
+	h := suite.TestAPI.IdempotencyMiddleware(NewMemoryIdempotencyStore())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("POST", "/test", nil)
+		r.Header.Set(IdempotencyKeyHeader, "abc")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	suite.Equal(2, calls, "expects server errors to be retried")
+}
+
+func (suite *HyperdriveTestSuite) TestIdempotencyMiddlewareEmptyResponse() {
+	var calls int
+	h := suite.TestAPI.IdempotencyMiddleware(NewMemoryIdempotencyStore())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("POST", "/test", nil)
+		r.Header.Set(IdempotencyKeyHeader, "abc")
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, r)
+		suite.Equal(http.StatusOK, rw.Code, "expects an implicit 200 OK")
+	}
+	suite.Equal(1, calls, "expects responses without a header or body to be replayed")
+}
+
+func (suite *HyperdriveTestSuite) TestIdempotencyMiddlewareGet() {
+	var calls int
+	h := suite.TestAPI.IdempotencyMiddleware(NewMemoryIdempotencyStore())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("GET", "/test", nil)
+		r.Header.Set(IdempotencyKeyHeader, "abc")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	suite.Equal(2, calls, "expects non-POST requests to be ignored")
+}
+
+func (suite *HyperdriveTestSuite) TestIdempotencyMiddlewareDifferentBody() {
+	var calls int
+	h := suite.TestAPI.IdempotencyMiddleware(NewMemoryIdempotencyStore())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := ioutil.ReadAll(r.Body)
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write(b)
+	}))
+	post := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+		r.Header.Set(IdempotencyKeyHeader, "abc")
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, r)
+		return rw
+	}
+	suite.Equal("amount=10", post("amount=10").Body.String(), "expects the handler to read the body")
+	suite.Equal("amount=10", post("amount=10").Body.String(), "expects retries with the same body to be replayed")
+	rw := post("amount=1000")
+	suite.Equal(http.StatusUnprocessableEntity, rw.Code, "expects a 422 when the key is reused with a different body")
+	suite.Equal(problemContentType, rw.Header().Get("Content-Type"), "expects a problem+json response")
+	suite.Equal(1, calls, "expects the handler to be called once")
+}
+
+func (suite *HyperdriveTestSuite) TestIdempotencyMiddlewarePrincipal() {
+	var calls int
+	h := suite.TestAPI.IdempotencyMiddleware(NewMemoryIdempotencyStore())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		p, _ := CurrentPrincipal(r)
+		rw.Write([]byte(p.ID))
+	}))
+	for _, id := range []string{"alice", "mallory"} {
+		r := httptest.NewRequest("POST", "/test", nil)
+		r.Header.Set(IdempotencyKeyHeader, "abc")
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, WithPrincipal(r, &Principal{ID: id, Method: "test"}))
+		suite.Equal(id, rw.Body.String(), "expects each principal to get their own response")
+	}
+	suite.Equal(2, calls, "expects the same key to be processed for each principal")
+}
@@ -0,0 +1,180 @@
+package hyperdrive
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+type statsContextKey struct{}
+
+// ResponseStats holds information about a response, collected as it passes
+// through the middleware chain. WireBytes is the size of the body as it was
+// written to the client (i.e. after compression), and PayloadBytes is the
+// size of the body as it was written by the endpoint (i.e. before
+// compression). Ledger counts the resources used to serve the request, and
+// Recovered holds the value of a panic, recovered by RecoveryMiddleware.
+type ResponseStats struct {
+	RequestID    string
+	Start        time.Time
+	Status       int
+	WireBytes    int64
+	PayloadBytes int64
+	Ledger       *Ledger
+	Recovered    interface{}
+	payload      bool
+	traceparent  string
+	commit       []func(http.Header)
+	complete     []func(*http.Request, *ResponseStats)
+}
+
+// Duration returns the time elapsed since the request was received.
+func (s *ResponseStats) Duration() time.Duration {
+	return time.Since(s.Start)
+}
+
+// OnComplete registers a function to be run once the response has been fully
+// written, by every middleware in the chain. This allows middleware (e.g.
+// LoggingMiddleware) to report on the response regardless of its position in
+// the chain.
+func (s *ResponseStats) OnComplete(f func(*http.Request, *ResponseStats)) {
+	s.complete = append(s.complete, f)
+}
+
+// OnCommit registers a function to be run just before the status and headers
+// are written, so it can still change the headers, e.g. to set a cookie.
+func (s *ResponseStats) OnCommit(f func(http.Header)) {
+	s.commit = append(s.commit, f)
+}
+
+// GetResponseStats returns the ResponseStats for the given request, or nil if
+// the request is not being instrumented.
+func GetResponseStats(r *http.Request) *ResponseStats {
+	return statsFromContext(r.Context())
+}
+
+func statsFromContext(ctx context.Context) *ResponseStats {
+	if s, ok := ctx.Value(statsContextKey{}).(*ResponseStats); ok {
+		return s
+	}
+	return nil
+}
+
+// instrument wraps the given http.Handler so the bytes written to the client
+// are counted, and runs the OnComplete functions once the response is
+// written. Each request is given an ID (see RequestIDHeader), and its body is
+// cached by RawBody. If the request is already being instrumented, h is
+// called as is.
+func instrument(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if GetResponseStats(r) != nil {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		stats := &ResponseStats{RequestID: requestID(r), Start: time.Now(), Status: http.StatusOK, Ledger: &Ledger{}, traceparent: r.Header.Get("Traceparent")}
+		rw.Header().Set(RequestIDHeader, stats.RequestID)
+		r = withRawBodyCache(r.WithContext(context.WithValue(r.Context(), statsContextKey{}, stats)))
+		w := &instrumentedWriter{ResponseWriter: rw, stats: stats}
+		defer func() {
+			if !stats.payload {
+				stats.PayloadBytes = stats.WireBytes
+			}
+			for _, f := range stats.complete {
+				f(r, stats)
+			}
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// trackPayload wraps the given http.Handler so the bytes written by it are
+// counted as the PayloadBytes of an instrumented request.
+func trackPayload(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		stats := GetResponseStats(r)
+		if stats == nil {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		stats.payload = true
+		h.ServeHTTP(&instrumentedWriter{ResponseWriter: rw, stats: stats, payload: true}, r)
+	})
+}
+
+// instrumentedWriter wraps an http.ResponseWriter to record the status and
+// number of bytes written, while still exposing the optional interfaces
+// (http.Flusher, http.Hijacker, http.CloseNotifier) of the underlying writer.
+type instrumentedWriter struct {
+	http.ResponseWriter
+	stats       *ResponseStats
+	payload     bool
+	wroteHeader bool
+}
+
+func (w *instrumentedWriter) WriteHeader(status int) {
+	if !w.wroteHeader && !w.payload {
+		w.stats.Status = status
+		for _, f := range w.stats.commit {
+			f(w.Header())
+		}
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *instrumentedWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	if w.payload {
+		w.stats.PayloadBytes += int64(n)
+	} else {
+		w.stats.WireBytes += int64(n)
+	}
+	return n, err
+}
+
+// Committed returns true once the status and headers have been written.
+func (w *instrumentedWriter) Committed() bool {
+	return w.wroteHeader
+}
+
+// CanFlush returns true if the underlying http.ResponseWriter supports
+// flushing.
+func (w *instrumentedWriter) CanFlush() bool {
+	if fc, ok := w.ResponseWriter.(flushChecker); ok {
+		return fc.CanFlush()
+	}
+	_, ok := w.ResponseWriter.(http.Flusher)
+	return ok
+}
+
+func (w *instrumentedWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *instrumentedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("hyperdrive: http.Hijacker is not supported by the underlying http.ResponseWriter")
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for
+// http.ResponseController.
+func (w *instrumentedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *instrumentedWriter) CloseNotify() <-chan bool {
+	if c, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return c.CloseNotify()
+	}
+	return make(chan bool)
+}
@@ -0,0 +1,65 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func (suite *HyperdriveTestSuite) TestGetResponseStatsNil() {
+	suite.Nil(GetResponseStats(suite.TestGetRequest), "expects nil when the request is not instrumented")
+}
+
+func (suite *HyperdriveTestSuite) TestInstrument() {
+	var stats *ResponseStats
+	h := instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		stats = GetResponseStats(r)
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte("created"))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	suite.Equal(http.StatusCreated, stats.Status, "expects the status to be recorded")
+	suite.Equal(int64(7), stats.WireBytes, "expects the bytes written to be recorded")
+	suite.Equal(int64(7), stats.PayloadBytes, "expects payload bytes to equal wire bytes when not tracked")
+}
+
+func (suite *HyperdriveTestSuite) TestInstrumentOnComplete() {
+	var called bool
+	h := instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		GetResponseStats(r).OnComplete(func(r *http.Request, s *ResponseStats) {
+			called = true
+		})
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	suite.True(called, "expects OnComplete functions to be called")
+}
+
+func (suite *HyperdriveTestSuite) TestInstrumentOnCommit() {
+	h := instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		GetResponseStats(r).OnCommit(func(h http.Header) {
+			h.Set("X-Committed", "true")
+		})
+		rw.Write([]byte("created"))
+	}))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, suite.TestGetRequest)
+	suite.Equal("true", rw.Header().Get("X-Committed"), "expects OnCommit functions to change the headers before they are written")
+}
+
+func (suite *HyperdriveTestSuite) TestInstrumentCompressed() {
+	var stats *ResponseStats
+	c := NewMiddlewareChain(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			stats = GetResponseStats(r)
+			h.ServeHTTP(rw, r)
+		})
+	}, suite.TestAPI.CompressionMiddleware)
+	h := c.Then(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(strings.Repeat("a", 1000)))
+	}))
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	suite.Equal(int64(1000), stats.PayloadBytes, "expects payload bytes to be uncompressed size")
+	suite.True(stats.WireBytes < stats.PayloadBytes, "expects wire bytes to be compressed size")
+}
@@ -0,0 +1,235 @@
+package hyperdrive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type introspectionContextKey struct{}
+
+// maxIntrospectionCacheSize is the number of tokens cached by an Introspector
+// before expired entries are purged.
+const maxIntrospectionCacheSize = 10000
+
+// TokenIntrospection is the response of an OAuth 2.0 token introspection
+// endpoint, as defined by RFC 7662.
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Nbf       int64  `json:"nbf,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
+
+// Scopes returns the scopes granted to the token.
+func (t *TokenIntrospection) Scopes() []string {
+	return strings.Fields(t.Scope)
+}
+
+// HasScopes returns true if the token has been granted every one of the given
+// scopes.
+func (t *TokenIntrospection) HasScopes(scopes ...string) bool {
+	granted := t.Scopes()
+	for _, s := range scopes {
+		if !contains(granted, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// subject returns the subject of the token, falling back to the username,
+// then the client it was issued to.
+func (t *TokenIntrospection) subject() string {
+	switch {
+	case t.Sub != "":
+		return t.Sub
+	case t.Username != "":
+		return t.Username
+	}
+	return t.ClientID
+}
+
+type introspectionEntry struct {
+	token   *TokenIntrospection
+	expires time.Time
+}
+
+// Introspector validates opaque bearer tokens against an OAuth 2.0 token
+// introspection endpoint (RFC 7662), authenticating with the client
+// credentials of the API. Results are cached for CacheTTL, or until the token
+// expires, if that is sooner, so the authorization server is not called for
+// every request.
+type Introspector struct {
+	Endpoint     string
+	ClientID     string
+	ClientSecret string
+	CacheTTL     time.Duration
+	Client       *http.Client
+	mu           sync.Mutex
+	cache        map[string]introspectionEntry
+}
+
+// NewIntrospector creates an instance of Introspector, configured via the
+// following environment variables:
+//
+// - OAUTH2_INTROSPECTION_URL (string)
+// - OAUTH2_CLIENT_ID (string)
+// - OAUTH2_CLIENT_SECRET (string)
+// - OAUTH2_INTROSPECTION_CACHE_TTL (duration, default: 1m)
+func NewIntrospector() *Introspector {
+	return &Introspector{
+		Endpoint:     conf.OAuth2IntrospectionURL,
+		ClientID:     conf.OAuth2ClientID,
+		ClientSecret: conf.OAuth2ClientSecret,
+		CacheTTL:     conf.OAuth2IntrospectionCacheTTL,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		cache:        map[string]introspectionEntry{},
+	}
+}
+
+func (i *Introspector) cached(key string) (*TokenIntrospection, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	entry, ok := i.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.token, true
+}
+
+func (i *Introspector) store(key string, token *TokenIntrospection) {
+	if i.CacheTTL <= 0 {
+		return
+	}
+	expires := time.Now().Add(i.CacheTTL)
+	if token.Exp > 0 && time.Unix(token.Exp, 0).Before(expires) {
+		expires = time.Unix(token.Exp, 0)
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cache == nil {
+		i.cache = map[string]introspectionEntry{}
+	}
+	if len(i.cache) >= maxIntrospectionCacheSize {
+		now := time.Now()
+		for k, entry := range i.cache {
+			if now.After(entry.expires) {
+				delete(i.cache, k)
+			}
+		}
+	}
+	i.cache[key] = introspectionEntry{token: token, expires: expires}
+}
+
+// Introspect returns the introspection response for the given token, from the
+// cache, or the introspection endpoint. An error is only returned if the
+// endpoint could not be called: inactive (e.g. expired or revoked) tokens are
+// returned with Active set to false.
+func (i *Introspector) Introspect(ctx context.Context, token string) (*TokenIntrospection, error) {
+	key := sha256Digest(token)
+	if t, ok := i.cached(key); ok {
+		return t, nil
+	}
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequest("POST", i.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(i.ClientID), url.QueryEscape(i.ClientSecret))
+	client := i.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token introspection failed with status %d", resp.StatusCode)
+	}
+	t := &TokenIntrospection{}
+	if err := json.NewDecoder(resp.Body).Decode(t); err != nil {
+		return nil, fmt.Errorf("could not parse token introspection response: %v", err)
+	}
+	if t.Active && t.Exp > 0 && time.Now().After(time.Unix(t.Exp, 0)) {
+		t.Active = false
+	}
+	i.store(key, t)
+	return t, nil
+}
+
+// bearerToken returns the token from the request's Authorization header.
+func bearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", errors.New("missing bearer token")
+	}
+	return strings.TrimSpace(header[7:]), nil
+}
+
+// CurrentToken returns the TokenIntrospection of the bearer token
+// authenticated by IntrospectionMiddleware, and false if the request has not
+// been authenticated.
+func CurrentToken(r *http.Request) (*TokenIntrospection, bool) {
+	t, ok := r.Context().Value(introspectionContextKey{}).(*TokenIntrospection)
+	return t, ok
+}
+
+// IntrospectionMiddleware returns a Middleware which requires requests to
+// present an active bearer token, as reported by the given Introspector,
+// responding to those without one with a 401 Unauthorized. If scopes are
+// given, the token must have been granted all of them, or the request is
+// refused with a 403 Forbidden, so the middleware can be used per route
+// with the scopes each route requires, e.g.
+//
+//	read := api.IntrospectionMiddleware(introspector, "invoices:read")
+//	api.Router.Handle("/invoices", read(handler))
+//
+// The introspection response can be retrieved with CurrentToken.
+func (api *API) IntrospectionMiddleware(i *Introspector, scopes ...string) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			token, err := bearerToken(r)
+			if err != nil {
+				rw.Header().Set("WWW-Authenticate", `Bearer`)
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			t, err := i.Introspect(r.Context(), token)
+			if err != nil {
+				http.Error(rw, GetErrorText(http.StatusInternalServerError, err), http.StatusInternalServerError)
+				return
+			}
+			if !t.Active {
+				rw.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if !t.HasScopes(scopes...) {
+				rw.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, strings.Join(scopes, " ")))
+				http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), introspectionContextKey{}, t))
+			h.ServeHTTP(rw, WithPrincipal(r, &Principal{ID: t.subject(), Method: AuthMethodBearer, Scopes: t.Scopes()}))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) testIntrospector(calls *int) (*Introspector, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		*calls++
+		user, pass, _ := r.BasicAuth()
+		if user != "api" || pass != "s3cret" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.ParseForm()
+		switch r.PostForm.Get("token") {
+		case "good":
+			json.NewEncoder(rw).Encode(TokenIntrospection{Active: true, Scope: "invoices:read invoices:write", Sub: "alice", Exp: time.Now().Add(time.Hour).Unix()})
+		default:
+			json.NewEncoder(rw).Encode(TokenIntrospection{Active: false})
+		}
+	}))
+	i := NewIntrospector()
+	i.Endpoint, i.ClientID, i.ClientSecret = server.URL, "api", "s3cret"
+	return i, server.Close
+}
+
+func (suite *HyperdriveTestSuite) TestIntrospect() {
+	var calls int
+	i, done := suite.testIntrospector(&calls)
+	defer done()
+	t, err := i.Introspect(suite.TestGetRequest.Context(), "good")
+	suite.Nil(err, "expects no error")
+	suite.True(t.Active, "expects the token to be active")
+	suite.Equal("alice", t.Sub, "expects the introspection response")
+	i.Introspect(suite.TestGetRequest.Context(), "good")
+	suite.Equal(1, calls, "expects the response to be cached")
+}
+
+func (suite *HyperdriveTestSuite) TestIntrospectError() {
+	var calls int
+	i, done := suite.testIntrospector(&calls)
+	defer done()
+	i.ClientSecret = "wrong"
+	_, err := i.Introspect(suite.TestGetRequest.Context(), "good")
+	suite.Error(err, "expects an error if the introspection endpoint fails")
+}
+
+func (suite *HyperdriveTestSuite) TestTokenIntrospectionHasScopes() {
+	t := &TokenIntrospection{Scope: "a b"}
+	suite.True(t.HasScopes("a", "b"), "expects true when every scope is granted")
+	suite.False(t.HasScopes("a", "c"), "expects false when a scope is missing")
+}
+
+func (suite *HyperdriveTestSuite) TestIntrospectionMiddleware() {
+	var (
+		calls     int
+		token     *TokenIntrospection
+		principal *Principal
+	)
+	i, done := suite.testIntrospector(&calls)
+	defer done()
+	h := suite.TestAPI.IntrospectionMiddleware(i, "invoices:read")(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		token, _ = CurrentToken(r)
+		principal, _ = CurrentPrincipal(r)
+	}))
+	rw, r := httptest.NewRecorder(), httptest.NewRequest("GET", "/invoices", nil)
+	r.Header.Set("Authorization", "Bearer good")
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusOK, rw.Code, "expects active tokens to be accepted")
+	suite.Equal("alice", token.Sub, "expects the token to be added to the request context")
+	suite.Equal("alice", principal.ID, "expects the subject of the token to be the principal")
+	suite.Equal(AuthMethodBearer, principal.Method, "expects the principal to be authenticated by a bearer token")
+}
+
+func (suite *HyperdriveTestSuite) TestIntrospectionMiddlewareUnauthorized() {
+	var calls int
+	i, done := suite.testIntrospector(&calls)
+	defer done()
+	h := suite.TestAPI.IntrospectionMiddleware(i)(suite.TestHandler)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/invoices", nil))
+	suite.Equal(http.StatusUnauthorized, rw.Code, "expects requests without a token to be rejected")
+	rw, r := httptest.NewRecorder(), httptest.NewRequest("GET", "/invoices", nil)
+	r.Header.Set("Authorization", "Bearer revoked")
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusUnauthorized, rw.Code, "expects inactive tokens to be rejected")
+	suite.Equal(`Bearer error="invalid_token"`, rw.Header().Get("WWW-Authenticate"), "expects an invalid_token challenge")
+}
+
+func (suite *HyperdriveTestSuite) TestIntrospectionMiddlewareForbidden() {
+	var calls int
+	i, done := suite.testIntrospector(&calls)
+	defer done()
+	rw, r := httptest.NewRecorder(), httptest.NewRequest("DELETE", "/invoices", nil)
+	r.Header.Set("Authorization", "Bearer good")
+	suite.TestAPI.IntrospectionMiddleware(i, "invoices:delete")(suite.TestHandler).ServeHTTP(rw, r)
+	suite.Equal(http.StatusForbidden, rw.Code, "expects tokens without the required scopes to be refused")
+	suite.Equal(`Bearer error="insufficient_scope", scope="invoices:delete"`, rw.Header().Get("WWW-Authenticate"), "expects an insufficient_scope challenge")
+}
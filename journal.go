@@ -0,0 +1,141 @@
+package hyperdrive
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+)
+
+type journalContextKey struct{}
+
+// ErrNotJournaled is returned by Journal.Add when the request is not being
+// served by JournalMiddleware.
+var ErrNotJournaled = errors.New("the request is not journaled, see JournalMiddleware")
+
+// SideEffect is a named effect of a request, outside of the API's own
+// database, e.g. sending an email, or charging a card.
+type SideEffect struct {
+	Name string
+	Run  func(context.Context) error
+}
+
+// Journal collects the SideEffects of a single request, to be run once the
+// request has succeeded, and at most once across every retry of it. See
+// JournalMiddleware.
+type Journal struct {
+	mu      sync.Mutex
+	store   IdempotencyStore
+	key     string
+	effects []SideEffect
+}
+
+// GetJournal returns the Journal for the given request, or nil if the request
+// is not being served by JournalMiddleware.
+func GetJournal(r *http.Request) *Journal {
+	if j, ok := r.Context().Value(journalContextKey{}).(*Journal); ok {
+		return j
+	}
+	return nil
+}
+
+// Add registers a side effect, run by f once the request has succeeded. The
+// name identifies the effect across retries of the request, so it must be
+// unique within the request, and the same for every retry, e.g.
+// "welcome-email". It returns ErrNotJournaled for a nil Journal, rather than
+// running f straight away, without the guarantees of the Journal.
+func (j *Journal) Add(name string, f func(context.Context) error) error {
+	if j == nil {
+		return ErrNotJournaled
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.effects = append(j.effects, SideEffect{Name: name, Run: f})
+	return nil
+}
+
+// Effects returns the side effects registered so far.
+func (j *Journal) Effects() []SideEffect {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]SideEffect(nil), j.effects...)
+}
+
+// run runs each side effect which has not already been run for a previous
+// attempt of the request. An effect is claimed, by locking its key in the
+// store for the IDEMPOTENCY_TTL, before it is run, and the claim is only
+// released if it fails, so it can be retried. An effect interrupted by a
+// crash is never run again.
+func (j *Journal) run(ctx context.Context) {
+	for _, e := range j.Effects() {
+		key := j.key + " " + e.Name
+		claimed, err := j.store.Lock(key, conf.IdempotencyTTL)
+		if err != nil {
+			log.Printf("Side effect %s could not be claimed: %v", e.Name, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if err := e.Run(ctx); err != nil {
+			log.Printf("Side effect %s failed: %v", e.Name, err)
+			if err := j.store.Unlock(key); err != nil {
+				log.Printf("Side effect %s could not be released: %v", e.Name, err)
+			}
+		}
+	}
+}
+
+// journalKey returns the key identifying every attempt of the request: its
+// Idempotency-Key header if it has one, otherwise its request ID, which
+// clients may reuse across retries with the X-Request-Id header.
+func journalKey(r *http.Request) string {
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		key = RequestID(r)
+	}
+	return "journal " + idempotencyKey(r, key)
+}
+
+// JournalMiddleware returns a Middleware which gives each request a Journal
+// (see GetJournal), so endpoints can register side effects, e.g. emails or
+// charges, which should happen at most once, however many times a client
+// retries the request:
+//
+//	GetJournal(r).Add("welcome-email", func(ctx context.Context) error {
+//		return mailer.SendWelcome(ctx, user)
+//	})
+//
+// The side effects are only run once the response is complete, and the
+// request succeeded (i.e. with a status below 400), so an effect is never run
+// for a request whose changes were rolled back. They are run in the
+// background (see Background), in the order they were added, and are
+// recorded in the given IdempotencyStore (or in memory, if it is nil), keyed
+// by the request's Idempotency-Key header, for the IDEMPOTENCY_TTL (default:
+// 24h). Use a shared store, e.g. from the hyperdrive/redis package, when
+// running more than one instance.
+func (api *API) JournalMiddleware(store IdempotencyStore) Middleware {
+	if store == nil {
+		store = NewMemoryIdempotencyStore()
+	}
+	return func(h http.Handler) http.Handler {
+		return instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			j := &Journal{store: store, key: journalKey(r)}
+			GetResponseStats(r).OnComplete(func(r *http.Request, s *ResponseStats) {
+				if s.Status >= http.StatusBadRequest || s.Recovered != nil || len(j.Effects()) == 0 {
+					return
+				}
+				if conf.Serverless() {
+					j.run(context.Background())
+					return
+				}
+				api.Background(r, j.run)
+			})
+			h.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), journalContextKey{}, j)))
+		}))
+	}
+}
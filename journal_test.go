@@ -0,0 +1,71 @@
+package hyperdrive
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+)
+
+// journaledHandler returns a handler which adds a side effect, counted in n,
+// and responds with the given status.
+func journaledHandler(n *int, status int, err error) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		GetJournal(r).Add("effect", func(ctx context.Context) error {
+			*n++
+			return err
+		})
+		rw.WriteHeader(status)
+	})
+}
+
+func journaledRequest(key string) *http.Request {
+	r := httptest.NewRequest("POST", "/test", nil)
+	r.Header.Set(IdempotencyKeyHeader, key)
+	return r
+}
+
+func (suite *HyperdriveTestSuite) TestJournalMiddleware() {
+	defer func(c Config) { conf = c }(conf)
+	conf.DeployMode = "serverless"
+	var n int
+	h := suite.TestAPI.JournalMiddleware(nil)(journaledHandler(&n, http.StatusCreated, nil))
+	h.ServeHTTP(httptest.NewRecorder(), journaledRequest("abc"))
+	suite.Equal(1, n, "expects the side effect to run once the request succeeds")
+	h.ServeHTTP(httptest.NewRecorder(), journaledRequest("abc"))
+	suite.Equal(1, n, "expects the side effect not to run again for a retry")
+	h.ServeHTTP(httptest.NewRecorder(), journaledRequest("def"))
+	suite.Equal(2, n, "expects the side effect to run for another request")
+}
+
+func (suite *HyperdriveTestSuite) TestJournalMiddlewareFailedRequest() {
+	defer func(c Config) { conf = c }(conf)
+	conf.DeployMode = "serverless"
+	var n int
+	h := suite.TestAPI.JournalMiddleware(nil)(journaledHandler(&n, http.StatusInternalServerError, nil))
+	h.ServeHTTP(httptest.NewRecorder(), journaledRequest("abc"))
+	suite.Equal(0, n, "expects the side effect not to run when the request fails")
+}
+
+func (suite *HyperdriveTestSuite) TestJournalMiddlewareFailedEffect() {
+	defer func(c Config) { conf = c }(conf)
+	conf.DeployMode = "serverless"
+	var n int
+	h := suite.TestAPI.JournalMiddleware(nil)(journaledHandler(&n, http.StatusOK, errors.New("failed")))
+	h.ServeHTTP(httptest.NewRecorder(), journaledRequest("abc"))
+	h.ServeHTTP(httptest.NewRecorder(), journaledRequest("abc"))
+	suite.Equal(2, n, "expects a failed side effect to be run again for a retry")
+}
+
+func (suite *HyperdriveTestSuite) TestJournalMiddlewareBackground() {
+	var n int
+	h := suite.TestAPI.JournalMiddleware(nil)(journaledHandler(&n, http.StatusOK, nil))
+	h.ServeHTTP(httptest.NewRecorder(), journaledRequest("abc"))
+	suite.Nil(suite.TestAPI.Shutdown(context.Background()), "expects no error")
+	suite.Equal(1, n, "expects the side effect to run in the background")
+}
+
+func (suite *HyperdriveTestSuite) TestGetJournal() {
+	suite.Nil(GetJournal(suite.TestGetRequest), "expects no journal outside of JournalMiddleware")
+	suite.Equal(ErrNotJournaled, GetJournal(suite.TestGetRequest).Add("effect", nil), "expects an error adding to a nil journal")
+}
@@ -0,0 +1,303 @@
+// Package jsonapi renders hyperdrive responses as JSON:API
+// (https://jsonapi.org) documents: resource objects with their
+// relationships, included resources, errors, sparse fieldsets, and
+// pagination links for the lists parsed by hyperdrive.ListParams, e.g.
+//
+//	func (e *UsersEndpoint) Get(rw http.ResponseWriter, r *http.Request) {
+//		q, err := hyperdrive.ListParams(e, r)
+//		if err != nil {
+//			jsonapi.RespondError(rw, http.StatusBadRequest, err)
+//			return
+//		}
+//		users, total := e.store.List(q)
+//		doc := &jsonapi.Document{Links: jsonapi.PaginationLinks(r, q, total)}
+//		for _, u := range users {
+//			res, _ := jsonapi.NewResource("users", strconv.Itoa(u.ID), u)
+//			doc.AddData(res)
+//		}
+//		doc.ApplyFields(jsonapi.Fields(r))
+//		jsonapi.Respond(rw, http.StatusOK, doc)
+//	}
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hyperdriven/hyperdrive"
+)
+
+// ContentType is the media type of JSON:API documents.
+const ContentType = "application/vnd.api+json"
+
+// The query params parsed by Fields and Include.
+const (
+	FieldsParam  = "fields"
+	IncludeParam = "include"
+)
+
+// Links are the links of a document, resource, or relationship, by name.
+type Links map[string]string
+
+// Document is a JSON:API top-level document. Data is a *Resource, or a
+// []*Resource for lists (see AddData). Documents with Errors have no Data.
+type Document struct {
+	Data     interface{}            `json:"data,omitempty"`
+	Errors   []Error                `json:"errors,omitempty"`
+	Included []*Resource            `json:"included,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+	Links    Links                  `json:"links,omitempty"`
+}
+
+// AddData appends a resource to the primary data of a list document.
+func (d *Document) AddData(res *Resource) {
+	list, _ := d.Data.([]*Resource)
+	d.Data = append(list, res)
+}
+
+// Include adds a related resource to the included resources, unless a
+// resource with the same type and id has already been included.
+func (d *Document) Include(res *Resource) {
+	for _, inc := range d.Included {
+		if inc.Type == res.Type && inc.ID == res.ID {
+			return
+		}
+	}
+	d.Included = append(d.Included, res)
+}
+
+// ApplyFields removes the attributes and relationships which were not
+// requested from the primary data and included resources, for the sparse
+// fieldsets returned by Fields. Resources of types without a fieldset are
+// left as they are.
+func (d *Document) ApplyFields(fields map[string][]string) {
+	if len(fields) == 0 {
+		return
+	}
+	switch data := d.Data.(type) {
+	case *Resource:
+		data.applyFields(fields)
+	case []*Resource:
+		for _, res := range data {
+			res.applyFields(fields)
+		}
+	}
+	for _, res := range d.Included {
+		res.applyFields(fields)
+	}
+}
+
+// MarshalJSON encodes the document, with a data member of null for documents
+// without data or errors, and of [] for empty lists.
+func (d *Document) MarshalJSON() ([]byte, error) {
+	type document Document
+	b, err := json.Marshal((*document)(d))
+	if err != nil || d.Data != nil || len(d.Errors) > 0 {
+		return b, err
+	}
+	if string(b) == "{}" {
+		return []byte(`{"data":null}`), nil
+	}
+	return append([]byte(`{"data":null,`), b[1:]...), nil
+}
+
+// Resource is a JSON:API resource object.
+type Resource struct {
+	Type          string                  `json:"type"`
+	ID            string                  `json:"id,omitempty"`
+	Attributes    map[string]interface{}  `json:"attributes,omitempty"`
+	Relationships map[string]Relationship `json:"relationships,omitempty"`
+	Links         Links                   `json:"links,omitempty"`
+	Meta          map[string]interface{}  `json:"meta,omitempty"`
+}
+
+// NewResource returns a Resource of the given type and id, whose attributes
+// are the fields of attributes (a struct or map), as they are encoded as
+// JSON. Fields named id or type are left out, as JSON:API reserves them.
+func NewResource(typ string, id string, attributes interface{}) (*Resource, error) {
+	res := &Resource{Type: typ, ID: id}
+	if attributes == nil {
+		return res, nil
+	}
+	b, err := json.Marshal(attributes)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&res.Attributes); err != nil || res.Attributes == nil {
+		return nil, errors.New("jsonapi: attributes must be encoded as a JSON object")
+	}
+	delete(res.Attributes, "id")
+	delete(res.Attributes, "type")
+	return res, nil
+}
+
+// Identifier returns the resource identifier object of the resource.
+func (res *Resource) Identifier() ResourceIdentifier {
+	return ResourceIdentifier{Type: res.Type, ID: res.ID}
+}
+
+// AddToOne adds a to-one relationship, to the related resource, or to none
+// if it is nil.
+func (res *Resource) AddToOne(name string, related *ResourceIdentifier, links Links) {
+	res.addRelationship(name, Relationship{Data: related, Links: links})
+}
+
+// AddToMany adds a to-many relationship, to the related resources.
+func (res *Resource) AddToMany(name string, related []ResourceIdentifier, links Links) {
+	if related == nil {
+		related = []ResourceIdentifier{}
+	}
+	res.addRelationship(name, Relationship{Data: related, Links: links})
+}
+
+func (res *Resource) addRelationship(name string, rel Relationship) {
+	if res.Relationships == nil {
+		res.Relationships = map[string]Relationship{}
+	}
+	res.Relationships[name] = rel
+}
+
+// applyFields removes the attributes and relationships not in the fieldset
+// for the type of the resource.
+func (res *Resource) applyFields(fields map[string][]string) {
+	fieldset, ok := fields[res.Type]
+	if !ok {
+		return
+	}
+	requested := map[string]bool{}
+	for _, f := range fieldset {
+		requested[f] = true
+	}
+	for k := range res.Attributes {
+		if !requested[k] {
+			delete(res.Attributes, k)
+		}
+	}
+	for k := range res.Relationships {
+		if !requested[k] {
+			delete(res.Relationships, k)
+		}
+	}
+}
+
+// ResourceIdentifier is a JSON:API resource identifier object, which refers
+// to a resource in a relationship.
+type ResourceIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// Relationship is a JSON:API relationship object. Data is a
+// *ResourceIdentifier or nil for to-one relationships, and a
+// []ResourceIdentifier for to-many relationships.
+type Relationship struct {
+	Data  interface{} `json:"data"`
+	Links Links       `json:"links,omitempty"`
+}
+
+// Error is a JSON:API error object.
+type Error struct {
+	Status string       `json:"status,omitempty"`
+	Code   string       `json:"code,omitempty"`
+	Title  string       `json:"title,omitempty"`
+	Detail string       `json:"detail,omitempty"`
+	Source *ErrorSource `json:"source,omitempty"`
+}
+
+// ErrorSource is the part of the request an Error was caused by: the JSON
+// Pointer of a value in the body, or the name of a query param.
+type ErrorSource struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+}
+
+// ValidationErrors returns an Error for each FieldError of the
+// *hyperdrive.ValidationError, e.g. returned by hyperdrive.Bind. Pointers to
+// values in the body are relative to the data member, as the attributes of a
+// resource are.
+func ValidationErrors(verr *hyperdrive.ValidationError) []Error {
+	errs := make([]Error, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		errs[i] = Error{
+			Status: strconv.Itoa(http.StatusBadRequest),
+			Code:   fe.Code,
+			Title:  http.StatusText(http.StatusBadRequest),
+			Detail: fe.Message,
+		}
+		if fe.In == hyperdrive.InBody {
+			errs[i].Source = &ErrorSource{Pointer: "/data/attributes" + fe.Pointer}
+		} else {
+			errs[i].Source = &ErrorSource{Parameter: fe.Parameter}
+		}
+	}
+	return errs
+}
+
+// Fields returns the sparse fieldsets requested with fields[TYPE] query
+// params, e.g. fields[users]=name,email, by type.
+func Fields(r *http.Request) map[string][]string {
+	fields := map[string][]string{}
+	for k, v := range r.URL.Query() {
+		if !strings.HasPrefix(k, FieldsParam+"[") || !strings.HasSuffix(k, "]") || len(v) == 0 {
+			continue
+		}
+		typ := k[len(FieldsParam)+1 : len(k)-1]
+		fields[typ] = []string{}
+		for _, f := range strings.Split(v[0], ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields[typ] = append(fields[typ], f)
+			}
+		}
+	}
+	return fields
+}
+
+// Include returns the relationship paths requested with the include query
+// param, e.g. include=author,comments.author.
+func Include(r *http.Request) []string {
+	var paths []string
+	for _, p := range strings.Split(r.URL.Query().Get(IncludeParam), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// PaginationLinks returns the self, first, prev, next and last links of the
+// list requested, for the page and per_page query params parsed by
+// hyperdrive.ListParams (see hyperdrive.PageLinks).
+func PaginationLinks(r *http.Request, q hyperdrive.ListQuery, total int) Links {
+	links := Links{"self": r.URL.RequestURI()}
+	for rel, href := range hyperdrive.PageLinks(r, q, total) {
+		links[rel] = href
+	}
+	return links
+}
+
+// Respond responds with the given status, and the document, with a
+// Content-Type of application/vnd.api+json, as hyperdrive.RespondJSON does.
+func Respond(rw http.ResponseWriter, status int, doc *Document) error {
+	return hyperdrive.RespondJSON(rw, status, doc, hyperdrive.RenderOptions{ContentType: ContentType})
+}
+
+// RespondError responds with an errors document: a 400 Bad Request with an
+// Error for each FieldError if err is a *hyperdrive.ValidationError, and
+// otherwise the given status, with an Error describing err (see
+// hyperdrive.GetErrorText, so its message is not leaked in production).
+func RespondError(rw http.ResponseWriter, status int, err error) error {
+	if verr, ok := err.(*hyperdrive.ValidationError); ok {
+		return Respond(rw, http.StatusBadRequest, &Document{Errors: ValidationErrors(verr)})
+	}
+	return Respond(rw, status, &Document{Errors: []Error{{
+		Status: strconv.Itoa(status),
+		Title:  http.StatusText(status),
+		Detail: hyperdrive.GetErrorText(status, err),
+	}}})
+}
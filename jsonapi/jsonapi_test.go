@@ -0,0 +1,125 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperdriven/hyperdrive"
+	"github.com/stretchr/testify/suite"
+)
+
+type JSONAPITestSuite struct {
+	suite.Suite
+}
+
+type testUser struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (suite *JSONAPITestSuite) TestNewResource() {
+	res, err := NewResource("users", "42", testUser{ID: 42, Name: "Ada", Email: "ada@example.com"})
+	suite.Nil(err, "expects no error")
+	suite.Equal(map[string]interface{}{"name": "Ada", "email": "ada@example.com"}, res.Attributes, "expects the fields, without the id, as attributes")
+	_, err = NewResource("users", "42", []string{"Ada"})
+	suite.Error(err, "expects an error for attributes which are not an object")
+}
+
+func (suite *JSONAPITestSuite) TestDocument() {
+	res, _ := NewResource("articles", "1", map[string]string{"title": "Hypermedia"})
+	res.AddToOne("author", &ResourceIdentifier{Type: "users", ID: "42"}, Links{"related": "/articles/1/author"})
+	res.AddToMany("tags", nil, nil)
+	author, _ := NewResource("users", "42", testUser{Name: "Ada"})
+	doc := &Document{Data: res}
+	doc.Include(author)
+	doc.Include(author)
+	b, err := json.Marshal(doc)
+	suite.Nil(err, "expects no error")
+	suite.JSONEq(`{
+		"data": {
+			"type": "articles",
+			"id": "1",
+			"attributes": {"title": "Hypermedia"},
+			"relationships": {
+				"author": {"data": {"type": "users", "id": "42"}, "links": {"related": "/articles/1/author"}},
+				"tags": {"data": []}
+			}
+		},
+		"included": [{"type": "users", "id": "42", "attributes": {"name": "Ada", "email": ""}}]
+	}`, string(b), "expects a compliant document, including each resource once")
+}
+
+func (suite *JSONAPITestSuite) TestDocumentData() {
+	b, _ := json.Marshal(&Document{})
+	suite.JSONEq(`{"data": null}`, string(b), "expects null data")
+	b, _ = json.Marshal(&Document{Meta: map[string]interface{}{"total": 0}})
+	suite.JSONEq(`{"data": null, "meta": {"total": 0}}`, string(b), "expects null data alongside other members")
+	doc := &Document{}
+	r1, _ := NewResource("users", "1", nil)
+	doc.AddData(r1)
+	b, _ = json.Marshal(doc)
+	suite.JSONEq(`{"data": [{"type": "users", "id": "1"}]}`, string(b), "expects a list")
+}
+
+func (suite *JSONAPITestSuite) TestFields() {
+	r := httptest.NewRequest("GET", "/articles?fields[articles]=title,author&fields[users]=name&include=author,%20comments.author", nil)
+	suite.Equal(map[string][]string{"articles": {"title", "author"}, "users": {"name"}}, Fields(r), "expects the fieldsets by type")
+	suite.Equal([]string{"author", "comments.author"}, Include(r), "expects the included paths")
+
+	res, _ := NewResource("articles", "1", map[string]string{"title": "Hypermedia", "body": "..."})
+	res.AddToOne("author", &ResourceIdentifier{Type: "users", ID: "42"}, nil)
+	res.AddToMany("comments", nil, nil)
+	author, _ := NewResource("users", "42", testUser{Name: "Ada", Email: "ada@example.com"})
+	doc := &Document{Data: []*Resource{res}, Included: []*Resource{author}}
+	doc.ApplyFields(Fields(r))
+	suite.Equal(map[string]interface{}{"title": "Hypermedia"}, res.Attributes, "expects only the requested attributes")
+	suite.Contains(res.Relationships, "author", "expects the requested relationships")
+	suite.NotContains(res.Relationships, "comments", "expects only the requested relationships")
+	suite.Equal(map[string]interface{}{"name": "Ada"}, author.Attributes, "expects fieldsets to apply to included resources")
+}
+
+func (suite *JSONAPITestSuite) TestPaginationLinks() {
+	r := httptest.NewRequest("GET", "/articles?page=2&per_page=10", nil)
+	links := PaginationLinks(r, hyperdrive.ListQuery{Page: 2, PerPage: 10}, 25)
+	suite.Equal(Links{
+		"self":  "/articles?page=2&per_page=10",
+		"first": "/articles?page=1&per_page=10",
+		"prev":  "/articles?page=1&per_page=10",
+		"next":  "/articles?page=3&per_page=10",
+		"last":  "/articles?page=3&per_page=10",
+	}, links, "expects links to the pages of the list")
+}
+
+func (suite *JSONAPITestSuite) TestRespond() {
+	rw := httptest.NewRecorder()
+	res, _ := NewResource("users", "1", nil)
+	suite.Nil(Respond(rw, http.StatusCreated, &Document{Data: res}), "expects no error")
+	suite.Equal(http.StatusCreated, rw.Code, "expects the status to be written")
+	suite.Equal(ContentType, rw.Header().Get("Content-Type"), "expects the JSON:API Content-Type")
+}
+
+func (suite *JSONAPITestSuite) TestRespondError() {
+	verr := &hyperdrive.ValidationError{}
+	verr.AddBodyError("/name", hyperdrive.ValidationRequired, "Missing required field: name")
+	verr.AddParamError(hyperdrive.InQuery, "page", hyperdrive.ValidationInvalidType, "page must be an integer")
+	rw := httptest.NewRecorder()
+	RespondError(rw, http.StatusInternalServerError, verr)
+	suite.Equal(http.StatusBadRequest, rw.Code, "expects validation errors to be a 400")
+	suite.JSONEq(`{"errors": [
+		{"status": "400", "code": "required", "title": "Bad Request", "detail": "Missing required field: name", "source": {"pointer": "/data/attributes/name"}},
+		{"status": "400", "code": "invalid_type", "title": "Bad Request", "detail": "page must be an integer", "source": {"parameter": "page"}}
+	]}`, rw.Body.String(), "expects an error for each field")
+
+	rw = httptest.NewRecorder()
+	RespondError(rw, http.StatusConflict, errors.New("already exists"))
+	suite.Equal(http.StatusConflict, rw.Code, "expects the status to be written")
+	suite.JSONEq(`{"errors": [{"status": "409", "title": "Conflict", "detail": "already exists"}]}`, rw.Body.String(), "expects the error to be described")
+}
+
+func TestJSONAPITestSuite(t *testing.T) {
+	suite.Run(t, new(JSONAPITestSuite))
+}
@@ -0,0 +1,74 @@
+// Package kafka provides a hyperdrive.QueueAdapter which receives messages
+// from a Kafka topic, using a consumer group reader, so they can be handled
+// by a hyperdrive consumer.
+//
+//	reader := kafkago.NewReader(kafkago.ReaderConfig{
+//		Brokers: strings.Split(os.Getenv("KAFKA_BROKERS"), ","),
+//		GroupID: "orders-worker",
+//		Topic:   "orders",
+//	})
+//	api.AddConsumer("orders", kafka.NewAdapter(reader), handler)
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hyperdriven/hyperdrive"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Reader interface is satisfied by a kafka-go Reader, and holds the methods
+// used by Adapter.
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafkago.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafkago.Message) error
+}
+
+// Adapter is an implementation of hyperdrive.QueueAdapter, which fetches one
+// message at a time from a Reader. Acked messages have their offset
+// committed. Kafka has no way to redeliver a single message, so nacked
+// messages are not committed, and are only redelivered once the consumer
+// group is rebalanced (e.g. the process restarts) before a later offset in
+// the same partition is committed. Use CONSUMER_MAX_ATTEMPTS to retry
+// messages in process instead.
+type Adapter struct {
+	Reader Reader
+}
+
+// NewAdapter creates an instance of Adapter, for the given Reader.
+func NewAdapter(reader Reader) *Adapter {
+	return &Adapter{Reader: reader}
+}
+
+// Receive fetches the next message from the Reader, blocking until one
+// arrives or the context is done.
+func (a *Adapter) Receive(ctx context.Context) ([]*hyperdrive.Message, error) {
+	msg, err := a.Reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := &hyperdrive.Message{
+		ID:         fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset),
+		Body:       msg.Value,
+		Attributes: map[string]string{},
+		Raw:        msg,
+	}
+	if len(msg.Key) > 0 {
+		m.Attributes["key"] = string(msg.Key)
+	}
+	for _, h := range msg.Headers {
+		m.Attributes[h.Key] = string(h.Value)
+	}
+	return []*hyperdrive.Message{m}, nil
+}
+
+// Ack commits the offset of the message.
+func (a *Adapter) Ack(ctx context.Context, m *hyperdrive.Message) error {
+	return a.Reader.CommitMessages(ctx, m.Raw.(kafkago.Message))
+}
+
+// Nack leaves the offset of the message uncommitted.
+func (a *Adapter) Nack(ctx context.Context, m *hyperdrive.Message) error {
+	return nil
+}
@@ -0,0 +1,71 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperdriven/hyperdrive"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/suite"
+)
+
+type testReader struct {
+	committed []kafkago.Message
+}
+
+func (r *testReader) FetchMessage(ctx context.Context) (kafkago.Message, error) {
+	return kafkago.Message{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    42,
+		Key:       []byte("customer-1"),
+		Value:     []byte(`{"id":1}`),
+		Headers:   []kafkago.Header{{Key: "type", Value: []byte("order.created")}},
+	}, nil
+}
+
+func (r *testReader) CommitMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+type KafkaTestSuite struct {
+	suite.Suite
+	Reader  *testReader
+	Adapter *Adapter
+}
+
+func (suite *KafkaTestSuite) SetupTest() {
+	suite.Reader = &testReader{}
+	suite.Adapter = NewAdapter(suite.Reader)
+}
+
+func (suite *KafkaTestSuite) TestQueueAdapter() {
+	suite.Implements((*hyperdrive.QueueAdapter)(nil), suite.Adapter, "expects an implementation of hyperdrive.QueueAdapter")
+}
+
+func (suite *KafkaTestSuite) TestReceive() {
+	messages, err := suite.Adapter.Receive(context.Background())
+	suite.Nil(err, "expects no error")
+	suite.Equal(1, len(messages), "expects the message fetched")
+	suite.Equal("orders/2/42", messages[0].ID, "expects the ID to be the topic, partition, and offset")
+	suite.Equal(`{"id":1}`, string(messages[0].Body), "expects the value of the message")
+	suite.Equal("customer-1", messages[0].Attributes["key"], "expects the key of the message")
+	suite.Equal("order.created", messages[0].Attributes["type"], "expects the headers of the message")
+}
+
+func (suite *KafkaTestSuite) TestAck() {
+	messages, _ := suite.Adapter.Receive(context.Background())
+	suite.Nil(suite.Adapter.Ack(context.Background(), messages[0]), "expects no error")
+	suite.Equal(1, len(suite.Reader.committed), "expects acked messages to be committed")
+}
+
+func (suite *KafkaTestSuite) TestNack() {
+	messages, _ := suite.Adapter.Receive(context.Background())
+	suite.Nil(suite.Adapter.Nack(context.Background(), messages[0]), "expects no error")
+	suite.Equal(0, len(suite.Reader.committed), "expects nacked messages not to be committed")
+}
+
+func TestKafkaTestSuite(t *testing.T) {
+	suite.Run(t, new(KafkaTestSuite))
+}
@@ -0,0 +1,272 @@
+// Package lambda runs a hyperdrive API on AWS Lambda, behind API Gateway
+// (REST or HTTP APIs) or an Application Load Balancer, instead of listening
+// on a socket. Events are converted to http.Requests, and served by the same
+// router and middleware chains as the long running server.
+//
+//	func main() {
+//		api := hyperdrive.NewAPI("API", "An example API.")
+//		api.AddEndpoint(NewWidgetEndpoint())
+//		if lambda.Detected() {
+//			lambda.Start(&api)
+//			return
+//		}
+//		api.Start()
+//	}
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	awslambda "github.com/aws/aws-lambda-go/lambda"
+	"github.com/hyperdriven/hyperdrive"
+)
+
+// Detected returns true if the process is running on AWS Lambda.
+func Detected() bool {
+	return os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != ""
+}
+
+// Start starts handling Lambda events with api, and blocks until the Lambda
+// runtime stops the process. Consumers added to the API are not run.
+func Start(api *hyperdrive.API) {
+	awslambda.StartHandler(NewHandler(api))
+}
+
+// Handler is an implementation of the Lambda Handler interface, which serves
+// API Gateway and ALB events with an http.Handler. The kind of event is
+// detected from its payload: version 2.0 events are from API Gateway HTTP
+// APIs, events with an elb request context are from an ALB, and any other
+// events are from API Gateway REST APIs.
+type Handler struct {
+	Handler http.Handler
+}
+
+// NewHandler creates an instance of Handler, serving requests with the API's
+// server handler (i.e. its router, with redirects applied).
+func NewHandler(api *hyperdrive.API) *Handler {
+	return &Handler{Handler: api.Server.Handler}
+}
+
+// event holds the fields used to detect the kind of event.
+type event struct {
+	Version        string `json:"version"`
+	RequestContext struct {
+		ELB *json.RawMessage `json:"elb"`
+	} `json:"requestContext"`
+}
+
+// Invoke serves a single Lambda event.
+func (h *Handler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var e event
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, err
+	}
+	switch {
+	case e.Version == "2.0":
+		var req events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		resp, err := h.ServeHTTPAPI(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(resp)
+	case e.RequestContext.ELB != nil:
+		var req events.ALBTargetGroupRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		resp, err := h.ServeALB(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(resp)
+	default:
+		var req events.APIGatewayProxyRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		resp, err := h.ServeRESTAPI(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(resp)
+	}
+}
+
+// ServeRESTAPI serves an event from an API Gateway REST API (i.e. a version
+// 1.0 proxy integration).
+func (h *Handler) ServeRESTAPI(ctx context.Context, e events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	query := url.Values{}
+	mergeValues(query.Add, e.QueryStringParameters, e.MultiValueQueryStringParameters)
+	r, err := newRequest(ctx, e.HTTPMethod, e.Path, query.Encode(), e.Body, e.IsBase64Encoded)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	mergeValues(r.Header.Add, e.Headers, e.MultiValueHeaders)
+	r.RemoteAddr = e.RequestContext.Identity.SourceIP
+	rw := h.serve(r)
+	body, b64 := rw.body()
+	return events.APIGatewayProxyResponse{StatusCode: rw.status, MultiValueHeaders: rw.header, Body: body, IsBase64Encoded: b64}, nil
+}
+
+// ServeHTTPAPI serves an event from an API Gateway HTTP API (i.e. a version
+// 2.0 payload).
+func (h *Handler) ServeHTTPAPI(ctx context.Context, e events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	r, err := newRequest(ctx, e.RequestContext.HTTP.Method, e.RawPath, e.RawQueryString, e.Body, e.IsBase64Encoded)
+	if err != nil {
+		return events.APIGatewayV2HTTPResponse{}, err
+	}
+	for k, v := range e.Headers {
+		r.Header.Set(k, v)
+	}
+	if len(e.Cookies) > 0 {
+		r.Header.Set("Cookie", strings.Join(e.Cookies, "; "))
+	}
+	r.RemoteAddr = e.RequestContext.HTTP.SourceIP
+	rw := h.serve(r)
+	cookies := rw.header["Set-Cookie"]
+	delete(rw.header, "Set-Cookie")
+	body, b64 := rw.body()
+	return events.APIGatewayV2HTTPResponse{StatusCode: rw.status, MultiValueHeaders: rw.header, Body: body, IsBase64Encoded: b64, Cookies: cookies}, nil
+}
+
+// ServeALB serves an event from an Application Load Balancer. Headers are
+// returned as multiple values if the target group has multi value headers
+// enabled (i.e. the event has them).
+func (h *Handler) ServeALB(ctx context.Context, e events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	var query []string
+	for k, v := range e.QueryStringParameters {
+		query = append(query, k+"="+v)
+	}
+	for k, values := range e.MultiValueQueryStringParameters {
+		for _, v := range values {
+			query = append(query, k+"="+v)
+		}
+	}
+	r, err := newRequest(ctx, e.HTTPMethod, e.Path, strings.Join(query, "&"), e.Body, e.IsBase64Encoded)
+	if err != nil {
+		return events.ALBTargetGroupResponse{}, err
+	}
+	mergeValues(r.Header.Add, e.Headers, e.MultiValueHeaders)
+	rw := h.serve(r)
+	body, b64 := rw.body()
+	resp := events.ALBTargetGroupResponse{
+		StatusCode:        rw.status,
+		StatusDescription: http.StatusText(rw.status),
+		Body:              body,
+		IsBase64Encoded:   b64,
+	}
+	if e.MultiValueHeaders != nil {
+		resp.MultiValueHeaders = rw.header
+	} else {
+		resp.Headers = map[string]string{}
+		for k := range rw.header {
+			resp.Headers[k] = rw.header.Get(k)
+		}
+	}
+	return resp, nil
+}
+
+func (h *Handler) serve(r *http.Request) *responseWriter {
+	rw := &responseWriter{header: http.Header{}, status: http.StatusOK}
+	r.Host = r.Header.Get("Host")
+	h.Handler.ServeHTTP(rw, r)
+	return rw
+}
+
+// newRequest creates an http.Request from the parts of an event. The query
+// is expected to be URL encoded.
+func newRequest(ctx context.Context, method string, path string, query string, body string, b64 bool) (*http.Request, error) {
+	if path == "" {
+		path = "/"
+	}
+	b := []byte(body)
+	if b64 {
+		var err error
+		if b, err = base64.StdEncoding.DecodeString(body); err != nil {
+			return nil, errors.New("request body is not valid base64")
+		}
+	}
+	u := &url.URL{Path: path, RawQuery: query}
+	r, err := http.NewRequest(method, u.RequestURI(), bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	r.RequestURI = u.RequestURI()
+	return r.WithContext(ctx), nil
+}
+
+// mergeValues adds the single and multiple values of an event with the add
+// function, without duplicating values which appear in both.
+func mergeValues(add func(string, string), single map[string]string, multi map[string][]string) {
+	for k, values := range multi {
+		for _, value := range values {
+			add(k, value)
+		}
+	}
+	for k, value := range single {
+		if _, ok := multi[k]; !ok {
+			add(k, value)
+		}
+	}
+}
+
+// responseWriter is an http.ResponseWriter which buffers the response, so it
+// can be returned to Lambda.
+type responseWriter struct {
+	header      http.Header
+	status      int
+	buf         bytes.Buffer
+	wroteHeader bool
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.WriteHeader(http.StatusOK)
+	return rw.buf.Write(b)
+}
+
+// body returns the response body, base64 encoded unless it is text.
+func (rw *responseWriter) body() (string, bool) {
+	if rw.buf.Len() == 0 {
+		return "", false
+	}
+	if rw.header.Get("Content-Encoding") == "" && isText(rw.header.Get("Content-Type")) {
+		return rw.buf.String(), false
+	}
+	return base64.StdEncoding.EncodeToString(rw.buf.Bytes()), true
+}
+
+func isText(ct string) bool {
+	ct = strings.ToLower(ct)
+	if ct == "" || strings.HasPrefix(ct, "text/") {
+		return true
+	}
+	for _, s := range []string{"json", "xml", "javascript", "x-www-form-urlencoded"} {
+		if strings.Contains(ct, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package lambda
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/hyperdriven/hyperdrive"
+	"github.com/stretchr/testify/suite"
+)
+
+type LambdaTestSuite struct {
+	suite.Suite
+	Handler *Handler
+}
+
+func (suite *LambdaTestSuite) SetupTest() {
+	suite.Handler = &Handler{Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		http.SetCookie(rw, &http.Cookie{Name: "a", Value: "1"})
+		http.SetCookie(rw, &http.Cookie{Name: "b", Value: "2"})
+		rw.Header().Set("X-Method", r.Method)
+		rw.Header().Set("X-Query", r.URL.Query().Get("q"))
+		rw.Header().Set("X-Host", r.Host)
+		if r.URL.Path == "/binary" {
+			rw.Header().Set("Content-Type", "image/png")
+			rw.Write([]byte{0x89, 0x50})
+			return
+		}
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte(r.URL.Path + ":" + string(body)))
+	})}
+}
+
+func (suite *LambdaTestSuite) TestServeRESTAPI() {
+	resp, err := suite.Handler.ServeRESTAPI(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "POST",
+		Path:                  "/widgets",
+		Headers:               map[string]string{"Host": "api.example.com"},
+		QueryStringParameters: map[string]string{"q": "a b"},
+		Body:                  base64.StdEncoding.EncodeToString([]byte("sprocket")),
+		IsBase64Encoded:       true,
+	})
+	suite.Nil(err, "expects no error")
+	suite.Equal(http.StatusCreated, resp.StatusCode, "expects the status of the response")
+	suite.Equal("/widgets:sprocket", resp.Body, "expects the body to be decoded")
+	suite.Equal([]string{"a b"}, resp.MultiValueHeaders["X-Query"], "expects the query to be passed to the handler")
+	suite.Equal([]string{"api.example.com"}, resp.MultiValueHeaders["X-Host"], "expects the host to be passed to the handler")
+	suite.Equal(2, len(resp.MultiValueHeaders["Set-Cookie"]), "expects headers with multiple values")
+}
+
+func (suite *LambdaTestSuite) TestServeHTTPAPI() {
+	e := events.APIGatewayV2HTTPRequest{Version: "2.0", RawPath: "/widgets", RawQueryString: "q=a%20b", Body: "sprocket"}
+	e.RequestContext.HTTP.Method = "PUT"
+	resp, err := suite.Handler.ServeHTTPAPI(context.Background(), e)
+	suite.Nil(err, "expects no error")
+	suite.Equal(http.StatusCreated, resp.StatusCode, "expects the status of the response")
+	suite.Equal("/widgets:sprocket", resp.Body, "expects the body of the response")
+	suite.Equal([]string{"PUT"}, resp.MultiValueHeaders["X-Method"], "expects the method to be passed to the handler")
+	suite.Equal([]string{"a=1", "b=2"}, resp.Cookies, "expects cookies to be returned separately")
+}
+
+func (suite *LambdaTestSuite) TestServeALB() {
+	resp, err := suite.Handler.ServeALB(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/binary",
+		QueryStringParameters: map[string]string{"q": "a%20b"},
+	})
+	suite.Nil(err, "expects no error")
+	suite.Equal("OK", resp.StatusDescription, "expects the status description")
+	suite.True(resp.IsBase64Encoded, "expects binary bodies to be base64 encoded")
+	suite.Equal(base64.StdEncoding.EncodeToString([]byte{0x89, 0x50}), resp.Body, "expects the body to be base64 encoded")
+	suite.Equal("a b", resp.Headers["X-Query"], "expects the encoded query to be passed to the handler")
+	suite.Nil(resp.MultiValueHeaders, "expects single value headers unless the event has multiple value headers")
+}
+
+func (suite *LambdaTestSuite) TestInvoke() {
+	for name, payload := range map[string]string{
+		"rest": `{"httpMethod": "POST", "path": "/widgets", "body": "sprocket"}`,
+		"http": `{"version": "2.0", "rawPath": "/widgets", "body": "sprocket", "requestContext": {"http": {"method": "POST"}}}`,
+		"alb":  `{"httpMethod": "POST", "path": "/widgets", "body": "sprocket", "requestContext": {"elb": {"targetGroupArn": "arn"}}}`,
+	} {
+		out, err := suite.Handler.Invoke(context.Background(), []byte(payload))
+		suite.Nil(err, "expects no error for a %s event", name)
+		var resp struct {
+			StatusCode int    `json:"statusCode"`
+			Body       string `json:"body"`
+		}
+		json.Unmarshal(out, &resp)
+		suite.Equal(http.StatusCreated, resp.StatusCode, "expects the status of the response to a %s event", name)
+		suite.Equal("/widgets:sprocket", resp.Body, "expects the body of the response to a %s event", name)
+	}
+}
+
+func (suite *LambdaTestSuite) TestInvokeInvalid() {
+	_, err := suite.Handler.Invoke(context.Background(), []byte("not json"))
+	suite.Error(err, "expects an error for an invalid event")
+}
+
+func (suite *LambdaTestSuite) TestNewHandler() {
+	api := hyperdrive.NewAPI("API", "An example API.")
+	resp, err := NewHandler(&api).ServeRESTAPI(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/", Headers: map[string]string{"Accept": "application/json"}})
+	suite.Nil(err, "expects no error")
+	suite.Equal(http.StatusOK, resp.StatusCode, "expects the API's routes to be served")
+	suite.Contains(resp.Body, `"name":"API"`, "expects the root resource to be served")
+}
+
+func TestLambdaTestSuite(t *testing.T) {
+	suite.Run(t, new(LambdaTestSuite))
+}
@@ -0,0 +1,78 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Common resources counted by a Ledger. Any other name can be used too.
+const (
+	LedgerDBQueries     = "db_queries"
+	LedgerExternalCalls = "external_calls"
+	LedgerBytesFetched  = "bytes_fetched"
+)
+
+// Ledger counts the resources (e.g. database queries, calls to other
+// services, bytes fetched) used while serving a single request. Every
+// instrumented request has a Ledger, which handlers, and the clients they
+// use, can add to. The totals are included in the json access log, and
+// recorded by MetricsMiddleware, so N+1 queries and runaway handlers can be
+// spotted in production.
+//
+// The methods of a nil Ledger do nothing, so the result of GetLedger can be
+// used without checking it, even outside of the middleware chain.
+type Ledger struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// GetLedger returns the Ledger for the given request, or nil if the request
+// is not being instrumented.
+func GetLedger(r *http.Request) *Ledger {
+	if s := GetResponseStats(r); s != nil {
+		return s.Ledger
+	}
+	return nil
+}
+
+// Add adds n to the count for the given resource.
+func (l *Ledger) Add(resource string, n int64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts == nil {
+		l.counts = map[string]int64{}
+	}
+	l.counts[resource] += n
+}
+
+// Inc adds 1 to the count for the given resource.
+func (l *Ledger) Inc(resource string) {
+	l.Add(resource, 1)
+}
+
+// Get returns the count for the given resource.
+func (l *Ledger) Get(resource string) int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.counts[resource]
+}
+
+// Counts returns a copy of the count for every resource used so far.
+func (l *Ledger) Counts() map[string]int64 {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counts := make(map[string]int64, len(l.counts))
+	for k, v := range l.counts {
+		counts[k] = v
+	}
+	return counts
+}
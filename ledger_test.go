@@ -0,0 +1,34 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestLedger() {
+	l := &Ledger{}
+	l.Inc(LedgerDBQueries)
+	l.Inc(LedgerDBQueries)
+	l.Add(LedgerBytesFetched, 512)
+	suite.Equal(int64(2), l.Get(LedgerDBQueries), "expects counts to be incremented")
+	suite.Equal(map[string]int64{LedgerDBQueries: 2, LedgerBytesFetched: 512}, l.Counts(), "expects a copy of every count")
+}
+
+func (suite *HyperdriveTestSuite) TestLedgerNil() {
+	var l *Ledger
+	l.Inc(LedgerDBQueries)
+	suite.Equal(int64(0), l.Get(LedgerDBQueries), "expects a nil Ledger to do nothing")
+	suite.Nil(GetLedger(suite.TestGetRequest), "expects nil when the request is not being instrumented")
+}
+
+func (suite *HyperdriveTestSuite) TestGetLedger() {
+	var counts map[string]int64
+	h := instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		GetResponseStats(r).OnComplete(func(r *http.Request, s *ResponseStats) {
+			counts = s.Ledger.Counts()
+		})
+		GetLedger(r).Inc(LedgerExternalCalls)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	suite.Equal(map[string]int64{LedgerExternalCalls: 1}, counts, "expects the request's Ledger to be counted")
+}
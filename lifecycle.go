@@ -0,0 +1,213 @@
+package hyperdrive
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// serverlessShutdownTimeout is the longest a serverless API waits for
+// requests to finish once asked to shut down, leaving time to exit before the
+// platform kills the process (Cloud Run allows 10 seconds after SIGTERM).
+const serverlessShutdownTimeout = 8 * time.Second
+
+// lifecycle tracks whether the API is ready to serve requests, shutting down
+// (i.e. no longer ready), or draining, and the background work started with
+// Background, shared by every copy of the API. Consumers stop receiving
+// messages once the consuming context is cancelled, when draining begins.
+type lifecycle struct {
+	mu            sync.Mutex
+	ready         bool
+	shuttingDown  bool
+	draining      bool
+	wg            sync.WaitGroup
+	ctx           context.Context
+	cancel        context.CancelFunc
+	consuming     context.Context
+	stopConsuming context.CancelFunc
+}
+
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	consuming, stopConsuming := context.WithCancel(ctx)
+	return &lifecycle{ctx: ctx, cancel: cancel, consuming: consuming, stopConsuming: stopConsuming}
+}
+
+// goConsumers runs the API's consumers in a new goroutine, which Shutdown
+// waits for: they receive messages until draining begins, and the messages
+// they are handling are given until the shutdown deadline to finish.
+func (api *API) goConsumers() {
+	l := api.lifecycle
+	l.goTracked(func(ctx context.Context) {
+		api.runConsumers(l.consuming, ctx)
+	})
+}
+
+// goTracked runs f in a new goroutine, which Shutdown waits for. It returns
+// false, without running f, if the API is draining.
+func (l *lifecycle) goTracked(f func(context.Context)) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.draining {
+		return false
+	}
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		f(l.ctx)
+	}()
+	return true
+}
+
+// Ready returns true once the API is serving requests (i.e. Start has bound
+// its listeners), until it begins shutting down.
+func (api *API) Ready() bool {
+	api.lifecycle.mu.Lock()
+	defer api.lifecycle.mu.Unlock()
+	return api.lifecycle.ready && !api.lifecycle.shuttingDown
+}
+
+// SetReady marks the API as ready, or not ready, to serve requests. Start
+// calls it for you; use it if the API is served some other way.
+func (api *API) SetReady(ready bool) {
+	api.lifecycle.mu.Lock()
+	defer api.lifecycle.mu.Unlock()
+	api.lifecycle.ready = ready
+}
+
+// ReadyHandler returns an http.Handler which responds with 200 OK if the API
+// is Ready, and 503 SERVICE UNAVAILABLE otherwise, so load balancers stop
+// sending requests while it shuts down. Health checks are not run, so it
+// responds immediately; use CheckHealth for those. It is served on READY_PATH
+// (default: "/ready").
+func (api *API) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		status, ready := http.StatusOK, api.Ready()
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-store")
+		rw.WriteHeader(status)
+		json.NewEncoder(rw).Encode(map[string]bool{"ready": ready})
+	})
+}
+
+// Background runs work which does not need to finish before the response to
+// r is sent. When the API is serverless (see Config.Serverless), where the
+// CPU is throttled outside of requests, f is run once the response has been
+// written, before the request completes. Otherwise f is run in a new
+// goroutine, which Shutdown waits for, and its context is cancelled if the
+// shutdown deadline passes. Panics in f are logged, and forwarded to every
+// PanicReporter registered with AddPanicReporter.
+func (api *API) Background(r *http.Request, f func(context.Context)) {
+	run := func(ctx context.Context) {
+		defer func() {
+			if v := recover(); v != nil {
+				p := Panic{Value: v, Stack: debug.Stack()}
+				log.Println(v)
+				if conf.Env != "production" {
+					log.Printf("%s", p.Stack)
+				}
+				api.panicReporters.report(p)
+			}
+		}()
+		f(ctx)
+	}
+	if conf.Serverless() {
+		if s := GetResponseStats(r); s != nil {
+			s.OnComplete(func(r *http.Request, s *ResponseStats) {
+				run(context.Background())
+			})
+			return
+		}
+		run(r.Context())
+		return
+	}
+	if !api.lifecycle.goTracked(run) {
+		run(context.Background())
+	}
+}
+
+// Shutdown gracefully shuts down the API's Server, waiting (until the context
+// is done) for active requests, streams, consumers, and background work to
+// finish. Streams registered with TrackStream are drained, and given
+// STREAM_DRAIN_TIMEOUT to end, and consumers stop receiving messages, and
+// finish handling those they have. ReadyHandler responds with 503 SERVICE
+// UNAVAILABLE from the moment it is called.
+//
+// Load balancers can take a while to notice an instance is no longer ready,
+// and keep sending it requests, which fail once the Server has shut down.
+// When DRAIN_OVERLAP is set (e.g. to twice the health check interval), the
+// API keeps serving new requests for that long after becoming unready, before
+// it starts draining. Serverless APIs do not wait.
+func (api *API) Shutdown(ctx context.Context) error {
+	l := api.lifecycle
+	l.mu.Lock()
+	l.shuttingDown = true
+	l.mu.Unlock()
+	if conf.DrainOverlap > 0 && !conf.Serverless() {
+		log.Printf("Not ready, serving requests for another %s before draining", conf.DrainOverlap)
+		select {
+		case <-time.After(conf.DrainOverlap):
+		case <-ctx.Done():
+		}
+	}
+	l.mu.Lock()
+	l.draining = true
+	l.mu.Unlock()
+	l.stopConsuming()
+	drained := api.streams.drain(conf.StreamDrainTimeout)
+	err := api.Server.Shutdown(ctx)
+	done := make(chan struct{})
+	go func() {
+		<-drained
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	l.cancel()
+	return err
+}
+
+// shutdownTimeout returns SHUTDOWN_TIMEOUT, capped for serverless APIs.
+func shutdownTimeout() time.Duration {
+	if conf.Serverless() && conf.ShutdownTimeout > serverlessShutdownTimeout {
+		return serverlessShutdownTimeout
+	}
+	return conf.ShutdownTimeout
+}
+
+// shutdownOnSignal shuts the API down when the process receives SIGTERM or
+// SIGINT, closing the returned channel once it has.
+func (api *API) shutdownOnSignal() <-chan struct{} {
+	done := make(chan struct{})
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		sig := <-c
+		signal.Stop(c)
+		timeout := shutdownTimeout()
+		log.Printf("Received %s, shutting down hyperdriven API (timeout: %s): %s", sig, timeout, api.Name)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := api.Shutdown(ctx); err != nil {
+			log.Printf("Shutdown did not complete: %v", err)
+		}
+		close(done)
+	}()
+	return done
+}
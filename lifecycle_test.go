@@ -0,0 +1,122 @@
+package hyperdrive
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestReady() {
+	suite.False(suite.TestAPI.Ready(), "expects the API not to be ready until it is started")
+	suite.TestAPI.SetReady(true)
+	suite.True(suite.TestAPI.Ready(), "expects the API to be ready")
+	suite.Nil(suite.TestAPI.Shutdown(context.Background()), "expects no error")
+	suite.False(suite.TestAPI.Ready(), "expects the API not to be ready once it is shutting down")
+}
+
+func (suite *HyperdriveTestSuite) TestShutdownDrainOverlap() {
+	defer func(c Config) { conf = c }(conf)
+	conf.DrainOverlap = 50 * time.Millisecond
+	conf.DeployMode = "server"
+	suite.TestAPI.SetReady(true)
+	done := make(chan error)
+	go func() {
+		done <- suite.TestAPI.Shutdown(context.Background())
+	}()
+	time.Sleep(10 * time.Millisecond)
+	suite.False(suite.TestAPI.Ready(), "expects the API not to be ready during the overlap")
+	suite.True(suite.TestAPI.lifecycle.goTracked(func(context.Context) {}), "expects the API not to be draining during the overlap")
+	suite.Nil(<-done, "expects no error")
+	suite.False(suite.TestAPI.lifecycle.goTracked(func(context.Context) {}), "expects the API to be draining after the overlap")
+}
+
+func (suite *HyperdriveTestSuite) TestReadyHandler() {
+	rw := httptest.NewRecorder()
+	suite.TestAPI.Router.ServeHTTP(rw, httptest.NewRequest("GET", "/ready", nil))
+	suite.Equal(http.StatusServiceUnavailable, rw.Code, "expects a 503 when the API is not ready")
+	suite.TestAPI.SetReady(true)
+	rw = httptest.NewRecorder()
+	suite.TestAPI.Router.ServeHTTP(rw, httptest.NewRequest("GET", "/ready", nil))
+	suite.Equal(http.StatusOK, rw.Code, "expects a 200 when the API is ready")
+	suite.Equal("{\"ready\":true}\n", rw.Body.String(), "expects the readiness in the body")
+}
+
+func (suite *HyperdriveTestSuite) TestBackground() {
+	done := make(chan struct{})
+	suite.TestAPI.Background(suite.TestGetRequest, func(ctx context.Context) {
+		time.Sleep(10 * time.Millisecond)
+		close(done)
+	})
+	suite.Nil(suite.TestAPI.Shutdown(context.Background()), "expects no error")
+	select {
+	case <-done:
+	default:
+		suite.Fail("expects Shutdown to wait for background work")
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestBackgroundShutdownTimeout() {
+	var cancelled bool
+	suite.TestAPI.Background(suite.TestGetRequest, func(ctx context.Context) {
+		<-ctx.Done()
+		cancelled = true
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	suite.Equal(context.DeadlineExceeded, suite.TestAPI.Shutdown(ctx), "expects an error when background work does not finish in time")
+	suite.TestAPI.lifecycle.wg.Wait()
+	suite.True(cancelled, "expects the context of background work to be cancelled")
+}
+
+func (suite *HyperdriveTestSuite) TestBackgroundServerless() {
+	conf.DeployMode = "serverless"
+	defer func() { conf.DeployMode = "auto" }()
+	var order []string
+	h := instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		suite.TestAPI.Background(r, func(ctx context.Context) {
+			order = append(order, "background")
+		})
+		order = append(order, "handler")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	suite.Equal([]string{"handler", "background"}, order, "expects background work to run once the response is written, before the request completes")
+}
+
+func (suite *HyperdriveTestSuite) TestBackgroundPanic() {
+	var reported bool
+	suite.TestAPI.AddPanicReporter(PanicReporterFunc(func(p Panic) {
+		reported = true
+	}))
+	suite.TestAPI.Background(suite.TestGetRequest, func(ctx context.Context) {
+		panic("boom")
+	})
+	suite.TestAPI.Shutdown(context.Background())
+	suite.True(reported, "expects panics in background work to be reported")
+}
+
+func (suite *HyperdriveTestSuite) TestShutdownTimeout() {
+	suite.Equal(15*time.Second, shutdownTimeout(), "expects SHUTDOWN_TIMEOUT")
+	conf.DeployMode = "serverless"
+	defer func() { conf.DeployMode = "auto" }()
+	suite.Equal(serverlessShutdownTimeout, shutdownTimeout(), "expects the timeout to be capped for serverless APIs")
+}
+
+func (suite *HyperdriveTestSuite) TestShutdownConsumers() {
+	handled := make(chan struct{})
+	q := NewMemoryQueue(10)
+	q.Publish([]byte("one"))
+	suite.TestAPI.AddConsumer("widgets", q, MessageHandlerFunc(func(ctx context.Context, m *Message) error {
+		close(handled)
+		return nil
+	}))
+	suite.TestAPI.goConsumers()
+	<-handled
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	suite.Nil(suite.TestAPI.Shutdown(ctx), "expects consumers to stop once the API is draining")
+	suite.True(time.Since(start) < 500*time.Millisecond, "expects Shutdown not to wait for the deadline")
+	acked, _ := q.Stats()
+	suite.Equal(1, acked, "expects the message to be acked")
+}
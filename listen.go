@@ -0,0 +1,82 @@
+package hyperdrive
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Listen binds every address returned by Config.GetListenAddrs, and returns
+// the resulting listeners. If any address can not be bound, the listeners
+// already created are closed, and an error naming the failing address is
+// returned.
+//
+// When PROXY_PROTOCOL is true, every connection must begin with a HAProxy
+// PROXY protocol (v1 or v2) header, and the client address it contains is
+// used as the connection's remote address (i.e. http.Request.RemoteAddr).
+// Only enable this behind a load balancer that sends the header, as it is
+// trusted unconditionally. PROXY_PROTOCOL_TIMEOUT (default: 5s) limits how
+// long a connection may take to send the header.
+func (api *API) Listen() ([]net.Listener, error) {
+	addrs, err := conf.GetListenAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var listeners []net.Listener
+	for _, addr := range addrs {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, fmt.Errorf("could not listen on %s: %v", addr, err)
+		}
+		if conf.ProxyProtocol {
+			l = newProxyListener(l, conf.ProxyProtocolTimeout)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// Serve accepts connections on each of the given listeners, handling them
+// with the API's Server. It blocks until one of the listeners fails, and
+// returns that error, after closing the remaining listeners.
+//
+// When TLS_CERT_FILE and TLS_KEY_FILE are set, connections are served over
+// TLS, rejecting handshakes below TLS_MIN_VERSION (default: 1.2), or, when
+// TLS_CIPHER_SUITES is set, using any other cipher suite. When HTTP3_ENABLED
+// is true, the API is also served over HTTP/3 (QUIC) on HTTP3_ADDR (a UDP
+// address, which defaults to the first listen address), and every response
+// served over TCP advertises it with an Alt-Svc header. HTTP/3 support is
+// experimental.
+func (api *API) Serve(listeners ...net.Listener) error {
+	if len(listeners) == 0 {
+		return errors.New("no listeners to serve")
+	}
+	errs := make(chan error, len(listeners)+1)
+	if conf.HTTP3Enabled {
+		h3 := api.newHTTP3Server()
+		defer h3.Close()
+		api.Server.Handler = altSvcHandler(api.Server.Handler, h3.Addr)
+		go func() {
+			errs <- h3.ListenAndServeTLS(conf.TLSCertFile, conf.TLSKeyFile)
+		}()
+	}
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			if conf.TLSEnabled() {
+				errs <- api.Server.ServeTLS(l, conf.TLSCertFile, conf.TLSKeyFile)
+				return
+			}
+			errs <- api.Server.Serve(l)
+		}(l)
+	}
+	err := <-errs
+	closeListeners(listeners)
+	return err
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, l := range listeners {
+		l.Close()
+	}
+}
@@ -0,0 +1,43 @@
+package hyperdrive
+
+import "net"
+
+func (suite *HyperdriveTestSuite) TestListen() {
+	conf.ListenAddrs = "127.0.0.1:0"
+	defer func() { conf.ListenAddrs = "" }()
+	listeners, err := suite.TestAPI.Listen()
+	suite.Nil(err, "expects no error")
+	suite.Equal(1, len(listeners), "expects a listener for each address")
+	closeListeners(listeners)
+}
+
+func (suite *HyperdriveTestSuite) TestListenError() {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	conf.ListenAddrs = l.Addr().String()
+	defer func() { conf.ListenAddrs = "" }()
+	_, err := suite.TestAPI.Listen()
+	suite.Contains(err.Error(), l.Addr().String(), "expects the error to name the address that failed")
+}
+
+func (suite *HyperdriveTestSuite) TestServeNoListeners() {
+	suite.Error(suite.TestAPI.Serve(), "expects an error when there is nothing to serve")
+}
+
+func (suite *HyperdriveTestSuite) TestServeClosed() {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	l.Close()
+	suite.Error(suite.TestAPI.Serve(l), "expects the listener error to be returned")
+}
+
+func (suite *HyperdriveTestSuite) TestListenProxyProtocol() {
+	conf.ListenAddrs = "127.0.0.1:0"
+	conf.ProxyProtocol = true
+	defer func() {
+		conf.ListenAddrs = ""
+		conf.ProxyProtocol = false
+	}()
+	listeners, _ := suite.TestAPI.Listen()
+	suite.IsType(&proxyListener{}, listeners[0], "expects listeners to read the PROXY protocol header")
+	closeListeners(listeners)
+}
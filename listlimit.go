@@ -0,0 +1,47 @@
+package hyperdrive
+
+import (
+	"log"
+	"net/http"
+	"reflect"
+)
+
+// LedgerOversizedLists counts the list responses with more than
+// LIST_MAX_ITEMS items, in the request's Ledger.
+const LedgerOversizedLists = "oversized_lists"
+
+// limitList enforces LIST_MAX_ITEMS on the body given to Respond. Lists
+// (slices) with more items are counted in the request's Ledger,
+// logged, and, when LIST_LIMIT_ACTION is paginate, cut down to the page
+// requested by the cursor query param, with a Link to the next page. It
+// returns false if a response has already been written, because the cursor
+// is invalid.
+func limitList(rw http.ResponseWriter, r *http.Request, body interface{}) (interface{}, bool) {
+	v := reflect.ValueOf(body)
+	if conf.ListMaxItems <= 0 || v.Kind() != reflect.Slice || v.Len() <= conf.ListMaxItems {
+		return body, true
+	}
+	GetLedger(r).Inc(LedgerOversizedLists)
+	if conf.ListLimitAction != "paginate" || conf.CursorSecret == "" {
+		log.Printf("Response to %s %s has %d items, more than LIST_MAX_ITEMS (%d)", r.Method, requestURI(r), v.Len(), conf.ListMaxItems)
+		return body, true
+	}
+	cursor := Cursor{}
+	if _, err := GetCursor(r, &cursor); err != nil {
+		WriteValidationError(rw, err.(*ValidationError))
+		return nil, false
+	}
+	start := cursor.Offset
+	if start < 0 || start > v.Len() {
+		start = v.Len()
+	}
+	end := start + conf.ListMaxItems
+	if end < v.Len() {
+		if next, err := NextPageURL(r, Cursor{Offset: end}); err == nil {
+			rw.Header().Add("Link", "<"+next+`>; rel="next"`)
+		}
+	} else {
+		end = v.Len()
+	}
+	return v.Slice(start, end).Interface(), true
+}
@@ -0,0 +1,51 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func (suite *HyperdriveTestSuite) TestRespondListLimitPaginate() {
+	defer func(c Config) { conf = c }(conf)
+	conf.ListMaxItems = 2
+	conf.CursorSecret = "secret"
+	items := []int{1, 2, 3, 4, 5}
+	var (
+		page []int
+		next = "/items"
+		all  []int
+	)
+	for next != "" {
+		r := httptest.NewRequest("GET", next, nil)
+		r.Header.Set("Accept", "application/json")
+		rw := httptest.NewRecorder()
+		Respond(rw, r, http.StatusOK, items)
+		suite.Nil(json.Unmarshal(rw.Body.Bytes(), &page), "expects valid json")
+		suite.True(len(page) <= 2, "expects at most LIST_MAX_ITEMS items")
+		all = append(all, page...)
+		next = ""
+		if link := rw.Header().Get("Link"); link != "" {
+			next = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		}
+	}
+	suite.Equal(items, all, "expects every item to be served across the pages")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondListLimitLog() {
+	defer func(c Config) { conf = c }(conf)
+	conf.ListMaxItems = 2
+	conf.ListLimitAction = "log"
+	var page []int
+	h := instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		Respond(rw, r, http.StatusOK, []int{1, 2, 3})
+		suite.Equal(int64(1), GetLedger(r).Get(LedgerOversizedLists), "expects the oversized list to be counted")
+	}))
+	r := httptest.NewRequest("GET", "/items", nil)
+	r.Header.Set("Accept", "application/json")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	suite.Nil(json.Unmarshal(rw.Body.Bytes(), &page), "expects valid json")
+	suite.Equal([]int{1, 2, 3}, page, "expects the whole list to be served")
+}
@@ -0,0 +1,188 @@
+package hyperdrive
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The query params parsed by ListParams.
+const (
+	PageParam    = "page"
+	PerPageParam = "per_page"
+	SortParam    = "sort"
+	FilterParam  = "filter"
+)
+
+// Sortable interface is satisfied if the endpoint has implemented a method
+// called SortableFields(), returning the fields its lists can be sorted by.
+type Sortable interface {
+	SortableFields() []string
+}
+
+// Filterable interface is satisfied if the endpoint has implemented a method
+// called FilterableFields(), returning the fields its lists can be filtered
+// by.
+type Filterable interface {
+	FilterableFields() []string
+}
+
+// SortField is a field to sort a list by, in ascending order, unless Desc.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListQuery holds the pagination, sort, and filter params of a request for a
+// list, as parsed by ListParams.
+type ListQuery struct {
+	Page    int
+	PerPage int
+	Cursor  string
+	Sort    []SortField
+	Filters map[string][]string
+}
+
+// Offset returns the number of items before the page requested.
+func (q ListQuery) Offset() int {
+	return (q.Page - 1) * q.PerPage
+}
+
+// PageLinks returns the URLs, relative to the API's host, of the first, prev,
+// next and last pages of the list requested, by rel: the path and query of
+// the request, with the page and per_page query params parsed by ListParams
+// set, given the total number of items in the list. prev and next are only
+// returned if there is such a page.
+func PageLinks(r *http.Request, q ListQuery, total int) map[string]string {
+	last := totalPages(q, total)
+	links := map[string]string{
+		"first": pageURL(r, 1, q.PerPage),
+		"last":  pageURL(r, last, q.PerPage),
+	}
+	if q.Page > 1 {
+		links["prev"] = pageURL(r, q.Page-1, q.PerPage)
+	}
+	if q.Page < last {
+		links["next"] = pageURL(r, q.Page+1, q.PerPage)
+	}
+	return links
+}
+
+// pageURL returns the path and query of the request, with its page and
+// per_page query params set.
+func pageURL(r *http.Request, page int, perPage int) string {
+	u := *r.URL
+	query := u.Query()
+	query.Set(PageParam, strconv.Itoa(page))
+	query.Set(PerPageParam, strconv.Itoa(perPage))
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
+// ListParams parses the query params of a request for a list:
+//
+//   - page (default: 1) and per_page (default: LIST_DEFAULT_PER_PAGE, 25, at
+//     most LIST_MAX_PER_PAGE, 100), or cursor, which is returned as given, to
+//     be decoded with GetCursor.
+//   - sort, a list of fields, each of them descending if prefixed with -,
+//     e.g. sort=-created_at,name. The fields must be in the endpoint's
+//     SortableFields (see Sortable).
+//   - filter[field], a list of values for each field, e.g.
+//     filter[status]=open,closed. The fields must be in the endpoint's
+//     FilterableFields (see Filterable).
+//
+// Lists are split as ParamList. If any param is invalid, it returns a
+// *ValidationError, with a FieldError for each of them, which can be written
+// with WriteError.
+func ListParams(e Endpointer, r *http.Request) (ListQuery, error) {
+	var (
+		q       = ListQuery{Page: 1, PerPage: conf.ListDefaultPerPage, Filters: map[string][]string{}}
+		query   = r.URL.Query()
+		verr    = &ValidationError{}
+		sorts   []string
+		filters []string
+	)
+	if s, ok := e.(Sortable); ok {
+		sorts = s.SortableFields()
+	}
+	if f, ok := e.(Filterable); ok {
+		filters = f.FilterableFields()
+	}
+	q.Cursor = query.Get(CursorParam)
+	if q.Cursor != "" && query.Get(PageParam) != "" {
+		verr.AddParamError(InQuery, PageParam, ValidationInvalid, "page can not be given with a cursor")
+	}
+	q.Page = listParamInt(verr, query, PageParam, q.Page, math.MaxInt32)
+	q.PerPage = listParamInt(verr, query, PerPageParam, q.PerPage, conf.ListMaxPerPage)
+	for _, field := range splitParamValues(query[SortParam]) {
+		sf := SortField{Field: strings.TrimPrefix(field, "-"), Desc: strings.HasPrefix(field, "-")}
+		if !contains(sorts, sf.Field) {
+			verr.AddParamError(InQuery, SortParam, ValidationInvalid, unsupportedListField("sort", sf.Field, sorts))
+			continue
+		}
+		q.Sort = append(q.Sort, sf)
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		field, ok := filterField(k)
+		if !ok {
+			continue
+		}
+		if !contains(filters, field) {
+			verr.AddParamError(InQuery, k, ValidationInvalid, unsupportedListField("filter", field, filters))
+			continue
+		}
+		q.Filters[field] = splitParamValues(query[k])
+	}
+	if !verr.Empty() {
+		return q, verr
+	}
+	return q, nil
+}
+
+// listParamInt returns the value of the named query param, which must be an
+// integer between 1 and max, or def if it is missing.
+func listParamInt(verr *ValidationError, query url.Values, name string, def int, max int) int {
+	s := ""
+	if values := query[name]; len(values) > 0 {
+		s = values[len(values)-1]
+	}
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		verr.AddTypeError(InQuery, name, "an integer", s)
+		return def
+	}
+	if n < 1 || n > max {
+		verr.AddParamError(InQuery, name, ValidationInvalid, fmt.Sprintf("%s must be between 1 and %d", name, max))
+		return def
+	}
+	return n
+}
+
+// filterField returns the field of a filter[field] query param.
+func filterField(key string) (string, bool) {
+	if !strings.HasPrefix(key, FilterParam+"[") || !strings.HasSuffix(key, "]") {
+		return "", false
+	}
+	return key[len(FilterParam)+1 : len(key)-1], true
+}
+
+// unsupportedListField returns the message of a FieldError for a field which
+// can not be sorted or filtered by.
+func unsupportedListField(action string, field string, allowed []string) string {
+	if len(allowed) == 0 {
+		return fmt.Sprintf("Can not %s by %s", action, field)
+	}
+	return fmt.Sprintf("Can not %s by %s, must be one of: %s", action, field, strings.Join(allowed, ", "))
+}
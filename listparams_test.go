@@ -0,0 +1,47 @@
+package hyperdrive
+
+import (
+	"net/http/httptest"
+)
+
+type ListEndpoint struct {
+	Endpoint
+}
+
+func (e *ListEndpoint) SortableFields() []string {
+	return []string{"created_at", "name"}
+}
+
+func (e *ListEndpoint) FilterableFields() []string {
+	return []string{"status", "owner"}
+}
+
+func (suite *HyperdriveTestSuite) TestListParams() {
+	e := &ListEndpoint{Endpoint: *NewEndpoint("Widgets", "Lists widgets", "/widgets", "1")}
+	q, err := ListParams(e, httptest.NewRequest("GET", "/widgets?page=3&per_page=10&sort=-created_at,name&filter[status]=open,closed&filter[owner]=me", nil))
+	suite.Nil(err, "expects no error")
+	suite.Equal(3, q.Page, "expects the page")
+	suite.Equal(10, q.PerPage, "expects the page size")
+	suite.Equal(20, q.Offset(), "expects the offset of the page")
+	suite.Equal([]SortField{{Field: "created_at", Desc: true}, {Field: "name"}}, q.Sort, "expects the sort fields, in order")
+	suite.Equal(map[string][]string{"status": {"open", "closed"}, "owner": {"me"}}, q.Filters, "expects the filters")
+}
+
+func (suite *HyperdriveTestSuite) TestListParamsDefaults() {
+	q, err := ListParams(suite.TestEndpoint, httptest.NewRequest("GET", "/widgets?cursor=abc", nil))
+	suite.Nil(err, "expects no error")
+	suite.Equal(ListQuery{Page: 1, PerPage: 25, Cursor: "abc", Filters: map[string][]string{}}, q, "expects the defaults and the cursor")
+}
+
+func (suite *HyperdriveTestSuite) TestListParamsInvalid() {
+	e := &ListEndpoint{Endpoint: *NewEndpoint("Widgets", "Lists widgets", "/widgets", "1")}
+	_, err := ListParams(e, httptest.NewRequest("GET", "/widgets?page=two&per_page=1000&sort=price&filter[secret]=x", nil))
+	suite.Equal([]FieldError{
+		{In: InQuery, Parameter: "page", Code: ValidationInvalidType, Message: "page must be an integer", Expected: "an integer", Value: "two"},
+		{In: InQuery, Parameter: "per_page", Code: ValidationInvalid, Message: "per_page must be between 1 and 100"},
+		{In: InQuery, Parameter: "sort", Code: ValidationInvalid, Message: "Can not sort by price, must be one of: created_at, name"},
+		{In: InQuery, Parameter: "filter[secret]", Code: ValidationInvalid, Message: "Can not filter by secret, must be one of: status, owner"},
+	}, err.(*ValidationError).Errors, "expects every invalid param to be reported")
+	_, err = ListParams(suite.TestEndpoint, httptest.NewRequest("GET", "/widgets?sort=name", nil))
+	suite.Error(err, "expects an error when the endpoint is not Sortable")
+}
@@ -0,0 +1,68 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// maintenance holds the runtime maintenance mode state, shared by every copy
+// of an API.
+type maintenance struct {
+	enabled int32
+}
+
+func (m *maintenance) set(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&m.enabled, v)
+}
+
+func (m *maintenance) get() bool {
+	return atomic.LoadInt32(&m.enabled) == 1
+}
+
+// EnableMaintenance puts the API into maintenance mode at runtime, causing
+// MaintenanceMiddleware to respond to requests with a 503 Service Unavailable.
+func (api *API) EnableMaintenance() {
+	api.maintenance.set(true)
+}
+
+// DisableMaintenance takes the API out of maintenance mode.
+func (api *API) DisableMaintenance() {
+	api.maintenance.set(false)
+}
+
+// InMaintenance returns true if the API is in maintenance mode.
+func (api *API) InMaintenance() bool {
+	return api.maintenance.get()
+}
+
+// MaintenanceMiddleware responds to every request with a 503 Service
+// Unavailable, while the API is in maintenance mode, except for requests to
+// the paths in the allowlist (e.g. health checks). The middleware can be
+// configured via the following environment variables:
+//
+// - MAINTENANCE_MODE (bool)
+// - MAINTENANCE_RETRY_AFTER (int, seconds)
+// - MAINTENANCE_BODY (string, json)
+// - MAINTENANCE_ALLOW (string, comma separated paths)
+//
+// Maintenance mode can also be toggled at runtime with EnableMaintenance and
+// DisableMaintenance.
+func (api *API) MaintenanceMiddleware(h http.Handler) http.Handler {
+	allowed := strings.Split(conf.MaintenanceAllow, ",")
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !api.maintenance.get() || contains(allowed, r.URL.Path) {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Retry-After", strconv.Itoa(conf.MaintenanceRetryAfter))
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		rw.Write([]byte(conf.MaintenanceBody))
+	})
+}
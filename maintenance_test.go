@@ -0,0 +1,40 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestMaintenanceMiddleware() {
+	suite.Implements((*http.Handler)(nil), suite.TestAPI.MaintenanceMiddleware(suite.TestHandler), "return an implementation of http.Handler")
+}
+
+func (suite *HyperdriveTestSuite) TestInMaintenanceDefault() {
+	suite.False(suite.TestAPI.InMaintenance(), "expects maintenance mode to be disabled by default")
+}
+
+func (suite *HyperdriveTestSuite) TestEnableMaintenance() {
+	suite.TestAPI.EnableMaintenance()
+	suite.True(suite.TestAPI.InMaintenance(), "expects maintenance mode to be enabled")
+	suite.TestAPI.DisableMaintenance()
+	suite.False(suite.TestAPI.InMaintenance(), "expects maintenance mode to be disabled")
+}
+
+func (suite *HyperdriveTestSuite) TestMaintenanceMiddlewareEnabled() {
+	rw := httptest.NewRecorder()
+	suite.TestAPI.EnableMaintenance()
+	suite.TestAPI.MaintenanceMiddleware(suite.TestHandler).ServeHTTP(rw, suite.TestGetRequest)
+	suite.Equal(http.StatusServiceUnavailable, rw.Code, "expects a 503 error")
+	suite.Equal("300", rw.Header().Get("Retry-After"), "expects the Retry-After header to be set")
+	suite.Equal(`{"error":"Service Unavailable","status":503}`, rw.Body.String(), "expects the maintenance body")
+}
+
+func (suite *HyperdriveTestSuite) TestMaintenanceMiddlewareAllowed() {
+	var called bool
+	suite.TestAPI.EnableMaintenance()
+	h := suite.TestAPI.MaintenanceMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+	suite.True(called, "expects allowed paths to be served")
+}
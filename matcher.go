@@ -0,0 +1,55 @@
+package hyperdrive
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Matcher restricts the requests an endpoint will be routed, in addition to
+// its path and media types. Matchers are passed to AddEndpoint, e.g.
+//
+//	api.AddEndpoint(v2, hyperdrive.MatchHeader("X-Api-Version", "2"))
+//	api.AddEndpoint(v1)
+//
+// Endpoints are tried in the order they are added, so endpoints sharing a path
+// should be added from the most to the least specific.
+type Matcher func(*mux.Route) *mux.Route
+
+// MatchHeader returns a Matcher for requests with the given header set to the
+// given value.
+func MatchHeader(key string, value string) Matcher {
+	return func(route *mux.Route) *mux.Route {
+		return route.Headers(key, value)
+	}
+}
+
+// MatchHeaderRegexp returns a Matcher for requests with the given header
+// matching the given regular expression.
+func MatchHeaderRegexp(key string, pattern string) Matcher {
+	return func(route *mux.Route) *mux.Route {
+		return route.HeadersRegexp(key, pattern)
+	}
+}
+
+// MatchQuery returns a Matcher for requests with the given query param set to
+// the given value. The value may contain a pattern, as described here:
+// http://www.gorillatoolkit.org/pkg/mux, e.g. "{id:[0-9]+}".
+func MatchQuery(key string, value string) Matcher {
+	return func(route *mux.Route) *mux.Route {
+		return route.Queries(key, value)
+	}
+}
+
+// MatchContentType returns a Matcher for requests with a body of one of the
+// given media types, e.g. "application/json". Params of the Content-Type
+// header (e.g. charset) are ignored.
+func MatchContentType(types ...string) Matcher {
+	return func(route *mux.Route) *mux.Route {
+		return route.MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			return err == nil && contains(types, mediaType)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gorilla/mux"
+)
+
+func (suite *HyperdriveTestSuite) matches(m Matcher, r *http.Request) bool {
+	route := m(mux.NewRouter().Handle("/test", suite.TestHandler))
+	return route.Match(r, &mux.RouteMatch{})
+}
+
+func (suite *HyperdriveTestSuite) TestMatchHeader() {
+	r := httptest.NewRequest("GET", "/test", nil)
+	suite.False(suite.matches(MatchHeader("X-Api-Version", "2"), r), "expects requests without the header not to match")
+	r.Header.Set("X-Api-Version", "2")
+	suite.True(suite.matches(MatchHeader("X-Api-Version", "2"), r), "expects requests with the header to match")
+}
+
+func (suite *HyperdriveTestSuite) TestMatchHeaderRegexp() {
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("X-Api-Version", "2.1")
+	suite.True(suite.matches(MatchHeaderRegexp("X-Api-Version", "^2\\."), r), "expects requests with a matching header to match")
+}
+
+func (suite *HyperdriveTestSuite) TestMatchQuery() {
+	suite.True(suite.matches(MatchQuery("format", "csv"), httptest.NewRequest("GET", "/test?format=csv", nil)), "expects requests with the query param to match")
+	suite.False(suite.matches(MatchQuery("format", "csv"), httptest.NewRequest("GET", "/test?format=json", nil)), "expects requests with another value not to match")
+}
+
+func (suite *HyperdriveTestSuite) TestMatchContentType() {
+	r := httptest.NewRequest("POST", "/test", nil)
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	suite.True(suite.matches(MatchContentType("application/json"), r), "expects requests with the content type to match")
+	suite.False(suite.matches(MatchContentType("application/xml"), r), "expects requests with another content type not to match")
+}
+
+func (suite *HyperdriveTestSuite) TestAddEndpointWithMatchers() {
+	suite.TestAPI.AddEndpoint(suite.TestEndpoint, MatchHeader("X-Api-Version", "2"))
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Accept", GetContentTypeJSON(suite.TestAPI, suite.TestEndpoint))
+	rw := httptest.NewRecorder()
+	suite.TestAPI.Router.ServeHTTP(rw, r)
+	suite.Equal(http.StatusNotFound, rw.Code, "expects requests without the header not to be routed")
+	r.Header.Set("X-Api-Version", "2")
+	rw = httptest.NewRecorder()
+	suite.TestAPI.Router.ServeHTTP(rw, r)
+	suite.NotEqual(http.StatusNotFound, rw.Code, "expects requests with the header to be routed")
+}
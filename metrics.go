@@ -0,0 +1,293 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsNamespace = "hyperdrive"
+	metricsSubsystem = "http"
+)
+
+// The route labels used in place of the route of a request, when it can not
+// be labeled by its route template.
+const (
+	// RouteLabelUnknown labels requests which did not match a route.
+	RouteLabelUnknown = "unknown"
+	// RouteLabelOther labels routes excluded by METRICS_ROUTE_ALLOW or
+	// METRICS_ROUTE_DENY.
+	RouteLabelOther = "other"
+	// RouteLabelOverflow labels routes seen after METRICS_MAX_ROUTES
+	// distinct routes have been labeled.
+	RouteLabelOverflow = "overflow"
+)
+
+var (
+	// routeVarPattern matches the pattern of a route variable, e.g. the
+	// ":[0-9]+" of "{id:[0-9]+}".
+	routeVarPattern = regexp.MustCompile(`\{([^{}:]+):(?:[^{}]|\{[^{}]*\})*\}`)
+	// idSegmentPattern matches path segments which are likely to be IDs:
+	// numbers, UUIDs, and long hex strings.
+	idSegmentPattern = regexp.MustCompile(`^(?:[0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+)
+
+// standardMethods are labeled as is; any other method is labeled OTHER.
+var standardMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE"}
+
+// metrics holds the prometheus collectors used by MetricsMiddleware. Each API
+// has its own registry, so that multiple APIs can exist in the same process.
+type metrics struct {
+	registry    *prometheus.Registry
+	requests    *prometheus.CounterVec
+	inFlight    *prometheus.GaugeVec
+	duration    *prometheus.HistogramVec
+	size        *prometheus.HistogramVec
+	payloadSize *prometheus.HistogramVec
+	resources   *prometheus.HistogramVec
+	rollouts    *prometheus.CounterVec
+	mirrors     *prometheus.CounterVec
+	faults      *prometheus.CounterVec
+	routes      *routeLabels
+
+	messages        *prometheus.CounterVec
+	messageDuration *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	sizeBuckets := prometheus.ExponentialBuckets(100, 10, 6)
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		routes:   newRouteLabels(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "requests_total",
+			Help:      "Total number of HTTP requests, by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "requests_in_flight",
+			Help:      "Number of HTTP requests currently being served, by route and method.",
+		}, []string{"route", "method"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency in seconds, by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "response_size_bytes",
+			Help:      "HTTP response size in bytes, as sent to the client, by route, method and status.",
+			Buckets:   sizeBuckets,
+		}, []string{"route", "method", "status"}),
+		payloadSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "response_payload_size_bytes",
+			Help:      "HTTP response size in bytes, before compression, by route, method and status.",
+			Buckets:   sizeBuckets,
+		}, []string{"route", "method", "status"}),
+		resources: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "request_resources",
+			Help:      "Resources used per HTTP request, as counted by its Ledger, by route, method and resource.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		}, []string{"route", "method", "resource"}),
+		rollouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "rollout_requests_total",
+			Help:      "Total number of HTTP requests served by RolloutMiddleware, by rollout, arm and status.",
+		}, []string{"rollout", "arm", "status"}),
+		mirrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "mirrored_requests_total",
+			Help:      "Total number of HTTP requests mirrored by MirrorMiddleware, by result (ok, error or dropped).",
+		}, []string{"result"}),
+		faults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "injected_faults_total",
+			Help:      "Total number of faults injected by FaultInjectionMiddleware, by kind (delay or error).",
+		}, []string{"kind"}),
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "consumer",
+			Name:      "messages_total",
+			Help:      "Total number of messages handled by consumers, by queue and outcome.",
+		}, []string{"queue", "outcome"}),
+		messageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "consumer",
+			Name:      "message_duration_seconds",
+			Help:      "Time taken to handle messages in seconds, by queue.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"queue"}),
+	}
+	m.registry.MustRegister(prometheus.NewGoCollector(), m.requests, m.inFlight, m.duration, m.size, m.payloadSize, m.resources, m.rollouts, m.mirrors, m.faults, m.messages, m.messageDuration)
+	return m
+}
+
+// routeLabel returns the path template of the mux route matched by the given
+// request, e.g. /users/{id}, to keep the number of label values bounded.
+// The patterns of route variables are removed, e.g. /users/{id:[0-9]+} is
+// labeled /users/{id}.
+func routeLabel(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return routeVarPattern.ReplaceAllString(tpl, "{$1}")
+		}
+		if name := route.GetName(); name != "" {
+			return name
+		}
+	}
+	return RouteLabelUnknown
+}
+
+// bucketPath returns the path of the request, with the segments which are
+// likely to be IDs replaced by {id}, e.g. /users/42 becomes /users/{id}.
+func bucketPath(r *http.Request) string {
+	segments := strings.Split(r.URL.Path, "/")
+	for i, s := range segments {
+		if idSegmentPattern.MatchString(s) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// matchRoutePatterns returns true if the route matches one of the patterns:
+// an exact route, or a prefix ending in *, e.g. /admin/*.
+func matchRoutePatterns(patterns []string, route string) bool {
+	for _, p := range patterns {
+		if p == route || (strings.HasSuffix(p, "*") && strings.HasPrefix(route, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeLabels bounds the number of route label values recorded by
+// MetricsMiddleware.
+type routeLabels struct {
+	sync.Mutex
+	seen  map[string]bool
+	allow []string
+	deny  []string
+	max   int
+}
+
+func newRouteLabels() *routeLabels {
+	return &routeLabels{
+		seen:  map[string]bool{},
+		allow: splitList(conf.MetricsRouteAllow),
+		deny:  splitList(conf.MetricsRouteDeny),
+		max:   conf.MetricsMaxRoutes,
+	}
+}
+
+// label returns the route label for the request, after applying
+// METRICS_BUCKET_PATHS, METRICS_ROUTE_ALLOW, METRICS_ROUTE_DENY, and
+// METRICS_MAX_ROUTES.
+func (rl *routeLabels) label(r *http.Request) string {
+	route := routeLabel(r)
+	if route == RouteLabelUnknown {
+		if !conf.MetricsBucketPaths {
+			return route
+		}
+		route = bucketPath(r)
+	}
+	if (len(rl.allow) > 0 && !matchRoutePatterns(rl.allow, route)) || matchRoutePatterns(rl.deny, route) {
+		return RouteLabelOther
+	}
+	rl.Lock()
+	defer rl.Unlock()
+	if rl.seen[route] {
+		return route
+	}
+	if rl.max > 0 && len(rl.seen) >= rl.max {
+		return RouteLabelOverflow
+	}
+	rl.seen[route] = true
+	return route
+}
+
+// methodLabel returns the method of the request, or OTHER for non-standard
+// methods, which clients can choose freely.
+func methodLabel(r *http.Request) string {
+	if contains(standardMethods, r.Method) {
+		return r.Method
+	}
+	return "OTHER"
+}
+
+// MetricsMiddleware wraps the given http.Handler and records prometheus
+// metrics for each request: a count of requests, a gauge of in-flight
+// requests, and histograms of latency, response sizes, and the resources
+// counted by each request's Ledger. Metrics are labeled by the route template
+// (e.g. /users/{id}), method and status, and are served by the http.Handler
+// returned from MetricsHandler.
+//
+// The number of label values is bounded, so metrics stay usable at scale:
+// non-standard methods are labeled OTHER, and requests which did not match a
+// route are labeled unknown, unless METRICS_BUCKET_PATHS is true, in which
+// case they are labeled by their path, with IDs (numbers, UUIDs, and long hex
+// strings) replaced by {id}. Only routes matching METRICS_ROUTE_ALLOW (when
+// set), and not METRICS_ROUTE_DENY, are labeled as is, others are labeled
+// other. Both are comma separated lists of routes, or prefixes ending in *,
+// e.g. "/admin/*". Once METRICS_MAX_ROUTES (default: 500, or 0 for no limit)
+// distinct routes have been labeled, any new route is labeled overflow.
+func (api *API) MetricsMiddleware(h http.Handler) http.Handler {
+	m := api.metrics
+	return instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		route, method := m.routes.label(r), methodLabel(r)
+		m.inFlight.WithLabelValues(route, method).Inc()
+		GetResponseStats(r).OnComplete(func(r *http.Request, s *ResponseStats) {
+			status := strconv.Itoa(s.Status)
+			m.inFlight.WithLabelValues(route, method).Dec()
+			m.requests.WithLabelValues(route, method, status).Inc()
+			m.duration.WithLabelValues(route, method, status).Observe(s.Duration().Seconds())
+			m.size.WithLabelValues(route, method, status).Observe(float64(s.WireBytes))
+			m.payloadSize.WithLabelValues(route, method, status).Observe(float64(s.PayloadBytes))
+			for resource, n := range s.Ledger.Counts() {
+				m.resources.WithLabelValues(route, method, resource).Observe(float64(n))
+			}
+		})
+		h.ServeHTTP(rw, r)
+	}))
+}
+
+// MetricsHandler returns an http.Handler which serves the metrics recorded by
+// MetricsMiddleware, in the prometheus exposition format. Mount it on the
+// API's Router, e.g.:
+//
+//	api.Router.Handle("/metrics", api.MetricsHandler())
+func (api *API) MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(api.metrics.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterMetrics registers additional prometheus collectors (e.g. for a
+// database connection pool) with the API, so they are served by
+// MetricsHandler alongside the metrics recorded by MetricsMiddleware.
+func (api *API) RegisterMetrics(collectors ...prometheus.Collector) error {
+	for _, c := range collectors {
+		if err := api.metrics.registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func (suite *HyperdriveTestSuite) TestMetricsMiddleware() {
+	suite.Implements((*http.Handler)(nil), suite.TestAPI.MetricsMiddleware(suite.TestHandler), "return an implementation of http.Handler")
+}
+
+func (suite *HyperdriveTestSuite) TestMetricsHandler() {
+	suite.Implements((*http.Handler)(nil), suite.TestAPI.MetricsHandler(), "return an implementation of http.Handler")
+}
+
+func (suite *HyperdriveTestSuite) TestMetricsHandlerRequest() {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/json")
+	suite.TestAPI.Router.ServeHTTP(httptest.NewRecorder(), r)
+	rw := httptest.NewRecorder()
+	suite.TestAPI.MetricsHandler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	suite.Contains(rw.Body.String(), `hyperdrive_http_requests_total{method="GET",route="/",status="200"} 1`, "expects requests to be counted by route, method and status")
+}
+
+func (suite *HyperdriveTestSuite) TestRouteLabelUnknown() {
+	suite.Equal("unknown", routeLabel(suite.TestGetRequest), "expects unknown when no route was matched")
+}
+
+func (suite *HyperdriveTestSuite) TestRouteLabelTemplate() {
+	suite.TestAPI.Router.Handle("/users/{id:[0-9]+}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("/users/{id}", routeLabel(r), "expects the patterns of route variables to be removed")
+	}))
+	suite.TestAPI.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42", nil))
+}
+
+func (suite *HyperdriveTestSuite) TestRouteLabelsBucketPaths() {
+	defer func(c Config) { conf = c }(conf)
+	conf.MetricsBucketPaths = true
+	r := httptest.NewRequest("GET", "/users/42/keys/0f8fad5b-d9cb-469f-a165-70867728950e", nil)
+	suite.Equal("/users/{id}/keys/{id}", newRouteLabels().label(r), "expects IDs in unmatched paths to be bucketed")
+}
+
+func (suite *HyperdriveTestSuite) TestRouteLabelsAllowDeny() {
+	defer func(c Config) { conf = c }(conf)
+	conf.MetricsBucketPaths = true
+	conf.MetricsRouteAllow = "/users/*,/admin/*"
+	conf.MetricsRouteDeny = "/admin/*"
+	rl := newRouteLabels()
+	suite.Equal("/users/{id}", rl.label(httptest.NewRequest("GET", "/users/42", nil)), "expects allowed routes to be labeled")
+	suite.Equal(RouteLabelOther, rl.label(httptest.NewRequest("GET", "/orders/42", nil)), "expects routes not allowed to be labeled other")
+	suite.Equal(RouteLabelOther, rl.label(httptest.NewRequest("GET", "/admin/config", nil)), "expects denied routes to be labeled other")
+}
+
+func (suite *HyperdriveTestSuite) TestRouteLabelsOverflow() {
+	defer func(c Config) { conf = c }(conf)
+	conf.MetricsBucketPaths = true
+	conf.MetricsMaxRoutes = 2
+	rl := newRouteLabels()
+	suite.Equal("/a", rl.label(httptest.NewRequest("GET", "/a", nil)), "expects the first route to be labeled")
+	suite.Equal("/b", rl.label(httptest.NewRequest("GET", "/b", nil)), "expects the second route to be labeled")
+	suite.Equal(RouteLabelOverflow, rl.label(httptest.NewRequest("GET", "/c", nil)), "expects routes over the cap to be labeled overflow")
+	suite.Equal("/a", rl.label(httptest.NewRequest("GET", "/a", nil)), "expects routes already labeled to keep their label")
+}
+
+func (suite *HyperdriveTestSuite) TestMethodLabel() {
+	suite.Equal("GET", methodLabel(httptest.NewRequest("GET", "/", nil)), "expects standard methods to be labeled as is")
+	suite.Equal("OTHER", methodLabel(httptest.NewRequest("PURGE", "/", nil)), "expects non-standard methods to be labeled OTHER")
+}
+
+func (suite *HyperdriveTestSuite) TestMetricsMiddlewareResources() {
+	h := suite.TestAPI.MetricsMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		GetLedger(r).Add(LedgerDBQueries, 3)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	rw := httptest.NewRecorder()
+	suite.TestAPI.MetricsHandler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	suite.Contains(rw.Body.String(), `hyperdrive_http_request_resources_sum{method="GET",resource="db_queries",route="unknown"} 3`, "expects the resources counted by the Ledger to be recorded")
+}
+
+func (suite *HyperdriveTestSuite) TestRegisterMetrics() {
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "widgets_total", Help: "Total number of widgets."})
+	suite.Nil(suite.TestAPI.RegisterMetrics(c), "expects no error")
+	suite.Error(suite.TestAPI.RegisterMetrics(c), "expects an error if the collector is already registered")
+	c.Inc()
+	rw := httptest.NewRecorder()
+	suite.TestAPI.MetricsHandler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	suite.Contains(rw.Body.String(), "widgets_total 1", "expects registered collectors to be served")
+}
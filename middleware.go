@@ -1,32 +1,131 @@
 package hyperdrive
 
 import (
+	"log"
 	"net/http"
-	"os"
-	"strings"
 
 	"github.com/gorilla/handlers"
 )
 
-// DefaultMiddlewareChain wraps the given http.Handler in the following chain
-// of middleware: CorsMiddleware, FrameOptionsMiddleware,
+// Middleware is a function that wraps an http.Handler and returns a new
+// http.Handler. All of the middleware provided by hyperdrive satisfy this type.
+type Middleware func(http.Handler) http.Handler
+
+// MiddlewareChain is an ordered list of Middleware. The first Middleware in
+// the chain is the outermost, and is the first to see each request and the
+// last to see each response.
+type MiddlewareChain []Middleware
+
+// NewMiddlewareChain creates an instance of MiddlewareChain, in the order given.
+func NewMiddlewareChain(m ...Middleware) MiddlewareChain {
+	return append(MiddlewareChain{}, m...)
+}
+
+// Append returns a new MiddlewareChain with the given Middleware added to the
+// end (innermost) of the chain.
+func (c MiddlewareChain) Append(m ...Middleware) MiddlewareChain {
+	return append(NewMiddlewareChain(c...), m...)
+}
+
+// Prepend returns a new MiddlewareChain with the given Middleware added to the
+// beginning (outermost) of the chain.
+func (c MiddlewareChain) Prepend(m ...Middleware) MiddlewareChain {
+	return append(NewMiddlewareChain(m...), c...)
+}
+
+// Then wraps the given http.Handler in every Middleware in the chain, and
+// returns the resulting http.Handler. The response is instrumented on both
+// sides of the chain, so ResponseStats reports the bytes written by the
+// handler as well as the bytes written to the client.
+func (c MiddlewareChain) Then(h http.Handler) http.Handler {
+	h = trackPayload(h)
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+	return instrument(h)
+}
+
+// DefaultMiddleware returns the MiddlewareChain hyperdrive uses unless
+// another is given to SetMiddlewareChain: MetricsMiddleware, CorsMiddleware,
+// MaintenanceMiddleware, FrameOptionsMiddleware,
 // ContentTypeOptionsMiddleware, CompressionMiddleware, LoggingMiddleware,
-// RecoveryMiddleware.
+// RecoveryMiddleware. It is a useful starting point for building your own
+// chain, when you only need to reorder or add to the defaults.
+func (api *API) DefaultMiddleware() MiddlewareChain {
+	return NewMiddlewareChain(
+		api.MetricsMiddleware,
+		api.CorsMiddleware,
+		api.MaintenanceMiddleware,
+		api.FrameOptionsMiddleware,
+		api.ContentTypeOptionsMiddleware,
+		api.CompressionMiddleware,
+		api.LoggingMiddleware,
+		api.RecoveryMiddleware,
+	)
+}
+
+// SetMiddlewareChain replaces the MiddlewareChain used to wrap the Discovery
+// URL and every endpoint added afterwards. Call it before AddEndpoint, e.g. to
+// make RecoveryMiddleware the outermost middleware, and log before compressing:
+//
+//	api.SetMiddlewareChain(hyperdrive.NewMiddlewareChain(
+//		api.RecoveryMiddleware,
+//		api.CorsMiddleware,
+//		api.LoggingMiddleware,
+//		api.CompressionMiddleware,
+//	))
+func (api *API) SetMiddlewareChain(c MiddlewareChain) {
+	api.middleware = c
+	api.rootRoute.Handler(api.DefaultMiddlewareChain(api.Root))
+}
+
+// DefaultMiddlewareChain wraps the given http.Handler in the API's
+// MiddlewareChain. Unless SetMiddlewareChain has been called, this is the
+// chain declared by the MIDDLEWARE_CHAIN environment variable (see
+// ParseMiddlewareChain), or the chain returned by DefaultMiddleware.
 func (api *API) DefaultMiddlewareChain(h http.Handler) http.Handler {
-	return api.CorsMiddleware(api.FrameOptionsMiddleware(api.ContentTypeOptionsMiddleware(api.CompressionMiddleware(api.LoggingMiddleware(api.RecoveryMiddleware(h))))))
+	if api.middleware != nil {
+		return api.middleware.Then(h)
+	}
+	if conf.MiddlewareChain != "" {
+		c, err := api.ParseMiddlewareChain(conf.MiddlewareChain)
+		if err != nil {
+			log.Fatalf("Middleware chain could not be initialized: %v", err)
+		}
+		return c.Then(h)
+	}
+	return api.DefaultMiddleware().Then(h)
 }
 
 // LoggingMiddleware wraps the given http.Handler and outputs requests in Apache-style
-// Combined Log format. All logging is done to STDOUT only.
+// Combined Log format, or as json when the LOG_FORMAT environment variable is set
+// to json. All logging is done to STDOUT only.
+//
+// Requests are logged once the response is complete, so the size reported is
+// the number of bytes sent to the client, even when CompressionMiddleware is
+// inside of LoggingMiddleware. The json format also includes payload_bytes,
+// the size of the response before compression. Server errors (5xx) are also
+// kept for the admin dashboard (see RecentErrors). Custom fields can be added
+// to every entry with AddAccessLogEnricher.
 func (api *API) LoggingMiddleware(h http.Handler) http.Handler {
-	return handlers.CombinedLoggingHandler(os.Stdout, h)
+	return instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		GetResponseStats(r).OnComplete(func(r *http.Request, s *ResponseStats) {
+			entry := NewAccessLogEntry(r, s)
+			entry.Fields = api.accessLogEnrichers.fields(r, s)
+			writeAccessLogEntry(accessLogOutput, entry, s.Start)
+			api.recentErrors.record(r, s)
+		})
+		h.ServeHTTP(rw, r)
+	}))
 }
 
 // RecoveryMiddleware wraps the given http.Handler and recovers from panics. It wil log
-// the stacktrace if HYPERDRIVE_ENVIRONMENT env var is not set to "production".
+// the stacktrace if HYPERDRIVE_ENVIRONMENT env var is not set to "production". Panics
+// are also forwarded, with the request and stacktrace, to every PanicReporter
+// registered with AddPanicReporter. The response is a 500 Internal Server
+// Error problem (see RespondProblem).
 func (api *API) RecoveryMiddleware(h http.Handler) http.Handler {
-	opt := handlers.PrintRecoveryStack(conf.Env != "production")
-	return handlers.RecoveryHandler(opt)(h)
+	return recoverHandler(h, api.panicReporters, conf.Env != "production")
 }
 
 // CompressionMiddleware wraps the given http.Handler and returns a gzipped response if
@@ -48,8 +147,38 @@ func (api *API) RecoveryMiddleware(h http.Handler) http.Handler {
 //
 // More info can be found in the docs for the compress/flate package:
 // https://golang.org/pkg/compress/flate/
+//
+// Requests with a Range header are not compressed, since the byte offsets in
+// a partial response refer to the uncompressed representation, and neither
+// are requests which accept Server-Sent Events (see EventStream), since
+// compressors hold back what is written until they have enough of it, or
+// WebSocket upgrade requests (see WebSocketHandler).
+//
+// Once a CompressionDictionary is available (see SetCompressionDictionary,
+// and COMPRESSION_DICTIONARY_SAMPLE_RATE to train one from sampled
+// responses), clients which send its hash in the Available-Dictionary header,
+// and accept the dcz encoding, receive responses compressed with zstd and the
+// dictionary instead. When COMPRESSION_DICTIONARY_PATH is set, the dictionary
+// is served there, and advertised on every response with a Link header, so
+// capable clients (e.g. Chrome) download it and use it for later requests.
 func (api *API) CompressionMiddleware(h http.Handler) http.Handler {
-	return handlers.CompressHandlerLevel(h, conf.GzipLevel)
+	sampled := api.dictionarySampler(h)
+	compressed := handlers.CompressHandlerLevel(sampled, conf.GzipLevel)
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" || acceptsEventStream(r) || isWebSocketUpgrade(r) {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		d := api.CompressionDictionary()
+		if d != nil && conf.CompressionDictionaryPath != "" {
+			rw.Header().Add("Link", "<"+conf.CompressionDictionaryPath+`>; rel="compression-dictionary"`)
+		}
+		if acceptsDictionary(r, d) {
+			dictionaryCompressed(sampled, d).ServeHTTP(rw, r)
+			return
+		}
+		compressed.ServeHTTP(rw, r)
+	})
 }
 
 // MethodOverrideMiddleware allows clients who can not perform native PUT, PATCH,
@@ -59,26 +188,6 @@ func (api *API) MethodOverrideMiddleware(h http.Handler) http.Handler {
 	return handlers.HTTPMethodOverrideHandler(h)
 }
 
-// CorsMiddleware allows cross-origin HTTP requests to your API. The middleware is enabled
-// by default, and can be configured via the following environment variables:
-//
-// - CORS_ENABLED (bool)
-// - CORS_ORIGINS (string)
-// - CORS_HEADERS (string)
-// - CORS_CREDENTIALS (bool)
-func (api *API) CorsMiddleware(h http.Handler) http.Handler {
-	if conf.CorsEnabled != true {
-		return h
-	}
-	defaultHeaders := []string{"Content-Type", "X-Content-Type-Options"}
-	headers := handlers.AllowedHeaders(append(defaultHeaders, strings.Split(conf.CorsHeaders, ",")...))
-	origins := handlers.AllowedOrigins(strings.Split(conf.CorsOrigins, ","))
-	if conf.CorsCredentials == true {
-		handlers.AllowCredentials()
-	}
-	return handlers.CORS(headers, origins)(h)
-}
-
 // ContentTypeOptionsMiddleware adds X-Content-Type-Options header set to nosniff to every response.
 func (api *API) ContentTypeOptionsMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
@@ -94,3 +203,32 @@ func (api *API) FrameOptionsMiddleware(h http.Handler) http.Handler {
 		h.ServeHTTP(rw, r)
 	})
 }
+
+// NoCacher interface is satisfied by endpoints whose responses must never be
+// stored, e.g. those issuing tokens. Their method handlers are wrapped in
+// NoCacheMiddleware by AddEndpoint.
+type NoCacher interface {
+	NoCache() bool
+}
+
+// NoCacheMiddleware adds headers to every response preventing it from being
+// stored by the client, or any intermediary cache: Cache-Control set to
+// no-store, Pragma set to no-cache (for HTTP/1.0 caches), and Expires set to
+// 0. It is not part of the DefaultMiddleware, and is intended for sensitive
+// endpoints, e.g. those issuing tokens: implement NoCacher to add it to an
+// endpoint, or add no-cache to MIDDLEWARE_CHAIN for every route.
+func (api *API) NoCacheMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Cache-Control", "no-store")
+		rw.Header().Set("Pragma", "no-cache")
+		rw.Header().Set("Expires", "0")
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// requiresNoCache returns true if the endpoint's responses must never be
+// stored.
+func requiresNoCache(e Endpointer) bool {
+	n, ok := e.(NoCacher)
+	return ok && n.NoCache()
+}
@@ -0,0 +1,159 @@
+package hyperdrive
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const customMiddlewarePrefix = "custom:"
+
+// middlewareRegistry holds the custom Middleware registered with an API,
+// shared by every copy of the API.
+type middlewareRegistry struct {
+	sync.RWMutex
+	middleware map[string]Middleware
+}
+
+func (reg *middlewareRegistry) get(name string) (Middleware, bool) {
+	reg.RLock()
+	defer reg.RUnlock()
+	m, ok := reg.middleware[name]
+	return m, ok
+}
+
+// RegisterMiddleware registers a custom Middleware with the given name, so it
+// can be referred to as "custom:<name>" in the MIDDLEWARE_CHAIN environment
+// variable. Custom middleware must be registered before the API is started.
+func (api *API) RegisterMiddleware(name string, m Middleware) {
+	api.registry.Lock()
+	defer api.registry.Unlock()
+	if api.registry.middleware == nil {
+		api.registry.middleware = map[string]Middleware{}
+	}
+	api.registry.middleware[name] = m
+}
+
+// builtinMiddleware returns the Middleware provided by hyperdrive, by the
+// name used to refer to them in MIDDLEWARE_CHAIN.
+func (api *API) builtinMiddleware() map[string]MiddlewareChain {
+	return map[string]MiddlewareChain{
+		"metrics":              {api.MetricsMiddleware},
+		"cors":                 {api.CorsMiddleware},
+		"maintenance":          {api.MaintenanceMiddleware},
+		"security":             {api.FrameOptionsMiddleware, api.ContentTypeOptionsMiddleware},
+		"frame-options":        {api.FrameOptionsMiddleware},
+		"content-type-options": {api.ContentTypeOptionsMiddleware},
+		"compress":             {api.CompressionMiddleware},
+		"decompress":           {api.DecompressionMiddleware},
+		"logging":              {api.LoggingMiddleware},
+		"recovery":             {api.RecoveryMiddleware},
+		"method-override":      {api.MethodOverrideMiddleware},
+		"canonical":            {api.CanonicalURLMiddleware},
+		"no-cache":             {api.NoCacheMiddleware},
+		"basic-auth":           {api.BasicAuthMiddleware(nil)},
+		"session":              {api.SessionMiddleware(nil)},
+		"rate-limit":           {api.RateLimitMiddleware(nil)},
+		"quota":                {api.QuotaMiddleware},
+		"concurrency-limit":    {api.ConcurrencyLimitMiddleware},
+		"user-agent":           {api.UserAgentMiddleware},
+		"mirror":               {api.MirrorMiddleware},
+		"fault-injection":      {api.FaultInjectionMiddleware},
+	}
+}
+
+// ParseMiddlewareChain creates a MiddlewareChain from a comma separated list
+// of middleware names, outermost first, e.g.
+// "recovery,cors,security,logging,compress,custom:auth". An error is
+// returned for unknown names. The built-in middleware are:
+//
+// - metrics
+// - cors
+// - maintenance
+// - security (frame-options and content-type-options)
+// - frame-options
+// - content-type-options
+// - compress
+// - decompress
+// - logging
+// - recovery
+// - method-override
+// - canonical
+// - no-cache
+// - basic-auth (with the users set by BASIC_AUTH_USERS)
+// - session (with the values held in the cookie)
+// - rate-limit (counted in the store set by SetRateLimitStore)
+// - quota (counted in the store set by SetQuotaStore)
+// - concurrency-limit
+// - user-agent
+// - mirror
+// - fault-injection
+//
+// Custom middleware, registered with RegisterMiddleware, are prefixed with
+// "custom:", and are looked up when the first request is served, so they may
+// be registered after the chain is parsed.
+func (api *API) ParseMiddlewareChain(spec string) (MiddlewareChain, error) {
+	var (
+		chain    = MiddlewareChain{}
+		builtins = api.builtinMiddleware()
+	)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+			continue
+		case strings.HasPrefix(name, customMiddlewarePrefix):
+			chain = append(chain, api.customMiddleware(strings.TrimPrefix(name, customMiddlewarePrefix)))
+		default:
+			m, ok := builtins[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown middleware %q in middleware chain %q", name, spec)
+			}
+			chain = append(chain, m...)
+		}
+	}
+	return chain, nil
+}
+
+// customMiddleware returns a Middleware which wraps its handler in the custom
+// Middleware registered with the given name, when the first request is served.
+func (api *API) customMiddleware(name string) Middleware {
+	return func(h http.Handler) http.Handler {
+		var (
+			once    sync.Once
+			wrapped http.Handler
+		)
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			once.Do(func() {
+				m, ok := api.registry.get(name)
+				if !ok {
+					log.Printf("Custom middleware %q has not been registered", name)
+					wrapped = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+						http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					})
+					return
+				}
+				wrapped = m(h)
+			})
+			wrapped.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// unregisteredMiddleware returns the names of custom middleware in the
+// MIDDLEWARE_CHAIN which have not been registered.
+func (api *API) unregisteredMiddleware() []string {
+	var missing []string
+	for _, name := range strings.Split(conf.MiddlewareChain, ",") {
+		name = strings.TrimSpace(name)
+		if !strings.HasPrefix(name, customMiddlewarePrefix) {
+			continue
+		}
+		if _, ok := api.registry.get(strings.TrimPrefix(name, customMiddlewarePrefix)); !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
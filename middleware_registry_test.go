@@ -0,0 +1,57 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestParseMiddlewareChain() {
+	c, err := suite.TestAPI.ParseMiddlewareChain("cors, security,compress,logging,recovery")
+	suite.Nil(err, "expects no error")
+	suite.Equal(6, len(c), "expects security to expand to two middleware")
+}
+
+func (suite *HyperdriveTestSuite) TestParseMiddlewareChainUnknown() {
+	_, err := suite.TestAPI.ParseMiddlewareChain("cors,nope")
+	suite.Error(err, "expects an error for an unknown middleware")
+}
+
+func (suite *HyperdriveTestSuite) TestParseMiddlewareChainCustom() {
+	var called bool
+	c, _ := suite.TestAPI.ParseMiddlewareChain("custom:auth")
+	suite.TestAPI.RegisterMiddleware("auth", func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			called = true
+			h.ServeHTTP(rw, r)
+		})
+	})
+	c.Then(suite.TestHandler).ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	suite.True(called, "expects custom middleware registered after parsing to be used")
+}
+
+func (suite *HyperdriveTestSuite) TestParseMiddlewareChainCustomMissing() {
+	rw := httptest.NewRecorder()
+	c, _ := suite.TestAPI.ParseMiddlewareChain("custom:missing")
+	c.Then(suite.TestHandler).ServeHTTP(rw, suite.TestGetRequest)
+	suite.Equal(http.StatusInternalServerError, rw.Code, "expects a 500 if the custom middleware is missing")
+}
+
+func (suite *HyperdriveTestSuite) TestUnregisteredMiddleware() {
+	conf.MiddlewareChain = "cors,custom:auth,custom:audit"
+	defer func() { conf.MiddlewareChain = "" }()
+	suite.TestAPI.RegisterMiddleware("audit", suite.TestAPI.LoggingMiddleware)
+	suite.Equal([]string{"custom:auth"}, suite.TestAPI.unregisteredMiddleware(), "expects the custom middleware which are not registered")
+}
+
+func (suite *HyperdriveTestSuite) TestDefaultMiddlewareChainFromConfig() {
+	var called bool
+	conf.MiddlewareChain = "custom:test"
+	defer func() { conf.MiddlewareChain = "" }()
+	suite.TestAPI.RegisterMiddleware("test", func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			called = true
+		})
+	})
+	suite.TestAPI.DefaultMiddlewareChain(suite.TestHandler).ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	suite.True(called, "expects the chain declared in MIDDLEWARE_CHAIN to be used")
+}
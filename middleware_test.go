@@ -1,6 +1,9 @@
 package hyperdrive
 
-import "net/http"
+import (
+	"net/http"
+	"net/http/httptest"
+)
 
 func (suite *HyperdriveTestSuite) TestDefaultMiddlewareChain() {
 	suite.Implements((*http.Handler)(nil), suite.TestAPI.DefaultMiddlewareChain(suite.TestHandler), "return an implementation of http.Handler")
@@ -39,3 +42,72 @@ func (suite *HyperdriveTestSuite) TestContentTypeOptionsMiddleware() {
 func (suite *HyperdriveTestSuite) TestFrameOptionsMiddleware() {
 	suite.Implements((*http.Handler)(nil), suite.TestAPI.FrameOptionsMiddleware(suite.TestHandler), "return an implementation of http.Handler")
 }
+
+func (suite *HyperdriveTestSuite) TestNewMiddlewareChain() {
+	suite.IsType(MiddlewareChain{}, NewMiddlewareChain(suite.TestAPI.LoggingMiddleware), "expects an instance of MiddlewareChain")
+}
+
+func (suite *HyperdriveTestSuite) TestMiddlewareChainOrder() {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				h.ServeHTTP(rw, r)
+			})
+		}
+	}
+	c := NewMiddlewareChain(mw("b")).Append(mw("c")).Prepend(mw("a"))
+	c.Then(suite.TestHandler).ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	suite.Equal([]string{"a", "b", "c"}, order, "expects middleware to run outermost first")
+}
+
+func (suite *HyperdriveTestSuite) TestDefaultMiddleware() {
+	suite.Equal(8, len(suite.TestAPI.DefaultMiddleware()), "expects the default chain to contain 8 middleware")
+}
+
+func (suite *HyperdriveTestSuite) TestSetMiddlewareChain() {
+	var called bool
+	suite.TestAPI.SetMiddlewareChain(NewMiddlewareChain(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			called = true
+			h.ServeHTTP(rw, r)
+		})
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/json")
+	suite.TestAPI.Router.ServeHTTP(httptest.NewRecorder(), r)
+	suite.True(called, "expects the Discovery URL to use the new chain")
+}
+
+func (suite *HyperdriveTestSuite) TestNoCacheMiddleware() {
+	rw := httptest.NewRecorder()
+	suite.TestAPI.NoCacheMiddleware(suite.TestHandler).ServeHTTP(rw, suite.TestGetRequest)
+	suite.Equal("no-store", rw.Header().Get("Cache-Control"), "expects Cache-Control to be no-store")
+	suite.Equal("no-cache", rw.Header().Get("Pragma"), "expects Pragma to be no-cache")
+	suite.Equal("0", rw.Header().Get("Expires"), "expects Expires to be 0")
+}
+
+type noCacheEndpoint struct {
+	*Endpoint
+}
+
+func (e *noCacheEndpoint) NoCache() bool {
+	return true
+}
+
+func (e *noCacheEndpoint) Get(rw http.ResponseWriter, r *http.Request) {
+	Respond(rw, r, http.StatusOK, map[string]string{"token": "secret"})
+}
+
+func (suite *HyperdriveTestSuite) TestAddEndpointNoCache() {
+	e := &noCacheEndpoint{NewEndpoint("Token", "Token Endpoint", "/token", "1")}
+	suite.TestAPI.AddEndpoint(e)
+	suite.TestAPI.AddEndpoint(suite.TestEndpoint)
+	rw := httptest.NewRecorder()
+	suite.TestAPI.Router.ServeHTTP(rw, httptest.NewRequest("GET", "/token", nil))
+	suite.Equal("no-store", rw.Header().Get("Cache-Control"), "expects endpoints implementing NoCacher not to be cached")
+	rw = httptest.NewRecorder()
+	suite.TestAPI.Router.ServeHTTP(rw, httptest.NewRequest("GET", suite.TestEndpoint.GetPath(), nil))
+	suite.NotEqual("no-store", rw.Header().Get("Cache-Control"), "expects other endpoints to be unaffected")
+}
@@ -0,0 +1,142 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MirrorHeader is set on every request sent to the MIRROR_URL, so the shadow
+// upstream can tell mirrored requests apart, e.g. to skip side effects.
+const MirrorHeader = "X-Mirrored-Request"
+
+// maxMirrorsInFlight is the most mirrored requests sent at once; more are
+// dropped, so a slow shadow upstream can not exhaust the API's resources.
+const maxMirrorsInFlight = 100
+
+// hopHeaders are not forwarded to the shadow upstream, as they only apply
+// to the client's connection to the API.
+var hopHeaders = []string{"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// mirror holds the state of MirrorMiddleware, shared by every copy of the
+// API.
+type mirror struct {
+	client   *http.Client
+	inFlight chan struct{}
+}
+
+func newMirror() *mirror {
+	return &mirror{client: &http.Client{}, inFlight: make(chan struct{}, maxMirrorsInFlight)}
+}
+
+// mirrorURL returns the URL the request is mirrored to: the path and query of
+// the request, appended to the MIRROR_URL.
+func mirrorURL(target *url.URL, r *http.Request) string {
+	u := *target
+	u.Path = strings.TrimSuffix(u.Path, "/") + r.URL.Path
+	u.RawPath = ""
+	u.RawQuery = r.URL.RawQuery
+	return u.String()
+}
+
+// newMirrorRequest creates the request sent to the shadow upstream.
+func newMirrorRequest(ctx context.Context, target *url.URL, r *http.Request, body []byte) (*http.Request, error) {
+	mr, err := http.NewRequestWithContext(ctx, r.Method, mirrorURL(target, r), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	mr.Header = r.Header.Clone()
+	for _, h := range hopHeaders {
+		mr.Header.Del(h)
+	}
+	if !conf.MirrorCredentials {
+		for _, h := range credentialHeaders() {
+			if h != "" {
+				mr.Header.Del(h)
+			}
+		}
+		if q := mr.URL.Query(); conf.APIKeyQueryParam != "" && q.Get(conf.APIKeyQueryParam) != "" {
+			q.Del(conf.APIKeyQueryParam)
+			mr.URL.RawQuery = q.Encode()
+		}
+	}
+	mr.Header.Set(MirrorHeader, "true")
+	mr.Header.Set("X-Forwarded-For", remoteHost(r))
+	mr.Host = r.Host
+	return mr, nil
+}
+
+// send sends the request to the shadow upstream, discarding the response.
+func (m *mirror) send(mr *http.Request) string {
+	res, err := m.client.Do(mr)
+	if err != nil {
+		return "error"
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	return "ok"
+}
+
+// MirrorMiddleware wraps the given http.Handler, and replays MIRROR_PERCENT
+// (0 to 100) of requests to a shadow upstream at MIRROR_URL, e.g. a new
+// version of the service, so it can be tested with the shape of production
+// traffic. Mirrored requests are sent in the background, with the
+// X-Mirrored-Request header, once the request has been served, and their
+// responses are discarded, so the shadow upstream never affects clients.
+//
+// Credentials (the Authorization, Cookie, API key and signature headers, and
+// the API key query param) are stripped from mirrored requests, so they are
+// not sent to another service, unless MIRROR_CREDENTIALS (bool) is true, e.g.
+// when the shadow upstream must authenticate requests like the API does.
+//
+// Request bodies are buffered to be replayed, so requests with bodies larger
+// than MIRROR_MAX_BODY (default: 1MB) are not mirrored. Mirrored requests
+// time out after MIRROR_TIMEOUT (default: 5s), and are dropped when 100 are
+// already in flight. The outcome of each is counted in the
+// mirrored_requests_total metric.
+func (api *API) MirrorMiddleware(h http.Handler) http.Handler {
+	target, err := url.Parse(conf.MirrorURL)
+	if conf.MirrorURL == "" || err != nil {
+		return h
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if conf.MirrorPercent <= 0 || rand.Float64()*100 >= conf.MirrorPercent {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		var body []byte
+		if r.Body != nil && r.Body != http.NoBody {
+			b, err := io.ReadAll(io.LimitReader(r.Body, int64(conf.MirrorMaxBody)+1))
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(b), r.Body))
+			if err != nil || len(b) > conf.MirrorMaxBody {
+				api.metrics.mirrors.WithLabelValues("dropped").Inc()
+				h.ServeHTTP(rw, r)
+				return
+			}
+			body = b
+		}
+		mirrored := r.Clone(context.Background())
+		h.ServeHTTP(rw, r)
+		select {
+		case api.mirror.inFlight <- struct{}{}:
+		default:
+			api.metrics.mirrors.WithLabelValues("dropped").Inc()
+			return
+		}
+		api.Background(r, func(ctx context.Context) {
+			defer func() { <-api.mirror.inFlight }()
+			ctx, cancel := context.WithTimeout(ctx, conf.MirrorTimeout)
+			defer cancel()
+			mr, err := newMirrorRequest(ctx, target, mirrored, body)
+			if err != nil {
+				api.metrics.mirrors.WithLabelValues("error").Inc()
+				return
+			}
+			api.metrics.mirrors.WithLabelValues(api.mirror.send(mr)).Inc()
+		})
+	})
+}
@@ -0,0 +1,105 @@
+package hyperdrive
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// shadowUpstream returns a server which sends every request it receives, with
+// its body, to the returned channel.
+func shadowUpstream() (*httptest.Server, chan *http.Request, chan string) {
+	requests, bodies := make(chan *http.Request, 10), make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- string(b)
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	return ts, requests, bodies
+}
+
+func (suite *HyperdriveTestSuite) TestMirrorMiddleware() {
+	defer func(c Config) { conf = c }(conf)
+	ts, requests, bodies := shadowUpstream()
+	defer ts.Close()
+	conf.MirrorURL = ts.URL + "/shadow/"
+	conf.MirrorPercent = 100
+	var served string
+	h := suite.TestAPI.MirrorMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		served = string(b)
+		rw.WriteHeader(http.StatusCreated)
+	}))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("POST", "/test?a=b", strings.NewReader(`{"name":"test"}`)))
+	suite.Nil(suite.TestAPI.Shutdown(context.Background()), "expects no error")
+	suite.Equal(http.StatusCreated, rw.Code, "expects the response of the handler, not the shadow upstream")
+	suite.Equal(`{"name":"test"}`, served, "expects the handler to read the whole body")
+	r := <-requests
+	suite.Equal("POST", r.Method, "expects the method to be mirrored")
+	suite.Equal("/shadow/test?a=b", r.URL.RequestURI(), "expects the path and query to be mirrored")
+	suite.Equal("true", r.Header.Get(MirrorHeader), "expects the request to be marked as mirrored")
+	suite.Equal(`{"name":"test"}`, <-bodies, "expects the body to be mirrored")
+}
+
+func (suite *HyperdriveTestSuite) TestMirrorMiddlewareCredentials() {
+	defer func(c Config) { conf = c }(conf)
+	ts, requests, _ := shadowUpstream()
+	defer ts.Close()
+	conf.MirrorURL = ts.URL
+	conf.MirrorPercent = 100
+	conf.APIKeyQueryParam = "api_key"
+	h := suite.TestAPI.MirrorMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	mirror := func() *http.Request {
+		r := httptest.NewRequest("GET", "/test?a=b&api_key=secret", nil)
+		for _, header := range []string{"Authorization", "Cookie", "X-API-Key", "X-Signature"} {
+			r.Header.Set(header, "secret")
+		}
+		r.Header.Set("Accept", "application/json")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		return <-requests
+	}
+	r := mirror()
+	for _, header := range []string{"Authorization", "Cookie", "X-API-Key", "X-Signature"} {
+		suite.Empty(r.Header.Get(header), "expects the "+header+" header not to be mirrored")
+	}
+	suite.Equal("/test?a=b", r.URL.RequestURI(), "expects the API key query param not to be mirrored")
+	suite.Equal("application/json", r.Header.Get("Accept"), "expects other headers to be mirrored")
+	conf.MirrorCredentials = true
+	r = mirror()
+	suite.Equal("secret", r.Header.Get("Authorization"), "expects credentials to be mirrored when MIRROR_CREDENTIALS is true")
+	suite.Equal("/test?a=b&api_key=secret", r.URL.RequestURI(), "expects the API key query param to be mirrored when MIRROR_CREDENTIALS is true")
+	suite.Nil(suite.TestAPI.Shutdown(context.Background()), "expects no error")
+}
+
+func (suite *HyperdriveTestSuite) TestMirrorMiddlewareMaxBody() {
+	defer func(c Config) { conf = c }(conf)
+	ts, requests, _ := shadowUpstream()
+	defer ts.Close()
+	conf.MirrorURL = ts.URL
+	conf.MirrorPercent = 100
+	conf.MirrorMaxBody = 4
+	var served string
+	h := suite.TestAPI.MirrorMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		served = string(b)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/test", strings.NewReader(`{"name":"test"}`)))
+	suite.Nil(suite.TestAPI.Shutdown(context.Background()), "expects no error")
+	suite.Equal(`{"name":"test"}`, served, "expects the handler to read the whole body")
+	suite.Empty(requests, "expects requests with large bodies not to be mirrored")
+}
+
+func (suite *HyperdriveTestSuite) TestMirrorMiddlewareDisabled() {
+	defer func(c Config) { conf = c }(conf)
+	ts, requests, _ := shadowUpstream()
+	defer ts.Close()
+	conf.MirrorURL = ts.URL
+	h := suite.TestAPI.MirrorMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+	suite.Nil(suite.TestAPI.Shutdown(context.Background()), "expects no error")
+	suite.Empty(requests, "expects no requests to be mirrored with a MIRROR_PERCENT of 0")
+}
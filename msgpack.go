@@ -0,0 +1,373 @@
+package hyperdrive
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMsgPackDepth is the deepest nesting of maps and arrays decoded by
+// BodyMsgPack, as encoding/json limits JSON bodies.
+const maxMsgPackDepth = 10000
+
+// msgPackTimestamp is the MessagePack extension type of timestamps.
+const msgPackTimestamp = -1
+
+var errMsgPackTruncated = errors.New("msgpack: unexpected end of data")
+
+// BodyMsgPack decodes the MessagePack map in the request body into the same
+// shape as BodyJSON, so internal clients can avoid the overhead of JSON while
+// being served by the same handlers: maps (with string keys) become objects,
+// integers and floats json.Number, binary data strings, and timestamps
+// strings formatted as time.RFC3339Nano. Other extension types are rejected.
+// The body is left unread, so BodyMsgPack (and BodyParams) can be called more
+// than once.
+func BodyMsgPack(r *http.Request) (map[string]interface{}, error) {
+	b, err := RawBody(r)
+	if err != nil || len(b) == 0 {
+		return map[string]interface{}{}, err
+	}
+	d := &msgPackDecoder{data: b}
+	v, err := d.decode(0)
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+	if d.pos != len(d.data) {
+		return map[string]interface{}{}, errors.New("msgpack: unexpected data after the top-level value")
+	}
+	body, ok := v.(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}, errors.New("msgpack: the top-level value must be a map")
+	}
+	return body, nil
+}
+
+// isMsgPackContentType returns true for application/msgpack,
+// application/x-msgpack, application/vnd.msgpack, and media types with a
+// msgpack suffix (+msgpack).
+func isMsgPackContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return contains([]string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}, mediaType) || strings.HasSuffix(mediaType, "+msgpack")
+}
+
+// msgPackDecoder decodes MessagePack values, as described by BodyMsgPack.
+type msgPackDecoder struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next n bytes.
+func (d *msgPackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, errMsgPackTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// uint returns the next n (1, 2, 4, or 8) bytes as a big-endian integer.
+func (d *msgPackDecoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// length returns the next n bytes as the length of a value, which must fit
+// in the remaining data, given each item is at least min bytes long.
+func (d *msgPackDecoder) length(n int, min int) (int, error) {
+	l, err := d.uint(n)
+	if err != nil {
+		return 0, err
+	}
+	if l > uint64(len(d.data)-d.pos)/uint64(min) {
+		return 0, errMsgPackTruncated
+	}
+	return int(l), nil
+}
+
+func (d *msgPackDecoder) decode(depth int) (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	t := b[0]
+	switch {
+	case t <= 0x7f:
+		return json.Number(strconv.Itoa(int(t))), nil
+	case t >= 0xe0:
+		return json.Number(strconv.Itoa(int(int8(t)))), nil
+	case t >= 0x80 && t <= 0x8f:
+		return d.decodeMap(int(t&0x0f), depth)
+	case t >= 0x90 && t <= 0x9f:
+		return d.decodeArray(int(t&0x0f), depth)
+	case t >= 0xa0 && t <= 0xbf:
+		s, err := d.next(int(t & 0x1f))
+		return string(s), err
+	}
+	switch t {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		return d.decodeString(1 << (t - 0xc4))
+	case 0xd9, 0xda, 0xdb:
+		return d.decodeString(1 << (t - 0xd9))
+	case 0xca:
+		u, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return msgPackFloat(float64(math.Float32frombits(uint32(u))), 32)
+	case 0xcb:
+		u, err := d.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return msgPackFloat(math.Float64frombits(u), 64)
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (t - 0xcc))
+		return json.Number(strconv.FormatUint(u, 10)), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (t - 0xd0)
+		u, err := d.uint(n)
+		if err != nil {
+			return nil, err
+		}
+		// Sign extend the n byte integer.
+		shift := uint(64 - 8*n)
+		return json.Number(strconv.FormatInt(int64(u<<shift)>>shift, 10)), nil
+	case 0xdc, 0xdd:
+		n, err := d.length(2<<(t-0xdc), 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(2<<(t-0xde), 2)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n, depth)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (t - 0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.length(1<<(t-0xc7), 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(n)
+	}
+	return nil, fmt.Errorf("msgpack: invalid type 0x%x", t)
+}
+
+// decodeString decodes a string, or binary data, whose length is given by the
+// next n bytes.
+func (d *msgPackDecoder) decodeString(n int) (interface{}, error) {
+	l, err := d.length(n, 1)
+	if err != nil {
+		return nil, err
+	}
+	s, err := d.next(l)
+	return string(s), err
+}
+
+func (d *msgPackDecoder) decodeArray(n int, depth int) (interface{}, error) {
+	if depth >= maxMsgPackDepth {
+		return nil, errors.New("msgpack: exceeded max depth")
+	}
+	values := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (d *msgPackDecoder) decodeMap(n int, depth int) (interface{}, error) {
+	if depth >= maxMsgPackDepth {
+		return nil, errors.New("msgpack: exceeded max depth")
+	}
+	fields := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, errors.New("msgpack: map keys must be strings")
+		}
+		if fields[key], err = d.decode(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// decodeExt decodes an extension value of n bytes. Only timestamps are
+// supported.
+func (d *msgPackDecoder) decodeExt(n int) (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	typ := int8(b[0])
+	data, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if typ != msgPackTimestamp {
+		return nil, fmt.Errorf("msgpack: unsupported extension type %d", typ)
+	}
+	var sec, nsec int64
+	switch n {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		u := binary.BigEndian.Uint64(data)
+		sec, nsec = int64(u&(1<<34-1)), int64(u>>34)
+	case 12:
+		nsec, sec = int64(binary.BigEndian.Uint32(data)), int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		return nil, fmt.Errorf("msgpack: invalid timestamp length %d", n)
+	}
+	return time.Unix(sec, nsec).UTC().Format(time.RFC3339Nano), nil
+}
+
+// msgPackFloat returns f as a json.Number, formatted with the precision of
+// its encoding.
+func msgPackFloat(f float64, bits int) (interface{}, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, errors.New("msgpack: NaN and infinite floats are not supported")
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, bits)), nil
+}
+
+// encodeMsgPack appends the MessagePack encoding of v, a value decoded from
+// JSON with json.Decoder.UseNumber, to b. Map keys are sorted, so the
+// encoding is deterministic.
+func encodeMsgPack(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case json.Number:
+		return encodeMsgPackNumber(b, v)
+	case string:
+		return append(msgPackHeader(b, len(v), 0xa0, 0xd9), v...), nil
+	case []interface{}:
+		b = msgPackHeader(b, len(v), 0x90, 0xdc)
+		for _, e := range v {
+			var err error
+			if b, err = encodeMsgPack(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		b = msgPackHeader(b, len(v), 0x80, 0xde)
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var err error
+			b = append(msgPackHeader(b, len(k), 0xa0, 0xd9), k...)
+			if b, err = encodeMsgPack(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type %T", v)
+}
+
+// encodeMsgPackNumber appends n as the smallest MessagePack integer it fits
+// in, or as a 64 bit float.
+func encodeMsgPackNumber(b []byte, n json.Number) ([]byte, error) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		switch {
+		case i >= 0 && i <= 0x7f, i < 0 && i >= -32:
+			return append(b, byte(i)), nil
+		case i >= math.MinInt8 && i <= math.MaxInt8:
+			return append(b, 0xd0, byte(i)), nil
+		case i >= math.MinInt16 && i <= math.MaxInt16:
+			return appendMsgPackUint(append(b, 0xd1), uint64(i), 2), nil
+		case i >= math.MinInt32 && i <= math.MaxInt32:
+			return appendMsgPackUint(append(b, 0xd2), uint64(i), 4), nil
+		}
+		return appendMsgPackUint(append(b, 0xd3), uint64(i), 8), nil
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return appendMsgPackUint(append(b, 0xcf), u, 8), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	return appendMsgPackUint(append(b, 0xcb), math.Float64bits(f), 8), nil
+}
+
+// msgPackHeader appends the type and length of a string, array, or map of n
+// items: fix is the type of the fixed length form, and first the type of the
+// form with an 8 bit (strings) or 16 bit length, followed by the wider ones.
+func msgPackHeader(b []byte, n int, fix byte, first byte) []byte {
+	fixMax := 15
+	if fix == 0xa0 {
+		fixMax = 31
+	}
+	switch {
+	case n <= fixMax:
+		return append(b, fix|byte(n))
+	case fix == 0xa0 && n <= math.MaxUint8:
+		return append(b, first, byte(n))
+	}
+	if fix == 0xa0 {
+		first++
+	}
+	if n <= math.MaxUint16 {
+		return appendMsgPackUint(append(b, first), uint64(n), 2)
+	}
+	return appendMsgPackUint(append(b, first+1), uint64(n), 4)
+}
+
+// appendMsgPackUint appends the low n (2, 4, or 8) bytes of u, big-endian.
+func appendMsgPackUint(b []byte, u uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		b = append(b, byte(u>>(8*uint(i))))
+	}
+	return b
+}
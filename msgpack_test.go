@@ -0,0 +1,71 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+)
+
+// testMsgPackUser is {"name": "test", "limit": 10, "tags": ["a", "b"],
+// "address": {"city": "Austin"}}, encoded as MessagePack.
+var testMsgPackUser = []byte{
+	0x84,
+	0xa4, 'n', 'a', 'm', 'e', 0xa4, 't', 'e', 's', 't',
+	0xa5, 'l', 'i', 'm', 'i', 't', 0x0a,
+	0xa4, 't', 'a', 'g', 's', 0x92, 0xa1, 'a', 0xa1, 'b',
+	0xa7, 'a', 'd', 'd', 'r', 'e', 's', 's', 0x81, 0xa4, 'c', 'i', 't', 'y', 0xa6, 'A', 'u', 's', 't', 'i', 'n',
+}
+
+func (suite *HyperdriveTestSuite) TestBodyMsgPack() {
+	r := httptest.NewRequest("POST", "/test", bytes.NewReader(testMsgPackUser))
+	r.Header.Set("Content-Type", "application/msgpack")
+	body, err := BodyMsgPack(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal(map[string]interface{}{
+		"name":    "test",
+		"limit":   json.Number("10"),
+		"tags":    []interface{}{"a", "b"},
+		"address": map[string]interface{}{"city": "Austin"},
+	}, body, "expects the same shape as BodyJSON")
+	suite.Equal([]string{"a", "b"}, BodyParams(r)["tags"], "expects the body to be flattened by BodyParams")
+}
+
+func (suite *HyperdriveTestSuite) TestBodyMsgPackTypes() {
+	r := httptest.NewRequest("POST", "/test", bytes.NewReader([]byte{
+		0x86,
+		0xa1, 'n', 0xd1, 0xff, 0x38,
+		0xa1, 'u', 0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xa1, 'f', 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+		0xa1, 'b', 0xc2,
+		0xa1, 'z', 0xc0,
+		0xa1, 't', 0xd6, 0xff, 0x5a, 0x4a, 0xf6, 0xa0,
+	}))
+	r.Header.Set("Content-Type", "application/x-msgpack")
+	body, err := BodyMsgPack(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal(json.Number("-200"), body["n"], "expects negative integers")
+	suite.Equal(json.Number("18446744073709551615"), body["u"], "expects large integers not to lose precision")
+	suite.Equal(json.Number("1.5"), body["f"], "expects floats")
+	suite.Equal(false, body["b"], "expects booleans")
+	suite.Nil(body["z"], "expects nil")
+	suite.Equal("2018-01-02T03:04:00Z", body["t"], "expects timestamps formatted as RFC 3339")
+}
+
+func (suite *HyperdriveTestSuite) TestBodyMsgPackInvalid() {
+	for _, b := range [][]byte{{0x91, 0x01}, {0x81, 0x01, 0x01}, {0xdf, 0xff, 0xff, 0xff, 0xff}, {0x80, 0x80}, {0x81, 0xa1, 'x', 0xd4, 0x01, 0x00}} {
+		r := httptest.NewRequest("POST", "/test", bytes.NewReader(b))
+		_, err := BodyMsgPack(r)
+		suite.Error(err, "expects an error for invalid bodies")
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestBindMsgPack() {
+	r := httptest.NewRequest("PUT", "/users/42", bytes.NewReader(testMsgPackUser))
+	r.Header.Set("Content-Type", "application/vnd.api+msgpack")
+	target, err := suite.bindRequest(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal("test", target.Name, "expects the field to be decoded")
+	suite.Equal(10, *target.Limit, "expects integers to be decoded")
+	suite.Equal([]string{"a", "b"}, target.Tags, "expects arrays to be decoded")
+	suite.Equal(map[string]string{"city": "Austin"}, target.Address, "expects nested maps to be decoded")
+}
@@ -0,0 +1,229 @@
+package hyperdrive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sort"
+)
+
+// ErrFileTooLarge is returned by the Reader of a StreamedFile once more than
+// its size limit has been read.
+var ErrFileTooLarge = errors.New("file too large")
+
+// errUploadTooLarge is returned by the body of a multipart request once more
+// than MaxUploadBytes has been read.
+var errUploadTooLarge = errors.New("upload too large")
+
+// MultipartLimits configures how multipart bodies are read by Files and
+// StreamFiles. MaxMemory is the number of bytes of a body held in memory, the
+// rest being written to temporary files on disk. MaxUploadBytes is the size
+// limit of the whole body (0 for no limit). MaxFileSize is the size limit of
+// every file (0 for no limit), unless the field it was uploaded in has its
+// own limit in FieldLimits.
+type MultipartLimits struct {
+	MaxMemory      int64
+	MaxUploadBytes int64
+	MaxFileSize    int64
+	FieldLimits    map[string]int64
+}
+
+// DefaultMultipartLimits returns the MultipartLimits configured by the
+// MULTIPART_MAX_MEMORY (default: 32MB), MAX_UPLOAD_BYTES (default: 0, no
+// limit), and MULTIPART_MAX_FILE_SIZE (default: 0, no limit) environment
+// variables.
+func DefaultMultipartLimits() MultipartLimits {
+	return MultipartLimits{MaxMemory: int64(conf.MultipartMaxMemory), MaxUploadBytes: int64(conf.MaxUploadBytes), MaxFileSize: int64(conf.MultipartMaxFileSize)}
+}
+
+// limitBody limits the body of the request to MaxUploadBytes, and returns
+// the limitedReader it is read through, or nil if there is no limit. The
+// multipart package does not return the errors of the body as they are, so
+// use exceeded to check if the limit was reached.
+func (l MultipartLimits) limitBody(r *http.Request) *limitedReader {
+	if l.MaxUploadBytes <= 0 || r.Body == nil {
+		return nil
+	}
+	lr := &limitedReader{r: r.Body, n: l.MaxUploadBytes, err: errUploadTooLarge}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{lr, r.Body}
+	return lr
+}
+
+// uploadTooLarge returns a *ValidationError for a body over MaxUploadBytes.
+func (l MultipartLimits) uploadTooLarge() error {
+	verr := &ValidationError{}
+	verr.AddBodyError("", ValidationTooLarge, fmt.Sprintf("The request body must not be larger than %d bytes", l.MaxUploadBytes))
+	return verr
+}
+
+// limit returns the size limit of the files uploaded in the given field.
+func (l MultipartLimits) limit(field string) int64 {
+	if n, ok := l.FieldLimits[field]; ok {
+		return n
+	}
+	return l.MaxFileSize
+}
+
+// tooLarge returns a *ValidationError for a file over its size limit.
+func (l MultipartLimits) tooLarge(field string, filename string) error {
+	verr := &ValidationError{}
+	verr.AddBodyError(JSONPointer(field), ValidationTooLarge, fmt.Sprintf("%s must not be larger than %d bytes", filename, l.limit(field)))
+	return verr
+}
+
+// UploadedFile is a file uploaded in a multipart body, as returned by Files.
+type UploadedFile struct {
+	Field       string
+	Filename    string
+	ContentType string
+	Size        int64
+	header      *multipart.FileHeader
+}
+
+// Open opens the file, from memory, or from the temporary file it was
+// written to. Temporary files are removed once the request has been served.
+func (f UploadedFile) Open() (multipart.File, error) {
+	return f.header.Open()
+}
+
+// Files parses the multipart body of the request, with the
+// DefaultMultipartLimits, and returns the files uploaded in it, sorted by
+// field. The other form values are available from BodyParams. See
+// FilesWithLimits.
+func Files(r *http.Request) ([]UploadedFile, error) {
+	return FilesWithLimits(r, DefaultMultipartLimits())
+}
+
+// FilesWithLimits parses the multipart body of the request, keeping up to
+// l.MaxMemory bytes in memory, and writing the rest to temporary files, and
+// returns the files uploaded in it. If the body or a file is over its size
+// limit, a *ValidationError is returned, which can be written with
+// WriteValidationError. Use StreamFiles to read large files without writing
+// them to disk.
+func FilesWithLimits(r *http.Request, l MultipartLimits) ([]UploadedFile, error) {
+	body := l.limitBody(r)
+	if err := r.ParseMultipartForm(l.MaxMemory); err != nil {
+		if body.exceeded() {
+			return nil, l.uploadTooLarge()
+		}
+		return nil, err
+	}
+	var (
+		files  []UploadedFile
+		fields []string
+	)
+	for field := range r.MultipartForm.File {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		for _, fh := range r.MultipartForm.File[field] {
+			if limit := l.limit(field); limit > 0 && fh.Size > limit {
+				return nil, l.tooLarge(field, fh.Filename)
+			}
+			files = append(files, UploadedFile{
+				Field:       field,
+				Filename:    fh.Filename,
+				ContentType: fh.Header.Get("Content-Type"),
+				Size:        fh.Size,
+				header:      fh,
+			})
+		}
+	}
+	return files, nil
+}
+
+// StreamedFile is a file being read from a multipart body by StreamFiles.
+// Reading more than its size limit from Reader returns ErrFileTooLarge.
+type StreamedFile struct {
+	Field       string
+	Filename    string
+	ContentType string
+	Reader      io.Reader
+}
+
+// limitedReader returns err (default: ErrFileTooLarge) once more than n
+// bytes are read.
+type limitedReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+// exceeded returns true if more than n bytes have been read.
+func (lr *limitedReader) exceeded() bool {
+	return lr != nil && lr.n < 0
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.err == nil {
+		lr.err = ErrFileTooLarge
+	}
+	if lr.n < 0 {
+		return 0, lr.err
+	}
+	if int64(len(p)) > lr.n+1 {
+		p = p[:lr.n+1]
+	}
+	n, err := lr.r.Read(p)
+	lr.n -= int64(n)
+	if lr.n < 0 {
+		return n + int(lr.n), lr.err
+	}
+	return n, err
+}
+
+// StreamFiles reads the multipart body of the request one part at a time,
+// calling fn with each file as it arrives, so large files can be processed
+// (e.g. copied to object storage) without being held in memory or written to
+// disk. Parts which are not files are skipped. If fn returns an error, or a
+// file or the body is over its size limit, StreamFiles stops and returns it;
+// a file or body over its limit is reported as a *ValidationError.
+func StreamFiles(r *http.Request, l MultipartLimits, fn func(f StreamedFile) error) error {
+	body := l.limitBody(r)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && body.exceeded() {
+			return l.uploadTooLarge()
+		}
+		if err != nil {
+			return err
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+		var reader io.Reader = part
+		if limit := l.limit(part.FormName()); limit > 0 {
+			reader = &limitedReader{r: part, n: limit}
+		}
+		err = fn(StreamedFile{
+			Field:       part.FormName(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Reader:      reader,
+		})
+		part.Close()
+		if err != nil && body.exceeded() {
+			return l.uploadTooLarge()
+		}
+		if errors.Is(err, ErrFileTooLarge) {
+			return l.tooLarge(part.FormName(), part.FileName())
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
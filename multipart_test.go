@@ -0,0 +1,96 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func newMultipartRequest(files map[string]string) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("name", "test")
+	for field, content := range files {
+		fw, _ := w.CreateFormFile(field, field+".txt")
+		io.WriteString(fw, content)
+	}
+	w.Close()
+	r := httptest.NewRequest("POST", "/test", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	return r
+}
+
+func (suite *HyperdriveTestSuite) TestFiles() {
+	r := newMultipartRequest(map[string]string{"avatar": "hello", "resume": "world!"})
+	files, err := Files(r)
+	suite.Require().Nil(err, "expects the files to be parsed")
+	suite.Require().Len(files, 2, "expects every file to be returned")
+	suite.Equal("avatar", files[0].Field, "expects the files to be sorted by field")
+	suite.Equal("avatar.txt", files[0].Filename, "expects the filename")
+	suite.Equal("application/octet-stream", files[0].ContentType, "expects the content type")
+	suite.Equal(int64(5), files[0].Size, "expects the size")
+	f, err := files[1].Open()
+	suite.Require().Nil(err, "expects the file to be opened")
+	defer f.Close()
+	content, _ := ioutil.ReadAll(f)
+	suite.Equal("world!", string(content), "expects the content of the file")
+	suite.Equal("test", r.FormValue("name"), "expects the other form values to be parsed")
+}
+
+func (suite *HyperdriveTestSuite) TestFilesNotMultipart() {
+	_, err := Files(suite.TestPostRequest)
+	suite.Equal(http.ErrNotMultipart, err, "expects an error when the body is not multipart")
+}
+
+func (suite *HyperdriveTestSuite) TestFilesWithLimits() {
+	l := MultipartLimits{MaxMemory: 1024, MaxFileSize: 5, FieldLimits: map[string]int64{"resume": 10}}
+	_, err := FilesWithLimits(newMultipartRequest(map[string]string{"avatar": "hello", "resume": "world!"}), l)
+	suite.Nil(err, "expects files within their limits to be accepted")
+	_, err = FilesWithLimits(newMultipartRequest(map[string]string{"avatar": "hello!"}), l)
+	verr, ok := err.(*ValidationError)
+	suite.Require().True(ok, "expects a *ValidationError")
+	suite.Equal([]FieldError{{In: InBody, Pointer: "/avatar", Code: ValidationTooLarge, Message: "avatar.txt must not be larger than 5 bytes"}}, verr.Errors, "expects the file over its limit to be reported")
+}
+
+func (suite *HyperdriveTestSuite) TestStreamFiles() {
+	var streamed []string
+	err := StreamFiles(newMultipartRequest(map[string]string{"avatar": "hello"}), MultipartLimits{}, func(f StreamedFile) error {
+		content, err := ioutil.ReadAll(f.Reader)
+		streamed = append(streamed, f.Field+"="+string(content))
+		return err
+	})
+	suite.Nil(err, "expects the files to be streamed")
+	suite.Equal([]string{"avatar=hello"}, streamed, "expects only the files to be streamed")
+}
+
+func (suite *HyperdriveTestSuite) TestStreamFilesTooLarge() {
+	l := MultipartLimits{FieldLimits: map[string]int64{"avatar": 4}}
+	err := StreamFiles(newMultipartRequest(map[string]string{"avatar": strings.Repeat("a", 4096)}), l, func(f StreamedFile) error {
+		_, err := io.Copy(ioutil.Discard, f.Reader)
+		return err
+	})
+	verr, ok := err.(*ValidationError)
+	suite.Require().True(ok, "expects a *ValidationError")
+	suite.Equal(ValidationTooLarge, verr.Errors[0].Code, "expects the file over its limit to be reported")
+}
+
+func (suite *HyperdriveTestSuite) TestFilesUploadTooLarge() {
+	l := MultipartLimits{MaxMemory: 1024, MaxUploadBytes: 256}
+	_, err := FilesWithLimits(newMultipartRequest(map[string]string{"avatar": strings.Repeat("a", 4096)}), l)
+	suite.Equal(&ValidationError{Errors: []FieldError{{In: InBody, Code: ValidationTooLarge, Message: "The request body must not be larger than 256 bytes"}}}, err, "expects the body over its limit to be reported")
+	err = StreamFiles(newMultipartRequest(map[string]string{"avatar": strings.Repeat("a", 4096)}), l, func(f StreamedFile) error {
+		_, err := io.Copy(ioutil.Discard, f.Reader)
+		return err
+	})
+	suite.Equal(ValidationTooLarge, err.(*ValidationError).Errors[0].Code, "expects the body over its limit to be reported when streaming")
+}
+
+func (suite *HyperdriveTestSuite) TestDefaultMultipartLimits() {
+	defer func(c Config) { conf = c }(conf)
+	conf.MaxUploadBytes = 1024
+	suite.Equal(int64(1024), DefaultMultipartLimits().MaxUploadBytes, "expects MAX_UPLOAD_BYTES to be used")
+}
@@ -0,0 +1,91 @@
+// Package nats provides a hyperdrive.QueueAdapter which receives messages
+// from a NATS JetStream pull subscription, so they can be handled by a
+// hyperdrive consumer.
+//
+//	nc, _ := natsgo.Connect(natsgo.DefaultURL)
+//	js, _ := nc.JetStream()
+//	sub, err := js.PullSubscribe("orders.*", "orders-worker")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	api.AddConsumer("orders", nats.NewAdapter(sub), handler)
+package nats
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hyperdriven/hyperdrive"
+	natsgo "github.com/nats-io/nats.go"
+)
+
+// defaultFetchTimeout is how long Receive waits for messages, when the
+// FetchTimeout of the Adapter is not set.
+const defaultFetchTimeout = 5 * time.Second
+
+// Subscription interface is satisfied by a JetStream pull subscription, and
+// holds the methods used by Adapter.
+type Subscription interface {
+	Fetch(batch int, opts ...natsgo.PullOpt) ([]*natsgo.Msg, error)
+}
+
+// Adapter is an implementation of hyperdrive.QueueAdapter, which fetches up
+// to Batch messages at a time from a JetStream pull subscription, waiting up
+// to FetchTimeout for them. Acked messages are acknowledged, and nacked
+// messages are negatively acknowledged, so JetStream redelivers them.
+type Adapter struct {
+	Subscription Subscription
+	Batch        int
+	FetchTimeout time.Duration
+}
+
+// NewAdapter creates an instance of Adapter, for the given subscription.
+func NewAdapter(sub Subscription) *Adapter {
+	return &Adapter{Subscription: sub, Batch: 10, FetchTimeout: defaultFetchTimeout}
+}
+
+// Receive fetches messages from the subscription, waiting until
+// FetchTimeout passes, or the context is done, for them to arrive. No
+// messages, and no error, are returned if none arrive in time. (JetStream
+// requires a deadline for each fetch, and the context of a consumer has
+// none.)
+func (a *Adapter) Receive(ctx context.Context) ([]*hyperdrive.Message, error) {
+	timeout := a.FetchTimeout
+	if timeout <= 0 {
+		timeout = defaultFetchTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	msgs, err := a.Subscription.Fetch(a.Batch, natsgo.Context(ctx))
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, natsgo.ErrTimeout) {
+		return nil, err
+	}
+	messages := make([]*hyperdrive.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		m := &hyperdrive.Message{
+			Body:       msg.Data,
+			Attributes: map[string]string{},
+			Raw:        msg,
+		}
+		for k := range msg.Header {
+			m.Attributes[k] = msg.Header.Get(k)
+		}
+		m.ID = msg.Header.Get(natsgo.MsgIdHdr)
+		if meta, err := msg.Metadata(); err == nil {
+			m.Attempts = int(meta.NumDelivered)
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// Ack acknowledges the message, so it is not redelivered.
+func (a *Adapter) Ack(ctx context.Context, m *hyperdrive.Message) error {
+	return m.Raw.(*natsgo.Msg).Ack(natsgo.Context(ctx))
+}
+
+// Nack negatively acknowledges the message, so it is redelivered.
+func (a *Adapter) Nack(ctx context.Context, m *hyperdrive.Message) error {
+	return m.Raw.(*natsgo.Msg).Nak(natsgo.Context(ctx))
+}
@@ -0,0 +1,84 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperdriven/hyperdrive"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/suite"
+)
+
+type testSubscription struct {
+	msgs []*natsgo.Msg
+	err  error
+	opts []natsgo.PullOpt
+}
+
+func (s *testSubscription) Fetch(batch int, opts ...natsgo.PullOpt) ([]*natsgo.Msg, error) {
+	s.opts = opts
+	return s.msgs, s.err
+}
+
+type NATSTestSuite struct {
+	suite.Suite
+	Subscription *testSubscription
+	Adapter      *Adapter
+}
+
+func (suite *NATSTestSuite) SetupTest() {
+	msg := natsgo.NewMsg("orders.created")
+	msg.Data = []byte(`{"id":1}`)
+	msg.Header.Set(natsgo.MsgIdHdr, "1")
+	msg.Header.Set("Type", "order.created")
+	suite.Subscription = &testSubscription{msgs: []*natsgo.Msg{msg}}
+	suite.Adapter = NewAdapter(suite.Subscription)
+}
+
+func (suite *NATSTestSuite) TestQueueAdapter() {
+	suite.Implements((*hyperdrive.QueueAdapter)(nil), suite.Adapter, "expects an implementation of hyperdrive.QueueAdapter")
+}
+
+func (suite *NATSTestSuite) TestReceive() {
+	messages, err := suite.Adapter.Receive(context.Background())
+	suite.Nil(err, "expects no error")
+	suite.Equal(1, len(messages), "expects the messages fetched")
+	suite.Equal("1", messages[0].ID, "expects the ID of the message")
+	suite.Equal(`{"id":1}`, string(messages[0].Body), "expects the body of the message")
+	suite.Equal("order.created", messages[0].Attributes["Type"], "expects the headers of the message")
+}
+
+func (suite *NATSTestSuite) TestReceiveDeadline() {
+	suite.Adapter.Receive(context.Background())
+	suite.Require().Equal(1, len(suite.Subscription.opts), "expects a context to be passed to Fetch")
+	ctx, ok := suite.Subscription.opts[0].(natsgo.ContextOpt)
+	suite.Require().True(ok, "expects a context to be passed to Fetch")
+	deadline, ok := ctx.Deadline()
+	suite.True(ok, "expects the context to have a deadline, as JetStream requires")
+	suite.WithinDuration(time.Now().Add(suite.Adapter.FetchTimeout), deadline, time.Second, "expects the deadline to be FetchTimeout from now")
+}
+
+func (suite *NATSTestSuite) TestReceiveTimeout() {
+	suite.Subscription.msgs, suite.Subscription.err = nil, natsgo.ErrTimeout
+	messages, err := suite.Adapter.Receive(context.Background())
+	suite.Nil(err, "expects no error when no messages arrive in time")
+	suite.Equal(0, len(messages), "expects no messages")
+}
+
+func (suite *NATSTestSuite) TestReceiveError() {
+	suite.Subscription.err = errors.New("connection closed")
+	_, err := suite.Adapter.Receive(context.Background())
+	suite.Error(err, "expects an error")
+}
+
+func (suite *NATSTestSuite) TestAckNotJetStream() {
+	messages, _ := suite.Adapter.Receive(context.Background())
+	suite.Error(suite.Adapter.Ack(context.Background(), messages[0]), "expects an error for a message not bound to a subscription")
+	suite.Error(suite.Adapter.Nack(context.Background(), messages[0]), "expects an error for a message not bound to a subscription")
+}
+
+func TestNATSTestSuite(t *testing.T) {
+	suite.Run(t, new(NATSTestSuite))
+}
@@ -0,0 +1,113 @@
+package hyperdrive
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// renderedMediaTypes are the media types offered for the wildcard ranges of
+// an Accept header (e.g. */* or text/*), most preferred first.
+var renderedMediaTypes = []string{"application/json", "application/xml", "application/msgpack", "text/html"}
+
+// acceptRange is a media range of an Accept header, with its quality.
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept returns the media ranges of an Accept header, in the order
+// given. Malformed ranges are skipped. An empty header accepts anything.
+func parseAccept(accept string) []acceptRange {
+	if strings.TrimSpace(accept) == "" {
+		return []acceptRange{{"*/*", 1}}
+	}
+	var ranges []acceptRange
+	for _, a := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(a)
+		if err != nil || !strings.Contains(mediaType, "/") {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType, q})
+	}
+	return ranges
+}
+
+// quality returns the quality the ranges give the media type: that of the
+// most specific range matching it (the media type itself, then type/*, then
+// */*), or 0 if none do.
+func quality(ranges []acceptRange, mediaType string) float64 {
+	q, specificity := 0.0, 0
+	for _, r := range ranges {
+		s := 0
+		switch {
+		case r.mediaType == mediaType:
+			s = 3
+		case r.mediaType == mediaType[:strings.Index(mediaType, "/")+1]+"*":
+			s = 2
+		case r.mediaType == "*/*":
+			s = 1
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// canRender returns true if hyperdrive can render the media type: a media
+// type with a Codec registered with RegisterCodec, or whose structured
+// suffix or extension (e.g. application/vnd.api.users.v1+json or
+// application/vnd.api.users.v1.json) is json, xml, msgpack, html or csv.
+func canRender(mediaType string) bool {
+	if _, ok := hAPI.codecs.lookup(mediaType); ok {
+		return true
+	}
+	return renderFormat(mediaType) != ""
+}
+
+// renderFormat returns the built in format (json, xml, msgpack, html, or csv)
+// of the media type, or "" if it has none.
+func renderFormat(mediaType string) string {
+	switch suffix := mediaTypeSuffix(mediaType); suffix {
+	case "json", "xml", "msgpack", "html", "csv":
+		return suffix
+	case "x-msgpack":
+		return "msgpack"
+	}
+	return ""
+}
+
+// Negotiate returns the media type to respond with, chosen by the Accept
+// header: of the media types it names which can be rendered (JSON, XML,
+// MessagePack, HTML, CSV, those matched by their structured suffix, e.g.
+// application/vnd.api.users.v1+json, and those with a Codec registered with
+// RegisterCodec), and those offered for wildcard ranges (application/json,
+// application/xml, application/msgpack, and text/html, in that order), the one
+// with the highest quality (q-value), preferring those named first. A media
+// type excluded with q=0 is never chosen. Negotiate returns false if none is
+// acceptable, and Respond then responds with a 406 Not Acceptable.
+func Negotiate(accept string) (string, bool) {
+	ranges := parseAccept(accept)
+	var offers []string
+	for _, r := range ranges {
+		if !strings.Contains(r.mediaType, "*") && canRender(r.mediaType) {
+			offers = append(offers, r.mediaType)
+		}
+	}
+	offers = append(offers, renderedMediaTypes...)
+	sort.SliceStable(offers, func(i, j int) bool {
+		return quality(ranges, offers[i]) > quality(ranges, offers[j])
+	})
+	if len(offers) == 0 || quality(ranges, offers[0]) == 0 {
+		return "", false
+	}
+	return offers[0], true
+}
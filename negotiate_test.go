@@ -0,0 +1,58 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestNegotiate() {
+	for accept, expected := range map[string]string{
+		"":                                  "application/json",
+		"*/*":                               "application/json",
+		"application/xml":                   "application/xml",
+		"application/vnd.api.users.v1+json": "application/vnd.api.users.v1+json",
+		"application/vnd.api.users.v1.xml":  "application/vnd.api.users.v1.xml",
+		"application/json;q=0.5, application/x-msgpack": "application/x-msgpack",
+		"text/html,application/xml;q=0.9,*/*;q=0.8":     "text/html",
+		"text/*":                            "text/html",
+		"application/json;q=0, */*":         "application/xml",
+		"image/png, */*;q=0.1":              "application/json",
+		"application/xml, application/json": "application/xml",
+	} {
+		mediaType, ok := Negotiate(accept)
+		suite.True(ok, "expects a media type to be acceptable for "+accept)
+		suite.Equal(expected, mediaType, "expects the preferred media type for "+accept)
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestNegotiateNotAcceptable() {
+	for _, accept := range []string{"text/plain", "image/*", "*/*;q=0", "application/json;q=2"} {
+		_, ok := Negotiate(accept)
+		suite.False(ok, "expects no media type to be acceptable for "+accept)
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestNegotiateCodec() {
+	suite.TestAPI.RegisterCodec("application/vnd.test+lines", lineCodec{})
+	mediaType, _ := Negotiate("application/json;q=0.9, application/vnd.test+lines")
+	suite.Equal("application/vnd.test+lines", mediaType, "expects media types with a codec to be acceptable")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondNotAcceptable() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Accept", "text/plain")
+	Respond(rw, r, http.StatusOK, map[string]string{"name": "test"})
+	suite.Equal(http.StatusNotAcceptable, rw.Code, "expects a 406")
+	suite.Equal(problemContentType, rw.Header().Get("Content-Type"), "expects a problem")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondNegotiated() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Accept", "application/json;q=0.5, application/msgpack")
+	Respond(rw, r, http.StatusOK, map[string]string{"name": "test"})
+	suite.Equal("application/msgpack", rw.Header().Get("Content-Type"), "expects the preferred media type")
+	suite.Equal("Accept", rw.Header().Get("Vary"), "expects the response to vary by Accept")
+	suite.Equal([]byte{0x81, 0xa4, 'n', 'a', 'm', 'e', 0xa4, 't', 'e', 's', 't'}, rw.Body.Bytes(), "expects the body to be encoded as MessagePack")
+}
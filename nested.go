@@ -0,0 +1,124 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// NestedParams returns the params of the request, from the query, body, and
+// path (with the same precedence as Params), keeping their structure, in the
+// shape returned by BodyJSON. Query and form params with bracketed keys, as
+// sent by Rack and PHP style clients, are parsed into nested maps, e.g.
+// filter[status]=open&filter[owner]=me becomes
+//
+//	{"filter": {"status": "open", "owner": "me"}}
+//
+// and keys ending in [] (e.g. tags[]=a&tags[]=b) into lists. Other params
+// given more than once become lists too. Malformed keys (e.g. a[b or
+// a[][b]) are kept as they are. JSON, XML, MessagePack, and YAML bodies are
+// used as decoded by BodyJSON, BodyXML, BodyMsgPack, and BodyYAML, and
+// bodies with a registered Codec (see RegisterCodec) as decoded by BodyCodec.
+func NestedParams(r *http.Request) map[string]interface{} {
+	params := nestedValues(QueryParams(r))
+	var body map[string]interface{}
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case r.Method == "GET":
+	case hasCodec(contentType):
+		body, _ = BodyCodec(r)
+	case isJSONContentType(contentType):
+		body, _ = BodyJSON(r)
+	case isXMLContentType(contentType):
+		body, _ = BodyXML(r)
+	case isMsgPackContentType(contentType):
+		body, _ = BodyMsgPack(r)
+	case isYAMLContentType(contentType):
+		body, _ = BodyYAML(r)
+	default:
+		body = nestedValues(BodyParams(r))
+	}
+	for k, v := range body {
+		params[k] = v
+	}
+	for k, v := range mux.Vars(r) {
+		params[k] = v
+	}
+	return params
+}
+
+// nestedValues parses the bracketed keys of the values into nested maps.
+// Keys are handled in order, so when keys conflict (e.g. a=1&a[b]=2), the
+// result does not depend on map order.
+func nestedValues(values url.Values) map[string]interface{} {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := map[string]interface{}{}
+	for _, k := range keys {
+		path := parseNestedKey(k)
+		v := paramValue(values[k])
+		if path[len(path)-1] == "" {
+			path = path[:len(path)-1]
+			list := make([]interface{}, len(values[k]))
+			for i, s := range values[k] {
+				list[i] = s
+			}
+			v = list
+		}
+		node := params
+		for _, segment := range path[:len(path)-1] {
+			child, ok := node[segment].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node[segment] = child
+			}
+			node = child
+		}
+		node[path[len(path)-1]] = v
+	}
+	return params
+}
+
+// parseNestedKey splits a bracketed key into its segments, e.g.
+// filter[status] into filter and status, and tags[] into tags and an empty
+// segment. Malformed keys are returned as a single segment.
+func parseNestedKey(key string) []string {
+	i := strings.IndexByte(key, '[')
+	if i <= 0 {
+		return []string{key}
+	}
+	segments := []string{key[:i]}
+	rest := key[i:]
+	for rest != "" {
+		j := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || j < 0 {
+			return []string{key}
+		}
+		segments = append(segments, rest[1:j])
+		rest = rest[j+1:]
+	}
+	for _, s := range segments[1 : len(segments)-1] {
+		if s == "" {
+			return []string{key}
+		}
+	}
+	return segments
+}
+
+// paramValue returns a single value as a string, and several as a list.
+func paramValue(values []string) interface{} {
+	if len(values) == 1 {
+		return values[0]
+	}
+	list := make([]interface{}, len(values))
+	for i, v := range values {
+		list[i] = v
+	}
+	return list
+}
@@ -0,0 +1,46 @@
+package hyperdrive
+
+import (
+	"net/http/httptest"
+	"strings"
+)
+
+func (suite *HyperdriveTestSuite) TestNestedParamsQuery() {
+	r := httptest.NewRequest("GET", "/test?filter[status]=open&filter[owner]=me&tags[]=a&tags[]=b&page=2", nil)
+	suite.Equal(map[string]interface{}{
+		"filter": map[string]interface{}{"status": "open", "owner": "me"},
+		"tags":   []interface{}{"a", "b"},
+		"page":   "2",
+	}, NestedParams(r), "expects bracketed keys to be nested")
+}
+
+func (suite *HyperdriveTestSuite) TestNestedParamsForm() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader("user[name]=test&user[address][city]=Austin&roles[]=admin"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	suite.Equal(map[string]interface{}{
+		"user":  map[string]interface{}{"name": "test", "address": map[string]interface{}{"city": "Austin"}},
+		"roles": []interface{}{"admin"},
+	}, NestedParams(r), "expects bracketed form keys to be nested")
+}
+
+func (suite *HyperdriveTestSuite) TestNestedParamsJSON() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(`{"user":{"name":"test"}}`))
+	r.Header.Set("Content-Type", "application/json")
+	suite.Equal(map[string]interface{}{"user": map[string]interface{}{"name": "test"}}, NestedParams(r), "expects the decoded JSON body")
+}
+
+func (suite *HyperdriveTestSuite) TestParseNestedKey() {
+	suite.Equal([]string{"filter", "status"}, parseNestedKey("filter[status]"), "expects the segments")
+	suite.Equal([]string{"tags", ""}, parseNestedKey("tags[]"), "expects an empty last segment for lists")
+	suite.Equal([]string{"a[b"}, parseNestedKey("a[b"), "expects malformed keys to be kept")
+	suite.Equal([]string{"a[][b]"}, parseNestedKey("a[][b]"), "expects lists of objects to be kept")
+	suite.Equal([]string{"[a]"}, parseNestedKey("[a]"), "expects keys without a name to be kept")
+}
+
+func (suite *HyperdriveTestSuite) TestPermitNestedForm() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader("user[name]=test&user[admin]=true"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	p := Permit(r, "user.name")
+	suite.Equal(map[string]interface{}{"user": map[string]interface{}{"name": "test"}}, p.Values, "expects nested form params to be permitted")
+	suite.Equal([]string{"user.admin"}, p.Rejected, "expects nested form params to be rejected")
+}
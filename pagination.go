@@ -0,0 +1,102 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TotalCountHeader is the response header Paginate sets to the total number
+// of items in a list.
+const TotalCountHeader = "X-Total-Count"
+
+// PaginationEnvelope is the body Paginate returns when PAGINATION_STYLE is
+// envelope or both: the items of the page requested, as Data, and where it
+// is in the list, as Pagination.
+type PaginationEnvelope struct {
+	Data       interface{}    `json:"data" xml:"data"`
+	Pagination PaginationInfo `json:"pagination" xml:"pagination"`
+}
+
+// PaginationInfo describes a page of a list: its number, its size, the
+// total number of items and pages in the list, and the URLs of the first,
+// prev, next and last pages, by rel (see PageLinks).
+type PaginationInfo struct {
+	Page       int               `json:"page" xml:"page"`
+	PerPage    int               `json:"per_page" xml:"per_page"`
+	Total      int               `json:"total" xml:"total"`
+	TotalPages int               `json:"total_pages" xml:"total_pages"`
+	Links      map[string]string `json:"links" xml:"-"`
+}
+
+// Paginate sets up the response to a request for a page of a list, as parsed
+// by ListParams, given the items of the page and the total number of items
+// in the list, and returns the body to respond with. How the page is
+// described depends on PAGINATION_STYLE:
+//
+//   - headers (the default) sets a Link header (see LinkHeader) to the
+//     first, prev, next and last pages, and X-Total-Count to the total, and
+//     returns the items as they are.
+//   - envelope returns the items in a PaginationEnvelope.
+//   - both does both.
+//
+// e.g.
+//
+//	q, err := hyperdrive.ListParams(e, r)
+//	if err != nil {
+//		hyperdrive.WriteError(rw, err)
+//		return
+//	}
+//	users, total := e.store.List(q.Offset(), q.PerPage)
+//	hyperdrive.Respond(rw, r, http.StatusOK, hyperdrive.Paginate(rw, r, q, total, users))
+func Paginate(rw http.ResponseWriter, r *http.Request, q ListQuery, total int, items interface{}) interface{} {
+	links := PageLinks(r, q, total)
+	if conf.PaginationStyle != "envelope" {
+		rw.Header().Add("Link", LinkHeader(links))
+		rw.Header().Set(TotalCountHeader, strconv.Itoa(total))
+	}
+	if conf.PaginationStyle == "headers" {
+		return items
+	}
+	return PaginationEnvelope{Data: items, Pagination: PaginationInfo{
+		Page:       q.Page,
+		PerPage:    q.PerPage,
+		Total:      total,
+		TotalPages: totalPages(q, total),
+		Links:      links,
+	}}
+}
+
+// LinkHeader returns the value of an RFC 5988 Link header, with a link to
+// each of the URLs, by rel, ordered as first, prev, next and last, e.g.
+// </users?page=1&per_page=25>; rel="first", </users?page=3&per_page=25>;
+// rel="next". Other rels follow them, ordered by name.
+func LinkHeader(links map[string]string) string {
+	values := make([]string, 0, len(links))
+	for _, rel := range pageRels {
+		if href, ok := links[rel]; ok {
+			values = append(values, "<"+href+`>; rel="`+rel+`"`)
+		}
+	}
+	others := make([]string, 0, len(links))
+	for rel := range links {
+		if !contains(pageRels, rel) {
+			others = append(others, rel)
+		}
+	}
+	sort.Strings(others)
+	for _, rel := range others {
+		values = append(values, "<"+links[rel]+`>; rel="`+rel+`"`)
+	}
+	return strings.Join(values, ", ")
+}
+
+// totalPages returns the number of pages of a list, of which there is
+// always at least one, as in PageLinks.
+func totalPages(q ListQuery, total int) int {
+	if q.PerPage <= 0 || total <= q.PerPage {
+		return 1
+	}
+	return (total + q.PerPage - 1) / q.PerPage
+}
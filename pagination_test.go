@@ -0,0 +1,51 @@
+package hyperdrive
+
+import (
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestPaginateHeaders() {
+	r := httptest.NewRequest("GET", "/widgets?page=2&per_page=10", nil)
+	rw := httptest.NewRecorder()
+	items := []string{"a", "b"}
+	body := Paginate(rw, r, ListQuery{Page: 2, PerPage: 10}, 35, items)
+	suite.Equal(items, body, "expects the items as they are")
+	suite.Equal("35", rw.Header().Get(TotalCountHeader), "expects the total count")
+	suite.Equal(`</widgets?page=1&per_page=10>; rel="first", </widgets?page=1&per_page=10>; rel="prev", </widgets?page=3&per_page=10>; rel="next", </widgets?page=4&per_page=10>; rel="last"`, rw.Header().Get("Link"), "expects links to the pages")
+}
+
+func (suite *HyperdriveTestSuite) TestPaginateEnvelope() {
+	defer func(c Config) { conf = c }(conf)
+	conf.PaginationStyle = "envelope"
+	r := httptest.NewRequest("GET", "/widgets", nil)
+	rw := httptest.NewRecorder()
+	body := Paginate(rw, r, ListQuery{Page: 1, PerPage: 25}, 30, []string{"a"})
+	suite.Equal(PaginationEnvelope{Data: []string{"a"}, Pagination: PaginationInfo{
+		Page:       1,
+		PerPage:    25,
+		Total:      30,
+		TotalPages: 2,
+		Links: map[string]string{
+			"first": "/widgets?page=1&per_page=25",
+			"next":  "/widgets?page=2&per_page=25",
+			"last":  "/widgets?page=2&per_page=25",
+		},
+	}}, body, "expects the items in an envelope")
+	suite.Empty(rw.Header().Get("Link"), "expects no Link header")
+	suite.Empty(rw.Header().Get(TotalCountHeader), "expects no total count header")
+}
+
+func (suite *HyperdriveTestSuite) TestPaginateBoth() {
+	defer func(c Config) { conf = c }(conf)
+	conf.PaginationStyle = "both"
+	rw := httptest.NewRecorder()
+	body := Paginate(rw, httptest.NewRequest("GET", "/widgets", nil), ListQuery{Page: 1, PerPage: 25}, 0, []string{})
+	suite.IsType(PaginationEnvelope{}, body, "expects an envelope")
+	suite.Equal(1, body.(PaginationEnvelope).Pagination.TotalPages, "expects a single page of an empty list")
+	suite.Equal("0", rw.Header().Get(TotalCountHeader), "expects the total count")
+	suite.NotEmpty(rw.Header().Get("Link"), "expects a Link header")
+}
+
+func (suite *HyperdriveTestSuite) TestLinkHeader() {
+	suite.Equal(`</a>; rel="next", </b>; rel="alternate", </c>; rel="related"`, LinkHeader(map[string]string{"related": "/c", "next": "/a", "alternate": "/b"}), "expects the page rels first, then the others by name")
+}
@@ -0,0 +1,272 @@
+package hyperdrive
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// lookupParam returns the value of the named param, and where it was found,
+// with the same precedence as Params (see PARAM_PRECEDENCE).
+func lookupParam(r *http.Request, name string) (string, string, bool) {
+	values, in, ok := lookupParamValues(r, name)
+	if !ok {
+		return "", in, false
+	}
+	return values[len(values)-1], in, true
+}
+
+// paramError returns a *ValidationError for the named param.
+func paramError(in string, name string, code string, message string) error {
+	verr := &ValidationError{}
+	if in == InBody {
+		verr.AddBodyError(JSONPointer(name), code, message)
+	} else {
+		verr.AddParamError(in, name, code, message)
+	}
+	return verr
+}
+
+// typeError returns a *ValidationError for the named param, whose value could
+// not be converted to the expected type.
+func typeError(in string, name string, expected string, value string) error {
+	verr := &ValidationError{}
+	verr.AddTypeError(in, name, expected, value)
+	return verr
+}
+
+// requiredParam returns the value of the named param, and where it was
+// found, or an error if it is missing.
+func requiredParam(r *http.Request, name string) (string, string, error) {
+	s, in, ok := lookupParam(r, name)
+	if !ok {
+		return "", in, paramError(in, name, ValidationRequired, fmt.Sprintf("Missing required parameter: %s", name))
+	}
+	return s, in, nil
+}
+
+// typedParam converts the named param into target, a pointer to a value of
+// the requested type.
+func typedParam(r *http.Request, name string, target interface{}) error {
+	s, in, err := requiredParam(r, name)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(target).Elem()
+	if err := convertString(v, s); err != nil {
+		return typeError(in, name, describeType(v.Type()), s)
+	}
+	return nil
+}
+
+// ParamString returns the value of the named param, from the path, body, or
+// query (in the order of precedence of Params, see PARAM_PRECEDENCE). Like
+// the other typed param getters, it returns a *ValidationError, with a single
+// FieldError, if the param is missing (code required) or invalid (code
+// invalid_type), which can be written with WriteValidationError.
+func ParamString(r *http.Request, name string) (string, error) {
+	var v string
+	err := typedParam(r, name, &v)
+	return v, err
+}
+
+// ParamInt returns the value of the named param as an int. See ParamString.
+func ParamInt(r *http.Request, name string) (int, error) {
+	var v int
+	err := typedParam(r, name, &v)
+	return v, err
+}
+
+// ParamInt64 returns the value of the named param as an int64. See
+// ParamString.
+func ParamInt64(r *http.Request, name string) (int64, error) {
+	var v int64
+	err := typedParam(r, name, &v)
+	return v, err
+}
+
+// ParamFloat returns the value of the named param as a float64. See
+// ParamString.
+func ParamFloat(r *http.Request, name string) (float64, error) {
+	var v float64
+	err := typedParam(r, name, &v)
+	return v, err
+}
+
+// ParamBool returns the value of the named param as a bool, accepting the
+// values understood by strconv.ParseBool, e.g. true, false, 1, or 0. See
+// ParamString.
+func ParamBool(r *http.Request, name string) (bool, error) {
+	var v bool
+	err := typedParam(r, name, &v)
+	return v, err
+}
+
+// ParamDuration returns the value of the named param as a time.Duration,
+// e.g. 1m30s. See ParamString.
+func ParamDuration(r *http.Request, name string) (time.Duration, error) {
+	var v time.Duration
+	err := typedParam(r, name, &v)
+	return v, err
+}
+
+// ParamTime returns the value of the named param as a time.Time, parsed with
+// the given layout, e.g. time.RFC3339, or "2006-01-02". See ParamString.
+func ParamTime(r *http.Request, name string, layout string) (time.Time, error) {
+	s, in, err := requiredParam(r, name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, typeError(in, name, "a time, formatted as "+layout, s)
+	}
+	return t, nil
+}
+
+// ParamUUID returns the value of the named param, if it is a UUID, e.g.
+// 0f8fad5b-d9cb-469f-a165-70867728950e, in lower case. See ParamString.
+func ParamUUID(r *http.Request, name string) (string, error) {
+	s, in, err := requiredParam(r, name)
+	if err != nil {
+		return "", err
+	}
+	if !uuidPattern.MatchString(s) {
+		return "", typeError(in, name, "a UUID", s)
+	}
+	return strings.ToLower(s), nil
+}
+
+// ParamStringDefault returns the value of the named param, or def if it is
+// missing. Like the other typed param getters with a default, it only
+// returns an error if the param is invalid. See ParamString.
+func ParamStringDefault(r *http.Request, name string, def string) (string, error) {
+	if _, _, ok := lookupParam(r, name); !ok {
+		return def, nil
+	}
+	return ParamString(r, name)
+}
+
+// ParamIntDefault returns the value of the named param as an int, or def if
+// it is missing, e.g. ParamIntDefault(r, "page", 1). See ParamStringDefault.
+func ParamIntDefault(r *http.Request, name string, def int) (int, error) {
+	if _, _, ok := lookupParam(r, name); !ok {
+		return def, nil
+	}
+	return ParamInt(r, name)
+}
+
+// ParamInt64Default returns the value of the named param as an int64, or def
+// if it is missing. See ParamStringDefault.
+func ParamInt64Default(r *http.Request, name string, def int64) (int64, error) {
+	if _, _, ok := lookupParam(r, name); !ok {
+		return def, nil
+	}
+	return ParamInt64(r, name)
+}
+
+// ParamFloatDefault returns the value of the named param as a float64, or
+// def if it is missing. See ParamStringDefault.
+func ParamFloatDefault(r *http.Request, name string, def float64) (float64, error) {
+	if _, _, ok := lookupParam(r, name); !ok {
+		return def, nil
+	}
+	return ParamFloat(r, name)
+}
+
+// ParamBoolDefault returns the value of the named param as a bool, or def if
+// it is missing. See ParamStringDefault.
+func ParamBoolDefault(r *http.Request, name string, def bool) (bool, error) {
+	if _, _, ok := lookupParam(r, name); !ok {
+		return def, nil
+	}
+	return ParamBool(r, name)
+}
+
+// ParamDurationDefault returns the value of the named param as a
+// time.Duration, or def if it is missing. See ParamStringDefault.
+func ParamDurationDefault(r *http.Request, name string, def time.Duration) (time.Duration, error) {
+	if _, _, ok := lookupParam(r, name); !ok {
+		return def, nil
+	}
+	return ParamDuration(r, name)
+}
+
+// lookupParamValues returns every value of the named param, and where they
+// were found, with the same precedence as Params.
+func lookupParamValues(r *http.Request, name string) ([]string, string, bool) {
+	for _, source := range paramPrecedence(nil) {
+		var values []string
+		switch source {
+		case SourcePath:
+			if v, ok := mux.Vars(r)[name]; ok {
+				values = []string{v}
+			}
+		case SourceBody:
+			values = BodyParams(r)[name]
+		case SourceQuery:
+			values = r.URL.Query()[name]
+		}
+		if len(values) > 0 {
+			return values, source, true
+		}
+	}
+	return nil, InQuery, false
+}
+
+// splitParamValues splits each value on any of the PARAM_LIST_SEPARATORS
+// (default: ","), trimming spaces, and dropping empty items.
+func splitParamValues(values []string) []string {
+	items := []string{}
+	for _, v := range values {
+		for _, item := range strings.FieldsFunc(v, func(c rune) bool { return strings.ContainsRune(conf.ParamListSeparators, c) }) {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// ParamList returns the values of the named param as a list, whether the
+// client repeated the param (?id=1&id=2&id=3), separated the values
+// (?id=1,2,3, with any of the PARAM_LIST_SEPARATORS, default: ","), or both,
+// so collection filters behave the same regardless of client style. A
+// missing param is an empty list.
+func ParamList(r *http.Request, name string) []string {
+	values, _, _ := lookupParamValues(r, name)
+	return splitParamValues(values)
+}
+
+// ParamIntList returns the values of the named param as a list of ints. See
+// ParamList and ParamListOf.
+func ParamIntList(r *http.Request, name string) ([]int, error) {
+	var v []int
+	err := ParamListOf(r, name, &v)
+	return v, err
+}
+
+// ParamListOf converts the values of the named param (see ParamList) into
+// target, a pointer to a slice of any type supported by Bind, e.g.
+// *[]time.Duration. If any value can not be converted, it returns a
+// *ValidationError, which can be written with WriteValidationError.
+func ParamListOf(r *http.Request, name string, target interface{}) error {
+	values, in, _ := lookupParamValues(r, name)
+	items := splitParamValues(values)
+	v := reflect.ValueOf(target).Elem()
+	list := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := convertString(list.Index(i), item); err != nil {
+			return typeError(in, name, describeType(v.Type()), item)
+		}
+	}
+	v.Set(list)
+	return nil
+}
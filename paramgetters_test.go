@@ -0,0 +1,87 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestTypedParams() {
+	r := httptest.NewRequest("GET", "/test?id=42&ratio=0.5&active=true&since=2017-03-27&ttl=1m&key=0F8FAD5B-D9CB-469F-A165-70867728950E", nil)
+	id, err := ParamInt(r, "id")
+	suite.Nil(err, "expects no error")
+	suite.Equal(42, id, "expects an int")
+	ratio, _ := ParamFloat(r, "ratio")
+	suite.Equal(0.5, ratio, "expects a float")
+	active, _ := ParamBool(r, "active")
+	suite.True(active, "expects a bool")
+	since, _ := ParamTime(r, "since", "2006-01-02")
+	suite.Equal(time.Date(2017, 3, 27, 0, 0, 0, 0, time.UTC), since, "expects a time")
+	ttl, _ := ParamDuration(r, "ttl")
+	suite.Equal(time.Minute, ttl, "expects a duration")
+	key, _ := ParamUUID(r, "key")
+	suite.Equal("0f8fad5b-d9cb-469f-a165-70867728950e", key, "expects a lower case UUID")
+}
+
+func (suite *HyperdriveTestSuite) TestTypedParamsDefaults() {
+	r := httptest.NewRequest("GET", "/test?page=3&active=maybe", nil)
+	page, err := ParamIntDefault(r, "page", 1)
+	suite.Nil(err, "expects no error")
+	suite.Equal(3, page, "expects the given value")
+	perPage, err := ParamIntDefault(r, "per_page", 25)
+	suite.Nil(err, "expects no error")
+	suite.Equal(25, perPage, "expects the default when the param is missing")
+	sort, _ := ParamStringDefault(r, "sort", "name")
+	suite.Equal("name", sort, "expects the default string")
+	ttl, _ := ParamDurationDefault(r, "ttl", time.Minute)
+	suite.Equal(time.Minute, ttl, "expects the default duration")
+	_, err = ParamBoolDefault(r, "active", false)
+	suite.Error(err, "expects an error when the param is invalid")
+}
+
+func (suite *HyperdriveTestSuite) TestParamList() {
+	separated := httptest.NewRequest("GET", "/test?id=1,2,3", nil)
+	repeated := httptest.NewRequest("GET", "/test?id=1&id=2&id=3", nil)
+	mixed := httptest.NewRequest("GET", "/test?id=1,+2&id=3,", nil)
+	for _, r := range []*http.Request{separated, repeated, mixed} {
+		suite.Equal([]string{"1", "2", "3"}, ParamList(r, "id"), "expects the same list regardless of client style")
+		ids, err := ParamIntList(r, "id")
+		suite.Nil(err, "expects no error")
+		suite.Equal([]int{1, 2, 3}, ids, "expects a list of ints")
+	}
+	suite.Equal([]string{}, ParamList(separated, "missing"), "expects an empty list when the param is missing")
+}
+
+func (suite *HyperdriveTestSuite) TestParamListOf() {
+	defer func(c Config) { conf = c }(conf)
+	conf.ParamListSeparators = ",|"
+	r := httptest.NewRequest("GET", "/test?ttl=1m|30s&id=1,a", nil)
+	var ttls []time.Duration
+	suite.Nil(ParamListOf(r, "ttl", &ttls), "expects no error")
+	suite.Equal([]time.Duration{time.Minute, 30 * time.Second}, ttls, "expects the configured separators to be used")
+	_, err := ParamIntList(r, "id")
+	suite.Equal([]FieldError{{In: InQuery, Parameter: "id", Code: ValidationInvalidType, Message: "id must be a list, where each item is an integer", Expected: "a list, where each item is an integer", Value: "a"}}, err.(*ValidationError).Errors, "expects an invalid_type error")
+}
+
+func (suite *HyperdriveTestSuite) TestTypedParamsErrors() {
+	r := httptest.NewRequest("GET", "/test?id=abc&key=123", nil)
+	_, err := ParamInt(r, "id")
+	suite.Equal([]FieldError{{In: InQuery, Parameter: "id", Code: ValidationInvalidType, Message: "id must be an integer", Expected: "an integer", Value: "abc"}}, err.(*ValidationError).Errors, "expects an invalid_type error")
+	_, err = ParamInt(r, "limit")
+	suite.Equal([]FieldError{{In: InQuery, Parameter: "limit", Code: ValidationRequired, Message: "Missing required parameter: limit"}}, err.(*ValidationError).Errors, "expects a required error")
+	_, err = ParamUUID(r, "key")
+	suite.Equal(ValidationInvalidType, err.(*ValidationError).Errors[0].Code, "expects an invalid_type error")
+}
+
+func (suite *HyperdriveTestSuite) TestTypedParamsSources() {
+	suite.TestAPI.Router.Handle("/users/{id}", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id, _ := ParamInt(r, "id")
+		suite.Equal(7, id, "expects the path to take precedence")
+		_, err := ParamBool(r, "notify")
+		suite.Equal(FieldError{In: InBody, Pointer: "/notify", Code: ValidationInvalidType, Message: "notify must be a boolean", Expected: "a boolean", Value: "maybe"}, err.(*ValidationError).Errors[0], "expects errors in the body to be reported with a pointer")
+	}))
+	r := httptest.NewRequest("POST", "/users/7?id=8", strings.NewReader(`{"notify":"maybe"}`))
+	r.Header.Set("Content-Type", "application/json")
+	suite.TestAPI.Router.ServeHTTP(httptest.NewRecorder(), r)
+}
@@ -1,9 +1,16 @@
 package hyperdrive
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"mime"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
@@ -22,11 +29,218 @@ func QueryParams(r *http.Request) url.Values {
 // BodyParams deserializes the input, and extracts the values from the request
 // body. It returns a url.Values object (essentially map[string][]string). If
 // the request method is GET, an empty url.Values is returned.
+//
+// Form encoded bodies are parsed as by http.Request.ParseForm. JSON bodies
+// (with a Content-Type of application/json, or a json media type, e.g.
+// application/vnd.api.users.v1.json) must be an object, whose top-level
+// fields are flattened: strings, numbers and booleans become a single value,
+// arrays of them one value per element, and nested objects and arrays their
+// JSON encoding. Use BodyJSON to work with nested structures. XML bodies
+// (with a Content-Type of application/xml, text/xml, or an xml media type)
+// are decoded by BodyXML, MessagePack bodies (with a Content-Type of
+// application/msgpack, or a msgpack media type) by BodyMsgPack, and YAML
+// bodies (with a Content-Type of application/yaml, or a yaml media type) by
+// BodyYAML, and all of them are flattened in the same way, as are bodies
+// decoded by a Codec registered for their Content-Type (see RegisterCodec),
+// which take precedence.
 func BodyParams(r *http.Request) url.Values {
-	if r.Method != "GET" {
+	if r.Method == "GET" {
 		return url.Values{}
 	}
-	return url.Values{}
+	if hasCodec(r.Header.Get("Content-Type")) {
+		body, err := BodyCodec(r)
+		if err != nil {
+			return url.Values{}
+		}
+		return flattenJSON(body)
+	}
+	if isJSONContentType(r.Header.Get("Content-Type")) {
+		body, err := BodyJSON(r)
+		if err != nil {
+			return url.Values{}
+		}
+		return flattenJSON(body)
+	}
+	if isXMLContentType(r.Header.Get("Content-Type")) {
+		body, err := BodyXML(r)
+		if err != nil {
+			return url.Values{}
+		}
+		return flattenJSON(body)
+	}
+	if isMsgPackContentType(r.Header.Get("Content-Type")) {
+		body, err := BodyMsgPack(r)
+		if err != nil {
+			return url.Values{}
+		}
+		return flattenJSON(body)
+	}
+	if isYAMLContentType(r.Header.Get("Content-Type")) {
+		body, err := BodyYAML(r)
+		if err != nil {
+			return url.Values{}
+		}
+		return flattenJSON(body)
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm == nil {
+		return url.Values{}
+	}
+	return r.PostForm
+}
+
+// BodyJSON decodes the JSON object in the request body, so handlers can work
+// with nested structures. Numbers are decoded as json.Number, so large
+// integers do not lose precision. The body is left unread, so BodyJSON (and
+// BodyParams) can be called more than once.
+func BodyJSON(r *http.Request) (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+	b, err := RawBody(r)
+	if err != nil || len(bytes.TrimSpace(b)) == 0 {
+		return body, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return map[string]interface{}{}, err
+	}
+	return body, nil
+}
+
+// BodyXML decodes the XML document in the request body into the same shape
+// as BodyJSON, so XML clients can be served by the same handlers: the child
+// elements and attributes of the root element become fields, elements
+// repeated under the same name become arrays, and elements with children or
+// attributes become nested objects (holding their text, if any, in a #text
+// field). Other values are strings. The body is left unread, so BodyXML (and
+// BodyParams) can be called more than once.
+func BodyXML(r *http.Request) (map[string]interface{}, error) {
+	b, err := RawBody(r)
+	if err != nil || len(bytes.TrimSpace(b)) == 0 {
+		return map[string]interface{}{}, err
+	}
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return map[string]interface{}{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			v, err := decodeXMLElement(dec, start)
+			if err != nil {
+				return map[string]interface{}{}, err
+			}
+			if body, ok := v.(map[string]interface{}); ok {
+				return body, nil
+			}
+			return map[string]interface{}{}, nil
+		}
+	}
+}
+
+// decodeXMLElement decodes the element opened by start, as described by
+// BodyXML.
+func decodeXMLElement(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	fields := map[string]interface{}{}
+	add := func(name string, v interface{}) {
+		switch existing := fields[name].(type) {
+		case nil:
+			fields[name] = v
+		case []interface{}:
+			fields[name] = append(existing, v)
+		default:
+			fields[name] = []interface{}{existing, v}
+		}
+	}
+	for _, attr := range start.Attr {
+		add(attr.Name.Local, attr.Value)
+	}
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			v, err := decodeXMLElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			add(t.Name.Local, v)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if len(fields) == 0 {
+				return text.String(), nil
+			}
+			if s := strings.TrimSpace(text.String()); s != "" {
+				fields["#text"] = s
+			}
+			return fields, nil
+		}
+	}
+}
+
+// isXMLContentType returns true for application/xml, text/xml, and media
+// types with an xml suffix (+xml) or extension (.xml).
+func isXMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml") || strings.HasSuffix(mediaType, ".xml")
+}
+
+// isJSONContentType returns true for application/json, and media types with
+// a json suffix (+json) or extension (.json).
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, ".json")
+}
+
+// flattenJSON converts the top-level fields of a decoded JSON object to
+// url.Values. Null fields are omitted.
+func flattenJSON(body map[string]interface{}) url.Values {
+	params := url.Values{}
+	for k, v := range body {
+		if values, ok := v.([]interface{}); ok && scalars(values) {
+			for _, e := range values {
+				params.Add(k, jsonScalar(e))
+			}
+			continue
+		}
+		if v != nil {
+			params.Add(k, jsonScalar(v))
+		}
+	}
+	return params
+}
+
+func scalars(values []interface{}) bool {
+	for _, v := range values {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}, nil:
+			return false
+		}
+	}
+	return true
+}
+
+// jsonScalar formats a decoded JSON value as a param value.
+func jsonScalar(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
 }
 
 // PathParams extracts the values from the request path which match named
@@ -40,28 +254,78 @@ func PathParams(r *http.Request) url.Values {
 	return params
 }
 
-// Params extracts the param values from all sources: query, body, and path -- in
-// that order. Each subsequent source will overwrite values with the same key, to
-// ensure API client intent is maintained in a consistent way.
-func Params(r *http.Request) url.Values {
-	var params = QueryParams(r)
+// ParamPrecedence is the order in which the sources of params (SourcePath,
+// SourceQuery, and SourceBody) are tried: when a key is in more than one
+// source, the first of them wins.
+type ParamPrecedence []string
 
-	for k, values := range BodyParams(r) {
-		for _, v := range values {
-			params.Set(k, v)
-		}
+// The orders of precedence for Params.
+var (
+	PathFirst  = ParamPrecedence{SourcePath, SourceBody, SourceQuery}
+	BodyFirst  = ParamPrecedence{SourceBody, SourcePath, SourceQuery}
+	QueryFirst = ParamPrecedence{SourceQuery, SourcePath, SourceBody}
+)
+
+// parseParamPrecedence parses a comma separated list of every source, e.g.
+// "path,body,query".
+func parseParamPrecedence(s string) (ParamPrecedence, error) {
+	p := ParamPrecedence(strings.Split(strings.Replace(s, " ", "", -1), ","))
+	sorted := append([]string{}, p...)
+	sort.Strings(sorted)
+	if strings.Join(sorted, ",") != "body,path,query" {
+		return nil, fmt.Errorf("must list path, query, and body once each, got %q", s)
 	}
+	return p, nil
+}
 
-	for k, v := range mux.Vars(r) {
-		params.Set(k, v)
+// paramPrecedence returns the first of the given orders of precedence, or
+// the one configured by PARAM_PRECEDENCE (default: path,body,query).
+func paramPrecedence(precedence []ParamPrecedence) ParamPrecedence {
+	if len(precedence) > 0 {
+		return precedence[0]
+	}
+	if p, err := parseParamPrecedence(conf.ParamPrecedence); err == nil {
+		return p
 	}
+	return PathFirst
+}
 
+// ParamsBySource returns the params of each source, keyed by SourcePath,
+// SourceQuery, and SourceBody, so handlers can tell where each value came
+// from.
+func ParamsBySource(r *http.Request) map[string]url.Values {
+	return map[string]url.Values{
+		SourcePath:  PathParams(r),
+		SourceQuery: QueryParams(r),
+		SourceBody:  BodyParams(r),
+	}
+}
+
+// Params extracts the param values from all sources: path, body, and query.
+// When a key is in more than one source, the values of the source which comes
+// first in the order of precedence given (e.g. Params(r, QueryFirst)), or
+// configured by PARAM_PRECEDENCE (default: path,body,query, see PathFirst),
+// are used, to ensure API client intent is maintained in a consistent way.
+func Params(r *http.Request, precedence ...ParamPrecedence) url.Values {
+	var (
+		params  = url.Values{}
+		sources = ParamsBySource(r)
+		order   = paramPrecedence(precedence)
+	)
+	for i := len(order) - 1; i >= 0; i-- {
+		for k, values := range sources[order[i]] {
+			params[k] = values
+		}
+	}
 	return params
 }
 
-// GetParams returns all allowed request params. It returns an error on
-// the first required param is not present. GetParams is intended to be used
-// in your method handlers in a given endpoint.
+// GetParams returns all allowed request params. Missing params with a
+// default, given by the d option of their tag (e.g. `param:"per_page;d=25"`),
+// are set to it. If any required params are not present, it returns a
+// *ValidationError, with a FieldError for each of them, which can be written
+// with WriteValidationError. GetParams is intended to be used in your method
+// handlers in a given endpoint.
 func GetParams(e Endpointer, r *http.Request) (url.Values, error) {
 	pp := parseEndpoint(e)
 	p := Params(r)
@@ -70,11 +334,28 @@ func GetParams(e Endpointer, r *http.Request) (url.Values, error) {
 			p.Del(k)
 		}
 	}
+	for k, v := range pp.Defaults(r.Method) {
+		if _, ok := p[k]; !ok {
+			p.Set(k, v)
+		}
+	}
 
-	for _, required := range pp.Required(r.Method) {
-		if k, ok := p[required]; !ok {
-			return p, fmt.Errorf("Missing required parameter: %s", k)
+	verr := &ValidationError{}
+	required := pp.Required(r.Method)
+	sort.Strings(required)
+	for _, k := range required {
+		if _, ok := p[k]; ok {
+			continue
 		}
+		msg := fmt.Sprintf("Missing required parameter: %s", k)
+		if r.Method == "GET" {
+			verr.AddParamError(InQuery, k, ValidationRequired, msg)
+		} else {
+			verr.AddBodyError(JSONPointer(k), ValidationRequired, msg)
+		}
+	}
+	if !verr.Empty() {
+		return p, verr
 	}
 	return p, nil
 }
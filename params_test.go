@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 )
 
 func (suite *HyperdriveTestSuite) TestQueryParamsGet() {
@@ -30,6 +31,57 @@ func (suite *HyperdriveTestSuite) TestBodyParamsNonGetValues() {
 	suite.Equal(url.Values{}, BodyParams(suite.TestPostRequest), "returns populated url.Values")
 }
 
+func (suite *HyperdriveTestSuite) TestBodyParamsForm() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader("name=test&tags=a&tags=b"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	suite.Equal(url.Values{"name": []string{"test"}, "tags": []string{"a", "b"}}, BodyParams(r), "returns the form values")
+}
+
+func (suite *HyperdriveTestSuite) TestBodyParamsJSON() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(`{"name":"test","count":12345678901,"active":true,"tags":["a","b"],"address":{"city":"Austin"},"deleted":null}`))
+	r.Header.Set("Content-Type", "application/vnd.api.test.v1.json; charset=utf-8")
+	suite.Equal(url.Values{
+		"name":    []string{"test"},
+		"count":   []string{"12345678901"},
+		"active":  []string{"true"},
+		"tags":    []string{"a", "b"},
+		"address": []string{`{"city":"Austin"}`},
+	}, BodyParams(r), "returns the flattened top-level fields")
+	body, err := BodyJSON(r)
+	suite.Nil(err, "expects the body to be readable again")
+	suite.Equal(map[string]interface{}{"city": "Austin"}, body["address"], "returns nested structures")
+}
+
+func (suite *HyperdriveTestSuite) TestBodyJSONInvalid() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(`["not","an","object"]`))
+	r.Header.Set("Content-Type", "application/json")
+	_, err := BodyJSON(r)
+	suite.Error(err, "expects an error when the body is not an object")
+	suite.Equal(url.Values{}, BodyParams(r), "returns empty url.Values")
+}
+
+func (suite *HyperdriveTestSuite) TestBodyParamsXML() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(`<?xml version="1.0"?><user id="7"><name>test</name><tags>a</tags><tags>b</tags><address><city>Austin</city></address></user>`))
+	r.Header.Set("Content-Type", "application/vnd.api.test.v1.xml")
+	suite.Equal(url.Values{
+		"id":      []string{"7"},
+		"name":    []string{"test"},
+		"tags":    []string{"a", "b"},
+		"address": []string{`{"city":"Austin"}`},
+	}, BodyParams(r), "returns the flattened top-level elements and attributes")
+	body, err := BodyXML(r)
+	suite.Nil(err, "expects the body to be readable again")
+	suite.Equal(map[string]interface{}{"city": "Austin"}, body["address"], "returns nested elements")
+}
+
+func (suite *HyperdriveTestSuite) TestBodyXMLText() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(`<note><body lang="en">hello</body></note>`))
+	r.Header.Set("Content-Type", "application/xml")
+	body, err := BodyXML(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal(map[string]interface{}{"lang": "en", "#text": "hello"}, body["body"], "returns the text of elements with attributes")
+}
+
 func (suite *HyperdriveTestSuite) TestPathParamsGet() {
 	suite.TestAPI.Router.Handle("/test/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		suite.IsType(url.Values{}, PathParams(suite.TestGetRequest), "expects an instance of url.Values")
@@ -67,6 +119,23 @@ func (suite *HyperdriveTestSuite) TestParamsNonGetValues() {
 	suite.TestAPI.Router.ServeHTTP(httptest.NewRecorder(), suite.TestPostRequest)
 }
 
+func (suite *HyperdriveTestSuite) TestParamsPrecedence() {
+	defer func(c Config) { conf = c }(conf)
+	suite.TestAPI.Router.Handle("/users/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("7", Params(r).Get("id"), "expects the path to win by default")
+		suite.Equal("8", Params(r, QueryFirst).Get("id"), "expects the query to win when it comes first")
+		suite.Equal(map[string]url.Values{
+			SourcePath:  {"id": {"7"}},
+			SourceQuery: {"id": {"8"}},
+			SourceBody:  {},
+		}, ParamsBySource(r), "expects the params of each source")
+		conf.ParamPrecedence = "query,path,body"
+		id, _ := ParamInt(r, "id")
+		suite.Equal(8, id, "expects the configured precedence to be used by the typed getters")
+	}))
+	suite.TestAPI.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/7?id=8", nil))
+}
+
 func (suite *HyperdriveTestSuite) TestGetParams() {
 	params, err := GetParams(suite.TestTaggedEndpoint, suite.TestGetRequest)
 	suite.Equal(url.Values{"id": []string{"1"}}, params, "returns populated url.Values")
@@ -79,6 +148,26 @@ func (suite *HyperdriveTestSuite) TestGetParamsError() {
 	suite.Error(err, "returns populated url.Values")
 }
 
+type PaginatedEndpoint struct {
+	Endpoint
+	Page    int `param:"page;d=1"`
+	PerPage int `param:"per_page;d=25;a=GET"`
+}
+
+func (suite *HyperdriveTestSuite) TestGetParamsDefaults() {
+	e := &PaginatedEndpoint{Endpoint: *NewEndpoint("Paginated", "Paginated Endpoint", "/paginated", "1")}
+	params, err := GetParams(e, httptest.NewRequest("GET", "/paginated?page=3", nil))
+	suite.Nil(err, "expects no error")
+	suite.Equal(url.Values{"page": []string{"3"}, "per_page": []string{"25"}}, params, "expects missing params to be set to their default")
+}
+
 func (suite *HyperdriveTestSuite) TestParameter() {
 	suite.Implements((*Parameter)(nil), new(ID), "is an implementation of Parameter")
 }
+
+func (suite *HyperdriveTestSuite) TestGetParamsValidationError() {
+	_, err := GetParams(suite.TestTaggedEndpoint, suite.TestGetRequestNoParams)
+	verr, ok := err.(*ValidationError)
+	suite.Require().True(ok, "expects a *ValidationError")
+	suite.Equal([]FieldError{{In: InQuery, Parameter: "id", Code: ValidationRequired, Message: "Missing required parameter: id"}}, verr.Errors, "expects the missing param to be reported")
+}
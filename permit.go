@@ -0,0 +1,128 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// LedgerRejectedParams is the Ledger resource counting the params rejected
+// by Permit.
+const LedgerRejectedParams = "rejected_params"
+
+// PermittedParams holds the params of a request allowed by Permit, in the
+// shape returned by BodyJSON: nested objects are maps, and lists (and params
+// given more than once) are []interface{}. Rejected holds the keys of every
+// param which was not allowed, in the dotted notation given to Permit.
+type PermittedParams struct {
+	Values   map[string]interface{}
+	Rejected []string
+}
+
+// Permit returns the params of the request (see NestedParams) whose keys are
+// in the given list, so they can be passed to a model without clients being
+// able to set other fields (i.e. mass assignment). Nested keys of JSON and
+// XML bodies, and of bracketed params, are given in dotted notation:
+// "address" allows the whole address object, while "address.city" only
+// allows its city field. Keys of objects in lists apply to every object,
+// e.g. "items.sku".
+//
+// The keys of params which are not allowed are returned as Rejected, and
+// counted in the request's Ledger as LedgerRejectedParams, so clients sending
+// them can be spotted.
+func Permit(r *http.Request, keys ...string) PermittedParams {
+	p := PermittedParams{Values: map[string]interface{}{}}
+	permitObject(p.Values, NestedParams(r), newPermitTree(keys), "", &p.Rejected)
+	p.Rejected = uniqueSorted(p.Rejected)
+	if len(p.Rejected) > 0 {
+		GetLedger(r).Add(LedgerRejectedParams, int64(len(p.Rejected)))
+	}
+	return p
+}
+
+// uniqueSorted sorts the keys, and removes duplicates, e.g. those rejected in
+// each object of a list.
+func uniqueSorted(keys []string) []string {
+	sort.Strings(keys)
+	var unique []string
+	for i, k := range keys {
+		if i == 0 || k != keys[i-1] {
+			unique = append(unique, k)
+		}
+	}
+	return unique
+}
+
+// permitTree holds the permitted keys, by segment. A nil permitTree permits
+// every key below it.
+type permitTree map[string]permitTree
+
+func newPermitTree(keys []string) permitTree {
+	tree := permitTree{}
+	for _, key := range keys {
+		node := tree
+		segments := strings.Split(key, ".")
+		for i, s := range segments {
+			child, seen := node[s]
+			if seen && child == nil {
+				break
+			}
+			if i == len(segments)-1 {
+				node[s] = nil
+				break
+			}
+			if child == nil {
+				child = permitTree{}
+				node[s] = child
+			}
+			node = child
+		}
+	}
+	return tree
+}
+
+// permitObject copies the fields of src permitted by tree to dst, and adds
+// the keys of the others, prefixed with prefix, to rejected.
+func permitObject(dst map[string]interface{}, src map[string]interface{}, tree permitTree, prefix string, rejected *[]string) {
+	for k, v := range src {
+		child, ok := tree[k]
+		if !ok {
+			*rejected = append(*rejected, prefix+k)
+			continue
+		}
+		if child == nil {
+			dst[k] = v
+			continue
+		}
+		if permitted, ok := permitNested(v, child, prefix+k, rejected); ok {
+			dst[k] = permitted
+		}
+	}
+}
+
+// permitNested returns the permitted fields of an object, or of each object
+// in a list. Other values are rejected, as only some of their fields are
+// permitted.
+func permitNested(v interface{}, tree permitTree, key string, rejected *[]string) (interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		obj := map[string]interface{}{}
+		permitObject(obj, v, tree, key+".", rejected)
+		return obj, true
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			obj, ok := e.(map[string]interface{})
+			if !ok {
+				*rejected = append(*rejected, key)
+				return nil, false
+			}
+			permitted := map[string]interface{}{}
+			permitObject(permitted, obj, tree, key+".", rejected)
+			list = append(list, permitted)
+		}
+		return list, true
+	}
+	*rejected = append(*rejected, key)
+	return nil, false
+}
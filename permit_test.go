@@ -0,0 +1,51 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func (suite *HyperdriveTestSuite) TestPermitJSON() {
+	r := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"test","admin":true,"address":{"city":"Austin","verified":true},"items":[{"sku":"a","price":1},{"sku":"b","price":2}]}`))
+	r.Header.Set("Content-Type", "application/json")
+	p := Permit(r, "name", "email", "address.city", "items.sku")
+	suite.Equal(map[string]interface{}{
+		"name":    "test",
+		"address": map[string]interface{}{"city": "Austin"},
+		"items":   []interface{}{map[string]interface{}{"sku": "a"}, map[string]interface{}{"sku": "b"}},
+	}, p.Values, "expects only the permitted params")
+	suite.Equal([]string{"address.verified", "admin", "items.price"}, p.Rejected, "expects the rejected keys")
+}
+
+func (suite *HyperdriveTestSuite) TestPermitWholeObject() {
+	r := httptest.NewRequest("POST", "/users", strings.NewReader(`{"address":{"city":"Austin","zip":"78701"},"tags":"admin"}`))
+	r.Header.Set("Content-Type", "application/json")
+	p := Permit(r, "address.city", "address", "tags.name")
+	suite.Equal(map[string]interface{}{"address": map[string]interface{}{"city": "Austin", "zip": "78701"}}, p.Values, "expects the whole object to be permitted")
+	suite.Equal([]string{"tags"}, p.Rejected, "expects scalars to be rejected where an object is expected")
+}
+
+func (suite *HyperdriveTestSuite) TestPermitForm() {
+	var p PermittedParams
+	suite.TestAPI.Router.Handle("/users/{id}", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		p = Permit(r, "id", "name", "tags")
+	}))
+	r := httptest.NewRequest("POST", "/users/42", strings.NewReader("name=test&tags=a&tags=b&role=admin"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	suite.TestAPI.Router.ServeHTTP(httptest.NewRecorder(), r)
+	suite.Equal(map[string]interface{}{"id": "42", "name": "test", "tags": []interface{}{"a", "b"}}, p.Values, "expects the permitted form and path params")
+	suite.Equal([]string{"role"}, p.Rejected, "expects the rejected keys")
+}
+
+func (suite *HyperdriveTestSuite) TestPermitLedger() {
+	var ledger *Ledger
+	h := instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		Permit(r, "name")
+		ledger = GetLedger(r)
+	}))
+	r := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"test","admin":true}`))
+	r.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	suite.Equal(map[string]int64{LedgerRejectedParams: 1}, ledger.Counts(), "expects the rejected params to be counted")
+}
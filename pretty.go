@@ -0,0 +1,41 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// PrettyParam is the query param which asks Respond to indent JSON, e.g.
+// ?pretty=true.
+const PrettyParam = "pretty"
+
+// prettyIndent is what each nested value of pretty JSON is indented by.
+const prettyIndent = "  "
+
+// UsesPrettyJSON returns true if Respond indents JSON for the request, so it
+// is readable by humans poking the API in a browser: when its pretty query
+// param is true, or, if it has none, when JSON_PRETTY is true. The values
+// understood by strconv.ParseBool are accepted, and others are ignored, so
+// ?pretty=false turns it off for a request even when JSON_PRETTY is true.
+// Programmatic clients, which do not ask for it, get compact JSON.
+func UsesPrettyJSON(r *http.Request) bool {
+	if pretty, err := strconv.ParseBool(r.URL.Query().Get(PrettyParam)); err == nil {
+		return pretty
+	}
+	return conf.JSONPretty
+}
+
+// indentJSON indents the output of the JSON based encoders returned by
+// GetEncoder, and leaves others as they are.
+func indentJSON(enc ContentEncoder) {
+	switch e := enc.(type) {
+	case JSONEncoder:
+		e.Encoder.SetIndent("", prettyIndent)
+	case HALEncoder:
+		e.Encoder.SetIndent("", prettyIndent)
+	case SirenEncoder:
+		e.Encoder.SetIndent("", prettyIndent)
+	case CollectionJSONEncoder:
+		e.Encoder.SetIndent("", prettyIndent)
+	}
+}
@@ -0,0 +1,39 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestUsesPrettyJSON() {
+	defer func(c Config) { conf = c }(conf)
+	suite.False(UsesPrettyJSON(httptest.NewRequest("GET", "/test", nil)), "expects compact JSON by default")
+	suite.True(UsesPrettyJSON(httptest.NewRequest("GET", "/test?pretty=true", nil)), "expects pretty JSON when asked for")
+	suite.False(UsesPrettyJSON(httptest.NewRequest("GET", "/test?pretty=nope", nil)), "expects invalid values to be ignored")
+
+	conf.JSONPretty = true
+	suite.True(UsesPrettyJSON(httptest.NewRequest("GET", "/test", nil)), "expects pretty JSON when JSON_PRETTY is true")
+	suite.False(UsesPrettyJSON(httptest.NewRequest("GET", "/test?pretty=0", nil)), "expects the query param to turn it off")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondPrettyJSON() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test?pretty=1", nil)
+	r.Header.Set("Accept", "application/json")
+	Respond(rw, r, http.StatusOK, map[string]interface{}{"a": []int{1}})
+	suite.Equal("{\n  \"a\": [\n    1\n  ]\n}\n", rw.Body.String(), "expects indented json")
+
+	rw = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/test?pretty=1", nil)
+	r.Header.Set("Accept", "application/json")
+	Respond(rw, WithCanonicalJSON(r), http.StatusOK, map[string]interface{}{"a": []int{1}})
+	suite.Equal(`{"a":[1]}`+"\n", rw.Body.String(), "expects canonical json to stay compact")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondPrettyHAL() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test?pretty=true", nil)
+	r.Header.Set("Accept", HALContentType)
+	Respond(rw, r, http.StatusOK, NewHALResource(map[string]int{"a": 1}))
+	suite.Equal("{\n  \"a\": 1\n}\n", rw.Body.String(), "expects indented HAL")
+}
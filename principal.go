@@ -0,0 +1,82 @@
+package hyperdrive
+
+import (
+	"context"
+	"net/http"
+)
+
+type principalContextKey struct{}
+
+// The authentication methods set as Principal.Method by hyperdrive's auth
+// middleware.
+const (
+	AuthMethodBasic     = "basic"
+	AuthMethodAPIKey    = "api_key"
+	AuthMethodBearer    = "bearer"
+	AuthMethodSignature = "signature"
+)
+
+// Principal is the client authenticated by an auth middleware, whichever
+// method it used: ID is the user, key owner, token subject, or client ID, and
+// Method is how the client was authenticated (e.g. AuthMethodAPIKey). Custom
+// auth middleware can set a Principal with WithPrincipal, so downstream
+// handlers (and AuthorizeMiddleware) work unchanged. RateLimit and Quota
+// override RATE_LIMIT and QUOTA_LIMIT for the principal, as they do for an
+// APIKey (0 uses the configured default).
+type Principal struct {
+	ID        string
+	Method    string
+	Scopes    []string
+	Roles     []string
+	RateLimit int
+	Quota     int
+}
+
+// HasScopes returns true if the principal has been granted every one of the
+// given scopes.
+func (p *Principal) HasScopes(scopes ...string) bool {
+	for _, s := range scopes {
+		if !contains(p.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasRole returns true if the principal has at least one of the given roles.
+func (p *Principal) HasRole(roles ...string) bool {
+	return containsAny(p.Roles, roles)
+}
+
+// CurrentPrincipal returns the Principal authenticated for the request, and
+// false if the request has not been authenticated. If several auth
+// middleware authenticated the request, the innermost one wins.
+func CurrentPrincipal(r *http.Request) (*Principal, bool) {
+	p, ok := r.Context().Value(principalContextKey{}).(*Principal)
+	return p, ok
+}
+
+// credentialHeaders returns the request headers which carry credentials for
+// hyperdrive's auth methods.
+func credentialHeaders() []string {
+	return []string{"Authorization", "Cookie", conf.APIKeyHeader, conf.SignatureHeader}
+}
+
+// hasCredentials returns true if the request carries credentials for any of
+// hyperdrive's auth methods (an Authorization header, an API key, a
+// signature, or a cookie, e.g. a session), whether or not they have been
+// checked by an auth middleware yet.
+func hasCredentials(r *http.Request) bool {
+	for _, h := range credentialHeaders() {
+		if h != "" && r.Header.Get(h) != "" {
+			return true
+		}
+	}
+	return conf.APIKeyQueryParam != "" && r.URL.Query().Get(conf.APIKeyQueryParam) != ""
+}
+
+// WithPrincipal returns a shallow copy of r, authenticated as the given
+// Principal.
+func WithPrincipal(r *http.Request, p *Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalContextKey{}, p))
+}
@@ -0,0 +1,36 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestWithPrincipal() {
+	_, ok := CurrentPrincipal(suite.TestGetRequest)
+	suite.False(ok, "expects no principal for unauthenticated requests")
+	p := &Principal{ID: "alice", Method: "custom"}
+	r := WithPrincipal(suite.TestGetRequest, p)
+	current, ok := CurrentPrincipal(r)
+	suite.True(ok, "expects the principal to be set")
+	suite.Equal(p, current, "expects the principal to be added to the request context")
+}
+
+func (suite *HyperdriveTestSuite) TestPrincipalHasScopes() {
+	p := &Principal{Scopes: []string{"a", "b"}}
+	suite.True(p.HasScopes("a", "b"), "expects true when every scope is granted")
+	suite.False(p.HasScopes("a", "c"), "expects false when a scope is missing")
+}
+
+func (suite *HyperdriveTestSuite) TestPrincipalHasRole() {
+	p := &Principal{Roles: []string{"admin"}}
+	suite.True(p.HasRole("owner", "admin"), "expects true when one of the roles is granted")
+	suite.False(p.HasRole("owner"), "expects false when none of the roles are granted")
+}
+
+func (suite *HyperdriveTestSuite) TestAuthorizeMiddlewareCustomPrincipal() {
+	e := &ScopedEndpoint{Endpoint: *NewEndpoint("Widgets", "Widgets", "/widgets", "1")}
+	rw := httptest.NewRecorder()
+	r := WithPrincipal(httptest.NewRequest("GET", "/widgets", nil), &Principal{ID: "alice", Scopes: []string{"widgets:read"}})
+	suite.TestAPI.AuthorizeMiddleware(e)(NewMethodHandler(e)).ServeHTTP(rw, r)
+	suite.Equal(http.StatusOK, rw.Code, "expects a custom principal with the required scopes to be authorized")
+}
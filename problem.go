@@ -0,0 +1,126 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/handlers"
+)
+
+// problemContentType is the media type of Problem Details for HTTP APIs, as
+// defined by RFC 7807.
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object, the body of every error
+// response hyperdrive writes (see RespondProblem). Extensions holds any
+// other members, e.g. the errors of a ValidationError, which are written
+// alongside the standard ones, but can not replace them.
+type Problem struct {
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	Status     int                    `json:"status"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Extensions map[string]interface{} `json:"-" xml:"-"`
+}
+
+// NewProblem returns a Problem of type about:blank for the given status,
+// with its status text as the title, and the given detail.
+func NewProblem(status int, detail string) Problem {
+	return Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// problemMembers are the standard members of a Problem.
+type problemMembers Problem
+
+// MarshalJSON encodes the problem, with its extension members.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(problemMembers(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return b, err
+	}
+	members := map[string]interface{}{}
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	var standard map[string]interface{}
+	if err := json.Unmarshal(b, &standard); err != nil {
+		return nil, err
+	}
+	for k, v := range standard {
+		members[k] = v
+	}
+	return json.Marshal(members)
+}
+
+// UnmarshalJSON decodes a problem, keeping members other than the standard
+// ones in Extensions.
+func (p *Problem) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, (*problemMembers)(p)); err != nil {
+		return err
+	}
+	var members map[string]interface{}
+	if err := json.Unmarshal(b, &members); err != nil {
+		return err
+	}
+	for _, k := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(members, k)
+	}
+	p.Extensions = nil
+	if len(members) > 0 {
+		p.Extensions = members
+	}
+	return nil
+}
+
+// RespondProblem responds with the problem, as application/problem+json,
+// with its status, so every error of an API looks the same, e.g.
+//
+//	p := hyperdrive.NewProblem(http.StatusConflict, "A widget with this name already exists.")
+//	p.Instance = r.URL.Path
+//	hyperdrive.RespondProblem(rw, p)
+//
+// Problems of enveloped responses (see EnvelopeMiddleware) are written as
+// the errors of an Envelope, as application/json, instead.
+func RespondProblem(rw http.ResponseWriter, p Problem) {
+	if isEnveloped(rw) {
+		writeEnvelopedProblem(rw, p)
+		return
+	}
+	rw.Header().Set("Content-Type", problemContentType)
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(p.Status)
+	json.NewEncoder(rw).Encode(p)
+}
+
+// writeProblem responds with an application/problem+json body, for the given
+// status and detail.
+func writeProblem(rw http.ResponseWriter, status int, detail string) {
+	RespondProblem(rw, NewProblem(status, detail))
+}
+
+// notFoundHandler responds to requests no route matches with a 404 problem.
+func notFoundHandler(rw http.ResponseWriter, r *http.Request) {
+	writeProblem(rw, http.StatusNotFound, "No resource matches the requested URL.")
+}
+
+// problemMethodHandler responds to requests with a method the endpoint does
+// not handle with a 405 problem, and an Allow header listing those it does,
+// and passes others to the handlers.MethodHandler.
+func problemMethodHandler(h handlers.MethodHandler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if _, ok := h[r.Method]; ok || r.Method == "OPTIONS" {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		allow := make([]string, 0, len(h))
+		for method := range h {
+			allow = append(allow, method)
+		}
+		sort.Strings(allow)
+		rw.Header().Set("Allow", strings.Join(allow, ", "))
+		writeProblem(rw, http.StatusMethodNotAllowed, "The "+r.Method+" method is not supported by this resource.")
+	})
+}
@@ -0,0 +1,56 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestProblemMarshalJSON() {
+	p := NewProblem(http.StatusConflict, "A widget with this name already exists.")
+	p.Instance = "/widgets"
+	p.Extensions = map[string]interface{}{"name": "sprocket", "status": 200}
+	b, err := json.Marshal(p)
+	suite.Nil(err, "expects no error")
+	suite.JSONEq(`{
+		"type": "about:blank",
+		"title": "Conflict",
+		"status": 409,
+		"detail": "A widget with this name already exists.",
+		"instance": "/widgets",
+		"name": "sprocket"
+	}`, string(b), "expects the extensions alongside the standard members, which they can not replace")
+
+	var decoded Problem
+	suite.Nil(json.Unmarshal(b, &decoded), "expects no error")
+	suite.Equal("/widgets", decoded.Instance, "expects the standard members to be decoded")
+	suite.Equal(map[string]interface{}{"name": "sprocket"}, decoded.Extensions, "expects the extensions to be decoded")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondProblem() {
+	rw := httptest.NewRecorder()
+	RespondProblem(rw, NewProblem(http.StatusConflict, "Already exists."))
+	suite.Equal(http.StatusConflict, rw.Code, "expects the status of the problem")
+	suite.Equal(problemContentType, rw.Header().Get("Content-Type"), "expects a problem+json body")
+	suite.JSONEq(`{"type": "about:blank", "title": "Conflict", "status": 409, "detail": "Already exists."}`, rw.Body.String(), "expects the problem")
+}
+
+func (suite *HyperdriveTestSuite) TestNotFoundProblem() {
+	rw := httptest.NewRecorder()
+	suite.TestAPI.Router.ServeHTTP(rw, httptest.NewRequest("GET", "/missing", nil))
+	suite.Equal(http.StatusNotFound, rw.Code, "expects a 404")
+	suite.Equal(problemContentType, rw.Header().Get("Content-Type"), "expects a problem+json body")
+}
+
+func (suite *HyperdriveTestSuite) TestMethodNotAllowedProblem() {
+	e := &SirenEndpoint{Endpoint: NewEndpoint("Orders", "Manage orders", "/orders", "1")}
+	rw := httptest.NewRecorder()
+	NewMethodHandler(e).ServeHTTP(rw, httptest.NewRequest("PUT", "/orders", nil))
+	suite.Equal(http.StatusMethodNotAllowed, rw.Code, "expects a 405")
+	suite.Equal("DELETE, GET, PATCH, POST", rw.Header().Get("Allow"), "expects the allowed methods")
+	suite.Equal(problemContentType, rw.Header().Get("Content-Type"), "expects a problem+json body")
+
+	rw = httptest.NewRecorder()
+	NewMethodHandler(e).ServeHTTP(rw, httptest.NewRequest("OPTIONS", "/orders", nil))
+	suite.Equal(http.StatusOK, rw.Code, "expects OPTIONS to be answered")
+}
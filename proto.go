@@ -0,0 +1,68 @@
+package hyperdrive
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrUnsupportedMediaType is returned by BindProto when the request body is
+// neither protobuf nor JSON. WriteError responds to it with a 415
+// Unsupported Media Type.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// BindProto decodes the request body into m, so endpoints can accept
+// protobuf messages from internal services. Bodies with a Content-Type of
+// application/x-protobuf, application/protobuf,
+// application/vnd.google.protobuf, or a media type with a +proto or
+// +protobuf suffix, are decoded from the protobuf wire format, and JSON
+// bodies from the protobuf JSON mapping (see protojson), so the same endpoint
+// can serve both. The body is left unread.
+//
+// If the body can not be decoded, BindProto returns a *ValidationError, which
+// can be written with WriteError, as can the ErrUnsupportedMediaType
+// returned for other bodies. Otherwise, if m is Validatable, the errors
+// added by its Validate method are returned.
+func BindProto(r *http.Request, m proto.Message) error {
+	contentType := r.Header.Get("Content-Type")
+	isProto := isProtobufContentType(contentType)
+	if !isProto && !isJSONContentType(contentType) {
+		return ErrUnsupportedMediaType
+	}
+	b, err := RawBody(r)
+	if err != nil {
+		return err
+	}
+	verr := &ValidationError{}
+	if isProto {
+		err = proto.Unmarshal(b, m)
+	} else {
+		err = protojson.Unmarshal(b, m)
+	}
+	if err != nil {
+		verr.AddBodyError("", ValidationInvalid, "The request body must be a valid "+string(m.ProtoReflect().Descriptor().FullName())+" message.")
+		return verr
+	}
+	if val, ok := m.(Validatable); ok {
+		val.Validate(verr)
+	}
+	if !verr.Empty() {
+		return verr
+	}
+	return nil
+}
+
+// isProtobufContentType returns true for application/x-protobuf,
+// application/protobuf, application/vnd.google.protobuf, and media types with
+// a proto or protobuf suffix (+proto or +protobuf).
+func isProtobufContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return contains([]string{"application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf"}, mediaType) || strings.HasSuffix(mediaType, "+proto") || strings.HasSuffix(mediaType, "+protobuf")
+}
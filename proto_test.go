@@ -0,0 +1,46 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func (suite *HyperdriveTestSuite) TestBindProto() {
+	b, _ := proto.Marshal(wrapperspb.String("test"))
+	r := httptest.NewRequest("POST", "/test", bytes.NewReader(b))
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	var m wrapperspb.StringValue
+	suite.Nil(BindProto(r, &m), "expects no error")
+	suite.Equal("test", m.GetValue(), "expects the message to be decoded")
+}
+
+func (suite *HyperdriveTestSuite) TestBindProtoJSON() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(`"test"`))
+	r.Header.Set("Content-Type", "application/json")
+	var m wrapperspb.StringValue
+	suite.Nil(BindProto(r, &m), "expects no error")
+	suite.Equal("test", m.GetValue(), "expects the JSON mapping to be decoded")
+}
+
+func (suite *HyperdriveTestSuite) TestBindProtoInvalid() {
+	r := httptest.NewRequest("POST", "/test", bytes.NewReader([]byte{0xff, 0xff}))
+	r.Header.Set("Content-Type", "application/protobuf")
+	err := BindProto(r, &wrapperspb.StringValue{})
+	suite.IsType(&ValidationError{}, err, "expects a ValidationError")
+	suite.Equal("The request body must be a valid google.protobuf.StringValue message.", err.Error(), "expects the message type to be reported")
+}
+
+func (suite *HyperdriveTestSuite) TestBindProtoUnsupportedMediaType() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader("value=test"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	err := BindProto(r, &wrapperspb.StringValue{})
+	suite.Equal(ErrUnsupportedMediaType, err, "expects ErrUnsupportedMediaType")
+	rw := httptest.NewRecorder()
+	WriteError(rw, err)
+	suite.Equal(http.StatusUnsupportedMediaType, rw.Code, "expects a 415 Unsupported Media Type")
+}
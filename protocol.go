@@ -0,0 +1,146 @@
+package hyperdrive
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+)
+
+// The TLS versions accepted by TLS_MIN_VERSION.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// The HTTP versions accepted by HTTP_MIN_VERSION, as major and minor
+// versions.
+var httpVersions = map[string][2]int{
+	"1.0": {1, 0},
+	"1.1": {1, 1},
+	"2":   {2, 0},
+}
+
+// parseTLSVersion returns the TLS version with the given name, e.g. "1.2".
+func parseTLSVersion(name string) (uint16, error) {
+	if v, ok := tlsVersions[name]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q, expected one of 1.0, 1.1, 1.2, 1.3", name)
+}
+
+// tlsVersionName returns the name of the given TLS version, e.g. "1.2".
+func tlsVersionName(v uint16) string {
+	for name, version := range tlsVersions {
+		if version == v {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// parseCipherSuites returns the IDs of the cipher suites with the given
+// names (as named by crypto/tls, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256).
+// Insecure cipher suites are rejected.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	var ids []uint16
+	for _, name := range names {
+		found := false
+		for _, cs := range tls.CipherSuites() {
+			if cs.Name == name {
+				ids = append(ids, cs.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+		}
+	}
+	return ids, nil
+}
+
+// newTLSConfig returns the tls.Config of the API's Server, which rejects
+// handshakes below TLS_MIN_VERSION (default: 1.2), and, when
+// TLS_CIPHER_SUITES is set, with any other cipher suite. Cipher suites can
+// not be configured for TLS 1.3.
+func newTLSConfig() *tls.Config {
+	c := &tls.Config{}
+	c.MinVersion, _ = parseTLSVersion(conf.TLSMinVersion)
+	c.CipherSuites, _ = parseCipherSuites(splitList(conf.TLSCipherSuites))
+	return c
+}
+
+// ProtocolPolicy restricts the protocols a route can be requested over, for
+// compliance-constrained deployments. MinHTTPVersion is the oldest HTTP
+// version accepted ("1.0", "1.1", or "2"; empty accepts any), RequireTLS
+// rejects plaintext requests, and MinTLSVersion (e.g. tls.VersionTLS13, 0
+// for any) rejects requests over older TLS versions than the server accepts.
+type ProtocolPolicy struct {
+	MinHTTPVersion string
+	RequireTLS     bool
+	MinTLSVersion  uint16
+}
+
+// ProtocolPolicer interface is satisfied by endpoints with their own
+// ProtocolPolicy, which AddEndpoint enforces instead of the
+// DefaultProtocolPolicy.
+type ProtocolPolicer interface {
+	ProtocolPolicy() ProtocolPolicy
+}
+
+// DefaultProtocolPolicy returns the ProtocolPolicy enforced for endpoints
+// without their own, which only accepts HTTP_MIN_VERSION (default: 1.0) or
+// later.
+func DefaultProtocolPolicy() ProtocolPolicy {
+	return ProtocolPolicy{MinHTTPVersion: conf.HTTPMinVersion}
+}
+
+// acceptsAny returns true if the ProtocolPolicy accepts any protocol.
+func (p ProtocolPolicy) acceptsAny() bool {
+	v := httpVersions[p.MinHTTPVersion]
+	return v[0] <= 1 && v[1] == 0 && !p.RequireTLS && p.MinTLSVersion == 0
+}
+
+// ProtocolPolicyMiddleware returns a Middleware enforcing the given
+// ProtocolPolicy. Requests over an older HTTP version are rejected with a 505
+// HTTP Version Not Supported, and plaintext requests, or requests over an
+// older TLS version, with a 403 Forbidden. Each response explains which
+// protocol is required.
+func (api *API) ProtocolPolicyMiddleware(p ProtocolPolicy) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if v, ok := httpVersions[p.MinHTTPVersion]; ok && !r.ProtoAtLeast(v[0], v[1]) {
+				writeProblem(rw, http.StatusHTTPVersionNotSupported, fmt.Sprintf("HTTP/%s or later is required, the request was made over %s.", p.MinHTTPVersion, r.Proto))
+				return
+			}
+			if (p.RequireTLS || p.MinTLSVersion != 0) && r.TLS == nil {
+				writeProblem(rw, http.StatusForbidden, "TLS is required, the request was made over plaintext.")
+				return
+			}
+			if p.MinTLSVersion != 0 && r.TLS.Version < p.MinTLSVersion {
+				writeProblem(rw, http.StatusForbidden, fmt.Sprintf("TLS %s or later is required, the request was made over TLS %s.", tlsVersionName(p.MinTLSVersion), tlsVersionName(r.TLS.Version)))
+				return
+			}
+			h.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// protocolPolicy returns the ProtocolPolicy of the given endpoint.
+func protocolPolicy(e Endpointer) ProtocolPolicy {
+	if pp, ok := e.(ProtocolPolicer); ok {
+		return pp.ProtocolPolicy()
+	}
+	return DefaultProtocolPolicy()
+}
+
+// validateHTTPVersion returns an error if the given HTTP_MIN_VERSION is not
+// supported.
+func validateHTTPVersion(name string) error {
+	if _, ok := httpVersions[name]; !ok {
+		return fmt.Errorf("unknown HTTP version %q, expected one of 1.0, 1.1, 2", name)
+	}
+	return nil
+}
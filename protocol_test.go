@@ -0,0 +1,65 @@
+package hyperdrive
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+)
+
+type HTTP2OnlyEndpoint struct {
+	Endpoint
+}
+
+func (e *HTTP2OnlyEndpoint) ProtocolPolicy() ProtocolPolicy {
+	return ProtocolPolicy{MinHTTPVersion: "2"}
+}
+
+func (suite *HyperdriveTestSuite) TestNewTLSConfig() {
+	defer func(c Config) { conf = c }(conf)
+	conf.TLSMinVersion = "1.3"
+	conf.TLSCipherSuites = "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"
+	c := newTLSConfig()
+	suite.Equal(uint16(tls.VersionTLS13), c.MinVersion, "expects the TLS_MIN_VERSION to be used")
+	suite.Equal([]uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, c.CipherSuites, "expects the TLS_CIPHER_SUITES to be used")
+}
+
+func (suite *HyperdriveTestSuite) TestAPIServerTLSConfig() {
+	suite.Equal(uint16(tls.VersionTLS12), suite.TestAPI.Server.TLSConfig.MinVersion, "expects TLS 1.2 to be required by default")
+}
+
+func (suite *HyperdriveTestSuite) TestProtocolPolicyMiddlewareHTTPVersion() {
+	h := suite.TestAPI.ProtocolPolicyMiddleware(ProtocolPolicy{MinHTTPVersion: "1.1"})(suite.TestHandler)
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/1.0", 1, 0
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusHTTPVersionNotSupported, rw.Code, "expects HTTP/1.0 to be rejected")
+	suite.Contains(rw.Body.String(), "HTTP/1.1 or later is required", "expects the required version to be explained")
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/test", nil))
+	suite.NotEqual(http.StatusHTTPVersionNotSupported, rw.Code, "expects HTTP/1.1 to be accepted")
+}
+
+func (suite *HyperdriveTestSuite) TestProtocolPolicyMiddlewareTLS() {
+	h := suite.TestAPI.ProtocolPolicyMiddleware(ProtocolPolicy{MinTLSVersion: tls.VersionTLS13})(suite.TestHandler)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/test", nil))
+	suite.Equal(http.StatusForbidden, rw.Code, "expects plaintext requests to be rejected")
+	r := httptest.NewRequest("GET", "https://example.com/test", nil)
+	r.TLS.Version = tls.VersionTLS12
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusForbidden, rw.Code, "expects older TLS versions to be rejected")
+	suite.Contains(rw.Body.String(), "TLS 1.3 or later is required, the request was made over TLS 1.2.", "expects the required version to be explained")
+	r.TLS.Version = tls.VersionTLS13
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	suite.NotEqual(http.StatusForbidden, rw.Code, "expects TLS 1.3 to be accepted")
+}
+
+func (suite *HyperdriveTestSuite) TestProtocolPolicyEndpoint() {
+	e := &HTTP2OnlyEndpoint{Endpoint: *NewEndpoint("HTTP2", "HTTP/2 Only Endpoint", "/http2", "1")}
+	suite.Equal(ProtocolPolicy{MinHTTPVersion: "2"}, protocolPolicy(e), "expects the endpoint's ProtocolPolicy")
+	suite.Equal(DefaultProtocolPolicy(), protocolPolicy(suite.TestEndpoint), "expects the DefaultProtocolPolicy")
+	suite.True(DefaultProtocolPolicy().acceptsAny(), "expects any protocol to be accepted by default")
+}
@@ -0,0 +1,198 @@
+package hyperdrive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	proxyV1Prefix    = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errProxyHeader = errors.New("invalid PROXY protocol header")
+)
+
+// proxyListener wraps a net.Listener, and reads a HAProxy PROXY protocol
+// (v1 or v2) header from each accepted connection, so the client address
+// reported by the connection is the one given by the load balancer.
+type proxyListener struct {
+	net.Listener
+	timeout time.Duration
+}
+
+func newProxyListener(l net.Listener, timeout time.Duration) net.Listener {
+	return &proxyListener{Listener: l, timeout: timeout}
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newProxyConn(conn, l.timeout), nil
+}
+
+// proxyConn is a net.Conn which lazily reads the PROXY protocol header on the
+// first call to Read, RemoteAddr, or LocalAddr, so a slow client can not block
+// the listener's Accept loop.
+type proxyConn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	once    sync.Once
+	err     error
+	src     net.Addr
+	dst     net.Addr
+}
+
+func newProxyConn(conn net.Conn, timeout time.Duration) *proxyConn {
+	return &proxyConn{Conn: conn, r: bufio.NewReader(conn), timeout: timeout}
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer c.Conn.SetReadDeadline(time.Time{})
+		}
+		c.src, c.dst, c.err = readProxyHeader(c.r)
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.src != nil {
+		return c.src
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.init()
+	if c.dst != nil {
+		return c.dst
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readProxyHeader reads a v1 or v2 PROXY protocol header from r, returning
+// the source and destination addresses it contains. Both addresses are nil
+// for LOCAL (v2) and UNKNOWN (v1) connections, e.g. health checks made by the
+// load balancer itself.
+func readProxyHeader(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	b, err := r.Peek(len(proxyV1Prefix))
+	if err != nil {
+		return nil, nil, err
+	}
+	if bytes.Equal(b, proxyV1Prefix) {
+		return readProxyHeaderV1(r)
+	}
+	if b, err = r.Peek(len(proxyV2Signature)); err == nil && bytes.Equal(b, proxyV2Signature) {
+		return readProxyHeaderV2(r)
+	}
+	return nil, nil, errProxyHeader
+}
+
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	// v1 headers are at most 107 bytes, including the CRLF.
+	var line []byte
+	for len(line) < 107 {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errProxyHeader
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, errProxyHeader
+	}
+	src, err := parseProxyAddr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := parseProxyAddr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+func parseProxyAddr(ip string, port string) (*net.TCPAddr, error) {
+	addr := net.ParseIP(ip)
+	p, err := strconv.Atoi(port)
+	if addr == nil || err != nil || p < 0 || p > 65535 {
+		return nil, fmt.Errorf("%v: bad address %s:%s", errProxyHeader, ip, port)
+	}
+	return &net.TCPAddr{IP: addr, Port: p}, nil
+}
+
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, nil, errProxyHeader
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, err
+	}
+	command, family := header[12]&0x0f, header[13]
+	if command == 0 {
+		// LOCAL
+		return nil, nil, nil
+	}
+	if command != 1 {
+		return nil, nil, errProxyHeader
+	}
+	var size int
+	switch family >> 4 {
+	case 1:
+		size = net.IPv4len
+	case 2:
+		size = net.IPv6len
+	default:
+		// AF_UNSPEC or AF_UNIX: the addresses are not useful to us.
+		return nil, nil, nil
+	}
+	if len(payload) < size*2+4 {
+		return nil, nil, errProxyHeader
+	}
+	src := &net.TCPAddr{
+		IP:   net.IP(payload[:size]),
+		Port: int(binary.BigEndian.Uint16(payload[size*2:])),
+	}
+	dst := &net.TCPAddr{
+		IP:   net.IP(payload[size : size*2]),
+		Port: int(binary.BigEndian.Uint16(payload[size*2+2:])),
+	}
+	return src, dst, nil
+}
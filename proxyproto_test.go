@@ -0,0 +1,69 @@
+package hyperdrive
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestReadProxyHeaderV1() {
+	r := bufio.NewReader(strings.NewReader("PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\nGET / HTTP/1.1\r\n"))
+	src, dst, err := readProxyHeader(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal("192.168.0.1:56324", src.String(), "expects the source address")
+	suite.Equal("10.0.0.1:443", dst.String(), "expects the destination address")
+	rest, _ := ioutil.ReadAll(r)
+	suite.Equal("GET / HTTP/1.1\r\n", string(rest), "expects the header to be consumed")
+}
+
+func (suite *HyperdriveTestSuite) TestReadProxyHeaderV1TCP6() {
+	r := bufio.NewReader(strings.NewReader("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"))
+	src, _, err := readProxyHeader(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal("[2001:db8::1]:56324", src.String(), "expects the IPv6 source address")
+}
+
+func (suite *HyperdriveTestSuite) TestReadProxyHeaderV1Unknown() {
+	src, dst, err := readProxyHeader(bufio.NewReader(strings.NewReader("PROXY UNKNOWN\r\n")))
+	suite.Nil(err, "expects no error")
+	suite.Nil(src, "expects no source address")
+	suite.Nil(dst, "expects no destination address")
+}
+
+func (suite *HyperdriveTestSuite) TestReadProxyHeaderV1Invalid() {
+	_, _, err := readProxyHeader(bufio.NewReader(strings.NewReader("PROXY TCP4 nope 10.0.0.1 1 2\r\n")))
+	suite.Error(err, "expects an error for invalid addresses")
+}
+
+func (suite *HyperdriveTestSuite) TestReadProxyHeaderV2() {
+	header := string(proxyV2Signature) + "\x21\x11\x00\x0c" + "\xc0\xa8\x00\x01" + "\x0a\x00\x00\x01" + "\xdc\x04" + "\x01\xbb"
+	src, dst, err := readProxyHeader(bufio.NewReader(strings.NewReader(header)))
+	suite.Nil(err, "expects no error")
+	suite.Equal("192.168.0.1:56324", src.String(), "expects the source address")
+	suite.Equal("10.0.0.1:443", dst.String(), "expects the destination address")
+}
+
+func (suite *HyperdriveTestSuite) TestReadProxyHeaderV2Local() {
+	header := string(proxyV2Signature) + "\x20\x00\x00\x00"
+	src, _, err := readProxyHeader(bufio.NewReader(strings.NewReader(header)))
+	suite.Nil(err, "expects no error")
+	suite.Nil(src, "expects no source address")
+}
+
+func (suite *HyperdriveTestSuite) TestReadProxyHeaderMissing() {
+	_, _, err := readProxyHeader(bufio.NewReader(strings.NewReader("GET / HTTP/1.1\r\n\r\n")))
+	suite.Equal(errProxyHeader, err, "expects an error if the header is missing")
+}
+
+func (suite *HyperdriveTestSuite) TestProxyConn() {
+	client, server := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\nhello"))
+	conn := newProxyConn(server, time.Second)
+	suite.Equal("192.168.0.1:56324", conn.RemoteAddr().String(), "expects the remote address from the header")
+	b := make([]byte, 5)
+	conn.Read(b)
+	suite.Equal("hello", string(b), "expects the data after the header")
+}
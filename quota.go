@@ -0,0 +1,202 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// QuotaStore interface is satisfied by anything that can count the requests
+// made by each principal per quota period, for QuotaMiddleware. Increment
+// adds n requests to the count for the key, which may be discarded once it
+// expires, and returns the new count. Get returns the count for the key, or
+// 0 if there is none. Implementations must be safe for concurrent use. See
+// MemoryQuotaStore.
+type QuotaStore interface {
+	Increment(key string, n int, expires time.Time) (int, error)
+	Get(key string) (int, error)
+}
+
+type memoryQuotaEntry struct {
+	count   int
+	expires time.Time
+}
+
+// MemoryQuotaStore is an in-memory implementation of QuotaStore. Counts are
+// lost when the process exits, so it is only suitable for development.
+type MemoryQuotaStore struct {
+	mu     sync.Mutex
+	counts map[string]memoryQuotaEntry
+}
+
+// NewMemoryQuotaStore creates an instance of MemoryQuotaStore.
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{counts: map[string]memoryQuotaEntry{}}
+}
+
+// Increment adds n requests to the count for the key.
+func (s *MemoryQuotaStore) Increment(key string, n int, expires time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.counts[key]
+	entry.count += n
+	entry.expires = expires
+	s.counts[key] = entry
+	return entry.count, nil
+}
+
+// Get returns the count for the key, if it has not expired.
+func (s *MemoryQuotaStore) Get(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.counts[key]
+	if !ok {
+		return 0, nil
+	}
+	if !time.Now().Before(entry.expires) {
+		delete(s.counts, key)
+		return 0, nil
+	}
+	return entry.count, nil
+}
+
+// QuotaUsage is a principal's usage of its quota in the current period.
+// Limit is 0 if the principal has no quota.
+type QuotaUsage struct {
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// quotaPeriod returns the label of the QUOTA_PERIOD containing t, and when
+// it ends. Periods are calendar days or months, in UTC.
+func quotaPeriod(t time.Time) (string, time.Time) {
+	t = t.UTC()
+	if conf.QuotaPeriod == "day" {
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+	}
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start.Format("2006-01"), start.AddDate(0, 1, 0)
+}
+
+// quotaLimit returns the principal's quota: its own if it has one, or
+// QUOTA_LIMIT. A limit of 0 or less is unlimited.
+func quotaLimit(p *Principal) int {
+	if p.Quota != 0 {
+		return p.Quota
+	}
+	return conf.QuotaLimit
+}
+
+func quotaKey(p *Principal, period string) string {
+	return p.Method + ":" + p.ID + ":" + period
+}
+
+func newQuotaUsage(limit int, count int, reset time.Time) *QuotaUsage {
+	if limit <= 0 {
+		return &QuotaUsage{Used: count, Reset: reset}
+	}
+	u := &QuotaUsage{Limit: limit, Used: count, Reset: reset}
+	if u.Used > limit {
+		u.Used = limit
+	}
+	u.Remaining = limit - u.Used
+	return u
+}
+
+// quotas holds the QuotaStore of an API, shared by every copy of the API.
+type quotas struct {
+	sync.RWMutex
+	store QuotaStore
+}
+
+func (q *quotas) get() QuotaStore {
+	q.RLock()
+	defer q.RUnlock()
+	return q.store
+}
+
+// SetQuotaStore replaces the QuotaStore used to count requests by
+// QuotaMiddleware, and to report usage by QuotaUsageFor, which is a
+// MemoryQuotaStore by default. Use a persistent store in production, so
+// counts survive restarts, and are shared by every instance of the API.
+func (api *API) SetQuotaStore(store QuotaStore) {
+	api.quotas.Lock()
+	defer api.quotas.Unlock()
+	api.quotas.store = store
+}
+
+// QuotaUsageFor returns the principal's usage of its quota in the current
+// QUOTA_PERIOD.
+func (api *API) QuotaUsageFor(p *Principal) (*QuotaUsage, error) {
+	period, reset := quotaPeriod(time.Now())
+	count, err := api.quotas.get().Get(quotaKey(p, period))
+	if err != nil {
+		return nil, err
+	}
+	return newQuotaUsage(quotaLimit(p), count, reset), nil
+}
+
+// QuotaMiddleware wraps the given http.Handler, and counts the requests made
+// by each Principal against its quota: QUOTA_LIMIT requests per QUOTA_PERIOD
+// (a calendar day or month, in UTC; default: month), or the Quota of its API
+// key. The usage is reported in the X-Quota-Limit, X-Quota-Remaining and
+// X-Quota-Reset (Unix time) headers, and requests over the quota are
+// rejected with a 429 Too Many Requests. Requests are not counted while
+// QUOTA_LIMIT is 0 (the default), unless the principal has its own quota,
+// nor if they have not been authenticated, so the auth middleware must be
+// earlier (outer) in the chain.
+func (api *API) QuotaMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		p, ok := CurrentPrincipal(r)
+		if !ok || quotaLimit(p) <= 0 {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		limit := quotaLimit(p)
+		period, reset := quotaPeriod(time.Now())
+		count, err := api.quotas.get().Increment(quotaKey(p, period), 1, reset)
+		if err != nil {
+			http.Error(rw, GetErrorText(http.StatusInternalServerError, err), http.StatusInternalServerError)
+			return
+		}
+		u := newQuotaUsage(limit, count, reset)
+		rw.Header().Set("X-Quota-Limit", strconv.Itoa(u.Limit))
+		rw.Header().Set("X-Quota-Remaining", strconv.Itoa(u.Remaining))
+		rw.Header().Set("X-Quota-Reset", strconv.FormatInt(u.Reset.Unix(), 10))
+		if count > limit {
+			rw.Header().Set("Retry-After", strconv.Itoa(secondsUntil(reset)))
+			writeProblem(rw, http.StatusTooManyRequests, fmt.Sprintf("Quota of %d requests per %s exceeded.", limit, conf.QuotaPeriod))
+			return
+		}
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// QuotaHandler returns an http.Handler which responds with the current
+// principal's QuotaUsage, as json, or a 401 Unauthorized if the request has
+// not been authenticated. Mount it behind an auth middleware, e.g.
+//
+//	api.Router.Handle("/quota", api.APIKeyMiddleware(keys)(api.QuotaHandler()))
+func (api *API) QuotaHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		p, ok := CurrentPrincipal(r)
+		if !ok {
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		u, err := api.QuotaUsageFor(p)
+		if err != nil {
+			http.Error(rw, GetErrorText(http.StatusInternalServerError, err), http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(rw).Encode(u)
+	})
+}
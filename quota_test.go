@@ -0,0 +1,105 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestMemoryQuotaStore() {
+	suite.Implements((*QuotaStore)(nil), NewMemoryQuotaStore(), "expects an implementation of QuotaStore")
+}
+
+func (suite *HyperdriveTestSuite) TestMemoryQuotaStoreExpires() {
+	store := NewMemoryQuotaStore()
+	count, _ := store.Increment("key", 2, time.Now().Add(time.Minute))
+	suite.Equal(2, count, "expects the new count")
+	count, _ = store.Get("key")
+	suite.Equal(2, count, "expects the count")
+	store.Increment("key", 1, time.Now().Add(-time.Second))
+	count, _ = store.Get("key")
+	suite.Equal(0, count, "expects expired counts to be removed")
+}
+
+func (suite *HyperdriveTestSuite) TestQuotaPeriod() {
+	defer func(c Config) { conf = c }(conf)
+	t := time.Date(2024, time.February, 29, 18, 30, 0, 0, time.UTC)
+	period, reset := quotaPeriod(t)
+	suite.Equal("2024-02", period, "expects the month")
+	suite.Equal(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), reset, "expects the start of the next month")
+	conf.QuotaPeriod = "day"
+	period, reset = quotaPeriod(t)
+	suite.Equal("2024-02-29", period, "expects the day")
+	suite.Equal(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), reset, "expects the start of the next day")
+}
+
+func (suite *HyperdriveTestSuite) quotaRequest(h http.Handler, p *Principal) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/test", nil)
+	if p != nil {
+		r = WithPrincipal(r, p)
+	}
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	return rw
+}
+
+func (suite *HyperdriveTestSuite) TestQuotaMiddleware() {
+	defer func(c Config) { conf = c }(conf)
+	conf.QuotaLimit = 2
+	p := &Principal{ID: "billing-service", Method: AuthMethodAPIKey}
+	h := suite.TestAPI.QuotaMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	rw := suite.quotaRequest(h, p)
+	suite.Equal(http.StatusOK, rw.Code, "expects requests within the quota to be served")
+	suite.Equal("2", rw.Header().Get("X-Quota-Limit"), "expects the quota")
+	suite.Equal("1", rw.Header().Get("X-Quota-Remaining"), "expects the remaining requests")
+	_, reset := quotaPeriod(time.Now())
+	suite.Equal(strconv.FormatInt(reset.Unix(), 10), rw.Header().Get("X-Quota-Reset"), "expects the end of the period")
+	suite.quotaRequest(h, p)
+	rw = suite.quotaRequest(h, p)
+	suite.Equal(http.StatusTooManyRequests, rw.Code, "expects requests over the quota to be rejected")
+	suite.Equal("0", rw.Header().Get("X-Quota-Remaining"), "expects no remaining requests")
+	suite.Equal(http.StatusOK, suite.quotaRequest(h, &Principal{ID: "other", Method: AuthMethodAPIKey}).Code, "expects principals to be counted separately")
+}
+
+func (suite *HyperdriveTestSuite) TestQuotaMiddlewareOverride() {
+	defer func(c Config) { conf = c }(conf)
+	conf.QuotaLimit = 1
+	h := suite.TestAPI.QuotaMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	p := &Principal{ID: "paid", Method: AuthMethodAPIKey, Quota: 3}
+	for i := 0; i < 3; i++ {
+		suite.Equal(http.StatusOK, suite.quotaRequest(h, p).Code, "expects the principal's quota to override QUOTA_LIMIT")
+	}
+	p = &Principal{ID: "internal", Method: AuthMethodAPIKey, Quota: -1}
+	for i := 0; i < 3; i++ {
+		rw := suite.quotaRequest(h, p)
+		suite.Equal(http.StatusOK, rw.Code, "expects principals with a negative quota to be unlimited")
+		suite.Equal("", rw.Header().Get("X-Quota-Limit"), "expects no quota headers for unlimited principals")
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestQuotaMiddlewareUnauthenticated() {
+	defer func(c Config) { conf = c }(conf)
+	conf.QuotaLimit = 1
+	h := suite.TestAPI.QuotaMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 2; i++ {
+		suite.Equal(http.StatusOK, suite.quotaRequest(h, nil).Code, "expects unauthenticated requests not to be counted")
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestQuotaHandler() {
+	defer func(c Config) { conf = c }(conf)
+	conf.QuotaLimit = 10
+	store := NewMemoryQuotaStore()
+	suite.TestAPI.SetQuotaStore(store)
+	p := &Principal{ID: "billing-service", Method: AuthMethodAPIKey}
+	suite.TestAPI.QuotaMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), WithPrincipal(httptest.NewRequest("GET", "/test", nil), p))
+	rw := suite.quotaRequest(suite.TestAPI.QuotaHandler(), p)
+	var u QuotaUsage
+	json.Unmarshal(rw.Body.Bytes(), &u)
+	suite.Equal(10, u.Limit, "expects the quota")
+	suite.Equal(1, u.Used, "expects the requests counted in the store")
+	suite.Equal(9, u.Remaining, "expects the remaining requests")
+	suite.Equal(http.StatusUnauthorized, suite.quotaRequest(suite.TestAPI.QuotaHandler(), nil).Code, "expects a 401 for unauthenticated requests")
+}
@@ -0,0 +1,26 @@
+package hyperdrive
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// RespondRange is a helper function to make it easy for an Endpointer's
+// method handler to serve large payloads (e.g. exports or media) which
+// clients may want to download in parts, or resume. It honors the Range and
+// If-Range headers, responding with a `206 Partial Content` and the correct
+// Content-Range header, or a `416 Requested Range Not Satisfiable`.
+//
+// The Content-Type header is set to the given contentType, and the
+// Last-Modified header is set to the given time, unless it is the zero
+// time.Time. To support If-Range with an entity tag, set the ETag header on
+// the http.ResponseWriter before calling RespondRange.
+//
+// CompressionMiddleware does not compress requests with a Range header, so
+// the bytes served match the offsets in the Content-Range header.
+func RespondRange(rw http.ResponseWriter, r *http.Request, contentType string, modified time.Time, content io.ReadSeeker) {
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("Accept-Ranges", "bytes")
+	http.ServeContent(rw, r, "", modified, content)
+}
@@ -0,0 +1,56 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestRespondRange() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/export", nil)
+	r.Header.Set("Range", "bytes=2-5")
+	RespondRange(rw, r, "text/csv", time.Time{}, strings.NewReader("0123456789"))
+	suite.Equal(http.StatusPartialContent, rw.Code, "expects a 206")
+	suite.Equal("bytes 2-5/10", rw.Header().Get("Content-Range"), "expects the Content-Range header")
+	suite.Equal("text/csv", rw.Header().Get("Content-Type"), "expects the Content-Type header")
+	suite.Equal("2345", rw.Body.String(), "expects the requested bytes")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondRangeFull() {
+	rw := httptest.NewRecorder()
+	RespondRange(rw, httptest.NewRequest("GET", "/export", nil), "text/csv", time.Time{}, strings.NewReader("0123456789"))
+	suite.Equal(http.StatusOK, rw.Code, "expects a 200 without a Range header")
+	suite.Equal("bytes", rw.Header().Get("Accept-Ranges"), "expects the Accept-Ranges header")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondRangeIfRange() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/export", nil)
+	r.Header.Set("Range", "bytes=2-5")
+	r.Header.Set("If-Range", `"old"`)
+	rw.Header().Set("ETag", `"new"`)
+	RespondRange(rw, r, "text/csv", time.Time{}, strings.NewReader("0123456789"))
+	suite.Equal(http.StatusOK, rw.Code, "expects the full content when If-Range does not match")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondRangeNotSatisfiable() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/export", nil)
+	r.Header.Set("Range", "bytes=20-30")
+	RespondRange(rw, r, "text/csv", time.Time{}, strings.NewReader("0123456789"))
+	suite.Equal(http.StatusRequestedRangeNotSatisfiable, rw.Code, "expects a 416")
+}
+
+func (suite *HyperdriveTestSuite) TestCompressionMiddlewareRange() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/export", nil)
+	r.Header.Set("Range", "bytes=2-5")
+	r.Header.Set("Accept-Encoding", "gzip")
+	suite.TestAPI.CompressionMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		RespondRange(rw, r, "text/csv", time.Time{}, strings.NewReader("0123456789"))
+	})).ServeHTTP(rw, r)
+	suite.Equal("", rw.Header().Get("Content-Encoding"), "expects ranged responses not to be compressed")
+	suite.Equal("2345", rw.Body.String(), "expects the requested bytes")
+}
@@ -0,0 +1,189 @@
+package hyperdrive
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitStore interface is satisfied by anything that can count the
+// requests made by each client within a window, for RateLimitMiddleware.
+// Increment adds a request to the window of the given length for the key,
+// and returns the number of requests in the window, and when it resets (i.e.
+// when the client can make another request). Implementations must be safe
+// for concurrent use. See MemoryRateLimitStore, which counts in fixed
+// windows, and the hyperdrive/redis package for a store shared by every
+// instance of an API.
+type RateLimitStore interface {
+	Increment(key string, window time.Duration) (count int, reset time.Time, err error)
+}
+
+type memoryRateLimitEntry struct {
+	count int
+	reset time.Time
+}
+
+// MemoryRateLimitStore is an in-memory implementation of RateLimitStore. It
+// is suitable for development, and APIs running as a single instance.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	windows map[string]memoryRateLimitEntry
+	swept   time.Time
+}
+
+// NewMemoryRateLimitStore creates an instance of MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{windows: map[string]memoryRateLimitEntry{}, swept: time.Now()}
+}
+
+// Increment adds a request to the current window for the key. Expired
+// windows are removed once per window, so the store does not grow with
+// clients which have gone away.
+func (s *MemoryRateLimitStore) Increment(key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.swept) > window {
+		for k, entry := range s.windows {
+			if !now.Before(entry.reset) {
+				delete(s.windows, k)
+			}
+		}
+		s.swept = now
+	}
+	entry, ok := s.windows[key]
+	if !ok || !now.Before(entry.reset) {
+		entry = memoryRateLimitEntry{reset: now.Add(window)}
+	}
+	entry.count++
+	s.windows[key] = entry
+	return entry.count, entry.reset, nil
+}
+
+// rateLimits holds the RateLimitStore of an API, shared by every copy of the
+// API, and every route the rate-limit middleware is added to.
+type rateLimits struct {
+	sync.RWMutex
+	store RateLimitStore
+}
+
+func (l *rateLimits) get() RateLimitStore {
+	l.RLock()
+	defer l.RUnlock()
+	return l.store
+}
+
+// SetRateLimitStore replaces the RateLimitStore used to count requests by
+// RateLimitMiddleware, when it is not given one (e.g. by the rate-limit
+// middleware of MIDDLEWARE_CHAIN), which is a MemoryRateLimitStore by
+// default.
+func (api *API) SetRateLimitStore(store RateLimitStore) {
+	api.rateLimits.Lock()
+	defer api.rateLimits.Unlock()
+	api.rateLimits.store = store
+}
+
+// rateLimitKey returns the key the request is counted under, and the number
+// of requests allowed per window. When RATE_LIMIT_BY is principal,
+// authenticated requests are counted by their Principal, with its RateLimit
+// overriding RATE_LIMIT; other requests are counted by client IP.
+func rateLimitKey(r *http.Request) (string, int) {
+	if conf.RateLimitBy == "principal" {
+		if p, ok := CurrentPrincipal(r); ok {
+			limit := conf.RateLimit
+			if p.RateLimit != 0 {
+				limit = p.RateLimit
+			}
+			return "principal:" + p.Method + ":" + p.ID, limit
+		}
+	}
+	return "ip:" + remoteHost(r), conf.RateLimit
+}
+
+// SetRateLimitHeaders sets the rate limit headers selected by
+// RATE_LIMIT_HEADERS on the response header, so clients can throttle
+// themselves, whichever limiter is used:
+//
+// - x-ratelimit: X-RateLimit-Limit, X-RateLimit-Remaining, and
+// X-RateLimit-Reset (as a Unix time)
+// - ratelimit: the IETF draft RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset (in seconds from now), and RateLimit-Policy headers
+//
+// Both are set by default; set RATE_LIMIT_HEADERS to an empty string to set
+// neither.
+func SetRateLimitHeaders(h http.Header, limit int, remaining int, reset time.Time, window time.Duration) {
+	if remaining < 0 {
+		remaining = 0
+	}
+	for _, set := range strings.Split(conf.RateLimitHeaders, ",") {
+		switch strings.TrimSpace(set) {
+		case "x-ratelimit":
+			h.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			h.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		case "ratelimit":
+			h.Set("RateLimit-Limit", strconv.Itoa(limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(secondsUntil(reset)))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit, int(window.Seconds())))
+		}
+	}
+}
+
+// secondsUntil returns the number of whole seconds until t, rounded up, and
+// at least 1.
+func secondsUntil(t time.Time) int {
+	s := int(math.Ceil(time.Until(t).Seconds()))
+	if s < 1 {
+		return 1
+	}
+	return s
+}
+
+// RateLimitMiddleware returns a Middleware which allows each client
+// RATE_LIMIT requests per RATE_LIMIT_WINDOW (default: 1m), counted in the
+// given RateLimitStore (or the API's, if it is nil, so every route counts
+// towards the same limit; see SetRateLimitStore), and responds to requests
+// over the limit with a 429 Too Many Requests, and a Retry-After header. The
+// client's usage of its limit is reported in the headers set by
+// SetRateLimitHeaders. Requests are not limited while RATE_LIMIT is 0 (the
+// default).
+//
+// Clients are identified by IP, unless RATE_LIMIT_BY is principal, in which
+// case authenticated requests are counted by the Principal set by the auth
+// middleware, which must be earlier (outer) in the chain, and API keys with
+// a RateLimit set get their own limit, e.g. for free and paid tiers:
+//
+//	[{"key": "abc123", "owner": "free-tier-client", "rate_limit": 60},
+//	 {"key": "def456", "owner": "paid-tier-client", "rate_limit": 6000}]
+func (api *API) RateLimitMiddleware(store RateLimitStore) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			key, limit := rateLimitKey(r)
+			if limit <= 0 {
+				h.ServeHTTP(rw, r)
+				return
+			}
+			s := store
+			if s == nil {
+				s = api.rateLimits.get()
+			}
+			count, reset, err := s.Increment(key, conf.RateLimitWindow)
+			if err != nil {
+				http.Error(rw, GetErrorText(http.StatusInternalServerError, err), http.StatusInternalServerError)
+				return
+			}
+			SetRateLimitHeaders(rw.Header(), limit, limit-count, reset, conf.RateLimitWindow)
+			if count > limit {
+				rw.Header().Set("Retry-After", strconv.Itoa(secondsUntil(reset)))
+				writeProblem(rw, http.StatusTooManyRequests, fmt.Sprintf("Rate limit of %d requests per %s exceeded.", limit, conf.RateLimitWindow))
+				return
+			}
+			h.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestMemoryRateLimitStore() {
+	suite.Implements((*RateLimitStore)(nil), NewMemoryRateLimitStore(), "expects an implementation of RateLimitStore")
+}
+
+func (suite *HyperdriveTestSuite) TestMemoryRateLimitStoreIncrement() {
+	store := NewMemoryRateLimitStore()
+	store.Increment("a", time.Minute)
+	count, reset, _ := store.Increment("a", time.Minute)
+	suite.Equal(2, count, "expects requests in the same window to be counted together")
+	suite.True(reset.After(time.Now()), "expects the window to reset in the future")
+	count, _, _ = store.Increment("b", time.Minute)
+	suite.Equal(1, count, "expects keys to be counted separately")
+}
+
+func (suite *HyperdriveTestSuite) TestMemoryRateLimitStoreReset() {
+	store := NewMemoryRateLimitStore()
+	store.Increment("a", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	count, _, _ := store.Increment("a", time.Millisecond)
+	suite.Equal(1, count, "expects a new window once the window has passed")
+}
+
+func (suite *HyperdriveTestSuite) rateLimitedRequest(h http.Handler, addr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.RemoteAddr = addr
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	return rw
+}
+
+func (suite *HyperdriveTestSuite) TestRateLimitMiddlewareByIP() {
+	defer func(c Config) { conf = c }(conf)
+	conf.RateLimit = 2
+	h := suite.TestAPI.RateLimitMiddleware(nil)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	suite.Equal(http.StatusOK, suite.rateLimitedRequest(h, "10.0.0.1:1234").Code, "expects requests under the limit to be served")
+	suite.Equal(http.StatusOK, suite.rateLimitedRequest(h, "10.0.0.1:5678").Code, "expects requests from the same IP to be counted together")
+	rw := suite.rateLimitedRequest(h, "10.0.0.1:1234")
+	suite.Equal(http.StatusTooManyRequests, rw.Code, "expects requests over the limit to be rejected")
+	suite.Equal("60", rw.Header().Get("Retry-After"), "expects the seconds until the window resets")
+	suite.Equal(problemContentType, rw.Header().Get("Content-Type"), "expects a problem+json response")
+	suite.Equal(http.StatusOK, suite.rateLimitedRequest(h, "10.0.0.2:1234").Code, "expects other IPs to be counted separately")
+}
+
+func (suite *HyperdriveTestSuite) TestRateLimitMiddlewareDisabled() {
+	h := suite.TestAPI.RateLimitMiddleware(nil)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 3; i++ {
+		suite.Equal(http.StatusOK, suite.rateLimitedRequest(h, "10.0.0.1:1234").Code, "expects requests not to be limited when RATE_LIMIT is 0")
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestRateLimitMiddlewareByPrincipal() {
+	defer func(c Config) { conf = c }(conf)
+	conf.RateLimit = 1
+	conf.RateLimitBy = "principal"
+	keys := NewMemoryKeyStore(
+		APIKey{Key: "free", Owner: "free-client"},
+		APIKey{Key: "paid", Owner: "paid-client", RateLimit: 3},
+		APIKey{Key: "internal", Owner: "internal-client", RateLimit: -1},
+	)
+	h := suite.TestAPI.APIKeyMiddleware(keys)(suite.TestAPI.RateLimitMiddleware(nil)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})))
+	served := func(key string, addr string) int {
+		var n int
+		for i := 0; i < 5; i++ {
+			r := httptest.NewRequest("GET", "/test", nil)
+			r.RemoteAddr = addr
+			r.Header.Set("X-API-Key", key)
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, r)
+			if rw.Code == http.StatusOK {
+				n++
+			}
+		}
+		return n
+	}
+	suite.Equal(1, served("free", "10.0.0.1:1234"), "expects keys without a rate limit to use RATE_LIMIT")
+	suite.Equal(3, served("paid", "10.0.0.1:1234"), "expects the key's rate limit to override RATE_LIMIT, and each principal to be counted separately")
+	suite.Equal(5, served("internal", "10.0.0.1:1234"), "expects keys with a negative rate limit to be unlimited")
+}
+
+func (suite *HyperdriveTestSuite) TestRateLimitMiddlewareByPrincipalUnauthenticated() {
+	defer func(c Config) { conf = c }(conf)
+	conf.RateLimit = 1
+	conf.RateLimitBy = "principal"
+	h := suite.TestAPI.RateLimitMiddleware(nil)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	suite.Equal(http.StatusOK, suite.rateLimitedRequest(h, "10.0.0.1:1234").Code, "expects the first request to be served")
+	suite.Equal(http.StatusTooManyRequests, suite.rateLimitedRequest(h, "10.0.0.1:1234").Code, "expects unauthenticated requests to be counted by IP")
+}
+
+func (suite *HyperdriveTestSuite) TestRateLimitMiddlewareHeaders() {
+	defer func(c Config) { conf = c }(conf)
+	conf.RateLimit = 2
+	h := suite.TestAPI.RateLimitMiddleware(nil)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	rw := suite.rateLimitedRequest(h, "10.0.0.1:1234")
+	suite.Equal("2", rw.Header().Get("X-RateLimit-Limit"), "expects the limit")
+	suite.Equal("1", rw.Header().Get("X-RateLimit-Remaining"), "expects the remaining requests")
+	suite.NotEmpty(rw.Header().Get("X-RateLimit-Reset"), "expects the time the window resets")
+	suite.Equal("2", rw.Header().Get("RateLimit-Limit"), "expects the draft limit header")
+	suite.Equal("1", rw.Header().Get("RateLimit-Remaining"), "expects the draft remaining header")
+	suite.Equal("60", rw.Header().Get("RateLimit-Reset"), "expects the seconds until the window resets")
+	suite.Equal("2;w=60", rw.Header().Get("RateLimit-Policy"), "expects the policy")
+	suite.rateLimitedRequest(h, "10.0.0.1:1234")
+	rw = suite.rateLimitedRequest(h, "10.0.0.1:1234")
+	suite.Equal("0", rw.Header().Get("X-RateLimit-Remaining"), "expects no remaining requests once the limit is exceeded")
+}
+
+func (suite *HyperdriveTestSuite) TestSetRateLimitHeadersConfigurable() {
+	defer func(c Config) { conf = c }(conf)
+	conf.RateLimitHeaders = "ratelimit"
+	h := http.Header{}
+	SetRateLimitHeaders(h, 10, 5, time.Now().Add(time.Minute), time.Minute)
+	suite.Equal("", h.Get("X-RateLimit-Limit"), "expects only the selected headers")
+	suite.Equal("10", h.Get("RateLimit-Limit"), "expects the selected headers")
+	conf.RateLimitHeaders = ""
+	h = http.Header{}
+	SetRateLimitHeaders(h, 10, 5, time.Now().Add(time.Minute), time.Minute)
+	suite.Empty(h, "expects no headers when none are selected")
+}
+
+func (suite *HyperdriveTestSuite) TestRateLimitMiddlewareSharedStore() {
+	defer func(c Config) { conf = c }(conf)
+	conf.RateLimit = 1
+	users, _ := suite.TestAPI.ParseMiddlewareChain("rate-limit")
+	widgets, _ := suite.TestAPI.ParseMiddlewareChain("rate-limit")
+	noop := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})
+	suite.Equal(http.StatusOK, suite.rateLimitedRequest(users.Then(noop), "10.0.0.1:1234").Code, "expects the first request to be served")
+	suite.Equal(http.StatusTooManyRequests, suite.rateLimitedRequest(widgets.Then(noop), "10.0.0.1:1234").Code, "expects requests to every route to count towards the same limit")
+	suite.TestAPI.SetRateLimitStore(NewMemoryRateLimitStore())
+	suite.Equal(http.StatusOK, suite.rateLimitedRequest(users.Then(noop), "10.0.0.1:1234").Code, "expects requests to be counted in the store set by SetRateLimitStore")
+}
@@ -0,0 +1,73 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ErrBodyTooLarge is returned by RawBody when the request body is larger
+// than RAW_BODY_MAX_BYTES. WriteError responds to it with a 413 Request
+// Entity Too Large.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+type rawBodyContextKey struct{}
+
+// rawBodyCache holds the body of a request, once it has been read by
+// RawBody.
+type rawBodyCache struct {
+	once sync.Once
+	body []byte
+	err  error
+}
+
+// withRawBodyCache returns a shallow copy of the request, whose body will be
+// cached by RawBody. It is called for every instrumented request.
+func withRawBodyCache(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), rawBodyContextKey{}, &rawBodyCache{}))
+}
+
+// RawBody returns the bytes of the request body, so they can be used by more
+// than one reader, e.g. SignatureMiddleware and BodyParams. The body is read
+// once per request (for requests passed through the middleware, see
+// instrument), cached in the request context, and r.Body is replaced with a
+// fresh reader of the bytes on each call, so handlers can still read it.
+//
+// Bodies larger than RAW_BODY_MAX_BYTES (default: 10485760) are not cached:
+// ErrBodyTooLarge is returned, and r.Body is left to be streamed from the
+// start.
+func RawBody(r *http.Request) ([]byte, error) {
+	c, ok := r.Context().Value(rawBodyContextKey{}).(*rawBodyCache)
+	if !ok {
+		c = &rawBodyCache{}
+	}
+	c.once.Do(func() { c.body, c.err = readRawBody(r) })
+	if c.err == nil && r.Body != nil {
+		r.Body = io.NopCloser(bytes.NewReader(c.body))
+	}
+	return c.body, c.err
+}
+
+// readRawBody reads up to RAW_BODY_MAX_BYTES of the request body. If it is
+// larger, the bytes read are put back in front of the rest of the body.
+func readRawBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return []byte{}, nil
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, int64(conf.RawBodyMaxBytes)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > conf.RawBodyMaxBytes {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+		return nil, ErrBodyTooLarge
+	}
+	r.Body.Close()
+	return b, nil
+}
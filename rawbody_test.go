@@ -0,0 +1,58 @@
+package hyperdrive
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// countingReader counts the calls to Read which return data.
+type countingReader struct {
+	io.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	if n > 0 {
+		c.reads++
+	}
+	return n, err
+}
+
+func (suite *HyperdriveTestSuite) TestRawBody() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader("name=test"))
+	b, err := RawBody(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal("name=test", string(b), "expects the body")
+	rest, _ := io.ReadAll(r.Body)
+	suite.Equal("name=test", string(rest), "expects the body to still be readable")
+}
+
+func (suite *HyperdriveTestSuite) TestRawBodyCached() {
+	body := &countingReader{Reader: strings.NewReader(`{"name":"test"}`)}
+	r := httptest.NewRequest("POST", "/test", body)
+	r.Header.Set("Content-Type", "application/json")
+	instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := RawBody(r)
+		suite.Equal(`{"name":"test"}`, string(b), "expects the body")
+		suite.Equal("test", BodyParams(r).Get("name"), "expects the cached body to be parsed")
+		b, _ = RawBody(r.WithContext(r.Context()))
+		suite.Equal(`{"name":"test"}`, string(b), "expects the body to be cached in the request context")
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	suite.Equal(1, body.reads, "expects the body to be read once")
+}
+
+func (suite *HyperdriveTestSuite) TestRawBodyTooLarge() {
+	defer func(c Config) { conf = c }(conf)
+	conf.RawBodyMaxBytes = 4
+	r := httptest.NewRequest("POST", "/test", strings.NewReader("name=test"))
+	_, err := RawBody(r)
+	suite.Equal(ErrBodyTooLarge, err, "expects ErrBodyTooLarge")
+	rest, _ := io.ReadAll(r.Body)
+	suite.Equal("name=test", string(rest), "expects the whole body to be left to stream")
+	rw := httptest.NewRecorder()
+	WriteError(rw, err)
+	suite.Equal(http.StatusRequestEntityTooLarge, rw.Code, "expects a 413 Request Entity Too Large")
+}
@@ -0,0 +1,84 @@
+package hyperdrive
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+// Panic contains information about a panic recovered by RecoveryMiddleware:
+// the value passed to panic(), the stacktrace of the goroutine that
+// panicked, and the request being served at the time. Request is nil for
+// panics recovered by MessageRecoveryMiddleware.
+type Panic struct {
+	Value   interface{}
+	Stack   []byte
+	Request *http.Request
+}
+
+// PanicReporter interface is satisfied by anything that can forward
+// recovered panics to an error reporting service, e.g. Sentry, Bugsnag, or
+// Rollbar. See the hyperdrive/sentry package for an implementation.
+type PanicReporter interface {
+	ReportPanic(Panic)
+}
+
+// PanicReporterFunc is an adapter to allow the use of ordinary functions as
+// a PanicReporter.
+type PanicReporterFunc func(Panic)
+
+// ReportPanic calls f(p).
+func (f PanicReporterFunc) ReportPanic(p Panic) {
+	f(p)
+}
+
+type panicReporters struct {
+	sync.RWMutex
+	reporters []PanicReporter
+}
+
+func (pr *panicReporters) add(r PanicReporter) {
+	pr.Lock()
+	defer pr.Unlock()
+	pr.reporters = append(pr.reporters, r)
+}
+
+func (pr *panicReporters) report(p Panic) {
+	pr.RLock()
+	defer pr.RUnlock()
+	for _, r := range pr.reporters {
+		r.ReportPanic(p)
+	}
+}
+
+// AddPanicReporter registers a PanicReporter, which RecoveryMiddleware will
+// call with every panic it recovers from.
+func (api *API) AddPanicReporter(r PanicReporter) {
+	api.panicReporters.add(r)
+}
+
+// recoverHandler wraps the given http.Handler, recovering from panics with a
+// 500 Internal Server Error problem (see RespondProblem), logging the
+// stacktrace (when printStack is true) and forwarding the panic to the
+// registered panic reporters.
+func recoverHandler(h http.Handler, reporters *panicReporters, printStack bool) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if v := recover(); v != nil {
+				p := Panic{Value: v, Stack: debug.Stack(), Request: r}
+				if s := GetResponseStats(r); s != nil {
+					s.Recovered = v
+				}
+				writeProblem(rw, http.StatusInternalServerError, GetErrorText(http.StatusInternalServerError, fmt.Errorf("%v", v)))
+				log.Println(v)
+				if printStack {
+					log.Printf("%s", p.Stack)
+				}
+				reporters.report(p)
+			}
+		}()
+		h.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,34 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestPanicReporterFunc() {
+	suite.Implements((*PanicReporter)(nil), PanicReporterFunc(func(Panic) {}), "expects an implementation of PanicReporter")
+}
+
+func (suite *HyperdriveTestSuite) TestRecoveryMiddlewareStatus() {
+	rw := httptest.NewRecorder()
+	h := suite.TestAPI.RecoveryMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	}))
+	h.ServeHTTP(rw, suite.TestGetRequest)
+	suite.Equal(http.StatusInternalServerError, rw.Code, "expects a 500 error")
+	suite.Equal(problemContentType, rw.Header().Get("Content-Type"), "expects a problem")
+}
+
+func (suite *HyperdriveTestSuite) TestAddPanicReporter() {
+	var reported Panic
+	suite.TestAPI.AddPanicReporter(PanicReporterFunc(func(p Panic) {
+		reported = p
+	}))
+	h := suite.TestAPI.RecoveryMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	suite.Equal("test panic", reported.Value, "expects the panic value to be reported")
+	suite.Equal(suite.TestGetRequest, reported.Request, "expects the request to be reported")
+	suite.NotEmpty(reported.Stack, "expects the stacktrace to be reported")
+}
@@ -0,0 +1,135 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Redirect declares that requests to Path should be redirected to Target,
+// with the given Status (default: 301). When PreserveQuery is true, the
+// request's query string is added to the Target.
+type Redirect struct {
+	Path          string `json:"path"`
+	Target        string `json:"target"`
+	Status        int    `json:"status"`
+	PreserveQuery bool   `json:"preserve_query"`
+}
+
+func (rd Redirect) validate() error {
+	if !strings.HasPrefix(rd.Path, "/") {
+		return fmt.Errorf("redirect path must begin with a slash: %q", rd.Path)
+	}
+	if rd.Target == "" {
+		return fmt.Errorf("redirect target is missing for %s", rd.Path)
+	}
+	switch rd.Status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return nil
+	}
+	return fmt.Errorf("redirect status for %s must be one of 301, 302, 303, 307, 308, got %d", rd.Path, rd.Status)
+}
+
+// location returns the URL the given request should be redirected to.
+func (rd Redirect) location(r *http.Request) string {
+	if !rd.PreserveQuery || r.URL.RawQuery == "" {
+		return rd.Target
+	}
+	if strings.Contains(rd.Target, "?") {
+		return rd.Target + "&" + r.URL.RawQuery
+	}
+	return rd.Target + "?" + r.URL.RawQuery
+}
+
+// redirectTable holds an API's redirects, shared by every copy of the API,
+// so they can be replaced while the API is running.
+type redirectTable struct {
+	sync.RWMutex
+	redirects map[string]Redirect
+}
+
+func (t *redirectTable) get(path string) (Redirect, bool) {
+	t.RLock()
+	defer t.RUnlock()
+	rd, ok := t.redirects[path]
+	return rd, ok
+}
+
+// SetRedirects validates the given redirects, and replaces the API's redirect
+// table with them. If any redirect is invalid, the table is left unchanged.
+func (api *API) SetRedirects(redirects []Redirect) error {
+	table := map[string]Redirect{}
+	for _, rd := range redirects {
+		if rd.Status == 0 {
+			rd.Status = http.StatusMovedPermanently
+		}
+		if err := rd.validate(); err != nil {
+			return err
+		}
+		table[rd.Path] = rd
+	}
+	api.redirects.Lock()
+	defer api.redirects.Unlock()
+	api.redirects.redirects = table
+	return nil
+}
+
+// LoadRedirects reads redirects from the given json file, and replaces the
+// API's redirect table with them. The file contains an array of redirects:
+//
+//	[
+//		{"path": "/old", "target": "/new", "status": 301, "preserve_query": true}
+//	]
+func (api *API) LoadRedirects(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var redirects []Redirect
+	if err := json.NewDecoder(f).Decode(&redirects); err != nil {
+		return fmt.Errorf("could not parse redirects file %s: %v", path, err)
+	}
+	return api.SetRedirects(redirects)
+}
+
+// ReloadRedirects reloads the redirects from the file configured with the
+// REDIRECTS_FILE environment variable. It is called when the process
+// receives a SIGHUP, so redirects can be changed without a restart.
+func (api *API) ReloadRedirects() error {
+	if conf.RedirectsFile == "" {
+		return nil
+	}
+	return api.LoadRedirects(conf.RedirectsFile)
+}
+
+// RedirectMiddleware redirects requests which match a path in the API's
+// redirect table, before they are routed. It is applied to the API's Server,
+// so paths which no longer have an endpoint are redirected too.
+func (api *API) RedirectMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if rd, ok := api.redirects.get(r.URL.Path); ok {
+			http.Redirect(rw, r, rd.location(r), rd.Status)
+			return
+		}
+		h.ServeHTTP(rw, r)
+	})
+}
+
+func (api *API) reloadRedirectsOnHangup() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		if err := api.ReloadRedirects(); err != nil {
+			log.Printf("Redirects could not be reloaded: %v", err)
+			continue
+		}
+		log.Printf("Reloaded redirects from %s", conf.RedirectsFile)
+	}
+}
@@ -0,0 +1,61 @@
+package hyperdrive
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+)
+
+func (suite *HyperdriveTestSuite) TestSetRedirects() {
+	suite.Nil(suite.TestAPI.SetRedirects([]Redirect{{Path: "/old", Target: "/new"}}), "expects no error")
+	rd, _ := suite.TestAPI.redirects.get("/old")
+	suite.Equal(http.StatusMovedPermanently, rd.Status, "expects the default status to be 301")
+}
+
+func (suite *HyperdriveTestSuite) TestSetRedirectsInvalid() {
+	suite.Error(suite.TestAPI.SetRedirects([]Redirect{{Path: "/old", Target: "/new", Status: 200}}), "expects an error for an invalid status")
+	suite.Error(suite.TestAPI.SetRedirects([]Redirect{{Path: "old", Target: "/new"}}), "expects an error for an invalid path")
+	suite.Error(suite.TestAPI.SetRedirects([]Redirect{{Path: "/old"}}), "expects an error for a missing target")
+}
+
+func (suite *HyperdriveTestSuite) TestRedirectMiddleware() {
+	suite.TestAPI.SetRedirects([]Redirect{{Path: "/old", Target: "/new", Status: http.StatusFound}})
+	rw := httptest.NewRecorder()
+	suite.TestAPI.RedirectMiddleware(suite.TestHandler).ServeHTTP(rw, httptest.NewRequest("GET", "/old?a=b", nil))
+	suite.Equal(http.StatusFound, rw.Code, "expects the configured status")
+	suite.Equal("/new", rw.Header().Get("Location"), "expects the query to be dropped")
+}
+
+func (suite *HyperdriveTestSuite) TestRedirectMiddlewarePreserveQuery() {
+	suite.TestAPI.SetRedirects([]Redirect{{Path: "/old", Target: "/new?c=d", PreserveQuery: true}})
+	rw := httptest.NewRecorder()
+	suite.TestAPI.Server.Handler.ServeHTTP(rw, httptest.NewRequest("GET", "/old?a=b", nil))
+	suite.Equal("/new?c=d&a=b", rw.Header().Get("Location"), "expects the query to be preserved")
+}
+
+func (suite *HyperdriveTestSuite) TestLoadRedirects() {
+	f, _ := ioutil.TempFile("", "redirects")
+	defer os.Remove(f.Name())
+	f.WriteString(`[{"path": "/old", "target": "https://example.com/new", "status": 308}]`)
+	f.Close()
+	suite.Nil(suite.TestAPI.LoadRedirects(f.Name()), "expects no error")
+	rd, _ := suite.TestAPI.redirects.get("/old")
+	suite.Equal(Redirect{Path: "/old", Target: "https://example.com/new", Status: 308}, rd, "expects the redirect to be loaded")
+}
+
+func (suite *HyperdriveTestSuite) TestLoadRedirectsError() {
+	suite.Error(suite.TestAPI.LoadRedirects("does-not-exist.json"), "expects an error if the file is missing")
+}
+
+func (suite *HyperdriveTestSuite) TestReloadRedirects() {
+	f, _ := ioutil.TempFile("", "redirects")
+	defer os.Remove(f.Name())
+	f.WriteString(`[{"path": "/old", "target": "/new"}]`)
+	f.Close()
+	conf.RedirectsFile = f.Name()
+	defer func() { conf.RedirectsFile = "" }()
+	suite.Nil(suite.TestAPI.ReloadRedirects(), "expects no error")
+	_, ok := suite.TestAPI.redirects.get("/old")
+	suite.True(ok, "expects the redirects to be reloaded")
+}
@@ -0,0 +1,102 @@
+package redis
+
+import (
+	"os"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/hyperdriven/hyperdrive"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultURL is the Redis server used by NewClientFromEnv, when REDIS_URL is
+// not set.
+const DefaultURL = "redis://localhost:6379/0"
+
+// NewClientFromEnv creates a Redis client, connecting to the server given by
+// the REDIS_URL environment variable (default: redis://localhost:6379/0),
+// e.g. redis://:password@redis.internal:6379/1. Share it between the
+// hyperdrive stores which use Redis (e.g. IdempotencyStore), rather than
+// creating a client for each of them.
+func NewClientFromEnv() (*goredis.Client, error) {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		url = DefaultURL
+	}
+	opts, err := goredis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return goredis.NewClient(opts), nil
+}
+
+// HealthCheck returns a hyperdrive.HealthCheck which pings the Redis server.
+func HealthCheck(client *goredis.Client) hyperdrive.HealthCheck {
+	return func() error {
+		return client.Ping().Err()
+	}
+}
+
+// Collector is a prometheus.Collector which exports the connection pool
+// stats of a Redis client.
+type Collector struct {
+	Client     *goredis.Client
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+// NewCollector creates an instance of Collector for the given client.
+func NewCollector(client *goredis.Client) *Collector {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc("hyperdrive_redis_pool_"+name, help, nil, nil)
+	}
+	return &Collector{
+		Client:     client,
+		hits:       desc("hits_total", "Number of times a free connection was found in the pool."),
+		misses:     desc("misses_total", "Number of times a free connection was not found in the pool."),
+		timeouts:   desc("timeouts_total", "Number of times a wait for a connection timed out."),
+		totalConns: desc("connections", "Number of connections in the pool."),
+		idleConns:  desc("idle_connections", "Number of idle connections in the pool."),
+		staleConns: desc("stale_connections_total", "Number of stale connections removed from the pool."),
+	}
+}
+
+// Describe satisfies the prometheus.Collector interface.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.staleConns
+}
+
+// Collect satisfies the prometheus.Collector interface.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.Client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+}
+
+// Instrument adds a "redis" health check for the given client to the API,
+// and registers a Collector for its connection pool stats, e.g.
+//
+//	client, err := redis.NewClientFromEnv()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	if err := redis.Instrument(&api, client); err != nil {
+//		log.Fatal(err)
+//	}
+//	api.SetMiddlewareChain(api.DefaultMiddleware().Append(api.IdempotencyMiddleware(redis.NewIdempotencyStore(client))))
+func Instrument(api *hyperdrive.API, client *goredis.Client) error {
+	api.AddHealthCheck("redis", HealthCheck(client))
+	return api.RegisterMetrics(NewCollector(client))
+}
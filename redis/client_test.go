@@ -0,0 +1,47 @@
+package redis
+
+import (
+	"net/http/httptest"
+	"os"
+
+	"github.com/hyperdriven/hyperdrive"
+)
+
+func (suite *RedisTestSuite) TestNewClientFromEnv() {
+	os.Setenv("REDIS_URL", "redis://"+suite.Server.Addr()+"/0")
+	defer os.Unsetenv("REDIS_URL")
+	client, err := NewClientFromEnv()
+	suite.Nil(err, "expects no error")
+	defer client.Close()
+	suite.Nil(client.Ping().Err(), "expects a client connected to REDIS_URL")
+}
+
+func (suite *RedisTestSuite) TestNewClientFromEnvDefault() {
+	client, err := NewClientFromEnv()
+	suite.Nil(err, "expects no error")
+	suite.Equal("localhost:6379", client.Options().Addr, "expects the default URL to be used")
+}
+
+func (suite *RedisTestSuite) TestNewClientFromEnvError() {
+	os.Setenv("REDIS_URL", "http://localhost")
+	defer os.Unsetenv("REDIS_URL")
+	_, err := NewClientFromEnv()
+	suite.Error(err, "expects an error for an invalid REDIS_URL")
+}
+
+func (suite *RedisTestSuite) TestHealthCheck() {
+	suite.Nil(HealthCheck(suite.Client)(), "expects no error when the server is up")
+	suite.Server.Close()
+	suite.Error(HealthCheck(suite.Client)(), "expects an error when the server is down")
+}
+
+func (suite *RedisTestSuite) TestInstrument() {
+	api := hyperdrive.NewAPI("API", "Test API Desc")
+	suite.Nil(Instrument(&api, suite.Client), "expects no error")
+	statuses := api.CheckHealth()
+	suite.Equal("redis", statuses[0].Name, "expects a redis health check")
+	suite.True(statuses[0].Healthy, "expects the redis health check to pass")
+	rw := httptest.NewRecorder()
+	api.MetricsHandler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	suite.Contains(rw.Body.String(), "hyperdrive_redis_pool_connections", "expects the pool stats to be exported")
+}
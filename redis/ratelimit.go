@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+)
+
+// RateLimitStore is an implementation of hyperdrive.RateLimitStore, which
+// counts requests in Redis, so limits are enforced consistently by every
+// instance of an API. Requests are counted in a sliding window: a sorted set
+// per key holds the time of each request made within the last window, so
+// clients can not make twice their limit across the boundary of a fixed
+// window. Requests over the limit are counted too, so clients which keep
+// retrying stay limited. Keys are namespaced with the given Prefix.
+type RateLimitStore struct {
+	Client *goredis.Client
+	Prefix string
+}
+
+// NewRateLimitStore creates an instance of RateLimitStore.
+func NewRateLimitStore(client *goredis.Client) *RateLimitStore {
+	return &RateLimitStore{Client: client, Prefix: "hyperdrive:ratelimit:"}
+}
+
+// Increment adds a request to the window for the key, and returns the number
+// of requests made within the window, and when the oldest of them leaves it.
+func (s *RateLimitStore) Increment(key string, window time.Duration) (int, time.Time, error) {
+	now := time.Now()
+	k := s.Prefix + key
+	var (
+		count  *goredis.IntCmd
+		oldest *goredis.ZSliceCmd
+	)
+	_, err := s.Client.TxPipelined(func(pipe goredis.Pipeliner) error {
+		pipe.ZRemRangeByScore(k, "-inf", strconv.FormatInt(now.Add(-window).UnixNano(), 10))
+		pipe.ZAdd(k, goredis.Z{Score: float64(now.UnixNano()), Member: strconv.FormatInt(now.UnixNano(), 10) + ":" + strconv.Itoa(rand.Int())})
+		count = pipe.ZCard(k)
+		oldest = pipe.ZRangeWithScores(k, 0, 0)
+		pipe.PExpire(k, window)
+		return nil
+	})
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	reset := now.Add(window)
+	if z := oldest.Val(); len(z) > 0 {
+		reset = time.Unix(0, int64(z[0].Score)).Add(window)
+	}
+	return int(count.Val()), reset, nil
+}
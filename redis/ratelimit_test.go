@@ -0,0 +1,41 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/hyperdriven/hyperdrive"
+)
+
+func (suite *RedisTestSuite) TestRateLimitStore() {
+	suite.Implements((*hyperdrive.RateLimitStore)(nil), NewRateLimitStore(suite.Client), "expects an implementation of hyperdrive.RateLimitStore")
+}
+
+func (suite *RedisTestSuite) TestRateLimitStoreIncrement() {
+	store := NewRateLimitStore(suite.Client)
+	first := time.Now()
+	count, _, err := store.Increment("a", time.Minute)
+	suite.Nil(err, "expects no error")
+	suite.Equal(1, count, "expects the first request")
+	count, reset, _ := store.Increment("a", time.Minute)
+	suite.Equal(2, count, "expects requests within the window to be counted together")
+	suite.WithinDuration(first.Add(time.Minute), reset, time.Second, "expects the window to reset when the oldest request leaves it")
+	count, _, _ = store.Increment("b", time.Minute)
+	suite.Equal(1, count, "expects keys to be counted separately")
+	suite.True(suite.Server.Exists("hyperdrive:ratelimit:a"), "expects keys to be prefixed")
+}
+
+func (suite *RedisTestSuite) TestRateLimitStoreSlidingWindow() {
+	store := NewRateLimitStore(suite.Client)
+	store.Increment("a", 20*time.Millisecond)
+	time.Sleep(15 * time.Millisecond)
+	store.Increment("a", 20*time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	count, _, _ := store.Increment("a", 20*time.Millisecond)
+	suite.Equal(2, count, "expects requests older than the window to be discarded")
+}
+
+func (suite *RedisTestSuite) TestRateLimitStoreExpires() {
+	store := NewRateLimitStore(suite.Client)
+	store.Increment("a", time.Minute)
+	suite.True(suite.Server.TTL("hyperdrive:ratelimit:a") > 0, "expects idle keys to expire")
+}
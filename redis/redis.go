@@ -0,0 +1,61 @@
+// Package redis provides Redis backed implementations of the pluggable stores
+// used by hyperdrive's middleware, so their state can be shared by every
+// instance of an API, along with helpers to create a client from the
+// environment, which every store can share, and to monitor it.
+package redis
+
+import (
+	"encoding/json"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/hyperdriven/hyperdrive"
+)
+
+// IdempotencyStore is an implementation of hyperdrive.IdempotencyStore,
+// which stores responses in Redis. Keys are namespaced with the given Prefix.
+type IdempotencyStore struct {
+	Client *goredis.Client
+	Prefix string
+}
+
+// NewIdempotencyStore creates an instance of IdempotencyStore.
+func NewIdempotencyStore(client *goredis.Client) *IdempotencyStore {
+	return &IdempotencyStore{Client: client, Prefix: "hyperdrive:idempotency:"}
+}
+
+// Get returns the response stored for the given key, or nil if there is none.
+func (s *IdempotencyStore) Get(key string) (*hyperdrive.StoredResponse, error) {
+	b, err := s.Client.Get(s.Prefix + key).Bytes()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resp hyperdrive.StoredResponse
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Set stores the response for the given key, until the ttl expires.
+func (s *IdempotencyStore) Set(key string, resp *hyperdrive.StoredResponse, ttl time.Duration) error {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return s.Client.Set(s.Prefix+key, b, ttl).Err()
+}
+
+// Lock locks the given key until Unlock is called, or the ttl expires. It
+// returns false if the key is already locked.
+func (s *IdempotencyStore) Lock(key string, ttl time.Duration) (bool, error) {
+	return s.Client.SetNX(s.Prefix+"lock:"+key, 1, ttl).Result()
+}
+
+// Unlock unlocks the given key.
+func (s *IdempotencyStore) Unlock(key string) error {
+	return s.Client.Del(s.Prefix + "lock:" + key).Err()
+}
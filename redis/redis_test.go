@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	goredis "github.com/go-redis/redis"
+	"github.com/hyperdriven/hyperdrive"
+	"github.com/stretchr/testify/suite"
+)
+
+type RedisTestSuite struct {
+	suite.Suite
+	Server *miniredis.Miniredis
+	Client *goredis.Client
+	Store  *IdempotencyStore
+}
+
+func (suite *RedisTestSuite) SetupTest() {
+	suite.Server, _ = miniredis.Run()
+	suite.Client = goredis.NewClient(&goredis.Options{Addr: suite.Server.Addr()})
+	suite.Store = NewIdempotencyStore(suite.Client)
+}
+
+func (suite *RedisTestSuite) TearDownTest() {
+	suite.Client.Close()
+	suite.Server.Close()
+}
+
+func (suite *RedisTestSuite) TestIdempotencyStore() {
+	suite.Implements((*hyperdrive.IdempotencyStore)(nil), suite.Store, "expects an implementation of hyperdrive.IdempotencyStore")
+}
+
+func (suite *RedisTestSuite) TestIdempotencyStoreGetMissing() {
+	resp, err := suite.Store.Get("missing")
+	suite.Nil(err, "expects no error")
+	suite.Nil(resp, "expects no response")
+}
+
+func (suite *RedisTestSuite) TestIdempotencyStoreSet() {
+	stored := &hyperdrive.StoredResponse{Status: 201, Header: http.Header{"Content-Type": []string{"application/json"}}, Body: []byte(`{"id":1}`)}
+	suite.Nil(suite.Store.Set("key", stored, time.Minute), "expects no error")
+	resp, _ := suite.Store.Get("key")
+	suite.Equal(stored, resp, "expects the stored response")
+}
+
+func (suite *RedisTestSuite) TestIdempotencyStoreLock() {
+	locked, _ := suite.Store.Lock("key", time.Minute)
+	suite.True(locked, "expects the lock to be acquired")
+	locked, _ = suite.Store.Lock("key", time.Minute)
+	suite.False(locked, "expects the lock to be held")
+	suite.Store.Unlock("key")
+	locked, _ = suite.Store.Lock("key", time.Minute)
+	suite.True(locked, "expects the lock to be released")
+}
+
+func TestRedisTestSuite(t *testing.T) {
+	suite.Run(t, new(RedisTestSuite))
+}
@@ -0,0 +1,120 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// RenderOptions changes how RespondJSON and RespondXML write the body:
+// Indent, when set, puts each nested value on its own line, indented by it
+// (e.g. "  "), and ContentType replaces the default Content-Type, e.g. with
+// the media type of an endpoint (see GetContentTypeJSON). Request, when
+// given, is the request responded to, so 200 OK responses get an ETag when
+// UsesETag is true for it (see NotModifiedBody).
+type RenderOptions struct {
+	Indent      string
+	ContentType string
+	Request     *http.Request
+}
+
+// RespondJSON responds with the given status, and v encoded as JSON, with a
+// Content-Type of application/json (unless RenderOptions give another), so
+// handlers need not write their own encoders. v is encoded before anything is
+// written, so if it can not be, RespondJSON responds with a 500 Internal
+// Server Error instead (see RespondError), and returns the error. v is
+// written in an Envelope for enveloped endpoints (see Enveloper).
+func RespondJSON(rw http.ResponseWriter, status int, v interface{}, opts ...RenderOptions) error {
+	if isEnveloped(rw) {
+		v = envelopeBody(v)
+	}
+	o := renderOptions(opts, "application/json; charset=utf-8")
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", o.Indent)
+	if err := enc.Encode(v); err != nil {
+		RespondError(rw, http.StatusInternalServerError, err)
+		return err
+	}
+	if o.notModified(rw, status, buf.Bytes()) {
+		return nil
+	}
+	writeRendered(rw, status, o.ContentType, buf.Bytes())
+	return nil
+}
+
+// RespondXML responds with the given status, and v encoded as XML, as
+// RespondJSON does, with a Content-Type of application/xml.
+func RespondXML(rw http.ResponseWriter, status int, v interface{}, opts ...RenderOptions) error {
+	if isEnveloped(rw) {
+		v = envelopeBody(v)
+	}
+	o := renderOptions(opts, "application/xml; charset=utf-8")
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", o.Indent)
+	if err := enc.Encode(v); err != nil {
+		RespondError(rw, http.StatusInternalServerError, err)
+		return err
+	}
+	buf.WriteString("\n")
+	if o.notModified(rw, status, buf.Bytes()) {
+		return nil
+	}
+	writeRendered(rw, status, o.ContentType, buf.Bytes())
+	return nil
+}
+
+// RespondError responds with the given status, and an
+// application/problem+json body describing err (see GetErrorText, so its
+// message is not leaked in production). A *ValidationError is written with
+// its FieldErrors, as by WriteValidationError, and an *Error with its own
+// status and code, whatever the status.
+func RespondError(rw http.ResponseWriter, status int, err error) {
+	if verr, ok := err.(*ValidationError); ok {
+		WriteValidationError(rw, verr)
+		return
+	}
+	var herr *Error
+	if errors.As(err, &herr) {
+		RespondProblem(rw, herr.problem())
+		return
+	}
+	writeProblem(rw, status, GetErrorText(status, err))
+}
+
+// renderOptions returns the first of the RenderOptions, with the default
+// Content-Type if it gives none.
+func renderOptions(opts []RenderOptions, contentType string) RenderOptions {
+	var o RenderOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.ContentType == "" {
+		o.ContentType = contentType
+	}
+	return o
+}
+
+// notModified sets the ETag of a 200 OK response to the request of the
+// options, when UsesETag is true for it, and returns true if it has been
+// responded to with a 304 Not Modified.
+func (o RenderOptions) notModified(rw http.ResponseWriter, status int, body []byte) bool {
+	if o.Request == nil || status != http.StatusOK || !UsesETag(o.Request) {
+		return false
+	}
+	return NotModifiedBody(rw, o.Request, body)
+}
+
+// writeRendered writes the headers, status, and encoded body of a response.
+func writeRendered(rw http.ResponseWriter, status int, contentType string, body []byte) {
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(status)
+	rw.Write(body)
+}
@@ -0,0 +1,63 @@
+package hyperdrive
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+)
+
+type renderTarget struct {
+	XMLName xml.Name `json:"-" xml:"user"`
+	Name    string   `json:"name" xml:"name"`
+}
+
+func (suite *HyperdriveTestSuite) TestRespondJSON() {
+	rw := httptest.NewRecorder()
+	err := RespondJSON(rw, http.StatusCreated, renderTarget{Name: "test"})
+	suite.Nil(err, "expects no error")
+	suite.Equal(http.StatusCreated, rw.Code, "expects the status to be written")
+	suite.Equal("application/json; charset=utf-8", rw.Header().Get("Content-Type"), "expects a JSON Content-Type")
+	suite.Equal("16", rw.Header().Get("Content-Length"), "expects the Content-Length to be set")
+	suite.Equal("{\"name\":\"test\"}\n", rw.Body.String(), "expects the body to be encoded as JSON")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondJSONOptions() {
+	rw := httptest.NewRecorder()
+	err := RespondJSON(rw, http.StatusOK, renderTarget{Name: "test"}, RenderOptions{Indent: "  ", ContentType: "application/vnd.api.test.v1.json"})
+	suite.Nil(err, "expects no error")
+	suite.Equal("application/vnd.api.test.v1.json", rw.Header().Get("Content-Type"), "expects the given Content-Type")
+	suite.Equal("{\n  \"name\": \"test\"\n}\n", rw.Body.String(), "expects the body to be indented")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondJSONError() {
+	rw := httptest.NewRecorder()
+	err := RespondJSON(rw, http.StatusOK, map[string]interface{}{"c": make(chan int)})
+	suite.Error(err, "expects an error for values which can not be encoded")
+	suite.Equal(http.StatusInternalServerError, rw.Code, "expects a 500")
+	suite.Equal(problemContentType, rw.Header().Get("Content-Type"), "expects a problem")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondXML() {
+	rw := httptest.NewRecorder()
+	err := RespondXML(rw, http.StatusOK, renderTarget{Name: "test"})
+	suite.Nil(err, "expects no error")
+	suite.Equal("application/xml; charset=utf-8", rw.Header().Get("Content-Type"), "expects an XML Content-Type")
+	suite.Equal(`<?xml version="1.0" encoding="UTF-8"?>`+"\n<user><name>test</name></user>\n", rw.Body.String(), "expects the body to be encoded as XML")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondError() {
+	defer func(c Config) { conf = c }(conf)
+	conf.Env = "development"
+	rw := httptest.NewRecorder()
+	RespondError(rw, http.StatusConflict, errors.New("already exists"))
+	suite.Equal(http.StatusConflict, rw.Code, "expects the status to be written")
+	suite.Equal(problemContentType, rw.Header().Get("Content-Type"), "expects a problem")
+	suite.Contains(rw.Body.String(), `"detail":"already exists"`, "expects the error to be described")
+
+	rw = httptest.NewRecorder()
+	verr := &ValidationError{}
+	verr.AddParamError(InQuery, "limit", ValidationRequired, "Missing required parameter: limit")
+	RespondError(rw, http.StatusUnprocessableEntity, verr)
+	suite.Equal(http.StatusBadRequest, rw.Code, "expects validation errors to be written as by WriteValidationError")
+}
@@ -0,0 +1,42 @@
+package hyperdrive
+
+import (
+	"net/http"
+)
+
+// RequestIDHeader is the header used to pass the ID of a request between
+// services. If a request has a valid ID in this header, it is used, otherwise
+// a new one is generated. Either way, the ID is sent back in the same header
+// of the response.
+const RequestIDHeader = "X-Request-Id"
+
+// RequestID returns the ID of the given request, or an empty string if the
+// request is not being instrumented.
+func RequestID(r *http.Request) string {
+	if s := GetResponseStats(r); s != nil {
+		return s.RequestID
+	}
+	return ""
+}
+
+// requestID returns the ID given by the request's X-Request-Id header, if it
+// is valid (at most 128 printable ASCII characters), or a new ID (see
+// NewID).
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+	return NewID()
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
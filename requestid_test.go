@@ -0,0 +1,36 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestRequestID() {
+	var id string
+	rw := httptest.NewRecorder()
+	instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id = RequestID(r)
+	})).ServeHTTP(rw, suite.TestGetRequest)
+	suite.Equal(32, len(id), "expects a new ID to be generated")
+	suite.Equal(id, rw.Header().Get(RequestIDHeader), "expects the ID to be sent with the response")
+}
+
+func (suite *HyperdriveTestSuite) TestRequestIDFromHeader() {
+	var id string
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set(RequestIDHeader, "abc-123")
+	instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id = RequestID(r)
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	suite.Equal("abc-123", id, "expects the ID given by the client to be used")
+}
+
+func (suite *HyperdriveTestSuite) TestRequestIDInvalidHeader() {
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set(RequestIDHeader, "abc 123")
+	suite.NotEqual("abc 123", requestID(r), "expects invalid IDs to be replaced")
+}
+
+func (suite *HyperdriveTestSuite) TestRequestIDNotInstrumented() {
+	suite.Equal("", RequestID(suite.TestGetRequest), "expects an empty ID when the request is not instrumented")
+}
@@ -0,0 +1,189 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrHeadersSent is returned by Response when the status or headers are
+	// changed after they have been sent to the client.
+	ErrHeadersSent = errors.New("headers have already been sent")
+
+	// ErrFlushNotSupported is returned by Response.Flush when the underlying
+	// http.ResponseWriter does not support flushing.
+	ErrFlushNotSupported = errors.New("http.Flusher is not supported by the underlying http.ResponseWriter")
+)
+
+// committer is implemented by writers which know whether the headers have been
+// sent, e.g. the instrumented writer used by every MiddlewareChain.
+type committer interface {
+	Committed() bool
+}
+
+// flushChecker is implemented by writers which wrap another writer, and know
+// whether it supports flushing.
+type flushChecker interface {
+	CanFlush() bool
+}
+
+// Response wraps an http.ResponseWriter, giving handlers explicit control of
+// when the status and headers are sent (committed), and when the body is
+// flushed to the client. In buffered mode, the body is held in memory until
+// Flush is called, so the status and headers can still be changed, or the
+// response reset, e.g. when an error occurs halfway through rendering.
+//
+// Response satisfies the http.ResponseWriter interface, so it can be passed
+// to anything which expects one, e.g. Respond.
+type Response struct {
+	rw        http.ResponseWriter
+	status    int
+	buffered  bool
+	buf       bytes.Buffer
+	committed bool
+}
+
+// NewResponse creates an instance of Response, wrapping the given
+// http.ResponseWriter.
+func NewResponse(rw http.ResponseWriter) *Response {
+	if resp, ok := rw.(*Response); ok {
+		return resp
+	}
+	return &Response{rw: rw, status: http.StatusOK}
+}
+
+// Header returns the header map that will be sent when the response is
+// committed.
+func (resp *Response) Header() http.Header {
+	return resp.rw.Header()
+}
+
+// Committed returns true if the status and headers have been sent to the
+// client, by this Response, or by an earlier write to the underlying
+// http.ResponseWriter.
+func (resp *Response) Committed() bool {
+	if resp.committed {
+		return true
+	}
+	if c, ok := resp.rw.(committer); ok {
+		return c.Committed()
+	}
+	return false
+}
+
+// SetHeader sets a header, returning ErrHeadersSent if the headers have
+// already been committed.
+func (resp *Response) SetHeader(key string, value string) error {
+	if resp.Committed() {
+		return ErrHeadersSent
+	}
+	resp.rw.Header().Set(key, value)
+	return nil
+}
+
+// SetStatus sets the status code which will be sent when the response is
+// committed, returning ErrHeadersSent if it already has been.
+func (resp *Response) SetStatus(status int) error {
+	if resp.Committed() {
+		return ErrHeadersSent
+	}
+	resp.status = status
+	return nil
+}
+
+// Status returns the status code of the response.
+func (resp *Response) Status() int {
+	return resp.status
+}
+
+// Buffer switches the response to buffered mode, returning ErrHeadersSent if
+// the headers have already been committed.
+func (resp *Response) Buffer() error {
+	if resp.Committed() {
+		return ErrHeadersSent
+	}
+	resp.buffered = true
+	return nil
+}
+
+// Buffered returns the number of bytes written to the buffer, which have not
+// yet been flushed.
+func (resp *Response) Buffered() int {
+	return resp.buf.Len()
+}
+
+// Reset discards the buffered body, and all headers set so far, so a
+// different response can be written instead. It returns ErrHeadersSent if
+// the headers have already been committed.
+func (resp *Response) Reset() error {
+	if resp.Committed() {
+		return ErrHeadersSent
+	}
+	resp.buf.Reset()
+	resp.status = http.StatusOK
+	for k := range resp.rw.Header() {
+		delete(resp.rw.Header(), k)
+	}
+	return nil
+}
+
+// Commit sends the status and headers to the client. It returns
+// ErrHeadersSent if they have already been sent.
+func (resp *Response) Commit() error {
+	if resp.Committed() {
+		return ErrHeadersSent
+	}
+	resp.committed = true
+	resp.rw.WriteHeader(resp.status)
+	return nil
+}
+
+// WriteHeader satisfies the http.ResponseWriter interface, and sets the
+// status code. Unless the response is buffered, the status and headers are
+// also committed. Use SetStatus and Commit to be told when the headers have
+// already been sent.
+func (resp *Response) WriteHeader(status int) {
+	if resp.SetStatus(status) == nil && !resp.buffered {
+		resp.Commit()
+	}
+}
+
+// Write writes the body to the buffer, in buffered mode. Otherwise, the
+// status and headers are committed (if they have not been already) and the
+// body is written to the client.
+func (resp *Response) Write(b []byte) (int, error) {
+	if resp.buffered {
+		return resp.buf.Write(b)
+	}
+	if !resp.Committed() {
+		resp.Commit()
+	}
+	return resp.rw.Write(b)
+}
+
+// Flush commits the status and headers (if they have not been already),
+// writes any buffered body, and flushes it to the client. Buffered mode
+// remains enabled, so subsequent writes are buffered until the next Flush.
+// It returns ErrFlushNotSupported if the underlying http.ResponseWriter can
+// not be flushed, after writing the buffered body.
+func (resp *Response) Flush() error {
+	if !resp.Committed() {
+		resp.Commit()
+	}
+	if resp.buf.Len() > 0 {
+		if _, err := resp.rw.Write(resp.buf.Bytes()); err != nil {
+			return err
+		}
+		resp.buf.Reset()
+	}
+	if fc, ok := resp.rw.(flushChecker); ok && !fc.CanFlush() {
+		return ErrFlushNotSupported
+	}
+	f, ok := resp.rw.(http.Flusher)
+	if !ok {
+		return ErrFlushNotSupported
+	}
+	f.Flush()
+	return nil
+}
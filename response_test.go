@@ -0,0 +1,81 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+type nonFlushingWriter struct {
+	http.ResponseWriter
+}
+
+func (suite *HyperdriveTestSuite) TestNewResponse() {
+	suite.Implements((*http.ResponseWriter)(nil), NewResponse(httptest.NewRecorder()), "expects an implementation of http.ResponseWriter")
+}
+
+func (suite *HyperdriveTestSuite) TestNewResponseWrapped() {
+	resp := NewResponse(httptest.NewRecorder())
+	suite.Equal(resp, NewResponse(resp), "expects an existing Response to be reused")
+}
+
+func (suite *HyperdriveTestSuite) TestResponseWrite() {
+	rw := httptest.NewRecorder()
+	resp := NewResponse(rw)
+	resp.Write([]byte("ok"))
+	suite.True(resp.Committed(), "expects the headers to be committed")
+	suite.Equal(ErrHeadersSent, resp.SetHeader("X-Test", "1"), "expects an error setting headers after they are sent")
+	suite.Equal(ErrHeadersSent, resp.SetStatus(http.StatusCreated), "expects an error setting the status after it is sent")
+}
+
+func (suite *HyperdriveTestSuite) TestResponseBuffered() {
+	rw := httptest.NewRecorder()
+	resp := NewResponse(rw)
+	suite.Nil(resp.Buffer(), "expects buffered mode to be enabled")
+	resp.Write([]byte("ok"))
+	suite.False(resp.Committed(), "expects the headers not to be committed")
+	suite.Equal(2, resp.Buffered(), "expects the body to be buffered")
+	suite.Nil(resp.SetStatus(http.StatusCreated), "expects the status can still be changed")
+	suite.Nil(resp.Flush(), "expects the response to be flushed")
+	suite.Equal(http.StatusCreated, rw.Code, "expects the status to be sent")
+	suite.Equal("ok", rw.Body.String(), "expects the body to be sent")
+}
+
+func (suite *HyperdriveTestSuite) TestResponseReset() {
+	rw := httptest.NewRecorder()
+	resp := NewResponse(rw)
+	resp.Buffer()
+	resp.SetHeader("X-Test", "1")
+	resp.Write([]byte("partial"))
+	suite.Nil(resp.Reset(), "expects the response to be reset")
+	resp.Write([]byte("ok"))
+	resp.Flush()
+	suite.Equal("", rw.Header().Get("X-Test"), "expects headers to be discarded")
+	suite.Equal("ok", rw.Body.String(), "expects the buffered body to be discarded")
+}
+
+func (suite *HyperdriveTestSuite) TestResponseCommit() {
+	resp := NewResponse(httptest.NewRecorder())
+	suite.Nil(resp.Commit(), "expects the headers to be committed")
+	suite.Equal(ErrHeadersSent, resp.Commit(), "expects an error committing twice")
+	suite.Equal(ErrHeadersSent, resp.Buffer(), "expects an error buffering after commit")
+}
+
+func (suite *HyperdriveTestSuite) TestResponseFlushNotSupported() {
+	resp := NewResponse(nonFlushingWriter{httptest.NewRecorder()})
+	suite.Equal(ErrFlushNotSupported, resp.Flush(), "expects an error when flushing is not supported")
+}
+
+func (suite *HyperdriveTestSuite) TestResponseInstrumented() {
+	h := NewMiddlewareChain().Then(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusAccepted)
+		suite.True(NewResponse(rw).Committed(), "expects headers sent by an earlier write to be detected")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+}
+
+func (suite *HyperdriveTestSuite) TestResponseInstrumentedFlushNotSupported() {
+	h := NewMiddlewareChain().Then(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		suite.Equal(ErrFlushNotSupported, NewResponse(rw).Flush(), "expects an error when flushing is not supported")
+	}))
+	h.ServeHTTP(nonFlushingWriter{httptest.NewRecorder()}, suite.TestGetRequest)
+}
@@ -0,0 +1,90 @@
+package hyperdrive
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The arms of a Rollout, as labeled in the rollout_requests_total metric.
+const (
+	RolloutTreatment = "treatment"
+	RolloutControl   = "control"
+)
+
+// Rollout configures which requests RolloutMiddleware applies its middleware
+// to. Percent (0 to 100) of requests are in the treatment arm, chosen at
+// random, or by hashing the value returned by Key when it is set, so each
+// client (e.g. principal) consistently gets the same arm. If Header is set,
+// and the request has it, its value forces the arm: "on", "true" or "1" for
+// the treatment, and "off", "false" or "0" for the control. Name labels the
+// rollout's metrics.
+type Rollout struct {
+	Name    string
+	Percent float64
+	Header  string
+	Key     func(r *http.Request) string
+}
+
+// arm returns the arm of the rollout the request is in.
+func (ro Rollout) arm(r *http.Request) string {
+	if ro.Header != "" {
+		switch strings.ToLower(r.Header.Get(ro.Header)) {
+		case "on", "true", "1":
+			return RolloutTreatment
+		case "off", "false", "0":
+			return RolloutControl
+		}
+	}
+	var bucket float64
+	if k := ro.key(r); k != "" {
+		h := fnv.New32a()
+		h.Write([]byte(ro.Name + ":" + k))
+		bucket = float64(h.Sum32()%10000) / 100
+	} else {
+		bucket = rand.Float64() * 100
+	}
+	if bucket < ro.Percent {
+		return RolloutTreatment
+	}
+	return RolloutControl
+}
+
+func (ro Rollout) key(r *http.Request) string {
+	if ro.Key == nil {
+		return ""
+	}
+	return ro.Key(r)
+}
+
+// RolloutMiddleware returns a Middleware which applies the given Middleware
+// only to the requests in the treatment arm of the Rollout, so risky
+// middleware (e.g. a new auth layer) can be rolled out gradually. Requests in
+// the control arm skip it. Requests are counted by rollout, arm and status in
+// the hyperdrive_http_rollout_requests_total metric, so the arms can be
+// compared, e.g.
+//
+//	api.RolloutMiddleware(api.APIKeyMiddleware(keys), hyperdrive.Rollout{
+//		Name:    "api-keys",
+//		Percent: 5,
+//		Header:  "X-Rollout-API-Keys",
+//	})
+func (api *API) RolloutMiddleware(m Middleware, ro Rollout) Middleware {
+	counter := api.metrics.rollouts
+	return func(h http.Handler) http.Handler {
+		treated := m(h)
+		return instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			arm := ro.arm(r)
+			GetResponseStats(r).OnComplete(func(r *http.Request, s *ResponseStats) {
+				counter.WithLabelValues(ro.Name, arm, strconv.Itoa(s.Status)).Inc()
+			})
+			if arm == RolloutTreatment {
+				treated.ServeHTTP(rw, r)
+				return
+			}
+			h.ServeHTTP(rw, r)
+		}))
+	}
+}
@@ -0,0 +1,58 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) rolloutHandler(ro Rollout) http.Handler {
+	m := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("X-Treated", "true")
+			h.ServeHTTP(rw, r)
+		})
+	}
+	return suite.TestAPI.RolloutMiddleware(m, ro)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+}
+
+func (suite *HyperdriveTestSuite) TestRolloutMiddlewarePercent() {
+	for percent, expected := range map[float64]string{0: "", 100: "true"} {
+		rw := httptest.NewRecorder()
+		suite.rolloutHandler(Rollout{Name: "test", Percent: percent}).ServeHTTP(rw, suite.TestGetRequest)
+		suite.Equal(expected, rw.Header().Get("X-Treated"), "expects the middleware to be applied to %v%% of requests", percent)
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestRolloutMiddlewareHeader() {
+	h := suite.rolloutHandler(Rollout{Name: "test", Percent: 0, Header: "X-Rollout"})
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("X-Rollout", "on")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	suite.Equal("true", rw.Header().Get("X-Treated"), "expects the header to force the treatment")
+	h = suite.rolloutHandler(Rollout{Name: "test", Percent: 100, Header: "X-Rollout"})
+	r.Header.Set("X-Rollout", "off")
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	suite.Equal("", rw.Header().Get("X-Treated"), "expects the header to force the control")
+}
+
+func (suite *HyperdriveTestSuite) TestRolloutMiddlewareKey() {
+	ro := Rollout{Name: "test", Percent: 50, Key: func(r *http.Request) string { return r.Header.Get("X-Client") }}
+	counts := map[string]int{}
+	for i := 0; i < 200; i++ {
+		r := httptest.NewRequest("GET", "/test", nil)
+		r.Header.Set("X-Client", string(rune('a'+i%20)))
+		counts[string(rune('a'+i%20))+ro.arm(r)]++
+	}
+	for k, n := range counts {
+		suite.Equal(10, n, "expects each client to consistently get the same arm: %s", k)
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestRolloutMiddlewareMetrics() {
+	suite.rolloutHandler(Rollout{Name: "new-auth", Percent: 100}).ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	rw := httptest.NewRecorder()
+	suite.TestAPI.MetricsHandler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	suite.Contains(rw.Body.String(), `hyperdrive_http_rollout_requests_total{arm="treatment",rollout="new-auth",status="200"} 1`, "expects requests to be counted by rollout, arm and status")
+}
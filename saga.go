@@ -0,0 +1,125 @@
+package hyperdrive
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// SagaStep is a single step of a Saga: an Action, e.g. reserving stock, and
+// the Compensation which undoes it, e.g. releasing the stock. Compensation
+// may be nil for steps which do not need to be undone.
+type SagaStep struct {
+	Name         string
+	Action       func(context.Context) error
+	Compensation func(context.Context) error
+}
+
+// Saga runs a sequence of steps, which each change a different dependency
+// (e.g. a payment provider, an inventory service, and the API's own
+// database), as a single unit: if a step fails, the steps already completed
+// are compensated, in reverse order. This keeps endpoints which write to
+// several dependencies consistent, without distributed transactions:
+//
+//	err := hyperdrive.NewSaga("checkout").
+//		Step("reserve-stock", reserve, release).
+//		Step("charge-card", charge, refund).
+//		Step("create-order", createOrder, nil).
+//		Run(r.Context())
+type Saga struct {
+	Name  string
+	Steps []SagaStep
+}
+
+// NewSaga creates an instance of Saga, with the given name, which is used in
+// logs and errors.
+func NewSaga(name string) *Saga {
+	return &Saga{Name: name}
+}
+
+// Step adds a step to the Saga, and returns the Saga, so steps can be
+// chained.
+func (s *Saga) Step(name string, action func(context.Context) error, compensation func(context.Context) error) *Saga {
+	s.Steps = append(s.Steps, SagaStep{Name: name, Action: action, Compensation: compensation})
+	return s
+}
+
+// SagaError is returned by Saga.Run when a step fails. Err is the error of the
+// failed Step, and CompensationErrors holds the errors of any compensations
+// which also failed, by step name; these steps may need to be undone by hand.
+type SagaError struct {
+	Saga               string
+	Step               string
+	Err                error
+	CompensationErrors map[string]error
+}
+
+// Error returns the failed step and its error, along with any failed
+// compensations.
+func (e *SagaError) Error() string {
+	msg := fmt.Sprintf("saga %s failed at step %s: %v", e.Saga, e.Step, e.Err)
+	if len(e.CompensationErrors) > 0 {
+		var failed []string
+		for step, err := range e.CompensationErrors {
+			failed = append(failed, fmt.Sprintf("%s: %v", step, err))
+		}
+		sort.Strings(failed)
+		msg += fmt.Sprintf(" (compensations failed: %s)", strings.Join(failed, ", "))
+	}
+	return msg
+}
+
+// Unwrap returns the error of the failed step.
+func (e *SagaError) Unwrap() error {
+	return e.Err
+}
+
+// Run runs each step in order, and stops at the first to fail (or panic),
+// compensating the steps which completed before it in reverse order, and
+// returning a *SagaError. Compensations are run even if the context has been
+// cancelled, e.g. by the client disconnecting, so they are not cut short.
+// Every failure, and compensation, is logged.
+func (s *Saga) Run(ctx context.Context) error {
+	for i, step := range s.Steps {
+		err := runSagaFunc(ctx, step.Action)
+		if err == nil {
+			continue
+		}
+		log.Printf("Saga %s failed at step %s: %v", s.Name, step.Name, err)
+		serr := &SagaError{Saga: s.Name, Step: step.Name, Err: err}
+		s.compensate(context.WithoutCancel(ctx), s.Steps[:i], serr)
+		return serr
+	}
+	return nil
+}
+
+func (s *Saga) compensate(ctx context.Context, completed []SagaStep, serr *SagaError) {
+	for i := len(completed) - 1; i >= 0; i-- {
+		step := completed[i]
+		if step.Compensation == nil {
+			continue
+		}
+		if err := runSagaFunc(ctx, step.Compensation); err != nil {
+			log.Printf("Saga %s could not compensate step %s: %v", s.Name, step.Name, err)
+			if serr.CompensationErrors == nil {
+				serr.CompensationErrors = map[string]error{}
+			}
+			serr.CompensationErrors[step.Name] = err
+			continue
+		}
+		log.Printf("Saga %s compensated step %s", s.Name, step.Name)
+	}
+}
+
+// runSagaFunc runs f, returning any panic as an error, so the completed steps
+// are still compensated.
+func runSagaFunc(ctx context.Context, f func(context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return f(ctx)
+}
@@ -0,0 +1,60 @@
+package hyperdrive
+
+import (
+	"context"
+	"errors"
+)
+
+// sagaRecorder returns an action which appends name to calls, and returns
+// err.
+func sagaRecorder(calls *[]string, name string, err error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		*calls = append(*calls, name)
+		return err
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestSagaRun() {
+	var calls []string
+	err := NewSaga("test").
+		Step("a", sagaRecorder(&calls, "a", nil), sagaRecorder(&calls, "undo a", nil)).
+		Step("b", sagaRecorder(&calls, "b", nil), sagaRecorder(&calls, "undo b", nil)).
+		Run(context.Background())
+	suite.Nil(err, "expects no error")
+	suite.Equal([]string{"a", "b"}, calls, "expects every step to run, in order")
+}
+
+func (suite *HyperdriveTestSuite) TestSagaCompensation() {
+	var calls []string
+	failed := errors.New("failed")
+	err := NewSaga("test").
+		Step("a", sagaRecorder(&calls, "a", nil), sagaRecorder(&calls, "undo a", nil)).
+		Step("b", sagaRecorder(&calls, "b", nil), nil).
+		Step("c", sagaRecorder(&calls, "c", nil), sagaRecorder(&calls, "undo c", nil)).
+		Step("d", sagaRecorder(&calls, "d", failed), sagaRecorder(&calls, "undo d", nil)).
+		Step("e", sagaRecorder(&calls, "e", nil), nil).
+		Run(context.Background())
+	suite.Equal([]string{"a", "b", "c", "d", "undo c", "undo a"}, calls, "expects completed steps to be compensated in reverse order")
+	var serr *SagaError
+	suite.True(errors.As(err, &serr), "expects a SagaError")
+	suite.Equal("d", serr.Step, "expects the failed step")
+	suite.True(errors.Is(err, failed), "expects the error of the failed step")
+	suite.Empty(serr.CompensationErrors, "expects no compensation errors")
+}
+
+func (suite *HyperdriveTestSuite) TestSagaCompensationErrors() {
+	var calls []string
+	ctx, cancel := context.WithCancel(context.Background())
+	err := NewSaga("test").
+		Step("a", sagaRecorder(&calls, "a", nil), func(ctx context.Context) error {
+			return ctx.Err()
+		}).
+		Step("b", sagaRecorder(&calls, "b", nil), sagaRecorder(&calls, "undo b", errors.New("refund failed"))).
+		Step("c", func(ctx context.Context) error {
+			cancel()
+			panic("boom")
+		}, nil).
+		Run(ctx)
+	suite.Equal("saga test failed at step c: panic: boom (compensations failed: b: refund failed)", err.Error(), "expects panics and failed compensations to be reported")
+	suite.Equal([]string{"a", "b", "undo b"}, calls, "expects earlier steps to be compensated after a failed compensation")
+}
@@ -0,0 +1,84 @@
+package hyperdrive
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"strings"
+)
+
+// sealer seals values handed to clients, which must come back unchanged
+// (session cookies and pagination cursors): they are encrypted with
+// AES-256-GCM, if there is an encryptionKey, and signed with HMAC-SHA256,
+// keyed by the secret, as "<value>.<signature>" (both base64 encoded). The
+// signature is bound to the name, so a value sealed for one use can not be
+// passed off as another.
+type sealer struct {
+	name          string
+	secret        string
+	encryptionKey string
+}
+
+// seal returns the sealed value of b.
+func (s sealer) seal(b []byte) (string, error) {
+	if s.encryptionKey != "" {
+		gcm, err := s.cipher()
+		if err != nil {
+			return "", err
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return "", err
+		}
+		b = gcm.Seal(nonce, nonce, b, nil)
+	}
+	value := base64.RawURLEncoding.EncodeToString(b)
+	return value + "." + s.signature(value), nil
+}
+
+// open verifies and decrypts a sealed value, and returns false if it was
+// tampered with, or not sealed by a sealer with the same keys and name.
+func (s sealer) open(sealed string) ([]byte, bool) {
+	parts := strings.SplitN(sealed, ".", 2)
+	if s.secret == "" || len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(s.signature(parts[0]))) {
+		return nil, false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, false
+	}
+	if s.encryptionKey != "" {
+		gcm, err := s.cipher()
+		if err != nil || len(b) < gcm.NonceSize() {
+			return nil, false
+		}
+		b, err = gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+		if err != nil {
+			return nil, false
+		}
+	}
+	return b, true
+}
+
+// signature returns the HMAC-SHA256 of the value, keyed by the secret, and
+// bound to the name.
+func (s sealer) signature(value string) string {
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	mac.Write([]byte(s.name + "=" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// cipher returns an AES-256-GCM cipher, keyed by the SHA-256 digest of the
+// encryptionKey.
+func (s sealer) cipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(s.encryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,18 @@
+package hyperdrive
+
+func (suite *HyperdriveTestSuite) TestSealer() {
+	for _, key := range []string{"", "encryption-key"} {
+		s := sealer{name: "cursor", secret: "secret", encryptionKey: key}
+		sealed, err := s.seal([]byte(`{"offset":10}`))
+		suite.Nil(err, "expects no error")
+		b, ok := s.open(sealed)
+		suite.True(ok, "expects the value to be opened")
+		suite.Equal(`{"offset":10}`, string(b), "expects the sealed value")
+		_, ok = sealer{name: "session", secret: "secret", encryptionKey: key}.open(sealed)
+		suite.False(ok, "expects values sealed for another name to be rejected")
+		_, ok = sealer{name: "cursor", secret: "other", encryptionKey: key}.open(sealed)
+		suite.False(ok, "expects values sealed with another secret to be rejected")
+	}
+	_, ok := sealer{name: "cursor"}.open("e30.")
+	suite.False(ok, "expects values to be rejected without a secret")
+}
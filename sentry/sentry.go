@@ -0,0 +1,49 @@
+// Package sentry provides a hyperdrive.PanicReporter which forwards panics
+// recovered by hyperdrive's RecoveryMiddleware to Sentry.
+//
+//	reporter, err := sentry.NewReporter(os.Getenv("SENTRY_DSN"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	api.AddPanicReporter(reporter)
+package sentry
+
+import (
+	"fmt"
+
+	raven "github.com/getsentry/raven-go"
+	"github.com/hyperdriven/hyperdrive"
+)
+
+// Reporter is an implementation of hyperdrive.PanicReporter, which sends
+// each panic to Sentry as an exception, along with the request's metadata.
+type Reporter struct {
+	Client *raven.Client
+}
+
+// NewReporter creates an instance of Reporter, with a Sentry client
+// configured to use the given DSN.
+func NewReporter(dsn string) (*Reporter, error) {
+	client, err := raven.New(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &Reporter{Client: client}, nil
+}
+
+// ReportPanic satisfies the hyperdrive.PanicReporter interface, and captures
+// the panic in Sentry.
+func (rep *Reporter) ReportPanic(p hyperdrive.Panic) {
+	err, ok := p.Value.(error)
+	if !ok {
+		err = fmt.Errorf("%v", p.Value)
+	}
+	trace := raven.NewStacktrace(2, 3, nil)
+	if p.Request == nil {
+		// e.g. a panic recovered by a consumer, rather than an HTTP handler.
+		rep.Client.Capture(raven.NewPacket(err.Error(), raven.NewException(err, trace)), nil)
+		return
+	}
+	packet := raven.NewPacket(err.Error(), raven.NewException(err, trace), raven.NewHttp(p.Request))
+	rep.Client.Capture(packet, map[string]string{"method": p.Request.Method})
+}
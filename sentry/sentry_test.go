@@ -0,0 +1,46 @@
+package sentry
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperdriven/hyperdrive"
+	"github.com/stretchr/testify/suite"
+)
+
+type SentryTestSuite struct {
+	suite.Suite
+	TestReporter *Reporter
+}
+
+func (suite *SentryTestSuite) SetupTest() {
+	suite.TestReporter, _ = NewReporter("")
+}
+
+func (suite *SentryTestSuite) TestNewReporter() {
+	suite.IsType(&Reporter{}, suite.TestReporter, "expects an instance of sentry.Reporter")
+}
+
+func (suite *SentryTestSuite) TestNewReporterError() {
+	_, err := NewReporter("not a dsn")
+	suite.Error(err, "expects an error if the DSN is invalid")
+}
+
+func (suite *SentryTestSuite) TestPanicReporter() {
+	suite.Implements((*hyperdrive.PanicReporter)(nil), suite.TestReporter, "expects an implementation of hyperdrive.PanicReporter")
+}
+
+func (suite *SentryTestSuite) TestReportPanic() {
+	p := hyperdrive.Panic{Value: errors.New("test panic"), Request: httptest.NewRequest("GET", "/test", nil)}
+	suite.NotPanics(func() { suite.TestReporter.ReportPanic(p) }, "expects the panic to be reported")
+}
+
+func (suite *SentryTestSuite) TestReportPanicWithoutRequest() {
+	p := hyperdrive.Panic{Value: "test panic"}
+	suite.NotPanics(func() { suite.TestReporter.ReportPanic(p) }, "expects panics without a request to be reported")
+}
+
+func TestSentryTestSuite(t *testing.T) {
+	suite.Run(t, new(SentryTestSuite))
+}
@@ -0,0 +1,309 @@
+package hyperdrive
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type sessionContextKey struct{}
+
+// maxCookieSize is the largest cookie browsers are required to accept.
+const maxCookieSize = 4096
+
+// ErrInvalidSession is returned when a session cookie has been tampered with,
+// could not be decrypted, or has expired.
+var ErrInvalidSession = errors.New("invalid session cookie")
+
+// SessionStore interface is implemented by server side session stores. When
+// a store is used, the session cookie only holds the (signed) session ID, and
+// the values are loaded from the store. Load returns nil values, and no
+// error, if the session does not exist.
+type SessionStore interface {
+	Load(id string) (map[string]interface{}, error)
+	Save(id string, values map[string]interface{}, maxAge time.Duration) error
+	Delete(id string) error
+}
+
+// MemorySessionStore is an implementation of SessionStore, which holds
+// sessions in memory, until they expire. It is intended for development, and
+// single instance deployments; sessions are lost when the process restarts.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	values  map[string]interface{}
+	expires time.Time
+}
+
+// NewMemorySessionStore creates an instance of MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]memorySession{}}
+}
+
+// Load returns the values of the session with the given ID, or nil if it does
+// not exist or has expired.
+func (s *MemorySessionStore) Load(id string) (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok || time.Now().After(sess.expires) {
+		delete(s.sessions, id)
+		return nil, nil
+	}
+	return sess.values, nil
+}
+
+// Save stores the values of the session with the given ID, until maxAge has
+// passed.
+func (s *MemorySessionStore) Save(id string, values map[string]interface{}, maxAge time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = memorySession{values: values, expires: time.Now().Add(maxAge)}
+	return nil
+}
+
+// Delete removes the session with the given ID.
+func (s *MemorySessionStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// SessionState holds the values of a session, as returned by Session. Values
+// are encoded as JSON, so once a session has been saved, numbers are returned
+// as float64, and structs as map[string]interface{}. It is safe for
+// concurrent use.
+type SessionState struct {
+	mu        sync.Mutex
+	id        string
+	values    map[string]interface{}
+	changed   bool
+	destroyed bool
+}
+
+func newSessionState() *SessionState {
+	return &SessionState{values: map[string]interface{}{}}
+}
+
+// Get returns the value with the given key, or nil if it is not set.
+func (s *SessionState) Get(key string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+// Set sets the value with the given key.
+func (s *SessionState) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	s.changed = true
+}
+
+// Delete removes the value with the given key.
+func (s *SessionState) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.changed = true
+	}
+}
+
+// Destroy removes every value, and expires the session cookie, e.g. when the
+// user logs out. Values set after Destroy are saved in a new session, with a
+// new ID, e.g. to prevent session fixation when the user logs in.
+func (s *SessionState) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = map[string]interface{}{}
+	s.changed = false
+	s.destroyed = true
+}
+
+// Session returns the session for the given request, or nil if the request
+// has not passed through SessionMiddleware.
+func Session(r *http.Request) *SessionState {
+	if s, ok := r.Context().Value(sessionContextKey{}).(*SessionState); ok {
+		return s
+	}
+	return nil
+}
+
+// sessionCookie is the payload of a session cookie: the session ID when a
+// SessionStore is used, otherwise the values themselves.
+type sessionCookie struct {
+	ID      string                 `json:"id,omitempty"`
+	Values  map[string]interface{} `json:"values,omitempty"`
+	Expires int64                  `json:"exp"`
+}
+
+// SessionMiddleware loads the session for each request from its session
+// cookie, making it available with Session, and saves it when the response
+// headers are written, if it has changed. Cookies are signed with
+// SESSION_SECRET, and also encrypted (with AES-GCM) if SESSION_ENCRYPTION_KEY
+// is set. If store is nil, the values are held in the cookie itself,
+// otherwise only the session ID is.
+//
+// The cookie is named by SESSION_COOKIE_NAME (default: "hyperdrive_session"),
+// expires after SESSION_MAX_AGE (default: 24h), and is HttpOnly, SameSite=Lax,
+// and Secure unless SESSION_COOKIE_SECURE is false. Requests are rejected
+// with a 500 error if SESSION_SECRET is not set.
+func (api *API) SessionMiddleware(store SessionStore) Middleware {
+	return func(h http.Handler) http.Handler {
+		return instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if conf.SessionSecret == "" {
+				log.Printf("Session middleware could not be used: SESSION_SECRET is not set")
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			sess, err := loadSession(r, store)
+			if err != nil {
+				http.Error(rw, GetErrorText(http.StatusInternalServerError, err), http.StatusInternalServerError)
+				return
+			}
+			var once sync.Once
+			save := func(header http.Header) {
+				once.Do(func() {
+					if err := saveSession(header, sess, store); err != nil {
+						log.Printf("Session could not be saved: %v", err)
+					}
+				})
+			}
+			GetResponseStats(r).OnCommit(save)
+			h.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, sess)))
+			if c, ok := rw.(committer); !ok || !c.Committed() {
+				save(rw.Header())
+			}
+		}))
+	}
+}
+
+// loadSession returns the session for the request's cookie, or a new session
+// if there is no valid cookie.
+func loadSession(r *http.Request, store SessionStore) (*SessionState, error) {
+	sess := newSessionState()
+	cookie, err := r.Cookie(conf.SessionCookieName)
+	if err != nil {
+		return sess, nil
+	}
+	payload, err := decodeSessionCookie(cookie.Value)
+	if err != nil {
+		return sess, nil
+	}
+	if store == nil {
+		if payload.Values != nil {
+			sess.values = payload.Values
+		}
+		return sess, nil
+	}
+	values, err := store.Load(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	if values != nil {
+		sess.id = payload.ID
+		sess.values = values
+	}
+	return sess, nil
+}
+
+// saveSession writes the session cookie to the given header, and saves the
+// session to the store, if it has changed or been destroyed.
+func saveSession(header http.Header, sess *SessionState, store SessionStore) error {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	cookie := &http.Cookie{
+		Name:     conf.SessionCookieName,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   conf.SessionCookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if sess.destroyed {
+		if store != nil && sess.id != "" {
+			if err := store.Delete(sess.id); err != nil {
+				return err
+			}
+		}
+		sess.id = ""
+		if !sess.changed {
+			cookie.MaxAge = -1
+			header.Add("Set-Cookie", cookie.String())
+			return nil
+		}
+	}
+	if !sess.changed {
+		return nil
+	}
+	payload := sessionCookie{Expires: time.Now().Add(conf.SessionMaxAge).Unix()}
+	if store == nil {
+		payload.Values = sess.values
+	} else {
+		if sess.id == "" {
+			sess.id = newSessionID()
+		}
+		if err := store.Save(sess.id, sess.values, conf.SessionMaxAge); err != nil {
+			return err
+		}
+		payload.ID = sess.id
+	}
+	value, err := encodeSessionCookie(payload)
+	if err != nil {
+		return err
+	}
+	cookie.Value = value
+	cookie.MaxAge = int(conf.SessionMaxAge.Seconds())
+	if s := cookie.String(); len(s) > maxCookieSize {
+		log.Printf("Session cookie is %d bytes, and may be rejected by browsers; use a SessionStore for large sessions", len(s))
+	}
+	header.Add("Set-Cookie", cookie.String())
+	return nil
+}
+
+func newSessionID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// sessionSealer returns the sealer of session cookies, keyed by
+// SESSION_SECRET and SESSION_ENCRYPTION_KEY, and bound to the cookie name.
+func sessionSealer() sealer {
+	return sealer{name: conf.SessionCookieName, secret: conf.SessionSecret, encryptionKey: conf.SessionEncryptionKey}
+}
+
+// encodeSessionCookie encodes the payload as JSON, encrypts it if
+// SESSION_ENCRYPTION_KEY is set, and signs it with SESSION_SECRET (see
+// sealer).
+func encodeSessionCookie(payload sessionCookie) (string, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return sessionSealer().seal(b)
+}
+
+// decodeSessionCookie verifies, decrypts, and decodes a session cookie,
+// returning ErrInvalidSession if any step fails, or it has expired.
+func decodeSessionCookie(value string) (*sessionCookie, error) {
+	b, ok := sessionSealer().open(value)
+	if !ok {
+		return nil, ErrInvalidSession
+	}
+	payload := &sessionCookie{}
+	if err := json.Unmarshal(b, payload); err != nil || time.Now().Unix() > payload.Expires {
+		return nil, ErrInvalidSession
+	}
+	return payload, nil
+}
@@ -0,0 +1,132 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) withSessionSecret(f func()) {
+	conf.SessionSecret = "s3cret"
+	defer func() { conf.SessionSecret = "" }()
+	f()
+}
+
+func (suite *HyperdriveTestSuite) sessionHandler(store SessionStore) http.Handler {
+	return suite.TestAPI.SessionMiddleware(store)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		sess := Session(r)
+		switch r.URL.Path {
+		case "/login":
+			sess.Set("user", "ada")
+		case "/logout":
+			sess.Destroy()
+		}
+		if user, ok := sess.Get("user").(string); ok {
+			rw.Write([]byte(user))
+		}
+	}))
+}
+
+func (suite *HyperdriveTestSuite) sessionRequest(h http.Handler, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	return rw
+}
+
+func sessionCookieFrom(rw *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range (&http.Response{Header: rw.Header()}).Cookies() {
+		if c.Name == conf.SessionCookieName {
+			return c
+		}
+	}
+	return nil
+}
+
+func (suite *HyperdriveTestSuite) TestSessionMiddlewareCookieStore() {
+	suite.withSessionSecret(func() {
+		h := suite.sessionHandler(nil)
+		rw := suite.sessionRequest(h, "/login", nil)
+		cookie := sessionCookieFrom(rw)
+		suite.NotNil(cookie, "expects a session cookie to be set")
+		suite.True(cookie.HttpOnly, "expects the session cookie to be HttpOnly")
+		suite.True(cookie.Secure, "expects the session cookie to be Secure")
+		rw = suite.sessionRequest(h, "/", cookie)
+		suite.Equal("ada", rw.Body.String(), "expects the session to be loaded from the cookie")
+		suite.Nil(sessionCookieFrom(rw), "expects an unchanged session not to be saved")
+	})
+}
+
+func (suite *HyperdriveTestSuite) TestSessionMiddlewareEncrypted() {
+	suite.withSessionSecret(func() {
+		conf.SessionEncryptionKey = "k3y"
+		defer func() { conf.SessionEncryptionKey = "" }()
+		h := suite.sessionHandler(nil)
+		cookie := sessionCookieFrom(suite.sessionRequest(h, "/login", nil))
+		suite.False(strings.Contains(cookie.Value, "ada"), "expects the session values to be encrypted")
+		suite.Equal("ada", suite.sessionRequest(h, "/", cookie).Body.String(), "expects the session to be decrypted")
+	})
+}
+
+func (suite *HyperdriveTestSuite) TestSessionMiddlewareTampered() {
+	suite.withSessionSecret(func() {
+		h := suite.sessionHandler(nil)
+		cookie := sessionCookieFrom(suite.sessionRequest(h, "/login", nil))
+		cookie.Value = "x" + cookie.Value
+		suite.Equal("", suite.sessionRequest(h, "/", cookie).Body.String(), "expects a tampered session to be discarded")
+	})
+}
+
+func (suite *HyperdriveTestSuite) TestSessionMiddlewareExpired() {
+	suite.withSessionSecret(func() {
+		value, _ := encodeSessionCookie(sessionCookie{Values: map[string]interface{}{"user": "ada"}, Expires: time.Now().Add(-time.Minute).Unix()})
+		_, err := decodeSessionCookie(value)
+		suite.Equal(ErrInvalidSession, err, "expects an expired session to be invalid")
+	})
+}
+
+func (suite *HyperdriveTestSuite) TestSessionMiddlewareStore() {
+	suite.withSessionSecret(func() {
+		store := NewMemorySessionStore()
+		h := suite.sessionHandler(store)
+		cookie := sessionCookieFrom(suite.sessionRequest(h, "/login", nil))
+		payload, err := decodeSessionCookie(cookie.Value)
+		suite.Nil(err, "expects no error")
+		suite.Nil(payload.Values, "expects the values not to be held in the cookie")
+		values, _ := store.Load(payload.ID)
+		suite.Equal("ada", values["user"], "expects the values to be saved to the store")
+		suite.Equal("ada", suite.sessionRequest(h, "/", cookie).Body.String(), "expects the session to be loaded from the store")
+		rw := suite.sessionRequest(h, "/logout", cookie)
+		suite.Equal(-1, sessionCookieFrom(rw).MaxAge, "expects a destroyed session cookie to be expired")
+		values, _ = store.Load(payload.ID)
+		suite.Nil(values, "expects a destroyed session to be deleted from the store")
+	})
+}
+
+func (suite *HyperdriveTestSuite) TestSessionDestroyThenSet() {
+	suite.withSessionSecret(func() {
+		store := NewMemorySessionStore()
+		h := suite.sessionHandler(store)
+		cookie := sessionCookieFrom(suite.sessionRequest(h, "/login", nil))
+		old, _ := decodeSessionCookie(cookie.Value)
+		login := suite.TestAPI.SessionMiddleware(store)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			Session(r).Destroy()
+			Session(r).Set("user", "grace")
+		}))
+		renewed, _ := decodeSessionCookie(sessionCookieFrom(suite.sessionRequest(login, "/", cookie)).Value)
+		suite.NotEqual(old.ID, renewed.ID, "expects a new session ID after the session is destroyed")
+	})
+}
+
+func (suite *HyperdriveTestSuite) TestSessionMiddlewareNoSecret() {
+	rw := suite.sessionRequest(suite.sessionHandler(nil), "/", nil)
+	suite.Equal(http.StatusInternalServerError, rw.Code, "expects a 500 error when SESSION_SECRET is not set")
+}
+
+func (suite *HyperdriveTestSuite) TestSessionNotSet() {
+	suite.Nil(Session(suite.TestGetRequest), "expects no session without SessionMiddleware")
+}
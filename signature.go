@@ -0,0 +1,162 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type signatureContextKey struct{}
+
+// SigningSecretStore interface is satisfied by anything that can look up the
+// secrets shared with a client, used by SignatureMiddleware to verify its
+// requests. More than one secret can be returned, so they can be rotated
+// without downtime. An empty slice, without an error, should be returned for
+// unknown clients. See StaticSigningSecrets for a simple implementation.
+type SigningSecretStore interface {
+	SigningSecrets(clientID string) ([]string, error)
+}
+
+// StaticSigningSecrets is a SigningSecretStore for a fixed set of clients,
+// keyed by client ID, with their secrets as values.
+type StaticSigningSecrets map[string][]string
+
+// SigningSecrets returns the secrets of the given client.
+func (s StaticSigningSecrets) SigningSecrets(clientID string) ([]string, error) {
+	return s[clientID], nil
+}
+
+// Sign returns the signature of a request body sent at the given time: the
+// hex encoded HMAC-SHA256, using the secret, of the unix timestamp, a dot,
+// and the body.
+func Sign(secret string, t time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", t.Unix())
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest signs the given request, so it will be accepted by
+// SignatureMiddleware: the client ID is set in the header given by
+// SIGNATURE_CLIENT_HEADER (default: X-Client-Id), and the timestamp and
+// signature in the header given by SIGNATURE_HEADER (default: X-Signature),
+// e.g. "t=1492004832,v1=5257a869...".
+func SignRequest(r *http.Request, clientID string, secret string) error {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return err
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	t := time.Now()
+	r.Header.Set(conf.SignatureClientHeader, clientID)
+	r.Header.Set(conf.SignatureHeader, fmt.Sprintf("t=%d,v1=%s", t.Unix(), Sign(secret, t, body)))
+	return nil
+}
+
+// parseSignature returns the timestamp and signatures of a signature header.
+func parseSignature(header string) (time.Time, []string, error) {
+	var (
+		t          time.Time
+		signatures []string
+	)
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			ts, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return t, nil, fmt.Errorf("invalid signature timestamp: %q", kv[1])
+			}
+			t = time.Unix(ts, 0)
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+	if t.IsZero() || len(signatures) == 0 {
+		return t, nil, fmt.Errorf("invalid signature header: %q", header)
+	}
+	return t, signatures, nil
+}
+
+// verifySignature returns true if one of the signatures matches the body,
+// signed with one of the secrets.
+func verifySignature(secrets []string, t time.Time, body []byte, signatures []string) bool {
+	for _, secret := range secrets {
+		expected := []byte(Sign(secret, t, body))
+		for _, sig := range signatures {
+			if hmac.Equal(expected, []byte(sig)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SignedClient returns the ID of the client whose signature was verified by
+// SignatureMiddleware, and false if the request has not been verified.
+func SignedClient(r *http.Request) (string, bool) {
+	id, ok := r.Context().Value(signatureContextKey{}).(string)
+	return id, ok
+}
+
+// SignatureMiddleware returns a Middleware which verifies that requests are
+// signed by a known client (see SignRequest), using the secrets in the given
+// SigningSecretStore, responding to unsigned, tampered with, or stale
+// requests with a 401 Unauthorized. Requests are stale if their timestamp is
+// further from the current time than SIGNATURE_TOLERANCE (default: 5m),
+// which prevents them from being replayed later. The ID of the client can be
+// retrieved with SignedClient.
+func (api *API) SignatureMiddleware(store SigningSecretStore) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			unauthorized := func() {
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			}
+			clientID := r.Header.Get(conf.SignatureClientHeader)
+			t, signatures, err := parseSignature(r.Header.Get(conf.SignatureHeader))
+			if clientID == "" || err != nil {
+				unauthorized()
+				return
+			}
+			if age := time.Since(t); age > conf.SignatureTolerance || age < -conf.SignatureTolerance {
+				unauthorized()
+				return
+			}
+			secrets, err := store.SigningSecrets(clientID)
+			if err != nil {
+				http.Error(rw, GetErrorText(http.StatusInternalServerError, err), http.StatusInternalServerError)
+				return
+			}
+			body, err := RawBody(r)
+			if err == ErrBodyTooLarge {
+				writeProblem(rw, http.StatusRequestEntityTooLarge, fmt.Sprintf("The request body must not be larger than %d bytes.", conf.RawBodyMaxBytes))
+				return
+			}
+			if err != nil {
+				http.Error(rw, GetErrorText(http.StatusBadRequest, err), http.StatusBadRequest)
+				return
+			}
+			if !verifySignature(secrets, t, body, signatures) {
+				unauthorized()
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), signatureContextKey{}, clientID))
+			h.ServeHTTP(rw, WithPrincipal(r, &Principal{ID: clientID, Method: AuthMethodSignature}))
+		})
+	}
+}
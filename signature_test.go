@@ -0,0 +1,75 @@
+package hyperdrive
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestSign() {
+	t := time.Unix(1492004832, 0)
+	suite.Equal(Sign("s3cret", t, []byte("{}")), Sign("s3cret", t, []byte("{}")), "expects signatures to be deterministic")
+	suite.NotEqual(Sign("s3cret", t, []byte("{}")), Sign("s3cret", t.Add(time.Second), []byte("{}")), "expects the timestamp to be signed")
+	suite.NotEqual(Sign("s3cret", t, []byte("{}")), Sign("other", t, []byte("{}")), "expects the secret to be used")
+}
+
+func (suite *HyperdriveTestSuite) TestSignatureMiddleware() {
+	var (
+		client    string
+		body      []byte
+		principal *Principal
+	)
+	h := suite.TestAPI.SignatureMiddleware(StaticSigningSecrets{"billing": {"old", "s3cret"}})(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		client, _ = SignedClient(r)
+		principal, _ = CurrentPrincipal(r)
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	r := httptest.NewRequest("POST", "/webhooks", strings.NewReader(`{"id":1}`))
+	suite.Nil(SignRequest(r, "billing", "s3cret"), "expects no error")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusOK, rw.Code, "expects signed requests to be accepted")
+	suite.Equal("billing", client, "expects the client ID to be added to the request context")
+	suite.Equal(&Principal{ID: "billing", Method: AuthMethodSignature}, principal, "expects the client to be the principal")
+	suite.Equal(`{"id":1}`, string(body), "expects the body to still be readable")
+}
+
+func (suite *HyperdriveTestSuite) TestSignatureMiddlewareTampered() {
+	h := suite.TestAPI.SignatureMiddleware(StaticSigningSecrets{"billing": {"s3cret"}})(suite.TestHandler)
+	r := httptest.NewRequest("POST", "/webhooks", strings.NewReader(`{"id":1}`))
+	SignRequest(r, "billing", "s3cret")
+	r.Body = ioutil.NopCloser(strings.NewReader(`{"id":2}`))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusUnauthorized, rw.Code, "expects tampered requests to be rejected")
+}
+
+func (suite *HyperdriveTestSuite) TestSignatureMiddlewareStale() {
+	h := suite.TestAPI.SignatureMiddleware(StaticSigningSecrets{"billing": {"s3cret"}})(suite.TestHandler)
+	t := time.Now().Add(-time.Hour)
+	r := httptest.NewRequest("POST", "/webhooks", strings.NewReader(`{}`))
+	r.Header.Set("X-Client-Id", "billing")
+	r.Header.Set("X-Signature", fmt.Sprintf("t=%d,v1=%s", t.Unix(), Sign("s3cret", t, []byte(`{}`))))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusUnauthorized, rw.Code, "expects stale requests to be rejected")
+}
+
+func (suite *HyperdriveTestSuite) TestSignatureMiddlewareUnknownClient() {
+	h := suite.TestAPI.SignatureMiddleware(StaticSigningSecrets{"billing": {"s3cret"}})(suite.TestHandler)
+	r := httptest.NewRequest("POST", "/webhooks", strings.NewReader(`{}`))
+	SignRequest(r, "mallory", "s3cret")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	suite.Equal(http.StatusUnauthorized, rw.Code, "expects unknown clients to be rejected")
+}
+
+func (suite *HyperdriveTestSuite) TestParseSignatureError() {
+	_, _, err := parseSignature("t=abc,v1=def")
+	suite.Error(err, "expects an error for an invalid timestamp")
+	_, _, err = parseSignature("t=1492004832")
+	suite.Error(err, "expects an error if there is no signature")
+}
@@ -0,0 +1,178 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// SirenContentType is the media type of Siren documents.
+const SirenContentType = "application/vnd.siren+json"
+
+// SirenEntity is a Siren (application/vnd.siren+json) representation of a
+// resource: its Class, Properties (a struct or map, encoded as a JSON
+// object), sub-Entities (with the Rel they have to it), the Actions which
+// can be taken on it, and its Links, e.g.
+//
+//	entity := hyperdrive.NewSirenEntity(order, "order")
+//	entity.AddSelfLink(r)
+//	entity.AddActions(hyperdrive.SirenActions(e, r.URL.Path)...)
+//	hyperdrive.Respond(rw, r, http.StatusOK, entity)
+//
+// Responses negotiated as Siren (see Negotiate) are written as a
+// SirenEntity, even if the value given to Respond is not one: it becomes the
+// properties of an entity.
+type SirenEntity struct {
+	Class      []string       `json:"class,omitempty"`
+	Rel        []string       `json:"rel,omitempty"`
+	Title      string         `json:"title,omitempty"`
+	Properties interface{}    `json:"properties,omitempty"`
+	Entities   []*SirenEntity `json:"entities,omitempty"`
+	Actions    []SirenAction  `json:"actions,omitempty"`
+	Links      []SirenLink    `json:"links,omitempty"`
+}
+
+// SirenLink is a link of a SirenEntity.
+type SirenLink struct {
+	Class []string `json:"class,omitempty"`
+	Rel   []string `json:"rel"`
+	Href  string   `json:"href"`
+	Title string   `json:"title,omitempty"`
+	Type  string   `json:"type,omitempty"`
+}
+
+// SirenAction is an action which can be taken on a SirenEntity: a request
+// with the Method (default: GET) to the Href, with a body of the Type
+// (default: application/x-www-form-urlencoded), holding the Fields.
+type SirenAction struct {
+	Name   string       `json:"name"`
+	Class  []string     `json:"class,omitempty"`
+	Method string       `json:"method,omitempty"`
+	Href   string       `json:"href"`
+	Title  string       `json:"title,omitempty"`
+	Type   string       `json:"type,omitempty"`
+	Fields []SirenField `json:"fields,omitempty"`
+}
+
+// SirenField is a field of a SirenAction. Type is an HTML input type, e.g.
+// text or number.
+type SirenField struct {
+	Name  string      `json:"name"`
+	Class []string    `json:"class,omitempty"`
+	Type  string      `json:"type,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+	Title string      `json:"title,omitempty"`
+}
+
+// NewSirenEntity returns a SirenEntity with the given properties and
+// classes.
+func NewSirenEntity(properties interface{}, class ...string) *SirenEntity {
+	return &SirenEntity{Class: class, Properties: properties}
+}
+
+// AddLink adds a link to the href, with the given rels.
+func (ent *SirenEntity) AddLink(href string, rel ...string) {
+	ent.Links = append(ent.Links, SirenLink{Rel: rel, Href: href})
+}
+
+// AddSelfLink adds a self link to the URL of the request, relative to the
+// API's host.
+func (ent *SirenEntity) AddSelfLink(r *http.Request) {
+	ent.AddLink(r.URL.RequestURI(), "self")
+}
+
+// AddEntity adds a sub-entity, with the given rels to the entity.
+func (ent *SirenEntity) AddEntity(sub *SirenEntity, rel ...string) {
+	sub.Rel = rel
+	ent.Entities = append(ent.Entities, sub)
+}
+
+// AddActions adds actions, e.g. those returned by SirenActions.
+func (ent *SirenEntity) AddActions(actions ...SirenAction) {
+	ent.Actions = append(ent.Actions, actions...)
+}
+
+// NewSirenAction returns a SirenAction with the given name, method, and
+// href. Fields can be added with AddField.
+func NewSirenAction(name string, method string, href string) *SirenAction {
+	return &SirenAction{Name: name, Method: method, Href: href}
+}
+
+// AddField adds a field of the given input type, and returns the action, so
+// calls can be chained.
+func (a *SirenAction) AddField(name string, typ string) *SirenAction {
+	a.Fields = append(a.Fields, SirenField{Name: name, Type: typ})
+	return a
+}
+
+// SirenActions returns the actions which can be taken on the endpoint's
+// resource at href (e.g. the path of the request), declared by its metadata:
+// an action for each method it handles, other than GET and OPTIONS, named
+// after the method and endpoint (e.g. put-users), with a field for each
+// param allowed for the method (see the param struct tag), ordered by name.
+// Params with a description have it as their title, and those with a default
+// have it as their value.
+func SirenActions(e Endpointer, href string) []SirenAction {
+	params := parseEndpoint(e)
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var actions []SirenAction
+	for _, method := range GetMethods(e) {
+		if method == "GET" || method == "OPTIONS" {
+			continue
+		}
+		a := NewSirenAction(strings.ToLower(method)+"-"+slug(e.GetName()), method, href)
+		a.Title = e.GetDesc()
+		for _, k := range keys {
+			p := params[k]
+			if !p.IsAllowed(method) {
+				continue
+			}
+			f := SirenField{Name: p.Key, Type: sirenFieldType(p.Type)}
+			if p.Desc != "..." {
+				f.Title = p.Desc
+			}
+			if p.Default != "" {
+				f.Value = p.Default
+			}
+			a.Fields = append(a.Fields, f)
+		}
+		actions = append(actions, *a)
+	}
+	return actions
+}
+
+// sirenFieldType returns the input type of a field for a param of the Go
+// type with the given name.
+func sirenFieldType(typ string) string {
+	switch {
+	case typ == "bool":
+		return "checkbox"
+	case strings.HasPrefix(typ, "int"), strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "float"):
+		return "number"
+	}
+	return "text"
+}
+
+// SirenEncoder is an implementation of ContentEncoder which writes Siren
+// entities. A *Collection is written as returned by its Siren method, and
+// other values which are not a *SirenEntity as the properties of one.
+type SirenEncoder struct {
+	Encoder *json.Encoder
+}
+
+// Encode encodes input as a Siren entity or returns an error.
+func (enc SirenEncoder) Encode(v interface{}) error {
+	switch c := v.(type) {
+	case *SirenEntity, SirenEntity:
+	case *Collection:
+		v = c.Siren()
+	default:
+		v = NewSirenEntity(v)
+	}
+	return enc.Encoder.Encode(v)
+}
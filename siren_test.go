@@ -0,0 +1,54 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+)
+
+type SirenEndpoint struct {
+	*Endpoint
+	Name   string `param:"name;r=POST"`
+	Amount int    `param:"amount;a=POST,PATCH"`
+	Notify bool   `param:"notify;a=PATCH;d=true"`
+}
+
+func (e *SirenEndpoint) Get(rw http.ResponseWriter, r *http.Request)    {}
+func (e *SirenEndpoint) Post(rw http.ResponseWriter, r *http.Request)   {}
+func (e *SirenEndpoint) Patch(rw http.ResponseWriter, r *http.Request)  {}
+func (e *SirenEndpoint) Delete(rw http.ResponseWriter, r *http.Request) {}
+
+func (suite *HyperdriveTestSuite) TestSirenEntity() {
+	ent := NewSirenEntity(map[string]int{"total": 10}, "order")
+	ent.AddSelfLink(httptest.NewRequest("GET", "/orders/42?expand=items", nil))
+	ent.AddEntity(NewSirenEntity(map[string]string{"name": "Ada"}, "customer"), "customer")
+	ent.AddActions(*NewSirenAction("add-item", "POST", "/orders/42/items").AddField("sku", "text").AddField("quantity", "number"))
+	b, err := json.Marshal(ent)
+	suite.Nil(err, "expects no error")
+	suite.JSONEq(`{
+		"class": ["order"],
+		"properties": {"total": 10},
+		"entities": [{"class": ["customer"], "rel": ["customer"], "properties": {"name": "Ada"}}],
+		"actions": [{"name": "add-item", "method": "POST", "href": "/orders/42/items", "fields": [{"name": "sku", "type": "text"}, {"name": "quantity", "type": "number"}]}],
+		"links": [{"rel": ["self"], "href": "/orders/42?expand=items"}]
+	}`, string(b), "expects a Siren entity")
+}
+
+func (suite *HyperdriveTestSuite) TestSirenActions() {
+	e := &SirenEndpoint{Endpoint: NewEndpoint("Orders", "Manage orders", "/orders/{id}", "1")}
+	actions := SirenActions(e, "/orders/42")
+	suite.Equal([]SirenAction{
+		{Name: "post-orders", Method: "POST", Href: "/orders/42", Title: "Manage orders", Fields: []SirenField{{Name: "amount", Type: "number"}, {Name: "name", Type: "text"}}},
+		{Name: "patch-orders", Method: "PATCH", Href: "/orders/42", Title: "Manage orders", Fields: []SirenField{{Name: "amount", Type: "number"}, {Name: "name", Type: "text"}, {Name: "notify", Type: "checkbox", Value: "true"}}},
+		{Name: "delete-orders", Method: "DELETE", Href: "/orders/42", Title: "Manage orders"},
+	}, actions, "expects an action for each method, with the params allowed for it")
+}
+
+func (suite *HyperdriveTestSuite) TestRespondSiren() {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/orders/42", nil)
+	r.Header.Set("Accept", SirenContentType)
+	Respond(rw, r, http.StatusOK, map[string]int{"total": 10})
+	suite.Equal(SirenContentType, rw.Header().Get("Content-Type"), "expects the negotiated Siren Content-Type")
+	suite.JSONEq(`{"properties": {"total": 10}}`, rw.Body.String(), "expects the value to be the properties of an entity")
+}
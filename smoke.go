@@ -0,0 +1,216 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SmokeCheck is a request sent by Smoke to a running instance of the API,
+// and the response it expects. Method defaults to GET, and Status to 200 OK.
+// Validate, when set, checks the response body, e.g. with ValidateJSON.
+type SmokeCheck struct {
+	Name     string
+	Method   string
+	Path     string
+	Header   http.Header
+	Status   int
+	Validate func(res *http.Response, body []byte) error
+}
+
+// SmokeResult is the outcome of a SmokeCheck. Err is nil if it passed.
+type SmokeResult struct {
+	Check    SmokeCheck
+	Status   int
+	Duration time.Duration
+	Err      error
+}
+
+// SmokeReport holds the results of every SmokeCheck run against the Target.
+type SmokeReport struct {
+	Target  string
+	Results []SmokeResult
+}
+
+// Passed returns true if every SmokeCheck passed.
+func (sr SmokeReport) Passed() bool {
+	for _, res := range sr.Results {
+		if res.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns a summary of the report, with a line per SmokeCheck.
+func (sr SmokeReport) String() string {
+	var (
+		b      strings.Builder
+		failed int
+	)
+	fmt.Fprintf(&b, "Smoke testing %s\n", sr.Target)
+	for _, res := range sr.Results {
+		outcome := "PASS"
+		if res.Err != nil {
+			outcome = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(&b, "%s %s %s (%d, %v)", outcome, res.Check.Method, res.Check.Path, res.Status, res.Duration.Truncate(time.Millisecond))
+		if res.Err != nil {
+			fmt.Fprintf(&b, ": %v", res.Err)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%d passed, %d failed\n", len(sr.Results)-failed, failed)
+	return b.String()
+}
+
+// ValidateJSON returns a SmokeCheck Validate function, which checks the
+// response body is JSON matching the shape of v (e.g. a struct, or a pointer
+// to one): it must decode into a new value of the same type, without any
+// unknown fields.
+func ValidateJSON(v interface{}) func(res *http.Response, body []byte) error {
+	return func(res *http.Response, body []byte) error {
+		t := reflect.TypeOf(v)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		target := reflect.New(t).Interface()
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(target); err != nil {
+			return fmt.Errorf("unexpected response body: %v", err)
+		}
+		return nil
+	}
+}
+
+// smokeChecks holds the SmokeChecks declared with AddSmokeCheck, shared by
+// every copy of an API.
+type smokeChecks struct {
+	sync.RWMutex
+	checks []SmokeCheck
+}
+
+func (sc *smokeChecks) add(c SmokeCheck) {
+	sc.Lock()
+	defer sc.Unlock()
+	sc.checks = append(sc.checks, c)
+}
+
+func (sc *smokeChecks) list() []SmokeCheck {
+	sc.RLock()
+	defer sc.RUnlock()
+	return append([]SmokeCheck(nil), sc.checks...)
+}
+
+// AddSmokeCheck declares a SmokeCheck, to be run by Smoke instead of the
+// checks generated from the API's endpoints.
+func (api *API) AddSmokeCheck(c SmokeCheck) {
+	api.smokeChecks.add(c)
+}
+
+// SmokeChecks returns the SmokeChecks Smoke runs by default: those declared
+// with AddSmokeCheck, or, if there are none, a GET of the Discovery URL, and
+// of every endpoint which supports GET, in its json media type. Endpoints
+// with path variables, or params required for GET, are skipped, since they
+// can not be requested without knowing valid values.
+func (api *API) SmokeChecks() []SmokeCheck {
+	if checks := api.smokeChecks.list(); len(checks) > 0 {
+		return checks
+	}
+	checks := []SmokeCheck{{Name: "discovery", Path: "/", Header: http.Header{"Accept": {"application/json"}}}}
+	for _, e := range api.Root.Endpoints {
+		if !contains(e.Methods, "GET") || strings.Contains(e.Path, "{") || len(e.MediaTypes) == 0 {
+			continue
+		}
+		required := false
+		for _, p := range e.Params {
+			required = required || contains(p.Required, "GET")
+		}
+		if !required {
+			checks = append(checks, SmokeCheck{Name: e.Name, Path: e.Path, Header: http.Header{"Accept": {e.MediaTypes[0]}}})
+		}
+	}
+	return checks
+}
+
+// Smoke runs the given SmokeChecks (or, if none are given, those returned by
+// SmokeChecks) against a running instance of the API at target (e.g.
+// https://api.example.com), one at a time, for post-deploy verification.
+// Each check times out after SMOKE_TIMEOUT (default: 10s).
+func (api *API) Smoke(ctx context.Context, target string, checks ...SmokeCheck) SmokeReport {
+	if len(checks) == 0 {
+		checks = api.SmokeChecks()
+	}
+	report := SmokeReport{Target: target}
+	client := &http.Client{Timeout: conf.SmokeTimeout}
+	for _, c := range checks {
+		if c.Method == "" {
+			c.Method = "GET"
+		}
+		if c.Status == 0 {
+			c.Status = http.StatusOK
+		}
+		report.Results = append(report.Results, runSmokeCheck(ctx, client, strings.TrimSuffix(target, "/"), c))
+	}
+	return report
+}
+
+func runSmokeCheck(ctx context.Context, client *http.Client, target string, c SmokeCheck) SmokeResult {
+	result := SmokeResult{Check: c}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+	req, err := http.NewRequestWithContext(ctx, c.Method, target+c.Path, nil)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer res.Body.Close()
+	result.Status = res.StatusCode
+	body, err := io.ReadAll(res.Body)
+	switch {
+	case err != nil:
+		result.Err = err
+	case res.StatusCode != c.Status:
+		result.Err = fmt.Errorf("expected status %d, got %d", c.Status, res.StatusCode)
+	case c.Validate != nil:
+		result.Err = c.Validate(res, body)
+	}
+	return result
+}
+
+// runSmokeCommand runs the smoke command, e.g. "myapi smoke
+// https://api.example.com", printing the report, and returns the exit code.
+func (api *API) runSmokeCommand(args []string) int {
+	target := conf.SmokeTarget
+	if len(args) > 0 {
+		target = args[0]
+	}
+	if target == "" {
+		fmt.Fprintln(os.Stderr, "usage: smoke <target>, e.g. smoke https://api.example.com, or set SMOKE_TARGET")
+		return 2
+	}
+	report := api.Smoke(context.Background(), target)
+	fmt.Print(report)
+	if !report.Passed() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package hyperdrive
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HyperdriveTestSuite) TestSmoke() {
+	ts := httptest.NewServer(suite.TestAPI.Router)
+	defer ts.Close()
+	report := suite.TestAPI.Smoke(context.Background(), ts.URL+"/")
+	suite.True(report.Passed(), "expects the discovery URL to pass: "+report.String())
+	suite.Len(report.Results, 1, "expects a check of the discovery URL")
+	suite.Contains(report.String(), "1 passed, 0 failed", "expects a summary")
+}
+
+func (suite *HyperdriveTestSuite) TestSmokeFailures() {
+	ts := httptest.NewServer(suite.TestAPI.Router)
+	defer ts.Close()
+	var root struct {
+		Resource string `json:"resource"`
+	}
+	report := suite.TestAPI.Smoke(context.Background(), ts.URL,
+		SmokeCheck{Path: "/missing"},
+		SmokeCheck{Path: "/", Header: http.Header{"Accept": {"application/json"}}, Validate: ValidateJSON(&root)},
+	)
+	suite.False(report.Passed(), "expects the report to fail")
+	suite.Equal(http.StatusNotFound, report.Results[0].Status, "expects the status to be reported")
+	suite.Error(report.Results[0].Err, "expects an unexpected status to fail")
+	suite.Error(report.Results[1].Err, "expects a body with unknown fields to fail")
+}
+
+func (suite *HyperdriveTestSuite) TestAddSmokeCheck() {
+	suite.TestAPI.AddSmokeCheck(SmokeCheck{Name: "ready", Path: "/ready"})
+	suite.Equal([]SmokeCheck{{Name: "ready", Path: "/ready"}}, suite.TestAPI.SmokeChecks(), "expects declared checks to replace the generated ones")
+}
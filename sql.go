@@ -0,0 +1,210 @@
+package hyperdrive
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LedgerDBQueryMicroseconds is the resource counted by a Ledger for the
+// total time spent on database queries, by a driver wrapped with
+// WrapSQLDriver.
+const LedgerDBQueryMicroseconds = "db_query_microseconds"
+
+var (
+	// sqlCommentRequestID matches the request IDs added to queries.
+	sqlCommentRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+	// sqlCommentTraceparent matches W3C traceparent headers.
+	sqlCommentTraceparent = regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+)
+
+// RegisterSQLDriver wraps the given database/sql driver with WrapSQLDriver,
+// and registers it with the given name, e.g.
+//
+//	hyperdrive.RegisterSQLDriver("postgres+hyperdrive", &pq.Driver{})
+//	db, err := sql.Open("postgres+hyperdrive", dsn)
+func RegisterSQLDriver(name string, d driver.Driver) {
+	sql.Register(name, WrapSQLDriver(d))
+}
+
+// WrapSQLDriver wraps a database/sql driver, so that queries made with the
+// context of a request (e.g. db.QueryContext(r.Context(), ...)) are
+// correlated with it:
+//
+// - the number of queries, and the time spent on them, are counted by the
+// request's Ledger, so they are included in the json access log, and
+// recorded by MetricsMiddleware
+// - unless SQL_COMMENTS (bool) is false, the request's ID (and traceparent
+// header, if any) are added to the query as a comment, e.g.
+// SELECT 1 /*request_id='8d9f...'*/, so slow query logs can be traced back
+// to the request. As these come from request headers, only request IDs of up
+// to 128 letters, digits, dots, underscores and dashes, and traceparents of
+// the W3C format (00-<trace-id>-<parent-id>-<flags>) are added, and others
+// dropped
+//
+// Queries made without a request context are passed through unchanged.
+func WrapSQLDriver(d driver.Driver) driver.Driver {
+	return &sqlDriver{Driver: d}
+}
+
+type sqlDriver struct {
+	driver.Driver
+}
+
+func (d *sqlDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{Conn: conn}, nil
+}
+
+// sqlQuery adds the request's ID (and traceparent) to the query as a comment,
+// if they can be added safely.
+func sqlQuery(ctx context.Context, query string) string {
+	s := statsFromContext(ctx)
+	if s == nil || !conf.SQLComments {
+		return query
+	}
+	var tags []string
+	if sqlCommentRequestID.MatchString(s.RequestID) {
+		tags = append(tags, fmt.Sprintf("request_id='%s'", s.RequestID))
+	}
+	if sqlCommentTraceparent.MatchString(s.traceparent) {
+		tags = append(tags, fmt.Sprintf("traceparent='%s'", s.traceparent))
+	}
+	if len(tags) == 0 {
+		return query
+	}
+	return query + " /*" + strings.Join(tags, ",") + "*/"
+}
+
+// recordSQLQuery counts a query, which started at the given time, in the
+// Ledger of the request, if there is one.
+func recordSQLQuery(ctx context.Context, start time.Time) {
+	if s := statsFromContext(ctx); s != nil {
+		s.Ledger.Inc(LedgerDBQueries)
+		s.Ledger.Add(LedgerDBQueryMicroseconds, int64(time.Since(start)/time.Microsecond))
+	}
+}
+
+type sqlConn struct {
+	driver.Conn
+}
+
+func (c *sqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	query = sqlQuery(ctx, query)
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sqlStmt{Stmt: stmt}, nil
+}
+
+func (c *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer recordSQLQuery(ctx, time.Now())
+	return e.ExecContext(ctx, sqlQuery(ctx, query), args)
+}
+
+func (c *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer recordSQLQuery(ctx, time.Now())
+	return q.QueryContext(ctx, sqlQuery(ctx, query), args)
+}
+
+func (c *sqlConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) CheckNamedValue(v *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+type sqlStmt struct {
+	driver.Stmt
+}
+
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	defer recordSQLQuery(ctx, time.Now())
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values)
+}
+
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	defer recordSQLQuery(ctx, time.Now())
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(values)
+}
+
+func (s *sqlStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("hyperdrive: the wrapped driver does not support named parameters (%s)", arg.Name)
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,141 @@
+package hyperdrive
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+var (
+	registerTestSQLDriver sync.Once
+	testSQLQueries        []string
+)
+
+type testSQLDriver struct{}
+
+func (testSQLDriver) Open(name string) (driver.Conn, error) {
+	return testSQLConn{}, nil
+}
+
+type testSQLConn struct{}
+
+func (testSQLConn) Prepare(query string) (driver.Stmt, error) {
+	testSQLQueries = append(testSQLQueries, query)
+	return testSQLStmt{}, nil
+}
+
+func (testSQLConn) Close() error {
+	return nil
+}
+
+func (testSQLConn) Begin() (driver.Tx, error) {
+	return nil, driver.ErrSkip
+}
+
+func (testSQLConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	testSQLQueries = append(testSQLQueries, query)
+	return &testSQLRows{}, nil
+}
+
+type testSQLStmt struct{}
+
+func (testSQLStmt) Close() error {
+	return nil
+}
+
+func (testSQLStmt) NumInput() int {
+	return -1
+}
+
+func (testSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (testSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &testSQLRows{}, nil
+}
+
+type testSQLRows struct{}
+
+func (*testSQLRows) Columns() []string {
+	return []string{"n"}
+}
+
+func (*testSQLRows) Close() error {
+	return nil
+}
+
+func (*testSQLRows) Next(dest []driver.Value) error {
+	return io.EOF
+}
+
+func (suite *HyperdriveTestSuite) openTestDB() *sql.DB {
+	registerTestSQLDriver.Do(func() {
+		RegisterSQLDriver("hyperdrive-test", testSQLDriver{})
+	})
+	testSQLQueries = nil
+	db, _ := sql.Open("hyperdrive-test", "")
+	return db
+}
+
+func (suite *HyperdriveTestSuite) TestWrapSQLDriver() {
+	var ledger *Ledger
+	db := suite.openTestDB()
+	defer db.Close()
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set(RequestIDHeader, "abc123")
+	instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ledger = GetLedger(r)
+		rows, _ := db.QueryContext(r.Context(), "SELECT 1")
+		rows.Close()
+		db.ExecContext(r.Context(), "UPDATE widgets SET n = 1")
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	suite.Equal(int64(2), ledger.Get(LedgerDBQueries), "expects queries to be counted by the request's Ledger")
+	suite.Equal([]string{"SELECT 1 /*request_id='abc123'*/", "UPDATE widgets SET n = 1 /*request_id='abc123'*/"}, testSQLQueries, "expects queries to be tagged with the request ID")
+}
+
+func (suite *HyperdriveTestSuite) TestWrapSQLDriverWithoutRequest() {
+	db := suite.openTestDB()
+	defer db.Close()
+	rows, err := db.Query("SELECT 1")
+	suite.Nil(err, "expects no error")
+	rows.Close()
+	suite.Equal([]string{"SELECT 1"}, testSQLQueries, "expects queries without a request context to be unchanged")
+}
+
+func (suite *HyperdriveTestSuite) TestWrapSQLDriverNoComments() {
+	conf.SQLComments = false
+	defer func() { conf.SQLComments = true }()
+	db := suite.openTestDB()
+	defer db.Close()
+	instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		db.ExecContext(r.Context(), "DELETE FROM widgets")
+	})).ServeHTTP(httptest.NewRecorder(), suite.TestGetRequest)
+	suite.False(strings.Contains(testSQLQueries[0], "/*"), "expects queries not to be tagged when SQL_COMMENTS is false")
+}
+
+func (suite *HyperdriveTestSuite) TestSQLQueryTraceparent() {
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	var query string
+	instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		query = sqlQuery(r.Context(), "SELECT 1")
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	suite.Contains(query, "traceparent='00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01'", "expects the traceparent to be added to the query")
+}
+
+func (suite *HyperdriveTestSuite) TestSQLQueryInjection() {
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set(RequestIDHeader, "*'/;DROP_TABLE_users;--")
+	r.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01*/;DROP TABLE users;--")
+	var query string
+	instrument(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		query = sqlQuery(r.Context(), "SELECT 1")
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	suite.Equal("SELECT 1", query, "expects request IDs and traceparents which are not well formed to be dropped")
+}
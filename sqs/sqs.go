@@ -0,0 +1,109 @@
+// Package sqs provides a hyperdrive.QueueAdapter which receives messages from
+// an Amazon SQS queue, so they can be handled by a hyperdrive consumer.
+//
+//	adapter, err := sqs.NewAdapterFromEnv(context.Background(), os.Getenv("QUEUE_URL"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	api.AddConsumer("orders", adapter, handler)
+package sqs
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	awssqs "github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/hyperdriven/hyperdrive"
+)
+
+// Client interface is satisfied by the SQS client from the AWS SDK, and
+// holds the methods used by Adapter.
+type Client interface {
+	ReceiveMessage(ctx context.Context, params *awssqs.ReceiveMessageInput, optFns ...func(*awssqs.Options)) (*awssqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *awssqs.DeleteMessageInput, optFns ...func(*awssqs.Options)) (*awssqs.DeleteMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *awssqs.ChangeMessageVisibilityInput, optFns ...func(*awssqs.Options)) (*awssqs.ChangeMessageVisibilityOutput, error)
+}
+
+// Adapter is an implementation of hyperdrive.QueueAdapter, which long polls
+// an SQS queue for up to MaxMessages (1-10) at a time, waiting up to
+// WaitTimeSeconds (0-20) for them to arrive. Acked messages are deleted, and
+// nacked messages are made visible again immediately, so they are
+// redelivered.
+type Adapter struct {
+	Client          Client
+	QueueURL        string
+	MaxMessages     int32
+	WaitTimeSeconds int32
+}
+
+// NewAdapter creates an instance of Adapter for the queue with the given URL.
+func NewAdapter(client Client, queueURL string) *Adapter {
+	return &Adapter{Client: client, QueueURL: queueURL, MaxMessages: 10, WaitTimeSeconds: 20}
+}
+
+// NewAdapterFromEnv creates an instance of Adapter, with an SQS client
+// configured from the environment (e.g. AWS_REGION, AWS_ACCESS_KEY_ID, or
+// the instance's IAM role), as described here:
+// https://docs.aws.amazon.com/sdk-for-go/v2/developer-guide/configure-gosdk.html
+func NewAdapterFromEnv(ctx context.Context, queueURL string) (*Adapter, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewAdapter(awssqs.NewFromConfig(cfg), queueURL), nil
+}
+
+// Receive long polls the queue for messages.
+func (a *Adapter) Receive(ctx context.Context) ([]*hyperdrive.Message, error) {
+	out, err := a.Client.ReceiveMessage(ctx, &awssqs.ReceiveMessageInput{
+		QueueUrl:                    aws.String(a.QueueURL),
+		MaxNumberOfMessages:         a.MaxMessages,
+		WaitTimeSeconds:             a.WaitTimeSeconds,
+		MessageAttributeNames:       []string{"All"},
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameApproximateReceiveCount},
+	})
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]*hyperdrive.Message, 0, len(out.Messages))
+	for _, msg := range out.Messages {
+		m := &hyperdrive.Message{
+			ID:         aws.ToString(msg.MessageId),
+			Body:       []byte(aws.ToString(msg.Body)),
+			Attributes: map[string]string{},
+			Raw:        msg,
+		}
+		for k, v := range msg.MessageAttributes {
+			if v.StringValue != nil {
+				m.Attributes[k] = *v.StringValue
+			}
+		}
+		m.Attempts, _ = strconv.Atoi(msg.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// Ack deletes the message from the queue.
+func (a *Adapter) Ack(ctx context.Context, m *hyperdrive.Message) error {
+	msg := m.Raw.(types.Message)
+	_, err := a.Client.DeleteMessage(ctx, &awssqs.DeleteMessageInput{
+		QueueUrl:      aws.String(a.QueueURL),
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	return err
+}
+
+// Nack makes the message visible again, so it is redelivered.
+func (a *Adapter) Nack(ctx context.Context, m *hyperdrive.Message) error {
+	msg := m.Raw.(types.Message)
+	_, err := a.Client.ChangeMessageVisibility(ctx, &awssqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(a.QueueURL),
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: 0,
+	})
+	return err
+}
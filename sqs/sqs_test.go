@@ -0,0 +1,80 @@
+package sqs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awssqs "github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/hyperdriven/hyperdrive"
+	"github.com/stretchr/testify/suite"
+)
+
+type testClient struct {
+	received *awssqs.ReceiveMessageInput
+	deleted  []string
+	visible  []string
+}
+
+func (c *testClient) ReceiveMessage(ctx context.Context, params *awssqs.ReceiveMessageInput, optFns ...func(*awssqs.Options)) (*awssqs.ReceiveMessageOutput, error) {
+	c.received = params
+	return &awssqs.ReceiveMessageOutput{Messages: []types.Message{{
+		MessageId:         aws.String("1"),
+		ReceiptHandle:     aws.String("handle-1"),
+		Body:              aws.String(`{"id":1}`),
+		Attributes:        map[string]string{"ApproximateReceiveCount": "2"},
+		MessageAttributes: map[string]types.MessageAttributeValue{"type": {StringValue: aws.String("order.created")}},
+	}}}, nil
+}
+
+func (c *testClient) DeleteMessage(ctx context.Context, params *awssqs.DeleteMessageInput, optFns ...func(*awssqs.Options)) (*awssqs.DeleteMessageOutput, error) {
+	c.deleted = append(c.deleted, aws.ToString(params.ReceiptHandle))
+	return &awssqs.DeleteMessageOutput{}, nil
+}
+
+func (c *testClient) ChangeMessageVisibility(ctx context.Context, params *awssqs.ChangeMessageVisibilityInput, optFns ...func(*awssqs.Options)) (*awssqs.ChangeMessageVisibilityOutput, error) {
+	c.visible = append(c.visible, aws.ToString(params.ReceiptHandle))
+	return &awssqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+type SQSTestSuite struct {
+	suite.Suite
+	Client  *testClient
+	Adapter *Adapter
+}
+
+func (suite *SQSTestSuite) SetupTest() {
+	suite.Client = &testClient{}
+	suite.Adapter = NewAdapter(suite.Client, "https://sqs.us-east-1.amazonaws.com/123/orders")
+}
+
+func (suite *SQSTestSuite) TestQueueAdapter() {
+	suite.Implements((*hyperdrive.QueueAdapter)(nil), suite.Adapter, "expects an implementation of hyperdrive.QueueAdapter")
+}
+
+func (suite *SQSTestSuite) TestReceive() {
+	messages, err := suite.Adapter.Receive(context.Background())
+	suite.Nil(err, "expects no error")
+	suite.Equal(int32(20), suite.Client.received.WaitTimeSeconds, "expects the queue to be long polled")
+	suite.Equal(1, len(messages), "expects the messages received")
+	suite.Equal(`{"id":1}`, string(messages[0].Body), "expects the body of the message")
+	suite.Equal(2, messages[0].Attempts, "expects the receive count of the message")
+	suite.Equal("order.created", messages[0].Attributes["type"], "expects the attributes of the message")
+}
+
+func (suite *SQSTestSuite) TestAck() {
+	messages, _ := suite.Adapter.Receive(context.Background())
+	suite.Nil(suite.Adapter.Ack(context.Background(), messages[0]), "expects no error")
+	suite.Equal([]string{"handle-1"}, suite.Client.deleted, "expects acked messages to be deleted")
+}
+
+func (suite *SQSTestSuite) TestNack() {
+	messages, _ := suite.Adapter.Receive(context.Background())
+	suite.Nil(suite.Adapter.Nack(context.Background(), messages[0]), "expects no error")
+	suite.Equal([]string{"handle-1"}, suite.Client.visible, "expects nacked messages to be made visible")
+}
+
+func TestSQSTestSuite(t *testing.T) {
+	suite.Run(t, new(SQSTestSuite))
+}
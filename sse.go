@@ -0,0 +1,258 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventStreamContentType is the media type of Server-Sent Events.
+const EventStreamContentType = "text/event-stream"
+
+// sseShutdownRetry is how long clients of an EventStream drained when the
+// API shuts down wait before reconnecting, to another instance.
+const sseShutdownRetry = 2 * time.Second
+
+// ErrEventStreamClosed is returned by EventStream.Send once the stream has
+// been closed, or the client has disconnected.
+var ErrEventStreamClosed = errors.New("event stream closed")
+
+// Event is a Server-Sent Event. Data is written as it is if it is a string
+// or []byte, and as JSON otherwise. ID, when set, is sent back by clients
+// which reconnect, in the Last-Event-ID header (see
+// EventStream.LastEventID). Event is the type of the event (default:
+// message), and Retry, when set, tells clients how long to wait before
+// reconnecting.
+type Event struct {
+	ID    string
+	Event string
+	Data  interface{}
+	Retry time.Duration
+}
+
+// EventStream is a Server-Sent Events (text/event-stream) response, for
+// live-updating resources, e.g.
+//
+//	func (e *OrdersEndpoint) Get(rw http.ResponseWriter, r *http.Request) {
+//		s, err := hyperdrive.NewEventStream(rw, r)
+//		if err != nil {
+//			hyperdrive.WriteError(rw, err)
+//			return
+//		}
+//		defer s.Close()
+//		for _, o := range e.store.ChangedSince(s.LastEventID()) {
+//			s.Send(hyperdrive.Event{ID: o.Version, Event: "order", Data: o})
+//		}
+//		for {
+//			select {
+//			case o := <-e.store.Changes():
+//				s.Send(hyperdrive.Event{ID: o.Version, Event: "order", Data: o})
+//			case <-s.Done():
+//				return
+//			}
+//		}
+//	}
+//
+// A keep-alive comment is sent every SSE_KEEP_ALIVE (default: 15s, 0
+// disables it), so proxies do not close idle streams. CompressionMiddleware
+// does not compress requests which accept text/event-stream, since events
+// must reach the client as soon as they are flushed.
+//
+// The stream is not cut off by the server's WRITE_TIMEOUT, and is drained
+// (see TrackStream) when the API shuts down: clients are sent a shutdown
+// event (see WriteSSEShutdown), so they reconnect, and the stream is closed.
+type EventStream struct {
+	rw          http.ResponseWriter
+	flusher     http.Flusher
+	lastEventID string
+	mu          sync.Mutex
+	closed      chan struct{}
+	closeOnce   sync.Once
+	disconnect  <-chan struct{}
+	done        chan struct{}
+	untrack     func()
+	wg          sync.WaitGroup
+}
+
+// NewEventStream begins a Server-Sent Events response to r: the status (200
+// OK) and headers are sent, and flushed, with caching and proxy buffering
+// (X-Accel-Buffering) disabled. It returns ErrFlushNotSupported, before
+// anything is sent, if rw can not be flushed. Close must be called before
+// the handler returns.
+func NewEventStream(rw http.ResponseWriter, r *http.Request) (*EventStream, error) {
+	f, ok := rw.(http.Flusher)
+	if fc, isChecker := rw.(flushChecker); !ok || (isChecker && !fc.CanFlush()) {
+		return nil, ErrFlushNotSupported
+	}
+	h := rw.Header()
+	h.Set("Content-Type", EventStreamContentType)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+	clearWriteDeadline(rw)
+	rw.WriteHeader(http.StatusOK)
+	f.Flush()
+	s := &EventStream{
+		rw:          rw,
+		flusher:     f,
+		lastEventID: lastEventID(r),
+		closed:      make(chan struct{}),
+		disconnect:  r.Context().Done(),
+		done:        make(chan struct{}),
+		untrack:     func() {},
+	}
+	if hAPI.streams != nil {
+		var ctx context.Context
+		ctx, s.untrack = hAPI.TrackStream(r, s.drain)
+		s.disconnect = ctx.Done()
+	}
+	go func() {
+		select {
+		case <-s.closed:
+		case <-s.disconnect:
+		}
+		close(s.done)
+	}()
+	if conf.SSEKeepAlive > 0 {
+		s.wg.Add(1)
+		go s.keepAlive(conf.SSEKeepAlive)
+	}
+	return s, nil
+}
+
+// lastEventID returns the ID of the last event received by a client which
+// is reconnecting: its Last-Event-ID header, or, for polyfills which can
+// not set it, its lastEventId query param.
+func lastEventID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return r.URL.Query().Get("lastEventId")
+}
+
+// LastEventID returns the ID of the last event received by the client, if
+// it is reconnecting, so the stream can resume after it, or "" if it is not.
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done returns a channel which is closed once the stream has been closed,
+// or the client has disconnected.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send writes the event, and flushes it to the client. It returns
+// ErrEventStreamClosed once the stream has been closed, or the client has
+// disconnected, and an error if the data can not be encoded as JSON, or the
+// ID or type of the event has a line break.
+func (s *EventStream) Send(e Event) error {
+	if strings.ContainsAny(e.ID+e.Event, "\r\n") {
+		return errors.New("hyperdrive: the id and type of an event must not have line breaks")
+	}
+	var buf bytes.Buffer
+	if e.ID != "" {
+		buf.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" {
+		buf.WriteString("event: " + e.Event + "\n")
+	}
+	if e.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	data, err := eventData(e.Data)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.Replace(data, "\r\n", "\n", -1), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return s.write(buf.Bytes())
+}
+
+// eventData returns the data of an event, as it is written.
+func eventData(v interface{}) (string, error) {
+	switch d := v.(type) {
+	case string:
+		return d, nil
+	case []byte:
+		return string(d), nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Close ends the stream, stopping its keep-alive comments. It is safe to
+// call more than once.
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	s.closeOnce.Do(func() { close(s.closed) })
+	s.mu.Unlock()
+	s.wg.Wait()
+	s.untrack()
+}
+
+// drain sends the client a shutdown event, unless the stream has already
+// been closed, and closes it.
+func (s *EventStream) drain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.closed:
+		return
+	case <-s.disconnect:
+	default:
+		WriteSSEShutdown(s.rw, sseShutdownRetry)
+	}
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+// keepAlive writes a comment every interval, until the stream is closed, or
+// the client disconnects.
+func (s *EventStream) keepAlive(interval time.Duration) {
+	defer s.wg.Done()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if s.write([]byte(": keep-alive\n\n")) != nil {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// write writes b to the client and flushes it, unless the stream has been
+// closed, or the client has disconnected.
+func (s *EventStream) write(b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.closed:
+		return ErrEventStreamClosed
+	case <-s.disconnect:
+		return ErrEventStreamClosed
+	default:
+	}
+	if _, err := s.rw.Write(b); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// acceptsEventStream returns true if the request accepts Server-Sent Events,
+// as EventSource clients do.
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), EventStreamContentType)
+}
@@ -0,0 +1,125 @@
+package hyperdrive
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestEventStream() {
+	defer func(c Config) { conf = c }(conf)
+	conf.SSEKeepAlive = 0
+	r := httptest.NewRequest("GET", "/orders", nil)
+	r.Header.Set("Last-Event-ID", "41")
+	rw := httptest.NewRecorder()
+	s, err := NewEventStream(rw, r)
+	suite.Nil(err, "expects no error")
+	suite.Equal("41", s.LastEventID(), "expects the Last-Event-ID of the reconnecting client")
+	suite.Nil(s.Send(Event{ID: "42", Event: "order", Data: map[string]int{"total": 3}}), "expects no error")
+	suite.Nil(s.Send(Event{Data: "first\nsecond", Retry: 2 * time.Second}), "expects no error")
+	suite.Error(s.Send(Event{Event: "a\nb"}), "expects an error for a type with a line break")
+	s.Close()
+	s.Close()
+	suite.Equal(ErrEventStreamClosed, s.Send(Event{Data: "late"}), "expects no events once the stream is closed")
+
+	suite.Equal(http.StatusOK, rw.Code, "expects a 200")
+	suite.Equal(EventStreamContentType, rw.Header().Get("Content-Type"), "expects an event stream")
+	suite.Equal("no-cache", rw.Header().Get("Cache-Control"), "expects the stream not to be cached")
+	suite.Equal("id: 42\nevent: order\ndata: {\"total\":3}\n\nretry: 2000\ndata: first\ndata: second\n\n", rw.Body.String(), "expects the events")
+	suite.True(rw.Flushed, "expects the events to be flushed")
+}
+
+func (suite *HyperdriveTestSuite) TestEventStreamLastEventIDQuery() {
+	s, err := NewEventStream(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders?lastEventId=7", nil))
+	suite.Nil(err, "expects no error")
+	defer s.Close()
+	suite.Equal("7", s.LastEventID(), "expects the lastEventId query param of polyfills")
+}
+
+func (suite *HyperdriveTestSuite) TestEventStreamKeepAlive() {
+	defer func(c Config) { conf = c }(conf)
+	conf.SSEKeepAlive = 5 * time.Millisecond
+	rw := httptest.NewRecorder()
+	s, err := NewEventStream(rw, httptest.NewRequest("GET", "/orders", nil))
+	suite.Nil(err, "expects no error")
+	time.Sleep(20 * time.Millisecond)
+	s.Close()
+	suite.True(strings.HasPrefix(rw.Body.String(), ": keep-alive\n\n"), "expects keep-alive comments")
+}
+
+func (suite *HyperdriveTestSuite) TestEventStreamClientDisconnected() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := NewEventStream(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders", nil).WithContext(ctx))
+	suite.Nil(err, "expects no error")
+	defer s.Close()
+	cancel()
+	<-s.Done()
+	suite.Equal(ErrEventStreamClosed, s.Send(Event{Data: "gone"}), "expects no events once the client has disconnected")
+}
+
+func (suite *HyperdriveTestSuite) TestEventStreamNotFlushable() {
+	_, err := NewEventStream(nonFlushingWriter{httptest.NewRecorder()}, httptest.NewRequest("GET", "/orders", nil))
+	suite.Equal(ErrFlushNotSupported, err, "expects an error when the writer can not be flushed")
+}
+
+func (suite *HyperdriveTestSuite) TestCompressionMiddlewareEventStream() {
+	r := httptest.NewRequest("GET", "/orders", nil)
+	r.Header.Set("Accept", EventStreamContentType)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rw := httptest.NewRecorder()
+	suite.TestAPI.CompressionMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s, _ := NewEventStream(rw, r)
+		defer s.Close()
+		s.Send(Event{Data: "hello"})
+	})).ServeHTTP(rw, r)
+	suite.Empty(rw.Header().Get("Content-Encoding"), "expects event streams not to be compressed")
+	suite.Equal("data: hello\n\n", rw.Body.String(), "expects the event as it is")
+}
+
+func (suite *HyperdriveTestSuite) TestEventStreamDrain() {
+	defer func(c Config) { conf = c }(conf)
+	conf.SSEKeepAlive = 0
+	rw := httptest.NewRecorder()
+	s, err := NewEventStream(rw, httptest.NewRequest("GET", "/orders", nil))
+	suite.Nil(err, "expects no error")
+	drained := suite.TestAPI.streams.drain(time.Second)
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		suite.Fail("expects the stream to be closed when the API shuts down")
+	}
+	s.Close()
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		suite.Fail("expects the stream to be untracked once it is closed")
+	}
+	suite.Equal("retry: 2000\nevent: shutdown\ndata: {}\n\n", rw.Body.String(), "expects a shutdown event")
+	suite.Equal(ErrEventStreamClosed, s.Send(Event{Data: "late"}), "expects no events once the stream is drained")
+}
+
+func (suite *HyperdriveTestSuite) TestEventStreamWriteTimeout() {
+	defer func(c Config) { conf = c }(conf)
+	conf.SSEKeepAlive = 0
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s, err := NewEventStream(rw, r)
+		if err != nil {
+			return
+		}
+		defer s.Close()
+		time.Sleep(100 * time.Millisecond)
+		s.Send(Event{Data: "late"})
+	}))
+	srv.Config.WriteTimeout = 20 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	suite.Require().Nil(err, "expects no error")
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err, "expects the stream not to be cut off by the write timeout")
+	suite.Equal("data: late\n\n", string(body), "expects events sent after the write timeout")
+}
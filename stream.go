@@ -0,0 +1,209 @@
+package hyperdrive
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WebSocketServiceRestart is the WebSocket close code (RFC 6455) telling
+// clients the server is restarting, and that they should reconnect.
+const WebSocketServiceRestart = 1012
+
+// StreamContentType is the media type of the newline delimited JSON written
+// by Stream.
+const StreamContentType = "application/x-ndjson"
+
+// trackedStream is a long-lived connection registered with TrackStream.
+type trackedStream struct {
+	drain  func()
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+// streams tracks the long-lived connections registered with TrackStream,
+// shared by every copy of the API.
+type streams struct {
+	mu       sync.Mutex
+	draining bool
+	active   map[*trackedStream]struct{}
+	wg       sync.WaitGroup
+}
+
+// TrackStream registers the long-lived connection (e.g. Server-Sent Events or
+// WebSocket) serving r, so it is drained when the API shuts down, rather than
+// being cut off: once Shutdown is called, drain is called (in a new
+// goroutine) to notify the client, e.g. with WriteSSEShutdown or
+// WriteWebSocketClose, and end the connection. If the connection has not
+// ended STREAM_DRAIN_TIMEOUT (default: 5s) later, the returned context is
+// cancelled. done must be called once the connection has ended. If the API
+// is already shutting down, drain is called right away.
+func (api *API) TrackStream(r *http.Request, drain func()) (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancel(r.Context())
+	s := &trackedStream{drain: drain, cancel: cancel}
+	st := api.streams
+	st.mu.Lock()
+	if st.active == nil {
+		st.active = map[*trackedStream]struct{}{}
+	}
+	st.active[s] = struct{}{}
+	st.wg.Add(1)
+	draining := st.draining
+	st.mu.Unlock()
+	if draining {
+		go s.drainOnce()
+	}
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			st.mu.Lock()
+			delete(st.active, s)
+			st.mu.Unlock()
+			cancel()
+			st.wg.Done()
+		})
+	}
+}
+
+func (s *trackedStream) drainOnce() {
+	s.once.Do(s.drain)
+}
+
+// drain notifies every tracked stream that the API is shutting down, and
+// returns a channel which is closed once they have all ended. Streams which
+// have not ended after the given timeout have their context cancelled.
+func (st *streams) drain(timeout time.Duration) <-chan struct{} {
+	st.mu.Lock()
+	st.draining = true
+	active := make([]*trackedStream, 0, len(st.active))
+	for s := range st.active {
+		active = append(active, s)
+	}
+	st.mu.Unlock()
+	for _, s := range active {
+		go s.drainOnce()
+	}
+	done := make(chan struct{})
+	go func() {
+		st.wg.Wait()
+		close(done)
+	}()
+	go func() {
+		select {
+		case <-done:
+		case <-time.After(timeout):
+			for _, s := range active {
+				s.cancel()
+			}
+		}
+	}()
+	return done
+}
+
+// WriteSSEShutdown writes a Server-Sent Event of type "shutdown" to rw, and
+// flushes it, telling the client to reconnect after the given delay (via the
+// event's retry field), by which time another instance should be serving.
+func WriteSSEShutdown(rw http.ResponseWriter, retry time.Duration) error {
+	if _, err := fmt.Fprintf(rw, "retry: %d\nevent: shutdown\ndata: {}\n\n", retry.Milliseconds()); err != nil {
+		return err
+	}
+	if f, ok := rw.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// WriteWebSocketClose writes a WebSocket close frame (RFC 6455), with the
+// given code and reason, to w, which must be the hijacked connection. Use
+// WebSocketServiceRestart to tell the client to reconnect. WebSocket
+// libraries have their own way of doing this (e.g. WriteControl), which
+// should be used instead when available.
+func WriteWebSocketClose(w io.Writer, code int, reason string) error {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	_, err := w.Write(append([]byte{0x88, byte(len(payload))}, payload...))
+	return err
+}
+
+// Stream writes a long-running chunked response, e.g. an export feed, as
+// newline delimited JSON (application/x-ndjson, unless another Content-Type
+// has been set): write is called with an encoder, each Encode of which
+// writes a value, followed by a newline, and flushes it to the client, so
+// it is sent as soon as it is ready, e.g.
+//
+//	err := hyperdrive.Stream(rw, r, func(enc *json.Encoder) error {
+//		for rows.Next() {
+//			...
+//			if err := enc.Encode(row); err != nil {
+//				return err
+//			}
+//		}
+//		return rows.Err()
+//	})
+//
+// The status (200 OK) and headers are sent, and flushed, before write is
+// called, with caching and proxy buffering (X-Accel-Buffering) disabled, and
+// the server's WRITE_TIMEOUT cleared, so long-running exports are not cut
+// off. Once the client disconnects, and the request's context is done, Encode
+// returns the context's error, so write can stop, and Stream returns it.
+// Otherwise, Stream returns the error returned by write, which can not be
+// responded to, as the response has already begun, and should be logged.
+func Stream(rw http.ResponseWriter, r *http.Request, write func(enc *json.Encoder) error) error {
+	h := rw.Header()
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", StreamContentType)
+	}
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Del("Content-Length")
+	clearWriteDeadline(rw)
+	rw.WriteHeader(http.StatusOK)
+	sw := &streamWriter{rw: rw, ctx: r.Context()}
+	sw.flush()
+	if err := write(json.NewEncoder(sw)); err != nil {
+		return err
+	}
+	return r.Context().Err()
+}
+
+// streamWriter flushes every write of a Stream, and fails them once the
+// client has disconnected.
+type streamWriter struct {
+	rw  http.ResponseWriter
+	ctx context.Context
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := w.rw.Write(b)
+	if err != nil {
+		return n, err
+	}
+	w.flush()
+	return n, nil
+}
+
+func (w *streamWriter) flush() {
+	if f, ok := w.rw.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// clearWriteDeadline clears the deadline set by the server's WRITE_TIMEOUT
+// for the response, which would otherwise cut off a long-lived stream. It is
+// left as it is by writers which can not set it (e.g. in tests).
+func clearWriteDeadline(rw http.ResponseWriter) {
+	http.NewResponseController(rw).SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,135 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func (suite *HyperdriveTestSuite) TestTrackStreamDrain() {
+	rw := httptest.NewRecorder()
+	finished := make(chan struct{})
+	drained := make(chan struct{})
+	ctx, done := suite.TestAPI.TrackStream(suite.TestGetRequest, func() { close(drained) })
+	go func() {
+		defer close(finished)
+		defer done()
+		select {
+		case <-drained:
+			WriteSSEShutdown(rw, 2*time.Second)
+		case <-ctx.Done():
+		}
+	}()
+	suite.Nil(suite.TestAPI.Shutdown(context.Background()), "expects no error")
+	<-finished
+	suite.Equal("retry: 2000\nevent: shutdown\ndata: {}\n\n", rw.Body.String(), "expects the client to be told to reconnect")
+	suite.True(rw.Flushed, "expects the event to be flushed")
+}
+
+func (suite *HyperdriveTestSuite) TestTrackStreamDrainTimeout() {
+	defer func(c Config) { conf = c }(conf)
+	conf.StreamDrainTimeout = 10 * time.Millisecond
+	ctx, done := suite.TestAPI.TrackStream(suite.TestGetRequest, func() {})
+	go func() {
+		<-ctx.Done()
+		done()
+	}()
+	suite.Nil(suite.TestAPI.Shutdown(context.Background()), "expects streams which do not end to be cancelled")
+	suite.Equal(context.Canceled, ctx.Err(), "expects the stream's context to be cancelled")
+}
+
+func (suite *HyperdriveTestSuite) TestTrackStreamWhileDraining() {
+	suite.Nil(suite.TestAPI.Shutdown(context.Background()), "expects no error")
+	drained := make(chan struct{})
+	_, done := suite.TestAPI.TrackStream(suite.TestGetRequest, func() { close(drained) })
+	defer done()
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		suite.Fail("expects streams tracked while shutting down to be drained right away")
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestWriteWebSocketClose() {
+	var b bytes.Buffer
+	suite.Nil(WriteWebSocketClose(&b, WebSocketServiceRestart, "restart"), "expects no error")
+	suite.Equal([]byte{0x88, 9, 0x03, 0xf4, 'r', 'e', 's', 't', 'a', 'r', 't'}, b.Bytes(), "expects a close frame with the code and reason")
+}
+
+func (suite *HyperdriveTestSuite) TestStream() {
+	rw := httptest.NewRecorder()
+	rw.Header().Set("Content-Length", "10")
+	err := Stream(rw, httptest.NewRequest("GET", "/export", nil), func(enc *json.Encoder) error {
+		for i := 1; i <= 3; i++ {
+			if err := enc.Encode(map[string]int{"id": i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	suite.Nil(err, "expects no error")
+	suite.Equal(http.StatusOK, rw.Code, "expects a 200")
+	suite.Equal(StreamContentType, rw.Header().Get("Content-Type"), "expects newline delimited json")
+	suite.Equal("no-cache", rw.Header().Get("Cache-Control"), "expects the stream not to be cached")
+	suite.Empty(rw.Header().Get("Content-Length"), "expects no Content-Length, so the response is chunked")
+	suite.Equal("{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n", rw.Body.String(), "expects a line for each value")
+	suite.True(rw.Flushed, "expects the values to be flushed")
+}
+
+func (suite *HyperdriveTestSuite) TestStreamClientDisconnected() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest("GET", "/export", nil).WithContext(ctx)
+	rw := httptest.NewRecorder()
+	written := 0
+	err := Stream(rw, r, func(enc *json.Encoder) error {
+		for i := 1; i <= 3; i++ {
+			if i == 2 {
+				cancel()
+			}
+			if err := enc.Encode(i); err != nil {
+				return err
+			}
+			written++
+		}
+		return nil
+	})
+	suite.Equal(context.Canceled, err, "expects the context's error once the client has disconnected")
+	suite.Equal(1, written, "expects encoding to fail once the client has disconnected")
+	suite.Equal("1\n", rw.Body.String(), "expects nothing to be written once the client has disconnected")
+}
+
+func (suite *HyperdriveTestSuite) TestStreamError() {
+	rw := httptest.NewRecorder()
+	err := Stream(rw, httptest.NewRequest("GET", "/export", nil), func(enc *json.Encoder) error {
+		return errors.New("query failed")
+	})
+	suite.EqualError(err, "query failed", "expects the error of write")
+}
+
+func (suite *HyperdriveTestSuite) TestStreamWriteTimeout() {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		Stream(rw, r, func(enc *json.Encoder) error {
+			for i := 1; i <= 2; i++ {
+				time.Sleep(50 * time.Millisecond)
+				if err := enc.Encode(i); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}))
+	srv.Config.WriteTimeout = 20 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	suite.Require().Nil(err, "expects no error")
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err, "expects the stream not to be cut off by the write timeout")
+	suite.Equal("1\n2\n", string(body), "expects values written after the write timeout")
+}
@@ -19,6 +19,7 @@ type parsedParam struct {
 	Key      string
 	Allowed  []string
 	Required []string
+	Default  string
 }
 
 func (p parsedParam) IsAllowed(method string) bool {
@@ -49,6 +50,18 @@ func (pp parsedParams) Allowed(method string) []string {
 	return allowed
 }
 
+// Defaults returns the default values of the params allowed for the given
+// method, by key.
+func (pp parsedParams) Defaults(method string) map[string]string {
+	defaults := map[string]string{}
+	for _, p := range pp {
+		if p.Default != "" && p.IsAllowed(method) {
+			defaults[p.Key] = p.Default
+		}
+	}
+	return defaults
+}
+
 func (pp parsedParams) Required(method string) []string {
 	var required []string
 	for _, p := range pp {
@@ -87,6 +100,7 @@ func parseField(field reflect.StructField) parsedParam {
 		tags     []string
 		allowed  = []string{"GET", "POST", "PUT", "PATCH"}
 		required = []string{}
+		def      string
 	)
 
 	t := field.Tag.Get(tagName)
@@ -97,7 +111,7 @@ func parseField(field reflect.StructField) parsedParam {
 	}
 
 	for _, tag := range tags {
-		pairs := strings.Split(tag, "=")
+		pairs := strings.SplitN(tag, "=", 2)
 		k, v := pairs[0], pairs[1]
 		switch k {
 		case "a":
@@ -109,13 +123,15 @@ func parseField(field reflect.StructField) parsedParam {
 				required = strings.Split(v, ",")
 				allowed = set.Strings(allowed)
 			}
+		case "d":
+			def = v
 		}
 	}
 	required = set.Strings(required)
 	allowed = append(allowed, required...)
 	allowed = set.Strings(allowed)
 	name, desc := fieldNameAndDesc(field)
-	return parsedParam{name, desc, field.Name, field.Type.Name(), key, allowed, required}
+	return parsedParam{name, desc, field.Name, field.Type.Name(), key, allowed, required, def}
 }
 
 func fieldNameAndDesc(field reflect.StructField) (string, string) {
@@ -87,3 +87,9 @@ func (suite *HyperdriveTestSuite) TestParameterDesc() {
 func (suite *HyperdriveTestSuite) TestParseTestParamCustom() {
 	suite.Equal(suite.TestParsedParamCustom, parseEndpoint(suite.TestCustomEndpoint)["id"], "expects it to return the correct parsedParam")
 }
+
+func (suite *HyperdriveTestSuite) TestParseDefault() {
+	params := parseEndpoint(&PaginatedEndpoint{})
+	suite.Equal("25", params["per_page"].Default, "expects the d option to be parsed")
+	suite.Equal(map[string]string{"page": "1"}, params.Defaults("POST"), "expects the defaults of the allowed params")
+}
@@ -0,0 +1,79 @@
+package hyperdrive
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type userAgentContextKey struct{}
+
+// userAgentPatterns parses a comma separated list of case insensitive
+// regular expressions, e.g. USER_AGENT_BLOCK.
+func userAgentPatterns(list string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid User-Agent pattern %q: %v", p, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+func matchUserAgent(patterns []*regexp.Regexp, ua string) (string, bool) {
+	for _, re := range patterns {
+		if re.MatchString(ua) {
+			return strings.TrimPrefix(re.String(), "(?i)"), true
+		}
+	}
+	return "", false
+}
+
+// FlaggedUserAgent returns the USER_AGENT_BLOCK pattern matched by the
+// request's User-Agent, and true, if it was flagged by UserAgentMiddleware,
+// e.g. so it can be given a lower rate limit.
+func FlaggedUserAgent(r *http.Request) (string, bool) {
+	pattern, ok := r.Context().Value(userAgentContextKey{}).(string)
+	return pattern, ok
+}
+
+// UserAgentMiddleware wraps the given http.Handler, and checks the User-Agent
+// of each request against USER_AGENT_BLOCK, a comma separated list of case
+// insensitive regular expressions, e.g. "curl,python-requests,^$" (the last
+// of which matches requests with no User-Agent). Requests which match are
+// rejected with a 403 Forbidden, or, when USER_AGENT_ACTION is tag, are
+// served, but flagged (see FlaggedUserAgent) for handlers and other
+// middleware to act on.
+//
+// Requests matching USER_AGENT_ALLOW (by default, the crawlers of the major
+// search engines) are never blocked. Note the User-Agent header is chosen by
+// the client, so this only stops clients which identify themselves honestly.
+func (api *API) UserAgentMiddleware(h http.Handler) http.Handler {
+	// Config.validate ensures the patterns compile.
+	blocked, _ := userAgentPatterns(conf.UserAgentBlock)
+	allowed, _ := userAgentPatterns(conf.UserAgentAllow)
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ua := r.UserAgent()
+		if _, ok := matchUserAgent(allowed, ua); ok {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		pattern, ok := matchUserAgent(blocked, ua)
+		if !ok {
+			h.ServeHTTP(rw, r)
+			return
+		}
+		if conf.UserAgentAction == "block" {
+			writeProblem(rw, http.StatusForbidden, "Requests from this User-Agent are not allowed.")
+			return
+		}
+		h.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), userAgentContextKey{}, pattern)))
+	})
+}
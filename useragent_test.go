@@ -0,0 +1,53 @@
+package hyperdrive
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func userAgentRequest(ua string) *http.Request {
+	r := httptest.NewRequest("GET", "/test", nil)
+	r.Header.Set("User-Agent", ua)
+	return r
+}
+
+func (suite *HyperdriveTestSuite) TestUserAgentMiddlewareBlock() {
+	defer func(c Config) { conf = c }(conf)
+	conf.UserAgentBlock = "curl,bot,^$"
+	h := suite.TestAPI.UserAgentMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, userAgentRequest("curl/8.0"))
+	suite.Equal(http.StatusForbidden, rw.Code, "expects a 403 for a blocked User-Agent")
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, userAgentRequest("CURL/8.0"))
+	suite.Equal(http.StatusForbidden, rw.Code, "expects patterns to be case insensitive")
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, userAgentRequest(""))
+	suite.Equal(http.StatusForbidden, rw.Code, "expects a 403 for a missing User-Agent")
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, userAgentRequest("Mozilla/5.0 (compatible; Googlebot/2.1)"))
+	suite.Equal(http.StatusOK, rw.Code, "expects allowed crawlers not to be blocked")
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, userAgentRequest("Mozilla/5.0"))
+	suite.Equal(http.StatusOK, rw.Code, "expects other User-Agents not to be blocked")
+}
+
+func (suite *HyperdriveTestSuite) TestUserAgentMiddlewareTag() {
+	defer func(c Config) { conf = c }(conf)
+	conf.UserAgentBlock = "python-requests"
+	conf.UserAgentAction = "tag"
+	var (
+		pattern string
+		flagged bool
+	)
+	h := suite.TestAPI.UserAgentMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		pattern, flagged = FlaggedUserAgent(r)
+	}))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, userAgentRequest("python-requests/2.31"))
+	suite.Equal(http.StatusOK, rw.Code, "expects tagged requests to be served")
+	suite.True(flagged, "expects the request to be flagged")
+	suite.Equal("python-requests", pattern, "expects the matched pattern")
+	h.ServeHTTP(httptest.NewRecorder(), userAgentRequest("Mozilla/5.0"))
+	suite.False(flagged, "expects other requests not to be flagged")
+}
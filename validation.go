@@ -0,0 +1,169 @@
+package hyperdrive
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// The locations of the values reported by a FieldError.
+const (
+	InBody  = "body"
+	InQuery = "query"
+	InPath  = "path"
+)
+
+// The codes of the FieldErrors reported by hyperdrive. Custom validation may
+// use its own codes.
+const (
+	ValidationRequired    = "required"
+	ValidationInvalidType = "invalid_type"
+	ValidationInvalid     = "invalid"
+	ValidationTooLarge    = "too_large"
+)
+
+// maxErrorValueLength is the number of characters of a provided value
+// reported by a FieldError, so large values are not echoed back in full.
+const maxErrorValueLength = 64
+
+// FieldError describes a single invalid value in a request: where it is (In
+// the body, at the JSON Pointer, or in the query or path, as the named
+// Parameter), a stable Code for clients to switch on, and a human readable
+// Message. Errors with code invalid_type also report the Expected type, e.g.
+// "an integer", and the Value provided, truncated if it is long.
+type FieldError struct {
+	In        string      `json:"in"`
+	Pointer   string      `json:"pointer,omitempty"`
+	Parameter string      `json:"parameter,omitempty"`
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Expected  string      `json:"expected,omitempty"`
+	Value     interface{} `json:"value,omitempty"`
+}
+
+// ValidationError is an error holding every FieldError found while
+// validating a request, so frontends can map them to form fields. Respond
+// with it using WriteValidationError.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error returns the messages of every FieldError.
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// AddBodyError adds a FieldError for the value in the body at the given JSON
+// Pointer (see JSONPointer).
+func (e *ValidationError) AddBodyError(pointer string, code string, message string) {
+	e.Errors = append(e.Errors, FieldError{In: InBody, Pointer: pointer, Code: code, Message: message})
+}
+
+// AddParamError adds a FieldError for the named query or path (given as in)
+// parameter.
+func (e *ValidationError) AddParamError(in string, name string, code string, message string) {
+	e.Errors = append(e.Errors, FieldError{In: in, Parameter: name, Code: code, Message: message})
+}
+
+// AddTypeError adds a FieldError, with code invalid_type, for the named param
+// (in the query, path, or body, given as in) whose value could not be
+// converted to the expected type, described as by Bind, e.g. "an integer".
+func (e *ValidationError) AddTypeError(in string, name string, expected string, value interface{}) {
+	message := fmt.Sprintf("%s must be %s", name, expected)
+	if in == InBody {
+		e.AddBodyError(JSONPointer(name), ValidationInvalidType, message)
+	} else {
+		e.AddParamError(in, name, ValidationInvalidType, message)
+	}
+	fe := &e.Errors[len(e.Errors)-1]
+	fe.Expected = expected
+	fe.Value = truncateErrorValue(value)
+}
+
+// truncateErrorValue shortens string values to maxErrorValueLength
+// characters.
+func truncateErrorValue(value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok || utf8.RuneCountInString(s) <= maxErrorValueLength {
+		return value
+	}
+	return string([]rune(s)[:maxErrorValueLength]) + "..."
+}
+
+// Empty returns true if no errors have been added.
+func (e *ValidationError) Empty() bool {
+	return len(e.Errors) == 0
+}
+
+// JSONPointer returns the RFC 6901 JSON Pointer for the given reference
+// tokens, e.g. JSONPointer("items", "0", "name") returns "/items/0/name".
+// Tokens containing ~ or / are escaped.
+func JSONPointer(tokens ...string) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString("/")
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+	}
+	return b.String()
+}
+
+// WriteValidationError responds with a 400 Bad Request, and an
+// application/problem+json body listing every FieldError of the
+// ValidationError in its errors member, e.g.
+//
+//	{
+//	  "type": "about:blank",
+//	  "title": "Bad Request",
+//	  "status": 400,
+//	  "detail": "The request is invalid.",
+//	  "errors": [
+//	    {"in": "body", "pointer": "/items/0/name", "code": "required", "message": "Missing required field: name"},
+//	    {"in": "query", "parameter": "limit", "code": "invalid_type", "message": "limit must be an integer", "expected": "an integer", "value": "ten"}
+//	  ]
+//	}
+func WriteValidationError(rw http.ResponseWriter, err *ValidationError) {
+	p := NewProblem(http.StatusBadRequest, "The request is invalid.")
+	p.Extensions = map[string]interface{}{"errors": err.Errors}
+	RespondProblem(rw, p)
+}
+
+// WriteError responds with a 400 Bad Request, as WriteValidationError, if err
+// is a *ValidationError, e.g. one returned by Bind or a typed param getter,
+// with the status and code of an *Error (e.g. one returned by NotFound), or
+// one it wraps, with a 415 Unsupported Media Type if err is
+// ErrUnsupportedMediaType, with a
+// 413 Request Entity Too Large if it is ErrBodyTooLarge, and otherwise with a
+// 500 Internal Server Error, so handlers need not format their own errors,
+// e.g.
+//
+//	limit, err := hyperdrive.ParamInt(r, "limit")
+//	if err != nil {
+//		hyperdrive.WriteError(rw, err)
+//		return
+//	}
+func WriteError(rw http.ResponseWriter, err error) {
+	if verr, ok := err.(*ValidationError); ok {
+		WriteValidationError(rw, verr)
+		return
+	}
+	var herr *Error
+	if errors.As(err, &herr) {
+		RespondProblem(rw, herr.problem())
+		return
+	}
+	if err == ErrUnsupportedMediaType {
+		writeProblem(rw, http.StatusUnsupportedMediaType, "The Content-Type of the request body is not supported.")
+		return
+	}
+	if err == ErrBodyTooLarge {
+		writeProblem(rw, http.StatusRequestEntityTooLarge, fmt.Sprintf("The request body must not be larger than %d bytes.", conf.RawBodyMaxBytes))
+		return
+	}
+	writeProblem(rw, http.StatusInternalServerError, GetErrorText(http.StatusInternalServerError, err))
+}
@@ -0,0 +1,59 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func (suite *HyperdriveTestSuite) TestJSONPointer() {
+	suite.Equal("/items/0/name", JSONPointer("items", "0", "name"), "expects a pointer to the value")
+	suite.Equal("/a~1b/m~0n", JSONPointer("a/b", "m~n"), "expects tokens to be escaped")
+	suite.Equal("", JSONPointer(), "expects the pointer to the whole document")
+}
+
+func (suite *HyperdriveTestSuite) TestValidationError() {
+	verr := &ValidationError{}
+	suite.True(verr.Empty(), "expects no errors")
+	verr.AddBodyError(JSONPointer("items", "0", "name"), ValidationRequired, "Missing required field: name")
+	verr.AddParamError(InQuery, "limit", ValidationInvalidType, "limit must be an integer")
+	suite.False(verr.Empty(), "expects errors")
+	suite.Equal("Missing required field: name; limit must be an integer", verr.Error(), "expects every message")
+	suite.Equal(FieldError{In: InBody, Pointer: "/items/0/name", Code: ValidationRequired, Message: "Missing required field: name"}, verr.Errors[0], "expects the body error")
+	suite.Equal(FieldError{In: InQuery, Parameter: "limit", Code: ValidationInvalidType, Message: "limit must be an integer"}, verr.Errors[1], "expects the param error")
+}
+
+func (suite *HyperdriveTestSuite) TestAddTypeError() {
+	verr := &ValidationError{}
+	verr.AddTypeError(InQuery, "limit", "an integer", "ten")
+	verr.AddTypeError(InBody, "tags", "a list, where each item is a string", strings.Repeat("a", 100))
+	suite.Equal(FieldError{In: InQuery, Parameter: "limit", Code: ValidationInvalidType, Message: "limit must be an integer", Expected: "an integer", Value: "ten"}, verr.Errors[0], "expects the expected type and provided value")
+	suite.Equal("/tags", verr.Errors[1].Pointer, "expects body errors to be reported with a pointer")
+	suite.Equal(strings.Repeat("a", maxErrorValueLength)+"...", verr.Errors[1].Value, "expects long values to be truncated")
+}
+
+func (suite *HyperdriveTestSuite) TestWriteError() {
+	verr := &ValidationError{}
+	verr.AddTypeError(InQuery, "limit", "an integer", "ten")
+	rw := httptest.NewRecorder()
+	WriteError(rw, verr)
+	suite.Equal(http.StatusBadRequest, rw.Code, "expects a 400 Bad Request")
+	suite.Contains(rw.Body.String(), `"expected":"an integer","value":"ten"`, "expects the expected type and provided value")
+	rw = httptest.NewRecorder()
+	WriteError(rw, errors.New("boom"))
+	suite.Equal(http.StatusInternalServerError, rw.Code, "expects a 500 Internal Server Error for other errors")
+}
+
+func (suite *HyperdriveTestSuite) TestWriteValidationError() {
+	verr := &ValidationError{}
+	verr.AddBodyError("/name", ValidationRequired, "Missing required field: name")
+	rw := httptest.NewRecorder()
+	WriteValidationError(rw, verr)
+	suite.Equal(http.StatusBadRequest, rw.Code, "expects a 400 Bad Request")
+	suite.Equal(problemContentType, rw.Header().Get("Content-Type"), "expects a problem+json response")
+	var body map[string]interface{}
+	json.Unmarshal(rw.Body.Bytes(), &body)
+	suite.Equal([]interface{}{map[string]interface{}{"in": "body", "pointer": "/name", "code": "required", "message": "Missing required field: name"}}, body["errors"], "expects the errors in a stable schema")
+}
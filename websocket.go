@@ -0,0 +1,575 @@
+package hyperdrive
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The WebSocket close codes (RFC 6455) used by WebSocketConn, alongside
+// WebSocketServiceRestart.
+const (
+	WebSocketNormalClosure  = 1000
+	WebSocketGoingAway      = 1001
+	WebSocketProtocolError  = 1002
+	WebSocketInvalidPayload = 1007
+	WebSocketMessageTooBig  = 1009
+	WebSocketInternalError  = 1011
+)
+
+const (
+	// webSocketNoStatusCode is the code of close frames with no code.
+	webSocketNoStatusCode = 1005
+	// webSocketAcceptGUID is hashed with the key of an upgrade request, for
+	// the Sec-WebSocket-Accept header.
+	webSocketAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// webSocketWriteWait is how long a frame can take to be written.
+	webSocketWriteWait = 10 * time.Second
+	// webSocketCloseWait is how long Close waits for the client's close frame.
+	webSocketCloseWait = time.Second
+	// webSocketSendQueueLength is how many messages Send queues, before it
+	// blocks.
+	webSocketSendQueueLength = 16
+)
+
+// The opcodes of WebSocket frames.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+var (
+	// ErrNotWebSocket is returned by UpgradeWebSocket when the request is not
+	// a valid WebSocket upgrade request, after responding with a problem.
+	ErrNotWebSocket = errors.New("not a websocket upgrade request")
+
+	// ErrWebSocketClosed is returned by WebSocketConn.Send once the
+	// connection is closing, or has ended.
+	ErrWebSocketClosed = errors.New("websocket closed")
+)
+
+// WebSocketHandler interface is satisfied if the endpoint has implemented a
+// method called WebSocket(), which serves a WebSocket connection. AddEndpoint
+// routes WebSocket upgrade requests for the endpoint's path to it, wrapped in
+// the same middleware as its other methods, so real-time endpoints live
+// alongside REST ones, e.g.
+//
+//	func (e *ChatEndpoint) WebSocket(conn *hyperdrive.WebSocketConn) {
+//		for msg := range conn.Messages() {
+//			e.room.Broadcast(msg.Data)
+//		}
+//	}
+//
+// The connection is closed when WebSocket returns, and drained (see
+// TrackStream) when the API shuts down: clients are sent a close frame with
+// WebSocketServiceRestart, so they reconnect.
+type WebSocketHandler interface {
+	WebSocket(*WebSocketConn)
+}
+
+// WebSocketMessage is a message received from, or sent to, a WebSocket
+// client: text (UTF-8), unless Binary.
+type WebSocketMessage struct {
+	Binary bool
+	Data   []byte
+}
+
+// WebSocketConn is a WebSocket connection, upgraded by UpgradeWebSocket. A
+// read pump delivers the messages received to Messages, answering pings,
+// and a write pump sends the messages given to Send, and pings the client
+// every WEBSOCKET_PING_INTERVAL (default: 30s, 0 disables it). Clients which
+// send nothing, not even a pong, for twice that long are disconnected.
+// Messages larger than WEBSOCKET_MAX_MESSAGE_BYTES (default: 1MiB) close
+// the connection with WebSocketMessageTooBig.
+type WebSocketConn struct {
+	conn      net.Conn
+	br        *bufio.Reader
+	r         *http.Request
+	ctx       context.Context
+	cancel    context.CancelFunc
+	messages  chan WebSocketMessage
+	send      chan wsFrame
+	writerEnd chan struct{}
+	writeMu   sync.Mutex
+	closing   chan struct{}
+	closeOnce sync.Once
+	peerClose chan struct{}
+	peerOnce  sync.Once
+	done      chan struct{}
+	endOnce   sync.Once
+	wg        sync.WaitGroup
+}
+
+// wsFrame is a frame to be written by the write pump.
+type wsFrame struct {
+	opcode  byte
+	payload []byte
+}
+
+// wsCloseError is a failure of the read pump, which closes the connection
+// with the given code.
+type wsCloseError struct {
+	code   int
+	reason string
+}
+
+func (e wsCloseError) Error() string {
+	return "websocket: " + e.reason
+}
+
+// UpgradeWebSocket upgrades the connection of a WebSocket (RFC 6455, version
+// 13) upgrade request, and starts its pumps. Requests which are not valid
+// upgrade requests are responded to with a problem (e.g. 426 Upgrade
+// Required), and ErrNotWebSocket is returned. Cross-origin requests are
+// only upgraded if their Origin is in CORS_ORIGINS (or it is *), since
+// browsers do not apply CORS to WebSockets.
+//
+// The connection's Context carries the values of the request's context, so
+// what middleware adds to it for the upgrade request (e.g. its Principal, or
+// request ID) is available for the life of the connection.
+func UpgradeWebSocket(rw http.ResponseWriter, r *http.Request) (*WebSocketConn, error) {
+	if r.Method != "GET" || !isWebSocketUpgrade(r) {
+		rw.Header().Set("Upgrade", "websocket")
+		writeProblem(rw, http.StatusUpgradeRequired, "This resource must be requested with a WebSocket upgrade.")
+		return nil, ErrNotWebSocket
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		rw.Header().Set("Sec-WebSocket-Version", "13")
+		writeProblem(rw, http.StatusBadRequest, "Only version 13 of the WebSocket protocol is supported.")
+		return nil, ErrNotWebSocket
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		writeProblem(rw, http.StatusBadRequest, "The Sec-WebSocket-Key header is invalid.")
+		return nil, ErrNotWebSocket
+	}
+	if !webSocketOriginAllowed(r) {
+		writeProblem(rw, http.StatusForbidden, "WebSocket connections are not allowed from this origin.")
+		return nil, ErrNotWebSocket
+	}
+	h, ok := rw.(http.Hijacker)
+	if !ok {
+		err := errors.New("hyperdrive: http.Hijacker is not supported by the underlying http.ResponseWriter")
+		writeProblem(rw, http.StatusInternalServerError, GetErrorText(http.StatusInternalServerError, err))
+		return nil, err
+	}
+	conn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// Clear the deadlines set by the server's READ_TIMEOUT and WRITE_TIMEOUT,
+	// which would otherwise end the connection.
+	conn.SetDeadline(time.Time{})
+	sum := sha1.Sum([]byte(key + webSocketAcceptGUID))
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return newWebSocketConn(conn, brw.Reader, r), nil
+}
+
+// newWebSocketConn returns the WebSocketConn of an upgraded connection, and
+// starts its pumps.
+func newWebSocketConn(conn net.Conn, br *bufio.Reader, r *http.Request) *WebSocketConn {
+	ctx, cancel := context.WithCancel(r.Context())
+	c := &WebSocketConn{
+		conn:      conn,
+		br:        br,
+		r:         r,
+		ctx:       ctx,
+		cancel:    cancel,
+		messages:  make(chan WebSocketMessage),
+		send:      make(chan wsFrame, webSocketSendQueueLength),
+		writerEnd: make(chan struct{}),
+		closing:   make(chan struct{}),
+		peerClose: make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	c.wg.Add(2)
+	go c.readPump()
+	go c.writePump()
+	return c
+}
+
+// isWebSocketUpgrade returns true if the request asks for its connection to
+// be upgraded to a WebSocket.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") && headerHasToken(r.Header, "Connection", "upgrade")
+}
+
+// headerHasToken returns true if the comma separated values of the named
+// header include the token, in any case.
+func headerHasToken(h http.Header, name string, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// webSocketOriginAllowed returns true if the request has no Origin, or one
+// with the host of the request, or in CORS_ORIGINS.
+func webSocketOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	origins := corsOrigins()
+	return contains(origins, "*") || contains(origins, origin)
+}
+
+// Request returns the upgrade request of the connection.
+func (c *WebSocketConn) Request() *http.Request {
+	return c.r
+}
+
+// Context returns the context of the connection, which carries the values
+// of the upgrade request's context, and is cancelled once the connection has
+// ended.
+func (c *WebSocketConn) Context() context.Context {
+	return c.ctx
+}
+
+// Messages returns the channel the messages received are delivered to, in
+// order. It is closed once the connection is closing, or has ended. Nothing
+// more is read, and pings are not answered, until a message delivered has
+// been received from it.
+func (c *WebSocketConn) Messages() <-chan WebSocketMessage {
+	return c.messages
+}
+
+// Done returns a channel which is closed once the connection has ended.
+func (c *WebSocketConn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Send queues the message, to be sent by the write pump. It returns
+// ErrWebSocketClosed once the connection is closing, or has ended.
+func (c *WebSocketConn) Send(m WebSocketMessage) error {
+	opcode := byte(wsText)
+	if m.Binary {
+		opcode = wsBinary
+	}
+	select {
+	case <-c.closing:
+		return ErrWebSocketClosed
+	case <-c.done:
+		return ErrWebSocketClosed
+	default:
+	}
+	select {
+	case c.send <- wsFrame{opcode: opcode, payload: m.Data}:
+		return nil
+	case <-c.closing:
+		return ErrWebSocketClosed
+	case <-c.done:
+		return ErrWebSocketClosed
+	}
+}
+
+// SendText sends a text message.
+func (c *WebSocketConn) SendText(s string) error {
+	return c.Send(WebSocketMessage{Data: []byte(s)})
+}
+
+// SendJSON sends v, encoded as JSON, as a text message.
+func (c *WebSocketConn) SendJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Send(WebSocketMessage{Data: b})
+}
+
+// Close closes the connection, with the given close code (e.g.
+// WebSocketNormalClosure) and reason, waiting briefly for the client to
+// acknowledge it, and for the pumps to stop. It is safe to call more than
+// once, and from several goroutines.
+func (c *WebSocketConn) Close(code int, reason string) error {
+	c.closeWith(code, reason, true)
+	c.wg.Wait()
+	return nil
+}
+
+// closeWith sends a close frame, after the messages queued, unless one has
+// been sent already, waits for the client's close frame, if wait is true,
+// and ends the connection.
+func (c *WebSocketConn) closeWith(code int, reason string, wait bool) {
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		<-c.writerEnd
+		c.writeMu.Lock()
+		c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+		WriteWebSocketClose(c.conn, code, reason)
+		c.writeMu.Unlock()
+	})
+	if wait {
+		select {
+		case <-c.peerClose:
+		case <-c.done:
+		case <-time.After(webSocketCloseWait):
+		}
+	}
+	c.end()
+}
+
+// end ends the connection, without a closing handshake.
+func (c *WebSocketConn) end() {
+	c.endOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+		c.cancel()
+	})
+}
+
+// readPump delivers the messages received to Messages, until the connection
+// ends, closing it when the client does, or breaks the protocol.
+func (c *WebSocketConn) readPump() {
+	defer c.wg.Done()
+	defer close(c.messages)
+	for {
+		opcode, data, err := c.readMessage()
+		if err != nil {
+			var cerr wsCloseError
+			if errors.As(err, &cerr) {
+				c.closeWith(cerr.code, cerr.reason, false)
+			} else {
+				c.end()
+			}
+			return
+		}
+		select {
+		case c.messages <- WebSocketMessage{Binary: opcode == wsBinary, Data: data}:
+		case <-c.closing:
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readMessage returns the next message received, reassembled from its
+// fragments, answering the control frames received in between.
+func (c *WebSocketConn) readMessage() (byte, []byte, error) {
+	var (
+		opcode  byte
+		message []byte
+		started bool
+	)
+	for {
+		if conf.WebSocketPingInterval > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(2 * conf.WebSocketPingInterval))
+		}
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case wsPing:
+			c.queue(wsFrame{opcode: wsPong, payload: payload})
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.peerOnce.Do(func() { close(c.peerClose) })
+			code := WebSocketNormalClosure
+			if len(payload) >= 2 {
+				if received := int(binary.BigEndian.Uint16(payload)); received != webSocketNoStatusCode {
+					code = received
+				}
+			}
+			return 0, nil, wsCloseError{code: code, reason: "closed by the client"}
+		case wsText, wsBinary:
+			if started {
+				return 0, nil, wsCloseError{code: WebSocketProtocolError, reason: "expected a continuation frame"}
+			}
+			opcode, started = op, true
+		case wsContinuation:
+			if !started {
+				return 0, nil, wsCloseError{code: WebSocketProtocolError, reason: "unexpected continuation frame"}
+			}
+		default:
+			return 0, nil, wsCloseError{code: WebSocketProtocolError, reason: "unknown opcode"}
+		}
+		if len(message)+len(payload) > conf.WebSocketMaxMessageBytes {
+			return 0, nil, wsCloseError{code: WebSocketMessageTooBig, reason: "message too big"}
+		}
+		message = append(message, payload...)
+		if !fin {
+			continue
+		}
+		if opcode == wsText && !utf8.Valid(message) {
+			return 0, nil, wsCloseError{code: WebSocketInvalidPayload, reason: "text message is not valid UTF-8"}
+		}
+		return opcode, message, nil
+	}
+}
+
+// readFrame reads a frame, which clients must mask, and unmasks its payload.
+func (c *WebSocketConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(c.br, h[:]); err != nil {
+		return
+	}
+	fin, opcode = h[0]&0x80 != 0, h[0]&0x0f
+	if h[0]&0x70 != 0 {
+		return fin, opcode, nil, wsCloseError{code: WebSocketProtocolError, reason: "reserved bits are set"}
+	}
+	if h[1]&0x80 == 0 {
+		return fin, opcode, nil, wsCloseError{code: WebSocketProtocolError, reason: "frames from clients must be masked"}
+	}
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if opcode >= wsClose && (n > 125 || !fin) {
+		return fin, opcode, nil, wsCloseError{code: WebSocketProtocolError, reason: "invalid control frame"}
+	}
+	if n > uint64(conf.WebSocketMaxMessageBytes) {
+		return fin, opcode, nil, wsCloseError{code: WebSocketMessageTooBig, reason: "message too big"}
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// queue queues a frame for the write pump, unless the connection has ended.
+func (c *WebSocketConn) queue(f wsFrame) {
+	select {
+	case c.send <- f:
+	case <-c.closing:
+	case <-c.done:
+	}
+}
+
+// writePump writes the frames queued, and pings the client, until the
+// connection is closing, once what is queued has been written, or has ended.
+func (c *WebSocketConn) writePump() {
+	defer c.wg.Done()
+	defer close(c.writerEnd)
+	var ping <-chan time.Time
+	if conf.WebSocketPingInterval > 0 {
+		t := time.NewTicker(conf.WebSocketPingInterval)
+		defer t.Stop()
+		ping = t.C
+	}
+	for {
+		var err error
+		select {
+		case f := <-c.send:
+			err = c.writeFrame(f)
+		case <-ping:
+			err = c.writeFrame(wsFrame{opcode: wsPing})
+		case <-c.closing:
+			c.drain()
+			return
+		case <-c.done:
+			return
+		}
+		if err != nil {
+			c.end()
+			return
+		}
+	}
+}
+
+// drain writes the frames still queued, once the connection is closing.
+func (c *WebSocketConn) drain() {
+	for {
+		select {
+		case f := <-c.send:
+			if c.writeFrame(f) != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// writeFrame writes an unmasked, unfragmented frame.
+func (c *WebSocketConn) writeFrame(f wsFrame) error {
+	b := make([]byte, 0, 10+len(f.payload))
+	b = append(b, 0x80|f.opcode)
+	switch n := len(f.payload); {
+	case n <= 125:
+		b = append(b, byte(n))
+	case n <= 0xffff:
+		b = append(b, 126, byte(n>>8), byte(n))
+	default:
+		var l [8]byte
+		binary.BigEndian.PutUint64(l[:], uint64(n))
+		b = append(append(b, 127), l[:]...)
+	}
+	b = append(b, f.payload...)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// webSocketHandler returns an http.Handler which upgrades requests, and
+// serves their connections with the WebSocketHandler, closing them when it
+// returns, and draining them when the API shuts down.
+func (api *API) webSocketHandler(h WebSocketHandler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, err := UpgradeWebSocket(rw, r)
+		if err != nil {
+			return
+		}
+		ctx, done := api.TrackStream(r, func() {
+			conn.Close(WebSocketServiceRestart, "server restarting")
+		})
+		defer done()
+		go func() {
+			<-ctx.Done()
+			conn.end()
+		}()
+		defer conn.Close(WebSocketNormalClosure, "")
+		h.WebSocket(conn)
+	})
+}
@@ -0,0 +1,105 @@
+package hyperdrive
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+type echoEndpoint struct {
+	*Endpoint
+}
+
+func (e *echoEndpoint) WebSocket(conn *WebSocketConn) {
+	for msg := range conn.Messages() {
+		conn.Send(msg)
+	}
+}
+
+// webSocketClientFrame returns a masked frame, as sent by clients.
+func webSocketClientFrame(opcode byte, payload []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	b := append([]byte{0x80 | opcode, 0x80 | byte(len(payload))}, mask...)
+	for i, c := range payload {
+		b = append(b, c^mask[i%4])
+	}
+	return b
+}
+
+// readWebSocketFrame reads a short, unmasked frame, as sent by the server.
+func readWebSocketFrame(br *bufio.Reader) (byte, []byte, error) {
+	h := make([]byte, 2)
+	if _, err := io.ReadFull(br, h); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, h[1]&0x7f)
+	_, err := io.ReadFull(br, payload)
+	return h[0] & 0x0f, payload, err
+}
+
+func (suite *HyperdriveTestSuite) TestWebSocketEndpoint() {
+	e := &echoEndpoint{NewEndpoint("Echo", "Echoes messages", "/echo", "1")}
+	suite.TestAPI.AddEndpoint(e)
+	srv := httptest.NewServer(suite.TestAPI.Router)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	suite.Require().Nil(err, "expects no error")
+	defer conn.Close()
+	conn.Write([]byte("GET /echo HTTP/1.1\r\nHost: " + conn.RemoteAddr().String() + "\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	suite.Require().Nil(err, "expects no error")
+	suite.Equal(http.StatusSwitchingProtocols, resp.StatusCode, "expects the connection to be upgraded")
+	suite.Equal("s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"), "expects the accept key of RFC 6455")
+
+	conn.Write(webSocketClientFrame(wsText, []byte("hello")))
+	opcode, payload, err := readWebSocketFrame(br)
+	suite.Nil(err, "expects no error")
+	suite.Equal(byte(wsText), opcode, "expects a text message")
+	suite.Equal("hello", string(payload), "expects the message to be echoed")
+
+	conn.Write(webSocketClientFrame(wsPing, []byte("ping")))
+	opcode, payload, _ = readWebSocketFrame(br)
+	suite.Equal(byte(wsPong), opcode, "expects pings to be answered")
+	suite.Equal("ping", string(payload), "expects the payload of the ping")
+
+	conn.Write(webSocketClientFrame(wsClose, []byte{0x03, 0xe8}))
+	opcode, payload, _ = readWebSocketFrame(br)
+	suite.Equal(byte(wsClose), opcode, "expects the close to be acknowledged")
+	suite.Equal(uint16(WebSocketNormalClosure), binary.BigEndian.Uint16(payload), "expects the code of the client")
+}
+
+func (suite *HyperdriveTestSuite) TestUpgradeWebSocketInvalid() {
+	rw := httptest.NewRecorder()
+	_, err := UpgradeWebSocket(rw, httptest.NewRequest("GET", "/echo", nil))
+	suite.Equal(ErrNotWebSocket, err, "expects an error for requests which are not upgrades")
+	suite.Equal(http.StatusUpgradeRequired, rw.Code, "expects a 426")
+
+	r := httptest.NewRequest("GET", "/echo", nil)
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Sec-WebSocket-Version", "8")
+	rw = httptest.NewRecorder()
+	_, err = UpgradeWebSocket(rw, r)
+	suite.Equal(ErrNotWebSocket, err, "expects an error for other versions of the protocol")
+	suite.Equal(http.StatusBadRequest, rw.Code, "expects a 400")
+	suite.Equal("13", rw.Header().Get("Sec-WebSocket-Version"), "expects the supported version")
+}
+
+func (suite *HyperdriveTestSuite) TestWebSocketOriginAllowed() {
+	defer func(c Config) { conf = c }(conf)
+	conf.CorsOrigins = "https://app.example.com"
+	r := httptest.NewRequest("GET", "http://api.example.com/echo", nil)
+	suite.True(webSocketOriginAllowed(r), "expects requests without an Origin to be allowed")
+	r.Header.Set("Origin", "http://api.example.com")
+	suite.True(webSocketOriginAllowed(r), "expects same origin requests to be allowed")
+	r.Header.Set("Origin", "https://app.example.com")
+	suite.True(webSocketOriginAllowed(r), "expects origins in CORS_ORIGINS to be allowed")
+	r.Header.Set("Origin", "https://evil.example.com")
+	suite.False(webSocketOriginAllowed(r), "expects other origins to be rejected")
+}
@@ -0,0 +1,104 @@
+package hyperdrive
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BodyYAML decodes the YAML document in the request body into the same shape
+// as BodyJSON, so clients submitting YAML (e.g. configuration management
+// tools) are served by the same handlers without converting it to JSON
+// first: mappings become objects, with their keys as strings, sequences
+// arrays, numbers json.Number, and timestamps strings formatted as
+// time.RFC3339Nano. Only the first document of a stream is decoded. The body
+// is left unread, so BodyYAML (and BodyParams) can be called more than once.
+func BodyYAML(r *http.Request) (map[string]interface{}, error) {
+	b, err := RawBody(r)
+	if err != nil || len(bytes.TrimSpace(b)) == 0 {
+		return map[string]interface{}{}, err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return map[string]interface{}{}, err
+	}
+	v, err := normalizeYAML(doc)
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+	body, ok := v.(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}, errors.New("yaml: the document must be a mapping")
+	}
+	return body, nil
+}
+
+// normalizeYAML converts a decoded YAML value into the shape of a decoded
+// JSON value.
+func normalizeYAML(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		fields := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			n, err := normalizeYAML(e)
+			if err != nil {
+				return nil, err
+			}
+			fields[k] = n
+		}
+		return fields, nil
+	case map[interface{}]interface{}:
+		fields := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			n, err := normalizeYAML(e)
+			if err != nil {
+				return nil, err
+			}
+			fields[fmt.Sprint(k)] = n
+		}
+		return fields, nil
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, e := range v {
+			n, err := normalizeYAML(e)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = n
+		}
+		return values, nil
+	case int:
+		return json.Number(strconv.Itoa(v)), nil
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), nil
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10)), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, errors.New("yaml: .nan and .inf are not supported")
+		}
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64)), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+	return v, nil
+}
+
+// isYAMLContentType returns true for application/yaml, application/x-yaml,
+// text/yaml, text/x-yaml, and media types with a yaml suffix (+yaml).
+func isYAMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return contains([]string{"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml"}, mediaType) || strings.HasSuffix(mediaType, "+yaml")
+}
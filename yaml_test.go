@@ -0,0 +1,59 @@
+package hyperdrive
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+)
+
+const testYAMLUser = `
+name: test
+limit: 10
+tags: [a, b]
+address:
+  city: Austin
+`
+
+func (suite *HyperdriveTestSuite) TestBodyYAML() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(testYAMLUser))
+	r.Header.Set("Content-Type", "application/yaml")
+	body, err := BodyYAML(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal(map[string]interface{}{
+		"name":    "test",
+		"limit":   json.Number("10"),
+		"tags":    []interface{}{"a", "b"},
+		"address": map[string]interface{}{"city": "Austin"},
+	}, body, "expects the same shape as BodyJSON")
+	suite.Equal([]string{"a", "b"}, BodyParams(r)["tags"], "expects the body to be flattened by BodyParams")
+}
+
+func (suite *HyperdriveTestSuite) TestBodyYAMLTypes() {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader("ratio: 1.5\nenabled: true\nat: 2018-01-02T03:04:00Z\ncodes: {1: one}\nnone: ~\n"))
+	r.Header.Set("Content-Type", "text/yaml")
+	body, err := BodyYAML(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal(json.Number("1.5"), body["ratio"], "expects floats")
+	suite.Equal(true, body["enabled"], "expects booleans")
+	suite.Equal("2018-01-02T03:04:00Z", body["at"], "expects timestamps formatted as RFC 3339")
+	suite.Equal(map[string]interface{}{"1": "one"}, body["codes"], "expects keys to be strings")
+	suite.Nil(body["none"], "expects null")
+}
+
+func (suite *HyperdriveTestSuite) TestBodyYAMLInvalid() {
+	for _, doc := range []string{"- a\n- b\n", "name: [a\n"} {
+		_, err := BodyYAML(httptest.NewRequest("POST", "/test", strings.NewReader(doc)))
+		suite.Error(err, "expects an error for invalid documents")
+	}
+}
+
+func (suite *HyperdriveTestSuite) TestBindYAML() {
+	r := httptest.NewRequest("PUT", "/users/42", strings.NewReader(testYAMLUser))
+	r.Header.Set("Content-Type", "application/x-yaml")
+	target, err := suite.bindRequest(r)
+	suite.Nil(err, "expects no error")
+	suite.Equal("test", target.Name, "expects the field to be decoded")
+	suite.Equal(10, *target.Limit, "expects integers to be decoded")
+	suite.Equal([]string{"a", "b"}, target.Tags, "expects sequences to be decoded")
+	suite.Equal(map[string]string{"city": "Austin"}, target.Address, "expects nested mappings to be decoded")
+}